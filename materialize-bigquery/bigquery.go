@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
 	"cloud.google.com/go/bigquery"
@@ -100,6 +101,13 @@ type tableConfig struct {
 	Table     string `json:"table" jsonschema:"title=Table,description=Table in the BigQuery dataset to store materialized result in." jsonschema_extras:"x-collection-name=true"`
 	Dataset   string `json:"dataset,omitempty" jsonschema:"title=Alternative Dataset,description=Alternative dataset for this table (optional). Must be located in the region set in the endpoint configuration."`
 	Delta     bool   `json:"delta_updates,omitempty" jsonschema:"default=false,title=Delta Update,description=Should updates to this table be done via delta updates. Defaults is false."`
+
+	PartitionField       string   `json:"partition_field,omitempty" jsonschema:"title=Partition Field,description=Field used to partition the table (optional). Date and timestamp fields are truncated to the partition granularity. Integer fields are not supported."`
+	PartitionGranularity string   `json:"partition_granularity,omitempty" jsonschema:"title=Partition Granularity,description=Time unit of partitions for date and timestamp partition fields. Defaults to DAY.,enum=HOUR,enum=DAY,enum=MONTH,enum=YEAR"`
+	ClusterFields        []string `json:"cluster_fields,omitempty" jsonschema:"title=Cluster Fields,description=Up to 4 fields used to cluster the table (optional). Defaults to the first 4 collection key fields."`
+
+	Retention *sql.Retention `json:"retention,omitempty" jsonschema:"title=Retention,description=Remove rows from the table once they are older than a number of days."`
+
 	projectID string
 }
 
@@ -116,6 +124,21 @@ func (c tableConfig) Validate() error {
 	if c.Table == "" {
 		return fmt.Errorf("expected table")
 	}
+	if c.PartitionGranularity != "" {
+		if c.PartitionField == "" {
+			return fmt.Errorf("partition_granularity requires a partition_field")
+		} else if !slices.Contains([]string{"HOUR", "DAY", "MONTH", "YEAR"}, c.PartitionGranularity) {
+			return fmt.Errorf("invalid partition_granularity %q", c.PartitionGranularity)
+		}
+	}
+	if len(c.ClusterFields) > 4 {
+		return fmt.Errorf("at most 4 cluster_fields are allowed, got %d", len(c.ClusterFields))
+	}
+	if c.Retention != nil {
+		if err := c.Retention.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -129,6 +152,35 @@ func (c tableConfig) DeltaUpdates() bool {
 	return c.Delta
 }
 
+// ValidatePartitionColumn verifies that the partition column is a date, datetime or timestamp
+// column, and that date columns aren't partitioned by the hour.
+func (c tableConfig) ValidatePartitionColumn(field string, mapped sql.MappedType) error {
+	switch mapped.NullableDDL {
+	case "TIMESTAMP", "DATETIME":
+		return nil
+	case "DATE":
+		if c.PartitionGranularity == "HOUR" {
+			return fmt.Errorf("partition_field %q is a DATE column which can't be partitioned by HOUR", field)
+		}
+		return nil
+	default:
+		return fmt.Errorf("partition_field %q must be a date or timestamp column but is %s", field, mapped.NullableDDL)
+	}
+}
+
+// TableOptions returns the partitioning, clustering and retention of the table.
+func (c tableConfig) TableOptions() sql.TableOptions {
+	var opts = sql.TableOptions{
+		PartitionGranularity: c.PartitionGranularity,
+		ClusterBy:            c.ClusterFields,
+		Retention:            c.Retention,
+	}
+	if c.PartitionField != "" {
+		opts.PartitionBy = []string{c.PartitionField}
+	}
+	return opts
+}
+
 // decodeCredentials allows support for legacy credentials that were base64 encoded. Previously, the
 // connector required base64 encoding of JSON service account credentials. In the future, this
 // fallback can be removed when base64 encoded credentials are no longer supported and only
@@ -172,17 +224,18 @@ func newBigQueryDriver() *sql.Driver {
 			var metaSpecs, metaCheckpoints = sql.MetaTables(metaBase)
 
 			return &sql.Endpoint{
-				Config:               cfg,
-				Dialect:              bqDialect,
-				MetaSpecs:            &metaSpecs,
-				MetaCheckpoints:      &metaCheckpoints,
-				NewClient:            newClient,
-				CreateTableTemplate:  tplCreateTargetTable,
-				ReplaceTableTemplate: tplReplaceTargetTable,
-				NewResource:          newTableConfig,
-				NewTransactor:        newTransactor,
-				Tenant:               tenant,
-				ConcurrentApply:      true,
+				Config:                cfg,
+				Dialect:               bqDialect,
+				MetaSpecs:             &metaSpecs,
+				MetaCheckpoints:       &metaCheckpoints,
+				NewClient:             newClient,
+				CreateTableTemplate:   tplCreateTargetTable,
+				ReplaceTableTemplate:  tplReplaceTargetTable,
+				DeleteExpiredTemplate: tplDeleteExpired,
+				NewResource:           newTableConfig,
+				NewTransactor:         newTransactor,
+				Tenant:                tenant,
+				ConcurrentApply:       true,
 			}, nil
 		},
 	}
@@ -144,8 +144,40 @@ var (
 flow_temp_table_{{ $.Binding }}
 {{- end }}
 
+-- Templated partitioning and clustering clauses of a table definition. Tables
+-- are clustered by their first 4 key columns unless cluster columns are
+-- configured. Date, datetime and timestamp partition columns are truncated to
+-- the configured granularity, which defaults to DAY.
+-- Note: BigQuery only allows a maximum of 4 columns for clustering, and a single
+-- column for partitioning.
+
+{{ define "tableOptions" -}}
+{{- with $.PartitionColumns }}
+{{- $col := index . 0 }}
+{{- $granularity := or $.Options.PartitionGranularity "DAY" }}
+PARTITION BY
+{{- if eq $col.NullableDDL "TIMESTAMP" }} TIMESTAMP_TRUNC({{ $col.Identifier }}, {{ $granularity }})
+{{- else if eq $col.NullableDDL "DATETIME" }} DATETIME_TRUNC({{ $col.Identifier }}, {{ $granularity }})
+{{- else if and (eq $col.NullableDDL "DATE") (ne $granularity "DAY") }} DATE_TRUNC({{ $col.Identifier }}, {{ $granularity }})
+{{- else }} {{ $col.Identifier }}
+{{- end }}
+{{- end }}
+CLUSTER BY {{ if $.ClusterColumns -}}
+	{{- range $ind, $col := $.ClusterColumns }}
+		{{- if $ind }}, {{end -}}
+			{{$col.Identifier}}
+	{{- end -}}
+{{- else -}}
+	{{- range $ind, $key := $.Keys }}
+		{{- if lt $ind 4 -}}
+			{{- if $ind }}, {{end -}}
+				{{$key.Identifier}}
+			{{- end -}}
+	{{- end -}}
+{{- end -}}
+{{ end }}
+
 -- Templated creation of a materialized table definition and comments.
--- Note: BigQuery only allows a maximum of 4 columns for clustering.
 
 {{ define "createTargetTable" -}}
 CREATE TABLE IF NOT EXISTS {{$.Identifier}} (
@@ -154,12 +186,7 @@ CREATE TABLE IF NOT EXISTS {{$.Identifier}} (
 		{{$col.Identifier}} {{$col.DDL}}
 	{{- end }}
 )
-CLUSTER BY {{ range $ind, $key := $.Keys }}
-	{{- if lt $ind 4 -}}
-		{{- if $ind }}, {{end -}}
-			{{$key.Identifier}}
-		{{- end -}}
-	{{- end}};
+{{- template "tableOptions" $ }};
 {{ end }}
 
 -- Templated creation or replacement of a target table. It's exactly the
@@ -172,12 +199,7 @@ CREATE OR REPLACE TABLE {{$.Identifier}} (
 		{{$col.Identifier}} {{$col.DDL}}
 	{{- end }}
 )
-CLUSTER BY {{ range $ind, $key := $.Keys }}
-	{{- if lt $ind 4 -}}
-		{{- if $ind }}, {{end -}}
-			{{$key.Identifier}}
-		{{- end -}}
-	{{- end}};
+{{- template "tableOptions" $ }};
 {{ end }}
 
 -- Templated query which performs table alterations by adding columns and/or
@@ -275,6 +297,20 @@ WHEN NOT MATCHED THEN
 	);
 {{ end }}
 
+-- Templated deletion of the rows which are older than the retention of a
+-- materialized table. All expired rows are deleted by one statement, and the
+-- expiry is computed in the type of the retention column.
+
+{{ define "deleteExpired" -}}
+{{- $col := $.RetentionColumn -}}
+DELETE FROM {{ $.Identifier }}
+WHERE {{ $col.Identifier }} <
+{{- if eq $col.NullableDDL "DATE" }} DATE_SUB(CURRENT_DATE(), INTERVAL {{ $.Options.Retention.Days }} DAY)
+{{- else if eq $col.NullableDDL "DATETIME" }} DATETIME_SUB(CURRENT_DATETIME(), INTERVAL {{ $.Options.Retention.Days }} DAY)
+{{- else }} TIMESTAMP_SUB(CURRENT_TIMESTAMP(), INTERVAL {{ $.Options.Retention.Days }} DAY)
+{{- end }};
+{{- end }}
+
 {{ define "installFence" }}
 -- Our desired fence
 DECLARE vMaterialization STRING DEFAULT {{ Literal $.Materialization.String }};
@@ -354,4 +390,5 @@ UPDATE {{ Identifier $.TablePath }}
 	tplLoadQuery          = tplAll.Lookup("loadQuery")
 	tplStoreInsert        = tplAll.Lookup("storeInsert")
 	tplStoreUpdate        = tplAll.Lookup("storeUpdate")
+	tplDeleteExpired      = tplAll.Lookup("deleteExpired")
 )
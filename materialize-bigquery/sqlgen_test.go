@@ -125,3 +125,58 @@ func TestSQLGeneration(t *testing.T) {
 
 	cupaloy.SnapshotT(t, snap.String())
 }
+
+func TestPartitionOptions(t *testing.T) {
+	var tpl = tplAll.Lookup("tableOptions")
+
+	for _, tt := range []struct {
+		ddl         string
+		granularity string
+		want        string
+	}{
+		{"TIMESTAMP", "", "PARTITION BY TIMESTAMP_TRUNC(col, DAY)"},
+		{"DATETIME", "HOUR", "PARTITION BY DATETIME_TRUNC(col, HOUR)"},
+		{"DATE", "", "PARTITION BY col\n"},
+		{"DATE", "MONTH", "PARTITION BY DATE_TRUNC(col, MONTH)"},
+	} {
+		var table = sqlDriver.Table{
+			TableShape:       sqlDriver.TableShape{Options: sqlDriver.TableOptions{PartitionGranularity: tt.granularity}},
+			PartitionColumns: []*sqlDriver.Column{{Identifier: "col", MappedType: sqlDriver.MappedType{NullableDDL: tt.ddl}}},
+		}
+
+		var w strings.Builder
+		require.NoError(t, tpl.Execute(&w, &table))
+		require.Contains(t, w.String(), tt.want, tt.ddl)
+	}
+
+	var cfg = tableConfig{PartitionField: "col", PartitionGranularity: "HOUR"}
+	require.NoError(t, cfg.ValidatePartitionColumn("col", sqlDriver.MappedType{NullableDDL: "TIMESTAMP"}))
+	require.ErrorContains(t, cfg.ValidatePartitionColumn("col", sqlDriver.MappedType{NullableDDL: "DATE"}), "can't be partitioned by HOUR")
+	require.ErrorContains(t, cfg.ValidatePartitionColumn("col", sqlDriver.MappedType{NullableDDL: "INTEGER"}), "must be a date or timestamp column")
+
+	cfg.PartitionGranularity = "YEAR"
+	require.NoError(t, cfg.ValidatePartitionColumn("col", sqlDriver.MappedType{NullableDDL: "DATE"}))
+}
+
+func TestDeleteExpired(t *testing.T) {
+	for _, tt := range []struct {
+		ddl  string
+		want string
+	}{
+		{"TIMESTAMP", "WHERE col < TIMESTAMP_SUB(CURRENT_TIMESTAMP(), INTERVAL 30 DAY);"},
+		{"DATETIME", "WHERE col < DATETIME_SUB(CURRENT_DATETIME(), INTERVAL 30 DAY);"},
+		{"DATE", "WHERE col < DATE_SUB(CURRENT_DATE(), INTERVAL 30 DAY);"},
+	} {
+		var table = sqlDriver.Table{
+			TableShape: sqlDriver.TableShape{Options: sqlDriver.TableOptions{
+				Retention: &sqlDriver.Retention{Field: "col", Days: 30},
+			}},
+			Identifier:      "projectID.dataset.target_table",
+			RetentionColumn: &sqlDriver.Column{Identifier: "col", MappedType: sqlDriver.MappedType{NullableDDL: tt.ddl}},
+		}
+
+		var w strings.Builder
+		require.NoError(t, tplDeleteExpired.Execute(&w, &table))
+		require.Equal(t, "DELETE FROM projectID.dataset.target_table\n"+tt.want, w.String(), tt.ddl)
+	}
+}
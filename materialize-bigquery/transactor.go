@@ -29,6 +29,7 @@ type transactor struct {
 
 	bindings    []*binding
 	updateDelay time.Duration
+	retention   *sql.RetentionEnforcer
 }
 
 func newTransactor(
@@ -92,6 +93,10 @@ func newTransactor(
 		}
 	}
 
+	if t.retention, err = sql.NewRetentionEnforcer(bindings, tplDeleteExpired, 0); err != nil {
+		return nil, err
+	}
+
 	return t, nil
 }
 
@@ -156,8 +161,25 @@ func (t *transactor) AckDelay() time.Duration {
 	return t.updateDelay
 }
 
-func (t *transactor) UnmarshalState(state json.RawMessage) error                  { return nil }
-func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) { return nil, nil }
+func (t *transactor) UnmarshalState(state json.RawMessage) error { return nil }
+
+// Acknowledge removes expired rows from tables having a retention, once their transactions have
+// committed.
+func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) {
+	if err := t.retention.Enforce(ctx, func(ctx context.Context, statement string) (int64, error) {
+		job, err := t.client.query(ctx, statement)
+		if err != nil {
+			return 0, err
+		}
+		if stats, ok := job.LastStatus().Statistics.Details.(*bigquery.QueryStatistics); ok {
+			return stats.NumDMLAffectedRows, nil
+		}
+		return 0, nil
+	}); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
 
 func (t *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage) error) error {
 	var ctx = it.Context()
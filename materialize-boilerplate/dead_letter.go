@@ -0,0 +1,245 @@
+package boilerplate
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	pf "github.com/estuary/flow/go/protocols/flow"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeadLetter is a document which permanently failed to be materialized, along with the reason why.
+type DeadLetter struct {
+	Binding      int             `json:"binding"`
+	ResourcePath []string        `json:"resourcePath"`
+	Document     json.RawMessage `json:"document"`
+	Reason       string          `json:"reason"`
+	RejectedAt   time.Time       `json:"rejectedAt"`
+}
+
+// DeadLetterSink stores dead letters somewhere other than the materialized resources, such as a
+// separate index or topic of the endpoint, or files in a cloud storage bucket.
+type DeadLetterSink interface {
+	// WriteDeadLetters durably stores the dead letters.
+	WriteDeadLetters(ctx context.Context, letters []DeadLetter) error
+	// Close releases any resources held by the sink.
+	Close(ctx context.Context) error
+}
+
+// DeadLetterQueue is used by transactors of non-transactional materializations to report documents
+// which permanently failed to be materialized, rather than failing the task. Rejected documents are
+// written to the sink when the queue is flushed, which must happen before the transaction they
+// were rejected in is committed.
+//
+// A nil *DeadLetterQueue is valid and rejects no documents, so that transactors may use one
+// whether or not dead-lettering is configured.
+type DeadLetterQueue struct {
+	sink          DeadLetterSink
+	maxPerTxn     int
+	resourcePaths [][]string
+
+	mu        sync.Mutex
+	pending   []DeadLetter
+	byBinding []int
+	total     int
+}
+
+// DefaultMaxDeadLettersPerTransaction is the number of documents which may be rejected in a single
+// transaction if the materialization doesn't configure it. A finite threshold fails the task when
+// something other than the documents themselves causes every document to be rejected, such as a
+// misconfigured resource.
+const DefaultMaxDeadLettersPerTransaction = 100
+
+// NewDeadLetterQueue returns a DeadLetterQueue for the bindings of a materialization which writes
+// to the sink. The task fails if more than maxPerTransaction documents are rejected in a single
+// transaction, or more than DefaultMaxDeadLettersPerTransaction if maxPerTransaction is 0.
+func NewDeadLetterQueue(sink DeadLetterSink, maxPerTransaction int, bindings []*pf.MaterializationSpec_Binding) *DeadLetterQueue {
+	var resourcePaths [][]string
+	for _, b := range bindings {
+		resourcePaths = append(resourcePaths, b.ResourcePath)
+	}
+	if maxPerTransaction == 0 {
+		maxPerTransaction = DefaultMaxDeadLettersPerTransaction
+	}
+
+	return &DeadLetterQueue{
+		sink:          sink,
+		maxPerTxn:     maxPerTransaction,
+		resourcePaths: resourcePaths,
+		byBinding:     make([]int, len(bindings)),
+	}
+}
+
+// Reject dead-letters a document of the binding which permanently failed with the cause. It returns
+// a non-nil error wrapping the cause if the document can't be dead-lettered, either because there
+// is no queue or because too many documents have been rejected during the transaction. It is safe
+// to call concurrently.
+func (q *DeadLetterQueue) Reject(binding int, doc json.RawMessage, cause error) error {
+	if q == nil {
+		return cause
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) >= q.maxPerTxn {
+		return fmt.Errorf("more than %d documents were rejected in this transaction: %w", q.maxPerTxn, cause)
+	}
+
+	q.pending = append(q.pending, DeadLetter{
+		Binding:      binding,
+		ResourcePath: q.resourcePaths[binding],
+		Document:     doc,
+		Reason:       cause.Error(),
+		RejectedAt:   time.Now().UTC(),
+	})
+	q.byBinding[binding]++
+	q.total++
+
+	return nil
+}
+
+// Flush writes the documents rejected during the transaction to the sink.
+func (q *DeadLetterQueue) Flush(ctx context.Context) error {
+	if q == nil {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return nil
+	} else if err := q.sink.WriteDeadLetters(ctx, q.pending); err != nil {
+		return fmt.Errorf("writing dead letters: %w", err)
+	}
+
+	// The counts of rejected documents of the transaction and since the queue was created are
+	// reported for each resource which had any.
+	var rejected = make(map[string]int)
+	for _, l := range q.pending {
+		rejected[strings.Join(l.ResourcePath, ".")]++
+	}
+	var totals = make(map[string]int)
+	for binding, n := range q.byBinding {
+		if n != 0 {
+			totals[strings.Join(q.resourcePaths[binding], ".")] = n
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"rejected":                len(q.pending),
+		"rejectedByResource":      rejected,
+		"totalRejected":           q.total,
+		"totalRejectedByResource": totals,
+	}).Warn("documents were dead-lettered")
+
+	q.pending = nil
+	return nil
+}
+
+// Close closes the sink of the queue.
+func (q *DeadLetterQueue) Close(ctx context.Context) error {
+	if q == nil {
+		return nil
+	}
+	return q.sink.Close(ctx)
+}
+
+// DeadLetterUploadFn uploads the content of a dead letter file by its name, replacing any previous
+// upload of the same name.
+type DeadLetterUploadFn func(ctx context.Context, name string, r io.Reader) error
+
+// FileDeadLetterSink writes dead letters as JSON lines to a local file, which is uploaded to cloud
+// storage whenever letters are written to it. Files are rotated once they reach a maximum size, and
+// are named by when the sink was created and their sequence number.
+type FileDeadLetterSink struct {
+	upload       DeadLetterUploadFn
+	maxFileBytes int64
+	prefix       string
+
+	file    *os.File
+	written int64
+	seq     int
+}
+
+var _ DeadLetterSink = (*FileDeadLetterSink)(nil)
+
+// NewFileDeadLetterSink returns a FileDeadLetterSink which uploads its files with upload, rotating
+// them once they have at least maxFileBytes.
+func NewFileDeadLetterSink(upload DeadLetterUploadFn, maxFileBytes int64) *FileDeadLetterSink {
+	return &FileDeadLetterSink{
+		upload:       upload,
+		maxFileBytes: maxFileBytes,
+		prefix:       time.Now().UTC().Format("20060102T150405.000Z"),
+	}
+}
+
+func (s *FileDeadLetterSink) fileName() string {
+	return fmt.Sprintf("%s-%06d.jsonl", s.prefix, s.seq)
+}
+
+func (s *FileDeadLetterSink) WriteDeadLetters(ctx context.Context, letters []DeadLetter) error {
+	if s.file == nil {
+		f, err := os.CreateTemp("", "dead-letters-*.jsonl")
+		if err != nil {
+			return fmt.Errorf("creating dead letter file: %w", err)
+		}
+		s.file = f
+	}
+
+	var w = bufio.NewWriter(s.file)
+	var enc = json.NewEncoder(w)
+	for _, l := range letters {
+		if err := enc.Encode(l); err != nil {
+			return fmt.Errorf("writing dead letter: %w", err)
+		}
+	}
+	s.written += int64(w.Buffered())
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("flushing dead letter file: %w", err)
+	}
+
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking dead letter file: %w", err)
+	} else if err := s.upload(ctx, s.fileName(), io.LimitReader(s.file, s.written)); err != nil {
+		return fmt.Errorf("uploading dead letter file %q: %w", s.fileName(), err)
+	} else if _, err := s.file.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("seeking dead letter file: %w", err)
+	}
+
+	if s.written >= s.maxFileBytes {
+		if err := s.removeFile(); err != nil {
+			return err
+		}
+		s.seq++
+	}
+
+	return nil
+}
+
+func (s *FileDeadLetterSink) removeFile() error {
+	if s.file == nil {
+		return nil
+	} else if err := s.file.Close(); err != nil {
+		return fmt.Errorf("closing dead letter file: %w", err)
+	} else if err := os.Remove(s.file.Name()); err != nil {
+		return fmt.Errorf("removing dead letter file: %w", err)
+	}
+
+	s.file = nil
+	s.written = 0
+	return nil
+}
+
+// Close removes the local file. Its letters have already been uploaded.
+func (s *FileDeadLetterSink) Close(ctx context.Context) error {
+	return s.removeFile()
+}
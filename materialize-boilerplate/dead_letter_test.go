@@ -0,0 +1,94 @@
+package boilerplate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryDeadLetterSink struct {
+	letters []DeadLetter
+}
+
+func (s *memoryDeadLetterSink) WriteDeadLetters(_ context.Context, letters []DeadLetter) error {
+	s.letters = append(s.letters, letters...)
+	return nil
+}
+
+func (s *memoryDeadLetterSink) Close(context.Context) error { return nil }
+
+func TestDeadLetterQueue(t *testing.T) {
+	ctx := context.Background()
+	cause := errors.New("mapping conflict")
+
+	// A nil queue rejects nothing.
+	var nilQueue *DeadLetterQueue
+	require.Equal(t, cause, nilQueue.Reject(0, json.RawMessage(`{}`), cause))
+	require.NoError(t, nilQueue.Flush(ctx))
+
+	sink := &memoryDeadLetterSink{}
+	q := NewDeadLetterQueue(sink, 2, []*pf.MaterializationSpec_Binding{
+		{ResourcePath: []string{"first"}},
+		{ResourcePath: []string{"second"}},
+	})
+
+	require.NoError(t, q.Reject(0, json.RawMessage(`{"a":1}`), cause))
+	require.NoError(t, q.Reject(1, json.RawMessage(`{"b":1}`), cause))
+	require.ErrorContains(t, q.Reject(1, json.RawMessage(`{"b":2}`), cause), "more than 2 documents were rejected in this transaction: mapping conflict")
+	require.NoError(t, q.Flush(ctx))
+
+	require.Len(t, sink.letters, 2)
+	require.Equal(t, []string{"second"}, sink.letters[1].ResourcePath)
+	require.Equal(t, json.RawMessage(`{"b":1}`), sink.letters[1].Document)
+	require.Equal(t, "mapping conflict", sink.letters[1].Reason)
+
+	// The threshold applies to each transaction.
+	require.NoError(t, q.Reject(1, json.RawMessage(`{"b":2}`), cause))
+	require.NoError(t, q.Flush(ctx))
+	require.Equal(t, []int{1, 2}, q.byBinding)
+	require.Equal(t, 3, q.total)
+
+	// The threshold is finite if it's not configured.
+	q = NewDeadLetterQueue(sink, 0, []*pf.MaterializationSpec_Binding{{ResourcePath: []string{"first"}}})
+	for i := 0; i != DefaultMaxDeadLettersPerTransaction; i++ {
+		require.NoError(t, q.Reject(0, json.RawMessage(`{}`), cause))
+	}
+	require.ErrorContains(t, q.Reject(0, json.RawMessage(`{}`), cause), "more than 100 documents were rejected")
+}
+
+func TestFileDeadLetterSink(t *testing.T) {
+	ctx := context.Background()
+	uploads := make(map[string]string)
+
+	sink := NewFileDeadLetterSink(func(_ context.Context, name string, r io.Reader) error {
+		b, err := io.ReadAll(r)
+		uploads[name] = string(b)
+		return err
+	}, 200)
+
+	letter := DeadLetter{ResourcePath: []string{"table"}, Document: json.RawMessage(`{"a":1}`), Reason: "too large"}
+	require.NoError(t, sink.WriteDeadLetters(ctx, []DeadLetter{letter}))
+	first := sink.fileName()
+	require.NoError(t, sink.WriteDeadLetters(ctx, []DeadLetter{letter}))
+
+	// The file is re-uploaded with each write until it is rotated.
+	require.Len(t, uploads, 1)
+	require.Equal(t, 2, strings.Count(uploads[first], "\n"))
+
+	require.NoError(t, sink.WriteDeadLetters(ctx, []DeadLetter{letter}))
+	require.Len(t, uploads, 2)
+	require.Equal(t, 1, strings.Count(uploads[sink.fileName()], "\n"))
+	require.NotEqual(t, first, sink.fileName())
+
+	var got DeadLetter
+	require.NoError(t, json.Unmarshal([]byte(strings.Split(uploads[first], "\n")[0]), &got))
+	require.Equal(t, letter, got)
+
+	require.NoError(t, sink.Close(ctx))
+}
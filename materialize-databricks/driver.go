@@ -31,10 +31,19 @@ import (
 const defaultPort = "443"
 const volumeName = "flow_staging"
 
+// optimizeInterval is the minimum amount of time between runs of OPTIMIZE for tables that are
+// Z-ordered.
+const optimizeInterval = 24 * time.Hour
+
 type tableConfig struct {
 	Table  string `json:"table" jsonschema:"title=Table,description=Name of the table" jsonschema_extras:"x-collection-name=true"`
 	Schema string `json:"schema,omitempty" jsonschema:"title=Schema,description=Schema where the table resides"`
 	Delta  bool   `json:"delta_updates,omitempty" jsonschema:"default=false,title=Delta Update,description=Should updates to this table be done via delta updates. Default is false."`
+
+	PartitionFields []string `json:"partition_fields,omitempty" jsonschema:"title=Partition Fields,description=Fields used to partition the table (optional)."`
+	ZOrderFields    []string `json:"zorder_fields,omitempty" jsonschema:"title=Z-Order Fields,description=Fields used to periodically Z-order the table (optional)."`
+
+	Retention *sql.Retention `json:"retention,omitempty" jsonschema:"title=Retention,description=Remove rows from the table once they are older than a number of days."`
 }
 
 func newTableConfig(ep *sql.Endpoint) sql.Resource {
@@ -58,6 +67,12 @@ func (r tableConfig) Validate() error {
 		return fmt.Errorf("schema name %q contains one of the forbidden characters %q", r.Schema, forbiddenChars)
 	}
 
+	if r.Retention != nil {
+		if err := r.Retention.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -69,6 +84,14 @@ func (c tableConfig) DeltaUpdates() bool {
 	return c.Delta
 }
 
+func (c tableConfig) TableOptions() sql.TableOptions {
+	return sql.TableOptions{
+		PartitionBy: c.PartitionFields,
+		ClusterBy:   c.ZOrderFields,
+		Retention:   c.Retention,
+	}
+}
+
 func newDatabricksDriver() *sql.Driver {
 	return &sql.Driver{
 		DocumentationURL: "https://go.estuary.dev/materialize-databricks",
@@ -90,17 +113,18 @@ func newDatabricksDriver() *sql.Driver {
 			var metaSpecs, _ = sql.MetaTables(metaBase)
 
 			return &sql.Endpoint{
-				Config:               cfg,
-				Dialect:              databricksDialect,
-				MetaSpecs:            &metaSpecs,
-				MetaCheckpoints:      nil,
-				NewClient:            newClient,
-				CreateTableTemplate:  tplCreateTargetTable,
-				ReplaceTableTemplate: tplReplaceTargetTable,
-				NewResource:          newTableConfig,
-				NewTransactor:        newTransactor,
-				Tenant:               tenant,
-				ConcurrentApply:      true,
+				Config:                cfg,
+				Dialect:               databricksDialect,
+				MetaSpecs:             &metaSpecs,
+				MetaCheckpoints:       nil,
+				NewClient:             newClient,
+				CreateTableTemplate:   tplCreateTargetTable,
+				ReplaceTableTemplate:  tplReplaceTargetTable,
+				DeleteExpiredTemplate: tplDeleteExpired,
+				NewResource:           newTableConfig,
+				NewTransactor:         newTransactor,
+				Tenant:                tenant,
+				ConcurrentApply:       true,
 			}, nil
 		},
 	}
@@ -133,6 +157,7 @@ type transactor struct {
 	bindings []*binding
 
 	updateDelay time.Duration
+	retention   *sql.RetentionEnforcer
 }
 
 func (t *transactor) Context(ctx context.Context) context.Context {
@@ -235,6 +260,10 @@ func newTransactor(
 		d.localStagingPath = tempDir
 	}
 
+	if d.retention, err = sql.NewRetentionEnforcer(bindings, tplDeleteExpired, 0); err != nil {
+		return nil, err
+	}
+
 	return d, nil
 }
 
@@ -264,6 +293,12 @@ type binding struct {
 
 	mergeInto string
 
+	// optimizeSQL Z-orders the table, if it has any cluster columns. It is run no more often than
+	// optimizeInterval, as tracked by lastOptimized. The lastOptimized of a new binding is zero, so
+	// that restarts of the connector don't put off optimizing the table.
+	optimizeSQL   string
+	lastOptimized time.Time
+
 	copyIntoDirect string
 	copyIntoLoad   string
 	copyIntoStore  string
@@ -299,6 +334,11 @@ func (t *transactor) addBinding(ctx context.Context, target sql.Table, _range *p
 		}
 	}
 
+	var err error
+	if b.optimizeSQL, err = sql.RenderTableTemplate(target, tplOptimizeTable); err != nil {
+		return err
+	}
+
 	t.bindings = append(t.bindings, b)
 
 	// Drop existing temp tables
@@ -543,6 +583,18 @@ func renderWithFiles(tpl string, files ...string) string {
 // Acknowledge merges data from temporary table to main table
 // TODO: run these queries concurrently for improved performance
 func (d *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) {
+	// Remove expired rows from tables having a retention. This happens before the queries of the
+	// checkpoint are run and their files removed, so that a failure leaves them to be run again.
+	if err := d.retention.Enforce(ctx, func(ctx context.Context, statement string) (int64, error) {
+		result, err := d.store.conn.ExecContext(ctx, statement)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}); err != nil {
+		return nil, err
+	}
+
 	log.Info("store: starting committing changes")
 
 	for stateKey, item := range d.cp {
@@ -565,6 +617,10 @@ func (d *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error
 
 		// Cleanup files.
 		d.deleteFiles(ctx, item.ToDelete)
+
+		if err := d.maybeOptimize(ctx, stateKey); err != nil {
+			return nil, err
+		}
 	}
 
 	d.cpRecovery = false
@@ -589,6 +645,24 @@ func (d *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error
 	return &pf.ConnectorState{UpdatedJson: json.RawMessage(checkpointJSON), MergePatch: true}, nil
 }
 
+// maybeOptimize Z-orders the table of the binding with the given state key, if it has cluster
+// columns and it hasn't been optimized within the last optimizeInterval.
+func (d *transactor) maybeOptimize(ctx context.Context, stateKey string) error {
+	for _, b := range d.bindings {
+		if b.target.StateKey != stateKey || b.optimizeSQL == "" || time.Since(b.lastOptimized) < optimizeInterval {
+			continue
+		}
+
+		log.WithField("table", b.target.Identifier).Info("optimizing table")
+		if _, err := d.store.conn.ExecContext(ctx, b.optimizeSQL); err != nil {
+			return fmt.Errorf("query %q failed: %w", b.optimizeSQL, err)
+		}
+		b.lastOptimized = time.Now()
+	}
+
+	return nil
+}
+
 func (d *transactor) hasStateKey(stateKey string) bool {
 	for _, b := range d.bindings {
 		if b.target.StateKey == stateKey {
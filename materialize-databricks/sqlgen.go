@@ -162,6 +162,40 @@ TRUNCATE TABLE {{ template "temp_name_store" . }};
 DROP TABLE IF EXISTS {{ template "temp_name_store" . }}
 {{ end }}
 
+-- Templated partitioning clause of a table definition, if partition columns
+-- are configured.
+{{ define "partitionedBy" -}}
+{{- if $.PartitionColumns }}
+PARTITIONED BY (
+  {{- range $ind, $col := $.PartitionColumns }}
+  {{- if $ind }}, {{end -}}
+  {{$col.Identifier}}
+  {{- end -}}
+)
+{{- end -}}
+{{ end }}
+
+-- Templated Z-ordering of a table by its cluster columns. Databricks does not
+-- support Z-ordering in table definitions, so this is run periodically as data
+-- is merged into the table.
+{{ define "optimizeTable" -}}
+{{- if $.ClusterColumns -}}
+OPTIMIZE {{$.Identifier}} ZORDER BY (
+  {{- range $ind, $col := $.ClusterColumns }}
+  {{- if $ind }}, {{end -}}
+  {{$col.Identifier}}
+  {{- end -}}
+);
+{{- end -}}
+{{ end }}
+
+-- Templated deletion of the rows which are older than the retention of a
+-- materialized table. All expired rows are deleted by one statement.
+{{ define "deleteExpired" -}}
+DELETE FROM {{$.Identifier}}
+WHERE {{$.RetentionColumn.Identifier}} < current_timestamp() - INTERVAL {{$.Options.Retention.Days}} DAYS;
+{{- end }}
+
 -- Templated creation of a materialized table definition and comments:
 {{ define "createTargetTable" }}
 CREATE TABLE IF NOT EXISTS {{$.Identifier}} (
@@ -169,7 +203,8 @@ CREATE TABLE IF NOT EXISTS {{$.Identifier}} (
   {{- if $ind }},{{ end }}
   {{$col.Identifier}} {{$col.DDL}} COMMENT {{ Literal $col.Comment }}
   {{- end }}
-) COMMENT {{ Literal $.Comment }};
+) COMMENT {{ Literal $.Comment }}
+{{- template "partitionedBy" $ }};
 {{ end }}
 
 -- Templated creation or replacement of a target table. It's exactly the
@@ -180,7 +215,8 @@ CREATE OR REPLACE TABLE {{$.Identifier}} (
   {{- if $ind }},{{ end }}
   {{$col.Identifier}} {{$col.DDL}} COMMENT {{ Literal $col.Comment }}
   {{- end }}
-) COMMENT {{ Literal $.Comment }};
+) COMMENT {{ Literal $.Comment }}
+{{- template "partitionedBy" $ }};
 {{ end }}
 
 -- Templated query which performs table alterations by adding columns.
@@ -321,6 +357,8 @@ SELECT -1, ""
 	tplCreateTargetTable  = tplAll.Lookup("createTargetTable")
 	tplReplaceTargetTable = tplAll.Lookup("replaceTargetTable")
 	tplAlterTableColumns  = tplAll.Lookup("alterTableColumns")
+	tplOptimizeTable      = tplAll.Lookup("optimizeTable")
+	tplDeleteExpired      = tplAll.Lookup("deleteExpired")
 	tplCreateLoadTable    = tplAll.Lookup("createLoadTable")
 	tplCreateStoreTable   = tplAll.Lookup("createStoreTable")
 	tplLoadQuery          = tplAll.Lookup("loadQuery")
@@ -28,6 +28,10 @@ func TestSQLGeneration(t *testing.T) {
 		Schema: "default",
 		Table:  "Delta Updates",
 		Delta:  true,
+
+		PartitionFields: []string{"theKey"},
+		ZOrderFields:    []string{"aValue"},
+		Retention:       &sqlDriver.Retention{Field: "aValue", Days: 30},
 	})
 	shape2.Document = nil // TODO(johnny): this is a bit gross.
 
@@ -63,6 +67,7 @@ func TestSQLGeneration(t *testing.T) {
 		for _, tpl := range []*template.Template{
 			tplCreateTargetTable,
 			tplReplaceTargetTable,
+			tplOptimizeTable,
 		} {
 			var testcase = tbl.Identifier + " " + tpl.Name()
 
@@ -82,6 +87,10 @@ func TestSQLGeneration(t *testing.T) {
 	}))
 	snap.WriteString("--- End alter table add columns ---\n\n")
 
+	snap.WriteString("--- Begin " + table2.Identifier + " deleteExpired ---\n")
+	require.NoError(t, tplDeleteExpired.Execute(&snap, &table2))
+	snap.WriteString("\n--- End " + table2.Identifier + " deleteExpired ---\n\n")
+
 	var shapeNoValues = sqlDriver.BuildTableShape(spec, 2, tableConfig{
 		Table: "target_table_no_values_materialized",
 		Delta: false,
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -36,22 +39,34 @@ var (
 type ddbApplier struct {
 	client *client
 	cfg    config
+	// The previously applied spec, which is used to determine the secondary indexes and TTL
+	// attributes that were previously configured for tables.
+	storedSpec *pf.MaterializationSpec
 }
 
 func (e *ddbApplier) CreateMetaTables(ctx context.Context, spec *pf.MaterializationSpec) (string, boilerplate.ActionApplyFn, error) {
 	return fmt.Sprintf("create table %q", metaTableName), func(ctx context.Context) error {
-		return createTable(ctx, e.client, metaTableName, metaTableAttrs, metaTableSchema)
+		return createTable(ctx, e.client, &tableSpec{
+			name:      metaTableName,
+			attrs:     metaTableAttrs,
+			keySchema: metaTableSchema,
+		})
 	}, nil
 }
 
 func (e *ddbApplier) CreateResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int) (string, boilerplate.ActionApplyFn, error) {
 	binding := spec.Bindings[bindingIndex]
+	if sharesTable(spec, bindingIndex) {
+		return "", nil, nil
+	}
 
-	tableName := binding.ResourcePath[0]
-	attrs, schema := tableConfigFromBinding(binding.Collection.Projections)
+	ts, err := tableSpecFromBinding(binding)
+	if err != nil {
+		return "", nil, err
+	}
 
-	return fmt.Sprintf("create table %q", tableName), func(ctx context.Context) error {
-		return createTable(ctx, e.client, tableName, attrs, schema)
+	return fmt.Sprintf("create table %q", ts.name), func(ctx context.Context) error {
+		return createTable(ctx, e.client, ts)
 	}, nil
 }
 
@@ -85,19 +100,116 @@ func (e *ddbApplier) PutSpec(ctx context.Context, spec *pf.MaterializationSpec,
 func (e *ddbApplier) ReplaceResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int) (string, boilerplate.ActionApplyFn, error) {
 	binding := spec.Bindings[bindingIndex]
 
-	tableName := binding.ResourcePath[0]
-	attrs, schema := tableConfigFromBinding(binding.Collection.Projections)
+	if res, err := resolveResourceConfig(binding.ResourceConfigJson); err != nil {
+		return "", nil, err
+	} else if res.SingleTable != nil {
+		// A table of a single-table design has the items of other bindings, and possibly of
+		// other applications, so it isn't dropped. The items of the binding are deleted instead,
+		// so that items of documents which were deleted don't remain after the backfill.
+		table := binding.ResourcePath[0]
+		filter, err := res.SingleTable.itemsFilter()
+		if err != nil {
+			return "", nil, fmt.Errorf("backfilling collection %q of table %q: %w", binding.Collection.Name, table, err)
+		}
+
+		desc, update, err := e.UpdateResource(ctx, spec, bindingIndex, boilerplate.BindingUpdate{})
+		if err != nil {
+			return "", nil, err
+		}
+		deleteDesc := fmt.Sprintf("delete items of table %q where %s", table, filter.description)
+		if desc != "" {
+			desc += "\n"
+		}
+
+		return desc + deleteDesc, func(ctx context.Context) error {
+			if update != nil {
+				if err := update(ctx); err != nil {
+					return err
+				}
+			}
+			return deleteItems(ctx, e.client, table, res.SingleTable.keyAttributes(), filter)
+		}, nil
+	}
+
+	ts, err := tableSpecFromBinding(binding)
+	if err != nil {
+		return "", nil, err
+	}
 
-	return fmt.Sprintf("replace table %q", tableName), func(ctx context.Context) error {
-		return replaceTable(ctx, e.client, tableName, attrs, schema)
+	return fmt.Sprintf("replace table %q", ts.name), func(ctx context.Context) error {
+		return replaceTable(ctx, e.client, ts)
 	}, nil
 }
 
 func (e *ddbApplier) UpdateResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int, bindingUpdate boilerplate.BindingUpdate) (string, boilerplate.ActionApplyFn, error) {
-	// No-op since DynamoDB only applies a schema to the key columns, and Flow doesn't allow you to
-	// change the key of an established collection, and the Validation constraints don't allow
-	// changing the type of a key field in a way that would change its materialized type.
-	return "", nil, nil
+	// DynamoDB only applies a schema to the key attributes of the table and its indexes, and Flow
+	// doesn't allow you to change the key of an established collection, and the Validation
+	// constraints don't allow changing the type of a key field in a way that would change its
+	// materialized type. The secondary indexes, TTL, and capacity of the table are converged to
+	// those of its resource configuration.
+	binding := spec.Bindings[bindingIndex]
+	if sharesTable(spec, bindingIndex) {
+		return "", nil, nil
+	}
+
+	ts, err := tableSpecFromBinding(binding)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var prev *tableSpec
+	if existing, err := findBinding(binding.ResourcePath, e.storedSpec); err != nil {
+		return "", nil, err
+	} else if existing != nil {
+		if prev, err = tableSpecFromBinding(existing); err != nil {
+			return "", nil, fmt.Errorf("resolving previous configuration of table %q: %w", ts.name, err)
+		}
+	}
+
+	updates, err := tableUpdates(ctx, e.client, ts, prev)
+	if err != nil {
+		return "", nil, err
+	} else if len(updates) == 0 {
+		return "", nil, nil
+	}
+
+	var descriptions []string
+	for _, u := range updates {
+		descriptions = append(descriptions, u.description)
+	}
+
+	return strings.Join(descriptions, "\n"), func(ctx context.Context) error {
+		for _, u := range updates {
+			if err := u.apply(ctx); err != nil {
+				return fmt.Errorf("%s: %w", u.description, err)
+			}
+		}
+		return nil
+	}, nil
+}
+
+// sharesTable returns whether a prior binding of the spec materializes to the same table as the
+// binding. Bindings of a single-table design have the same table configuration, which is created
+// and updated for the first of them.
+func sharesTable(spec *pf.MaterializationSpec, bindingIndex int) bool {
+	return slices.ContainsFunc(spec.Bindings[:bindingIndex], func(b *pf.MaterializationSpec_Binding) bool {
+		return b.ResourcePath[0] == spec.Bindings[bindingIndex].ResourcePath[0]
+	})
+}
+
+// findBinding returns the binding of the spec with the resource path, or nil if there is none.
+func findBinding(resourcePath []string, spec *pf.MaterializationSpec) (*pf.MaterializationSpec_Binding, error) {
+	if spec == nil {
+		return nil, nil
+	}
+
+	for _, b := range spec.Bindings {
+		if slices.Equal(b.ResourcePath, resourcePath) {
+			return b, nil
+		}
+	}
+
+	return nil, nil
 }
 
 func getSpec(ctx context.Context, client *client, materialization string) (*pf.MaterializationSpec, error) {
@@ -131,18 +243,18 @@ func getSpec(ctx context.Context, client *client, materialization string) (*pf.M
 	return spec, nil
 }
 
-func createTable(
-	ctx context.Context,
-	client *client,
-	name string,
-	attrs []types.AttributeDefinition,
-	keySchema []types.KeySchemaElement,
-) error {
+func createTable(ctx context.Context, client *client, ts *tableSpec) error {
 	input := &dynamodb.CreateTableInput{
-		AttributeDefinitions: attrs,
-		KeySchema:            keySchema,
-		TableName:            aws.String(name),
-		BillingMode:          types.BillingModePayPerRequest,
+		AttributeDefinitions:   ts.attrs,
+		KeySchema:              ts.keySchema,
+		TableName:              aws.String(ts.name),
+		BillingMode:            types.BillingModePayPerRequest,
+		GlobalSecondaryIndexes: ts.globalIndexes,
+		LocalSecondaryIndexes:  ts.localIndexes,
+	}
+	if ts.billingMode == types.BillingModeProvisioned {
+		input.BillingMode = ts.billingMode
+		input.ProvisionedThroughput = ts.throughput
 	}
 
 	_, err := client.db.CreateTable(ctx, input)
@@ -152,14 +264,25 @@ func createTable(
 		// should not be trying to create tables that already exist, so emit a warning log if that
 		// ever occurs.
 		if !errors.As(err, &errInUse) {
-			return fmt.Errorf("create table %s: %w", name, err)
+			return fmt.Errorf("create table %s: %w", ts.name, err)
 		}
-		log.WithField("table", name).Warn("table already exists")
+		log.WithField("table", ts.name).Warn("table already exists")
 	}
 
-	// Wait for the table to be in an "active" state.
-	maxAttempts := 30
-	for attempt := 0; attempt < maxAttempts; attempt++ {
+	if err := waitForTable(ctx, client, ts.name, tableCreationAttempts); err != nil {
+		return err
+	}
+
+	if ts.ttlAttribute != "" {
+		return setTTL(ctx, client, ts.name, ts.ttlAttribute, true)
+	}
+
+	return nil
+}
+
+// waitForTable waits for a table and its global secondary indexes to be in an "active" state.
+func waitForTable(ctx context.Context, client *client, name string, attempts int) error {
+	for attempt := 0; attempt < attempts; attempt++ {
 		d, err := client.db.DescribeTable(ctx, &dynamodb.DescribeTableInput{
 			TableName: aws.String(name),
 		})
@@ -167,7 +290,11 @@ func createTable(
 			return err
 		}
 
-		if d.Table.TableStatus == types.TableStatusActive {
+		active := d.Table.TableStatus == types.TableStatusActive
+		for _, idx := range d.Table.GlobalSecondaryIndexes {
+			active = active && idx.IndexStatus == types.IndexStatusActive
+		}
+		if active {
 			return nil
 		}
 
@@ -178,16 +305,70 @@ func createTable(
 		time.Sleep(1 * time.Second)
 	}
 
-	return fmt.Errorf("table %s was created but did not become ready in time", name)
+	return fmt.Errorf("table %s was created or updated but did not become ready in time", name)
 }
 
-func replaceTable(
-	ctx context.Context,
-	client *client,
-	name string,
-	attrs []types.AttributeDefinition,
-	keySchema []types.KeySchemaElement,
-) error {
+// deleteItems deletes the items of a table which match the filter, which are found by scanning the
+// table.
+func deleteItems(ctx context.Context, client *client, table string, keyAttrs []string, filter *itemsFilter) error {
+	names := maps.Clone(filter.names)
+	var projection []string
+	for idx, attr := range keyAttrs {
+		name := fmt.Sprintf("#k%d", idx)
+		names[name] = attr
+		projection = append(projection, name)
+	}
+
+	paginator := dynamodb.NewScanPaginator(client.db, &dynamodb.ScanInput{
+		TableName:                 aws.String(table),
+		FilterExpression:          aws.String(filter.expression),
+		ProjectionExpression:      aws.String(strings.Join(projection, ", ")),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: filter.values,
+		ConsistentRead:            aws.Bool(true),
+	})
+
+	var deleted int
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("scanning table %q: %w", table, err)
+		}
+
+		for start := 0; start < len(page.Items); start += storeBatchSize {
+			var requests []types.WriteRequest
+			for _, key := range page.Items[start:min(start+storeBatchSize, len(page.Items))] {
+				requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
+			}
+
+			batch := map[string][]types.WriteRequest{table: requests}
+			// Items which exceed the rate limits of the table are returned as unprocessed, and are
+			// retried.
+			for attempt := 1; len(batch) != 0; attempt++ {
+				res, err := client.db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: batch})
+				if err != nil {
+					return fmt.Errorf("deleting items of table %q: %w", table, err)
+				} else if batch = res.UnprocessedItems; len(batch) != 0 {
+					if err := delay(ctx, attempt, "delete"); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		deleted += len(page.Items)
+	}
+
+	log.WithFields(log.Fields{
+		"table":   table,
+		"filter":  filter.description,
+		"deleted": deleted,
+	}).Info("deleted items of backfilled binding")
+
+	return nil
+}
+
+func replaceTable(ctx context.Context, client *client, ts *tableSpec) error {
+	name := ts.name
 	var errNotFound *types.ResourceNotFoundException
 
 	if _, err := client.db.DeleteTable(ctx, &dynamodb.DeleteTableInput{
@@ -225,33 +406,5 @@ func replaceTable(
 		attempts -= 1
 	}
 
-	return createTable(ctx, client, name, attrs, keySchema)
-}
-
-func tableConfigFromBinding(projections []pf.Projection) ([]types.AttributeDefinition, []types.KeySchemaElement) {
-	mappedKeys := []mappedType{}
-	for _, p := range projections {
-		if p.IsPrimaryKey {
-			mappedKeys = append(mappedKeys, mapType(&p))
-		}
-	}
-
-	// The collection keys will be used as the partition key and sort key, respectively.
-	keyTypes := [2]types.KeyType{types.KeyTypeHash, types.KeyTypeRange}
-
-	attrs := []types.AttributeDefinition{}
-	schema := []types.KeySchemaElement{}
-
-	for idx, k := range mappedKeys {
-		attrs = append(attrs, types.AttributeDefinition{
-			AttributeName: aws.String(k.field),
-			AttributeType: k.ddbScalarType,
-		})
-		schema = append(schema, types.KeySchemaElement{
-			AttributeName: aws.String(k.field),
-			KeyType:       keyTypes[idx],
-		})
-	}
-
-	return attrs, schema
+	return createTable(ctx, client, ts)
 }
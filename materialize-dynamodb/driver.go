@@ -1,16 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"path"
 	"regexp"
+	"slices"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	m "github.com/estuary/connectors/go/protocols/materialize"
 	schemagen "github.com/estuary/connectors/go/schema-gen"
 	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
@@ -23,6 +29,8 @@ type config struct {
 	AWSSecretAccessKey string `json:"awsSecretAccessKey" jsonschema:"title=Secret Access Key,description=AWS Secret Access Key for materializing to DynamoDB." jsonschema_extras:"secret=true,order=2"`
 	Region             string `json:"region" jsonschema:"title=Region,description=Region of the materialized tables." jsonschema_extras:"order=3"`
 
+	DeadLetter *deadLetterConfig `json:"dead_letter,omitempty" jsonschema:"title=Dead Letter Bucket,description=Write documents which DynamoDB rejects to files in an S3 bucket instead of failing the materialization. Items which are too large are rejected for example."`
+
 	Advanced advancedConfig `json:"advanced,omitempty" jsonschema:"title=Advanced Options,description=Options for advanced users. You should not typically need to modify these." jsonschema_extra:"advanced=true"`
 }
 
@@ -30,6 +38,13 @@ type advancedConfig struct {
 	Endpoint string `json:"endpoint,omitempty" jsonschema:"title=AWS Endpoint,description=The AWS endpoint URI to connect to. Use if you're materializing to a compatible API that isn't provided by AWS."`
 }
 
+// deadLetterConfig configures the S3 bucket which files of dead-lettered documents are written to.
+type deadLetterConfig struct {
+	Bucket                     string `json:"bucket" jsonschema:"title=Bucket,description=Name of the S3 bucket to write files of rejected documents to."`
+	Prefix                     string `json:"prefix,omitempty" jsonschema:"title=Prefix,description=Optional prefix of the names of files written to the bucket."`
+	MaxDocumentsPerTransaction int    `json:"max_documents_per_transaction,omitempty" jsonschema:"title=Max Documents Per Transaction,description=Fail the materialization if more than this many documents are rejected in a single transaction. Defaults to 100."`
+}
+
 func (c *config) Validate() error {
 	var requiredProperties = [][]string{
 		{"awsAccessKeyId", c.AWSAccessKeyID},
@@ -42,14 +57,48 @@ func (c *config) Validate() error {
 		}
 	}
 
+	if c.DeadLetter != nil && c.DeadLetter.Bucket == "" {
+		return fmt.Errorf("missing dead letter 'bucket'")
+	} else if c.DeadLetter != nil && c.DeadLetter.MaxDocumentsPerTransaction < 0 {
+		return fmt.Errorf("dead letter 'max_documents_per_transaction' cannot be negative")
+	}
+
 	return nil
 }
 
 type resource struct {
-	Table        string `json:"table" jsonschema:"title=Table Name,description=The name of the table to be materialized to." jsonschema_extras:"x-collection-name=true"`
-	DeltaUpdates bool   `json:"delta_updates,omitempty" jsonschema:"title=Delta updates,default=false"`
+	Table                  string        `json:"table" jsonschema:"title=Table Name,description=The name of the table to be materialized to." jsonschema_extras:"x-collection-name=true"`
+	DeltaUpdates           bool          `json:"delta_updates,omitempty" jsonschema:"title=Delta updates,default=false"`
+	GlobalSecondaryIndexes []globalIndex `json:"globalSecondaryIndexes,omitempty" jsonschema:"title=Global Secondary Indexes,description=Global secondary indexes of the table. Indexes are created and deleted as they are added to and removed from this list."`
+	LocalSecondaryIndexes  []localIndex  `json:"localSecondaryIndexes,omitempty" jsonschema:"title=Local Secondary Indexes,description=Local secondary indexes of the table. These can only be changed when the table is created or re-created by a backfill."`
+	TTLField               string        `json:"ttlField,omitempty" jsonschema:"title=TTL Field,description=Field which is the time to live of items. Its date-time values are stored as epoch seconds and the items are deleted by DynamoDB after that time."`
+	BillingMode            string        `json:"billingMode,omitempty" jsonschema:"title=Billing Mode,description=Capacity mode of the table. New tables are on-demand if this isn't set and the capacity mode of existing tables isn't changed.,enum=PAY_PER_REQUEST,enum=PROVISIONED"`
+	ReadCapacityUnits      int64         `json:"readCapacityUnits,omitempty" jsonschema:"title=Read Capacity Units,description=Provisioned read capacity units of the table and its global secondary indexes."`
+	WriteCapacityUnits     int64         `json:"writeCapacityUnits,omitempty" jsonschema:"title=Write Capacity Units,description=Provisioned write capacity units of the table and its global secondary indexes."`
+	SingleTable            *singleTable  `json:"singleTable,omitempty" jsonschema:"title=Single-Table Design,description=Key items by templates of the collection key instead of the collection key fields. Several bindings with this option can materialize to the same table."`
+}
+
+// globalIndex configures a global secondary index of a table.
+type globalIndex struct {
+	Name           string `json:"name" jsonschema:"title=Index Name"`
+	PartitionKey   string `json:"partitionKey" jsonschema:"title=Partition Key,description=Field which is the partition key of the index."`
+	SortKey        string `json:"sortKey,omitempty" jsonschema:"title=Sort Key,description=Optional field which is the sort key of the index."`
+	ProjectionType string `json:"projectionType,omitempty" jsonschema:"title=Projection Type,description=Attributes which are projected into the index. Defaults to all attributes.,enum=ALL,enum=KEYS_ONLY"`
+}
+
+// localIndex configures a local secondary index of a table, which has the partition key of the
+// table.
+type localIndex struct {
+	Name           string `json:"name" jsonschema:"title=Index Name"`
+	SortKey        string `json:"sortKey" jsonschema:"title=Sort Key,description=Field which is the sort key of the index."`
+	ProjectionType string `json:"projectionType,omitempty" jsonschema:"title=Projection Type,description=Attributes which are projected into the index. Defaults to all attributes.,enum=ALL,enum=KEYS_ONLY"`
 }
 
+const (
+	maxGlobalIndexes = 20
+	maxLocalIndexes  = 5
+)
+
 func (r *resource) Validate() error {
 	var requiredProperties = [][]string{
 		{"table", r.Table},
@@ -64,13 +113,146 @@ func (r *resource) Validate() error {
 		return err
 	}
 
+	if r.SingleTable != nil {
+		if err := r.SingleTable.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if len(r.GlobalSecondaryIndexes) > maxGlobalIndexes {
+		return fmt.Errorf("a table can have at most %d global secondary indexes", maxGlobalIndexes)
+	} else if len(r.LocalSecondaryIndexes) > maxLocalIndexes {
+		return fmt.Errorf("a table can have at most %d local secondary indexes", maxLocalIndexes)
+	}
+
+	var names = make(map[string]bool)
+	var checkIndex = func(name, projectionType string) error {
+		if name == "" {
+			return fmt.Errorf("missing index 'name'")
+		} else if !indexNameRe.MatchString(name) {
+			return fmt.Errorf("index name '%s' is invalid: must be 3 to 255 alphanumeric, dash ('-'), dot ('.'), or underscore ('_') characters", name)
+		} else if names[name] {
+			return fmt.Errorf("index name '%s' is repeated", name)
+		}
+		names[name] = true
+
+		switch types.ProjectionType(projectionType) {
+		case "", types.ProjectionTypeAll, types.ProjectionTypeKeysOnly:
+			return nil
+		default:
+			return fmt.Errorf("index '%s' has invalid projectionType '%s'", name, projectionType)
+		}
+	}
+	for _, idx := range r.GlobalSecondaryIndexes {
+		if err := checkIndex(idx.Name, idx.ProjectionType); err != nil {
+			return err
+		} else if idx.PartitionKey == "" {
+			return fmt.Errorf("missing 'partitionKey' of index '%s'", idx.Name)
+		}
+	}
+	for _, idx := range r.LocalSecondaryIndexes {
+		if err := checkIndex(idx.Name, idx.ProjectionType); err != nil {
+			return err
+		} else if idx.SortKey == "" {
+			return fmt.Errorf("missing 'sortKey' of index '%s'", idx.Name)
+		}
+	}
+
+	switch types.BillingMode(r.BillingMode) {
+	case "", types.BillingModePayPerRequest:
+		if r.ReadCapacityUnits != 0 || r.WriteCapacityUnits != 0 {
+			return fmt.Errorf("'readCapacityUnits' and 'writeCapacityUnits' can only be set for billing mode '%s'", types.BillingModeProvisioned)
+		}
+	case types.BillingModeProvisioned:
+		if r.ReadCapacityUnits <= 0 || r.WriteCapacityUnits <= 0 {
+			return fmt.Errorf("'readCapacityUnits' and 'writeCapacityUnits' must be positive for billing mode '%s'", types.BillingModeProvisioned)
+		}
+	default:
+		return fmt.Errorf("invalid 'billingMode' '%s'", r.BillingMode)
+	}
+
 	return nil
 }
 
+// indexKeys returns the fields which are keys of the resource's secondary indexes.
+func (r *resource) indexKeys() []string {
+	var out []string
+	var add = func(f string) {
+		if f != "" && !slices.Contains(out, f) {
+			out = append(out, f)
+		}
+	}
+
+	for _, idx := range r.GlobalSecondaryIndexes {
+		add(idx.PartitionKey)
+		add(idx.SortKey)
+	}
+	for _, idx := range r.LocalSecondaryIndexes {
+		add(idx.SortKey)
+	}
+
+	return out
+}
+
+// validateProjections returns an error if the secondary indexes and TTL field of the resource
+// aren't valid for projections of the collection.
+func (r *resource) validateProjections(collection pf.CollectionSpec) error {
+	if r.SingleTable != nil {
+		if err := r.SingleTable.validateKey(collection); err != nil {
+			return err
+		} else if len(r.LocalSecondaryIndexes) > 0 && r.SingleTable.SortKey == "" {
+			return fmt.Errorf("local secondary indexes require a table with a sort key, which requires a 'sortKey' template")
+		}
+	} else if len(r.LocalSecondaryIndexes) > 0 && len(collection.Key) < 2 {
+		return fmt.Errorf("local secondary indexes require a table with a sort key, which collection '%s' with a single key does not have", collection.Name)
+	}
+
+	for _, f := range r.indexKeys() {
+		if r.SingleTable != nil && slices.Contains(r.SingleTable.attributes(), f) {
+			// Attributes of the single-table design are strings which are always present.
+			continue
+		}
+
+		p := collection.GetProjection(f)
+		if p == nil {
+			return fmt.Errorf("index key field '%s' is not a projection of collection '%s'", f, collection.Name)
+		} else if mapType(p).ddbScalarType == "" {
+			return fmt.Errorf("index key field '%s' must have a single string, integer, or base64-encoded string type", f)
+		}
+	}
+
+	if r.TTLField != "" {
+		p := collection.GetProjection(r.TTLField)
+		if p == nil {
+			return fmt.Errorf("TTL field '%s' is not a projection of collection '%s'", r.TTLField, collection.Name)
+		} else if p.IsPrimaryKey || slices.Contains(r.indexKeys(), r.TTLField) {
+			return fmt.Errorf("TTL field '%s' cannot be a key of the table or its indexes", r.TTLField)
+		} else if !isTTLProjection(p) {
+			return fmt.Errorf("TTL field '%s' must be a date-time string or an integer of epoch seconds", r.TTLField)
+		}
+	}
+
+	return nil
+}
+
+// path returns the resource path of the resource's binding. Bindings of a single-table design share
+// a table, and are distinguished by their key templates.
+func (r *resource) path(tableName string) []string {
+	if r.SingleTable == nil {
+		return []string{tableName}
+	} else if r.SingleTable.SortKey == "" {
+		return []string{tableName, r.SingleTable.PartitionKey}
+	}
+	return []string{tableName, r.SingleTable.PartitionKey, r.SingleTable.SortKey}
+}
+
 var (
 	tableNameSanitizer = regexp.MustCompile(`[^\.\-_0-9a-zA-Z]`)
 	maxTableNameLength = 255
 	minTableNameLength = 3
+
+	// For index naming requirements, see the table naming requirements.
+	indexNameRe = regexp.MustCompile(`^[\.\-_0-9a-zA-Z]{3,255}$`)
 )
 
 // For table naming requirements, see
@@ -123,11 +305,36 @@ func (c *config) client(ctx context.Context) (*client, error) {
 		return nil, fmt.Errorf("creating aws config: %w", err)
 	}
 
-	return &client{db: dynamodb.NewFromConfig(awsCfg)}, nil
+	return &client{
+		db: dynamodb.NewFromConfig(awsCfg),
+		s3: s3.NewFromConfig(awsCfg),
+	}, nil
 }
 
 type client struct {
 	db *dynamodb.Client
+	s3 *s3.Client
+}
+
+// deadLetterUploader returns a boilerplate.DeadLetterUploadFn which uploads files of dead letters
+// to the bucket under the prefix.
+func (c *client) deadLetterUploader(bucket, prefix string) boilerplate.DeadLetterUploadFn {
+	return func(ctx context.Context, name string, r io.Reader) error {
+		// The content is buffered so that the request body is seekable, which is needed for the
+		// request to be signed.
+		body, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.s3.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(bucket),
+			Key:         aws.String(path.Join(prefix, name)),
+			Body:        bytes.NewReader(body),
+			ContentType: aws.String("application/x-ndjson"),
+		})
+		return err
+	}
 }
 
 func Driver() driver {
@@ -176,12 +383,20 @@ func (d driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Res
 		return nil, err
 	}
 
+	if dl := cfg.DeadLetter; dl != nil {
+		if _, err := client.s3.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(dl.Bucket)}); err != nil {
+			return nil, fmt.Errorf("checking dead letter bucket %q: %w", dl.Bucket, err)
+		}
+	}
+
 	storedSpec, err := getSpec(ctx, client, req.Name.String())
 	if err != nil {
 		return nil, err
 	}
 
-	tableNames := make([]string, 0, len(req.Bindings))
+	var tableNames []string
+	var resources []resource
+	var paths [][]string
 	for _, binding := range req.Bindings {
 		res, err := resolveResourceConfig(binding.ResourceConfigJson)
 		if err != nil {
@@ -193,7 +408,15 @@ func (d driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Res
 			return nil, err
 		}
 
-		tableNames = append(tableNames, tableName)
+		if !slices.Contains(tableNames, tableName) {
+			tableNames = append(tableNames, tableName)
+		}
+		resources = append(resources, res)
+		paths = append(paths, res.path(tableName))
+	}
+
+	if err := validateSharedTables(req.Bindings, resources, paths); err != nil {
+		return nil, err
 	}
 
 	is, err := infoSchema(ctx, client.db, tableNames)
@@ -204,15 +427,12 @@ func (d driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Res
 
 	var bindings = []*pm.Response_Validated_Binding{}
 	for i, binding := range req.Bindings {
-		res, err := resolveResourceConfig(binding.ResourceConfigJson)
-		if err != nil {
-			return nil, fmt.Errorf("building resource for binding %v: %w", binding.Collection.Name.String(), err)
-		}
+		res := resources[i]
 
 		// The primary key for a DynamoDB table is the partition key, and an optional sort key. For
 		// now we only support materializing collections with at most 2 collection keys, to map to
-		// this table structure.
-		if len(binding.Collection.Key) > 2 {
+		// this table structure, unless the keys of items are templates of the collection key.
+		if len(binding.Collection.Key) > 2 && res.SingleTable == nil {
 			return nil, fmt.Errorf(
 				"cannot materialize collection '%s' because it has more than 2 keys (has %d keys)'",
 				binding.Collection.Name.String(),
@@ -221,7 +441,7 @@ func (d driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Res
 		}
 
 		constraints, err := validator.ValidateBinding(
-			[]string{tableNames[i]},
+			paths[i],
 			res.DeltaUpdates,
 			binding.Backfill,
 			binding.Collection,
@@ -232,9 +452,39 @@ func (d driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Res
 			return nil, err
 		}
 
+		// Fields can't be materialized as the attributes which the connector adds to items of a
+		// single-table design.
+		if res.SingleTable != nil {
+			for _, attr := range res.SingleTable.attributes() {
+				if c, ok := constraints[attr]; !ok {
+					continue
+				} else if p := binding.Collection.GetProjection(attr); p.IsPrimaryKey {
+					return nil, fmt.Errorf("collection key field '%s' of collection '%s' is also a single-table attribute of table '%s'", attr, binding.Collection.Name, paths[i][0])
+				} else {
+					c.Type = pm.Response_Validated_Constraint_FIELD_FORBIDDEN
+					c.Reason = "This field is a single-table attribute of the table"
+				}
+			}
+		}
+
+		// Keys of secondary indexes and the TTL field must be materialized as attributes of items.
+		if err := res.validateProjections(binding.Collection); err != nil {
+			return nil, err
+		}
+		for _, f := range append(res.indexKeys(), res.TTLField) {
+			if c, ok := constraints[f]; !ok || f == "" {
+				continue
+			} else if c.Type == pm.Response_Validated_Constraint_FIELD_FORBIDDEN || c.Type == pm.Response_Validated_Constraint_UNSATISFIABLE {
+				return nil, fmt.Errorf("field '%s' of table '%s' cannot be materialized: %s", f, paths[i][0], c.Reason)
+			} else if c.Type != pm.Response_Validated_Constraint_LOCATION_REQUIRED {
+				c.Type = pm.Response_Validated_Constraint_FIELD_REQUIRED
+				c.Reason = "Keys of secondary indexes and the TTL field are required"
+			}
+		}
+
 		bindings = append(bindings, &pm.Response_Validated_Binding{
 			Constraints:  constraints,
-			ResourcePath: []string{tableNames[i]},
+			ResourcePath: paths[i],
 			DeltaUpdates: res.DeltaUpdates,
 		})
 	}
@@ -254,9 +504,12 @@ func (d driver) Apply(ctx context.Context, req *pm.Request_Apply) (*pm.Response_
 		return nil, err
 	}
 
-	tableNames := make([]string, 0, len(req.Materialization.Bindings))
+	var tableNames []string
 	for _, binding := range req.Materialization.Bindings {
-		tableNames = append(tableNames, binding.ResourcePath[0]) // Table names are already normalized in the Validate response.
+		// Table names are already normalized in the Validate response.
+		if !slices.Contains(tableNames, binding.ResourcePath[0]) {
+			tableNames = append(tableNames, binding.ResourcePath[0])
+		}
 	}
 
 	is, err := infoSchema(ctx, client.db, tableNames)
@@ -264,9 +517,15 @@ func (d driver) Apply(ctx context.Context, req *pm.Request_Apply) (*pm.Response_
 		return nil, fmt.Errorf("getting infoSchema for apply: %w", err)
 	}
 
+	storedSpec, err := getSpec(ctx, client, req.Materialization.Name.String())
+	if err != nil {
+		return nil, err
+	}
+
 	return boilerplate.ApplyChanges(ctx, req, &ddbApplier{
-		client: client,
-		cfg:    cfg,
+		client:     client,
+		cfg:        cfg,
+		storedSpec: storedSpec,
 	}, is, true)
 }
 
@@ -282,20 +541,45 @@ func (d driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Tran
 	}
 
 	var bindings []binding
-	tablesToBindings := make(map[string]int)
-	for idx, b := range open.Materialization.Bindings {
-		tablesToBindings[b.ResourcePath[0]] = idx
-		bindings = append(bindings, binding{
+	for _, b := range open.Materialization.Bindings {
+		res, err := resolveResourceConfig(b.ResourceConfigJson)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		binding := binding{
 			tableName: b.ResourcePath[0],
-			fields:    mapFields(b),
+			fields:    mapFields(b, res),
 			docField:  b.FieldSelection.Document,
-		})
+			keyAttrs:  b.FieldSelection.Keys,
+		}
+		if res.SingleTable != nil {
+			var keyPtrs []string
+			for _, k := range b.FieldSelection.Keys {
+				keyPtrs = append(keyPtrs, b.Collection.GetProjection(k).Ptr)
+			}
+			if binding.singleTable, err = newSingleTableKeys(res.SingleTable, keyPtrs); err != nil {
+				return nil, nil, fmt.Errorf("resolving key templates of binding for collection %q: %w", b.Collection.Name, err)
+			}
+			binding.keyAttrs = res.SingleTable.keyAttributes()
+		}
+		bindings = append(bindings, binding)
+	}
+
+	var deadLetters *boilerplate.DeadLetterQueue
+	if dl := cfg.DeadLetter; dl != nil {
+		deadLetters = boilerplate.NewDeadLetterQueue(
+			boilerplate.NewFileDeadLetterSink(client.deadLetterUploader(dl.Bucket, dl.Prefix), deadLetterFileBytes),
+			dl.MaxDocumentsPerTransaction,
+			open.Materialization.Bindings,
+		)
 	}
 
 	return &transactor{
-		client:           client,
-		bindings:         bindings,
-		tablesToBindings: tablesToBindings,
+		client:      client,
+		bindings:    bindings,
+		loadTables:  newLoadTables(bindings),
+		deadLetters: deadLetters,
 	}, &pm.Response_Opened{}, nil
 }
 
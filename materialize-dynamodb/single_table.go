@@ -0,0 +1,458 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	pm "github.com/estuary/flow/go/protocols/materialize"
+)
+
+// singleTable configures a binding which shares its table with other bindings, as in a
+// single-table design. Items of the binding are keyed by templates of its collection key.
+type singleTable struct {
+	PartitionKey          string `json:"partitionKey" jsonschema:"title=Partition Key Template,description=Template of the partition key of items. Locations of the collection key are written as {/pointer}. For example: CUSTOMER#{/customerId}"`
+	SortKey               string `json:"sortKey,omitempty" jsonschema:"title=Sort Key Template,description=Optional template of the sort key of items. For example: ORDER#{/orderId}"`
+	PartitionKeyAttribute string `json:"partitionKeyAttribute,omitempty" jsonschema:"title=Partition Key Attribute,description=Name of the partition key attribute of the table. Defaults to PK."`
+	SortKeyAttribute      string `json:"sortKeyAttribute,omitempty" jsonschema:"title=Sort Key Attribute,description=Name of the sort key attribute of the table. Defaults to SK."`
+	EntityType            string `json:"entityType,omitempty" jsonschema:"title=Entity Type,description=Optional value of the entity type attribute of items which identifies the kind of entity they are."`
+	EntityTypeAttribute   string `json:"entityTypeAttribute,omitempty" jsonschema:"title=Entity Type Attribute,description=Name of the entity type attribute of items. Defaults to entityType."`
+}
+
+const (
+	defaultPartitionKeyAttribute = "PK"
+	defaultSortKeyAttribute      = "SK"
+	defaultEntityTypeAttribute   = "entityType"
+)
+
+func (s *singleTable) Validate() error {
+	if s.PartitionKey == "" {
+		return fmt.Errorf("missing single-table 'partitionKey' template")
+	} else if _, err := parseKeyTemplate(s.PartitionKey); err != nil {
+		return fmt.Errorf("invalid 'partitionKey' template: %w", err)
+	} else if _, err := parseKeyTemplate(s.SortKey); err != nil {
+		return fmt.Errorf("invalid 'sortKey' template: %w", err)
+	} else if s.SortKey == "" && s.SortKeyAttribute != "" {
+		return fmt.Errorf("'sortKeyAttribute' can only be set with a 'sortKey' template")
+	} else if s.EntityType == "" && s.EntityTypeAttribute != "" {
+		return fmt.Errorf("'entityTypeAttribute' can only be set with an 'entityType'")
+	}
+
+	var attrs = s.attributes()
+	for i, a := range attrs {
+		if slices.Contains(attrs[:i], a) {
+			return fmt.Errorf("single-table attribute '%s' is repeated", a)
+		}
+	}
+
+	return nil
+}
+
+func (s *singleTable) partitionKeyAttribute() string {
+	if s.PartitionKeyAttribute == "" {
+		return defaultPartitionKeyAttribute
+	}
+	return s.PartitionKeyAttribute
+}
+
+func (s *singleTable) sortKeyAttribute() string {
+	if s.SortKey == "" {
+		return ""
+	} else if s.SortKeyAttribute == "" {
+		return defaultSortKeyAttribute
+	}
+	return s.SortKeyAttribute
+}
+
+func (s *singleTable) entityTypeAttribute() string {
+	if s.EntityType == "" {
+		return ""
+	} else if s.EntityTypeAttribute == "" {
+		return defaultEntityTypeAttribute
+	}
+	return s.EntityTypeAttribute
+}
+
+// keyAttributes returns the names of the key attributes of the table.
+func (s *singleTable) keyAttributes() []string {
+	if attr := s.sortKeyAttribute(); attr != "" {
+		return []string{s.partitionKeyAttribute(), attr}
+	}
+	return []string{s.partitionKeyAttribute()}
+}
+
+// attributes returns the names of the attributes which the connector adds to items.
+func (s *singleTable) attributes() []string {
+	if attr := s.entityTypeAttribute(); attr != "" {
+		return append(s.keyAttributes(), attr)
+	}
+	return s.keyAttributes()
+}
+
+// validateKey returns an error if the templates don't reference exactly the locations of the
+// collection key, or if placeholders of a template aren't separated by literal text. Every location
+// must be referenced so that documents with different keys have different templated keys, and
+// placeholders must be separated so that their values aren't run together: "{/a}{/b}" is the same
+// for the values "ab" and "c" as for "a" and "bc". The templated keys of different documents may
+// still be the same if values contain the literal text which follows their placeholder.
+func (s *singleTable) validateKey(collection pf.CollectionSpec) error {
+	var referenced []string
+	for _, tmpl := range []string{s.PartitionKey, s.SortKey} {
+		parts, err := parseKeyTemplate(tmpl)
+		if err != nil {
+			return err
+		}
+		for i, p := range parts {
+			if p.ptr == "" {
+				continue
+			} else if !slices.Contains(collection.Key, p.ptr) {
+				return fmt.Errorf("key template location '%s' is not a location of the key of collection '%s'", p.ptr, collection.Name)
+			} else if i > 0 && parts[i-1].ptr != "" {
+				return fmt.Errorf("key template '%s' must separate placeholders '{%s}' and '{%s}' with literal text", tmpl, parts[i-1].ptr, p.ptr)
+			}
+			referenced = append(referenced, p.ptr)
+		}
+	}
+
+	for _, ptr := range collection.Key {
+		if !slices.Contains(referenced, ptr) {
+			return fmt.Errorf("key templates must reference every location of the key of collection '%s', but don't reference '%s'", collection.Name, ptr)
+		}
+	}
+
+	return nil
+}
+
+// validateSharedTables returns an error if bindings which materialize to the same table don't all
+// have a single-table design with the same configuration of the table, or if their items could
+// have the same keys. The partition or sort key templates of each pair of bindings must begin with
+// literal text which distinguishes them, like "ORDER#" and "INVOICE#".
+func validateSharedTables(bindings []*pm.Request_Validate_Binding, resources []resource, paths [][]string) error {
+	for i := range resources {
+		for j := 0; j < i; j++ {
+			table := paths[i][0]
+			if paths[j][0] != table {
+				continue
+			}
+
+			a, b := resources[j], resources[i]
+			collections := []any{bindings[j].Collection.Name, bindings[i].Collection.Name, table}
+			if a.SingleTable == nil || b.SingleTable == nil {
+				return fmt.Errorf("collections '%s' and '%s' are both materialized to table '%s', which requires both of their bindings to configure 'singleTable'", collections...)
+			} else if slices.Equal(paths[i], paths[j]) {
+				return fmt.Errorf("collections '%s' and '%s' are both materialized to table '%s' with the same key templates", collections...)
+			} else if !slices.Equal(a.SingleTable.keyAttributes(), b.SingleTable.keyAttributes()) {
+				return fmt.Errorf("collections '%s' and '%s' are both materialized to table '%s' but have different partition or sort key attributes", collections...)
+			} else if disjoint, err := a.SingleTable.disjointKeys(b.SingleTable); err != nil {
+				return err
+			} else if !disjoint {
+				return fmt.Errorf("collections '%s' and '%s' are both materialized to table '%s' with key templates that may have the same values: the partition or sort key templates of each must begin with literal text which the other's doesn't begin with", collections...)
+			} else if !sameTableOptions(a, b) {
+				return fmt.Errorf("collections '%s' and '%s' are both materialized to table '%s' but have different secondary indexes, TTL fields, or capacity options", collections...)
+			}
+
+			for _, f := range a.indexKeys() {
+				pa, pb := bindings[j].Collection.GetProjection(f), bindings[i].Collection.GetProjection(f)
+				if pa == nil || pb == nil {
+					// Either the field is a single-table attribute, or it's reported as missing
+					// when the projections of the binding are validated.
+					continue
+				} else if mapType(pa).ddbScalarType != mapType(pb).ddbScalarType {
+					return fmt.Errorf("index key field '%s' of table '%s' has different types in collections '%s' and '%s'", f, table, bindings[j].Collection.Name, bindings[i].Collection.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// disjointKeys returns whether items of the single-table bindings can never have the same key,
+// because the literal text which their partition or sort key templates begin with differs.
+func (s *singleTable) disjointKeys(other *singleTable) (bool, error) {
+	for _, tmpls := range [][2]string{{s.PartitionKey, other.PartitionKey}, {s.SortKey, other.SortKey}} {
+		a, err := parseKeyTemplate(tmpls[0])
+		if err != nil {
+			return false, err
+		}
+		b, err := parseKeyTemplate(tmpls[1])
+		if err != nil {
+			return false, err
+		}
+		if a.disjoint(b) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sameTableOptions returns whether the resources configure their table in the same way.
+func sameTableOptions(a, b resource) bool {
+	return slices.Equal(a.GlobalSecondaryIndexes, b.GlobalSecondaryIndexes) &&
+		slices.Equal(a.LocalSecondaryIndexes, b.LocalSecondaryIndexes) &&
+		a.TTLField == b.TTLField &&
+		a.BillingMode == b.BillingMode &&
+		a.ReadCapacityUnits == b.ReadCapacityUnits &&
+		a.WriteCapacityUnits == b.WriteCapacityUnits
+}
+
+// templatePart is either literal text or a placeholder for a location of the collection key.
+type templatePart struct {
+	literal string
+	ptr     string
+	// Index of the location within the key of a document, which is resolved for a binding.
+	keyIdx int
+}
+
+// keyTemplate is a template of a key attribute, like "ORDER#{/orderId}".
+type keyTemplate []templatePart
+
+// parseKeyTemplate parses a template of literal text and placeholders of collection key
+// locations, which are JSON pointers enclosed in braces.
+func parseKeyTemplate(tmpl string) (keyTemplate, error) {
+	var out keyTemplate
+
+	for len(tmpl) != 0 {
+		open := strings.IndexAny(tmpl, "{}")
+		if open == -1 {
+			out = append(out, templatePart{literal: tmpl})
+			break
+		} else if tmpl[open] == '}' {
+			return nil, fmt.Errorf("unexpected '}' without a preceding '{'")
+		} else if open != 0 {
+			out = append(out, templatePart{literal: tmpl[:open]})
+		}
+
+		end := strings.IndexAny(tmpl[open+1:], "{}")
+		if end == -1 || tmpl[open+1+end] != '}' {
+			return nil, fmt.Errorf("placeholder starting at '%s' is not closed by '}'", tmpl[open:])
+		}
+
+		ptr := tmpl[open+1 : open+1+end]
+		if !strings.HasPrefix(ptr, "/") {
+			return nil, fmt.Errorf("placeholder '{%s}' must be a JSON pointer starting with '/'", ptr)
+		}
+		out = append(out, templatePart{ptr: ptr})
+		tmpl = tmpl[open+1+end+1:]
+	}
+
+	return out, nil
+}
+
+// prefix returns the literal text which the template begins with, and whether the template is
+// only literal text.
+func (t keyTemplate) prefix() (string, bool) {
+	if len(t) == 0 {
+		return "", true
+	} else if t[0].ptr != "" {
+		return "", false
+	}
+	return t[0].literal, len(t) == 1
+}
+
+// disjoint returns whether the values of the templates can never be the same, because of the
+// literal text they begin with. A template which is only literal text has exactly that value.
+func (t keyTemplate) disjoint(other keyTemplate) bool {
+	a, aLiteral := t.prefix()
+	b, bLiteral := other.prefix()
+
+	switch {
+	case aLiteral && bLiteral:
+		return a != b
+	case aLiteral:
+		return !strings.HasPrefix(a, b)
+	case bLiteral:
+		return !strings.HasPrefix(b, a)
+	default:
+		return !strings.HasPrefix(a, b) && !strings.HasPrefix(b, a)
+	}
+}
+
+// resolve returns the template with the indices of its locations within the key of documents,
+// which have the keyed locations `keyPtrs`.
+func (t keyTemplate) resolve(keyPtrs []string) (keyTemplate, error) {
+	var out = slices.Clone(t)
+	for i := range out {
+		if out[i].ptr == "" {
+			continue
+		} else if out[i].keyIdx = slices.Index(keyPtrs, out[i].ptr); out[i].keyIdx == -1 {
+			return nil, fmt.Errorf("key template location '%s' is not a selected key location", out[i].ptr)
+		}
+	}
+	return out, nil
+}
+
+// render returns the value of the template for the key of a document.
+func (t keyTemplate) render(key tuple.Tuple) (string, error) {
+	var out strings.Builder
+
+	for _, p := range t {
+		if p.ptr == "" {
+			out.WriteString(p.literal)
+			continue
+		}
+
+		switch v := key[p.keyIdx].(type) {
+		case string:
+			out.WriteString(v)
+		case int64:
+			out.WriteString(strconv.FormatInt(v, 10))
+		case uint64:
+			out.WriteString(strconv.FormatUint(v, 10))
+		case float64:
+			out.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			out.WriteString(strconv.FormatBool(v))
+		default:
+			return "", fmt.Errorf("unsupported type %T (%#v) of key location '%s'", v, v, p.ptr)
+		}
+	}
+
+	return out.String(), nil
+}
+
+// itemsFilter is a filter expression of a Scan which matches the items of a single-table binding.
+type itemsFilter struct {
+	expression string
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	// Human-readable description of the filter.
+	description string
+}
+
+// itemsFilter returns a filter which matches the items of the binding: those with key attributes
+// beginning with the literal text of its key templates, and with its entity type if it has one.
+// Bindings which share a table have templates that begin with distinguishing literal text, so the
+// filter doesn't match items of other bindings. It's an error if the binding has neither literal
+// text nor an entity type to identify its items by.
+func (s *singleTable) itemsFilter() (*itemsFilter, error) {
+	var out = &itemsFilter{names: make(map[string]string), values: make(map[string]types.AttributeValue)}
+	var conditions, descriptions []string
+
+	var add = func(attr, op, value string) {
+		name, placeholder := fmt.Sprintf("#a%d", len(conditions)), fmt.Sprintf(":v%d", len(conditions))
+		out.names[name] = attr
+		out.values[placeholder] = &types.AttributeValueMemberS{Value: value}
+		if op == "begins_with" {
+			conditions = append(conditions, fmt.Sprintf("begins_with(%s, %s)", name, placeholder))
+			descriptions = append(descriptions, fmt.Sprintf("begins_with(%s, %q)", attr, value))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("%s = %s", name, placeholder))
+			descriptions = append(descriptions, fmt.Sprintf("%s = %q", attr, value))
+		}
+	}
+
+	for _, k := range []struct{ attr, tmpl string }{
+		{s.partitionKeyAttribute(), s.PartitionKey},
+		{s.sortKeyAttribute(), s.SortKey},
+	} {
+		if k.attr == "" {
+			continue
+		}
+		parsed, err := parseKeyTemplate(k.tmpl)
+		if err != nil {
+			return nil, err
+		}
+		if prefix, literal := parsed.prefix(); literal {
+			add(k.attr, "=", prefix)
+		} else if prefix != "" {
+			add(k.attr, "begins_with", prefix)
+		}
+	}
+	if attr := s.entityTypeAttribute(); attr != "" {
+		add(attr, "=", s.EntityType)
+	}
+
+	if len(conditions) == 0 {
+		return nil, fmt.Errorf("items of the binding can't be identified: its key templates must begin with literal text, or it must have an 'entityType'")
+	}
+	out.expression = strings.Join(conditions, " AND ")
+	out.description = strings.Join(descriptions, " AND ")
+	return out, nil
+}
+
+// singleTableKeys are the resolved key templates and entity type of a single-table binding.
+type singleTableKeys struct {
+	partitionKeyAttr string
+	partitionKey     keyTemplate
+	sortKeyAttr      string
+	sortKey          keyTemplate
+	entityTypeAttr   string
+	entityType       string
+}
+
+func newSingleTableKeys(s *singleTable, keyPtrs []string) (*singleTableKeys, error) {
+	out := &singleTableKeys{
+		partitionKeyAttr: s.partitionKeyAttribute(),
+		sortKeyAttr:      s.sortKeyAttribute(),
+		entityTypeAttr:   s.entityTypeAttribute(),
+		entityType:       s.EntityType,
+	}
+
+	for _, t := range []struct {
+		tmpl string
+		out  *keyTemplate
+	}{{s.PartitionKey, &out.partitionKey}, {s.SortKey, &out.sortKey}} {
+		parsed, err := parseKeyTemplate(t.tmpl)
+		if err != nil {
+			return nil, err
+		} else if *t.out, err = parsed.resolve(keyPtrs); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// keyAttrs returns the templated key attributes of a document with the key.
+func (k *singleTableKeys) keyAttrs(key tuple.Tuple) (map[string]types.AttributeValue, error) {
+	pk, err := k.partitionKey.render(key)
+	if err != nil {
+		return nil, fmt.Errorf("rendering partition key: %w", err)
+	}
+	out := map[string]types.AttributeValue{
+		k.partitionKeyAttr: &types.AttributeValueMemberS{Value: pk},
+	}
+
+	if k.sortKeyAttr != "" {
+		sk, err := k.sortKey.render(key)
+		if err != nil {
+			return nil, fmt.Errorf("rendering sort key: %w", err)
+		}
+		out[k.sortKeyAttr] = &types.AttributeValueMemberS{Value: sk}
+	}
+
+	return out, nil
+}
+
+// itemKey returns a string which identifies an item of a table by the values of its key
+// attributes.
+func itemKey(table string, item map[string]types.AttributeValue, keyAttrs []string) string {
+	var out strings.Builder
+	out.WriteString(table)
+
+	for _, attr := range keyAttrs {
+		out.WriteByte(0)
+		switch v := item[attr].(type) {
+		case *types.AttributeValueMemberS:
+			out.WriteString("S" + v.Value)
+		case *types.AttributeValueMemberN:
+			// DynamoDB returns numbers in a canonical form which may differ from how they were
+			// written, such as without leading zeros.
+			if r, ok := new(big.Rat).SetString(v.Value); ok {
+				out.WriteString("N" + r.RatString())
+			} else {
+				out.WriteString("N" + v.Value)
+			}
+		case *types.AttributeValueMemberB:
+			out.WriteString("B" + string(v.Value))
+		}
+	}
+
+	return out.String()
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pm "github.com/estuary/flow/go/protocols/materialize"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyTemplate(t *testing.T) {
+	tmpl, err := parseKeyTemplate("CUSTOMER#{/customer}#ORDER#{/id}")
+	require.NoError(t, err)
+	require.Equal(t, keyTemplate{
+		{literal: "CUSTOMER#"},
+		{ptr: "/customer"},
+		{literal: "#ORDER#"},
+		{ptr: "/id"},
+	}, tmpl)
+
+	resolved, err := tmpl.resolve([]string{"/customer", "/id"})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		key  tuple.Tuple
+		want string
+	}{
+		{tuple.Tuple{"acme", int64(42)}, "CUSTOMER#acme#ORDER#42"},
+		{tuple.Tuple{"acme", uint64(18446744073709551615)}, "CUSTOMER#acme#ORDER#18446744073709551615"},
+		{tuple.Tuple{true, 1.5}, "CUSTOMER#true#ORDER#1.5"},
+	} {
+		got, err := resolved.render(tt.key)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, got)
+	}
+
+	_, err = tmpl.resolve([]string{"/customer"})
+	require.ErrorContains(t, err, "key template location '/id' is not a selected key location")
+
+	for tmpl, want := range map[string]string{
+		"ORDER#{/id":    "placeholder starting at '{/id' is not closed",
+		"ORDER#{/{id}}": "placeholder starting at '{/{id}}' is not closed",
+		"ORDER#/id}":    "unexpected '}'",
+		"ORDER#{id}":    "must be a JSON pointer",
+	} {
+		_, err := parseKeyTemplate(tmpl)
+		require.ErrorContains(t, err, want, tmpl)
+	}
+}
+
+func TestSingleTableValidate(t *testing.T) {
+	valid := singleTable{PartitionKey: "CUSTOMER#{/customer}", SortKey: "ORDER#{/id}", EntityType: "order"}
+	require.NoError(t, valid.Validate())
+	require.Equal(t, []string{"PK", "SK"}, valid.keyAttributes())
+	require.Equal(t, []string{"PK", "SK", "entityType"}, valid.attributes())
+	require.NoError(t, valid.validateKey(testBinding(t, resource{Table: "orders"}).Collection))
+
+	for _, tt := range []struct {
+		name   string
+		modify func(*singleTable)
+		want   string
+	}{
+		{"missing partition key", func(s *singleTable) { s.PartitionKey = "" }, "missing single-table 'partitionKey' template"},
+		{"invalid sort key", func(s *singleTable) { s.SortKey = "{id}" }, "invalid 'sortKey' template"},
+		{"sort key attribute without template", func(s *singleTable) { s.SortKey, s.SortKeyAttribute = "", "SK" }, "'sortKeyAttribute' can only be set"},
+		{"entity type attribute without type", func(s *singleTable) { s.EntityType, s.EntityTypeAttribute = "", "type" }, "'entityTypeAttribute' can only be set"},
+		{"repeated attribute", func(s *singleTable) { s.EntityTypeAttribute = "PK" }, "single-table attribute 'PK' is repeated"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid
+			tt.modify(&s)
+			require.ErrorContains(t, s.Validate(), tt.want)
+		})
+	}
+
+	collection := testBinding(t, resource{Table: "orders"}).Collection
+	require.ErrorContains(t, (&singleTable{PartitionKey: "C#{/customer}"}).validateKey(collection), "don't reference '/id'")
+	require.ErrorContains(t, (&singleTable{PartitionKey: "C#{/customer}", SortKey: "{/status}"}).validateKey(collection), "'/status' is not a location of the key")
+	require.ErrorContains(t, (&singleTable{PartitionKey: "C#{/customer}{/id}"}).validateKey(collection), "must separate placeholders '{/customer}' and '{/id}' with literal text")
+	require.NoError(t, (&singleTable{PartitionKey: "{/customer}#{/id}"}).validateKey(collection))
+
+	// Local secondary indexes require a sort key of the table.
+	res := resource{Table: "orders", SingleTable: &singleTable{PartitionKey: "C#{/customer}#{/id}"}, LocalSecondaryIndexes: []localIndex{{Name: "byUpdated", SortKey: "updated"}}}
+	require.ErrorContains(t, res.validateProjections(collection), "requires a 'sortKey' template")
+
+	// Single-table attributes can be keys of secondary indexes.
+	res = resource{Table: "orders", SingleTable: &valid, GlobalSecondaryIndexes: []globalIndex{{Name: "inverted", PartitionKey: "SK", SortKey: "PK"}}}
+	require.NoError(t, res.validateProjections(collection))
+}
+
+func TestValidateSharedTables(t *testing.T) {
+	orders := resource{Table: "acme", SingleTable: &singleTable{PartitionKey: "CUSTOMER#{/customer}", SortKey: "ORDER#{/id}"}, TTLField: "expires"}
+	invoices := resource{Table: "acme", SingleTable: &singleTable{PartitionKey: "CUSTOMER#{/customer}", SortKey: "INVOICE#{/id}"}, TTLField: "expires"}
+
+	validate := func(resources ...resource) error {
+		var bindings []*pm.Request_Validate_Binding
+		var paths [][]string
+		for _, r := range resources {
+			b := testBinding(t, r)
+			bindings = append(bindings, &pm.Request_Validate_Binding{Collection: b.Collection, ResourceConfigJson: b.ResourceConfigJson})
+			paths = append(paths, r.path(r.Table))
+		}
+		return validateSharedTables(bindings, resources, paths)
+	}
+
+	require.NoError(t, validate(orders, invoices, resource{Table: "other"}))
+	require.ErrorContains(t, validate(orders, resource{Table: "acme"}), "requires both of their bindings to configure 'singleTable'")
+	require.ErrorContains(t, validate(orders, invoices, orders), "with the same key templates")
+
+	other := invoices
+	other.SingleTable = &singleTable{PartitionKey: "CUSTOMER#{/customer}#INVOICE#{/id}"}
+	require.ErrorContains(t, validate(orders, other), "different partition or sort key attributes")
+
+	other = invoices
+	other.TTLField = ""
+	require.ErrorContains(t, validate(orders, other), "different secondary indexes, TTL fields, or capacity options")
+
+	// The key templates of bindings sharing a table must begin with distinguishing literal text.
+	for _, tt := range []struct {
+		partitionKey, sortKey string
+		valid                 bool
+	}{
+		{"CUSTOMER#{/customer}", "ORDER#ITEM#{/id}", false},
+		{"CUSTOMER#{/customer}", "{/id}", false},
+		{"CUSTOMER#{/customer}", "ORDER", true},
+		{"CUSTOMER", "ORDER#{/id}", true},
+		{"C{/customer}", "ORDER#{/id}", false},
+	} {
+		other.SingleTable = &singleTable{PartitionKey: tt.partitionKey, SortKey: tt.sortKey}
+		other.TTLField = "expires"
+		if err := validate(orders, other); tt.valid {
+			require.NoError(t, err, tt)
+		} else {
+			require.ErrorContains(t, err, "with key templates that may have the same values", tt)
+		}
+	}
+}
+
+func TestKeyTemplateDisjoint(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{"ORDER#{/id}", "INVOICE#{/id}", true},
+		{"A{/id}", "{/id}", false},
+		{"ORDER#{/id}", "ORDER#ITEM#{/id}", false},
+		{"ORDER", "ORDER#{/id}", true},
+		{"ORDER#", "ORDER{/id}", false},
+		{"ORDER", "ORDERS", true},
+		{"ORDERS", "ORDER", true},
+		{"ORDER", "ORDER", false},
+		{"", "", false},
+	} {
+		a, err := parseKeyTemplate(tt.a)
+		require.NoError(t, err)
+		b, err := parseKeyTemplate(tt.b)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, a.disjoint(b), tt)
+		require.Equal(t, tt.want, b.disjoint(a), tt)
+	}
+}
+
+func TestItemsFilter(t *testing.T) {
+	s := &singleTable{PartitionKey: "CUSTOMER#{/customer}", SortKey: "PROFILE", EntityType: "customer"}
+	filter, err := s.itemsFilter()
+	require.NoError(t, err)
+	require.Equal(t, "begins_with(#a0, :v0) AND #a1 = :v1 AND #a2 = :v2", filter.expression)
+	require.Equal(t, map[string]string{"#a0": "PK", "#a1": "SK", "#a2": "entityType"}, filter.names)
+	require.Equal(t, map[string]types.AttributeValue{
+		":v0": &types.AttributeValueMemberS{Value: "CUSTOMER#"},
+		":v1": &types.AttributeValueMemberS{Value: "PROFILE"},
+		":v2": &types.AttributeValueMemberS{Value: "customer"},
+	}, filter.values)
+	require.Equal(t, `begins_with(PK, "CUSTOMER#") AND SK = "PROFILE" AND entityType = "customer"`, filter.description)
+
+	// Only templates with literal prefixes identify items.
+	filter, err = (&singleTable{PartitionKey: "{/customer}", SortKey: "ORDER#{/id}"}).itemsFilter()
+	require.NoError(t, err)
+	require.Equal(t, "begins_with(#a0, :v0)", filter.expression)
+	require.Equal(t, map[string]string{"#a0": "SK"}, filter.names)
+
+	_, err = (&singleTable{PartitionKey: "{/customer}#{/id}"}).itemsFilter()
+	require.ErrorContains(t, err, "items of the binding can't be identified")
+}
+
+func TestSingleTableConvert(t *testing.T) {
+	res := resource{
+		Table:       "acme",
+		SingleTable: &singleTable{PartitionKey: "CUSTOMER#{/customer}", SortKey: "ORDER#{/id}", EntityType: "order"},
+	}
+	keys, err := newSingleTableKeys(res.SingleTable, []string{"/customer", "/id"})
+	require.NoError(t, err)
+
+	b := binding{
+		tableName:   "acme",
+		fields:      mapFields(testBinding(t, res), res),
+		docField:    "flow_document",
+		keyAttrs:    res.SingleTable.keyAttributes(),
+		singleTable: keys,
+	}
+
+	key, err := b.convertKey(tuple.Tuple{"a", int64(1)})
+	require.NoError(t, err)
+	require.Equal(t, map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "CUSTOMER#a"},
+		"SK": &types.AttributeValueMemberS{Value: "ORDER#1"},
+	}, key)
+
+	item, err := b.convert(tuple.Tuple{"a", int64(1)}, tuple.Tuple{nil, "open", "2024-01-01T00:00:00Z"}, json.RawMessage(`{"customer":"a"}`))
+	require.NoError(t, err)
+	require.Equal(t, &types.AttributeValueMemberS{Value: "CUSTOMER#a"}, item["PK"])
+	require.Equal(t, &types.AttributeValueMemberS{Value: "ORDER#1"}, item["SK"])
+	require.Equal(t, &types.AttributeValueMemberS{Value: "order"}, item["entityType"])
+	require.Equal(t, &types.AttributeValueMemberS{Value: "a"}, item["customer"])
+	require.Equal(t, &types.AttributeValueMemberN{Value: "1"}, item["id"])
+
+	// Loaded items are correlated with their requested keys by their key attributes.
+	require.Equal(t, itemKey("acme", key, b.keyAttrs), itemKey("acme", item, b.keyAttrs))
+	require.NotEqual(t, itemKey("acme", key, b.keyAttrs), itemKey("other", item, b.keyAttrs))
+	require.Equal(t,
+		itemKey("t", map[string]types.AttributeValue{"id": &types.AttributeValueMemberN{Value: "1.50"}}, []string{"id"}),
+		itemKey("t", map[string]types.AttributeValue{"id": &types.AttributeValueMemberN{Value: "1.5"}}, []string{"id"}),
+	)
+}
+
+func TestSingleTableSpecFromBinding(t *testing.T) {
+	ts, err := tableSpecFromBinding(testBinding(t, resource{
+		Table:                  "acme",
+		SingleTable:            &singleTable{PartitionKey: "CUSTOMER#{/customer}", SortKey: "ORDER#{/id}", EntityType: "order"},
+		GlobalSecondaryIndexes: []globalIndex{{Name: "byType", PartitionKey: "entityType", SortKey: "updated", ProjectionType: "KEYS_ONLY"}},
+	}))
+	require.NoError(t, err)
+
+	require.Equal(t, []types.AttributeDefinition{
+		{AttributeName: aws.String("PK"), AttributeType: types.ScalarAttributeTypeS},
+		{AttributeName: aws.String("SK"), AttributeType: types.ScalarAttributeTypeS},
+		{AttributeName: aws.String("entityType"), AttributeType: types.ScalarAttributeTypeS},
+		{AttributeName: aws.String("updated"), AttributeType: types.ScalarAttributeTypeS},
+	}, ts.attrs)
+	require.Equal(t, []types.KeySchemaElement{
+		{AttributeName: aws.String("PK"), KeyType: types.KeyTypeHash},
+		{AttributeName: aws.String("SK"), KeyType: types.KeyTypeRange},
+	}, ts.keySchema)
+}
+
+func TestNewLoadTables(t *testing.T) {
+	lts := newLoadTables([]binding{
+		{tableName: "acme", docField: "flow_document", keyAttrs: []string{"PK", "SK"}},
+		{tableName: "acme", docField: "doc", keyAttrs: []string{"PK", "SK"}},
+		{tableName: "acme", docField: "flow_document", keyAttrs: []string{"PK", "SK"}},
+		{tableName: "other", docField: "flow_document", keyAttrs: []string{"id"}},
+	})
+
+	require.Equal(t, map[string]*loadTable{
+		"acme": {
+			keyAttrs:   []string{"PK", "SK"},
+			projection: "#a0, #a1, #a2, #a3",
+			names:      map[string]string{"#a0": "PK", "#a1": "SK", "#a2": "flow_document", "#a3": "doc"},
+			attrs:      []string{"PK", "SK", "flow_document", "doc"},
+		},
+		"other": {
+			keyAttrs:   []string{"id"},
+			projection: "#a0, #a1",
+			names:      map[string]string{"#a0": "id", "#a1": "flow_document"},
+			attrs:      []string{"id", "flow_document"},
+		},
+	}, lts)
+}
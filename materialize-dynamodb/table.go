@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	pf "github.com/estuary/flow/go/protocols/flow"
+)
+
+const (
+	// Number of seconds to wait for a new table to become active.
+	tableCreationAttempts = 30
+
+	// Number of seconds to wait for a table to become active after it's updated. Creating a global
+	// secondary index of an existing table requires backfilling it, which can take a while.
+	tableUpdateAttempts = 60 * 60
+)
+
+// tableSpec is the desired configuration of a materialized table.
+type tableSpec struct {
+	name          string
+	attrs         []types.AttributeDefinition
+	keySchema     []types.KeySchemaElement
+	globalIndexes []types.GlobalSecondaryIndex
+	localIndexes  []types.LocalSecondaryIndex
+	// Billing mode of the table, which is empty if it's not configured.
+	billingMode types.BillingMode
+	// Provisioned throughput of the table and its global secondary indexes if its billing mode is
+	// provisioned.
+	throughput   *types.ProvisionedThroughput
+	ttlAttribute string
+}
+
+// tableSpecFromBinding returns the table spec of a binding, from the projections of its collection
+// and its resource configuration.
+func tableSpecFromBinding(binding *pf.MaterializationSpec_Binding) (*tableSpec, error) {
+	res, err := resolveResourceConfig(binding.ResourceConfigJson)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &tableSpec{
+		name:         binding.ResourcePath[0],
+		billingMode:  types.BillingMode(res.BillingMode),
+		ttlAttribute: res.TTLField,
+	}
+	if ts.billingMode == types.BillingModeProvisioned {
+		ts.throughput = &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(res.ReadCapacityUnits),
+			WriteCapacityUnits: aws.Int64(res.WriteCapacityUnits),
+		}
+	}
+
+	// addAttr adds the definition of an attribute which is a key of the table or an index.
+	addAttr := func(field string) error {
+		if slices.ContainsFunc(ts.attrs, func(a types.AttributeDefinition) bool { return *a.AttributeName == field }) {
+			return nil
+		} else if res.SingleTable != nil && slices.Contains(res.SingleTable.attributes(), field) {
+			ts.attrs = append(ts.attrs, types.AttributeDefinition{
+				AttributeName: aws.String(field),
+				AttributeType: types.ScalarAttributeTypeS,
+			})
+			return nil
+		}
+
+		p := binding.Collection.GetProjection(field)
+		if p == nil {
+			return fmt.Errorf("key field '%s' is not a projection of collection '%s'", field, binding.Collection.Name)
+		}
+		m := mapType(p)
+		if m.ddbScalarType == "" {
+			return fmt.Errorf("key field '%s' does not have a type which can be a key", field)
+		}
+
+		ts.attrs = append(ts.attrs, types.AttributeDefinition{
+			AttributeName: aws.String(field),
+			AttributeType: m.ddbScalarType,
+		})
+		return nil
+	}
+
+	// The collection keys will be used as the partition key and sort key, respectively, unless the
+	// table has a single-table design with string attributes of templated keys.
+	keyTypes := [2]types.KeyType{types.KeyTypeHash, types.KeyTypeRange}
+	var keyFields []string
+	if res.SingleTable != nil {
+		keyFields = res.SingleTable.keyAttributes()
+	} else {
+		for _, p := range binding.Collection.Projections {
+			if p.IsPrimaryKey {
+				keyFields = append(keyFields, p.Field)
+			}
+		}
+	}
+	for keyIdx, field := range keyFields {
+		if err := addAttr(field); err != nil {
+			return nil, err
+		}
+		ts.keySchema = append(ts.keySchema, types.KeySchemaElement{
+			AttributeName: aws.String(field),
+			KeyType:       keyTypes[keyIdx],
+		})
+	}
+
+	for _, idx := range res.GlobalSecondaryIndexes {
+		gsi := types.GlobalSecondaryIndex{
+			IndexName:             aws.String(idx.Name),
+			Projection:            indexProjection(idx.ProjectionType),
+			ProvisionedThroughput: ts.throughput,
+		}
+		for _, k := range []struct {
+			field   string
+			keyType types.KeyType
+		}{{idx.PartitionKey, types.KeyTypeHash}, {idx.SortKey, types.KeyTypeRange}} {
+			if k.field == "" {
+				continue
+			} else if err := addAttr(k.field); err != nil {
+				return nil, fmt.Errorf("index '%s': %w", idx.Name, err)
+			}
+			gsi.KeySchema = append(gsi.KeySchema, types.KeySchemaElement{
+				AttributeName: aws.String(k.field),
+				KeyType:       k.keyType,
+			})
+		}
+		ts.globalIndexes = append(ts.globalIndexes, gsi)
+	}
+
+	for _, idx := range res.LocalSecondaryIndexes {
+		if err := addAttr(idx.SortKey); err != nil {
+			return nil, fmt.Errorf("index '%s': %w", idx.Name, err)
+		}
+		ts.localIndexes = append(ts.localIndexes, types.LocalSecondaryIndex{
+			IndexName: aws.String(idx.Name),
+			KeySchema: []types.KeySchemaElement{
+				ts.keySchema[0],
+				{AttributeName: aws.String(idx.SortKey), KeyType: types.KeyTypeRange},
+			},
+			Projection: indexProjection(idx.ProjectionType),
+		})
+	}
+
+	return ts, nil
+}
+
+func indexProjection(projectionType string) *types.Projection {
+	if projectionType == "" {
+		return &types.Projection{ProjectionType: types.ProjectionTypeAll}
+	}
+	return &types.Projection{ProjectionType: types.ProjectionType(projectionType)}
+}
+
+// tableUpdate is an update of an existing table.
+type tableUpdate struct {
+	description string
+	apply       func(context.Context) error
+}
+
+// tableUpdates returns the updates which converge the capacity, global secondary indexes, and TTL
+// of an existing table to its spec. Global secondary indexes and TTL attributes of the table are
+// only removed if they were configured by the previously applied spec of the table, which is nil
+// if there isn't one.
+func tableUpdates(ctx context.Context, client *client, ts *tableSpec, prev *tableSpec) ([]tableUpdate, error) {
+	d, err := client.db.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(ts.name)})
+	if err != nil {
+		return nil, fmt.Errorf("describing table %q: %w", ts.name, err)
+	}
+	ttl, err := client.db.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(ts.name)})
+	if err != nil {
+		return nil, fmt.Errorf("describing TTL of table %q: %w", ts.name, err)
+	}
+
+	// The key of a table can't be changed after it's created, which is otherwise only possible if
+	// a binding is changed to or from a single-table design.
+	if !sameKeySchema(d.Table.KeySchema, ts.keySchema) {
+		return nil, fmt.Errorf(
+			"key of table %q is %s but %s is configured: the key of a table can only be changed by re-creating the table",
+			ts.name,
+			describeKeySchema(d.Table.KeySchema),
+			describeKeySchema(ts.keySchema),
+		)
+	}
+
+	// Local secondary indexes can't be changed after a table is created.
+	var existingLocal, wantLocal []string
+	for _, idx := range d.Table.LocalSecondaryIndexes {
+		existingLocal = append(existingLocal, *idx.IndexName)
+	}
+	for _, idx := range ts.localIndexes {
+		wantLocal = append(wantLocal, *idx.IndexName)
+	}
+	slices.Sort(existingLocal)
+	slices.Sort(wantLocal)
+	if !slices.Equal(existingLocal, wantLocal) {
+		return nil, fmt.Errorf(
+			"local secondary indexes of table %q are %v but %v are configured: local secondary indexes can only be changed by re-creating the table, which is done by backfilling the binding",
+			ts.name,
+			existingLocal,
+			wantLocal,
+		)
+	}
+
+	var updates []tableUpdate
+	waitAfter := func(fn func(context.Context) error) func(context.Context) error {
+		return func(ctx context.Context) error {
+			if err := fn(ctx); err != nil {
+				return err
+			}
+			return waitForTable(ctx, client, ts.name, tableUpdateAttempts)
+		}
+	}
+
+	existingGlobal := make(map[string]types.GlobalSecondaryIndexDescription)
+	for _, idx := range d.Table.GlobalSecondaryIndexes {
+		existingGlobal[*idx.IndexName] = idx
+	}
+
+	// The table's capacity is updated first, since any new global secondary indexes have the
+	// table's provisioned throughput.
+	currentMode := types.BillingModeProvisioned
+	if d.Table.BillingModeSummary != nil && d.Table.BillingModeSummary.BillingMode == types.BillingModePayPerRequest {
+		currentMode = types.BillingModePayPerRequest
+	}
+	if ts.billingMode != "" && (ts.billingMode != currentMode || ts.billingMode == types.BillingModeProvisioned && !sameThroughput(d.Table.ProvisionedThroughput, ts.throughput)) {
+		input := &dynamodb.UpdateTableInput{
+			TableName:             aws.String(ts.name),
+			BillingMode:           ts.billingMode,
+			ProvisionedThroughput: ts.throughput,
+		}
+		if ts.billingMode == types.BillingModeProvisioned {
+			// Existing global secondary indexes have the provisioned throughput of the table.
+			for name := range existingGlobal {
+				input.GlobalSecondaryIndexUpdates = append(input.GlobalSecondaryIndexUpdates, types.GlobalSecondaryIndexUpdate{
+					Update: &types.UpdateGlobalSecondaryIndexAction{
+						IndexName:             aws.String(name),
+						ProvisionedThroughput: ts.throughput,
+					},
+				})
+			}
+		}
+
+		updates = append(updates, tableUpdate{
+			description: fmt.Sprintf("set billing mode of table %q to %s", ts.name, ts.billingMode),
+			apply: waitAfter(func(ctx context.Context) error {
+				_, err := client.db.UpdateTable(ctx, input)
+				return err
+			}),
+		})
+	}
+
+	// Global secondary indexes are created, deleted, or re-created one at a time, since only one
+	// can be created or deleted by an update of a table.
+	for _, existing := range d.Table.GlobalSecondaryIndexes {
+		name := *existing.IndexName
+		wantIdx := slices.IndexFunc(ts.globalIndexes, func(idx types.GlobalSecondaryIndex) bool { return *idx.IndexName == name })
+		wasConfigured := prev != nil && slices.ContainsFunc(prev.globalIndexes, func(idx types.GlobalSecondaryIndex) bool { return *idx.IndexName == name })
+
+		if wantIdx == -1 && !wasConfigured {
+			// This index wasn't created by the materialization, and is left alone.
+			continue
+		} else if wantIdx != -1 && sameKeySchema(existing.KeySchema, ts.globalIndexes[wantIdx].KeySchema) &&
+			existing.Projection != nil && existing.Projection.ProjectionType == ts.globalIndexes[wantIdx].Projection.ProjectionType {
+			continue
+		}
+
+		updates = append(updates, tableUpdate{
+			description: fmt.Sprintf("delete global secondary index %q of table %q", name, ts.name),
+			apply: waitAfter(func(ctx context.Context) error {
+				_, err := client.db.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+					TableName: aws.String(ts.name),
+					GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{
+						Delete: &types.DeleteGlobalSecondaryIndexAction{IndexName: aws.String(name)},
+					}},
+				})
+				return err
+			}),
+		})
+		delete(existingGlobal, name)
+	}
+	for _, idx := range ts.globalIndexes {
+		if _, ok := existingGlobal[*idx.IndexName]; ok {
+			continue
+		}
+
+		idx := idx
+		updates = append(updates, tableUpdate{
+			description: fmt.Sprintf("create global secondary index %q of table %q", *idx.IndexName, ts.name),
+			apply: waitAfter(func(ctx context.Context) error {
+				_, err := client.db.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+					TableName:            aws.String(ts.name),
+					AttributeDefinitions: ts.attrs,
+					GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{
+						Create: &types.CreateGlobalSecondaryIndexAction{
+							IndexName:             idx.IndexName,
+							KeySchema:             idx.KeySchema,
+							Projection:            idx.Projection,
+							ProvisionedThroughput: idx.ProvisionedThroughput,
+						},
+					}},
+				})
+				return err
+			}),
+		})
+	}
+
+	// Only one attribute of a table can be its TTL, so TTL must be disabled for a prior attribute
+	// before it's enabled for another.
+	var currentTTL string
+	if desc := ttl.TimeToLiveDescription; desc != nil && desc.AttributeName != nil &&
+		(desc.TimeToLiveStatus == types.TimeToLiveStatusEnabled || desc.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+		currentTTL = *desc.AttributeName
+	}
+	wasConfigured := prev != nil && prev.ttlAttribute != "" && prev.ttlAttribute == currentTTL
+	if currentTTL != "" && currentTTL != ts.ttlAttribute && (ts.ttlAttribute != "" || wasConfigured) {
+		updates = append(updates, tableUpdate{
+			description: fmt.Sprintf("disable TTL attribute %q of table %q", currentTTL, ts.name),
+			apply: func(ctx context.Context) error {
+				return setTTL(ctx, client, ts.name, currentTTL, false)
+			},
+		})
+	}
+	if ts.ttlAttribute != "" && ts.ttlAttribute != currentTTL {
+		updates = append(updates, tableUpdate{
+			description: fmt.Sprintf("enable TTL attribute %q of table %q", ts.ttlAttribute, ts.name),
+			apply: func(ctx context.Context) error {
+				return setTTL(ctx, client, ts.name, ts.ttlAttribute, true)
+			},
+		})
+	}
+
+	return updates, nil
+}
+
+// setTTL enables or disables an attribute as the TTL of a table.
+func setTTL(ctx context.Context, client *client, table, attribute string, enabled bool) error {
+	_, err := client.db.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(table),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attribute),
+			Enabled:       aws.Bool(enabled),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("updating TTL of table %q: %w", table, err)
+	}
+	return nil
+}
+
+func sameKeySchema(a, b []types.KeySchemaElement) bool {
+	return slices.EqualFunc(a, b, func(x, y types.KeySchemaElement) bool {
+		return *x.AttributeName == *y.AttributeName && x.KeyType == y.KeyType
+	})
+}
+
+func describeKeySchema(ks []types.KeySchemaElement) string {
+	var out []string
+	for _, k := range ks {
+		out = append(out, fmt.Sprintf("%s (%s)", *k.AttributeName, k.KeyType))
+	}
+	return "[" + strings.Join(out, ", ") + "]"
+}
+
+func sameThroughput(current *types.ProvisionedThroughputDescription, want *types.ProvisionedThroughput) bool {
+	if current == nil || want == nil {
+		return current == nil && want == nil
+	}
+	return aws.ToInt64(current.ReadCapacityUnits) == aws.ToInt64(want.ReadCapacityUnits) &&
+		aws.ToInt64(current.WriteCapacityUnits) == aws.ToInt64(want.WriteCapacityUnits)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/stretchr/testify/require"
+)
+
+func testBinding(t *testing.T, res resource) *pf.MaterializationSpec_Binding {
+	t.Helper()
+
+	resJson, err := json.Marshal(res)
+	require.NoError(t, err)
+
+	return &pf.MaterializationSpec_Binding{
+		ResourceConfigJson: resJson,
+		ResourcePath:       res.path(res.Table),
+		Collection: pf.CollectionSpec{
+			Name: "acmeCo/orders",
+			Key:  []string{"/customer", "/id"},
+			Projections: []pf.Projection{
+				{Field: "customer", Ptr: "/customer", IsPrimaryKey: true, Inference: pf.Inference{Types: []string{"string"}, String_: &pf.Inference_String{}}},
+				{Field: "expires", Ptr: "/expires", Inference: pf.Inference{Types: []string{"string", "null"}, String_: &pf.Inference_String{Format: "date-time"}}},
+				{Field: "flow_document", Inference: pf.Inference{Types: []string{"object"}}},
+				{Field: "id", Ptr: "/id", IsPrimaryKey: true, Inference: pf.Inference{Types: []string{"integer"}}},
+				{Field: "status", Ptr: "/status", Inference: pf.Inference{Types: []string{"string", "null"}, String_: &pf.Inference_String{}}},
+				{Field: "total", Ptr: "/total", Inference: pf.Inference{Types: []string{"number"}}},
+				{Field: "updated", Ptr: "/updated", Inference: pf.Inference{Types: []string{"string"}, String_: &pf.Inference_String{Format: "date-time"}}},
+			},
+		},
+		FieldSelection: pf.FieldSelection{
+			Keys:     []string{"customer", "id"},
+			Values:   []string{"expires", "status", "updated"},
+			Document: "flow_document",
+		},
+	}
+}
+
+func TestResourceValidate(t *testing.T) {
+	valid := resource{
+		Table:                  "orders",
+		GlobalSecondaryIndexes: []globalIndex{{Name: "byStatus", PartitionKey: "status", SortKey: "updated"}},
+		LocalSecondaryIndexes:  []localIndex{{Name: "byUpdated", SortKey: "updated", ProjectionType: "KEYS_ONLY"}},
+		TTLField:               "expires",
+		BillingMode:            "PROVISIONED",
+		ReadCapacityUnits:      5,
+		WriteCapacityUnits:     5,
+	}
+	require.NoError(t, valid.Validate())
+	require.NoError(t, valid.validateProjections(testBinding(t, valid).Collection))
+	require.Equal(t, []string{"status", "updated"}, valid.indexKeys())
+
+	for _, tt := range []struct {
+		name   string
+		modify func(*resource)
+		want   string
+	}{
+		{"missing index name", func(r *resource) { r.GlobalSecondaryIndexes[0].Name = "" }, "missing index 'name'"},
+		{"invalid index name", func(r *resource) { r.GlobalSecondaryIndexes[0].Name = "by status" }, "index name 'by status' is invalid"},
+		{"repeated index name", func(r *resource) { r.LocalSecondaryIndexes[0].Name = "byStatus" }, "index name 'byStatus' is repeated"},
+		{"missing partition key", func(r *resource) { r.GlobalSecondaryIndexes[0].PartitionKey = "" }, "missing 'partitionKey' of index 'byStatus'"},
+		{"missing sort key", func(r *resource) { r.LocalSecondaryIndexes[0].SortKey = "" }, "missing 'sortKey' of index 'byUpdated'"},
+		{"invalid projection", func(r *resource) { r.GlobalSecondaryIndexes[0].ProjectionType = "INCLUDE" }, "index 'byStatus' has invalid projectionType 'INCLUDE'"},
+		{"invalid billing mode", func(r *resource) { r.BillingMode = "FREE" }, "invalid 'billingMode' 'FREE'"},
+		{"missing capacity", func(r *resource) { r.ReadCapacityUnits = 0 }, "must be positive for billing mode 'PROVISIONED'"},
+		{"capacity of on-demand", func(r *resource) { r.BillingMode = "" }, "can only be set for billing mode 'PROVISIONED'"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			r.GlobalSecondaryIndexes = append([]globalIndex{}, valid.GlobalSecondaryIndexes...)
+			r.LocalSecondaryIndexes = append([]localIndex{}, valid.LocalSecondaryIndexes...)
+			tt.modify(&r)
+			require.ErrorContains(t, r.Validate(), tt.want)
+		})
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(*resource)
+		want   string
+	}{
+		{"unknown index key", func(r *resource) { r.GlobalSecondaryIndexes[0].PartitionKey = "missing" }, "index key field 'missing' is not a projection"},
+		{"non-scalar index key", func(r *resource) { r.GlobalSecondaryIndexes[0].SortKey = "total" }, "index key field 'total' must have a single string"},
+		{"unknown TTL field", func(r *resource) { r.TTLField = "missing" }, "TTL field 'missing' is not a projection"},
+		{"key TTL field", func(r *resource) { r.TTLField = "id" }, "TTL field 'id' cannot be a key"},
+		{"index key TTL field", func(r *resource) { r.TTLField = "updated" }, "TTL field 'updated' cannot be a key"},
+		{"invalid TTL field", func(r *resource) { r.TTLField = "total" }, "TTL field 'total' must be a date-time string or an integer"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			r.GlobalSecondaryIndexes = append([]globalIndex{}, valid.GlobalSecondaryIndexes...)
+			tt.modify(&r)
+			require.ErrorContains(t, r.validateProjections(testBinding(t, valid).Collection), tt.want)
+		})
+	}
+}
+
+func TestTableSpecFromBinding(t *testing.T) {
+	ts, err := tableSpecFromBinding(testBinding(t, resource{
+		Table:                  "orders",
+		GlobalSecondaryIndexes: []globalIndex{{Name: "byStatus", PartitionKey: "status", SortKey: "updated"}},
+		LocalSecondaryIndexes:  []localIndex{{Name: "byUpdated", SortKey: "updated", ProjectionType: "KEYS_ONLY"}},
+		TTLField:               "expires",
+		BillingMode:            "PROVISIONED",
+		ReadCapacityUnits:      5,
+		WriteCapacityUnits:     10,
+	}))
+	require.NoError(t, err)
+
+	throughput := &types.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(5), WriteCapacityUnits: aws.Int64(10)}
+	require.Equal(t, &tableSpec{
+		name: "orders",
+		attrs: []types.AttributeDefinition{
+			{AttributeName: aws.String("customer"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeN},
+			{AttributeName: aws.String("status"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("updated"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		keySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("customer"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeRange},
+		},
+		globalIndexes: []types.GlobalSecondaryIndex{{
+			IndexName: aws.String("byStatus"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("status"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("updated"), KeyType: types.KeyTypeRange},
+			},
+			Projection:            &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			ProvisionedThroughput: throughput,
+		}},
+		localIndexes: []types.LocalSecondaryIndex{{
+			IndexName: aws.String("byUpdated"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("customer"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("updated"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+		}},
+		billingMode:  types.BillingModeProvisioned,
+		throughput:   throughput,
+		ttlAttribute: "expires",
+	}, ts)
+}
+
+func TestConvertIndexAndTTLFields(t *testing.T) {
+	res := resource{
+		Table:                  "orders",
+		GlobalSecondaryIndexes: []globalIndex{{Name: "byStatus", PartitionKey: "status"}},
+		TTLField:               "expires",
+	}
+	b := binding{tableName: "orders", fields: mapFields(testBinding(t, res), res), docField: "flow_document"}
+
+	// The TTL is stored as epoch seconds.
+	item, err := b.convert(tuple.Tuple{"a", int64(1)}, tuple.Tuple{"2024-01-02T03:04:05Z", "open", "2024-01-01T00:00:00Z"}, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	require.Equal(t, &types.AttributeValueMemberN{Value: "1704164645"}, item["expires"])
+	require.Equal(t, &types.AttributeValueMemberS{Value: "open"}, item["status"])
+
+	// Null values of index keys and the TTL are omitted from items, while null values of other
+	// attributes are not.
+	res.GlobalSecondaryIndexes = nil
+	b.fields = mapFields(testBinding(t, res), res)
+	item, err = b.convert(tuple.Tuple{"a", int64(1)}, tuple.Tuple{nil, nil, "2024-01-01T00:00:00Z"}, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	require.NotContains(t, item, "expires")
+	require.Equal(t, &types.AttributeValueMemberNULL{Value: true}, item["status"])
+
+	_, err = convertTTL("not a date-time")
+	require.ErrorContains(t, err, "parsing TTL date-time")
+}
+
+func TestApplyTableUpdates(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := testConfig().client(ctx)
+	require.NoError(t, err)
+
+	res := resource{
+		Table:                 "indexes",
+		LocalSecondaryIndexes: []localIndex{{Name: "byUpdated", SortKey: "updated"}},
+	}
+	ts, err := tableSpecFromBinding(testBinding(t, res))
+	require.NoError(t, err)
+	require.NoError(t, replaceTable(ctx, client, ts))
+	defer client.db.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(res.Table)})
+
+	// The table is converged to its configured global secondary indexes, TTL and capacity.
+	prev := ts
+	res.GlobalSecondaryIndexes = []globalIndex{{Name: "byStatus", PartitionKey: "status"}}
+	res.TTLField = "expires"
+	res.BillingMode = "PROVISIONED"
+	res.ReadCapacityUnits, res.WriteCapacityUnits = 2, 3
+	ts, err = tableSpecFromBinding(testBinding(t, res))
+	require.NoError(t, err)
+
+	updates, err := tableUpdates(ctx, client, ts, prev)
+	require.NoError(t, err)
+	var descriptions []string
+	for _, u := range updates {
+		descriptions = append(descriptions, u.description)
+		require.NoError(t, u.apply(ctx))
+	}
+	require.Equal(t, []string{
+		`set billing mode of table "indexes" to PROVISIONED`,
+		`create global secondary index "byStatus" of table "indexes"`,
+		`enable TTL attribute "expires" of table "indexes"`,
+	}, descriptions)
+
+	d, err := client.db.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(res.Table)})
+	require.NoError(t, err)
+	require.Len(t, d.Table.GlobalSecondaryIndexes, 1)
+	require.Equal(t, "byStatus", *d.Table.GlobalSecondaryIndexes[0].IndexName)
+	require.Equal(t, int64(2), *d.Table.ProvisionedThroughput.ReadCapacityUnits)
+
+	// There is nothing to do once the table is converged.
+	updates, err = tableUpdates(ctx, client, ts, prev)
+	require.NoError(t, err)
+	require.Empty(t, updates)
+
+	// Indexes which are removed from the configuration are deleted, and local secondary indexes
+	// can't be changed.
+	prev = ts
+	res.GlobalSecondaryIndexes = nil
+	ts, err = tableSpecFromBinding(testBinding(t, res))
+	require.NoError(t, err)
+	updates, err = tableUpdates(ctx, client, ts, prev)
+	require.NoError(t, err)
+	require.Len(t, updates, 1)
+	require.Equal(t, `delete global secondary index "byStatus" of table "indexes"`, updates[0].description)
+
+	res.LocalSecondaryIndexes = nil
+	ts, err = tableSpecFromBinding(testBinding(t, res))
+	require.NoError(t, err)
+	_, err = tableUpdates(ctx, client, ts, prev)
+	require.ErrorContains(t, err, "local secondary indexes can only be changed by re-creating the table")
+}
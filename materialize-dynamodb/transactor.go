@@ -3,14 +3,20 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
 	m "github.com/estuary/connectors/go/protocols/materialize"
+	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
 	"github.com/estuary/flow/go/protocols/fdb/tuple"
 	pf "github.com/estuary/flow/go/protocols/flow"
 	log "github.com/sirupsen/logrus"
@@ -33,30 +39,109 @@ const (
 
 	// Maximum number of retry attempts before failing with an error.
 	maxAttempts = 30
+
+	// Files of dead-lettered documents are rotated once they are at least this large.
+	deadLetterFileBytes = 16 * 1024 * 1024
 )
 
 type transactor struct {
 	client   *client
 	bindings []binding
 
-	// Allows for correlating a table name back into a binding number. This is needed for loads
-	// since DynamoDB allows for "get" batches to include different tables, and the results will
-	// have the table names for each returned record. This is better than grouping "get" batches by
-	// table since load requests are received randomly by binding.
-	tablesToBindings map[string]int
+	// How items are loaded from each table, which may be shared by several bindings.
+	loadTables map[string]*loadTable
+
+	// Items which DynamoDB rejects are dead-lettered if this is configured.
+	deadLetters *boilerplate.DeadLetterQueue
+}
+
+// loadTable is the projection of the attributes which are loaded from items of a table.
+type loadTable struct {
+	// Names of the key attributes of the table, which identify the binding of loaded items.
+	keyAttrs []string
+	// ProjectionExpression is for handling strange cases where the root document field has been
+	// projected as a field that clashes with a reserved word, or contains a dot. See:
+	// https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Expressions.ExpressionAttributeNames.html#Expressions.ExpressionAttributeNames.AttributeNamesContainingSpecialCharacters
+	projection string
+	names      map[string]string
+	// Names of the projected attributes.
+	attrs []string
+}
+
+// newLoadTables returns the projections of the key attributes and the document fields of the
+// bindings of each table.
+func newLoadTables(bindings []binding) map[string]*loadTable {
+	out := make(map[string]*loadTable)
+
+	for _, b := range bindings {
+		lt, ok := out[b.tableName]
+		if !ok {
+			lt = &loadTable{keyAttrs: b.keyAttrs, names: make(map[string]string)}
+			out[b.tableName] = lt
+		}
+
+		for _, attr := range append(slices.Clone(b.keyAttrs), b.docField) {
+			if attr == "" || slices.Contains(lt.attrs, attr) {
+				continue
+			}
+			name := fmt.Sprintf("#a%d", len(lt.names))
+			lt.names[name] = attr
+			lt.attrs = append(lt.attrs, attr)
+			lt.projection = strings.TrimPrefix(lt.projection+", "+name, ", ")
+		}
+	}
+
+	return out
+}
+
+// loadBatch is a batch of keys to get from each table.
+type loadBatch struct {
+	requests map[string]types.KeysAndAttributes
+	// Bindings of the requested items, by the itemKey of each item.
+	bindings map[string]int
+}
+
+func newLoadBatch() loadBatch {
+	return loadBatch{
+		requests: make(map[string]types.KeysAndAttributes),
+		bindings: make(map[string]int),
+	}
+}
+
+// storeBatch is a batch of items to write to each table, along with the documents and bindings that
+// the items were converted from.
+type storeBatch struct {
+	requests map[string][]types.WriteRequest
+	docs     map[string][]json.RawMessage
+	bindings map[string][]int
+}
+
+func newStoreBatch() storeBatch {
+	return storeBatch{
+		requests: make(map[string][]types.WriteRequest),
+		docs:     make(map[string][]json.RawMessage),
+		bindings: make(map[string][]int),
+	}
 }
 
 type binding struct {
 	tableName string
 	fields    []mappedType
 	docField  string
+	// Names of the key attributes of the table.
+	keyAttrs []string
+	// Templated keys of items if the table has a single-table design, or nil otherwise.
+	singleTable *singleTableKeys
 }
 
-func (b binding) convertKey(ts tuple.Tuple) (map[string]types.AttributeValue, error) {
-	return b.convert(ts, nil)
+func (b binding) convertKey(key tuple.Tuple) (map[string]types.AttributeValue, error) {
+	if b.singleTable != nil {
+		return b.singleTable.keyAttrs(key)
+	}
+	return b.convert(key, nil, nil)
 }
 
-func (b binding) convert(ts tuple.Tuple, doc json.RawMessage) (map[string]types.AttributeValue, error) {
+func (b binding) convert(key, values tuple.Tuple, doc json.RawMessage) (map[string]types.AttributeValue, error) {
 	vals := make(map[string]any)
 
 	fieldsIdx := 0
@@ -67,13 +152,15 @@ func (b binding) convert(ts tuple.Tuple, doc json.RawMessage) (map[string]types.
 			return fmt.Errorf("converting field %s: %w", f.field, err)
 		}
 
-		vals[f.field] = c
+		if c != nil || !f.omitNull {
+			vals[f.field] = c
+		}
 		fieldsIdx++
 
 		return nil
 	}
 
-	for _, t := range ts {
+	for _, t := range append(key[:len(key):len(key)], values...) {
 		if err := do(t); err != nil {
 			return nil, err
 		}
@@ -90,6 +177,18 @@ func (b binding) convert(ts tuple.Tuple, doc json.RawMessage) (map[string]types.
 		return nil, fmt.Errorf("marshalMap: %w", err)
 	}
 
+	if b.singleTable != nil {
+		keyAttrs, err := b.singleTable.keyAttrs(key)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(attrs, keyAttrs)
+
+		if b.singleTable.entityTypeAttr != "" {
+			attrs[b.singleTable.entityTypeAttr] = &types.AttributeValueMemberS{Value: b.singleTable.entityType}
+		}
+	}
+
 	return attrs, nil
 }
 
@@ -103,17 +202,17 @@ func (t *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage)
 	// committed before evaluating any loads.
 	it.WaitForAcknowledged()
 
-	batches := make(chan map[string]types.KeysAndAttributes)
+	batches := make(chan loadBatch)
 	group, groupCtx := errgroup.WithContext(ctx)
 
 	group.Go(func() error {
 		return t.loadWorker(groupCtx, loaded, batches)
 	})
 
-	batch := make(map[string]types.KeysAndAttributes)
+	batch := newLoadBatch()
 	batchSize := 0
 
-	sendBatch := func(b map[string]types.KeysAndAttributes) error {
+	sendBatch := func(b loadBatch) error {
 		select {
 		case <-groupCtx.Done():
 			return group.Wait()
@@ -124,34 +223,39 @@ func (t *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage)
 
 	for it.Next() {
 		b := t.bindings[it.Binding]
+		lt := t.loadTables[b.tableName]
 
 		key, err := b.convertKey(it.Key)
 		if err != nil {
 			return fmt.Errorf("converting key for table '%s': %w", b.tableName, err)
 		}
 
-		if _, ok := batch[b.tableName]; !ok {
-			batch[b.tableName] = types.KeysAndAttributes{
-				ConsistentRead: aws.Bool(true),
-				// ProjectionExpression is for handling strange cases where the root document field
-				// has been projected as a field that clashes with a reserved word, or contains a
-				// dot. See:
-				// https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Expressions.ExpressionAttributeNames.html#Expressions.ExpressionAttributeNames.AttributeNamesContainingSpecialCharacters
-				ProjectionExpression:     aws.String("#flow_document"),
-				ExpressionAttributeNames: map[string]string{"#flow_document": b.docField},
+		// Bindings of a single-table design must not have items with the same key, which would
+		// overwrite each other.
+		k := itemKey(b.tableName, key, lt.keyAttrs)
+		if other, ok := batch.bindings[k]; ok && other != it.Binding {
+			return fmt.Errorf("documents of bindings %d and %d have the same key of table '%s', which must be distinct for the key templates of the table", other, it.Binding, b.tableName)
+		}
+		batch.bindings[k] = it.Binding
+
+		if _, ok := batch.requests[b.tableName]; !ok {
+			batch.requests[b.tableName] = types.KeysAndAttributes{
+				ConsistentRead:           aws.Bool(true),
+				ProjectionExpression:     aws.String(lt.projection),
+				ExpressionAttributeNames: lt.names,
 			}
 		}
 
-		keyAndAttrs := batch[b.tableName]
+		keyAndAttrs := batch.requests[b.tableName]
 		keyAndAttrs.Keys = append(keyAndAttrs.Keys, key)
-		batch[b.tableName] = keyAndAttrs
+		batch.requests[b.tableName] = keyAndAttrs
 		batchSize++
 
 		if batchSize == loadBatchSize {
 			if err := sendBatch(batch); err != nil {
 				return err
 			}
-			batch = make(map[string]types.KeysAndAttributes)
+			batch = newLoadBatch()
 			batchSize = 0
 		}
 	}
@@ -169,17 +273,17 @@ func (t *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage)
 func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 	ctx := it.Context()
 
-	batches := make(chan map[string][]types.WriteRequest)
+	batches := make(chan storeBatch)
 	group, groupCtx := errgroup.WithContext(ctx)
 
 	group.Go(func() error {
 		return t.storeWorker(groupCtx, batches)
 	})
 
-	batch := make(map[string][]types.WriteRequest)
+	batch := newStoreBatch()
 	batchSize := 0
 
-	sendBatch := func(b map[string][]types.WriteRequest) error {
+	sendBatch := func(b storeBatch) error {
 		select {
 		case <-groupCtx.Done():
 			return group.Wait()
@@ -191,19 +295,21 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 	for it.Next() {
 		b := t.bindings[it.Binding]
 
-		item, err := b.convert(append(it.Key, it.Values...), it.RawJSON)
+		item, err := b.convert(it.Key, it.Values, it.RawJSON)
 		if err != nil {
 			return nil, fmt.Errorf("converting values for table '%s': %w", b.tableName, err)
 		}
 
-		batch[b.tableName] = append(batch[b.tableName], types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		batch.requests[b.tableName] = append(batch.requests[b.tableName], types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		batch.docs[b.tableName] = append(batch.docs[b.tableName], it.RawJSON)
+		batch.bindings[b.tableName] = append(batch.bindings[b.tableName], it.Binding)
 		batchSize++
 
 		if batchSize == storeBatchSize {
 			if err := sendBatch(batch); err != nil {
 				return nil, err
 			}
-			batch = make(map[string][]types.WriteRequest)
+			batch = newStoreBatch()
 			batchSize = 0
 		}
 	}
@@ -215,22 +321,31 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 	}
 
 	close(batches)
-	return nil, group.Wait()
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	return nil, t.deadLetters.Flush(ctx)
 }
 
-func (t *transactor) Destroy() {}
+func (t *transactor) Destroy() {
+	if err := t.deadLetters.Close(context.Background()); err != nil {
+		log.WithField("error", err).Warn("closing dead letter file")
+	}
+}
 
-func (t *transactor) loadWorker(ctx context.Context, loaded func(i int, doc json.RawMessage) error, batches <-chan map[string]types.KeysAndAttributes) error {
+func (t *transactor) loadWorker(ctx context.Context, loaded func(i int, doc json.RawMessage) error, batches <-chan loadBatch) error {
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case batch := <-batches:
-			if batch == nil {
+		case b, ok := <-batches:
+			if !ok {
 				// Channel is closed and no more items will be sent for this transaction.
 				return nil
 			}
 
+			batch := b.requests
 			for attempt := 1; ; attempt++ {
 				res, err := t.client.db.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
 					RequestItems: batch,
@@ -240,29 +355,34 @@ func (t *transactor) loadWorker(ctx context.Context, loaded func(i int, doc json
 				}
 
 				for table, records := range res.Responses {
-					b := t.tablesToBindings[table]
+					lt := t.loadTables[table]
 
 					for _, record := range records {
-						doc := make(map[string]any)
-						if err := attributevalue.UnmarshalMap(record, &doc); err != nil {
-							return fmt.Errorf("unmarshalling attributevalue: %w", err)
+						// DynamoDB allows for "get" batches to include different tables and
+						// bindings, and the results must be correlated back to the binding that
+						// requested them.
+						binding, ok := b.bindings[itemKey(table, record, lt.keyAttrs)]
+						if !ok {
+							return fmt.Errorf("load response included an item of table '%s' which was not requested", table)
 						}
 
-						// Sanity check: There should be only one field in the response per the
-						// ProjectionExpression.
-						if len(doc) != 1 {
-							return fmt.Errorf("expected 1 field in load response but got %d fields", len(record))
+						attr, ok := record[t.bindings[binding].docField]
+						if !ok {
+							return fmt.Errorf("item of table '%s' in load response has no document attribute '%s'", table, t.bindings[binding].docField)
 						}
 
-						for _, d := range doc {
-							j, err := json.Marshal(d)
-							if err != nil {
-								return fmt.Errorf("marshalling document to JSON bytes: %w", err)
-							}
+						var doc any
+						if err := attributevalue.Unmarshal(attr, &doc); err != nil {
+							return fmt.Errorf("unmarshalling attributevalue: %w", err)
+						}
 
-							if err := loaded(b, json.RawMessage(j)); err != nil {
-								return err
-							}
+						j, err := json.Marshal(doc)
+						if err != nil {
+							return fmt.Errorf("marshalling document to JSON bytes: %w", err)
+						}
+
+						if err := loaded(binding, json.RawMessage(j)); err != nil {
+							return err
 						}
 					}
 				}
@@ -285,22 +405,32 @@ func (t *transactor) loadWorker(ctx context.Context, loaded func(i int, doc json
 	}
 }
 
-func (t *transactor) storeWorker(ctx context.Context, batches <-chan map[string][]types.WriteRequest) error {
+func (t *transactor) storeWorker(ctx context.Context, batches <-chan storeBatch) error {
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case batch := <-batches:
-			if batch == nil {
+		case b, ok := <-batches:
+			if !ok {
 				// Channel is closed and no more items will be sent for this transaction.
 				return nil
 			}
 
+			batch := b.requests
 			for attempt := 1; ; attempt++ {
 				res, err := t.client.db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
 					RequestItems: batch,
 				})
-				if err != nil {
+				if err != nil && attempt == 1 && t.deadLetters != nil && isValidationError(err) {
+					// The entire batch is rejected if any of its items are invalid. Its items are
+					// written individually instead, so that only the invalid ones are rejected. If
+					// every item is invalid for a reason other than its document, such as a key
+					// which doesn't match the table, the dead letter threshold fails the transaction.
+					if err := t.storeIndividually(ctx, b); err != nil {
+						return err
+					}
+					break
+				} else if err != nil {
 					return err
 				}
 
@@ -321,6 +451,34 @@ func (t *transactor) storeWorker(ctx context.Context, batches <-chan map[string]
 	}
 }
 
+// storeIndividually writes each item of the batch on its own, dead-lettering the documents of items
+// which are invalid.
+func (t *transactor) storeIndividually(ctx context.Context, batch storeBatch) error {
+	for table, requests := range batch.requests {
+		for idx, r := range requests {
+			_, err := t.client.db.PutItem(ctx, &dynamodb.PutItemInput{
+				TableName: aws.String(table),
+				Item:      r.PutRequest.Item,
+			})
+			if err != nil && isValidationError(err) {
+				err = t.deadLetters.Reject(batch.bindings[table][idx], batch.docs[table][idx], err)
+			}
+			if err != nil {
+				return fmt.Errorf("storing item to table '%s': %w", table, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isValidationError returns whether the error is due to an invalid request, such as one with an
+// item which is too large or has an empty key attribute.
+func isValidationError(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationException"
+}
+
 func delay(ctx context.Context, attempt int, key string) error {
 	if attempt > maxAttempts {
 		return fmt.Errorf("%s worker failed after %d retry attempts", key, maxAttempts)
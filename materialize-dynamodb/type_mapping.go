@@ -9,6 +9,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -20,12 +21,23 @@ import (
 	pm "github.com/estuary/flow/go/protocols/materialize"
 )
 
-func mapFields(spec *pf.MaterializationSpec_Binding) []mappedType {
+func mapFields(spec *pf.MaterializationSpec_Binding, res resource) []mappedType {
 	out := []mappedType{}
 
 	for _, f := range spec.FieldSelection.AllFields() {
 		p := spec.Collection.GetProjection(f)
-		out = append(out, mapType(p))
+		m := mapType(p)
+
+		if f == res.TTLField && p.Inference.String_ != nil && p.Inference.String_.Format == "date-time" {
+			m.converter = convertTTL
+		}
+		if slices.Contains(res.indexKeys(), f) || f == res.TTLField {
+			// Items without a value for an index key are not included in the index, and items
+			// without a TTL never expire. These attributes can't be stored as null values.
+			m.omitNull = true
+		}
+
+		out = append(out, m)
 	}
 
 	return out
@@ -41,6 +53,9 @@ type mappedType struct {
 
 	// Converts tuple values into database-friendly values.
 	converter func(tuple.TupleElement) (any, error)
+
+	// Whether the attribute is omitted from items instead of having a null value.
+	omitNull bool
 }
 
 func mapType(p *pf.Projection) mappedType {
@@ -175,6 +190,42 @@ func convertNumeric(te tuple.TupleElement) (any, error) {
 	return out, nil
 }
 
+// isTTLProjection returns whether the projection can be the TTL of items, which is either a
+// date-time string or an integer of epoch seconds.
+func isTTLProjection(p *pf.Projection) bool {
+	jsonTypes := slices.DeleteFunc(slices.Clone(p.Inference.Types), func(t string) bool {
+		return t == pf.JsonTypeNull
+	})
+	if len(jsonTypes) != 1 {
+		return false
+	}
+
+	switch jsonTypes[0] {
+	case pf.JsonTypeString:
+		return p.Inference.String_ != nil && p.Inference.String_.Format == "date-time"
+	case pf.JsonTypeInteger:
+		return true
+	default:
+		return false
+	}
+}
+
+// convertTTL converts a date-time string into the epoch seconds of a TTL attribute.
+func convertTTL(te tuple.TupleElement) (any, error) {
+	switch tt := te.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, tt)
+		if err != nil {
+			return nil, fmt.Errorf("parsing TTL date-time: %w", err)
+		}
+		return &wrappedNumeric{innerNumeric: strconv.FormatInt(t.Unix(), 10)}, nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T (%#v)", te, te)
+	}
+}
+
 func convertBase64(te tuple.TupleElement) (any, error) {
 	bytes, err := base64.StdEncoding.DecodeString(te.(string))
 	if err != nil {
@@ -238,10 +289,10 @@ func (constrainter) DescriptionForType(p *pf.Projection) string {
 func infoSchema(ctx context.Context, db *dynamodb.Client, tableNames []string) (*boilerplate.InfoSchema, error) {
 	is := boilerplate.NewInfoSchema(
 		func(rp []string) []string {
-			// Pass-through the index name as-is, since it is the only component of the resource
-			// path and the required transformations are assumed to already be done as part of the
-			// Validate response.
-			return rp
+			// Pass-through the table name as-is, since the required transformations are assumed to
+			// already be done as part of the Validate response. Any further components of the
+			// resource path are the key templates of a single-table design, which share the table.
+			return rp[:1]
 		},
 		func(f string) string { return f },
 	)
@@ -15,8 +15,19 @@ type elasticApplier struct {
 }
 
 func (e *elasticApplier) CreateMetaTables(ctx context.Context, spec *pf.MaterializationSpec) (string, boilerplate.ActionApplyFn, error) {
-	return fmt.Sprintf("create index %q", defaultFlowMaterializations), func(ctx context.Context) error {
-		return e.client.createMetaIndex(ctx, e.cfg.Advanced.Replicas)
+	dl := e.cfg.DeadLetter
+	if dl == nil {
+		return fmt.Sprintf("create index %q", defaultFlowMaterializations), func(ctx context.Context) error {
+			return e.client.createMetaIndex(ctx, e.cfg.Advanced.Replicas)
+		}, nil
+	}
+
+	deadLetterIndex := normalizeIndexName(dl.Index, maxByteLength)
+	return fmt.Sprintf("create index %q\ncreate dead letter index %q", defaultFlowMaterializations, deadLetterIndex), func(ctx context.Context) error {
+		if err := e.client.createMetaIndex(ctx, e.cfg.Advanced.Replicas); err != nil {
+			return err
+		}
+		return e.client.createDeadLetterIndex(ctx, deadLetterIndex, e.cfg.Advanced.Replicas)
 	}, nil
 }
 
@@ -28,8 +39,26 @@ func (e *elasticApplier) CreateResource(ctx context.Context, spec *pf.Materializ
 		return "", nil, fmt.Errorf("parsing resource config: %w", err)
 	}
 
+	props, err := buildIndexProperties(binding)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if res.templated() {
+		index := binding.ResourcePath[0]
+		tmpl := res.indexTemplate(index, e.cfg.Advanced.Replicas, props)
+		return fmt.Sprintf("create index template %q", index), func(ctx context.Context) error {
+			if err := e.client.putIndexTemplate(ctx, index, tmpl); err != nil {
+				return err
+			} else if res.DataStream {
+				return e.client.createDataStream(ctx, index)
+			}
+			return nil
+		}, nil
+	}
+
 	return fmt.Sprintf("create index %q", binding.ResourcePath[0]), func(ctx context.Context) error {
-		return e.client.createIndex(ctx, binding.ResourcePath[0], res.Shards, e.cfg.Advanced.Replicas, buildIndexProperties(binding))
+		return e.client.createIndex(ctx, binding.ResourcePath[0], res.indexSettings(e.cfg.Advanced.Replicas), props)
 	}, nil
 }
 
@@ -52,8 +81,35 @@ func (e *elasticApplier) ReplaceResource(ctx context.Context, spec *pf.Materiali
 		return "", nil, fmt.Errorf("parsing resource config: %w", err)
 	}
 
+	props, err := buildIndexProperties(binding)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if res.templated() {
+		index := binding.ResourcePath[0]
+		tmpl := res.indexTemplate(index, e.cfg.Advanced.Replicas, props)
+		return fmt.Sprintf("replace index template %q and delete its indices", index), func(ctx context.Context) error {
+			if res.DataStream {
+				if err := e.client.deleteDataStream(ctx, index); err != nil {
+					return err
+				} else if err := e.client.putIndexTemplate(ctx, index, tmpl); err != nil {
+					return err
+				}
+				return e.client.createDataStream(ctx, index)
+			}
+
+			if indices, err := e.client.rollingIndices(ctx, index, rollingLayouts[res.RollingInterval]); err != nil {
+				return err
+			} else if err := e.client.deleteIndices(ctx, indices); err != nil {
+				return err
+			}
+			return e.client.putIndexTemplate(ctx, index, tmpl)
+		}, nil
+	}
+
 	return fmt.Sprintf("replace index %q", binding.ResourcePath[0]), func(ctx context.Context) error {
-		return e.client.replaceIndex(ctx, binding.ResourcePath[0], res.Shards, e.cfg.Advanced.Replicas, buildIndexProperties(binding))
+		return e.client.replaceIndex(ctx, binding.ResourcePath[0], res.indexSettings(e.cfg.Advanced.Replicas), props)
 	}, nil
 }
 
@@ -65,9 +121,23 @@ func (e *elasticApplier) UpdateResource(ctx context.Context, spec *pf.Materializ
 		return "", nil, nil
 	}
 
+	var res resource
+	if err := pf.UnmarshalStrict(binding.ResourceConfigJson, &res); err != nil {
+		return "", nil, fmt.Errorf("parsing resource config: %w", err)
+	}
+
+	if res.templated() {
+		return e.updateTemplate(res, binding, bindingUpdate)
+	}
+
 	var actions []string
+	var props = make(map[string]property)
 	for _, newProjection := range bindingUpdate.NewProjections {
-		prop := propForField(newProjection.Field, binding)
+		prop, err := propForField(newProjection.Field, binding)
+		if err != nil {
+			return "", nil, err
+		}
+		props[newProjection.Field] = prop
 		actions = append(actions, fmt.Sprintf(
 			"add mapping %q to index %q with type %q",
 			newProjection.Field,
@@ -78,11 +148,55 @@ func (e *elasticApplier) UpdateResource(ctx context.Context, spec *pf.Materializ
 
 	return strings.Join(actions, "\n"), func(ctx context.Context) error {
 		for _, newProjection := range bindingUpdate.NewProjections {
-			prop := propForField(newProjection.Field, binding)
-			if err := e.client.addMappingToIndex(ctx, binding.ResourcePath[0], newProjection.Field, prop); err != nil {
+			if err := e.client.addMappingToIndex(ctx, binding.ResourcePath[0], newProjection.Field, props[newProjection.Field]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// updateTemplate adds mappings for new projections to the index template of a data stream or of
+// rolling indices, as well as to the indices that already exist.
+func (e *elasticApplier) updateTemplate(res resource, binding *pf.MaterializationSpec_Binding, bindingUpdate boilerplate.BindingUpdate) (string, boilerplate.ActionApplyFn, error) {
+	index := binding.ResourcePath[0]
+
+	allProps, err := buildIndexProperties(binding)
+	if err != nil {
+		return "", nil, err
+	}
+	tmpl := res.indexTemplate(index, e.cfg.Advanced.Replicas, allProps)
+
+	actions := []string{fmt.Sprintf("update index template %q", index)}
+	for _, newProjection := range bindingUpdate.NewProjections {
+		actions = append(actions, fmt.Sprintf(
+			"add mapping %q to indices of %q with type %q",
+			newProjection.Field,
+			index,
+			allProps[newProjection.Field].Type,
+		))
+	}
+
+	return strings.Join(actions, "\n"), func(ctx context.Context) error {
+		if err := e.client.putIndexTemplate(ctx, index, tmpl); err != nil {
+			return err
+		}
+
+		// Mappings added to a data stream are added to all of its backing indices.
+		indices := []string{index}
+		if !res.DataStream {
+			if indices, err = e.client.rollingIndices(ctx, index, rollingLayouts[res.RollingInterval]); err != nil {
 				return err
 			}
 		}
+
+		for _, idx := range indices {
+			for _, newProjection := range bindingUpdate.NewProjections {
+				if err := e.client.addMappingToIndex(ctx, idx, newProjection.Field, allProps[newProjection.Field]); err != nil {
+					return err
+				}
+			}
+		}
 		return nil
 	}, nil
 }
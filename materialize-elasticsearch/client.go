@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -8,6 +9,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
+	"time"
 
 	elasticsearch "github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esutil"
@@ -33,9 +37,63 @@ func (c *client) createMetaIndex(ctx context.Context, replicas *int) error {
 	}
 
 	numShards := 1
-	return c.createIndex(ctx, defaultFlowMaterializations, &numShards, replicas, props)
+	return c.createIndex(ctx, defaultFlowMaterializations, indexSettings{Shards: &numShards, Replicas: replicas}, props)
 }
 
+func (c *client) createDeadLetterIndex(ctx context.Context, index string, replicas *int) error {
+	props := map[string]property{
+		"binding":      {Type: elasticTypeLong},
+		"resourcePath": {Type: elasticTypeKeyword},
+		// Rejected documents are not indexed, since they may be of any shape.
+		"document":   {Type: elasticTypeFlattened, Index: boolPtr(false)},
+		"reason":     {Type: elasticTypeText},
+		"rejectedAt": {Type: elasticTypeDate},
+	}
+
+	return c.createIndex(ctx, index, indexSettings{Replicas: replicas}, props)
+}
+
+// deadLetterIndex is a boilerplate.DeadLetterSink which indexes dead letters into an index.
+type deadLetterIndex struct {
+	client *client
+	index  string
+}
+
+func (d *deadLetterIndex) WriteDeadLetters(ctx context.Context, letters []boilerplate.DeadLetter) error {
+	var body bytes.Buffer
+	var enc = json.NewEncoder(&body)
+	for _, l := range letters {
+		if err := enc.Encode(map[string]map[string]string{"create": {"_index": d.index}}); err != nil {
+			return err
+		} else if err := enc.Encode(l); err != nil {
+			return err
+		}
+	}
+
+	res, err := d.client.es.Bulk(
+		&body,
+		d.client.es.Bulk.WithContext(ctx),
+		d.client.es.Bulk.WithFilterPath("errors", "items.*.error"),
+	)
+	if err != nil {
+		return fmt.Errorf("indexing dead letters: %w", err)
+	}
+	defer res.Body.Close()
+
+	respBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("reading dead letters response: %w", err)
+	} else if res.IsError() {
+		return fmt.Errorf("indexing dead letters error response [%s] %s", res.Status(), string(respBytes))
+	} else if gjson.GetBytes(respBytes, "errors").Bool() {
+		return fmt.Errorf("indexing dead letters failed: %s", string(respBytes))
+	}
+
+	return nil
+}
+
+func (d *deadLetterIndex) Close(context.Context) error { return nil }
+
 func (c *client) putSpec(ctx context.Context, spec *pf.MaterializationSpec, version string) error {
 	specBytes, err := spec.Marshal()
 	if err != nil {
@@ -102,8 +160,14 @@ type createIndexParams struct {
 }
 
 type indexSettings struct {
-	Shards   *int `json:"number_of_shards,omitempty"`
-	Replicas *int `json:"number_of_replicas,omitempty"`
+	Shards    *int               `json:"number_of_shards,omitempty"`
+	Replicas  *int               `json:"number_of_replicas,omitempty"`
+	Analysis  *analysisSettings  `json:"analysis,omitempty"`
+	Lifecycle *lifecycleSettings `json:"lifecycle,omitempty"`
+}
+
+type lifecycleSettings struct {
+	Name string `json:"name"`
 }
 
 type indexMappings struct {
@@ -111,7 +175,7 @@ type indexMappings struct {
 }
 
 // createIndex creates a new index and sets its mappings per indexProps if it doesn't already exist.
-func (c *client) createIndex(ctx context.Context, index string, shards *int, replicas *int, indexProps map[string]property) error {
+func (c *client) createIndex(ctx context.Context, index string, settings indexSettings, indexProps map[string]property) error {
 	existResp, err := c.es.Indices.Exists(
 		[]string{index},
 		c.es.Indices.Exists.WithContext(ctx),
@@ -129,10 +193,7 @@ func (c *client) createIndex(ctx context.Context, index string, shards *int, rep
 
 	// Index does not exist, create a new one.
 	params := createIndexParams{
-		Settings: indexSettings{
-			Shards:   shards,
-			Replicas: replicas,
-		},
+		Settings: settings,
 		Mappings: indexMappings{
 			Properties: indexProps,
 		},
@@ -155,7 +216,7 @@ func (c *client) createIndex(ctx context.Context, index string, shards *int, rep
 }
 
 // replaceIndex first deletes any existing index by the provided name, then creates it anew.
-func (c *client) replaceIndex(ctx context.Context, index string, shards *int, replicas *int, indexProps map[string]property) error {
+func (c *client) replaceIndex(ctx context.Context, index string, settings indexSettings, indexProps map[string]property) error {
 	res, err := c.es.Indices.Delete(
 		[]string{index},
 		c.es.Indices.Delete.WithContext(ctx),
@@ -170,10 +231,7 @@ func (c *client) replaceIndex(ctx context.Context, index string, shards *int, re
 	}
 
 	params := createIndexParams{
-		Settings: indexSettings{
-			Shards:   shards,
-			Replicas: replicas,
-		},
+		Settings: settings,
 		Mappings: indexMappings{
 			Properties: indexProps,
 		},
@@ -195,6 +253,159 @@ func (c *client) replaceIndex(ctx context.Context, index string, shards *int, re
 	return nil
 }
 
+// baseTemplatePriority is higher than the priority of the built-in templates for data streams,
+// such as those matching "logs-*-*".
+const baseTemplatePriority = 200
+
+// templatePriority is the priority of the index template of an index. Elasticsearch rejects
+// templates having the same priority and overlapping patterns, and the patterns of templates for
+// different indices overlap only if one index name is a prefix of the other. The template of the
+// longer, more specific index name has the higher priority.
+func templatePriority(index string) int {
+	return baseTemplatePriority + len(index)
+}
+
+// indexTemplate is an index template for the indices of a data stream or for rolling indices. Its
+// metadata identifies the index name of the binding it was created for.
+type indexTemplate struct {
+	IndexPatterns []string            `json:"index_patterns"`
+	DataStream    *dataStreamTemplate `json:"data_stream,omitempty"`
+	Priority      int                 `json:"priority"`
+	Template      createIndexParams   `json:"template"`
+	Meta          templateMeta        `json:"_meta"`
+}
+
+type dataStreamTemplate struct {
+	AllowCustomRouting bool `json:"allow_custom_routing,omitempty"`
+}
+
+type templateMeta struct {
+	FlowIndex string `json:"flow_index,omitempty"`
+}
+
+// putIndexTemplate creates or replaces the index template by the provided name.
+func (c *client) putIndexTemplate(ctx context.Context, name string, tmpl indexTemplate) error {
+	res, err := c.es.Indices.PutIndexTemplate(
+		name,
+		esutil.NewJSONReader(tmpl),
+		c.es.Indices.PutIndexTemplate.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("putIndexTemplate: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("putIndexTemplate error response [%s] %s", res.Status(), res.String())
+	}
+
+	return nil
+}
+
+// createDataStream creates a data stream if it doesn't already exist. A matching index template
+// must already exist.
+func (c *client) createDataStream(ctx context.Context, name string) error {
+	existResp, err := c.es.Indices.Exists(
+		[]string{name},
+		c.es.Indices.Exists.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("checking if data stream exists: %w", err)
+	}
+	defer existResp.Body.Close()
+
+	if existResp.StatusCode == http.StatusOK {
+		return nil
+	} else if existResp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("data stream exists unexpected status code: %d", existResp.StatusCode)
+	}
+
+	res, err := c.es.Indices.CreateDataStream(
+		name,
+		c.es.Indices.CreateDataStream.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("createDataStream: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("createDataStream error response [%s] %s", res.Status(), res.String())
+	}
+
+	return nil
+}
+
+// deleteDataStream deletes a data stream and all of its backing indices, if it exists.
+func (c *client) deleteDataStream(ctx context.Context, name string) error {
+	res, err := c.es.Indices.DeleteDataStream(
+		[]string{name},
+		c.es.Indices.DeleteDataStream.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("deleteDataStream: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("deleteDataStream error response [%s] %s", res.Status(), res.String())
+	}
+
+	return nil
+}
+
+// rollingIndices lists the existing rolling indices of an index, which are those named by the
+// index and a date suffix in the format of layout.
+func (c *client) rollingIndices(ctx context.Context, index string, layout string) ([]string, error) {
+	res, err := c.es.Indices.Get(
+		rollingIndexPatterns(index, layout),
+		c.es.Indices.Get.WithContext(ctx),
+		c.es.Indices.Get.WithFilterPath("*.settings.index.provided_name"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting rolling indices: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("getting rolling indices error response [%s] %s", res.Status(), res.String())
+	}
+
+	var indices map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return nil, fmt.Errorf("decoding rolling indices response: %w", err)
+	}
+
+	var out []string
+	for name := range indices {
+		// The wildcard patterns may match other indices having the same prefix.
+		if _, err := time.Parse(layout, strings.TrimPrefix(name, index+"-")); err == nil {
+			out = append(out, name)
+		}
+	}
+	slices.Sort(out)
+
+	return out, nil
+}
+
+// deleteIndices deletes the provided indices, if they exist.
+func (c *client) deleteIndices(ctx context.Context, indices []string) error {
+	if len(indices) == 0 {
+		return nil
+	}
+
+	res, err := c.es.Indices.Delete(
+		indices,
+		c.es.Indices.Delete.WithContext(ctx),
+		c.es.Indices.Delete.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return fmt.Errorf("deleting indices: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("delete indices error response [%s] %s", res.Status(), res.String())
+	}
+
+	return nil
+}
+
 func (c *client) addMappingToIndex(ctx context.Context, index string, field string, prop property) error {
 	res, err := c.es.Indices.PutMapping(
 		[]string{index},
@@ -257,5 +468,51 @@ func (c *client) infoSchema(ctx context.Context) (*boilerplate.InfoSchema, error
 		}
 	}
 
+	// The mappings of data streams and rolling indices are those of their index template, which
+	// applies to any indices created in the future. The fields of these are reported under the
+	// index name of the binding that created the template.
+	tmplRes, err := c.es.Indices.GetIndexTemplate(
+		c.es.Indices.GetIndexTemplate.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting index templates: %w", err)
+	}
+	defer tmplRes.Body.Close()
+	if tmplRes.IsError() {
+		return nil, fmt.Errorf("getting index templates error response [%s] %s", tmplRes.Status(), tmplRes.String())
+	}
+
+	var templates indexTemplatesResponse
+	if err := json.NewDecoder(tmplRes.Body).Decode(&templates); err != nil {
+		return nil, fmt.Errorf("decoding index templates response: %w", err)
+	}
+
+	for _, t := range templates.IndexTemplates {
+		index := t.IndexTemplate.Meta.FlowIndex
+		if index == "" || is.HasResource([]string{index}) {
+			// Not created by the connector, or shadowed by a concrete index of the same name.
+			continue
+		}
+
+		for field, prop := range t.IndexTemplate.Template.Mappings.Properties {
+			is.PushField(boilerplate.EndpointField{
+				Name:               field,
+				Nullable:           true,
+				Type:               string(prop.Type),
+				CharacterMaxLength: 0,
+			}, index)
+		}
+	}
+
 	return is, nil
 }
+
+type indexTemplatesResponse struct {
+	IndexTemplates []struct {
+		Name          string `json:"name"`
+		IndexTemplate struct {
+			Template indexMetaResponse `json:"template"`
+			Meta     templateMeta      `json:"_meta"`
+		} `json:"index_template"`
+	} `json:"index_templates"`
+}
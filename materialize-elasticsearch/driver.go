@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
 	"net/url"
 	"strings"
@@ -41,9 +42,10 @@ type credentials struct {
 }
 
 type config struct {
-	Credentials credentials    `json:"credentials"`
-	Endpoint    string         `json:"endpoint"`
-	Advanced    advancedConfig `json:"advanced,omitempty"`
+	Credentials credentials       `json:"credentials"`
+	Endpoint    string            `json:"endpoint"`
+	DeadLetter  *deadLetterConfig `json:"dead_letter,omitempty"`
+	Advanced    advancedConfig    `json:"advanced,omitempty"`
 
 	NetworkTunnel *tunnelConfig `json:"networkTunnel,omitempty"`
 }
@@ -52,6 +54,13 @@ type advancedConfig struct {
 	Replicas *int `json:"number_of_replicas,omitempty"`
 }
 
+// deadLetterConfig configures an index which documents that Elasticsearch permanently rejects are
+// written to, rather than failing the materialization.
+type deadLetterConfig struct {
+	Index                      string `json:"index"`
+	MaxDocumentsPerTransaction int    `json:"max_documents_per_transaction,omitempty"`
+}
+
 // The `go-schema-gen` package doesn't have a good way of dealing with oneOf, and I couldn't get it
 // to output a schema that works with Flow's UI. So the endpoint config schema is written out manually
 // instead of being generated from the structs.
@@ -109,6 +118,26 @@ func configSchema() json.RawMessage {
 			  }
 			]
 		  },
+		  "dead_letter": {
+			"type": "object",
+			"title": "Dead Letter Index",
+			"description": "Write documents which Elasticsearch rejects, such as those which conflict with the index mappings, to a separate index instead of failing the materialization.",
+			"properties": {
+			  "index": {
+				"type": "string",
+				"title": "Index",
+				"description": "Name of the index to write rejected documents to."
+			  },
+			  "max_documents_per_transaction": {
+				"type": "integer",
+				"title": "Max Documents Per Transaction",
+				"description": "Fail the materialization if more than this many documents are rejected in a single transaction. Defaults to 100."
+			  }
+			},
+			"required": [
+			  "index"
+			]
+		  },
 		  "advanced": {
 			"properties": {
 			  "number_of_replicas": {
@@ -203,6 +232,10 @@ func (c config) Validate() error {
 		return fmt.Errorf("endpoint '%s' is invalid: must start with either http:// or https://", c.Endpoint)
 	} else if c.Advanced.Replicas != nil && *c.Advanced.Replicas < 0 {
 		return fmt.Errorf("number_of_replicas cannot be negative")
+	} else if dl := c.DeadLetter; dl != nil && normalizeIndexName(dl.Index, maxByteLength) == "" {
+		return fmt.Errorf("missing dead letter index")
+	} else if dl != nil && dl.MaxDocumentsPerTransaction < 0 {
+		return fmt.Errorf("dead letter max_documents_per_transaction cannot be negative")
 	}
 
 	return c.Credentials.Validate()
@@ -265,9 +298,45 @@ func (c config) toClient(disableRetry bool) (*client, error) {
 }
 
 type resource struct {
-	Index        string `json:"index" jsonschema_extras:"x-collection-name=true"`
-	DeltaUpdates bool   `json:"delta_updates" jsonschema:"default=false"`
-	Shards       *int   `json:"number_of_shards,omitempty"`
+	Index        string            `json:"index" jsonschema_extras:"x-collection-name=true"`
+	DeltaUpdates bool              `json:"delta_updates" jsonschema:"default=false"`
+	Shards       *int              `json:"number_of_shards,omitempty"`
+	Pipeline     string            `json:"pipeline,omitempty"`
+	RoutingField string            `json:"routing_field,omitempty"`
+	Analysis     *analysisSettings `json:"analysis,omitempty"`
+
+	DataStream      bool   `json:"data_stream,omitempty"`
+	RollingInterval string `json:"rolling_interval,omitempty" jsonschema:"enum=day,enum=month,enum=year"`
+	TimestampField  string `json:"timestamp_field,omitempty"`
+	LifecyclePolicy string `json:"lifecycle_policy,omitempty"`
+}
+
+// dataStreamTimestamp is the field which every document of a data stream must have.
+const dataStreamTimestamp = "@timestamp"
+
+// rollingLayouts are the formats of the date suffixes of rolling indices for each interval.
+var rollingLayouts = map[string]string{
+	"day":   "2006.01.02",
+	"month": "2006.01",
+	"year":  "2006",
+}
+
+// rollingIndexPatterns are the wildcard patterns which match the rolling indices of an index,
+// having date suffixes in the format of layout. Patterns may only use '*' wildcards, and so they
+// match each dot-separated component of the date, and the leading digit of its year.
+func rollingIndexPatterns(index string, layout string) []string {
+	suffix := strings.Repeat(".*", strings.Count(layout, "."))
+	return []string{index + "-1*" + suffix, index + "-2*" + suffix}
+}
+
+// analysisSettings are custom analysis components of an index, keyed on their names. Their
+// definitions are passed through to Elasticsearch as-is.
+type analysisSettings struct {
+	Analyzer   map[string]map[string]any `json:"analyzer,omitempty"`
+	Normalizer map[string]map[string]any `json:"normalizer,omitempty"`
+	Tokenizer  map[string]map[string]any `json:"tokenizer,omitempty"`
+	Filter     map[string]map[string]any `json:"filter,omitempty"`
+	CharFilter map[string]map[string]any `json:"char_filter,omitempty"`
 }
 
 func (r resource) Validate() error {
@@ -275,11 +344,65 @@ func (r resource) Validate() error {
 		return fmt.Errorf("missing Index")
 	} else if r.Shards != nil && *r.Shards < 1 {
 		return fmt.Errorf("number_of_shards must be greater than 0")
+	} else if _, ok := rollingLayouts[r.RollingInterval]; r.RollingInterval != "" && !ok {
+		return fmt.Errorf("invalid rolling_interval %q: must be one of day, month, or year", r.RollingInterval)
+	} else if r.DataStream && r.RollingInterval != "" {
+		return fmt.Errorf("cannot set both data_stream and rolling_interval")
+	} else if r.templated() && !r.DeltaUpdates {
+		return fmt.Errorf("data streams and rolling indices require delta updates")
+	} else if r.templated() && r.TimestampField == "" {
+		return fmt.Errorf("data streams and rolling indices require a timestamp_field")
+	} else if !r.templated() && r.TimestampField != "" {
+		return fmt.Errorf("timestamp_field can only be set for data streams and rolling indices")
 	}
 
 	return nil
 }
 
+// templated is true if the binding writes to a data stream or to rolling indices, whose mappings
+// and settings are those of an index template rather than of a concrete index.
+func (r resource) templated() bool {
+	return r.DataStream || r.RollingInterval != ""
+}
+
+func (r resource) indexSettings(replicas *int) indexSettings {
+	s := indexSettings{
+		Shards:   r.Shards,
+		Replicas: replicas,
+		Analysis: r.Analysis,
+	}
+	if r.LifecyclePolicy != "" {
+		s.Lifecycle = &lifecycleSettings{Name: r.LifecyclePolicy}
+	}
+	return s
+}
+
+// indexTemplate is the index template for the indices of a data stream or for rolling indices.
+func (r resource) indexTemplate(index string, replicas *int, props map[string]property) indexTemplate {
+	tmpl := indexTemplate{
+		Priority: templatePriority(index),
+		Template: createIndexParams{
+			Settings: r.indexSettings(replicas),
+			Mappings: indexMappings{Properties: props},
+		},
+		Meta: templateMeta{FlowIndex: index},
+	}
+
+	if r.DataStream {
+		tmpl.IndexPatterns = []string{index}
+		tmpl.DataStream = &dataStreamTemplate{AllowCustomRouting: r.RoutingField != ""}
+		if _, ok := props[dataStreamTimestamp]; !ok {
+			withTimestamp := maps.Clone(props)
+			withTimestamp[dataStreamTimestamp] = property{Type: elasticTypeDate}
+			tmpl.Template.Mappings.Properties = withTimestamp
+		}
+	} else {
+		tmpl.IndexPatterns = rollingIndexPatterns(index, rollingLayouts[r.RollingInterval])
+	}
+
+	return tmpl
+}
+
 // GetFieldDocString implements the jsonschema.customSchemaGetFieldDocString interface.
 func (resource) GetFieldDocString(fieldName string) string {
 	switch fieldName {
@@ -289,6 +412,38 @@ func (resource) GetFieldDocString(fieldName string) string {
 		return "Should updates to this table be done via delta updates. Default is false."
 	case "Shards":
 		return "The number of shards to create the index with. Leave blank to use the cluster default."
+	case "Pipeline":
+		return "Name of an ingest pipeline to process documents with before they are indexed."
+	case "RoutingField":
+		return "A selected field whose value is used to route documents to shards. Must be a collection key unless delta updates are enabled. Changing it requires a backfill of a standard updates binding."
+	case "Analysis":
+		return "Custom analyzers, normalizers, tokenizers, filters and character filters to create the index with, which may be referenced by the configuration of fields. These are applied only when the index is created."
+	case "DataStream":
+		return "Write documents to a data stream named by the index. Requires delta updates and a timestamp field."
+	case "RollingInterval":
+		return "Write documents to indices suffixed with the date of their timestamp field, such as 'events-2024.05.01' for a daily interval. Requires delta updates."
+	case "TimestampField":
+		return "A selected date or date-time field which determines the rolling index of a document, or which is the '@timestamp' of documents in a data stream."
+	case "LifecyclePolicy":
+		return "Name of an index lifecycle management (ILM) policy to attach to the index, or to the indices of a data stream or rolling indices."
+	default:
+		return ""
+	}
+}
+
+// GetFieldDocString implements the jsonschema.customSchemaGetFieldDocString interface.
+func (analysisSettings) GetFieldDocString(fieldName string) string {
+	switch fieldName {
+	case "Analyzer":
+		return "Custom analyzers, keyed on their name."
+	case "Normalizer":
+		return "Custom normalizers, keyed on their name."
+	case "Tokenizer":
+		return "Custom tokenizers, keyed on their name."
+	case "Filter":
+		return "Custom token filters, keyed on their name."
+	case "CharFilter":
+		return "Custom character filters, keyed on their name."
 	default:
 		return ""
 	}
@@ -413,6 +568,52 @@ func (driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Respo
 			return nil, fmt.Errorf("validating binding: %w", err)
 		}
 
+		if err := validateExistingBinding(res, binding, findStoredBinding(storedSpec, indexName, binding.Collection.Name)); err != nil {
+			return nil, err
+		}
+
+		for field, raw := range binding.FieldConfigJsonMap {
+			projection := binding.Collection.GetProjection(field)
+			if projection == nil {
+				return nil, fmt.Errorf("field config is set for field %q, which is not a projection of the collection", field)
+			} else if fc, err := parseFieldConfig(raw); err != nil {
+				return nil, fmt.Errorf("field %q: %w", field, err)
+			} else if projection.IsRootDocumentProjection() {
+				continue // The root document is always a non-indexed flattened mapping.
+			} else if _, err := fc.apply(field, propForProjection(projection)); err != nil {
+				return nil, err
+			}
+		}
+
+		if res.RoutingField != "" {
+			projection := binding.Collection.GetProjection(res.RoutingField)
+			if projection == nil {
+				return nil, fmt.Errorf("routing_field %q is not a projection of the collection", res.RoutingField)
+			} else if !projection.IsPrimaryKey && !res.DeltaUpdates {
+				// Documents are loaded using only their key, which must therefore determine their routing.
+				return nil, fmt.Errorf("routing_field %q must be a collection key unless delta updates are enabled", res.RoutingField)
+			} else if !projection.IsPrimaryKey {
+				constraints[res.RoutingField] = &pm.Response_Validated_Constraint{
+					Type:   pm.Response_Validated_Constraint_FIELD_REQUIRED,
+					Reason: "This field is the routing field of the index",
+				}
+			}
+		}
+
+		if res.TimestampField != "" {
+			projection := binding.Collection.GetProjection(res.TimestampField)
+			if projection == nil {
+				return nil, fmt.Errorf("timestamp_field %q is not a projection of the collection", res.TimestampField)
+			} else if !isTimestampProjection(projection) {
+				return nil, fmt.Errorf("timestamp_field %q must be a string with format date or date-time", res.TimestampField)
+			} else if !projection.IsPrimaryKey {
+				constraints[res.TimestampField] = &pm.Response_Validated_Constraint{
+					Type:   pm.Response_Validated_Constraint_FIELD_REQUIRED,
+					Reason: "This field is the timestamp field of the index",
+				}
+			}
+		}
+
 		out = append(out, &pm.Response_Validated_Binding{
 			Constraints:  constraints,
 			DeltaUpdates: res.DeltaUpdates,
@@ -423,6 +624,75 @@ func (driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Respo
 	return &pm.Response_Validated{Bindings: out}, nil
 }
 
+// findStoredBinding returns the binding of the stored spec which materializes the collection to
+// the index, or nil if there is none.
+func findStoredBinding(storedSpec *pf.MaterializationSpec, index string, collection pf.Collection) *pf.MaterializationSpec_Binding {
+	if storedSpec == nil {
+		return nil
+	}
+	for _, b := range storedSpec.Bindings {
+		if b.Collection.Name == collection && len(b.ResourcePath) == 1 && b.ResourcePath[0] == index {
+			return b
+		}
+	}
+	return nil
+}
+
+// validateExistingBinding rejects changes to an existing binding which wouldn't be applied to its
+// index, unless the binding is backfilled and its index is replaced. Documents of a standard
+// updates index are stored and loaded with the routing of their key, so its routing_field can't be
+// changed. Elasticsearch doesn't allow the analysis of an existing field to be changed.
+func validateExistingBinding(res resource, binding *pm.Request_Validate_Binding, stored *pf.MaterializationSpec_Binding) error {
+	if stored == nil || stored.Backfill != binding.Backfill {
+		return nil
+	}
+
+	var storedRes resource
+	if err := pf.UnmarshalStrict(stored.ResourceConfigJson, &storedRes); err != nil {
+		return fmt.Errorf("parsing stored resource config: %w", err)
+	}
+
+	if !res.DeltaUpdates && res.RoutingField != storedRes.RoutingField {
+		return fmt.Errorf(
+			"routing_field of index %q cannot be changed from %q to %q without backfilling the binding",
+			stored.ResourcePath[0], storedRes.RoutingField, res.RoutingField,
+		)
+	}
+
+	for _, field := range stored.FieldSelection.AllFields() {
+		if p := binding.Collection.GetProjection(field); p == nil || p.IsRootDocumentProjection() {
+			continue
+		}
+
+		storedFc, err := parseFieldConfig(stored.FieldSelection.FieldConfigJsonMap[field])
+		if err != nil {
+			return fmt.Errorf("stored field %q: %w", field, err)
+		}
+		fc, err := parseFieldConfig(binding.FieldConfigJsonMap[field])
+		if err != nil {
+			return fmt.Errorf("field %q: %w", field, err)
+		}
+
+		for _, setting := range []struct {
+			name         string
+			stored, next string
+		}{
+			{"analyzer", storedFc.Analyzer, fc.Analyzer},
+			{"search_analyzer", storedFc.SearchAnalyzer, fc.SearchAnalyzer},
+			{"normalizer", storedFc.Normalizer, fc.Normalizer},
+		} {
+			if setting.stored != setting.next {
+				return fmt.Errorf(
+					"%s of existing field %q cannot be changed from %q to %q without backfilling the binding",
+					setting.name, field, setting.stored, setting.next,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
 func (driver) Apply(ctx context.Context, req *pm.Request_Apply) (*pm.Response_Applied, error) {
 	var cfg config
 	if err := pf.UnmarshalStrict(req.Materialization.ConfigJson, &cfg); err != nil {
@@ -466,26 +736,55 @@ func (d driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Tran
 
 		fields := append(b.FieldSelection.Keys, b.FieldSelection.Values...)
 		floatFields := make([]bool, len(fields))
+		routingIdx, timestampIdx := -1, -1
 		for idx := range fields {
-			if propForField(fields[idx], b).Type == elasticTypeDouble {
+			if prop, err := propForField(fields[idx], b); err != nil {
+				return nil, nil, err
+			} else if prop.Type == elasticTypeDouble {
 				floatFields[idx] = true
 			}
+			if fields[idx] == res.RoutingField {
+				routingIdx = idx
+			}
+			if fields[idx] == res.TimestampField {
+				timestampIdx = idx
+			}
+		}
+		if res.RoutingField != "" && routingIdx == -1 {
+			return nil, nil, fmt.Errorf("routing_field %q is not a selected field", res.RoutingField)
+		} else if res.TimestampField != "" && timestampIdx == -1 {
+			return nil, nil, fmt.Errorf("timestamp_field %q is not a selected field", res.TimestampField)
 		}
 
 		indexToBinding[b.ResourcePath[0]] = idx
 		bindings = append(bindings, binding{
-			index:        b.ResourcePath[0],
-			deltaUpdates: res.DeltaUpdates,
-			fields:       fields,
-			floatFields:  floatFields,
-			docField:     b.FieldSelection.Document,
+			index:         b.ResourcePath[0],
+			deltaUpdates:  res.DeltaUpdates,
+			fields:        fields,
+			floatFields:   floatFields,
+			docField:      b.FieldSelection.Document,
+			pipeline:      res.Pipeline,
+			routingIdx:    routingIdx,
+			dataStream:    res.DataStream,
+			rollingLayout: rollingLayouts[res.RollingInterval],
+			timestampIdx:  timestampIdx,
 		})
 	}
 
+	var deadLetters *boilerplate.DeadLetterQueue
+	if dl := cfg.DeadLetter; dl != nil {
+		deadLetters = boilerplate.NewDeadLetterQueue(
+			&deadLetterIndex{client: client, index: normalizeIndexName(dl.Index, maxByteLength)},
+			dl.MaxDocumentsPerTransaction,
+			open.Materialization.Bindings,
+		)
+	}
+
 	var transactor = &transactor{
 		client:         client,
 		bindings:       bindings,
 		indexToBinding: indexToBinding,
+		deadLetters:    deadLetters,
 	}
 	return transactor, &pm.Response_Opened{}, nil
 }
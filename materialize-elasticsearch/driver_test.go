@@ -162,3 +162,67 @@ func TestNormalizeIndexName(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateExistingBinding(t *testing.T) {
+	collection := pf.CollectionSpec{
+		Name: "acmeCo/events",
+		Projections: []pf.Projection{
+			{Field: "body", Ptr: "/body"},
+			{Field: "flow_document", Ptr: ""},
+			{Field: "id", Ptr: "/id", IsPrimaryKey: true},
+			{Field: "tenant", Ptr: "/tenant", IsPrimaryKey: true},
+		},
+	}
+	stored := &pf.MaterializationSpec_Binding{
+		ResourceConfigJson: json.RawMessage(`{"index":"events","routing_field":"id"}`),
+		ResourcePath:       []string{"events"},
+		Collection:         collection,
+		FieldSelection: pf.FieldSelection{
+			Keys:               []string{"id", "tenant"},
+			Values:             []string{"body"},
+			Document:           "flow_document",
+			FieldConfigJsonMap: map[string]json.RawMessage{"body": json.RawMessage(`{"analyzer":"english"}`)},
+		},
+	}
+	storedSpec := &pf.MaterializationSpec{Bindings: []*pf.MaterializationSpec_Binding{stored}}
+	require.Equal(t, stored, findStoredBinding(storedSpec, "events", collection.Name))
+	require.Nil(t, findStoredBinding(storedSpec, "other", collection.Name))
+	require.Nil(t, findStoredBinding(nil, "events", collection.Name))
+
+	unchanged := map[string]json.RawMessage{"body": json.RawMessage(`{"analyzer":"english"}`)}
+
+	for _, tt := range []struct {
+		name     string
+		res      resource
+		backfill uint32
+		config   map[string]json.RawMessage
+		want     string
+	}{
+		{"unchanged", resource{Index: "events", RoutingField: "id"}, 0, unchanged, ""},
+		{"routing changed", resource{Index: "events", RoutingField: "tenant"}, 0, unchanged, `routing_field of index "events" cannot be changed from "id" to "tenant"`},
+		{"routing removed", resource{Index: "events"}, 0, unchanged, `routing_field of index "events" cannot be changed from "id" to ""`},
+		{"routing changed with backfill", resource{Index: "events", RoutingField: "tenant"}, 1, unchanged, ""},
+		{"routing changed with delta updates", resource{Index: "events", RoutingField: "tenant", DeltaUpdates: true}, 0, unchanged, ""},
+		{"analyzer changed", resource{Index: "events", RoutingField: "id"}, 0, map[string]json.RawMessage{"body": json.RawMessage(`{"analyzer":"french"}`)}, `analyzer of existing field "body" cannot be changed from "english" to "french"`},
+		{"analyzer removed", resource{Index: "events", RoutingField: "id"}, 0, nil, `analyzer of existing field "body" cannot be changed from "english" to ""`},
+		{"search analyzer added", resource{Index: "events", RoutingField: "id"}, 0, map[string]json.RawMessage{"body": json.RawMessage(`{"analyzer":"english","search_analyzer":"simple"}`)}, `search_analyzer of existing field "body" cannot be changed from "" to "simple"`},
+		{"analyzer changed with backfill", resource{Index: "events", RoutingField: "id"}, 1, map[string]json.RawMessage{"body": json.RawMessage(`{"analyzer":"french"}`)}, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			binding := &pm.Request_Validate_Binding{
+				Collection:         collection,
+				Backfill:           tt.backfill,
+				FieldConfigJsonMap: tt.config,
+			}
+			err := validateExistingBinding(tt.res, binding, stored)
+			if tt.want == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.want)
+			}
+		})
+	}
+
+	// New bindings have nothing to validate.
+	require.NoError(t, validateExistingBinding(resource{Index: "events", RoutingField: "tenant"}, &pm.Request_Validate_Binding{Collection: collection}, nil))
+}
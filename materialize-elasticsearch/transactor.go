@@ -5,12 +5,15 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8/esutil"
 	m "github.com/estuary/connectors/go/protocols/materialize"
+	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
 	pf "github.com/estuary/flow/go/protocols/flow"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
@@ -43,6 +46,65 @@ type binding struct {
 	// Present if the binding includes the root document, empty if not. This is usually the default
 	// "flow_document" but may have an alternate user-defined projection name.
 	docField string
+
+	// The ingest pipeline which documents are stored with, or empty if there is none.
+	pipeline string
+
+	// Index of the field whose value routes documents to shards, or -1 if documents are routed by
+	// their ID. This is always a key field of standard updates bindings, so that it can be
+	// determined for loads.
+	routingIdx int
+
+	// Documents of data streams are stored with the value of their timestamp field as their
+	// "@timestamp", and documents of rolling indices are stored to an index suffixed with its date
+	// in the format of rollingLayout.
+	dataStream    bool
+	rollingLayout string
+
+	// Index of the timestamp field of data streams and rolling indices, or -1 if there is none.
+	timestampIdx int
+}
+
+// timestampValue parses the value of a date or date-time timestamp field.
+func timestampValue(v any) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("timestamp must be a string, got %v", v)
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	} else if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("timestamp %q must be a date or date-time", s)
+}
+
+// targetIndex is the index which a document having the timestamp value ts is stored to.
+func (b binding) targetIndex(ts any) (string, error) {
+	if b.rollingLayout == "" {
+		return b.index, nil
+	}
+
+	t, err := timestampValue(ts)
+	if err != nil {
+		return "", err
+	}
+	return b.index + "-" + t.UTC().Format(b.rollingLayout), nil
+}
+
+// routingValue is the routing of a document having the value for its routing field.
+func routingValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
 }
 
 type transactor struct {
@@ -51,6 +113,9 @@ type transactor struct {
 
 	// Used to correlate the binding number for loaded documents from Elasticsearch.
 	indexToBinding map[string]int
+
+	// Documents which Elasticsearch rejects are dead-lettered if this is configured.
+	deadLetters *boilerplate.DeadLetterQueue
 }
 
 func (t *transactor) UnmarshalState(state json.RawMessage) error                  { return nil }
@@ -104,11 +169,16 @@ func (t *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage)
 	batchSize := 0
 	for it.Next() {
 		id := base64.RawStdEncoding.EncodeToString(it.PackedKey)
-		batch = append(batch, getDoc{
+		b := t.bindings[it.Binding]
+		doc := getDoc{
 			Id:     id,
-			Index:  t.bindings[it.Binding].index,
-			Source: t.bindings[it.Binding].docField,
-		})
+			Index:  b.index,
+			Source: b.docField,
+		}
+		if b.routingIdx != -1 {
+			doc.Routing = routingValue(it.Key[b.routingIdx])
+		}
+		batch = append(batch, doc)
 		batchSize += len(id)
 
 		if batchSize > loadBatchSize {
@@ -134,44 +204,69 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 	ctx := it.Context()
 	errCh := make(chan error, 1)
 
-	indexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-		NumWorkers: storeWorkers,
-		FlushBytes: storeBatchSize,
-		Client:     t.client.es,
-		OnError: func(_ context.Context, err error) {
-			log.WithField("error", err.Error()).Error("bulk indexer error")
+	// The ingest pipeline is a parameter of an entire bulk request, so there is an indexer for
+	// each pipeline of the bindings.
+	indexers := make(map[string]esutil.BulkIndexer)
+	getIndexer := func(pipeline string) (esutil.BulkIndexer, error) {
+		if indexer, ok := indexers[pipeline]; ok {
+			return indexer, nil
+		}
 
-			select {
-			case errCh <- err:
-			default:
-			}
-		},
-		// Makes sure the changes are propagated to all replica shards.
-		// TODO(whb): I'm not totally convinced we need to always be requiring this. It may only
-		// really be applicable to case where there is a single replica shard and Elasticsearch
-		// considers a quorum to be possible by writing only to the primary shard, which could
-		// result in data loss if there is then a hardware failure on that single primary shard. At
-		// the very least we could consider making this an advanced configuration option in the
-		// future if it is problematic.
-		WaitForActiveShards: "all",
-	})
-	if err != nil {
-		return nil, fmt.Errorf("creating bulk indexer: %w", err)
+		indexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+			NumWorkers: storeWorkers,
+			FlushBytes: storeBatchSize,
+			Client:     t.client.es,
+			OnError: func(_ context.Context, err error) {
+				log.WithField("error", err.Error()).Error("bulk indexer error")
+
+				select {
+				case errCh <- err:
+				default:
+				}
+			},
+			Pipeline: pipeline,
+			// Makes sure the changes are propagated to all replica shards.
+			// TODO(whb): I'm not totally convinced we need to always be requiring this. It may only
+			// really be applicable to case where there is a single replica shard and Elasticsearch
+			// considers a quorum to be possible by writing only to the primary shard, which could
+			// result in data loss if there is then a hardware failure on that single primary shard. At
+			// the very least we could consider making this an advanced configuration option in the
+			// future if it is problematic.
+			WaitForActiveShards: "all",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("creating bulk indexer: %w", err)
+		}
+		indexers[pipeline] = indexer
+		return indexer, nil
 	}
 
 	// If a single item fails from a batch, it's common for _all_ of the items to fail from that
 	// batch. We'll get the first item failure error we see via errCh and report that as the
 	// connector failure error.
-	onItemFailure := func(_ context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
-		if err == nil {
-			// The err itself may be `nil` of the request was successful, but a failure is still
-			// indicated by the error from the response body.
-			err = errors.New(res.Error.Reason)
-		}
+	//
+	// Items that are rejected as bad requests, such as documents which conflict with the mappings
+	// of the index, are dead-lettered instead if that is configured.
+	var rejected atomic.Uint64
+	onItemFailure := func(binding int, doc json.RawMessage) func(context.Context, esutil.BulkIndexerItem, esutil.BulkIndexerResponseItem, error) {
+		return func(_ context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+			if err == nil {
+				// The err itself may be `nil` of the request was successful, but a failure is still
+				// indicated by the error from the response body.
+				err = fmt.Errorf("%s: %s", res.Error.Type, res.Error.Reason)
+
+				if res.Status == http.StatusBadRequest {
+					if err = t.deadLetters.Reject(binding, doc, err); err == nil {
+						rejected.Add(1)
+						return
+					}
+				}
+			}
 
-		select {
-		case errCh <- err:
-		default:
+			select {
+			case errCh <- err:
+			default:
+			}
 		}
 	}
 
@@ -187,7 +282,8 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 
 		doc := make(map[string]any)
 
-		for idx, v := range append(it.Key, it.Values...) {
+		values := append(it.Key, it.Values...)
+		for idx, v := range values {
 			if b, ok := v.([]byte); ok {
 				// An object or array field is received as raw JSON bytes. We currently only support
 				// objects.
@@ -206,6 +302,24 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 			doc[b.docField] = it.RawJSON
 		}
 
+		var routing string
+		if b.routingIdx != -1 {
+			routing = routingValue(values[b.routingIdx])
+		}
+
+		index := b.index
+		if b.timestampIdx != -1 {
+			ts := values[b.timestampIdx]
+			if b.dataStream {
+				doc[dataStreamTimestamp] = ts
+			}
+			target, err := b.targetIndex(ts)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", b.fields[b.timestampIdx], err)
+			}
+			index = target
+		}
+
 		var id string
 		if !b.deltaUpdates {
 			// Leave ID blank for delta updates so that ES will automatically generate one.
@@ -228,19 +342,34 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 			return nil, err
 		}
 
+		indexer, err := getIndexer(b.pipeline)
+		if err != nil {
+			return nil, err
+		}
+
 		if err := indexer.Add(it.Context(), esutil.BulkIndexerItem{
-			Index:      b.index,
+			Index:      index,
 			Action:     action,
 			DocumentID: id,
+			Routing:    routing,
 			Body:       bytes.NewReader(body),
-			OnFailure:  onItemFailure,
+			OnFailure:  onItemFailure(it.Binding, it.RawJSON),
 		}); err != nil {
 			return nil, fmt.Errorf("adding item to bulk indexer: %w", err)
 		}
 	}
 
-	if err := indexer.Close(ctx); err != nil {
-		return nil, fmt.Errorf("closing bulk indexer: %w", err)
+	var stats esutil.BulkIndexerStats
+	for _, indexer := range indexers {
+		if err := indexer.Close(ctx); err != nil {
+			return nil, fmt.Errorf("closing bulk indexer: %w", err)
+		}
+
+		s := indexer.Stats()
+		stats.NumAdded += s.NumAdded
+		stats.NumFailed += s.NumFailed
+		stats.NumIndexed += s.NumIndexed
+		stats.NumCreated += s.NumCreated
 	}
 
 	select {
@@ -249,11 +378,16 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 	default:
 	}
 
+	if err := t.deadLetters.Flush(ctx); err != nil {
+		return nil, err
+	}
+
 	// Sanity checks that everything went as expected.
-	stats := indexer.Stats()
-	if stats.NumFailed != 0 || stats.NumAdded != uint64(it.Total) || stats.NumIndexed+stats.NumCreated != uint64(it.Total) {
+	numRejected := rejected.Load()
+	if stats.NumFailed != numRejected || stats.NumAdded != uint64(it.Total) || stats.NumIndexed+stats.NumCreated+numRejected != uint64(it.Total) {
 		log.WithFields(log.Fields{
 			"stored":     it.Total,
+			"rejected":   numRejected,
 			"numFailed":  stats.NumFailed,
 			"numAdded":   stats.NumAdded,
 			"numIndex":   stats.NumIndexed,
@@ -268,9 +402,10 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 func (t *transactor) Destroy() {}
 
 type getDoc struct {
-	Id     string `json:"_id"`
-	Index  string `json:"_index"`
-	Source string `json:"_source"`
+	Id      string `json:"_id"`
+	Index   string `json:"_index"`
+	Source  string `json:"_source"`
+	Routing string `json:"routing,omitempty"`
 }
 
 type gotDoc struct {
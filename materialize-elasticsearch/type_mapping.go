@@ -23,16 +23,92 @@ const (
 	elasticTypeDate      elasticPropertyType = "date"
 	elasticTypeIp        elasticPropertyType = "ip"
 	elasticTypeFlattened elasticPropertyType = "flattened"
+
+	elasticTypeMatchOnlyText elasticPropertyType = "match_only_text"
+	elasticTypeWildcard      elasticPropertyType = "wildcard"
 )
 
+// textualTypes are the mapping types of string fields, which may be overridden with one another
+// through field configuration.
+var textualTypes = []elasticPropertyType{
+	elasticTypeText,
+	elasticTypeKeyword,
+	elasticTypeMatchOnlyText,
+	elasticTypeWildcard,
+}
+
 type property struct {
-	Type   elasticPropertyType `json:"type"`
-	Coerce bool                `json:"coerce,omitempty"`
-	Index  *bool               `json:"index,omitempty"`
+	Type           elasticPropertyType `json:"type"`
+	Coerce         bool                `json:"coerce,omitempty"`
+	Index          *bool               `json:"index,omitempty"`
+	Analyzer       string              `json:"analyzer,omitempty"`
+	SearchAnalyzer string              `json:"search_analyzer,omitempty"`
+	Normalizer     string              `json:"normalizer,omitempty"`
+	IgnoreAbove    *int                `json:"ignore_above,omitempty"`
+	// Multi-fields which index the value of the field in additional ways, keyed on their name.
+	Fields map[string]property `json:"fields,omitempty"`
+}
+
+// fieldConfig is the configuration of a selected field, which overrides parts of its mapping.
+type fieldConfig struct {
+	Type           elasticPropertyType `json:"type,omitempty"`
+	Analyzer       string              `json:"analyzer,omitempty"`
+	SearchAnalyzer string              `json:"search_analyzer,omitempty"`
+	Normalizer     string              `json:"normalizer,omitempty"`
+	IgnoreAbove    *int                `json:"ignore_above,omitempty"`
+	Fields         map[string]property `json:"fields,omitempty"`
+}
+
+func parseFieldConfig(raw json.RawMessage) (fieldConfig, error) {
+	var fc fieldConfig
+	if raw != nil {
+		if err := json.Unmarshal(raw, &fc); err != nil {
+			return fc, fmt.Errorf("parsing field config: %w", err)
+		}
+	}
+	return fc, nil
+}
+
+// apply overrides the mapping of the field with its configuration. Only mappings of string fields
+// may be overridden with another type.
+func (fc fieldConfig) apply(field string, prop property) (property, error) {
+	if fc.Type != "" && fc.Type != prop.Type {
+		if !slices.Contains(textualTypes, prop.Type) || !slices.Contains(textualTypes, fc.Type) {
+			return prop, fmt.Errorf("field %q: mapping type %q cannot be overridden with %q", field, prop.Type, fc.Type)
+		}
+		prop.Type = fc.Type
+	}
+
+	if (fc.Analyzer != "" || fc.SearchAnalyzer != "") && prop.Type != elasticTypeText {
+		return prop, fmt.Errorf("field %q: an analyzer can only be set for mapping type %q, not %q", field, elasticTypeText, prop.Type)
+	} else if fc.Normalizer != "" && prop.Type != elasticTypeKeyword {
+		return prop, fmt.Errorf("field %q: a normalizer can only be set for mapping type %q, not %q", field, elasticTypeKeyword, prop.Type)
+	} else if fc.IgnoreAbove != nil && prop.Type != elasticTypeKeyword && prop.Type != elasticTypeWildcard {
+		return prop, fmt.Errorf("field %q: ignore_above can only be set for mapping types %q and %q, not %q", field, elasticTypeKeyword, elasticTypeWildcard, prop.Type)
+	} else if len(fc.Fields) != 0 && !slices.Contains(textualTypes, prop.Type) {
+		return prop, fmt.Errorf("field %q: multi-fields can only be set for mapping types %v, not %q", field, textualTypes, prop.Type)
+	}
+	for name, sub := range fc.Fields {
+		if sub.Type == "" {
+			return prop, fmt.Errorf("field %q: multi-field %q must have a type", field, name)
+		}
+	}
+
+	prop.Analyzer = fc.Analyzer
+	prop.SearchAnalyzer = fc.SearchAnalyzer
+	prop.Normalizer = fc.Normalizer
+	prop.IgnoreAbove = fc.IgnoreAbove
+	prop.Fields = fc.Fields
+
+	return prop, nil
 }
 
-func propForField(field string, binding *pf.MaterializationSpec_Binding) property {
-	return propForProjection(binding.Collection.GetProjection(field))
+func propForField(field string, binding *pf.MaterializationSpec_Binding) (property, error) {
+	fc, err := parseFieldConfig(binding.FieldSelection.FieldConfigJsonMap[field])
+	if err != nil {
+		return property{}, fmt.Errorf("field %q: %w", field, err)
+	}
+	return fc.apply(field, propForProjection(binding.Collection.GetProjection(field)))
 }
 
 var numericStringTypes = map[boilerplate.StringWithNumericFormat]elasticPropertyType{
@@ -91,11 +167,22 @@ func propForProjection(p *pf.Projection) property {
 	}
 }
 
-func buildIndexProperties(b *pf.MaterializationSpec_Binding) map[string]property {
+// isTimestampProjection is true if the projection is materialized as a date mapping, which is
+// required of the timestamp field of data streams and rolling indices.
+func isTimestampProjection(p *pf.Projection) bool {
+	_, isNumeric := boilerplate.AsFormattedNumeric(p)
+	return !isNumeric && p.Inference.IsSingleType() && propForProjection(p).Type == elasticTypeDate
+}
+
+func buildIndexProperties(b *pf.MaterializationSpec_Binding) (map[string]property, error) {
 	props := make(map[string]property)
 
 	for _, v := range append(b.FieldSelection.Keys, b.FieldSelection.Values...) {
-		props[v] = propForField(v, b)
+		prop, err := propForField(v, b)
+		if err != nil {
+			return nil, err
+		}
+		props[v] = prop
 	}
 
 	if d := b.FieldSelection.Document; d != "" {
@@ -104,7 +191,7 @@ func buildIndexProperties(b *pf.MaterializationSpec_Binding) map[string]property
 		props[d] = property{Type: elasticTypeFlattened, Index: boolPtr(false)}
 	}
 
-	return props
+	return props, nil
 }
 
 func boolPtr(b bool) *bool {
@@ -145,8 +232,21 @@ func (constrainter) NewConstraints(p *pf.Projection, deltaUpdates bool) *pm.Resp
 	return &constraint
 }
 
-func (constrainter) Compatible(existing boilerplate.EndpointField, proposed *pf.Projection, _ json.RawMessage) (bool, error) {
-	prop := propForProjection(proposed).Type
+func (constrainter) Compatible(existing boilerplate.EndpointField, proposed *pf.Projection, rawFieldConfig json.RawMessage) (bool, error) {
+	fc, err := parseFieldConfig(rawFieldConfig)
+	if err != nil {
+		return false, err
+	}
+	overridden, err := fc.apply(proposed.Field, propForProjection(proposed))
+	if err != nil {
+		return false, err
+	}
+	prop := overridden.Type
+
+	if fc.Type != "" {
+		// An explicitly configured mapping type must match the existing mapping.
+		return strings.EqualFold(existing.Type, string(prop)), nil
+	}
 
 	if strings.EqualFold(existing.Type, string(elasticTypeText)) {
 		// Allow any of the "formatted string" types to be materialized into an existing text
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldConfigOverrides(t *testing.T) {
+	stringProjection := &pf.Projection{
+		Field:     "title",
+		Inference: pf.Inference{Types: []string{"string"}, String_: &pf.Inference_String{}},
+	}
+	intProjection := &pf.Projection{
+		Field:     "count",
+		Inference: pf.Inference{Types: []string{"integer"}},
+	}
+
+	apply := func(p *pf.Projection, raw string) (property, error) {
+		fc, err := parseFieldConfig(json.RawMessage(raw))
+		require.NoError(t, err)
+		return fc.apply(p.Field, propForProjection(p))
+	}
+
+	prop, err := apply(stringProjection, `{"analyzer": "english", "fields": {"raw": {"type": "keyword", "ignore_above": 256}}}`)
+	require.NoError(t, err)
+	require.Equal(t, property{
+		Type:     elasticTypeText,
+		Analyzer: "english",
+		Fields:   map[string]property{"raw": {Type: elasticTypeKeyword, IgnoreAbove: intPtr(256)}},
+	}, prop)
+
+	prop, err = apply(stringProjection, `{"type": "keyword", "normalizer": "lowercase"}`)
+	require.NoError(t, err)
+	require.Equal(t, property{Type: elasticTypeKeyword, Normalizer: "lowercase"}, prop)
+
+	_, err = apply(stringProjection, `{"normalizer": "lowercase"}`)
+	require.ErrorContains(t, err, `field "title": a normalizer can only be set for mapping type "keyword", not "text"`)
+	_, err = apply(stringProjection, `{"type": "keyword", "analyzer": "english"}`)
+	require.ErrorContains(t, err, `an analyzer can only be set for mapping type "text", not "keyword"`)
+	_, err = apply(stringProjection, `{"fields": {"raw": {}}}`)
+	require.ErrorContains(t, err, `multi-field "raw" must have a type`)
+	_, err = apply(intProjection, `{"type": "keyword"}`)
+	require.ErrorContains(t, err, `field "count": mapping type "long" cannot be overridden with "keyword"`)
+	_, err = apply(intProjection, `{"fields": {"raw": {"type": "keyword"}}}`)
+	require.ErrorContains(t, err, "multi-fields can only be set for mapping types")
+
+	// Without an explicit type, text may still be materialized to an existing keyword mapping. An
+	// explicit type must match the existing mapping.
+	for _, tt := range []struct {
+		existing string
+		config   string
+		want     bool
+	}{
+		{existing: "keyword", config: `{}`, want: true},
+		{existing: "text", config: `{"analyzer": "english"}`, want: true},
+		{existing: "keyword", config: `{"type": "keyword"}`, want: true},
+		{existing: "text", config: `{"type": "keyword"}`, want: false},
+		{existing: "keyword", config: `{"type": "wildcard"}`, want: false},
+	} {
+		compatible, err := constrainter{}.Compatible(boilerplate.EndpointField{Type: tt.existing}, stringProjection, json.RawMessage(tt.config))
+		require.NoError(t, err)
+		require.Equal(t, tt.want, compatible, "existing %s with config %s", tt.existing, tt.config)
+	}
+}
+
+func TestRoutingValue(t *testing.T) {
+	require.Equal(t, "", routingValue(nil))
+	require.Equal(t, "tenant", routingValue("tenant"))
+	require.Equal(t, "42", routingValue(int64(42)))
+	require.Equal(t, "true", routingValue(true))
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestTemplatedResources(t *testing.T) {
+	require.NoError(t, resource{Index: "events", DeltaUpdates: true, DataStream: true, TimestampField: "ts"}.Validate())
+	require.NoError(t, resource{Index: "events", DeltaUpdates: true, RollingInterval: "day", TimestampField: "ts"}.Validate())
+	require.ErrorContains(t, resource{Index: "events", DataStream: true, TimestampField: "ts"}.Validate(), "require delta updates")
+	require.ErrorContains(t, resource{Index: "events", DeltaUpdates: true, RollingInterval: "day"}.Validate(), "require a timestamp_field")
+	require.ErrorContains(t, resource{Index: "events", DeltaUpdates: true, RollingInterval: "week", TimestampField: "ts"}.Validate(), `invalid rolling_interval "week"`)
+	require.ErrorContains(t, resource{Index: "events", DeltaUpdates: true, DataStream: true, RollingInterval: "day", TimestampField: "ts"}.Validate(), "cannot set both")
+	require.ErrorContains(t, resource{Index: "events", TimestampField: "ts"}.Validate(), "timestamp_field can only be set")
+
+	props := map[string]property{"ts": {Type: elasticTypeDate}}
+	tmpl := resource{DataStream: true, RoutingField: "tenant", LifecyclePolicy: "logs"}.indexTemplate("events", nil, props)
+	require.Equal(t, []string{"events"}, tmpl.IndexPatterns)
+	require.Equal(t, &dataStreamTemplate{AllowCustomRouting: true}, tmpl.DataStream)
+	require.Equal(t, &lifecycleSettings{Name: "logs"}, tmpl.Template.Settings.Lifecycle)
+	require.Equal(t, "events", tmpl.Meta.FlowIndex)
+	require.Equal(t, 206, tmpl.Priority)
+	require.Equal(t, property{Type: elasticTypeDate}, tmpl.Template.Mappings.Properties[dataStreamTimestamp])
+	require.NotContains(t, props, dataStreamTimestamp)
+
+	tmpl = resource{RollingInterval: "month"}.indexTemplate("events", nil, props)
+	require.Equal(t, []string{"events-1*.*", "events-2*.*"}, tmpl.IndexPatterns)
+
+	// Templates of indices whose names are prefixed by another index have a higher priority.
+	require.Equal(t, []string{"events-2024-1*.*.*", "events-2024-2*.*.*"}, rollingIndexPatterns("events-2024", rollingLayouts["day"]))
+	require.Equal(t, []string{"events-1*", "events-2*"}, rollingIndexPatterns("events", rollingLayouts["year"]))
+	require.Greater(t, templatePriority("events-2024"), tmpl.Priority)
+	require.Nil(t, tmpl.DataStream)
+	require.Equal(t, props, tmpl.Template.Mappings.Properties)
+}
+
+func TestTargetIndex(t *testing.T) {
+	b := binding{index: "events", rollingLayout: rollingLayouts["day"]}
+
+	index, err := b.targetIndex("2024-05-01T23:30:00-02:00")
+	require.NoError(t, err)
+	require.Equal(t, "events-2024.05.02", index)
+
+	index, err = b.targetIndex("2024-05-01")
+	require.NoError(t, err)
+	require.Equal(t, "events-2024.05.01", index)
+
+	_, err = b.targetIndex(nil)
+	require.ErrorContains(t, err, "timestamp must be a string")
+	_, err = b.targetIndex("yesterday")
+	require.ErrorContains(t, err, `timestamp "yesterday" must be a date or date-time`)
+
+	index, err = binding{index: "events", dataStream: true}.targetIndex("2024-05-01")
+	require.NoError(t, err)
+	require.Equal(t, "events", index)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
 	"cloud.google.com/go/pubsub"
@@ -18,11 +19,87 @@ import (
 
 const (
 	IDENTIFIER_ATTRIBUTE_KEY = "identifier"
+
+	// Attributes of messages which are compressed or are chunks of a split document.
+	CONTENT_ENCODING_ATTRIBUTE_KEY = "content_encoding"
+	CHUNK_ID_ATTRIBUTE_KEY         = "chunk_id"
+	CHUNK_INDEX_ATTRIBUTE_KEY      = "chunk_index"
+	CHUNK_COUNT_ATTRIBUTE_KEY      = "chunk_count"
+)
+
+// reservedAttributes are attribute keys which are set by the connector, and so can't be used for
+// projections mapped to attributes.
+var reservedAttributes = []string{
+	IDENTIFIER_ATTRIBUTE_KEY,
+	CONTENT_ENCODING_ATTRIBUTE_KEY,
+	CHUNK_ID_ATTRIBUTE_KEY,
+	CHUNK_INDEX_ATTRIBUTE_KEY,
+	CHUNK_COUNT_ATTRIBUTE_KEY,
+}
+
+const (
+	orderingKeyHash = "key_hash"
+	orderingKey     = "key"
+	orderingNone    = "none"
+
+	oversizedReject = "reject"
+	oversizedSplit  = "split"
+
+	// Limits of Pub/Sub on the ordering key and attributes of messages.
+	maxOrderingKeyBytes    = 1024
+	maxAttributeKeyBytes   = 256
+	maxAttributeValueBytes = 1024
 )
 
 type config struct {
 	ProjectID   string                        `json:"project_id" jsonschema:"title=Google Cloud Project ID"`
 	Credentials *google_auth.CredentialConfig `json:"credentials" jsonschema:"title=Authentication"`
+	DeadLetter  *deadLetterConfig             `json:"dead_letter,omitempty" jsonschema:"title=Dead Letter Topic"`
+	Advanced    advancedConfig                `json:"advanced,omitempty" jsonschema:"title=Advanced Options" jsonschema_extras:"advanced=true"`
+}
+
+type advancedConfig struct {
+	CompressionThresholdBytes int    `json:"compression_threshold_bytes,omitempty" jsonschema:"title=Compression Threshold"`
+	OversizedDocuments        string `json:"oversized_documents,omitempty" jsonschema:"title=Oversized Documents,enum=reject,enum=split"`
+}
+
+func (advancedConfig) GetFieldDocString(fieldName string) string {
+	switch fieldName {
+	case "CompressionThresholdBytes":
+		return fmt.Sprintf("Documents of at least this many bytes are published gzip-compressed with a %q attribute of \"gzip\". Documents are not compressed if unset.", CONTENT_ENCODING_ATTRIBUTE_KEY)
+	case "OversizedDocuments":
+		return "How to handle documents which exceed the 10MB Pub/Sub message size limit, even after compression. " +
+			"They are rejected (the default) which fails the materialization unless a dead letter topic is configured. " +
+			fmt.Sprintf("Or they are split into multiple messages with %q and %q and %q attributes for subscribers to reassemble them.",
+				CHUNK_ID_ATTRIBUTE_KEY, CHUNK_INDEX_ATTRIBUTE_KEY, CHUNK_COUNT_ATTRIBUTE_KEY)
+	default:
+		return ""
+	}
+}
+
+func (c advancedConfig) oversizedDocuments() string {
+	if c.OversizedDocuments == "" {
+		return oversizedReject
+	}
+	return c.OversizedDocuments
+}
+
+// deadLetterConfig configures a topic which documents that cannot be published are published to
+// instead, rather than failing the materialization.
+type deadLetterConfig struct {
+	TopicName                  string `json:"topic" jsonschema:"title=Topic Name"`
+	MaxDocumentsPerTransaction int    `json:"max_documents_per_transaction,omitempty" jsonschema:"title=Max Documents Per Transaction"`
+}
+
+func (deadLetterConfig) GetFieldDocString(fieldName string) string {
+	switch fieldName {
+	case "TopicName":
+		return "Name of the topic to publish documents which cannot be published to their topic to, such as those exceeding the maximum message size. Created if it does not exist."
+	case "MaxDocumentsPerTransaction":
+		return "Fail the materialization if more than this many documents are dead-lettered in a single transaction. Defaults to 100."
+	default:
+		return ""
+	}
 }
 
 func (config) GetFieldDocString(fieldName string) string {
@@ -37,6 +114,18 @@ func (config) GetFieldDocString(fieldName string) string {
 func (c *config) Validate() error {
 	if c.ProjectID == "" {
 		return fmt.Errorf("missing project ID")
+	} else if c.DeadLetter != nil && c.DeadLetter.TopicName == "" {
+		return fmt.Errorf("missing dead letter topic name")
+	} else if c.DeadLetter != nil && c.DeadLetter.MaxDocumentsPerTransaction < 0 {
+		return fmt.Errorf("dead letter max_documents_per_transaction cannot be negative")
+	} else if c.Advanced.CompressionThresholdBytes < 0 {
+		return fmt.Errorf("compression_threshold_bytes cannot be negative")
+	}
+
+	switch c.Advanced.OversizedDocuments {
+	case "", oversizedReject, oversizedSplit:
+	default:
+		return fmt.Errorf("invalid oversized_documents %q: must be one of reject or split", c.Advanced.OversizedDocuments)
 	}
 
 	return c.Credentials.Validate()
@@ -61,9 +150,11 @@ func (c *config) client(ctx context.Context) (*pubsub.Client, error) {
 }
 
 type resource struct {
-	TopicName                 string `json:"topic" jsonschema:"title=Topic Name" jsonschema_extras:"x-collection-name=true"`
-	Identifier                string `json:"identifier,omitempty" jsonschema:"title=Resource Binding Identifier"`
-	CreateDefaultSubscription bool   `json:"create_default_subscription" jsonschema:"title=Create with Default Subscription,default=true"`
+	TopicName                 string   `json:"topic" jsonschema:"title=Topic Name" jsonschema_extras:"x-collection-name=true"`
+	Identifier                string   `json:"identifier,omitempty" jsonschema:"title=Resource Binding Identifier"`
+	CreateDefaultSubscription bool     `json:"create_default_subscription" jsonschema:"title=Create with Default Subscription,default=true"`
+	OrderingKey               string   `json:"ordering_key,omitempty" jsonschema:"title=Ordering Key,enum=key_hash,enum=key,enum=none"`
+	Attributes                []string `json:"attributes,omitempty" jsonschema:"title=Attribute Fields"`
 }
 
 func (resource) GetFieldDocString(fieldName string) string {
@@ -75,6 +166,13 @@ func (resource) GetFieldDocString(fieldName string) string {
 		return "Name of the topic to publish materialized results to."
 	case "CreateDefaultSubscription":
 		return "Create a default subscription when creating the topic. Will be created as \"<topic>-sub\". Has no effect if the topic already exists."
+	case "OrderingKey":
+		return "Ordering key of published messages, which subscribers with message ordering enabled receive messages of in order. " +
+			"\"key_hash\" (the default) is a hash of the collection key. \"key\" is the values of the collection key joined by '/'. " +
+			"\"none\" publishes messages without an ordering key."
+	case "Attributes":
+		return "Fields of the collection which are included as attributes of published messages, keyed by the field name. " +
+			"Subscribers may filter messages by these attributes."
 	default:
 		return ""
 	}
@@ -84,9 +182,33 @@ func (r resource) Validate() error {
 	if r.TopicName == "" {
 		return fmt.Errorf("missing topic name")
 	}
+
+	switch r.OrderingKey {
+	case "", orderingKeyHash, orderingKey, orderingNone:
+	default:
+		return fmt.Errorf("invalid ordering_key %q: must be one of key_hash, key, or none", r.OrderingKey)
+	}
+
+	for _, a := range r.Attributes {
+		if slices.Contains(reservedAttributes, a) {
+			return fmt.Errorf("attribute field %q conflicts with an attribute set by the connector", a)
+		} else if strings.HasPrefix(a, "goog") {
+			return fmt.Errorf("attribute field %q cannot start with 'goog'", a)
+		} else if len(a) > maxAttributeKeyBytes {
+			return fmt.Errorf("attribute field %q is longer than %d bytes", a, maxAttributeKeyBytes)
+		}
+	}
+
 	return nil
 }
 
+func (r resource) orderingKey() string {
+	if r.OrderingKey == "" {
+		return orderingKeyHash
+	}
+	return r.OrderingKey
+}
+
 func Driver() driver {
 	return driver{}
 }
@@ -151,6 +273,9 @@ func (d driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Res
 			case projection.IsRootDocumentProjection():
 				constraint.Type = pm.Response_Validated_Constraint_LOCATION_REQUIRED
 				constraint.Reason = "The root document must be materialized"
+			case slices.Contains(res.Attributes, projection.Field):
+				constraint.Type = pm.Response_Validated_Constraint_FIELD_REQUIRED
+				constraint.Reason = "This field is included as a message attribute"
 			default:
 				constraint.Type = pm.Response_Validated_Constraint_FIELD_FORBIDDEN
 				constraint.Reason = "PubSub only materializes the full document"
@@ -158,6 +283,12 @@ func (d driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Res
 			constraints[projection.Field] = constraint
 		}
 
+		for _, a := range res.Attributes {
+			if c, ok := constraints[a]; !ok || c.Type != pm.Response_Validated_Constraint_FIELD_REQUIRED {
+				return nil, fmt.Errorf("attribute field %q is not a field of collection %s", a, b.Collection.Name)
+			}
+		}
+
 		// Include identifier in the resource path if configured.
 		resourcePath := []string{res.TopicName}
 		if res.Identifier != "" {
@@ -225,6 +356,20 @@ func (d driver) Apply(ctx context.Context, req *pm.Request_Apply) (*pm.Response_
 	}
 
 	actions := []string{}
+	if dl := cfg.DeadLetter; dl != nil {
+		if _, ok := checkedTopics[dl.TopicName]; !ok {
+			exists, err := client.Topic(dl.TopicName).Exists(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("pubsub apply dead letter topic check error: %w", err)
+			} else if !exists {
+				if _, err := client.CreateTopic(ctx, dl.TopicName); err != nil {
+					return nil, fmt.Errorf("pubsub apply create dead letter topic error: %w", err)
+				}
+				actions = append(actions, fmt.Sprintf("created dead letter topic %s", dl.TopicName))
+			}
+		}
+	}
+
 	for _, topic := range newTopics {
 		var t *pubsub.Topic
 		var s *pubsub.Subscription
@@ -275,15 +420,43 @@ func (d driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Tran
 
 		// Allows for the reading of messages in-order with a provided ordering key. See
 		// https://cloud.google.com/pubsub/docs/ordering
-		t.EnableMessageOrdering = true
+		t.EnableMessageOrdering = res.orderingKey() != orderingNone
+
+		// Attribute fields may be keys or values of the field selection.
+		var attributes []attributeField
+		for idx, f := range b.FieldSelection.Keys {
+			if slices.Contains(res.Attributes, f) {
+				attributes = append(attributes, attributeField{name: f, key: true, idx: idx})
+			}
+		}
+		for idx, f := range b.FieldSelection.Values {
+			if slices.Contains(res.Attributes, f) {
+				attributes = append(attributes, attributeField{name: f, idx: idx})
+			}
+		}
+
 		topicBindings = append(topicBindings, &topicBinding{
-			identifier: res.Identifier,
-			topic:      t,
+			identifier:        res.Identifier,
+			topic:             t,
+			orderingKey:       res.orderingKey(),
+			attributes:        attributes,
+			compressThreshold: cfg.Advanced.CompressionThresholdBytes,
+			splitOversized:    cfg.Advanced.oversizedDocuments() == oversizedSplit,
 		})
 	}
 
+	var deadLetters *boilerplate.DeadLetterQueue
+	if dl := cfg.DeadLetter; dl != nil {
+		deadLetters = boilerplate.NewDeadLetterQueue(
+			&deadLetterTopic{topic: client.Topic(dl.TopicName)},
+			dl.MaxDocumentsPerTransaction,
+			open.Materialization.Bindings,
+		)
+	}
+
 	return &transactor{
-		bindings: topicBindings,
+		bindings:    topicBindings,
+		deadLetters: deadLetters,
 	}, &pm.Response_Opened{}, nil
 }
 
@@ -1,13 +1,29 @@
 package connector
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
 	"github.com/bradleyjkemp/cupaloy"
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
 	pm "github.com/estuary/flow/go/protocols/materialize"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func TestSpec(t *testing.T) {
@@ -21,3 +37,156 @@ func TestSpec(t *testing.T) {
 	require.NoError(t, err)
 	cupaloy.SnapshotT(t, string(formatted))
 }
+
+func TestMessages(t *testing.T) {
+	t.Parallel()
+
+	packedKey := tuple.Tuple{"a/b", int64(1)}.Pack()
+	doc := json.RawMessage(`{"id":"a/b","n":1,"kind":"widget"}`)
+
+	b := &topicBinding{
+		identifier:  "ident",
+		orderingKey: orderingKey,
+		attributes:  []attributeField{{name: "id", key: true, idx: 0}, {name: "kind", idx: 0}, {name: "missing", idx: 1}},
+	}
+	msgs, err := b.messages(packedKey, tuple.Tuple{"a/b", int64(1)}, tuple.Tuple{"widget", nil}, doc)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "a/b/1", msgs[0].OrderingKey)
+	require.Equal(t, map[string]string{"identifier": "ident", "id": "a/b", "kind": "widget"}, msgs[0].Attributes)
+	require.Equal(t, []byte(doc), msgs[0].Data)
+
+	b = &topicBinding{orderingKey: orderingKeyHash, compressThreshold: 10}
+	msgs, err = b.messages(packedKey, nil, nil, doc)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%08x", PackedKeyHash_HH64(packedKey)), msgs[0].OrderingKey)
+	require.Equal(t, "gzip", msgs[0].Attributes[CONTENT_ENCODING_ATTRIBUTE_KEY])
+	r, err := gzip.NewReader(bytes.NewReader(msgs[0].Data))
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte(doc), decompressed)
+
+	// Documents which are too large are rejected unless they are split.
+	large := json.RawMessage(`"` + strings.Repeat("x", 2*maxMessageBytes) + `"`)
+	b = &topicBinding{orderingKey: orderingNone}
+	_, err = b.messages(packedKey, nil, nil, large)
+	require.ErrorIs(t, err, errDocumentTooLarge)
+
+	b.splitOversized = true
+	msgs, err = b.messages(packedKey, nil, nil, large)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	require.Equal(t, []byte(large), reassemble(t, msgs))
+}
+
+// reassemble joins the data of chunked messages.
+func reassemble(t *testing.T, msgs []*pubsub.Message) []byte {
+	var out []byte
+	for idx, msg := range msgs {
+		require.LessOrEqual(t, messageSize(msg), maxMessageBytes)
+		require.Equal(t, msgs[0].Attributes[CHUNK_ID_ATTRIBUTE_KEY], msg.Attributes[CHUNK_ID_ATTRIBUTE_KEY])
+		require.Equal(t, strconv.Itoa(idx), msg.Attributes[CHUNK_INDEX_ATTRIBUTE_KEY])
+		require.Equal(t, strconv.Itoa(len(msgs)), msg.Attributes[CHUNK_COUNT_ATTRIBUTE_KEY])
+		out = append(out, msg.Data...)
+	}
+	return out
+}
+
+func TestPublishResumesOrderingKey(t *testing.T) {
+	ctx := context.Background()
+
+	srv := pstest.NewServer()
+	defer srv.Close()
+	client, err := pubsub.NewClient(ctx, "test-project",
+		option.WithEndpoint(srv.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+
+	topic, err := client.CreateTopic(ctx, "test-topic")
+	require.NoError(t, err)
+	defer topic.Stop()
+	topic.EnableMessageOrdering = true
+	transactor := &transactor{bindings: []*topicBinding{{topic: topic}}}
+
+	// A message which fails to publish pauses publishing for its ordering key. It's resumed, so
+	// that later messages of the key are still published by the topic.
+	group, groupCtx := errgroup.WithContext(ctx)
+	transactor.publish(groupCtx, group, 0, &pubsub.Message{Data: make([]byte, pubsub.MaxPublishRequestBytes), OrderingKey: "key"})
+	require.ErrorIs(t, group.Wait(), pubsub.ErrOversizedMessage)
+
+	group, groupCtx = errgroup.WithContext(ctx)
+	transactor.publish(groupCtx, group, 0, &pubsub.Message{Data: []byte("small"), OrderingKey: "key"})
+	require.NoError(t, group.Wait())
+	require.Len(t, srv.Messages(), 1)
+}
+
+// TestPublishEmulator publishes messages to the Pub/Sub emulator, which is started with:
+//
+//	gcloud beta emulators pubsub start --host-port=localhost:8085
+//	PUBSUB_EMULATOR_HOST=localhost:8085 go test -run TestPublishEmulator
+func TestPublishEmulator(t *testing.T) {
+	if os.Getenv("PUBSUB_EMULATOR_HOST") == "" {
+		t.Skip("PUBSUB_EMULATOR_HOST is not set")
+	}
+	ctx := context.Background()
+
+	client, err := pubsub.NewClient(ctx, "test-project")
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := fmt.Sprintf("test-topic-%d", time.Now().UnixNano())
+	topic, err := client.CreateTopic(ctx, name)
+	require.NoError(t, err)
+	defer topic.Delete(ctx)
+	topic.EnableMessageOrdering = true
+
+	sub, err := client.CreateSubscription(ctx, name+"-sub", pubsub.SubscriptionConfig{Topic: topic, EnableMessageOrdering: true})
+	require.NoError(t, err)
+	defer sub.Delete(ctx)
+
+	b := &topicBinding{
+		topic:          topic,
+		orderingKey:    orderingKey,
+		attributes:     []attributeField{{name: "kind", idx: 0}},
+		splitOversized: true,
+	}
+	small := json.RawMessage(`{"id":1,"kind":"small"}`)
+	large := json.RawMessage(`"` + strings.Repeat("x", maxMessageBytes+1) + `"`)
+
+	var published []*pubsub.Message
+	for _, doc := range []json.RawMessage{small, large} {
+		msgs, err := b.messages(tuple.Tuple{int64(1)}.Pack(), nil, tuple.Tuple{"doc"}, doc)
+		require.NoError(t, err)
+		published = append(published, msgs...)
+	}
+	require.Len(t, published, 3)
+
+	for _, msg := range published {
+		_, err := topic.Publish(ctx, msg).Get(ctx)
+		require.NoError(t, err)
+	}
+	topic.Stop()
+
+	var mu sync.Mutex
+	var received []*pubsub.Message
+	recvCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	require.NoError(t, sub.Receive(recvCtx, func(_ context.Context, msg *pubsub.Message) {
+		msg.Ack()
+		mu.Lock()
+		defer mu.Unlock()
+		if received = append(received, msg); len(received) == len(published) {
+			cancel()
+		}
+	}))
+
+	require.Len(t, received, 3)
+	require.Equal(t, []byte(small), received[0].Data)
+	require.Equal(t, "1", received[0].OrderingKey)
+	require.Equal(t, "doc", received[0].Attributes["kind"])
+	require.Equal(t, []byte(large), reassemble(t, received[1:]))
+}
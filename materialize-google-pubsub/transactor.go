@@ -1,25 +1,174 @@
 package connector
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"strconv"
+	"strings"
 
 	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/apiv1/pubsubpb"
 	m "github.com/estuary/connectors/go/protocols/materialize"
+	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
 	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/google/uuid"
 	"github.com/minio/highwayhash"
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/proto"
 )
 
 type transactor struct {
 	bindings []*topicBinding
+
+	// Documents which cannot be published are dead-lettered if this is configured.
+	deadLetters *boilerplate.DeadLetterQueue
 }
 
 type topicBinding struct {
-	identifier string
-	topic      *pubsub.Topic
+	identifier        string
+	topic             *pubsub.Topic
+	orderingKey       string
+	attributes        []attributeField
+	compressThreshold int
+	splitOversized    bool
+}
+
+// attributeField is a selected field which is included as a message attribute.
+type attributeField struct {
+	name string
+	// key is whether the field is a key of the field selection, and idx is its index among either
+	// the keys or values.
+	key bool
+	idx int
+}
+
+// maxMessageBytes is the largest encoded message which is published, leaving room for the rest of
+// the publish request such as the topic name.
+const maxMessageBytes int = pubsub.MaxPublishRequestBytes - 4*1024
+
+// errDocumentTooLarge is the cause of documents which can't be published within maxMessageBytes.
+var errDocumentTooLarge = errors.New("document is too large to publish")
+
+// messages builds the messages which a document is published as. This is a single message unless
+// the document is split into chunks.
+func (b *topicBinding) messages(packedKey []byte, key, values tuple.Tuple, doc json.RawMessage) ([]*pubsub.Message, error) {
+	var msg = &pubsub.Message{Data: doc, Attributes: make(map[string]string)}
+
+	switch b.orderingKey {
+	case orderingKeyHash:
+		msg.OrderingKey = fmt.Sprintf("%08x", PackedKeyHash_HH64(packedKey))
+	case orderingKey:
+		ts, err := tuple.Unpack(packedKey)
+		if err != nil {
+			return nil, fmt.Errorf("unpacking key: %w", err)
+		}
+		var parts []string
+		for _, t := range ts {
+			parts = append(parts, attributeValue(t))
+		}
+		if msg.OrderingKey = strings.Join(parts, "/"); len(msg.OrderingKey) > maxOrderingKeyBytes {
+			return nil, fmt.Errorf("ordering key %q is longer than %d bytes", msg.OrderingKey, maxOrderingKeyBytes)
+		}
+	}
+
+	// Only include an identifier attribute if an identifier has been configured.
+	if b.identifier != "" {
+		msg.Attributes[IDENTIFIER_ATTRIBUTE_KEY] = b.identifier
+	}
+	for _, a := range b.attributes {
+		var v tuple.TupleElement
+		if a.key {
+			v = key[a.idx]
+		} else {
+			v = values[a.idx]
+		}
+		if v == nil {
+			continue
+		} else if msg.Attributes[a.name] = attributeValue(v); len(msg.Attributes[a.name]) > maxAttributeValueBytes {
+			return nil, fmt.Errorf("value of attribute %q is longer than %d bytes", a.name, maxAttributeValueBytes)
+		}
+	}
+
+	if b.compressThreshold > 0 && len(doc) >= b.compressThreshold {
+		var buf bytes.Buffer
+		var w = gzip.NewWriter(&buf)
+		if _, err := w.Write(doc); err != nil {
+			return nil, fmt.Errorf("compressing document: %w", err)
+		} else if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("compressing document: %w", err)
+		}
+		msg.Data = buf.Bytes()
+		msg.Attributes[CONTENT_ENCODING_ATTRIBUTE_KEY] = "gzip"
+	}
+
+	if size := messageSize(msg); size <= maxMessageBytes {
+		return []*pubsub.Message{msg}, nil
+	} else if !b.splitOversized {
+		return nil, fmt.Errorf("%w: its message is %d bytes, which exceeds the limit of %d bytes", errDocumentTooLarge, size, maxMessageBytes)
+	}
+
+	return splitMessage(msg), nil
+}
+
+// splitMessage splits the data of an oversized message into chunks which are each published as a
+// message with the attributes and ordering key of the original, and chunk attributes which allow
+// subscribers to reassemble them.
+func splitMessage(msg *pubsub.Message) []*pubsub.Message {
+	var chunkID = uuid.NewString()
+	var data = msg.Data
+
+	// Account for the largest possible chunk attributes.
+	var attributes = maps.Clone(msg.Attributes)
+	attributes[CHUNK_ID_ATTRIBUTE_KEY] = chunkID
+	attributes[CHUNK_INDEX_ATTRIBUTE_KEY] = strconv.Itoa(len(data))
+	attributes[CHUNK_COUNT_ATTRIBUTE_KEY] = strconv.Itoa(len(data))
+	var chunkBytes = maxMessageBytes - messageSize(&pubsub.Message{Attributes: attributes, OrderingKey: msg.OrderingKey}) - 16
+
+	var count = (len(data) + chunkBytes - 1) / chunkBytes
+	var out []*pubsub.Message
+	for idx := 0; idx < count; idx++ {
+		var chunk = &pubsub.Message{
+			Data:        data[idx*chunkBytes : min((idx+1)*chunkBytes, len(data))],
+			Attributes:  maps.Clone(msg.Attributes),
+			OrderingKey: msg.OrderingKey,
+		}
+		chunk.Attributes[CHUNK_ID_ATTRIBUTE_KEY] = chunkID
+		chunk.Attributes[CHUNK_INDEX_ATTRIBUTE_KEY] = strconv.Itoa(idx)
+		chunk.Attributes[CHUNK_COUNT_ATTRIBUTE_KEY] = strconv.Itoa(count)
+		out = append(out, chunk)
+	}
+	return out
+}
+
+// messageSize is the encoded size of a message, as computed by the Pub/Sub client.
+func messageSize(msg *pubsub.Message) int {
+	return proto.Size(&pubsubpb.PubsubMessage{
+		Data:        msg.Data,
+		Attributes:  msg.Attributes,
+		OrderingKey: msg.OrderingKey,
+	})
+}
+
+// attributeValue formats a field value as a string.
+func attributeValue(v tuple.TupleElement) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case json.RawMessage:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
 }
 
 func (t *transactor) UnmarshalState(state json.RawMessage) error                  { return nil }
@@ -53,37 +202,55 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 	for it.Next() {
 		binding := t.bindings[it.Binding]
 
-		msg := &pubsub.Message{
-			Data:        it.RawJSON,
-			OrderingKey: fmt.Sprintf("%08x", PackedKeyHash_HH64(it.PackedKey)),
-		}
-		// Only include an identifier attribute if an identifier has been configured.
-		if binding.identifier != "" {
-			msg.Attributes = map[string]string{IDENTIFIER_ATTRIBUTE_KEY: binding.identifier}
-		}
-
-		// Blocks if the maximum number of messages are queue'd, since
-		// topic.PublishSettings.FlowControlSettings.LimitExceededBehavior = pubsub.FlowControlBlock
-		res := binding.topic.Publish(ctx, msg)
-
-		errGroup.Go(func() error {
-			// This will block until the individual publish call is complete.
-			if _, err := res.Get(ctx); err != nil {
-				// An error here indicates a non-retryable error. Retrying retryable errors is handled
-				// by the PubSub client. Returning an error from (*transactor).Store will result in the
-				// transaction being cancelled. With ordering enabled, we would normally need to resume
-				// publishing (see https://cloud.google.com/pubsub/docs/publisher#retry_ordering), but
-				// since returning an error here will cause the connector to exit, we don't need to
-				// worry about resuming publishing from the same client.
-				return fmt.Errorf("error publishing document for binding [%d]: %w", it.Binding, err)
+		msgs, err := binding.messages(it.PackedKey, it.Key, it.Values, it.RawJSON)
+		if errors.Is(err, errDocumentTooLarge) {
+			if err := t.deadLetters.Reject(it.Binding, it.RawJSON, err); err != nil {
+				return nil, fmt.Errorf("error publishing document for binding [%d]: %w", it.Binding, err)
 			}
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("building message for binding [%d]: %w", it.Binding, err)
+		}
 
-			return nil
-		})
+		for _, msg := range msgs {
+			t.publish(ctx, errGroup, it.Binding, msg)
+		}
 	}
 
 	// Wait for all messages to be delivered.
-	return nil, errGroup.Wait()
+	if err := errGroup.Wait(); err != nil {
+		return nil, err
+	}
+
+	return nil, t.deadLetters.Flush(it.Context())
+}
+
+// publish publishes a message of a document, and waits for the result in the errGroup. Messages
+// are built by (*topicBinding).messages to be within the limits of a publish request.
+func (t *transactor) publish(ctx context.Context, errGroup *errgroup.Group, binding int, msg *pubsub.Message) {
+	var topic = t.bindings[binding].topic
+
+	// Blocks if the maximum number of messages are queue'd, since
+	// topic.PublishSettings.FlowControlSettings.LimitExceededBehavior = pubsub.FlowControlBlock
+	res := topic.Publish(ctx, msg)
+
+	errGroup.Go(func() error {
+		// This will block until the individual publish call is complete.
+		if _, err := res.Get(ctx); err != nil {
+			// An error here indicates a non-retryable error. Retrying retryable errors is handled
+			// by the PubSub client. Returning an error from (*transactor).Store will result in the
+			// transaction being cancelled. With ordering enabled, publishing is paused for the
+			// ordering key of the message until it's resumed (see
+			// https://cloud.google.com/pubsub/docs/publisher#retry_ordering), so that the topic
+			// may still be used to publish messages of the key.
+			if msg.OrderingKey != "" {
+				topic.ResumePublish(msg.OrderingKey)
+			}
+			return fmt.Errorf("error publishing document for binding [%d]: %w", binding, err)
+		}
+
+		return nil
+	})
 }
 
 func (t *transactor) Destroy() {
@@ -91,4 +258,46 @@ func (t *transactor) Destroy() {
 		// Wait for all async messages to finished sending for each topic.
 		b.topic.Stop()
 	}
+	if err := t.deadLetters.Close(context.Background()); err != nil {
+		log.WithField("error", err).Warn("closing dead letter topic")
+	}
+}
+
+// maxDeadLetterDocumentBytes is the largest document which is included in a dead letter, leaving
+// room for the rest of the dead letter within the maximum publish request size.
+const maxDeadLetterDocumentBytes = pubsub.MaxPublishRequestBytes - 64*1024
+
+// deadLetterTopic is a boilerplate.DeadLetterSink which publishes dead letters to a topic.
+type deadLetterTopic struct {
+	topic *pubsub.Topic
+}
+
+func (d *deadLetterTopic) WriteDeadLetters(ctx context.Context, letters []boilerplate.DeadLetter) error {
+	var results []*pubsub.PublishResult
+	for _, l := range letters {
+		if len(l.Document) > maxDeadLetterDocumentBytes {
+			// The document of a dead letter was likely rejected for being too large to publish,
+			// and would be too large to publish as part of its dead letter too.
+			l.Document = nil
+			l.Reason += " (the document is too large to be included in this dead letter)"
+		}
+
+		data, err := json.Marshal(l)
+		if err != nil {
+			return fmt.Errorf("marshalling dead letter: %w", err)
+		}
+		results = append(results, d.topic.Publish(ctx, &pubsub.Message{Data: data}))
+	}
+
+	for _, res := range results {
+		if _, err := res.Get(ctx); err != nil {
+			return fmt.Errorf("publishing dead letter: %w", err)
+		}
+	}
+	return nil
+}
+
+func (d *deadLetterTopic) Close(context.Context) error {
+	d.topic.Stop()
+	return nil
 }
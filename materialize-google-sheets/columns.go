@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"google.golang.org/api/sheets/v4"
+)
+
+// column is a user-facing column of a sheet.
+type column struct {
+	// Field materialized into the column.
+	field string
+	// Header of the column.
+	header string
+	// Index of the field's value within the concatenated key and values of a document.
+	index int
+	// Number format of the column, or nil if the column is not formatted.
+	numberFormat *sheets.NumberFormat
+}
+
+// buildColumns returns the user-facing columns of a binding. Fields of the resource's column order
+// come first, followed by the remaining selected fields in the order of their selection.
+func buildColumns(res resource, binding *pf.MaterializationSpec_Binding) []column {
+	var selected = append(append([]string{}, binding.FieldSelection.Keys...), binding.FieldSelection.Values...)
+
+	var ordered []string
+	for _, f := range res.ColumnOrder {
+		if slices.Contains(selected, f) {
+			ordered = append(ordered, f)
+		}
+	}
+	for _, f := range selected {
+		if !slices.Contains(ordered, f) {
+			ordered = append(ordered, f)
+		}
+	}
+
+	var out []column
+	for _, f := range ordered {
+		var col = column{
+			field:  f,
+			header: f,
+			index:  slices.Index(selected, f),
+		}
+		if h, ok := res.ColumnNames[f]; ok {
+			col.header = h
+		}
+		if res.FormatColumns {
+			if p := binding.Collection.GetProjection(f); p != nil {
+				col.numberFormat = numberFormat(&p.Inference)
+			}
+		}
+		out = append(out, col)
+	}
+
+	return out
+}
+
+// numberFormat returns the number format of a column from the inferred type of its field, or nil if
+// the column has the default format.
+func numberFormat(inf *pf.Inference) *sheets.NumberFormat {
+	var types []string
+	for _, t := range inf.Types {
+		if t != pf.JsonTypeNull {
+			types = append(types, t)
+		}
+	}
+	if len(types) != 1 {
+		return nil
+	}
+
+	switch {
+	case types[0] == pf.JsonTypeInteger:
+		// Large integers such as IDs are otherwise displayed in scientific notation.
+		return &sheets.NumberFormat{Type: "NUMBER", Pattern: "0"}
+	case types[0] == pf.JsonTypeString && inf.String_ != nil && inf.String_.Format == "date":
+		return &sheets.NumberFormat{Type: "DATE", Pattern: "yyyy-mm-dd"}
+	case types[0] == pf.JsonTypeString && inf.String_ != nil && inf.String_.Format == "date-time":
+		return &sheets.NumberFormat{Type: "DATE_TIME", Pattern: "yyyy-mm-dd hh:mm:ss"}
+	default:
+		return nil
+	}
+}
+
+// cell returns the cell of a value of the column. Dates and date-times of formatted columns are
+// written as date values of UTC, so that they can be sorted and filtered as dates.
+func (c column) cell(e tuple.TupleElement) *sheets.CellData {
+	if c.numberFormat == nil {
+		return valueToCell(e)
+	}
+
+	var cell = valueToCell(e)
+	if s, ok := e.(string); ok {
+		var layout = time.RFC3339Nano
+		if c.numberFormat.Type == "DATE" {
+			layout = time.DateOnly
+		}
+		if c.numberFormat.Type != "NUMBER" {
+			if t, err := time.Parse(layout, s); err == nil {
+				var serial = dateSerial(t)
+				cell = &sheets.CellData{UserEnteredValue: &sheets.ExtendedValue{NumberValue: &serial}}
+			}
+		}
+	}
+	cell.UserEnteredFormat = &sheets.CellFormat{NumberFormat: c.numberFormat}
+
+	return cell
+}
+
+// dateSerial returns the spreadsheet date value of a time, which is the number of days since
+// 1899-12-30 in UTC.
+func dateSerial(t time.Time) float64 {
+	const unixEpochSerial = 25569 // 1970-01-01.
+	var secs = float64(t.Unix()) + float64(t.Nanosecond())/1e9
+	return unixEpochSerial + secs/(24*60*60)
+}
+
+// validateColumns returns an error if the column options of a resource name fields which are not
+// projections of the collection.
+func validateColumns(res resource, collection *pf.CollectionSpec) error {
+	var known = func(f string) bool {
+		return slices.ContainsFunc(collection.Projections, func(p pf.Projection) bool { return p.Field == f })
+	}
+
+	for _, f := range res.ColumnOrder {
+		if !known(f) {
+			return fmt.Errorf("column order field %q is not a projection of collection %q", f, collection.Name)
+		}
+	}
+	for f := range res.ColumnNames {
+		if !known(f) {
+			return fmt.Errorf("column name field %q is not a projection of collection %q", f, collection.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestBuildColumns(t *testing.T) {
+	var binding = &pf.MaterializationSpec_Binding{
+		Collection: pf.CollectionSpec{
+			Name: "acmeCo/orders",
+			Projections: []pf.Projection{
+				{Field: "created", Inference: pf.Inference{Types: []string{"string"}, String_: &pf.Inference_String{Format: "date-time"}}},
+				{Field: "id", Inference: pf.Inference{Types: []string{"integer"}}},
+				{Field: "note", Inference: pf.Inference{Types: []string{"string", "null"}}},
+				{Field: "shipped", Inference: pf.Inference{Types: []string{"string", "null"}, String_: &pf.Inference_String{Format: "date"}}},
+			},
+		},
+		FieldSelection: pf.FieldSelection{
+			Keys:   []string{"id"},
+			Values: []string{"created", "note", "shipped"},
+		},
+	}
+
+	// By default, columns are in the order of the field selection.
+	var cols = buildColumns(resource{Sheet: "orders"}, binding)
+	require.Equal(t, []column{
+		{field: "id", header: "id", index: 0},
+		{field: "created", header: "created", index: 1},
+		{field: "note", header: "note", index: 2},
+		{field: "shipped", header: "shipped", index: 3},
+	}, cols)
+
+	// Ordered columns come first, and unselected fields are ignored.
+	cols = buildColumns(resource{
+		Sheet:         "orders",
+		ColumnOrder:   []string{"note", "missing", "created"},
+		ColumnNames:   map[string]string{"id": "Order ID"},
+		FormatColumns: true,
+	}, binding)
+	require.Equal(t, []column{
+		{field: "note", header: "note", index: 2},
+		{field: "created", header: "created", index: 1, numberFormat: &sheets.NumberFormat{Type: "DATE_TIME", Pattern: "yyyy-mm-dd hh:mm:ss"}},
+		{field: "id", header: "Order ID", index: 0, numberFormat: &sheets.NumberFormat{Type: "NUMBER", Pattern: "0"}},
+		{field: "shipped", header: "shipped", index: 3, numberFormat: &sheets.NumberFormat{Type: "DATE", Pattern: "yyyy-mm-dd"}},
+	}, cols)
+
+	require.NoError(t, validateColumns(resource{ColumnOrder: []string{"note"}}, &binding.Collection))
+	require.ErrorContains(t, validateColumns(resource{ColumnOrder: []string{"missing"}}, &binding.Collection),
+		`column order field "missing" is not a projection of collection "acmeCo/orders"`)
+	require.ErrorContains(t, validateColumns(resource{ColumnNames: map[string]string{"missing": "Missing"}}, &binding.Collection),
+		`column name field "missing" is not a projection of collection "acmeCo/orders"`)
+}
+
+func TestColumnCell(t *testing.T) {
+	var dateTime = column{numberFormat: &sheets.NumberFormat{Type: "DATE_TIME", Pattern: "yyyy-mm-dd hh:mm:ss"}}
+	var date = column{numberFormat: &sheets.NumberFormat{Type: "DATE", Pattern: "yyyy-mm-dd"}}
+
+	// Dates and date-times are written as date values of UTC.
+	var cell = dateTime.cell("2024-01-02T18:00:00-06:00")
+	require.Equal(t, 45294.0, *cell.UserEnteredValue.NumberValue)
+	require.Equal(t, dateTime.numberFormat, cell.UserEnteredFormat.NumberFormat)
+
+	cell = date.cell("1970-01-01")
+	require.Equal(t, 25569.0, *cell.UserEnteredValue.NumberValue)
+
+	// Values which aren't dates are written as-is.
+	cell = date.cell("not a date")
+	require.Equal(t, "not a date", *cell.UserEnteredValue.StringValue)
+	require.Equal(t, date.numberFormat, cell.UserEnteredFormat.NumberFormat)
+
+	// Columns which aren't formatted have no format.
+	cell = column{}.cell(int64(42))
+	require.Equal(t, 42.0, *cell.UserEnteredValue.NumberValue)
+	require.Nil(t, cell.UserEnteredFormat)
+
+	require.Equal(t, 45294.5, dateSerial(time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)))
+}
@@ -21,3 +21,45 @@ func TestSpec(t *testing.T) {
 	require.NoError(t, err)
 	cupaloy.SnapshotT(t, string(formatted))
 }
+
+func TestResource(t *testing.T) {
+	var cfg = config{SpreadsheetURL: "https://docs.google.com/spreadsheets/d/endpoint/edit"}
+
+	var res = resource{Sheet: "orders"}
+	require.NoError(t, res.Validate())
+	require.Equal(t, "endpoint", res.spreadsheetID(cfg))
+	require.Equal(t, []string{"orders"}, res.path(cfg))
+
+	// Sheets of other spreadsheets are qualified by their spreadsheet ID.
+	res.SpreadsheetURL = "https://docs.google.com/spreadsheets/d/other/edit#gid=0"
+	require.NoError(t, res.Validate())
+	require.Equal(t, "other", res.spreadsheetID(cfg))
+	require.Equal(t, []string{"other", "orders"}, res.path(cfg))
+
+	// A sheet of the endpoint's spreadsheet has the same path whether or not it's configured.
+	res.SpreadsheetURL = cfg.SpreadsheetURL
+	require.Equal(t, []string{"orders"}, res.path(cfg))
+
+	var ids, groups = groupBySpreadsheet(cfg, []resource{
+		{Sheet: "a", SpreadsheetURL: "https://docs.google.com/spreadsheets/d/other/edit"},
+		{Sheet: "b"},
+		{Sheet: "c", SpreadsheetURL: "https://docs.google.com/spreadsheets/d/other/edit"},
+	})
+	require.Equal(t, []string{"other", "endpoint"}, ids)
+	require.Equal(t, map[string][]int{"other": {0, 2}, "endpoint": {1}}, groups)
+
+	for _, tt := range []struct {
+		res  resource
+		want string
+	}{
+		{resource{}, "missing required sheet name"},
+		{resource{Sheet: "orders", SpreadsheetURL: "https://example.com"}, "invalid Google Sheets URL"},
+		{resource{Sheet: "orders", ColumnOrder: []string{"a", "b", "a"}}, `column order field "a" is repeated`},
+		{resource{Sheet: "orders", ColumnNames: map[string]string{"a": ""}}, `column name of field "a" must not be empty`},
+		{resource{Sheet: "orders", FrozenColumns: -1}, "frozenColumns must not be negative"},
+		{resource{Sheet: "orders", DeltaUpdates: true, MaxRows: -1}, "maxRows must not be negative"},
+		{resource{Sheet: "orders", MaxRows: 100}, "maxRows can only be set for delta updates"},
+	} {
+		require.ErrorContains(t, tt.res.Validate(), tt.want)
+	}
+}
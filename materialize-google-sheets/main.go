@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -73,13 +74,37 @@ func (c config) buildService(ctx context.Context) (*sheets.Service, error) {
 }
 
 type resource struct {
-	Sheet string `json:"sheet" jsonschema:"title=Sheet Name" jsonschema_extras:"x-collection-name=true"`
+	Sheet          string            `json:"sheet" jsonschema:"title=Sheet Name" jsonschema_extras:"x-collection-name=true"`
+	SpreadsheetURL string            `json:"spreadsheetUrl,omitempty" jsonschema:"title=Spreadsheet URL"`
+	ColumnOrder    []string          `json:"columnOrder,omitempty" jsonschema:"title=Column Order"`
+	ColumnNames    map[string]string `json:"columnNames,omitempty" jsonschema:"title=Column Names"`
+	FormatColumns  bool              `json:"formatColumns,omitempty" jsonschema:"title=Format Columns"`
+	BoldHeader     bool              `json:"boldHeader,omitempty" jsonschema:"title=Bold Header"`
+	FrozenColumns  int               `json:"frozenColumns,omitempty" jsonschema:"title=Frozen Columns"`
+	DeltaUpdates   bool              `json:"deltaUpdates,omitempty" jsonschema:"default=false,title=Delta Updates"`
+	MaxRows        int               `json:"maxRows,omitempty" jsonschema:"title=Maximum Rows"`
 }
 
 func (resource) GetFieldDocString(fieldName string) string {
 	switch fieldName {
 	case "Sheet":
 		return "Name of the spreadsheet sheet to materialize into."
+	case "SpreadsheetURL":
+		return "URL of the spreadsheet to materialize this sheet into. Defaults to the spreadsheet of the endpoint configuration."
+	case "ColumnOrder":
+		return "Fields whose columns come first in the sheet, in this order. Columns of other fields follow them."
+	case "ColumnNames":
+		return "Header names of the columns of fields, which default to the names of the fields."
+	case "FormatColumns":
+		return "Format columns by the types of their fields. Dates and date-times are written as date values in UTC, and integers are displayed without scientific notation."
+	case "BoldHeader":
+		return "Display the header row in bold."
+	case "FrozenColumns":
+		return "Number of leftmost columns to freeze, which remain visible when scrolling horizontally."
+	case "DeltaUpdates":
+		return "Append each stored document to the sheet as a new row, instead of updating the row of its key."
+	case "MaxRows":
+		return "Maximum number of rows of a delta updates sheet. The oldest rows are deleted once it's reached. Unlimited if unset."
 	default:
 		return ""
 	}
@@ -89,9 +114,85 @@ func (r resource) Validate() error {
 	if r.Sheet == "" {
 		return fmt.Errorf("missing required sheet name")
 	}
+	if r.SpreadsheetURL != "" {
+		if _, err := parseSheetsID(r.SpreadsheetURL); err != nil {
+			return err
+		}
+	}
+	for i, f := range r.ColumnOrder {
+		if slices.Contains(r.ColumnOrder[:i], f) {
+			return fmt.Errorf("column order field %q is repeated", f)
+		}
+	}
+	for f, name := range r.ColumnNames {
+		if name == "" {
+			return fmt.Errorf("column name of field %q must not be empty", f)
+		}
+	}
+	if r.FrozenColumns < 0 {
+		return fmt.Errorf("frozenColumns must not be negative")
+	}
+	if r.MaxRows < 0 {
+		return fmt.Errorf("maxRows must not be negative")
+	} else if r.MaxRows != 0 && !r.DeltaUpdates {
+		return fmt.Errorf("maxRows can only be set for delta updates")
+	}
 	return nil
 }
 
+// spreadsheetID returns the ID of the spreadsheet of the resource's sheet.
+func (r resource) spreadsheetID(cfg config) string {
+	if r.SpreadsheetURL == "" {
+		return cfg.spreadsheetID()
+	}
+
+	var id, err = parseSheetsID(r.SpreadsheetURL)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// path returns the resource path of the resource's sheet. Sheets of the endpoint's spreadsheet
+// have paths of just their name, and sheets of other spreadsheets are qualified by their
+// spreadsheet ID.
+func (r resource) path(cfg config) []string {
+	if id := r.spreadsheetID(cfg); id != cfg.spreadsheetID() {
+		return []string{id, r.Sheet}
+	}
+	return []string{r.Sheet}
+}
+
+// resolveResources parses the resource configs of bindings.
+func resolveResources(bindings []*pf.MaterializationSpec_Binding) ([]resource, error) {
+	var out []resource
+	for _, binding := range bindings {
+		var res resource
+		if err := pf.UnmarshalStrict(binding.ResourceConfigJson, &res); err != nil {
+			return nil, fmt.Errorf("parsing resource config: %w", err)
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
+
+// groupBySpreadsheet returns the indices of resources grouped by the IDs of their spreadsheets, and
+// the spreadsheet IDs in order of their first resource.
+func groupBySpreadsheet(cfg config, resources []resource) ([]string, map[string][]int) {
+	var ids []string
+	var groups = make(map[string][]int)
+
+	for idx, res := range resources {
+		var id = res.spreadsheetID(cfg)
+		if _, ok := groups[id]; !ok {
+			ids = append(ids, id)
+		}
+		groups[id] = append(groups[id], idx)
+	}
+
+	return ids, groups
+}
+
 type driverCheckpoint struct {
 	Round int64
 }
@@ -133,26 +234,42 @@ func (driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Respo
 	var svc, err = cfg.buildService(ctx)
 	if err != nil {
 		return nil, err
-	} else if _, err = loadSheetIDMapping(svc, cfg.spreadsheetID()); err != nil {
-		var googleErr *googleapi.Error
-		if errors.As(err, &googleErr) {
-			if googleErr.Code == http.StatusNotFound {
-				return nil, cerrors.NewUserError(err, "configured sheet doesn't exist")
-			} else if googleErr.Code == http.StatusForbidden {
-				return nil, cerrors.NewUserError(err, "not authorized to view configured sheet")
-			}
-		}
-
-		return nil, fmt.Errorf("verifying credentials: %w", err)
 	}
 
-	var out []*pm.Response_Validated_Binding
+	var resources []resource
 	for _, binding := range req.Bindings {
-
 		var res resource
 		if err := pf.UnmarshalStrict(binding.ResourceConfigJson, &res); err != nil {
 			return nil, fmt.Errorf("parsing resource config: %w", err)
+		} else if err := validateColumns(res, &binding.Collection); err != nil {
+			return nil, err
+		}
+		resources = append(resources, res)
+	}
+
+	// Verify access to the endpoint's spreadsheet, and those of all bindings.
+	var spreadsheetIDs, _ = groupBySpreadsheet(cfg, resources)
+	if !slices.Contains(spreadsheetIDs, cfg.spreadsheetID()) {
+		spreadsheetIDs = append([]string{cfg.spreadsheetID()}, spreadsheetIDs...)
+	}
+	for _, id := range spreadsheetIDs {
+		if _, err = loadSheetIDMapping(svc, id); err != nil {
+			var googleErr *googleapi.Error
+			if errors.As(err, &googleErr) {
+				if googleErr.Code == http.StatusNotFound {
+					return nil, cerrors.NewUserError(err, fmt.Sprintf("configured spreadsheet %q doesn't exist", id))
+				} else if googleErr.Code == http.StatusForbidden {
+					return nil, cerrors.NewUserError(err, fmt.Sprintf("not authorized to view configured spreadsheet %q", id))
+				}
+			}
+
+			return nil, fmt.Errorf("verifying credentials: %w", err)
 		}
+	}
+
+	var out []*pm.Response_Validated_Binding
+	for bindingIdx, binding := range req.Bindings {
+		var res = resources[bindingIdx]
 
 		var constraints = make(map[string]*pm.Response_Validated_Constraint)
 		for _, projection := range binding.Collection.Projections {
@@ -179,8 +296,8 @@ func (driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Respo
 
 		out = append(out, &pm.Response_Validated_Binding{
 			Constraints:  constraints,
-			DeltaUpdates: false,
-			ResourcePath: []string{res.Sheet},
+			DeltaUpdates: res.DeltaUpdates,
+			ResourcePath: res.path(cfg),
 		})
 	}
 
@@ -197,40 +314,49 @@ func (driver) Apply(ctx context.Context, req *pm.Request_Apply) (*pm.Response_Ap
 	if err != nil {
 		return nil, err
 	}
-	sheetIDs, err := loadSheetIDMapping(svc, cfg.spreadsheetID())
+	resources, err := resolveResources(req.Materialization.Bindings)
 	if err != nil {
 		return nil, err
 	}
 
-	var actions []*sheets.Request
 	var description string
 	var rand = rand.New(rand.NewSource(time.Now().UnixMicro()))
+	var spreadsheetIDs, groups = groupBySpreadsheet(cfg, resources)
 
-	for _, binding := range req.Materialization.Bindings {
-		var res resource
-		if err := pf.UnmarshalStrict(binding.ResourceConfigJson, &res); err != nil {
-			return nil, fmt.Errorf("parsing resource config: %w", err)
+	for _, spreadsheetID := range spreadsheetIDs {
+		sheetIDs, err := loadSheetIDMapping(svc, spreadsheetID)
+		if err != nil {
+			return nil, err
 		}
-		var _, exists = sheetIDs[res.Sheet]
-
-		if !exists {
-			description += fmt.Sprintf("Created sheet %q.\n", res.Sheet)
-
-			// Create a new sheet.
-			var sheetID = int64(rand.Int31())
-			actions = append(actions, &sheets.Request{
-				AddSheet: &sheets.AddSheetRequest{
-					Properties: &sheets.SheetProperties{
-						Title:   res.Sheet,
-						SheetId: sheetID,
+
+		var actions []*sheets.Request
+		for _, idx := range groups[spreadsheetID] {
+			var res = resources[idx]
+			var _, exists = sheetIDs[res.Sheet]
+
+			if !exists {
+				if spreadsheetID == cfg.spreadsheetID() {
+					description += fmt.Sprintf("Created sheet %q.\n", res.Sheet)
+				} else {
+					description += fmt.Sprintf("Created sheet %q of spreadsheet %q.\n", res.Sheet, spreadsheetID)
+				}
+
+				// Create a new sheet.
+				var sheetID = int64(rand.Int31())
+				actions = append(actions, &sheets.Request{
+					AddSheet: &sheets.AddSheetRequest{
+						Properties: &sheets.SheetProperties{
+							Title:   res.Sheet,
+							SheetId: sheetID,
+						},
 					},
-				},
-			})
+				})
+			}
 		}
-	}
 
-	if err = batchRequestWithRetry(ctx, svc, cfg.spreadsheetID(), actions); err != nil {
-		return nil, fmt.Errorf("while updated sheets: %w", err)
+		if err = batchRequestWithRetry(ctx, svc, spreadsheetID, actions); err != nil {
+			return nil, fmt.Errorf("while updated sheets: %w", err)
+		}
 	}
 
 	return &pm.Response_Applied{ActionDescription: description}, nil
@@ -252,21 +378,37 @@ func (driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Transa
 		return nil, nil, err
 	}
 
-	states, err := loadSheetStates(
-		open.Materialization.Bindings,
-		svc,
-		cfg.spreadsheetID(),
-	)
+	resources, err := resolveResources(open.Materialization.Bindings)
 	if err != nil {
-		return nil, nil, fmt.Errorf("recovering sheet states: %w", err)
+		return nil, nil, err
 	}
 
-	if err := writeSheetSentinels(ctx, svc, cfg.spreadsheetID(), states); err != nil {
-		return nil, nil, fmt.Errorf("writing sheet sentinels: %w", err)
+	// Recover the states of the sheets of each spreadsheet.
+	var states = make([]SheetState, len(resources))
+	var spreadsheetIDs, groups = groupBySpreadsheet(cfg, resources)
+	for _, spreadsheetID := range spreadsheetIDs {
+		var sheetNames []string
+		for _, idx := range groups[spreadsheetID] {
+			sheetNames = append(sheetNames, resources[idx].Sheet)
+		}
+
+		loaded, err := loadSheetStates(sheetNames, svc, spreadsheetID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("recovering sheet states: %w", err)
+		}
+		if err := writeSheetSentinels(ctx, svc, spreadsheetID, loaded); err != nil {
+			return nil, nil, fmt.Errorf("writing sheet sentinels: %w", err)
+		}
+
+		for i, idx := range groups[spreadsheetID] {
+			states[idx] = loaded[i]
+		}
 	}
 
 	bindings, err := buildTransactorBindings(
+		cfg,
 		open.Materialization.Bindings,
+		resources,
 		checkpoint.Round,
 		states,
 	)
@@ -274,15 +416,18 @@ func (driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Transa
 		return nil, nil, err
 	}
 
-	if err := writeSheetHeaders(ctx, svc, cfg.spreadsheetID(), bindings); err != nil {
+	if err := trimDeltaSheets(ctx, svc, bindings, states); err != nil {
+		return nil, nil, fmt.Errorf("recovering delta updates sheets: %w", err)
+	}
+
+	if err := writeSheetHeaders(ctx, svc, bindings); err != nil {
 		return nil, nil, fmt.Errorf("writing sheet headers: %w", err)
 	}
 
 	var transactor = &transactor{
-		bindings:      bindings,
-		client:        svc,
-		round:         checkpoint.Round,
-		spreadsheetId: cfg.spreadsheetID(),
+		bindings: bindings,
+		client:   svc,
+		round:    checkpoint.Round,
 	}
 	return transactor, &pm.Response_Opened{}, nil
 }
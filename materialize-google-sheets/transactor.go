@@ -22,6 +22,10 @@ const (
 
 	// Accommodate API rate limits.
 	transactionDelay = 2 * time.Second
+
+	// Number of cells of rows appended to a delta updates sheet which are buffered before they're
+	// written, to bound the size of requests.
+	deltaBatchCells = 100000
 )
 
 func checkCellCount(rows int, cellsPerRow int) error {
@@ -43,8 +47,6 @@ type transactor struct {
 	// Round is a monotonic counter of the current transaction number,
 	// which is persisted into / recovered from the driver checkpoint.
 	round int64
-	// Spreadsheet to which we're materializing.
-	spreadsheetId string
 }
 
 type transactorRow struct {
@@ -55,12 +57,129 @@ type transactorRow struct {
 
 type transactorBinding struct {
 	rows []transactorRow
+	// Number of rows of a delta updates sheet.
+	deltaRows int
 	// Fields materialized by this binding.
 	Fields pf.FieldSelection
+	// User-facing columns of the fields, in the order of the sheet.
+	Columns []column
+	// Spreadsheet of the user-facing sheet for this binding.
+	SpreadsheetID string
 	// Sheet ID of the user-facing sheet for this binding.
 	UserSheetId int64
 	// User-facing sheet name for this binding.
 	UserSheetName string
+	// Whether the header row is bold.
+	BoldHeader bool
+	// Number of user-facing columns which are frozen.
+	FrozenColumns int
+	// Whether stored documents are appended as new rows.
+	DeltaUpdates bool
+	// Maximum number of rows of a delta updates sheet, or zero if unlimited.
+	MaxRows int
+}
+
+// deleteRows returns a request which deletes `count` rows of the binding's sheet, starting from
+// row index `start`.
+func (b *transactorBinding) deleteRows(start, count int) *sheets.Request {
+	return &sheets.Request{
+		DeleteDimension: &sheets.DeleteDimensionRequest{
+			Range: &sheets.DimensionRange{
+				SheetId:    b.UserSheetId,
+				Dimension:  "ROWS",
+				StartIndex: int64(start),
+				EndIndex:   int64(start + count),
+			},
+		},
+	}
+}
+
+// trimRows returns a request which deletes the oldest rows of a delta updates sheet in excess of its
+// maximum rows, or nil if there are none.
+func (b *transactorBinding) trimRows() *sheets.Request {
+	if b.MaxRows == 0 || b.deltaRows <= b.MaxRows {
+		return nil
+	}
+
+	// Rows are 1-indexed due to the header.
+	var req = b.deleteRows(1, b.deltaRows-b.MaxRows)
+	b.deltaRows = b.MaxRows
+	return req
+}
+
+// checkDeltaCellCount verifies that a delta updates sheet doesn't exceed the cells limit once
+// `pending` more rows are appended to it. Rows in excess of its maximum rows are trimmed, so a sheet
+// with a maximum never has more rows than that.
+func (b *transactorBinding) checkDeltaCellCount(pending int) error {
+	var rows = b.deltaRows + pending
+	if b.MaxRows != 0 {
+		rows = min(rows, b.MaxRows)
+	}
+	if err := checkCellCount(rows, b.columnCount()); err != nil {
+		return fmt.Errorf("%w Set maxRows of sheet %q to delete its oldest rows.", err, b.UserSheetName)
+	}
+	return nil
+}
+
+// appendRows returns requests which append rows to a delta updates sheet after its existing rows,
+// and then trim its oldest rows.
+func (b *transactorBinding) appendRows(rows []*sheets.RowData) []*sheets.Request {
+	// Rows are 1-indexed due to the header.
+	var start = int64(1 + b.deltaRows)
+	var end = start + int64(len(rows))
+	b.deltaRows += len(rows)
+
+	var out = []*sheets.Request{
+		{
+			InsertDimension: &sheets.InsertDimensionRequest{
+				Range: &sheets.DimensionRange{
+					SheetId:    b.UserSheetId,
+					Dimension:  "ROWS",
+					StartIndex: start,
+					EndIndex:   end,
+				},
+			},
+		},
+		{
+			UpdateCells: &sheets.UpdateCellsRequest{
+				Range: &sheets.GridRange{
+					SheetId:       b.UserSheetId,
+					StartRowIndex: start,
+					EndRowIndex:   end,
+				},
+				Rows:   rows,
+				Fields: b.cellFields(),
+			},
+		},
+	}
+	if req := b.trimRows(); req != nil {
+		out = append(out, req)
+	}
+	return out
+}
+
+// cells returns the cells of the key and value fields of a document, in the order of the binding's
+// columns.
+func (b transactorBinding) cells(key, values tuple.Tuple) []*sheets.CellData {
+	var out = make([]*sheets.CellData, 0, len(b.Columns))
+	for _, col := range b.Columns {
+		if col.index < len(key) {
+			out = append(out, col.cell(key[col.index]))
+		} else {
+			out = append(out, col.cell(values[col.index-len(key)]))
+		}
+	}
+	return out
+}
+
+// cellFields is the field mask of the cells of rows written to the binding's sheet.
+func (b transactorBinding) cellFields() string {
+	for _, col := range b.Columns {
+		if col.numberFormat != nil {
+			return "userEnteredValue,userEnteredFormat.numberFormat"
+		}
+	}
+	return "userEnteredValue"
 }
 
 func (b transactorBinding) columnCount() int {
@@ -107,9 +226,42 @@ func (d *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 		cells []*sheets.CellData
 	}
 	var stores = make([][]storedRow, len(d.bindings))
+	// Rows to append to delta updates sheets, which are written in batches.
+	var appends = make([][]*sheets.RowData, len(d.bindings))
 
 	// Gather all of the stored rows on a per-binding basis.
 	for it.Next() {
+		var binding = &d.bindings[it.Binding]
+
+		if binding.DeltaUpdates {
+			// Verify that the sheet doesn't grow excessively large, since all of its rows are
+			// retained unless it has a maximum.
+			if err := binding.checkDeltaCellCount(len(appends[it.Binding])); err != nil {
+				return nil, err
+			}
+
+			// The row's internal state is just the round which appended it.
+			var col0, err = json.Marshal(RowState{NextRound: d.round})
+			if err != nil {
+				panic(err)
+			}
+			var cells = append([]*sheets.CellData{valueToCell(col0)}, binding.cells(it.Key, it.Values)...)
+			appends[it.Binding] = append(appends[it.Binding], &sheets.RowData{Values: cells})
+
+			if len(appends[it.Binding])*binding.columnCount() >= deltaBatchCells {
+				if err := batchRequestWithRetry(
+					it.Context(),
+					d.client,
+					binding.SpreadsheetID,
+					binding.appendRows(appends[it.Binding]),
+				); err != nil {
+					return nil, fmt.Errorf("appending rows to sheet %q: %w", binding.UserSheetName, err)
+				}
+				appends[it.Binding] = nil
+			}
+			continue
+		}
+
 		// Verify that we don't read an excessive amount of data from the store iterator, which
 		// would indicate we are reading from a high cardinality collection that will not fit into a
 		// reasonable amount of connector memory.
@@ -119,13 +271,7 @@ func (d *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 
 		// Marshal key and value fields into cells of the row.
 		// cells[0] is a placeholder for internal state that's written later.
-		var cells = make([]*sheets.CellData, 1, 1+len(it.Key)+len(it.Values))
-		for _, e := range it.Key {
-			cells = append(cells, valueToCell(e))
-		}
-		for _, e := range it.Values {
-			cells = append(cells, valueToCell(e))
-		}
+		var cells = append([]*sheets.CellData{nil}, binding.cells(it.Key, it.Values)...)
 
 		stores[it.Binding] = append(stores[it.Binding], storedRow{
 			RowState: RowState{
@@ -137,8 +283,15 @@ func (d *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 		})
 	}
 
-	// batchRequests collects all sub-requests made to each sheet.
-	var batchRequests []*sheets.Request
+	// batchRequests collects all sub-requests made to each sheet, by spreadsheet.
+	var batchRequests = make(map[string][]*sheets.Request)
+
+	for bindInd := range d.bindings {
+		var binding = &d.bindings[bindInd]
+		if len(appends[bindInd]) != 0 {
+			batchRequests[binding.SpreadsheetID] = append(batchRequests[binding.SpreadsheetID], binding.appendRows(appends[bindInd])...)
+		}
+	}
 
 	for bindInd := range d.bindings {
 		var stores = stores[bindInd]
@@ -247,7 +400,7 @@ func (d *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 							EndRowIndex:   rowInd + 1,
 						},
 						Rows:   []*sheets.RowData{{Values: s.cells}},
-						Fields: "userEnteredValue",
+						Fields: d.bindings[bindInd].cellFields(),
 					},
 				})
 			}
@@ -255,14 +408,14 @@ func (d *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 		} // Done with merge of `prev` and `stored` into `next`.
 
 		d.bindings[bindInd].rows = next
-		batchRequests = append(batchRequests, addRows...)
-		batchRequests = append(batchRequests, updateCells...)
+		var spreadsheetID = d.bindings[bindInd].SpreadsheetID
+		batchRequests[spreadsheetID] = append(batchRequests[spreadsheetID], addRows...)
+		batchRequests[spreadsheetID] = append(batchRequests[spreadsheetID], updateCells...)
 	}
 
-	if err := batchRequestWithRetry(
+	if err := batchRequestsWithRetry(
 		it.Context(),
 		d.client,
-		d.spreadsheetId,
 		batchRequests,
 	); err != nil {
 		return nil, err
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestAppendRows(t *testing.T) {
+	var b = &transactorBinding{UserSheetId: 7, deltaRows: 3, DeltaUpdates: true, MaxRows: 5}
+	var rows = []*sheets.RowData{{}, {}, {}, {}}
+
+	// Rows are inserted after the existing rows and the header, and then the oldest rows in excess
+	// of the maximum are deleted.
+	var reqs = b.appendRows(rows)
+	require.Len(t, reqs, 3)
+	require.Equal(t, &sheets.DimensionRange{SheetId: 7, Dimension: "ROWS", StartIndex: 4, EndIndex: 8}, reqs[0].InsertDimension.Range)
+	require.Equal(t, &sheets.GridRange{SheetId: 7, StartRowIndex: 4, EndRowIndex: 8}, reqs[1].UpdateCells.Range)
+	require.Equal(t, rows, reqs[1].UpdateCells.Rows)
+	require.Equal(t, &sheets.DimensionRange{SheetId: 7, Dimension: "ROWS", StartIndex: 1, EndIndex: 3}, reqs[2].DeleteDimension.Range)
+	require.Equal(t, 5, b.deltaRows)
+
+	// Sheets without a maximum are never trimmed.
+	b.MaxRows = 0
+	reqs = b.appendRows(rows)
+	require.Len(t, reqs, 2)
+	require.Equal(t, 9, b.deltaRows)
+	require.Nil(t, b.trimRows())
+}
+
+func TestCheckDeltaCellCount(t *testing.T) {
+	var b = &transactorBinding{UserSheetName: "deltas", Fields: pf.FieldSelection{Keys: []string{"key"}, Values: make([]string, 8)}, deltaRows: cellsLimit/10 - 5, DeltaUpdates: true}
+	require.NoError(t, b.checkDeltaCellCount(5))
+	require.ErrorContains(t, b.checkDeltaCellCount(6), `Set maxRows of sheet "deltas"`)
+
+	// Sheets with a maximum number of rows are trimmed to it.
+	b.MaxRows = cellsLimit / 10
+	require.NoError(t, b.checkDeltaCellCount(100))
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"net/http"
 	"regexp"
+	"slices"
 	"time"
 
 	pf "github.com/estuary/flow/go/protocols/flow"
@@ -18,7 +19,9 @@ import (
 // buildTransactorBindings fetches documents of each binding for the committed `round`,
 // and returns bindings ready for use within the transactor.
 func buildTransactorBindings(
+	cfg config,
 	bindings []*pf.MaterializationSpec_Binding,
+	resources []resource,
 	loadRound int64,
 	states []SheetState,
 ) ([]transactorBinding, error) {
@@ -26,6 +29,28 @@ func buildTransactorBindings(
 	var out []transactorBinding
 	for bindInd, binding := range bindings {
 		var state = states[bindInd]
+		var res = resources[bindInd]
+
+		var tb = transactorBinding{
+			Fields:        binding.FieldSelection,
+			Columns:       buildColumns(res, binding),
+			SpreadsheetID: res.spreadsheetID(cfg),
+			UserSheetId:   state.SheetID,
+			UserSheetName: state.SheetName,
+			BoldHeader:    res.BoldHeader,
+			FrozenColumns: res.FrozenColumns,
+			DeltaUpdates:  res.DeltaUpdates,
+			MaxRows:       res.MaxRows,
+		}
+
+		if res.DeltaUpdates {
+			var err error
+			if tb.deltaRows, err = committedDeltaRows(state.Rows, loadRound); err != nil {
+				return nil, fmt.Errorf("sheet %q with loadRound=%d: %w", state.SheetName, loadRound, err)
+			}
+			out = append(out, tb)
+			continue
+		}
 
 		var rows []transactorRow
 		for rowInd, row := range state.Rows {
@@ -70,41 +95,80 @@ func buildTransactorBindings(
 			}
 		}
 
-		out = append(out, transactorBinding{
-			rows:          rows,
-			Fields:        binding.FieldSelection,
-			UserSheetId:   state.SheetID,
-			UserSheetName: state.SheetName,
-		})
+		tb.rows = rows
+		out = append(out, tb)
 	}
 
 	return out, nil
 }
 
-func writeSheetHeaders(ctx context.Context, client *sheets.Service, spreadsheetID string, bindings []transactorBinding) error {
-	var actions []*sheets.Request
+// committedDeltaRows returns the number of appended rows of a delta updates sheet which were
+// committed as-of `loadRound`. Rows are appended in order of their rounds, so committed rows always
+// precede rows of a rolled-back round.
+func committedDeltaRows(rows []RowState, loadRound int64) (int, error) {
+	var committed int
+	for rowInd, row := range rows {
+		switch {
+		case rowInd != 0 && row.NextRound < rows[rowInd-1].NextRound:
+			return 0, fmt.Errorf("row %d has NextRound=%d which is less than the prior row's NextRound=%d", rowInd, row.NextRound, rows[rowInd-1].NextRound)
+		case row.NextRound <= loadRound:
+			committed++
+		}
+	}
+	return committed, nil
+}
+
+// trimDeltaSheets deletes the rows of delta updates sheets which were appended by a rolled-back
+// round, and the oldest rows of sheets with more than their maximum rows.
+func trimDeltaSheets(ctx context.Context, client *sheets.Service, bindings []transactorBinding, states []SheetState) error {
+	var actions = make(map[string][]*sheets.Request)
+	for bindInd := range bindings {
+		var binding = &bindings[bindInd]
+		if !binding.DeltaUpdates {
+			continue
+		}
+
+		if rolledBack := len(states[bindInd].Rows) - binding.deltaRows; rolledBack != 0 {
+			actions[binding.SpreadsheetID] = append(actions[binding.SpreadsheetID], binding.deleteRows(1+binding.deltaRows, rolledBack))
+		}
+		if req := binding.trimRows(); req != nil {
+			actions[binding.SpreadsheetID] = append(actions[binding.SpreadsheetID], req)
+		}
+	}
+	return batchRequestsWithRetry(ctx, client, actions)
+}
+
+func writeSheetHeaders(ctx context.Context, client *sheets.Service, bindings []transactorBinding) error {
+	var actions = make(map[string][]*sheets.Request)
 	for _, binding := range bindings {
+		var bold = binding.BoldHeader
 		var headers = []*sheets.CellData{{}}
-		for i := range binding.Fields.Keys {
+		for _, col := range binding.Columns {
+			var header = col.header
 			headers = append(headers, &sheets.CellData{
-				UserEnteredValue: &sheets.ExtendedValue{StringValue: &binding.Fields.Keys[i]},
+				UserEnteredValue:  &sheets.ExtendedValue{StringValue: &header},
+				UserEnteredFormat: &sheets.CellFormat{TextFormat: &sheets.TextFormat{Bold: bold}},
 			})
 		}
-		for i := range binding.Fields.Values {
-			headers = append(headers, &sheets.CellData{
-				UserEnteredValue: &sheets.ExtendedValue{StringValue: &binding.Fields.Values[i]},
-			})
+
+		// The hidden first column is also frozen if any columns are.
+		var frozenColumns int64
+		if binding.FrozenColumns != 0 {
+			frozenColumns = int64(min(binding.FrozenColumns, len(binding.Columns))) + 1
 		}
-		actions = append(actions,
+
+		actions[binding.SpreadsheetID] = append(actions[binding.SpreadsheetID],
 			// Resize horizontally to the correct number of fields, and freeze the header row
+			// and any frozen columns
 			&sheets.Request{
 				UpdateSheetProperties: &sheets.UpdateSheetPropertiesRequest{
-					Fields: "gridProperties(columnCount,frozenRowCount)",
+					Fields: "gridProperties(columnCount,frozenRowCount,frozenColumnCount)",
 					Properties: &sheets.SheetProperties{
 						SheetId: binding.UserSheetId,
 						GridProperties: &sheets.GridProperties{
-							ColumnCount:    int64(len(headers)),
-							FrozenRowCount: 1,
+							ColumnCount:       int64(len(headers)),
+							FrozenRowCount:    1,
+							FrozenColumnCount: frozenColumns,
 						},
 					},
 				},
@@ -118,7 +182,7 @@ func writeSheetHeaders(ctx context.Context, client *sheets.Service, spreadsheetI
 						EndRowIndex:   1,
 					},
 					Rows:   []*sheets.RowData{{Values: headers}},
-					Fields: "userEnteredValue",
+					Fields: "userEnteredValue,userEnteredFormat.textFormat.bold",
 				},
 			},
 			// Hide the first column, which contains Flow internal data
@@ -138,7 +202,7 @@ func writeSheetHeaders(ctx context.Context, client *sheets.Service, spreadsheetI
 			})
 
 	}
-	return batchRequestWithRetry(ctx, client, spreadsheetID, actions)
+	return batchRequestsWithRetry(ctx, client, actions)
 }
 
 // SheetState is the recovered state of a materialized sheet.
@@ -154,8 +218,11 @@ type SheetState struct {
 //
 // On recovery a recovered document is selected depending on whether
 // `NextRound` is known to have committed, or to have been rolled-back.
+//
+// Rows of delta updates sheets have only a `NextRound`, which is the
+// round that appended them.
 type RowState struct {
-	PackedKey []byte          `json:"k"`
+	PackedKey []byte          `json:"k,omitempty"`
 	PrevRound int64           `json:"pr,omitempty"`
 	PrevDoc   json.RawMessage `json:"pd,omitempty"`
 	NextRound int64           `json:"nr"`
@@ -164,25 +231,24 @@ type RowState struct {
 
 const sentinelValue = `{}`
 
-// loadSheetStates loads the SheetStates of all named `sheetNames`.
+// loadSheetStates loads the SheetStates of all named `sheetNames` of a spreadsheet.
 // each row in a sheet has a zero-indexed cell where RowState is written to,
 // this zero-indexed cell is not visible to users when they look at the sheet
 // but we have it available when using the API
 func loadSheetStates(
-	bindings []*pf.MaterializationSpec_Binding,
+	sheetNames []string,
 	client *sheets.Service,
 	spreadsheetID string,
 ) ([]SheetState, error) {
-	if len(bindings) == 0 {
+	if len(sheetNames) == 0 {
 		// Bail out because an empty Ranges() constraint below will return *all* sheets.
 		return nil, nil
 	}
 
-	var sheetNames, sheetRanges []string
-	for _, binding := range bindings {
-		sheetNames = append(sheetNames, binding.ResourcePath[0])
+	var sheetRanges []string
+	for _, sheetName := range sheetNames {
 		// Fetch from A2 to skip the (empty) header.
-		sheetRanges = append(sheetRanges, fmt.Sprintf("'%s'!A2:A", binding.ResourcePath[0]))
+		sheetRanges = append(sheetRanges, fmt.Sprintf("'%s'!A2:A", sheetName))
 	}
 
 	var resp, err = client.Spreadsheets.
@@ -326,6 +392,27 @@ func batchRequestWithRetry(
 	panic("not reached")
 }
 
+// batchRequestsWithRetry applies the requests of each spreadsheet, keyed by spreadsheet ID, in a
+// batch per spreadsheet.
+func batchRequestsWithRetry(
+	ctx context.Context,
+	client *sheets.Service,
+	requests map[string][]*sheets.Request,
+) error {
+	var spreadsheetIDs []string
+	for id := range requests {
+		spreadsheetIDs = append(spreadsheetIDs, id)
+	}
+	slices.Sort(spreadsheetIDs)
+
+	for _, spreadsheetID := range spreadsheetIDs {
+		if err := batchRequestWithRetry(ctx, client, spreadsheetID, requests[spreadsheetID]); err != nil {
+			return fmt.Errorf("spreadsheet %q: %w", spreadsheetID, err)
+		}
+	}
+	return nil
+}
+
 // Example: https://docs.google.com/spreadsheets/d/1s7S1Abp8kAJEkReV10omef_ETZXKB2vHKPook49HpFk/edit#gid=1649530432
 const sheetsLinkRe = `^https://docs.google.com/spreadsheets/d/([\w\d_\-]+)/`
 
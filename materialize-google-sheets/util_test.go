@@ -14,3 +14,25 @@ func TestSheetsIDParsing(t *testing.T) {
 	_, err = parseSheetsID("https://github.com/estuary/connectors/issues/245")
 	require.Regexp(t, "invalid Google Sheets URL: .*", err)
 }
+
+func TestCommittedDeltaRows(t *testing.T) {
+	var rows = []RowState{{NextRound: 1}, {NextRound: 1}, {NextRound: 3}, {NextRound: 4}, {NextRound: 4}}
+
+	for _, tt := range []struct {
+		loadRound int64
+		want      int
+	}{
+		{0, 0},
+		{1, 2},
+		{3, 3},
+		{4, 5},
+		{5, 5},
+	} {
+		got, err := committedDeltaRows(rows, tt.loadRound)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, got, tt.loadRound)
+	}
+
+	_, err := committedDeltaRows([]RowState{{NextRound: 2}, {NextRound: 1}}, 2)
+	require.ErrorContains(t, err, "row 1 has NextRound=1 which is less than the prior row's NextRound=2")
+}
@@ -0,0 +1,152 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// Special timestamps of a ListOffsets request, which list the earliest and latest offsets of a
+// partition.
+const (
+	earliestOffsetTimestamp = -2
+	latestOffsetTimestamp   = -1
+)
+
+// adminClient issues the admin requests of the connector to the cluster.
+type adminClient struct {
+	client *kgo.Client
+}
+
+func (c config) adminClient() (*adminClient, error) {
+	opts, err := c.clientOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating client: %w", err)
+	}
+
+	return &adminClient{client: client}, nil
+}
+
+func (a *adminClient) Close() {
+	a.client.Close()
+}
+
+// topicPartitions returns the partitions of each topic which exists, of the given topics or of all
+// topics if none are given.
+func (a *adminClient) topicPartitions(ctx context.Context, topics ...string) (map[string][]int32, error) {
+	var req = kmsg.NewPtrMetadataRequest()
+	for _, topic := range topics {
+		var rt = kmsg.NewMetadataRequestTopic()
+		rt.Topic = kmsg.StringPtr(topic)
+		req.Topics = append(req.Topics, rt)
+	}
+
+	resp, err := req.RequestWith(ctx, a.client)
+	if err != nil {
+		return nil, err
+	}
+
+	var out = make(map[string][]int32)
+	for _, t := range resp.Topics {
+		if t.Topic == nil {
+			continue
+		} else if err := kerr.ErrorForCode(t.ErrorCode); err == kerr.UnknownTopicOrPartition {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("topic %q: %w", *t.Topic, err)
+		}
+
+		for _, p := range t.Partitions {
+			out[*t.Topic] = append(out[*t.Topic], p.Partition)
+		}
+	}
+
+	return out, nil
+}
+
+// createTopic creates a topic, using the defaults of the cluster for the number of partitions or
+// replication factor if they are -1.
+func (a *adminClient) createTopic(ctx context.Context, topic string, partitions int32, replicationFactor int16, configs map[string]*string) error {
+	var rt = kmsg.NewCreateTopicsRequestTopic()
+	rt.Topic = topic
+	rt.NumPartitions = partitions
+	rt.ReplicationFactor = replicationFactor
+	for name, value := range configs {
+		var c = kmsg.NewCreateTopicsRequestTopicConfig()
+		c.Name, c.Value = name, value
+		rt.Configs = append(rt.Configs, c)
+	}
+
+	var req = kmsg.NewPtrCreateTopicsRequest()
+	req.Topics = append(req.Topics, rt)
+
+	resp, err := req.RequestWith(ctx, a.client)
+	if err != nil {
+		return err
+	} else if len(resp.Topics) != 1 {
+		return fmt.Errorf("expected a response for 1 topic but got %d", len(resp.Topics))
+	}
+	return kerr.ErrorForCode(resp.Topics[0].ErrorCode)
+}
+
+// deleteTopic deletes a topic.
+func (a *adminClient) deleteTopic(ctx context.Context, topic string) error {
+	var rt = kmsg.NewDeleteTopicsRequestTopic()
+	rt.Topic = kmsg.StringPtr(topic)
+
+	var req = kmsg.NewPtrDeleteTopicsRequest()
+	req.TopicNames = []string{topic}
+	req.Topics = append(req.Topics, rt)
+
+	resp, err := req.RequestWith(ctx, a.client)
+	if err != nil {
+		return err
+	} else if len(resp.Topics) != 1 {
+		return fmt.Errorf("expected a response for 1 topic but got %d", len(resp.Topics))
+	}
+	return kerr.ErrorForCode(resp.Topics[0].ErrorCode)
+}
+
+// listOffsets lists the offsets of the partitions of a topic at the timestamp, which is typically
+// earliestOffsetTimestamp or latestOffsetTimestamp. Offsets are read committed, so the latest
+// offset of a partition is its last stable offset.
+func (a *adminClient) listOffsets(ctx context.Context, topic string, partitions []int32, timestamp int64) (map[int32]int64, error) {
+	var rt = kmsg.NewListOffsetsRequestTopic()
+	rt.Topic = topic
+	for _, partition := range partitions {
+		var rp = kmsg.NewListOffsetsRequestTopicPartition()
+		rp.Partition = partition
+		rp.Timestamp = timestamp
+		rt.Partitions = append(rt.Partitions, rp)
+	}
+
+	var req = kmsg.NewPtrListOffsetsRequest()
+	req.IsolationLevel = 1 // Read committed.
+	req.Topics = append(req.Topics, rt)
+
+	// The client splits the request by the leaders of the partitions, and merges their responses.
+	resp, err := req.RequestWith(ctx, a.client)
+	if err != nil {
+		return nil, err
+	}
+
+	var out = make(map[int32]int64)
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			if err := kerr.ErrorForCode(p.ErrorCode); err != nil {
+				return nil, fmt.Errorf("partition %d: %w", p.Partition, err)
+			}
+			out[p.Partition] = p.Offset
+		}
+	}
+
+	return out, nil
+}
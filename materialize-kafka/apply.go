@@ -0,0 +1,109 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+type kafkaApplier struct {
+	admin *adminClient
+	cfg   config
+}
+
+var _ boilerplate.Applier = (*kafkaApplier)(nil)
+
+// CreateMetaTables creates the compacted topic which checkpoints are committed to, if it doesn't
+// already exist.
+func (a *kafkaApplier) CreateMetaTables(ctx context.Context, spec *pf.MaterializationSpec) (string, boilerplate.ActionApplyFn, error) {
+	topic := a.cfg.checkpointTopic()
+
+	if topics, err := a.admin.topicPartitions(ctx, topic); err != nil {
+		return "", nil, fmt.Errorf("listing checkpoint topic: %w", err)
+	} else if _, ok := topics[topic]; ok {
+		return "", nil, nil
+	}
+
+	return fmt.Sprintf("create checkpoint topic %q", topic), func(ctx context.Context) error {
+		return a.createTopic(ctx, topic, 1, 0, map[string]*string{"cleanup.policy": kmsg.StringPtr("compact")})
+	}, nil
+}
+
+// LoadSpec returns no spec, since Kafka topics do not have a schema of their own which would need to
+// be migrated.
+func (a *kafkaApplier) LoadSpec(ctx context.Context, materialization pf.Materialization) (*pf.MaterializationSpec, error) {
+	return nil, nil
+}
+
+func (a *kafkaApplier) PutSpec(ctx context.Context, spec *pf.MaterializationSpec, version string, exists bool) (string, boilerplate.ActionApplyFn, error) {
+	return "", nil, nil
+}
+
+func (a *kafkaApplier) CreateResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int) (string, boilerplate.ActionApplyFn, error) {
+	res, err := resolveResourceConfig(spec.Bindings[bindingIndex].ResourceConfigJson)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("create topic %q", res.Topic), func(ctx context.Context) error {
+		return a.createTopic(ctx, res.Topic, res.Partitions, res.ReplicationFactor, nil)
+	}, nil
+}
+
+// ReplaceResource is a no-op. Messages which were already produced to a topic remain in it.
+func (a *kafkaApplier) ReplaceResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int) (string, boilerplate.ActionApplyFn, error) {
+	return "", nil, nil
+}
+
+// UpdateResource is a no-op. New Avro schemas are registered when the materialization starts.
+func (a *kafkaApplier) UpdateResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int, bindingUpdate boilerplate.BindingUpdate) (string, boilerplate.ActionApplyFn, error) {
+	return "", nil, nil
+}
+
+// createTopic creates a topic, using the defaults of the cluster for the number of partitions and
+// replication factor if they are 0. It is not an error if the topic already exists.
+func (a *kafkaApplier) createTopic(ctx context.Context, topic string, partitions, replicationFactor int, configs map[string]*string) error {
+	var p, rf = int32(-1), int16(-1)
+	if partitions > 0 {
+		p = int32(partitions)
+	}
+	if replicationFactor > 0 {
+		rf = int16(replicationFactor)
+	}
+
+	if err := a.admin.createTopic(ctx, topic, p, rf, configs); err != nil && !errors.Is(err, kerr.TopicAlreadyExists) {
+		return fmt.Errorf("creating topic %q: %w", topic, err)
+	}
+	return nil
+}
+
+// infoSchema reports the topics of the bindings which already exist. Kafka topics have no schema
+// of their own, so existing topics are considered to have all of the selected fields of their
+// binding.
+func infoSchema(ctx context.Context, admin *adminClient, bindings []*pf.MaterializationSpec_Binding) (*boilerplate.InfoSchema, error) {
+	is := boilerplate.NewInfoSchema(
+		func(rp []string) []string { return rp },
+		func(f string) string { return f },
+	)
+
+	topics, err := admin.topicPartitions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing topics: %w", err)
+	}
+
+	for _, b := range bindings {
+		if _, ok := topics[b.ResourcePath[0]]; !ok || is.HasResource(b.ResourcePath) {
+			continue
+		}
+		for _, f := range b.FieldSelection.AllFields() {
+			is.PushField(boilerplate.EndpointField{Name: f, Nullable: true}, b.ResourcePath...)
+		}
+	}
+
+	return is, nil
+}
@@ -0,0 +1,242 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/hamba/avro/v2"
+)
+
+// avroField is a selected field which is materialized as a field of an Avro record.
+type avroField struct {
+	name     string
+	typ      string
+	nullable bool
+	// asJSON is set for fields which are materialized as JSON-encoded strings, such as objects,
+	// arrays and fields which may have several types.
+	asJSON bool
+}
+
+// newAvroField maps a projection to an Avro field. Avro has no equivalent for JSON fields which
+// may have several types, or for objects and arrays without a known structure, so these are
+// materialized as JSON-encoded strings.
+func newAvroField(p pf.Projection) avroField {
+	var f = avroField{
+		name:     avroName(p.Field),
+		nullable: p.Inference.Exists != pf.Inference_MUST || slices.Contains(p.Inference.Types, pf.JsonTypeNull),
+	}
+
+	var types []string
+	for _, t := range p.Inference.Types {
+		if t != pf.JsonTypeNull {
+			types = append(types, t)
+		}
+	}
+	slices.Sort(types)
+
+	switch {
+	case p.IsRootDocumentProjection():
+		f.typ, f.asJSON = "string", true
+	case slices.Equal(types, []string{pf.JsonTypeString}):
+		f.typ = "string"
+	case slices.Equal(types, []string{pf.JsonTypeInteger}):
+		f.typ = "long"
+	case slices.Equal(types, []string{pf.JsonTypeNumber}), slices.Equal(types, []string{pf.JsonTypeInteger, pf.JsonTypeNumber}):
+		f.typ = "double"
+	case slices.Equal(types, []string{pf.JsonTypeBoolean}):
+		f.typ = "boolean"
+	default:
+		f.typ, f.asJSON = "string", true
+	}
+
+	return f
+}
+
+// value converts a field value into the value of its Avro field.
+func (f avroField) value(v tuple.TupleElement) (any, error) {
+	if v == nil {
+		return nil, nil
+	} else if f.asJSON {
+		if raw, ok := v.(json.RawMessage); ok {
+			return string(raw), nil
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+
+	switch f.typ {
+	case "long":
+		switch v := v.(type) {
+		case int64:
+			return v, nil
+		case uint64:
+			if v > math.MaxInt64 {
+				return nil, fmt.Errorf("value %d of field %q overflows an Avro long", v, f.name)
+			}
+			return int64(v), nil
+		case float64:
+			if v == math.Trunc(v) {
+				return int64(v), nil
+			}
+		}
+	case "double":
+		switch v := v.(type) {
+		case float64:
+			return v, nil
+		case int64:
+			return float64(v), nil
+		case uint64:
+			return float64(v), nil
+		}
+	case "string":
+		if v, ok := v.(string); ok {
+			return v, nil
+		}
+	case "boolean":
+		if v, ok := v.(bool); ok {
+			return v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("value %v of field %q has type %T which can't be materialized as an Avro %s", v, f.name, v, f.typ)
+}
+
+var avroNameRe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// avroName sanitizes a name for use as the name of an Avro record or field, which must start with a
+// letter or underscore and otherwise contain only letters, digits and underscores.
+func avroName(name string) string {
+	name = avroNameRe.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// avroRecord is an Avro record schema of selected fields, along with the ID of the schema in the
+// schema registry.
+type avroRecord struct {
+	schema   avro.Schema
+	schemaID int
+	fields   []avroField
+}
+
+// newAvroRecord builds an Avro record schema with the fields.
+func newAvroRecord(name string, fields []avroField) (*avroRecord, error) {
+	var schemaFields []map[string]any
+	for _, f := range fields {
+		var field = map[string]any{"name": f.name, "type": f.typ}
+		if f.nullable {
+			field["type"] = []string{"null", f.typ}
+			field["default"] = nil
+		}
+		schemaFields = append(schemaFields, field)
+	}
+
+	schemaJSON, err := json.Marshal(map[string]any{
+		"type":      "record",
+		"name":      avroName(name),
+		"namespace": "flow",
+		"fields":    schemaFields,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling schema: %w", err)
+	}
+
+	// Schemas are parsed with their own cache, since records of different topics may have the same
+	// name.
+	schema, err := avro.ParseBytesWithCache(schemaJSON, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema: %w", err)
+	}
+
+	return &avroRecord{schema: schema, fields: fields}, nil
+}
+
+// encode encodes the values of the fields of the record in the Confluent wire format, which is a
+// zero byte followed by the schema ID and the binary-encoded record.
+func (r *avroRecord) encode(values []tuple.TupleElement) ([]byte, error) {
+	var record = make(map[string]any, len(r.fields))
+	for idx, f := range r.fields {
+		v, err := f.value(values[idx])
+		if err != nil {
+			return nil, err
+		}
+		record[f.name] = v
+	}
+
+	body, err := avro.Marshal(r.schema, record)
+	if err != nil {
+		return nil, fmt.Errorf("encoding avro record: %w", err)
+	}
+
+	var out = make([]byte, 5, 5+len(body))
+	binary.BigEndian.PutUint32(out[1:], uint32(r.schemaID))
+	return append(out, body...), nil
+}
+
+// schemaRegistry registers schemas with a Confluent-compatible schema registry.
+type schemaRegistry struct {
+	cfg  schemaRegistryConfig
+	http *http.Client
+}
+
+func newSchemaRegistry(cfg schemaRegistryConfig) *schemaRegistry {
+	return &schemaRegistry{cfg: cfg, http: &http.Client{}}
+}
+
+// register registers the schema under the subject, returning its ID. Registering a schema which is
+// already registered returns its existing ID.
+func (r *schemaRegistry) register(ctx context.Context, subject string, schema avro.Schema) (int, error) {
+	body, err := json.Marshal(map[string]string{"schema": schema.String()})
+	if err != nil {
+		return 0, err
+	}
+
+	address := strings.TrimSuffix(r.cfg.Endpoint, "/") + "/subjects/" + url.PathEscape(subject) + "/versions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	if r.cfg.Username != "" {
+		req.SetBasicAuth(r.cfg.Username, r.cfg.Password)
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("registering schema for subject %q: %w", subject, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("reading response: %w", err)
+	} else if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("registering schema for subject %q: unexpected status %d: %s", subject, resp.StatusCode, string(respBody))
+	}
+
+	var registered struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &registered); err != nil {
+		return 0, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return registered.ID, nil
+}
@@ -0,0 +1,130 @@
+package connector
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+	pc "go.gazette.dev/core/consumer/protocol"
+)
+
+// transactionTimeout bounds how long a transaction may remain open before the broker aborts it. It
+// must be no larger than the transaction.max.timeout.ms of the cluster, which defaults to 15
+// minutes.
+const transactionTimeout = 10 * time.Minute
+
+// clientOpts returns the options common to all clients of the cluster.
+func (c config) clientOpts() ([]kgo.Opt, error) {
+	var opts = []kgo.Opt{
+		kgo.SeedBrokers(strings.Split(c.BootstrapServers, ",")...),
+	}
+
+	if c.TLS == tlsSystemCertificates {
+		opts = append(opts, kgo.DialTLSConfig(&tls.Config{}))
+	}
+
+	if creds := c.Credentials; creds != nil {
+		switch creds.Mechanism {
+		case mechanismPlain:
+			opts = append(opts, kgo.SASL(plain.Auth{User: creds.Username, Pass: creds.Password}.AsMechanism()))
+		case mechanismScramSha256:
+			opts = append(opts, kgo.SASL(scram.Auth{User: creds.Username, Pass: creds.Password}.AsSha256Mechanism()))
+		case mechanismScramSha512:
+			opts = append(opts, kgo.SASL(scram.Auth{User: creds.Username, Pass: creds.Password}.AsSha512Mechanism()))
+		default:
+			return nil, fmt.Errorf("unsupported SASL mechanism %q", creds.Mechanism)
+		}
+	}
+
+	return opts, nil
+}
+
+// readCheckpoint reads the most recently committed checkpoint of the transactional ID from the
+// checkpoint topic. It returns nil if there is no checkpoint, as is the case for a new
+// materialization.
+//
+// The checkpoint topic is read up to its last stable offset with control records included, so that
+// the final offset before it is always observed even though it is a transaction marker.
+func readCheckpoint(ctx context.Context, cfg config, transactionalID string) (*pc.Checkpoint, error) {
+	admin, err := cfg.adminClient()
+	if err != nil {
+		return nil, err
+	}
+	defer admin.Close()
+
+	topic := cfg.checkpointTopic()
+	topics, err := admin.topicPartitions(ctx, topic)
+	if err != nil {
+		return nil, fmt.Errorf("listing checkpoint topic: %w", err)
+	} else if _, ok := topics[topic]; !ok {
+		return nil, fmt.Errorf("checkpoint topic %q does not exist", topic)
+	}
+
+	starts, err := admin.listOffsets(ctx, topic, topics[topic], earliestOffsetTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("listing start offsets: %w", err)
+	}
+	ends, err := admin.listOffsets(ctx, topic, topics[topic], latestOffsetTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("listing committed offsets: %w", err)
+	}
+
+	// The offset through which each partition must be read.
+	var remaining = make(map[int32]int64)
+	var partitions = make(map[int32]kgo.Offset)
+	for partition, end := range ends {
+		if start, ok := starts[partition]; ok && end > start {
+			remaining[partition] = end - 1
+			partitions[partition] = kgo.NewOffset().At(start)
+		}
+	}
+	if len(remaining) == 0 {
+		return nil, nil
+	}
+
+	opts, err := cfg.clientOpts()
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := kgo.NewClient(append(opts,
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{topic: partitions}),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+		kgo.KeepControlRecords(),
+	)...)
+	if err != nil {
+		return nil, fmt.Errorf("creating consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	var latest []byte
+	for len(remaining) > 0 {
+		fetches := consumer.PollFetches(ctx)
+		if err := fetches.Err(); err != nil {
+			return nil, fmt.Errorf("reading checkpoint topic: %w", err)
+		}
+
+		fetches.EachRecord(func(r *kgo.Record) {
+			if !r.Attrs.IsControl() && string(r.Key) == transactionalID {
+				latest = r.Value
+			}
+			if end, ok := remaining[r.Partition]; ok && r.Offset >= end {
+				delete(remaining, r.Partition)
+			}
+		})
+	}
+
+	if latest == nil {
+		return nil, nil
+	}
+
+	var cp = new(pc.Checkpoint)
+	if err := cp.Unmarshal(latest); err != nil {
+		return nil, fmt.Errorf("unmarshalling checkpoint: %w", err)
+	}
+	return cp, nil
+}
@@ -0,0 +1,343 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	m "github.com/estuary/connectors/go/protocols/materialize"
+	schemagen "github.com/estuary/connectors/go/schema-gen"
+	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	pm "github.com/estuary/flow/go/protocols/materialize"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const (
+	messageFormatJSON = "json"
+	messageFormatAvro = "avro"
+
+	mechanismPlain       = "PLAIN"
+	mechanismScramSha256 = "SCRAM-SHA-256"
+	mechanismScramSha512 = "SCRAM-SHA-512"
+
+	tlsSystemCertificates = "system_certificates"
+
+	defaultCheckpointTopic = "flow_checkpoints"
+)
+
+type config struct {
+	BootstrapServers string                `json:"bootstrap_servers" jsonschema:"title=Bootstrap Servers,description=The initial servers in the Kafka cluster to connect to separated by commas. The Kafka client will be informed of the rest of the cluster nodes by connecting to one of these nodes." jsonschema_extras:"order=0"`
+	Credentials      *credentials          `json:"credentials,omitempty" jsonschema:"title=Credentials,description=The connection details for authenticating a client connection to Kafka via SASL. When not provided the client connection will use the PLAINTEXT (insecure) protocol. This must only be used in dev/test environments." jsonschema_extras:"order=1"`
+	TLS              string                `json:"tls,omitempty" jsonschema:"title=TLS Settings,description=Controls how TLS certificates are found or used. Connections do not use TLS if unset.,enum=system_certificates" jsonschema_extras:"order=2"`
+	MessageFormat    string                `json:"message_format,omitempty" jsonschema:"title=Message Format,description=Format of the keys and values of produced messages. Defaults to JSON.,enum=json,enum=avro" jsonschema_extras:"order=3"`
+	SchemaRegistry   *schemaRegistryConfig `json:"schema_registry,omitempty" jsonschema:"title=Schema Registry,description=Schema registry which the Avro schemas of messages are registered with. Required for the Avro message format." jsonschema_extras:"order=4"`
+	Advanced         advancedConfig        `json:"advanced,omitempty" jsonschema:"title=Advanced Options,description=Options for advanced users. You should not typically need to modify these." jsonschema_extras:"advanced=true"`
+}
+
+type credentials struct {
+	Mechanism string `json:"mechanism" jsonschema:"title=SASL Mechanism,enum=PLAIN,enum=SCRAM-SHA-256,enum=SCRAM-SHA-512" jsonschema_extras:"order=0"`
+	Username  string `json:"username" jsonschema:"title=Username" jsonschema_extras:"order=1"`
+	Password  string `json:"password" jsonschema:"title=Password" jsonschema_extras:"secret=true,order=2"`
+}
+
+type schemaRegistryConfig struct {
+	Endpoint string `json:"endpoint" jsonschema:"title=Schema Registry Endpoint,description=URL of the Confluent-compatible schema registry." jsonschema_extras:"order=0"`
+	Username string `json:"username,omitempty" jsonschema:"title=Username,description=Username for basic authentication with the schema registry." jsonschema_extras:"order=1"`
+	Password string `json:"password,omitempty" jsonschema:"title=Password,description=Password for basic authentication with the schema registry." jsonschema_extras:"secret=true,order=2"`
+}
+
+type advancedConfig struct {
+	CheckpointTopic string `json:"checkpoint_topic,omitempty" jsonschema:"title=Checkpoint Topic,description=Compacted topic which checkpoints of the materialization are committed to transactionally with its messages. Defaults to flow_checkpoints."`
+}
+
+func (c config) Validate() error {
+	if c.BootstrapServers == "" {
+		return fmt.Errorf("missing 'bootstrap_servers'")
+	}
+
+	if c.Credentials != nil {
+		switch c.Credentials.Mechanism {
+		case mechanismPlain, mechanismScramSha256, mechanismScramSha512:
+		default:
+			return fmt.Errorf("invalid SASL mechanism %q: must be one of PLAIN, SCRAM-SHA-256, or SCRAM-SHA-512", c.Credentials.Mechanism)
+		}
+		if c.Credentials.Username == "" {
+			return fmt.Errorf("missing 'username'")
+		} else if c.Credentials.Password == "" {
+			return fmt.Errorf("missing 'password'")
+		}
+	}
+
+	switch c.TLS {
+	case "", tlsSystemCertificates:
+	default:
+		return fmt.Errorf("invalid tls %q: must be system_certificates", c.TLS)
+	}
+
+	switch c.MessageFormat {
+	case "", messageFormatJSON:
+	case messageFormatAvro:
+		if c.SchemaRegistry == nil || c.SchemaRegistry.Endpoint == "" {
+			return fmt.Errorf("a schema registry endpoint is required for the avro message format")
+		}
+	default:
+		return fmt.Errorf("invalid message_format %q: must be one of json or avro", c.MessageFormat)
+	}
+
+	if t := c.Advanced.CheckpointTopic; t != "" {
+		if err := validateTopicName(t); err != nil {
+			return fmt.Errorf("checkpoint_topic: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c config) messageFormat() string {
+	if c.MessageFormat == "" {
+		return messageFormatJSON
+	}
+	return c.MessageFormat
+}
+
+func (c config) checkpointTopic() string {
+	if c.Advanced.CheckpointTopic == "" {
+		return defaultCheckpointTopic
+	}
+	return c.Advanced.CheckpointTopic
+}
+
+type resource struct {
+	Topic             string `json:"topic" jsonschema:"title=Topic,description=Name of the topic to produce messages to." jsonschema_extras:"x-collection-name=true"`
+	Partitions        int    `json:"partitions,omitempty" jsonschema:"title=Partitions,description=Number of partitions of the topic if it is created. Uses the default of the cluster if unset."`
+	ReplicationFactor int    `json:"replication_factor,omitempty" jsonschema:"title=Replication Factor,description=Replication factor of the topic if it is created. Uses the default of the cluster if unset."`
+}
+
+func (r resource) Validate() error {
+	if err := validateTopicName(r.Topic); err != nil {
+		return err
+	} else if r.Partitions < 0 {
+		return fmt.Errorf("partitions cannot be negative")
+	} else if r.ReplicationFactor < 0 {
+		return fmt.Errorf("replication_factor cannot be negative")
+	}
+	return nil
+}
+
+var topicNameRe = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+func validateTopicName(topic string) error {
+	if topic == "" {
+		return fmt.Errorf("missing topic name")
+	} else if len(topic) > 249 {
+		return fmt.Errorf("topic name %q is longer than 249 characters", topic)
+	} else if topic == "." || topic == ".." || !topicNameRe.MatchString(topic) {
+		return fmt.Errorf("invalid topic name %q: must contain only letters, digits, '.', '_', or '-'", topic)
+	}
+	return nil
+}
+
+type driver struct{}
+
+var _ boilerplate.Connector = &driver{}
+
+func Driver() driver {
+	return driver{}
+}
+
+func (driver) Spec(ctx context.Context, req *pm.Request_Spec) (*pm.Response_Spec, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("validating request: %w", err)
+	}
+
+	endpointSchema, err := schemagen.GenerateSchema("Materialize Kafka Spec", &config{}).MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("generating endpoint schema: %w", err)
+	}
+
+	resourceSchema, err := schemagen.GenerateSchema("Kafka Topic", &resource{}).MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("generating resource schema: %w", err)
+	}
+
+	return &pm.Response_Spec{
+		ConfigSchemaJson:         json.RawMessage(endpointSchema),
+		ResourceConfigSchemaJson: json.RawMessage(resourceSchema),
+		DocumentationUrl:         "https://go.estuary.dev/materialize-kafka",
+	}, nil
+}
+
+// Validate verifies that the cluster can be connected to and specifies the constraints for the
+// connector. The message format determines which fields may be materialized.
+func (driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Response_Validated, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("validating request: %w", err)
+	}
+
+	cfg, err := resolveEndpointConfig(req.ConfigJson)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := cfg.adminClient()
+	if err != nil {
+		return nil, err
+	}
+	defer admin.Close()
+
+	if _, err := admin.topicPartitions(ctx); err != nil {
+		return nil, fmt.Errorf("listing topics: %w", err)
+	}
+
+	var out []*pm.Response_Validated_Binding
+	for _, b := range req.Bindings {
+		res, err := resolveResourceConfig(b.ResourceConfigJson)
+		if err != nil {
+			return nil, err
+		}
+
+		constraints := make(map[string]*pm.Response_Validated_Constraint)
+		for _, projection := range b.Collection.Projections {
+			constraints[projection.Field] = constraint(cfg.messageFormat(), projection)
+		}
+
+		out = append(out, &pm.Response_Validated_Binding{
+			Constraints:  constraints,
+			DeltaUpdates: true,
+			ResourcePath: []string{res.Topic},
+		})
+	}
+
+	return &pm.Response_Validated{Bindings: out}, nil
+}
+
+// constraint returns the constraint of a projection. Keys are required to build message keys. JSON
+// messages are the full document, and Avro messages are records of the selected fields.
+func constraint(format string, projection pf.Projection) *pm.Response_Validated_Constraint {
+	var c = new(pm.Response_Validated_Constraint)
+
+	switch {
+	case projection.IsPrimaryKey:
+		c.Type = pm.Response_Validated_Constraint_LOCATION_REQUIRED
+		c.Reason = "All Locations that are part of the collections key are required"
+	case format == messageFormatJSON && projection.IsRootDocumentProjection():
+		c.Type = pm.Response_Validated_Constraint_LOCATION_REQUIRED
+		c.Reason = "The root document must be materialized as JSON messages"
+	case format == messageFormatJSON:
+		c.Type = pm.Response_Validated_Constraint_FIELD_FORBIDDEN
+		c.Reason = "JSON messages only materialize the full document"
+	case projection.IsRootDocumentProjection():
+		c.Type = pm.Response_Validated_Constraint_FIELD_OPTIONAL
+		c.Reason = "The root document may be included in Avro messages as a JSON string"
+	case strings.Count(projection.Ptr, "/") == 1:
+		c.Type = pm.Response_Validated_Constraint_LOCATION_RECOMMENDED
+		c.Reason = "Top-level locations should usually be materialized"
+	default:
+		c.Type = pm.Response_Validated_Constraint_FIELD_OPTIONAL
+		c.Reason = "This field is able to be materialized"
+	}
+
+	return c
+}
+
+// Apply creates the checkpoint topic and any new topics for the materialization, leaving existing
+// topics as-is.
+func (driver) Apply(ctx context.Context, req *pm.Request_Apply) (*pm.Response_Applied, error) {
+	cfg, err := resolveEndpointConfig(req.Materialization.ConfigJson)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := cfg.adminClient()
+	if err != nil {
+		return nil, err
+	}
+	defer admin.Close()
+
+	is, err := infoSchema(ctx, admin, req.Materialization.Bindings)
+	if err != nil {
+		return nil, fmt.Errorf("getting infoSchema for apply: %w", err)
+	}
+
+	return boilerplate.ApplyChanges(ctx, req, &kafkaApplier{admin: admin, cfg: cfg}, is, false)
+}
+
+func (driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Transactor, *pm.Response_Opened, error) {
+	cfg, err := resolveEndpointConfig(open.Materialization.ConfigJson)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var registry *schemaRegistry
+	if cfg.messageFormat() == messageFormatAvro {
+		registry = newSchemaRegistry(*cfg.SchemaRegistry)
+	}
+
+	var bindings []*binding
+	for _, b := range open.Materialization.Bindings {
+		res, err := resolveResourceConfig(b.ResourceConfigJson)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		binding, err := newBinding(ctx, res.Topic, b, registry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("binding for topic %q: %w", res.Topic, err)
+		}
+		bindings = append(bindings, binding)
+	}
+
+	// The transactional ID is unique to each shard of the materialization. Initializing a producer
+	// with it fences any prior producer of the shard, aborting its open transaction.
+	transactionalID := fmt.Sprintf("%s.%08x", open.Materialization.Name, open.Range.KeyBegin)
+
+	opts, err := cfg.clientOpts()
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := kgo.NewClient(append(opts,
+		kgo.TransactionalID(transactionalID),
+		kgo.TransactionTimeout(transactionTimeout),
+	)...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating producer: %w", err)
+	}
+	if _, _, err := client.ProducerID(ctx); err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("initializing transactional producer: %w", err)
+	}
+
+	cp, err := readCheckpoint(ctx, cfg, transactionalID)
+	if err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	return &transactor{
+		client:          client,
+		bindings:        bindings,
+		checkpointTopic: cfg.checkpointTopic(),
+		transactionalID: transactionalID,
+	}, &pm.Response_Opened{RuntimeCheckpoint: cp}, nil
+}
+
+func resolveEndpointConfig(specJson json.RawMessage) (config, error) {
+	var cfg = config{}
+	if err := pf.UnmarshalStrict(specJson, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing Kafka config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func resolveResourceConfig(specJson json.RawMessage) (resource, error) {
+	var res = resource{}
+	if err := pf.UnmarshalStrict(specJson, &res); err != nil {
+		return res, fmt.Errorf("parsing resource config: %w", err)
+	}
+
+	return res, nil
+}
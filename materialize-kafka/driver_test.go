@@ -0,0 +1,237 @@
+package connector
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bradleyjkemp/cupaloy"
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	pm "github.com/estuary/flow/go/protocols/materialize"
+	"github.com/hamba/avro/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+	pb "go.gazette.dev/core/broker/protocol"
+	pc "go.gazette.dev/core/consumer/protocol"
+)
+
+func TestDriverSpec(t *testing.T) {
+	t.Parallel()
+
+	response, err := driver{}.Spec(context.Background(), &pm.Request_Spec{})
+	require.NoError(t, err)
+
+	formatted, err := json.MarshalIndent(response, "", "  ")
+	require.NoError(t, err)
+	cupaloy.SnapshotT(t, string(formatted))
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, config{BootstrapServers: "localhost:9092"}.Validate())
+	require.NoError(t, resource{Topic: "my-topic.v1"}.Validate())
+
+	for _, tt := range []struct {
+		cfg  config
+		want string
+	}{
+		{cfg: config{}, want: "missing 'bootstrap_servers'"},
+		{cfg: config{BootstrapServers: "b", Credentials: &credentials{Mechanism: "GSSAPI"}}, want: `invalid SASL mechanism "GSSAPI"`},
+		{cfg: config{BootstrapServers: "b", Credentials: &credentials{Mechanism: mechanismScramSha512, Username: "u"}}, want: "missing 'password'"},
+		{cfg: config{BootstrapServers: "b", MessageFormat: messageFormatAvro}, want: "a schema registry endpoint is required"},
+		{cfg: config{BootstrapServers: "b", Advanced: advancedConfig{CheckpointTopic: "bad/topic"}}, want: "invalid topic name"},
+	} {
+		require.ErrorContains(t, tt.cfg.Validate(), tt.want)
+	}
+
+	require.ErrorContains(t, resource{Topic: "a topic"}.Validate(), "invalid topic name")
+}
+
+func testBinding() *pf.MaterializationSpec_Binding {
+	projection := func(field string, isKey bool, exists pf.Inference_Exists, types ...string) pf.Projection {
+		return pf.Projection{
+			Field:        field,
+			Ptr:          "/" + field,
+			IsPrimaryKey: isKey,
+			Inference:    pf.Inference{Types: types, Exists: exists},
+		}
+	}
+
+	// Projections are ordered by field, as they are in built specs.
+	return &pf.MaterializationSpec_Binding{
+		Collection: pf.CollectionSpec{
+			Projections: []pf.Projection{
+				{Field: "flow_document", Ptr: "", Inference: pf.Inference{Types: []string{pf.JsonTypeObject}, Exists: pf.Inference_MUST}},
+				projection("id", true, pf.Inference_MUST, pf.JsonTypeInteger),
+				projection("name", false, pf.Inference_MAY, pf.JsonTypeString),
+				projection("score", false, pf.Inference_MUST, pf.JsonTypeInteger, pf.JsonTypeNumber),
+				projection("tags", false, pf.Inference_MUST, pf.JsonTypeArray),
+			},
+		},
+		FieldSelection: pf.FieldSelection{
+			Keys:     []string{"id"},
+			Values:   []string{"name", "score", "tags"},
+			Document: "flow_document",
+		},
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	t.Parallel()
+
+	var got = make(map[string]map[string]string)
+	for _, format := range []string{messageFormatJSON, messageFormatAvro} {
+		got[format] = make(map[string]string)
+		for _, p := range testBinding().Collection.Projections {
+			got[format][p.Field] = constraint(format, p).Type.String()
+		}
+	}
+
+	require.Equal(t, map[string]map[string]string{
+		messageFormatJSON: {
+			"id":            "LOCATION_REQUIRED",
+			"name":          "FIELD_FORBIDDEN",
+			"score":         "FIELD_FORBIDDEN",
+			"tags":          "FIELD_FORBIDDEN",
+			"flow_document": "LOCATION_REQUIRED",
+		},
+		messageFormatAvro: {
+			"id":            "LOCATION_REQUIRED",
+			"name":          "LOCATION_RECOMMENDED",
+			"score":         "LOCATION_RECOMMENDED",
+			"tags":          "LOCATION_RECOMMENDED",
+			"flow_document": "FIELD_OPTIONAL",
+		},
+	}, got)
+}
+
+func TestJSONRecord(t *testing.T) {
+	t.Parallel()
+
+	b, err := newBinding(context.Background(), "topic", testBinding(), nil)
+	require.NoError(t, err)
+
+	doc := json.RawMessage(`{"id":1,"name":"a"}`)
+	r, err := b.record(tuple.Tuple{int64(1)}, tuple.Tuple{"a", 1.5, json.RawMessage(`["x"]`)}, doc)
+	require.NoError(t, err)
+	require.Equal(t, "topic", r.Topic)
+	require.Equal(t, `{"id":1}`, string(r.Key))
+	require.Equal(t, []byte(doc), r.Value)
+}
+
+func TestAvroRecord(t *testing.T) {
+	t.Parallel()
+
+	var subjects []string
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/vnd.schemaregistry.v1+json", r.Header.Get("Content-Type"))
+		subjects = append(subjects, r.URL.Path)
+		fmt.Fprintf(w, `{"id":%d}`, len(subjects))
+	}))
+	defer registry.Close()
+
+	b, err := newBinding(context.Background(), "my.topic", testBinding(), newSchemaRegistry(schemaRegistryConfig{Endpoint: registry.URL}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"/subjects/my.topic-key/versions", "/subjects/my.topic-value/versions"}, subjects)
+
+	cupaloy.SnapshotT(t, b.valueRecord.schema.String())
+
+	doc := json.RawMessage(`{"id":1,"tags":["x"]}`)
+	r, err := b.record(tuple.Tuple{int64(1)}, tuple.Tuple{nil, int64(3), json.RawMessage(`["x"]`)}, doc)
+	require.NoError(t, err)
+
+	// Messages are prefixed with a zero byte and the ID of their schema.
+	require.Equal(t, byte(0), r.Key[0])
+	require.Equal(t, uint32(1), binary.BigEndian.Uint32(r.Key[1:5]))
+	require.Equal(t, uint32(2), binary.BigEndian.Uint32(r.Value[1:5]))
+
+	var key map[string]any
+	require.NoError(t, avro.Unmarshal(b.keyRecord.schema, r.Key[5:], &key))
+	require.Equal(t, map[string]any{"id": int64(1)}, key)
+
+	var value map[string]any
+	require.NoError(t, avro.Unmarshal(b.valueRecord.schema, r.Value[5:], &value))
+	require.Equal(t, map[string]any{
+		"id":            int64(1),
+		"name":          nil,
+		"score":         float64(3),
+		"tags":          `["x"]`,
+		"flow_document": string(doc),
+	}, value)
+
+	_, err = b.record(tuple.Tuple{"not a number"}, tuple.Tuple{nil, int64(3), nil}, doc)
+	require.ErrorContains(t, err, `value not a number of field "id" has type string which can't be materialized as an Avro long`)
+}
+
+// TestCheckpoint commits and aborts transactions to a Kafka cluster, which is started with:
+//
+//	docker compose -f materialize-kafka/docker-compose.yaml up
+//	KAFKA_BROKERS=localhost:19092 go test -run TestCheckpoint ./materialize-kafka
+func TestCheckpoint(t *testing.T) {
+	brokers := os.Getenv("KAFKA_BROKERS")
+	if brokers == "" {
+		t.Skip("KAFKA_BROKERS is not set")
+	}
+	ctx := context.Background()
+
+	cfg := config{
+		BootstrapServers: brokers,
+		Advanced:         advancedConfig{CheckpointTopic: fmt.Sprintf("test_checkpoints_%d", time.Now().UnixNano())},
+	}
+	admin, err := cfg.adminClient()
+	require.NoError(t, err)
+	defer admin.Close()
+	defer admin.deleteTopic(ctx, cfg.checkpointTopic())
+
+	applier := &kafkaApplier{admin: admin, cfg: cfg}
+	_, action, err := applier.CreateMetaTables(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, action(ctx))
+
+	// There is no checkpoint for a new materialization.
+	cp, err := readCheckpoint(ctx, cfg, "test.00000000")
+	require.NoError(t, err)
+	require.Nil(t, cp)
+
+	opts, err := cfg.clientOpts()
+	require.NoError(t, err)
+	producer, err := kgo.NewClient(append(opts, kgo.TransactionalID("test.00000000"))...)
+	require.NoError(t, err)
+	defer producer.Close()
+
+	commit := func(journal string, end kgo.TransactionEndTry) {
+		cp := &pc.Checkpoint{Sources: map[pb.Journal]pc.Checkpoint_Source{pb.Journal(journal): {ReadThrough: 1}}}
+		value, err := cp.Marshal()
+		require.NoError(t, err)
+
+		require.NoError(t, producer.BeginTransaction())
+		require.NoError(t, producer.ProduceSync(ctx, &kgo.Record{
+			Topic: cfg.checkpointTopic(),
+			Key:   []byte("test.00000000"),
+			Value: value,
+		}).FirstErr())
+		require.NoError(t, producer.EndTransaction(ctx, end))
+	}
+
+	// Only the most recently committed checkpoint is read.
+	commit("first", kgo.TryCommit)
+	commit("second", kgo.TryCommit)
+	commit("aborted", kgo.TryAbort)
+
+	cp, err = readCheckpoint(ctx, cfg, "test.00000000")
+	require.NoError(t, err)
+	require.Contains(t, cp.Sources, pb.Journal("second"))
+	require.Len(t, cp.Sources, 1)
+
+	cp, err = readCheckpoint(ctx, cfg, "other.00000000")
+	require.NoError(t, err)
+	require.Nil(t, cp)
+}
@@ -0,0 +1,199 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	m "github.com/estuary/connectors/go/protocols/materialize"
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	log "github.com/sirupsen/logrus"
+	"github.com/twmb/franz-go/pkg/kgo"
+	pc "go.gazette.dev/core/consumer/protocol"
+)
+
+type binding struct {
+	topic     string
+	keyFields []string
+
+	// Records of the keys and values of messages, if messages are Avro encoded.
+	keyRecord   *avroRecord
+	valueRecord *avroRecord
+	// Whether the root document is included in Avro values.
+	includeDoc bool
+}
+
+// newBinding builds a binding which produces to the topic. If messages are Avro encoded, the
+// schemas of their keys and values are registered with the registry.
+func newBinding(ctx context.Context, topic string, spec *pf.MaterializationSpec_Binding, registry *schemaRegistry) (*binding, error) {
+	var b = &binding{
+		topic:     topic,
+		keyFields: spec.FieldSelection.Keys,
+	}
+	if registry == nil {
+		return b, nil
+	}
+
+	var keyFields, valueFields []avroField
+	for _, f := range spec.FieldSelection.Keys {
+		keyFields = append(keyFields, newAvroField(*spec.Collection.GetProjection(f)))
+	}
+	valueFields = append(valueFields, keyFields...)
+	for _, f := range spec.FieldSelection.Values {
+		valueFields = append(valueFields, newAvroField(*spec.Collection.GetProjection(f)))
+	}
+	if d := spec.FieldSelection.Document; d != "" {
+		valueFields = append(valueFields, newAvroField(*spec.Collection.GetProjection(d)))
+		b.includeDoc = true
+	}
+
+	var err error
+	if b.keyRecord, err = newAvroRecord(topic+"_key", keyFields); err != nil {
+		return nil, fmt.Errorf("building key schema: %w", err)
+	} else if b.valueRecord, err = newAvroRecord(topic+"_value", valueFields); err != nil {
+		return nil, fmt.Errorf("building value schema: %w", err)
+	}
+
+	// Subjects are named per the default TopicNameStrategy of Confluent serializers.
+	if b.keyRecord.schemaID, err = registry.register(ctx, topic+"-key", b.keyRecord.schema); err != nil {
+		return nil, err
+	} else if b.valueRecord.schemaID, err = registry.register(ctx, topic+"-value", b.valueRecord.schema); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// record builds the message of a stored document.
+func (b *binding) record(key, values tuple.Tuple, doc json.RawMessage) (*kgo.Record, error) {
+	var r = &kgo.Record{Topic: b.topic}
+	var err error
+
+	if b.valueRecord == nil {
+		// The key of JSON messages is an object of the key fields.
+		var buf bytes.Buffer
+		buf.WriteByte('{')
+		for idx, f := range b.keyFields {
+			if idx > 0 {
+				buf.WriteByte(',')
+			}
+			name, err := json.Marshal(f)
+			if err != nil {
+				return nil, err
+			}
+			value, err := json.Marshal(key[idx])
+			if err != nil {
+				return nil, fmt.Errorf("encoding key field %q: %w", f, err)
+			}
+			buf.Write(name)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteByte('}')
+
+		r.Key, r.Value = buf.Bytes(), doc
+		return r, nil
+	}
+
+	var all = append(append([]tuple.TupleElement{}, key...), values...)
+	if b.includeDoc {
+		all = append(all, doc)
+	}
+
+	if r.Key, err = b.keyRecord.encode(key); err != nil {
+		return nil, fmt.Errorf("encoding key: %w", err)
+	} else if r.Value, err = b.valueRecord.encode(all); err != nil {
+		return nil, fmt.Errorf("encoding value: %w", err)
+	}
+	return r, nil
+}
+
+type transactor struct {
+	client          *kgo.Client
+	bindings        []*binding
+	checkpointTopic string
+	transactionalID string
+}
+
+func (t *transactor) UnmarshalState(state json.RawMessage) error                  { return nil }
+func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) { return nil, nil }
+
+// Kafka is delta-update only.
+func (t *transactor) Load(it *m.LoadIterator, _ func(int, json.RawMessage) error) error {
+	for it.Next() {
+		panic("driver only supports delta updates")
+	}
+	return nil
+}
+
+// Store produces the messages of the transaction within a Kafka transaction, which is committed
+// along with the runtime checkpoint.
+func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
+	ctx := it.Context()
+
+	if err := t.client.BeginTransaction(); err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+
+	var mu sync.Mutex
+	var produceErr error
+	var promise = func(r *kgo.Record, err error) {
+		if err != nil {
+			mu.Lock()
+			defer mu.Unlock()
+			if produceErr == nil {
+				produceErr = fmt.Errorf("producing message to topic %q: %w", r.Topic, err)
+			}
+		}
+	}
+
+	for it.Next() {
+		r, err := t.bindings[it.Binding].record(it.Key, it.Values, it.RawJSON)
+		if err != nil {
+			return nil, fmt.Errorf("building message for binding [%d]: %w", it.Binding, err)
+		}
+
+		// Blocks if the maximum number of records are buffered.
+		t.client.Produce(ctx, r, promise)
+	}
+
+	return func(ctx context.Context, runtimeCheckpoint *pc.Checkpoint) (*pf.ConnectorState, m.OpFuture) {
+		return nil, m.RunAsyncOperation(func() error {
+			cp, err := runtimeCheckpoint.Marshal()
+			if err != nil {
+				return fmt.Errorf("marshalling checkpoint: %w", err)
+			}
+			t.client.Produce(ctx, &kgo.Record{
+				Topic: t.checkpointTopic,
+				Key:   []byte(t.transactionalID),
+				Value: cp,
+			}, promise)
+
+			if err := t.client.Flush(ctx); err != nil {
+				return fmt.Errorf("flushing messages: %w", err)
+			}
+
+			mu.Lock()
+			err = produceErr
+			mu.Unlock()
+
+			if err != nil {
+				if abortErr := t.client.EndTransaction(ctx, kgo.TryAbort); abortErr != nil {
+					log.WithField("error", abortErr).Warn("failed to abort transaction")
+				}
+				return err
+			} else if err := t.client.EndTransaction(ctx, kgo.TryCommit); err != nil {
+				return fmt.Errorf("committing transaction: %w", err)
+			}
+
+			return nil
+		})
+	}, nil
+}
+
+func (t *transactor) Destroy() {
+	t.client.Close()
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
 	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
 	pf "github.com/estuary/flow/go/protocols/flow"
@@ -17,6 +19,9 @@ const specCollection = "flow_materializations"
 type mongoApplier struct {
 	client *mongo.Client
 	cfg    config
+	// The previously applied spec, which is used to determine the indexes and validators that were
+	// previously configured for collections.
+	storedSpec *pf.MaterializationSpec
 }
 
 func (e *mongoApplier) CreateMetaTables(ctx context.Context, spec *pf.MaterializationSpec) (string, boilerplate.ActionApplyFn, error) {
@@ -25,8 +30,19 @@ func (e *mongoApplier) CreateMetaTables(ctx context.Context, spec *pf.Materializ
 }
 
 func (e *mongoApplier) CreateResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int) (string, boilerplate.ActionApplyFn, error) {
-	// No-op since new collections are automatically created when data is added to them.
-	return "", nil, nil
+	binding := spec.Bindings[bindingIndex]
+
+	res, err := resolveResourceConfig(binding.ResourceConfigJson)
+	if err != nil {
+		return "", nil, err
+	} else if res.TimeSeries == nil && res.SchemaValidation == "" && len(res.Indexes) == 0 {
+		// No-op since new collections are automatically created when data is added to them.
+		return "", nil, nil
+	}
+
+	return fmt.Sprintf("create collection %q", res.Collection), func(ctx context.Context) error {
+		return createCollection(ctx, e.client.Database(e.cfg.Database), res, binding.Collection)
+	}, nil
 }
 
 func (a *mongoApplier) LoadSpec(ctx context.Context, materialization pf.Materialization) (*pf.MaterializationSpec, error) {
@@ -78,12 +94,57 @@ func (a *mongoApplier) ReplaceResource(ctx context.Context, spec *pf.Materializa
 	}
 
 	return fmt.Sprintf("drop collection %q", res.Collection), func(ctx context.Context) error {
-		// All we do here is drop the collection, since it will be re-created automatically.
-		return a.client.Database(a.cfg.Database).Collection(res.Collection).Drop(ctx)
+		// The collection is re-created automatically if it has no options, and otherwise with its
+		// options.
+		db := a.client.Database(a.cfg.Database)
+		if err := db.Collection(res.Collection).Drop(ctx); err != nil {
+			return err
+		}
+		return createCollection(ctx, db, res, binding.Collection)
 	}, nil
 }
 
 func (e *mongoApplier) UpdateResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int, bindingUpdate boilerplate.BindingUpdate) (string, boilerplate.ActionApplyFn, error) {
-	// No-op since nothing in particular is currently configured for created collection.
-	return "", nil, nil
+	// Collections have no schema other than their indexes and validator, which are converged to
+	// those of the resource configuration.
+	binding := spec.Bindings[bindingIndex]
+
+	res, err := resolveResourceConfig(binding.ResourceConfigJson)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var prev *resource
+	if e.storedSpec != nil {
+		for _, b := range e.storedSpec.Bindings {
+			if slices.Equal(b.ResourcePath, binding.ResourcePath) {
+				r, err := resolveResourceConfig(b.ResourceConfigJson)
+				if err != nil {
+					return "", nil, fmt.Errorf("resolving previous configuration of collection %q: %w", res.Collection, err)
+				}
+				prev = &r
+			}
+		}
+	}
+
+	updates, err := collectionUpdates(ctx, e.client.Database(e.cfg.Database), res, prev, binding.Collection)
+	if err != nil {
+		return "", nil, err
+	} else if len(updates) == 0 {
+		return "", nil, nil
+	}
+
+	var descriptions []string
+	for _, u := range updates {
+		descriptions = append(descriptions, u.description)
+	}
+
+	return strings.Join(descriptions, "\n"), func(ctx context.Context) error {
+		for _, u := range updates {
+			if err := u.apply(ctx); err != nil {
+				return fmt.Errorf("%s: %w", u.description, err)
+			}
+		}
+		return nil
+	}, nil
 }
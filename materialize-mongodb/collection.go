@@ -0,0 +1,486 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pointerTokens returns the unescaped tokens of a JSON pointer.
+func pointerTokens(ptr string) []string {
+	if ptr == "" {
+		return nil
+	}
+
+	var out []string
+	for _, t := range strings.Split(strings.TrimPrefix(ptr, "/"), "/") {
+		out = append(out, strings.NewReplacer("~1", "/", "~0", "~").Replace(t))
+	}
+	return out
+}
+
+// documentPath returns the location of a projection as a path of MongoDB's dot notation.
+func documentPath(p *pf.Projection) (string, error) {
+	tokens := pointerTokens(p.Ptr)
+	if len(tokens) == 0 {
+		return "", fmt.Errorf("field '%s' is the root document", p.Field)
+	}
+
+	for _, t := range tokens {
+		if t == "" || strings.Contains(t, ".") || strings.HasPrefix(t, "$") {
+			return "", fmt.Errorf("location '%s' of field '%s' can't be written in dot notation", p.Ptr, p.Field)
+		}
+	}
+
+	return strings.Join(tokens, "."), nil
+}
+
+// isDateTime returns whether the projection is a date-time string, which may be null.
+func isDateTime(p *pf.Projection) bool {
+	types := slices.DeleteFunc(slices.Clone(p.Inference.Types), func(t string) bool { return t == pf.JsonTypeNull })
+	return len(types) == 1 && types[0] == pf.JsonTypeString &&
+		p.Inference.String_ != nil && p.Inference.String_.Format == "date-time"
+}
+
+// validateProjections returns an error if the indexes and time-series options of the resource
+// aren't valid for projections of the collection.
+func (r resource) validateProjections(collection pf.CollectionSpec) error {
+	var projection = func(f string) (*pf.Projection, error) {
+		p := collection.GetProjection(f)
+		if p == nil {
+			return nil, fmt.Errorf("field '%s' is not a projection of collection '%s'", f, collection.Name)
+		} else if _, err := documentPath(p); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	for _, idx := range r.Indexes {
+		for _, f := range idx.Fields {
+			p, err := projection(f.Field)
+			if err != nil {
+				return fmt.Errorf("index %q: %w", idx.name(), err)
+			} else if idx.ExpireAfterSeconds != nil && !isDateTime(p) {
+				return fmt.Errorf("field '%s' of TTL index %q must be a date-time string", f.Field, idx.name())
+			}
+		}
+	}
+
+	if ts := r.TimeSeries; ts != nil {
+		p, err := projection(ts.TimeField)
+		if err != nil {
+			return fmt.Errorf("time-series 'timeField': %w", err)
+		} else if len(pointerTokens(p.Ptr)) != 1 {
+			return fmt.Errorf("time-series 'timeField' '%s' must be a top-level field", ts.TimeField)
+		} else if !isDateTime(p) || p.Inference.Exists != pf.Inference_MUST || slices.Contains(p.Inference.Types, pf.JsonTypeNull) {
+			return fmt.Errorf("time-series 'timeField' '%s' must be a date-time string which always exists", ts.TimeField)
+		}
+
+		if ts.MetaField != "" {
+			if p, err := projection(ts.MetaField); err != nil {
+				return fmt.Errorf("time-series 'metaField': %w", err)
+			} else if len(pointerTokens(p.Ptr)) != 1 {
+				return fmt.Errorf("time-series 'metaField' '%s' must be a top-level field", ts.MetaField)
+			}
+		}
+	}
+
+	return nil
+}
+
+// dateFields returns the fields which are stored as dates rather than strings, since MongoDB only
+// expires documents and orders time-series by date values. These are the fields of TTL indexes and
+// the time field of a time-series collection.
+func (r resource) dateFields() []string {
+	var out []string
+	for _, idx := range r.Indexes {
+		if idx.ExpireAfterSeconds != nil && !slices.Contains(out, idx.Fields[0].Field) {
+			out = append(out, idx.Fields[0].Field)
+		}
+	}
+	if r.TimeSeries != nil && !slices.Contains(out, r.TimeSeries.TimeField) {
+		out = append(out, r.TimeSeries.TimeField)
+	}
+	return out
+}
+
+// datePointers returns the locations of the date fields of the resource.
+func (r resource) datePointers(collection pf.CollectionSpec) []string {
+	var out []string
+	for _, f := range r.dateFields() {
+		if p := collection.GetProjection(f); p != nil && !slices.Contains(out, p.Ptr) {
+			out = append(out, p.Ptr)
+		}
+	}
+	return out
+}
+
+// convertDates replaces the date-time strings of a document at the locations with dates. Dates
+// have millisecond precision and no time zone offset, which is why only delta updates bindings
+// store them: their documents are never loaded. Strings which aren't date-times are an error,
+// since they couldn't be stored as dates.
+func convertDates(doc map[string]any, ptrs []string) error {
+	for _, ptr := range ptrs {
+		tokens := pointerTokens(ptr)
+
+		var parent = doc
+		for _, t := range tokens[:len(tokens)-1] {
+			if parent, _ = parent[t].(map[string]any); parent == nil {
+				break
+			}
+		}
+		if parent == nil {
+			continue
+		}
+
+		last := tokens[len(tokens)-1]
+		if s, ok := parent[last].(string); ok {
+			// RFC 3339 allows a lowercase 't' and 'z', which time.Parse does not.
+			t, err := time.Parse(time.RFC3339Nano, strings.ToUpper(s))
+			if err != nil {
+				return fmt.Errorf("value %q at %q is not a date-time: %w", s, ptr, err)
+			}
+			parent[last] = t
+		}
+	}
+	return nil
+}
+
+// indexModels returns the models of the secondary indexes of the resource.
+func (r resource) indexModels(collection pf.CollectionSpec) ([]mongo.IndexModel, error) {
+	var out []mongo.IndexModel
+
+	for _, idx := range r.Indexes {
+		var keys bson.D
+		for _, f := range idx.Fields {
+			p := collection.GetProjection(f.Field)
+			if p == nil {
+				return nil, fmt.Errorf("index %q field '%s' is not a projection of collection '%s'", idx.name(), f.Field, collection.Name)
+			}
+			path, err := documentPath(p)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, bson.E{Key: path, Value: f.direction()})
+		}
+
+		opts := options.Index().SetName(idx.name())
+		if idx.Unique {
+			opts.SetUnique(true)
+		}
+		if idx.ExpireAfterSeconds != nil {
+			opts.SetExpireAfterSeconds(*idx.ExpireAfterSeconds)
+		}
+
+		out = append(out, mongo.IndexModel{Keys: keys, Options: opts})
+	}
+
+	return out, nil
+}
+
+// sameIndex returns whether an existing index has the keys and options of the model.
+func sameIndex(existing *mongo.IndexSpecification, model mongo.IndexModel) bool {
+	elems, err := existing.KeysDocument.Elements()
+	if err != nil {
+		return false
+	}
+
+	keys := model.Keys.(bson.D)
+	if len(elems) != len(keys) {
+		return false
+	}
+	for i, e := range elems {
+		direction, ok := e.Value().AsInt64OK()
+		if !ok || e.Key() != keys[i].Key || direction != int64(keys[i].Value.(int32)) {
+			return false
+		}
+	}
+
+	var wantUnique = model.Options.Unique != nil && *model.Options.Unique
+	var isUnique = existing.Unique != nil && *existing.Unique
+	var sameTTL = (existing.ExpireAfterSeconds == nil && model.Options.ExpireAfterSeconds == nil) ||
+		(existing.ExpireAfterSeconds != nil && model.Options.ExpireAfterSeconds != nil &&
+			*existing.ExpireAfterSeconds == *model.Options.ExpireAfterSeconds)
+
+	return wantUnique == isUnique && sameTTL
+}
+
+// jsonSchemaValidator returns a $jsonSchema validator of the locations of the projections of the
+// collection. It constrains the BSON types of the locations and requires those which must exist.
+// Locations of dates are constrained to be dates instead of strings.
+func jsonSchemaValidator(collection pf.CollectionSpec, datePtrs []string) bson.D {
+	type node struct {
+		bsonTypes  []string
+		required   []string
+		properties map[string]*node
+		order      []string
+	}
+	root := &node{bsonTypes: []string{"object"}}
+
+	var seen []string
+	for _, p := range collection.Projections {
+		if p.Ptr == "" || slices.Contains(seen, p.Ptr) {
+			continue
+		}
+		seen = append(seen, p.Ptr)
+
+		n := root
+		tokens := pointerTokens(p.Ptr)
+		for i, t := range tokens {
+			if n.properties == nil {
+				n.properties = make(map[string]*node)
+			}
+			child, ok := n.properties[t]
+			if !ok {
+				child = &node{}
+				n.properties[t] = child
+				n.order = append(n.order, t)
+			}
+			if i == len(tokens)-1 && p.Inference.Exists == pf.Inference_MUST && !slices.Contains(n.required, t) {
+				n.required = append(n.required, t)
+			}
+			n = child
+		}
+
+		n.bsonTypes = bsonTypes(&p, slices.Contains(datePtrs, p.Ptr))
+	}
+
+	var build func(n *node) bson.D
+	build = func(n *node) bson.D {
+		// An unconstrained location has an empty schema.
+		out := bson.D{}
+		if len(n.bsonTypes) == 1 {
+			out = append(out, bson.E{Key: "bsonType", Value: n.bsonTypes[0]})
+		} else if len(n.bsonTypes) > 1 {
+			out = append(out, bson.E{Key: "bsonType", Value: n.bsonTypes})
+		}
+		if len(n.required) > 0 {
+			out = append(out, bson.E{Key: "required", Value: n.required})
+		}
+		if len(n.order) > 0 {
+			var props bson.D
+			for _, k := range n.order {
+				props = append(props, bson.E{Key: k, Value: build(n.properties[k])})
+			}
+			out = append(out, bson.E{Key: "properties", Value: props})
+		}
+		return out
+	}
+
+	return bson.D{{Key: "$jsonSchema", Value: build(root)}}
+}
+
+// bsonTypes returns the BSON types of a projection as they are stored, or nil if the projection is
+// unconstrained. Numbers of documents are stored as doubles, and integers may be stored as any
+// numeric type.
+func bsonTypes(p *pf.Projection, isDate bool) []string {
+	var out []string
+	for _, t := range p.Inference.Types {
+		var bt string
+		switch t {
+		case pf.JsonTypeString:
+			bt = "string"
+			if isDate {
+				bt = "date"
+			}
+		case pf.JsonTypeInteger, pf.JsonTypeNumber:
+			bt = "number"
+		case pf.JsonTypeBoolean:
+			bt = "bool"
+		case pf.JsonTypeNull:
+			bt = "null"
+		case pf.JsonTypeObject:
+			bt = "object"
+		case pf.JsonTypeArray:
+			bt = "array"
+		}
+		if bt != "" && !slices.Contains(out, bt) {
+			out = append(out, bt)
+		}
+	}
+
+	if len(out) == 6 {
+		// All types are possible.
+		return nil
+	}
+	return out
+}
+
+// createCollection creates the collection of a binding with the options of its resource, and its
+// secondary indexes. Collections without options are created implicitly when documents are first
+// written to them.
+func createCollection(ctx context.Context, db *mongo.Database, res resource, collection pf.CollectionSpec) error {
+	opts := options.CreateCollection()
+	if ts := res.TimeSeries; ts != nil {
+		// The time and meta fields are top-level fields, per validateProjections.
+		timeField, err := documentPath(collection.GetProjection(ts.TimeField))
+		if err != nil {
+			return err
+		}
+		tsOpts := options.TimeSeries().SetTimeField(timeField)
+		if ts.MetaField != "" {
+			metaField, err := documentPath(collection.GetProjection(ts.MetaField))
+			if err != nil {
+				return err
+			}
+			tsOpts.SetMetaField(metaField)
+		}
+		if ts.Granularity != "" {
+			tsOpts.SetGranularity(ts.Granularity)
+		}
+		opts.SetTimeSeriesOptions(tsOpts)
+		if ts.ExpireAfterSeconds != 0 {
+			opts.SetExpireAfterSeconds(ts.ExpireAfterSeconds)
+		}
+	}
+	if res.SchemaValidation != "" {
+		opts.SetValidator(jsonSchemaValidator(collection, res.datePointers(collection))).
+			SetValidationAction(res.SchemaValidation).
+			SetValidationLevel("strict")
+	}
+
+	if res.TimeSeries != nil || res.SchemaValidation != "" {
+		if err := db.CreateCollection(ctx, res.Collection, opts); err != nil {
+			var cmdErr mongo.CommandError
+			// The collection may already exist if it was implicitly created by a write.
+			if !errors.As(err, &cmdErr) || cmdErr.Name != "NamespaceExists" {
+				return fmt.Errorf("creating collection %q: %w", res.Collection, err)
+			}
+		}
+	}
+
+	models, err := res.indexModels(collection)
+	if err != nil {
+		return err
+	} else if len(models) > 0 {
+		if _, err := db.Collection(res.Collection).Indexes().CreateMany(ctx, models); err != nil {
+			return fmt.Errorf("creating indexes of collection %q: %w", res.Collection, err)
+		}
+	}
+
+	return nil
+}
+
+// collectionUpdate is an update of an existing collection.
+type collectionUpdate struct {
+	description string
+	apply       func(context.Context) error
+}
+
+// collectionUpdates returns the updates which converge the secondary indexes and validator of an
+// existing collection to its resource. Indexes and validators are only removed if they were
+// configured by the previously applied resource of the binding, which is nil if there isn't one.
+func collectionUpdates(ctx context.Context, db *mongo.Database, res resource, prev *resource, collection pf.CollectionSpec) ([]collectionUpdate, error) {
+	specs, err := db.ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: res.Collection}})
+	if err != nil {
+		return nil, fmt.Errorf("listing collection %q: %w", res.Collection, err)
+	} else if len(specs) != 1 {
+		return nil, fmt.Errorf("expected one collection %q but found %d", res.Collection, len(specs))
+	}
+	spec := specs[0]
+
+	if res.TimeSeries != nil && spec.Type != "timeseries" {
+		return nil, fmt.Errorf("collection %q is not a time-series collection: a collection can only be changed to a time-series collection by re-creating it, which is done by backfilling the binding", res.Collection)
+	}
+
+	var updates []collectionUpdate
+	coll := db.Collection(res.Collection)
+
+	existing, err := coll.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing indexes of collection %q: %w", res.Collection, err)
+	}
+	models, err := res.indexModels(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var keep []string
+	for _, idx := range existing {
+		name := idx.Name
+		wantIdx := slices.IndexFunc(models, func(m mongo.IndexModel) bool { return *m.Options.Name == name })
+		wasConfigured := prev != nil && slices.ContainsFunc(prev.Indexes, func(i index) bool { return i.name() == name })
+
+		if wantIdx == -1 && !wasConfigured {
+			// This index wasn't created by the materialization, and is left alone.
+			continue
+		} else if wantIdx != -1 && sameIndex(idx, models[wantIdx]) {
+			keep = append(keep, name)
+			continue
+		}
+
+		updates = append(updates, collectionUpdate{
+			description: fmt.Sprintf("drop index %q of collection %q", name, res.Collection),
+			apply: func(ctx context.Context) error {
+				_, err := coll.Indexes().DropOne(ctx, name)
+				return err
+			},
+		})
+	}
+	for _, m := range models {
+		if slices.Contains(keep, *m.Options.Name) {
+			continue
+		}
+
+		m := m
+		updates = append(updates, collectionUpdate{
+			description: fmt.Sprintf("create index %q of collection %q", *m.Options.Name, res.Collection),
+			apply: func(ctx context.Context) error {
+				_, err := coll.Indexes().CreateOne(ctx, m)
+				return err
+			},
+		})
+	}
+
+	var existingValidator bson.Raw
+	var existingAction string
+	if v, err := spec.Options.LookupErr("validator"); err == nil {
+		existingValidator, _ = v.DocumentOK()
+	}
+	if v, err := spec.Options.LookupErr("validationAction"); err == nil {
+		existingAction, _ = v.StringValueOK()
+	}
+
+	if res.SchemaValidation != "" {
+		validator, err := bson.Marshal(jsonSchemaValidator(collection, res.datePointers(collection)))
+		if err != nil {
+			return nil, fmt.Errorf("encoding validator of collection %q: %w", res.Collection, err)
+		}
+
+		if !bytes.Equal(existingValidator, validator) || existingAction != res.SchemaValidation {
+			updates = append(updates, collectionUpdate{
+				description: fmt.Sprintf("update $jsonSchema validator of collection %q", res.Collection),
+				apply: func(ctx context.Context) error {
+					return db.RunCommand(ctx, bson.D{
+						{Key: "collMod", Value: res.Collection},
+						{Key: "validator", Value: bson.Raw(validator)},
+						{Key: "validationAction", Value: res.SchemaValidation},
+						{Key: "validationLevel", Value: "strict"},
+					}).Err()
+				},
+			})
+		}
+	} else if elems, _ := existingValidator.Elements(); prev != nil && prev.SchemaValidation != "" && len(elems) > 0 {
+		updates = append(updates, collectionUpdate{
+			description: fmt.Sprintf("remove $jsonSchema validator of collection %q", res.Collection),
+			apply: func(ctx context.Context) error {
+				return db.RunCommand(ctx, bson.D{
+					{Key: "collMod", Value: res.Collection},
+					{Key: "validator", Value: bson.D{}},
+					{Key: "validationLevel", Value: "off"},
+				}).Err()
+			},
+		})
+	}
+
+	return updates, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bradleyjkemp/cupaloy"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func testCollection() pf.CollectionSpec {
+	// Projections are ordered by field, which GetProjection relies on.
+	return pf.CollectionSpec{
+		Name: "acmeCo/events",
+		Key:  []string{"/id"},
+		Projections: []pf.Projection{
+			{Field: "device", Ptr: "/device", Inference: pf.Inference{Types: []string{pf.JsonTypeObject}, Exists: pf.Inference_MUST}},
+			{Field: "device/name", Ptr: "/device/name", Inference: pf.Inference{Types: []string{pf.JsonTypeString, pf.JsonTypeNull}, Exists: pf.Inference_MAY}},
+			{Field: "deviceName", Ptr: "/device/name", Inference: pf.Inference{Types: []string{pf.JsonTypeString, pf.JsonTypeNull}, Exists: pf.Inference_MAY}},
+			{Field: "expires", Ptr: "/meta/expires", Inference: pf.Inference{Types: []string{pf.JsonTypeString, pf.JsonTypeNull}, String_: &pf.Inference_String{Format: "date-time"}, Exists: pf.Inference_MAY}},
+			{Field: "extra", Ptr: "/extra", Inference: pf.Inference{Types: []string{pf.JsonTypeArray, pf.JsonTypeBoolean, pf.JsonTypeInteger, pf.JsonTypeNull, pf.JsonTypeNumber, pf.JsonTypeObject, pf.JsonTypeString}}},
+			{Field: "flow_document", Ptr: "", Inference: pf.Inference{Types: []string{pf.JsonTypeObject}, Exists: pf.Inference_MUST}},
+			{Field: "id", Ptr: "/id", IsPrimaryKey: true, Inference: pf.Inference{Types: []string{pf.JsonTypeString}, Exists: pf.Inference_MUST}},
+			{Field: "ts", Ptr: "/ts", Inference: pf.Inference{Types: []string{pf.JsonTypeString}, String_: &pf.Inference_String{Format: "date-time"}, Exists: pf.Inference_MUST}},
+			{Field: "value", Ptr: "/value", Inference: pf.Inference{Types: []string{pf.JsonTypeInteger}, Exists: pf.Inference_MUST}},
+		},
+	}
+}
+
+func TestResourceValidate(t *testing.T) {
+	ttl := int32(3600)
+	valid := resource{
+		Collection:   "events",
+		DeltaUpdates: true,
+		Indexes: []index{
+			{Fields: []indexField{{Field: "deviceName"}, {Field: "ts", Descending: true}}},
+			{Name: "expiry", Fields: []indexField{{Field: "expires"}}, ExpireAfterSeconds: &ttl},
+		},
+		TimeSeries: &timeSeries{TimeField: "ts", MetaField: "device", Granularity: "minutes"},
+	}
+	require.NoError(t, valid.Validate())
+	require.NoError(t, valid.validateProjections(testCollection()))
+	require.Equal(t, "deviceName_1_ts_-1", valid.Indexes[0].name())
+	require.Equal(t, []string{"/meta/expires", "/ts"}, valid.datePointers(testCollection()))
+
+	for _, tt := range []struct {
+		name   string
+		modify func(*resource)
+		want   string
+	}{
+		{"no index fields", func(r *resource) { r.Indexes[0].Fields = nil }, "must have at least one field"},
+		{"repeated index name", func(r *resource) { r.Indexes[1].Name = "deviceName_1_ts_-1" }, "index name \"deviceName_1_ts_-1\" is repeated"},
+		{"compound TTL index", func(r *resource) { r.Indexes[1].Fields = append(r.Indexes[1].Fields, indexField{Field: "ts"}) }, "TTL index \"expiry\" must have a single field"},
+		{"standard updates TTL index", func(r *resource) { r.DeltaUpdates, r.TimeSeries = false, nil }, "TTL index \"expiry\" requires delta updates"},
+		{"standard updates time-series", func(r *resource) { r.DeltaUpdates, r.Indexes = false, r.Indexes[:1] }, "time-series collections require delta updates"},
+		{"invalid granularity", func(r *resource) { r.TimeSeries.Granularity = "days" }, "invalid time-series 'granularity'"},
+		{"time-series validator", func(r *resource) { r.SchemaValidation = "error" }, "can't have a 'schemaValidation' validator"},
+		{"invalid schema validation", func(r *resource) { r.TimeSeries, r.SchemaValidation = nil, "strict" }, "invalid 'schemaValidation' \"strict\""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			r.Indexes = []index{valid.Indexes[0], valid.Indexes[1]}
+			ts := *valid.TimeSeries
+			r.TimeSeries = &ts
+			tt.modify(&r)
+			require.ErrorContains(t, r.Validate(), tt.want)
+		})
+	}
+
+	for _, tt := range []struct {
+		name string
+		res  resource
+		want string
+	}{
+		{"unknown index field", resource{Indexes: []index{{Fields: []indexField{{Field: "missing"}}}}}, "field 'missing' is not a projection"},
+		{"root document index", resource{Indexes: []index{{Fields: []indexField{{Field: "flow_document"}}}}}, "field 'flow_document' is the root document"},
+		{"TTL index of integer", resource{Indexes: []index{{Fields: []indexField{{Field: "value"}}, ExpireAfterSeconds: &ttl}}}, "must be a date-time string"},
+		{"nested time field", resource{TimeSeries: &timeSeries{TimeField: "expires"}}, "must be a top-level field"},
+		{"optional time field", resource{TimeSeries: &timeSeries{TimeField: "value"}}, "must be a date-time string which always exists"},
+		{"nested meta field", resource{TimeSeries: &timeSeries{TimeField: "ts", MetaField: "deviceName"}}, "'metaField' 'deviceName' must be a top-level field"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.res.validateProjections(testCollection()), tt.want)
+		})
+	}
+}
+
+func TestIndexModels(t *testing.T) {
+	ttl := int32(60)
+	res := resource{Indexes: []index{
+		{Fields: []indexField{{Field: "deviceName"}, {Field: "ts", Descending: true}}, Unique: true},
+		{Name: "expiry", Fields: []indexField{{Field: "expires"}}, ExpireAfterSeconds: &ttl},
+	}}
+
+	models, err := res.indexModels(testCollection())
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+	require.Equal(t, bson.D{{Key: "device.name", Value: int32(1)}, {Key: "ts", Value: int32(-1)}}, models[0].Keys)
+	require.Equal(t, "deviceName_1_ts_-1", *models[0].Options.Name)
+	require.True(t, *models[0].Options.Unique)
+	require.Equal(t, bson.D{{Key: "meta.expires", Value: int32(1)}}, models[1].Keys)
+	require.Equal(t, int32(60), *models[1].Options.ExpireAfterSeconds)
+
+	// Existing indexes are compared by their keys and options.
+	keys, err := bson.Marshal(bson.D{{Key: "device.name", Value: 1}, {Key: "ts", Value: -1}})
+	require.NoError(t, err)
+	unique := true
+	existing := &mongo.IndexSpecification{Name: "deviceName_1_ts_-1", KeysDocument: keys, Unique: &unique}
+	require.True(t, sameIndex(existing, models[0]))
+	existing.Unique = nil
+	require.False(t, sameIndex(existing, models[0]))
+	require.False(t, sameIndex(&mongo.IndexSpecification{KeysDocument: keys}, models[1]))
+}
+
+func TestJSONSchemaValidator(t *testing.T) {
+	validator := jsonSchemaValidator(testCollection(), []string{"/meta/expires"})
+
+	formatted, err := bson.MarshalExtJSONIndent(validator, false, false, "", "  ")
+	require.NoError(t, err)
+	cupaloy.SnapshotT(t, string(formatted))
+}
+
+func TestConvertDates(t *testing.T) {
+	doc := map[string]any{
+		"ts":    "2024-01-02t03:04:05.5z",
+		"meta":  map[string]any{"expires": "2024-02-01T00:00:00+01:00"},
+		"other": "2024-01-02T03:04:05Z",
+	}
+	require.NoError(t, convertDates(doc, []string{"/ts", "/meta/expires", "/missing/expires"}))
+
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 5e8, time.UTC), doc["ts"])
+	require.True(t, time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC).Equal(doc["meta"].(map[string]any)["expires"].(time.Time)))
+	require.Equal(t, "2024-01-02T03:04:05Z", doc["other"])
+
+	// Strings which aren't date-times can't be stored as dates.
+	doc = map[string]any{"ts": "yesterday"}
+	require.ErrorContains(t, convertDates(doc, []string{"/ts"}), "value \"yesterday\" at \"/ts\" is not a date-time")
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"fmt"
-
 	"net/url"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
 type config struct {
@@ -11,6 +15,18 @@ type config struct {
 	User     string `json:"user" jsonschema:"title=User,description=Database user to connect as." jsonschema_extras:"order=1"`
 	Password string `json:"password" jsonschema:"title=Password,description=Password for the specified database user." jsonschema_extras:"secret=true,order=2"`
 	Database string `json:"database" jsonschema:"title=Database,description=Name of the database to materialize to." jsonschema_extras:"order=3"`
+
+	Verification *verification `json:"verification,omitempty" jsonschema:"title=Verification,description=Verify the stored documents of each collection when the materialization is applied. Documents are read back the same way as loaded documents to check that they would be loaded for their keys. A summary of each run is logged and written to the flow_verification collection. Remove this once documents are verified." jsonschema_extras:"order=4"`
+
+	Advanced advancedConfig `json:"advanced,omitempty" jsonschema:"title=Advanced Options,description=Options for advanced users. You should not typically need to modify these." jsonschema_extras:"advanced=true"`
+}
+
+type advancedConfig struct {
+	Transactions        bool   `json:"transactions,omitempty" jsonschema:"title=Transactions,description=Write each Flow transaction in a MongoDB transaction together with its checkpoint for exactly-once semantics. Requires a replica set or sharded cluster. MongoDB aborts transactions which run longer than its transactionLifetimeLimitSeconds parameter (60 seconds by default) so every Flow transaction must be written within that time. Time-series collections can't be written in transactions."`
+	MaxTransactionBytes int64  `json:"maxTransactionBytes,omitempty" jsonschema:"title=Max Transaction Bytes,description=Most bytes of documents written by a MongoDB transaction when transactions are enabled. Flow transactions which write more fail with an error. Defaults to 64 MiB."`
+	WriteConcern        string `json:"writeConcern,omitempty" jsonschema:"title=Write Concern,description=Acknowledgment requested for writes: either majority or the number or tag set of members which must acknowledge them. Defaults to the write concern of the deployment."`
+	Journal             bool   `json:"journal,omitempty" jsonschema:"title=Journal,description=Request acknowledgment that writes have been written to the on-disk journal."`
+	ReadPreference      string `json:"readPreference,omitempty" jsonschema:"title=Read Preference,description=Members of the replica set which documents are loaded from. Documents loaded from secondaries may not reflect the latest transactions. Defaults to primary.,enum=primary,enum=primaryPreferred,enum=secondary,enum=secondaryPreferred,enum=nearest"`
 }
 
 func (c *config) Validate() error {
@@ -32,9 +48,73 @@ func (c *config) Validate() error {
 		return fmt.Errorf("`mongodb+srv://` addresses do not support specifying the port")
 	}
 
+	if c.Verification != nil {
+		if err := c.Verification.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if w, err := strconv.Atoi(c.Advanced.WriteConcern); err == nil && w < 1 {
+		return fmt.Errorf("'writeConcern' must request acknowledgment from at least one member")
+	} else if _, err := c.Advanced.readPreference(); err != nil {
+		return err
+	} else if c.Advanced.MaxTransactionBytes < 0 {
+		return fmt.Errorf("'maxTransactionBytes' cannot be negative")
+	}
+
+	return nil
+}
+
+// validateResource validates the resource of a binding against the endpoint configuration.
+func (c *config) validateResource(res resource) error {
+	if c.Advanced.Transactions && res.TimeSeries != nil {
+		return fmt.Errorf("collection %q can't be a time-series collection when transactions are enabled, since MongoDB doesn't allow writes to time-series collections in transactions", res.Collection)
+	}
 	return nil
 }
 
+// writeConcern returns the configured write concern, or nil if the write concern of the deployment
+// is used.
+func (c advancedConfig) writeConcern() *writeconcern.WriteConcern {
+	if c.WriteConcern == "" && !c.Journal {
+		return nil
+	}
+
+	var wc = &writeconcern.WriteConcern{}
+	if w, err := strconv.Atoi(c.WriteConcern); err == nil {
+		wc.W = w
+	} else if c.WriteConcern != "" {
+		wc.W = c.WriteConcern
+	}
+	if c.Journal {
+		wc.Journal = &c.Journal
+	}
+
+	return wc
+}
+
+// maxTransactionBytes returns the configured limit of the bytes of documents written by a
+// transaction, or its default.
+func (c advancedConfig) maxTransactionBytes() int {
+	if c.MaxTransactionBytes == 0 {
+		return defaultMaxTransactionBytes
+	}
+	return int(c.MaxTransactionBytes)
+}
+
+// readPreference returns the configured read preference, or nil if it isn't set.
+func (c advancedConfig) readPreference() (*readpref.ReadPref, error) {
+	if c.ReadPreference == "" {
+		return nil, nil
+	}
+
+	mode, err := readpref.ModeFromString(c.ReadPreference)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'readPreference' %q", c.ReadPreference)
+	}
+	return readpref.New(mode)
+}
+
 // ToURI converts the Config to a DSN string.
 func (c *config) ToURI() string {
 	var address = c.Address
@@ -57,13 +137,106 @@ func (c *config) ToURI() string {
 }
 
 type resource struct {
-	Collection   string `json:"collection" jsonschema:"title=Collection name" jsonschema_extras:"x-collection-name=true"`
-	DeltaUpdates bool   `json:"delta_updates,omitempty" jsonschema:"title=Delta updates,default=false"`
+	Collection       string      `json:"collection" jsonschema:"title=Collection name" jsonschema_extras:"x-collection-name=true"`
+	DeltaUpdates     bool        `json:"delta_updates,omitempty" jsonschema:"title=Delta updates,default=false"`
+	Indexes          []index     `json:"indexes,omitempty" jsonschema:"title=Indexes,description=Secondary indexes of the collection. Indexes are created and dropped as they are added to and removed from this list."`
+	TimeSeries       *timeSeries `json:"timeSeries,omitempty" jsonschema:"title=Time Series,description=Create the collection as a time-series collection. Only delta updates bindings can be time-series collections. This can only be changed when the collection is created or re-created by a backfill."`
+	SchemaValidation string      `json:"schemaValidation,omitempty" jsonschema:"title=Schema Validation,description=Install a $jsonSchema validator derived from the Flow collection schema. Documents which don't match it are either rejected or logged as a warning by MongoDB.,enum=error,enum=warn"`
+}
+
+// index configures a secondary index of a collection.
+type index struct {
+	Name               string       `json:"name,omitempty" jsonschema:"title=Index Name,description=Name of the index. Defaults to a name generated from its fields."`
+	Fields             []indexField `json:"fields" jsonschema:"title=Fields,description=Fields which are the keys of the index in order."`
+	Unique             bool         `json:"unique,omitempty" jsonschema:"title=Unique,description=Reject documents with the same values of the fields as another document."`
+	ExpireAfterSeconds *int32       `json:"expireAfterSeconds,omitempty" jsonschema:"title=Expire After Seconds,description=Make this a TTL index of its single date-time field. Documents are deleted by MongoDB this many seconds after that time. Only delta updates bindings can have TTL indexes, since the field is stored as a date having millisecond precision and no time zone offset."`
+}
+
+type indexField struct {
+	Field      string `json:"field" jsonschema:"title=Field,description=Projection of the Flow collection which is a key of the index."`
+	Descending bool   `json:"descending,omitempty" jsonschema:"title=Descending"`
+}
+
+// timeSeries configures a time-series collection.
+type timeSeries struct {
+	TimeField          string `json:"timeField" jsonschema:"title=Time Field,description=Top-level date-time field which is the time of each document."`
+	MetaField          string `json:"metaField,omitempty" jsonschema:"title=Meta Field,description=Optional top-level field which identifies the series of each document."`
+	Granularity        string `json:"granularity,omitempty" jsonschema:"title=Granularity,description=Expected interval between documents of a series.,enum=seconds,enum=minutes,enum=hours"`
+	ExpireAfterSeconds int64  `json:"expireAfterSeconds,omitempty" jsonschema:"title=Expire After Seconds,description=Delete documents this many seconds after their time. Leave blank to keep documents indefinitely."`
 }
 
 func (r resource) Validate() error {
 	if r.Collection == "" {
 		return fmt.Errorf("collection is required")
 	}
+
+	var names = make(map[string]bool)
+	for _, idx := range r.Indexes {
+		if len(idx.Fields) == 0 {
+			return fmt.Errorf("index %q must have at least one field", idx.name())
+		} else if names[idx.name()] {
+			return fmt.Errorf("index name %q is repeated", idx.name())
+		} else if idx.ExpireAfterSeconds != nil && len(idx.Fields) != 1 {
+			return fmt.Errorf("TTL index %q must have a single field", idx.name())
+		} else if idx.ExpireAfterSeconds != nil && *idx.ExpireAfterSeconds < 0 {
+			return fmt.Errorf("'expireAfterSeconds' of index %q cannot be negative", idx.name())
+		} else if idx.ExpireAfterSeconds != nil && !r.DeltaUpdates {
+			return fmt.Errorf("TTL index %q requires delta updates", idx.name())
+		}
+		names[idx.name()] = true
+
+		for _, f := range idx.Fields {
+			if f.Field == "" {
+				return fmt.Errorf("index %q has a field with no name", idx.name())
+			}
+		}
+	}
+
+	if ts := r.TimeSeries; ts != nil {
+		if !r.DeltaUpdates {
+			return fmt.Errorf("time-series collections require delta updates")
+		} else if ts.TimeField == "" {
+			return fmt.Errorf("missing time-series 'timeField'")
+		} else if ts.MetaField == ts.TimeField {
+			return fmt.Errorf("time-series 'metaField' cannot be the 'timeField'")
+		} else if ts.ExpireAfterSeconds < 0 {
+			return fmt.Errorf("time-series 'expireAfterSeconds' cannot be negative")
+		} else if r.SchemaValidation != "" {
+			return fmt.Errorf("time-series collections can't have a 'schemaValidation' validator")
+		}
+
+		switch ts.Granularity {
+		case "", "seconds", "minutes", "hours":
+		default:
+			return fmt.Errorf("invalid time-series 'granularity' %q", ts.Granularity)
+		}
+	}
+
+	switch r.SchemaValidation {
+	case "", "error", "warn":
+	default:
+		return fmt.Errorf("invalid 'schemaValidation' %q", r.SchemaValidation)
+	}
+
 	return nil
 }
+
+// name returns the name of the index, which defaults to the name MongoDB would generate for it.
+func (idx index) name() string {
+	if idx.Name != "" {
+		return idx.Name
+	}
+
+	var parts []string
+	for _, f := range idx.Fields {
+		parts = append(parts, f.Field, fmt.Sprint(f.direction()))
+	}
+	return strings.Join(parts, "_")
+}
+
+func (f indexField) direction() int32 {
+	if f.Descending {
+		return -1
+	}
+	return 1
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/bradleyjkemp/cupaloy"
 	pm "github.com/estuary/flow/go/protocols/materialize"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
 func TestSpecification(t *testing.T) {
@@ -20,3 +22,38 @@ func TestSpecification(t *testing.T) {
 
 	cupaloy.SnapshotT(t, formatted)
 }
+
+func TestAdvancedConfig(t *testing.T) {
+	require.Nil(t, advancedConfig{}.writeConcern())
+	require.Equal(t, &writeconcern.WriteConcern{W: "majority"}, advancedConfig{WriteConcern: "majority"}.writeConcern())
+
+	journal := true
+	require.Equal(t, &writeconcern.WriteConcern{W: 2, Journal: &journal}, advancedConfig{WriteConcern: "2", Journal: true}.writeConcern())
+
+	rp, err := advancedConfig{}.readPreference()
+	require.NoError(t, err)
+	require.Nil(t, rp)
+	rp, err = advancedConfig{ReadPreference: "secondaryPreferred"}.readPreference()
+	require.NoError(t, err)
+	require.Equal(t, readpref.SecondaryPreferredMode, rp.Mode())
+
+	var cfg = config{Address: "mongodb://localhost", User: "user", Password: "password", Database: "db"}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Advanced = advancedConfig{WriteConcern: "0"}
+	require.ErrorContains(t, cfg.Validate(), "'writeConcern' must request acknowledgment")
+	cfg.Advanced = advancedConfig{ReadPreference: "fastest"}
+	require.ErrorContains(t, cfg.Validate(), "invalid 'readPreference' \"fastest\"")
+	cfg.Advanced = advancedConfig{MaxTransactionBytes: -1}
+	require.ErrorContains(t, cfg.Validate(), "'maxTransactionBytes' cannot be negative")
+
+	require.Equal(t, defaultMaxTransactionBytes, advancedConfig{}.maxTransactionBytes())
+	require.Equal(t, 1024, advancedConfig{MaxTransactionBytes: 1024}.maxTransactionBytes())
+
+	// Time-series collections can't be written in transactions.
+	var res = resource{Collection: "events", DeltaUpdates: true, TimeSeries: &timeSeries{TimeField: "ts"}}
+	require.NoError(t, cfg.validateResource(res))
+	cfg.Advanced = advancedConfig{Transactions: true}
+	require.ErrorContains(t, cfg.validateResource(res), "collection \"events\" can't be a time-series collection when transactions are enabled")
+	require.NoError(t, cfg.validateResource(resource{Collection: "events"}))
+}
@@ -13,6 +13,7 @@ import (
 	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
 	pf "github.com/estuary/flow/go/protocols/flow"
 	pm "github.com/estuary/flow/go/protocols/materialize"
+	"go.gazette.dev/core/consumer/protocol"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -25,8 +26,18 @@ import (
 type driver struct{}
 
 func (d *driver) connect(ctx context.Context, cfg config) (*mongo.Client, error) {
+	var opts = options.Client().ApplyURI(cfg.ToURI())
+	if wc := cfg.Advanced.writeConcern(); wc != nil {
+		opts.SetWriteConcern(wc)
+	}
+	if rp, err := cfg.Advanced.readPreference(); err != nil {
+		return nil, err
+	} else if rp != nil {
+		opts.SetReadPreference(rp)
+	}
+
 	// Create a new client and connect to the server
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.ToURI()))
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +134,12 @@ func (d driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Res
 			constraints[projection.Field] = constraint
 		}
 
+		if err := res.validateProjections(b.Collection); err != nil {
+			return nil, err
+		} else if err := cfg.validateResource(res); err != nil {
+			return nil, err
+		}
+
 		resourcePath := []string{cfg.Database, res.Collection}
 
 		out = append(out, &pm.Response_Validated_Binding{
@@ -151,10 +168,36 @@ func (d driver) Apply(ctx context.Context, req *pm.Request_Apply) (*pm.Response_
 		return nil, fmt.Errorf("getting infoSchema for apply: %w", err)
 	}
 
-	return boilerplate.ApplyChanges(ctx, req, &mongoApplier{
+	applier := &mongoApplier{
 		client: client,
 		cfg:    cfg,
-	}, is, true)
+	}
+	if applier.storedSpec, err = applier.LoadSpec(ctx, req.Materialization.Name); err != nil {
+		return nil, fmt.Errorf("getting stored spec: %w", err)
+	}
+
+	applied, err := boilerplate.ApplyChanges(ctx, req, applier, is, true)
+	if err != nil || cfg.Verification == nil {
+		return applied, err
+	}
+
+	// Documents are verified once collections are updated, each time the materialization is
+	// applied while verification is configured.
+	v, err := newVerifier(client.Database(cfg.Database), req.Materialization, *cfg.Verification)
+	if err != nil {
+		return nil, err
+	}
+	run, err := v.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if applied.ActionDescription != "" {
+		applied.ActionDescription += "\n"
+	}
+	applied.ActionDescription += run.description()
+
+	return applied, nil
 }
 
 func (d driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Transactor, *pm.Response_Opened, error) {
@@ -179,13 +222,40 @@ func (d driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Tran
 		bindings = append(bindings, &binding{
 			collection:   collection,
 			deltaUpdates: b.DeltaUpdates,
+			datePtrs:     res.datePointers(b.Collection),
 		})
 	}
 
-	return &transactor{
+	var t = &transactor{
 		client:   client,
 		bindings: bindings,
-	}, &pm.Response_Opened{}, nil
+	}
+
+	var cp *protocol.Checkpoint
+	if cfg.Advanced.Transactions {
+		// We must install a fence to prevent another (zombie) instances of this materialization
+		// from committing further transactions.
+		var checkpoints = client.Database(cfg.Database).Collection(checkpointsCollection)
+		fence, err := installFence(ctx, checkpoints, fence{
+			Materialization: open.Materialization.Name.String(),
+			KeyBegin:        open.Range.KeyBegin,
+			KeyEnd:          open.Range.KeyEnd,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("installing checkpoints fence (transactions require a replica set or sharded cluster): %w", err)
+		}
+		t.checkpoints, t.fence = checkpoints, &fence
+		t.maxTransactionBytes = cfg.Advanced.maxTransactionBytes()
+
+		if len(fence.Checkpoint) > 0 {
+			cp = new(protocol.Checkpoint)
+			if err := cp.Unmarshal(fence.Checkpoint); err != nil {
+				return nil, nil, fmt.Errorf("unmarshalling fence.Checkpoint, %w", err)
+			}
+		}
+	}
+
+	return t, &pm.Response_Opened{RuntimeCheckpoint: cp}, nil
 }
 
 func resolveEndpointConfig(specJson json.RawMessage) (config, error) {
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// checkpointsCollection holds the fences of materializations which use transactions.
+const checkpointsCollection = "flow_checkpoints"
+
+// fence is an installed barrier in the checkpoints collection which prevents other sessions from
+// committing transactions under the fenced key range, and prevents this fence from committing where
+// another session has in turn fenced this instance off. It's the equivalent of the Fence of
+// materialize-sql.
+type fence struct {
+	// Full name of the fenced materialization.
+	Materialization string `bson:"materialization"`
+	// [KeyBegin, KeyEnd] identify the range of keys covered by this fence.
+	KeyBegin uint32 `bson:"key_begin"`
+	KeyEnd   uint32 `bson:"key_end"`
+	// Fence is the current value of the monotonically increasing integer used to order and
+	// isolate instances of the Transactions RPCs.
+	Fence int64 `bson:"fence"`
+	// Checkpoint associated with this fence. A zero-length Checkpoint indicates that the
+	// recovery-log Checkpoint should be used instead.
+	Checkpoint []byte `bson:"checkpoint"`
+}
+
+// installFence increments the fence of every checkpoint which overlaps the key range of the fence,
+// and returns the fence and checkpoint of the narrowest checkpoint which covers it. A checkpoint
+// for the exact key range is added if there isn't one already.
+//
+// A fence which is installed for the first time has no checkpoint, so a materialization which
+// enables transactions resumes from its recovery-log checkpoint.
+func installFence(ctx context.Context, checkpoints *mongo.Collection, f fence) (fence, error) {
+	session, err := checkpoints.Database().Client().StartSession()
+	if err != nil {
+		return fence{}, fmt.Errorf("starting session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	installed, err := session.WithTransaction(ctx, func(sctx mongo.SessionContext) (interface{}, error) {
+		var out = f // The transaction function may be retried.
+
+		if _, err := checkpoints.UpdateMany(sctx, bson.D{
+			{Key: "materialization", Value: out.Materialization},
+			{Key: "key_end", Value: bson.D{{Key: "$gte", Value: out.KeyBegin}}},
+			{Key: "key_begin", Value: bson.D{{Key: "$lte", Value: out.KeyEnd}}},
+		}, bson.D{{Key: "$inc", Value: bson.D{{Key: "fence", Value: 1}}}}); err != nil {
+			return nil, fmt.Errorf("incrementing fence: %w", err)
+		}
+
+		cur, err := checkpoints.Find(sctx, bson.D{
+			{Key: "materialization", Value: out.Materialization},
+			{Key: "key_begin", Value: bson.D{{Key: "$lte", Value: out.KeyBegin}}},
+			{Key: "key_end", Value: bson.D{{Key: "$gte", Value: out.KeyEnd}}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("finding fences: %w", err)
+		}
+		var covering []fence
+		if err := cur.All(sctx, &covering); err != nil {
+			return nil, fmt.Errorf("decoding fences: %w", err)
+		}
+
+		var narrowest = narrowestFence(covering)
+		if narrowest != nil {
+			out.Fence, out.Checkpoint = narrowest.Fence, narrowest.Checkpoint
+		}
+
+		// If a checkpoint for this exact range doesn't exist then insert it now.
+		if narrowest == nil || narrowest.KeyBegin != out.KeyBegin || narrowest.KeyEnd != out.KeyEnd {
+			if _, err := checkpoints.InsertOne(sctx, out); err != nil {
+				return nil, fmt.Errorf("inserting fence: %w", err)
+			}
+		}
+
+		return out, nil
+	})
+	if err != nil {
+		return fence{}, err
+	}
+
+	return installed.(fence), nil
+}
+
+// narrowestFence returns the fence having the narrowest key range, or nil if there are none.
+func narrowestFence(fences []fence) *fence {
+	var out *fence
+	for idx := range fences {
+		if f := &fences[idx]; out == nil || f.KeyEnd-f.KeyBegin < out.KeyEnd-out.KeyBegin {
+			out = f
+		}
+	}
+	return out
+}
+
+// updateFence sets the checkpoint of the fence within the transaction of the session context. It
+// errors if this instance has been fenced off by another.
+func updateFence(sctx mongo.SessionContext, checkpoints *mongo.Collection, f fence) error {
+	res, err := checkpoints.UpdateOne(sctx, bson.D{
+		{Key: "materialization", Value: f.Materialization},
+		{Key: "key_begin", Value: f.KeyBegin},
+		{Key: "key_end", Value: f.KeyEnd},
+		{Key: "fence", Value: f.Fence},
+	}, bson.D{{Key: "$set", Value: bson.D{{Key: "checkpoint", Value: f.Checkpoint}}}})
+	if err != nil {
+		return fmt.Errorf("updating fence: %w", err)
+	} else if res.MatchedCount == 0 {
+		return errors.New("this instance was fenced off by another")
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNarrowestFence(t *testing.T) {
+	require.Nil(t, narrowestFence(nil))
+
+	fences := []fence{
+		{KeyBegin: 0, KeyEnd: 0xffffffff, Fence: 1},
+		{KeyBegin: 0, KeyEnd: 0x7fffffff, Fence: 2},
+		{KeyBegin: 0x10000000, KeyEnd: 0x7fffffff, Fence: 3},
+	}
+	require.Equal(t, &fences[2], narrowestFence(fences))
+}
@@ -9,9 +9,11 @@ import (
 	m "github.com/estuary/connectors/go/protocols/materialize"
 	pf "github.com/estuary/flow/go/protocols/flow"
 	"github.com/sirupsen/logrus"
+	"go.gazette.dev/core/consumer/protocol"
 	"golang.org/x/sync/errgroup"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -28,16 +30,28 @@ const (
 	// The default batchWriteLimit is 100,000 documents. Practically speaking we will be limited to
 	// less than that to keep connector memory usage reasonable.
 	storeBatchSize = 10_000
+
+	// Default limit of the bytes of documents written by a MongoDB transaction. Transactions must
+	// commit within the transactionLifetimeLimitSeconds of MongoDB, which is 60 seconds by default.
+	defaultMaxTransactionBytes = 64 * 1024 * 1024
 )
 
 type transactor struct {
 	client   *mongo.Client
 	bindings []*binding
+	// Collection and installed fence of the checkpoint which is committed with each transaction,
+	// if transactions are enabled.
+	checkpoints *mongo.Collection
+	fence       *fence
+	// Most bytes of documents written by a MongoDB transaction.
+	maxTransactionBytes int
 }
 
 type binding struct {
 	collection   *mongo.Collection
 	deltaUpdates bool
+	// Locations of date-time strings which are stored as dates.
+	datePtrs []string
 }
 
 func (t *transactor) UnmarshalState(state json.RawMessage) error                  { return nil }
@@ -96,9 +110,29 @@ func (t *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage)
 	return group.Wait()
 }
 
-func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
+func (t *transactor) Store(it *m.StoreIterator) (_ m.StartCommitFunc, err error) {
 	var ctx = it.Context()
 
+	// Documents are written within a MongoDB transaction which is committed with the checkpoint of
+	// the Flow transaction, if transactions are enabled.
+	var session mongo.Session
+	if t.fence != nil {
+		if session, err = t.client.StartSession(); err != nil {
+			return nil, fmt.Errorf("starting session: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				// Ending the session aborts its transaction.
+				session.EndSession(ctx)
+			}
+		}()
+
+		if err := session.StartTransaction(); err != nil {
+			return nil, fmt.Errorf("starting transaction: %w", err)
+		}
+		ctx = mongo.NewSessionContext(ctx, session)
+	}
+
 	sendBatches := make(chan storeBatch)
 
 	group, groupCtx := errgroup.WithContext(ctx)
@@ -111,6 +145,8 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 	// protection against huge documents running the connector out of memory.
 	batchSize := 0
 	lastBinding := -1
+	// Bytes of documents written by the MongoDB transaction, if transactions are enabled.
+	transactionSize := 0
 
 	sendBatch := func() error {
 		select {
@@ -130,12 +166,22 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 			}
 		}
 
+		if session != nil {
+			if transactionSize += len(it.RawJSON); transactionSize > t.maxTransactionBytes {
+				return nil, fmt.Errorf("transaction writes more than %d bytes of documents, which is the 'maxTransactionBytes' of transactions: increase it or disable transactions", t.maxTransactionBytes)
+			}
+		}
+
 		key := fmt.Sprintf("%x", it.PackedKey) // Hex-encode
 
 		var doc bson.M
 		if err := json.Unmarshal(it.RawJSON, &doc); err != nil {
 			return nil, fmt.Errorf("bson unmarshalling json doc: %w", err)
 		}
+		if err := convertDates(doc, t.bindings[it.Binding].datePtrs); err != nil {
+			return nil, fmt.Errorf("converting dates of document: %w", err)
+		}
+
 		// In case of delta updates, we don't want to set the _id. We want MongoDB to generate a new
 		// _id for each record we insert
 		if !t.bindings[it.Binding].deltaUpdates {
@@ -163,7 +209,32 @@ func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
 	}
 
 	close(sendBatches)
-	return nil, group.Wait()
+	if err := group.Wait(); err != nil {
+		return nil, err
+	} else if session == nil {
+		return nil, nil
+	}
+
+	return func(ctx context.Context, runtimeCheckpoint *protocol.Checkpoint) (*pf.ConnectorState, m.OpFuture) {
+		return nil, m.RunAsyncOperation(func() error {
+			defer session.EndSession(ctx)
+			return t.commit(mongo.NewSessionContext(ctx, session), runtimeCheckpoint)
+		})
+	}, nil
+}
+
+// commit the transaction of the session together with the runtime checkpoint.
+func (t *transactor) commit(sctx mongo.SessionContext, runtimeCheckpoint *protocol.Checkpoint) error {
+	var err error
+	if t.fence.Checkpoint, err = runtimeCheckpoint.Marshal(); err != nil {
+		return fmt.Errorf("marshalling checkpoint: %w", err)
+	} else if err := updateFence(sctx, t.checkpoints, *t.fence); err != nil {
+		return err
+	} else if err := sctx.CommitTransaction(sctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
 }
 
 func (t *transactor) Destroy() {}
@@ -187,39 +258,50 @@ func (t *transactor) loadWorker(
 				return nil
 			}
 
-			if err := func() error { // Closure for the deferred cur.Close()
-				collection := t.bindings[batch.binding].collection
-				cur, err := collection.Find(ctx, bson.D{{
-					Key:   idField,
-					Value: bson.D{{Key: "$in", Value: batch.keys}},
-				}})
-				if err != nil {
-					return fmt.Errorf("finding document in collection: %w", err)
-				}
-				defer cur.Close(ctx)
-
-				for cur.Next(ctx) {
-					var doc bson.M
-					if err = cur.Decode(&doc); err != nil {
-						return fmt.Errorf("decoding document in collection %s: %w", collection.Name(), err)
-					}
-
-					js, err := json.Marshal(sanitizeDocument(doc))
-					if err != nil {
-						return fmt.Errorf("encoding document in collection %s as json: %w", collection.Name(), err)
-					} else if err := loaded(batch.binding, js); err != nil {
-						return fmt.Errorf("sending loaded: %w", err)
-					}
+			collection := t.bindings[batch.binding].collection
+			if err := findDocuments(ctx, collection, batch.keys, func(_ any, doc json.RawMessage) error {
+				if err := loaded(batch.binding, doc); err != nil {
+					return fmt.Errorf("sending loaded: %w", err)
 				}
-
 				return nil
-			}(); err != nil {
+			}); err != nil {
 				return err
 			}
 		}
 	}
 }
 
+// findDocuments finds the documents of the collection having one of the ids as their _id, and calls
+// fn with the _id and sanitized JSON of each document which is found. The ids are a slice of the
+// hex-encoded keys of loaded documents, or of any _id values when verifying documents.
+func findDocuments(ctx context.Context, collection *mongo.Collection, ids any, fn func(id any, doc json.RawMessage) error) error {
+	cur, err := collection.Find(ctx, bson.D{{
+		Key:   idField,
+		Value: bson.D{{Key: "$in", Value: ids}},
+	}})
+	if err != nil {
+		return fmt.Errorf("finding document in collection: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var doc bson.M
+		if err = cur.Decode(&doc); err != nil {
+			return fmt.Errorf("decoding document in collection %s: %w", collection.Name(), err)
+		}
+		id := doc[idField]
+
+		js, err := json.Marshal(sanitizeDocument(doc))
+		if err != nil {
+			return fmt.Errorf("encoding document in collection %s as json: %w", collection.Name(), err)
+		} else if err := fn(id, js); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
 type storeBatch struct {
 	binding int
 	models  []mongo.WriteModel
@@ -300,6 +382,9 @@ func sanitizeDocumentInner(doc map[string]interface{}) map[string]interface{} {
 			}
 		case map[string]interface{}:
 			doc[key] = sanitizeDocumentInner(v)
+		case primitive.M:
+			// Embedded documents are decoded as the type of their ancestor.
+			doc[key] = sanitizeDocumentInner(v)
 		}
 	}
 
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/sirupsen/logrus"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// verificationCollection holds the result of the latest verification run of each
+	// materialization.
+	verificationCollection = "flow_verification"
+
+	// At most this many mismatched, missing, and extra keys are reported for a binding.
+	maxReportedKeys = 100
+)
+
+// verification configures the verification of stored documents when the materialization is
+// applied.
+type verification struct {
+	SampleRate float64 `json:"sampleRate,omitempty" jsonschema:"title=Sample Rate,description=Fraction of the documents of each collection which are verified. Documents are sampled by their _id. Defaults to 1 which verifies every document."`
+}
+
+func (v verification) Validate() error {
+	if v.SampleRate < 0 || v.SampleRate > 1 {
+		return fmt.Errorf("verification 'sampleRate' must be between 0 and 1")
+	}
+	return nil
+}
+
+// verifier scans the stored documents of each binding, and reads them back through the same path
+// as loaded documents to check that they'd be loaded for their keys.
+type verifier struct {
+	db              *mongo.Database
+	materialization string
+	rate            float64
+	bindings        []verifyBinding
+}
+
+type verifyBinding struct {
+	collection     *mongo.Collection
+	flowCollection string
+	deltaUpdates   bool
+	// Locations of the key fields within documents.
+	keyPtrs []string
+}
+
+// verificationRun summarizes a run of the verifier. The summary of each run replaces that of the
+// previous run of the materialization in the verificationCollection.
+type verificationRun struct {
+	Materialization string                `bson:"_id"`
+	StartedAt       time.Time             `bson:"startedAt"`
+	CompletedAt     time.Time             `bson:"completedAt"`
+	SampleRate      float64               `bson:"sampleRate"`
+	Bindings        []*verificationResult `bson:"bindings"`
+}
+
+// verificationResult is the result of verifying the documents of a binding. Documents are
+// mismatched if their _id isn't the key of their key values, or if they don't have key values.
+// Keys are missing if no document is found for the key values of a mismatched document. Documents
+// are extra if their _id isn't one written by the materialization, such as documents written by
+// something else.
+type verificationResult struct {
+	Collection     string   `bson:"collection"`
+	FlowCollection string   `bson:"flowCollection"`
+	DeltaUpdates   bool     `bson:"deltaUpdates"`
+	Verified       int      `bson:"verified"`
+	Mismatched     int      `bson:"mismatched"`
+	Missing        int      `bson:"missing"`
+	Extra          int      `bson:"extra"`
+	MismatchedKeys []string `bson:"mismatchedKeys"`
+	MissingKeys    []string `bson:"missingKeys"`
+	ExtraKeys      []string `bson:"extraKeys"`
+}
+
+func newVerifier(db *mongo.Database, spec *pf.MaterializationSpec, cfg verification) (*verifier, error) {
+	var rate = cfg.SampleRate
+	if rate == 0 {
+		rate = 1
+	}
+
+	var bindings []verifyBinding
+	for _, b := range spec.Bindings {
+		res, err := resolveResourceConfig(b.ResourceConfigJson)
+		if err != nil {
+			return nil, err
+		}
+
+		var keyPtrs []string
+		for _, f := range b.FieldSelection.Keys {
+			p := b.Collection.GetProjection(f)
+			if p == nil {
+				return nil, fmt.Errorf("key field %q is not a projection of collection %q", f, b.Collection.Name)
+			}
+			keyPtrs = append(keyPtrs, p.Ptr)
+		}
+
+		bindings = append(bindings, verifyBinding{
+			collection:     db.Collection(res.Collection),
+			flowCollection: b.Collection.Name.String(),
+			deltaUpdates:   b.DeltaUpdates,
+			keyPtrs:        keyPtrs,
+		})
+	}
+
+	return &verifier{
+		db:              db,
+		materialization: spec.Name.String(),
+		rate:            rate,
+		bindings:        bindings,
+	}, nil
+}
+
+// sampled returns whether the document having the _id is verified. Documents are sampled by a hash
+// of their _id so that the same documents are verified by every run.
+func (v *verifier) sampled(id any) bool {
+	if v.rate >= 1 {
+		return true
+	}
+	var h = fnv.New64a()
+	fmt.Fprint(h, id)
+	return float64(h.Sum64())/math.MaxUint64 < v.rate
+}
+
+// run verifies the documents of every binding, logs the result of each, and writes the summary of
+// the run to the verificationCollection.
+func (v *verifier) run(ctx context.Context) (*verificationRun, error) {
+	var out = &verificationRun{
+		Materialization: v.materialization,
+		StartedAt:       time.Now().UTC(),
+		SampleRate:      v.rate,
+		Bindings:        []*verificationResult{},
+	}
+
+	for _, b := range v.bindings {
+		result, err := v.verifyBinding(ctx, b)
+		if err != nil {
+			return nil, fmt.Errorf("verifying collection %s: %w", b.collection.Name(), err)
+		}
+
+		var ll = logrus.WithFields(logrus.Fields{
+			"collection":     result.Collection,
+			"flowCollection": result.FlowCollection,
+			"verified":       result.Verified,
+			"mismatched":     result.Mismatched,
+			"missing":        result.Missing,
+			"extra":          result.Extra,
+		})
+		if result.Mismatched+result.Missing+result.Extra == 0 {
+			ll.Info("verified stored documents")
+		} else {
+			ll.WithFields(logrus.Fields{
+				"mismatchedKeys": result.MismatchedKeys,
+				"missingKeys":    result.MissingKeys,
+				"extraKeys":      result.ExtraKeys,
+			}).Warn("stored documents wouldn't be loaded for their keys")
+		}
+		out.Bindings = append(out.Bindings, result)
+	}
+	out.CompletedAt = time.Now().UTC()
+
+	if _, err := v.db.Collection(verificationCollection).ReplaceOne(
+		ctx,
+		bson.D{{Key: idField, Value: out.Materialization}},
+		out,
+		options.Replace().SetUpsert(true),
+	); err != nil {
+		return nil, fmt.Errorf("writing verification result: %w", err)
+	}
+
+	return out, nil
+}
+
+// verifyBinding scans the _id of every document of the binding's collection, and verifies the
+// sampled documents in batches like those of loaded documents.
+func (v *verifier) verifyBinding(ctx context.Context, b verifyBinding) (*verificationResult, error) {
+	var result = &verificationResult{
+		Collection:     b.collection.Name(),
+		FlowCollection: b.flowCollection,
+		DeltaUpdates:   b.deltaUpdates,
+		MismatchedKeys: []string{},
+		MissingKeys:    []string{},
+		ExtraKeys:      []string{},
+	}
+
+	cur, err := b.collection.Find(ctx, bson.D{}, options.Find().SetProjection(bson.D{{Key: idField, Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("scanning documents: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var batch bson.A
+	for cur.Next(ctx) {
+		var doc bson.M
+		if err := cur.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decoding document: %w", err)
+		}
+
+		var id = doc[idField]
+		if !v.sampled(id) {
+			continue
+		} else if !materializedID(id, b.deltaUpdates) {
+			result.Extra++
+			reportKey(&result.ExtraKeys, id)
+			continue
+		}
+
+		if batch = append(batch, id); len(batch) == loadBatchSize {
+			if err := verifyBatch(ctx, b, batch, result); err != nil {
+				return nil, err
+			}
+			batch = nil
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("scanning documents: %w", err)
+	}
+
+	if len(batch) != 0 {
+		if err := verifyBatch(ctx, b, batch, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// verifyBatch reads back the documents having the ids through findDocuments, and checks that their
+// key values are those of their _id. Documents deleted since they were scanned aren't verified.
+func verifyBatch(ctx context.Context, b verifyBinding, ids bson.A, result *verificationResult) error {
+	// Keys of the key values of mismatched documents, which should have been their _id.
+	var expected []string
+	var seen = make(map[string]bool)
+
+	if err := findDocuments(ctx, b.collection, ids, func(id any, doc json.RawMessage) error {
+		result.Verified++
+
+		key, err := documentKey(doc, b.keyPtrs)
+		if b.deltaUpdates || err != nil {
+			if err != nil {
+				result.Mismatched++
+				reportKey(&result.MismatchedKeys, id)
+			}
+			return nil
+		}
+
+		if packed := fmt.Sprintf("%x", key.Pack()); packed != id {
+			result.Mismatched++
+			reportKey(&result.MismatchedKeys, id)
+
+			if !seen[packed] {
+				expected = append(expected, packed)
+				seen[packed] = true
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if len(expected) == 0 {
+		return nil
+	}
+
+	var found = make(map[string]bool)
+	if err := findDocuments(ctx, b.collection, expected, func(id any, _ json.RawMessage) error {
+		found[fmt.Sprint(id)] = true
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, key := range expected {
+		if !found[key] {
+			result.Missing++
+			reportKey(&result.MissingKeys, key)
+		}
+	}
+
+	return nil
+}
+
+func reportKey(keys *[]string, id any) {
+	if len(*keys) < maxReportedKeys {
+		*keys = append(*keys, fmt.Sprint(id))
+	}
+}
+
+// materializedID returns whether the _id is one written by the materialization: a hex-encoded
+// packed key for standard updates, or an ObjectID generated by MongoDB for delta updates.
+func materializedID(id any, deltaUpdates bool) bool {
+	if deltaUpdates {
+		_, ok := id.(primitive.ObjectID)
+		return ok
+	}
+
+	s, ok := id.(string)
+	if !ok {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// documentKey returns the values of the document at the key locations, as they're packed into the
+// keys of loaded documents. Numbers of documents are stored as doubles, which are encoded as
+// integers when they have integer values.
+func documentKey(doc json.RawMessage, keyPtrs []string) (tuple.Tuple, error) {
+	var root any
+	var d = json.NewDecoder(bytes.NewReader(doc))
+	d.UseNumber()
+	if err := d.Decode(&root); err != nil {
+		return nil, fmt.Errorf("decoding document: %w", err)
+	}
+
+	var out tuple.Tuple
+	for _, ptr := range keyPtrs {
+		var value = root
+		for _, t := range pointerTokens(ptr) {
+			switch v := value.(type) {
+			case map[string]any:
+				var ok bool
+				if value, ok = v[t]; !ok {
+					return nil, fmt.Errorf("document has no key at %q", ptr)
+				}
+			case []any:
+				idx, err := strconv.Atoi(t)
+				if err != nil || idx < 0 || idx >= len(v) {
+					return nil, fmt.Errorf("document has no key at %q", ptr)
+				}
+				value = v[idx]
+			default:
+				return nil, fmt.Errorf("document has no key at %q", ptr)
+			}
+		}
+
+		switch v := value.(type) {
+		case nil, bool, string:
+			out = append(out, v)
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				out = append(out, i)
+			} else if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+				out = append(out, u)
+			} else if f, err := v.Float64(); err == nil {
+				out = append(out, f)
+			} else {
+				return nil, fmt.Errorf("invalid number %q at %q: %w", v, ptr, err)
+			}
+		default:
+			return nil, fmt.Errorf("key at %q is not a scalar value", ptr)
+		}
+	}
+
+	return out, nil
+}
+
+// description summarizes the run for the description of applied actions.
+func (r *verificationRun) description() string {
+	var verified, mismatched, missing, extra int
+	for _, b := range r.Bindings {
+		verified += b.Verified
+		mismatched += b.Mismatched
+		missing += b.Missing
+		extra += b.Extra
+	}
+
+	return fmt.Sprintf(
+		"verified %d stored documents of %d collections: %d mismatched, %d missing, and %d extra (see the %s collection)",
+		verified, len(r.Bindings), mismatched, missing, extra, verificationCollection,
+	)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestVerificationValidate(t *testing.T) {
+	require.NoError(t, verification{}.Validate())
+	require.NoError(t, verification{SampleRate: 0.5}.Validate())
+	require.ErrorContains(t, verification{SampleRate: 1.5}.Validate(), "must be between 0 and 1")
+	require.ErrorContains(t, verification{SampleRate: -1}.Validate(), "must be between 0 and 1")
+}
+
+func TestVerifierSampled(t *testing.T) {
+	var all = &verifier{rate: 1}
+	var half = &verifier{rate: 0.5}
+
+	var sampled int
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("%x", tuple.Tuple{fmt.Sprintf("key-%d", i)}.Pack())
+		require.True(t, all.sampled(id))
+		if half.sampled(id) {
+			sampled++
+		}
+		// Documents are sampled the same way by every run.
+		require.Equal(t, half.sampled(id), half.sampled(id))
+	}
+	require.InDelta(t, 500, sampled, 100)
+}
+
+func TestMaterializedID(t *testing.T) {
+	require.True(t, materializedID("0161", false))
+	require.False(t, materializedID("not-a-key", false))
+	require.False(t, materializedID(primitive.NewObjectID(), false))
+	require.True(t, materializedID(primitive.NewObjectID(), true))
+	require.False(t, materializedID("0161", true))
+}
+
+func TestDocumentKey(t *testing.T) {
+	var doc = json.RawMessage(`{"id":"a","n":3,"big":18446744073709551615,"f":1.5,"nested":{"list":[true,null]},"obj":{}}`)
+
+	key, err := documentKey(doc, []string{"/id", "/n", "/big", "/f", "/nested/list/0", "/nested/list/1"})
+	require.NoError(t, err)
+	require.Equal(t, tuple.Tuple{"a", int64(3), uint64(18446744073709551615), 1.5, true, nil}, key)
+
+	// The key of a stored document is that which it was stored under.
+	require.Equal(t, tuple.Tuple{"a", 3}.Pack(), tuple.Tuple{"a", int64(3)}.Pack())
+
+	for _, tt := range []struct {
+		ptr  string
+		want string
+	}{
+		{"/missing", "document has no key at \"/missing\""},
+		{"/nested/list/2", "document has no key at \"/nested/list/2\""},
+		{"/id/nested", "document has no key at \"/id/nested\""},
+		{"/obj", "key at \"/obj\" is not a scalar value"},
+	} {
+		_, err := documentKey(doc, []string{tt.ptr})
+		require.ErrorContains(t, err, tt.want)
+	}
+}
+
+func TestVerificationRunDescription(t *testing.T) {
+	var run = &verificationRun{Bindings: []*verificationResult{
+		{Verified: 10, Mismatched: 1, Extra: 2},
+		{Verified: 5, Missing: 1},
+	}}
+	require.Equal(t, "verified 15 stored documents of 2 collections: 1 mismatched, 1 missing, and 2 extra (see the flow_verification collection)", run.description())
+}
@@ -14,6 +14,7 @@ import (
 	sql "github.com/estuary/connectors/materialize-sql"
 	"github.com/estuary/flow/go/protocols/flow"
 	"github.com/go-sql-driver/mysql"
+	log "github.com/sirupsen/logrus"
 )
 
 type client struct {
@@ -178,8 +179,42 @@ func (c *client) InstallFence(ctx context.Context, checkpoints sql.Table, fence
 	return sql.StdInstallFence(ctx, c.db, checkpoints, fence, base64.StdEncoding.DecodeString)
 }
 
+// ExecStatements executes the statements in order through a single connection, like
+// sql.StdSQLExecStatements. MySQL has no IF NOT EXISTS for indexes, so a createIndex statement of
+// an index which already exists is not an error. This allows indexes to be created again after a
+// partial failure.
 func (c *client) ExecStatements(ctx context.Context, statements []string) error {
-	return sql.StdSQLExecStatements(ctx, c.db, statements)
+	var conn, err = c.db.Conn(ctx)
+	if err == nil {
+		err = conn.PingContext(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to DB: %w", err)
+	}
+	defer conn.Close()
+
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); isDuplicateKeyName(err) && isCreateIndex(statement) {
+			log.WithField("sql", statement).Info("index already exists")
+		} else if err != nil {
+			return fmt.Errorf("executing statement (%s): %w", statement, err)
+		} else {
+			log.WithField("sql", statement).Debug("executed statement")
+		}
+	}
+	return nil
+}
+
+// isDuplicateKeyName returns whether the error is ER_DUP_KEYNAME, which is returned when creating
+// an index that already exists.
+func isDuplicateKeyName(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1061
+}
+
+// isCreateIndex returns whether the statement was rendered by the createIndex template.
+func isCreateIndex(statement string) bool {
+	return strings.HasPrefix(statement, "CREATE INDEX ") || strings.HasPrefix(statement, "CREATE UNIQUE INDEX ")
 }
 
 func (c *client) FetchSpecAndVersion(ctx context.Context, specs sql.Table, materialization flow.Materialization) (string, string, error) {
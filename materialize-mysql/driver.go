@@ -64,6 +64,9 @@ type advancedConfig struct {
 	SSLServerCA   string `json:"ssl_server_ca,omitempty" jsonschema:"title=SSL Server CA,description=Optional server certificate authority to use when connecting with custom SSL mode." jsonschema_extras:"secret=true,multiline=true"`
 	SSLClientCert string `json:"ssl_client_cert,omitempty" jsonschema:"title=SSL Client Certificate,description=Optional client certificate to use when connecting with custom SSL mode." jsonschema_extras:"secret=true,multiline=true"`
 	SSLClientKey  string `json:"ssl_client_key,omitempty" jsonschema:"title=SSL Client Key,description=Optional client key to use when connecting with custom SSL mode." jsonschema_extras:"secret=true,multiline=true"`
+
+	AuditSchemaChanges bool `json:"audit_schema_changes,omitempty" jsonschema:"title=Audit Schema Changes,description=Record every table creation and alteration in a flow_schema_changes table alongside the materialization metadata tables."`
+	LoadCacheSizeMB    int  `json:"load_cache_size_mb,omitempty" jsonschema:"title=Load Cache Size (MB),description=Size of an in-memory cache of recently stored documents which is used to avoid querying the database for loads of frequently updated keys. Disabled if not set."`
 }
 
 // Validate the configuration.
@@ -96,6 +99,10 @@ func (c *config) Validate() error {
 		return fmt.Errorf("ssl_server_ca is required when using `verify_ca` and `verify_identity` modes")
 	}
 
+	if c.Advanced.LoadCacheSizeMB < 0 {
+		return fmt.Errorf("invalid 'load_cache_size_mb' configuration: must not be negative")
+	}
+
 	return nil
 }
 
@@ -198,6 +205,9 @@ func (c *config) ToURI() string {
 type tableConfig struct {
 	Table string `json:"table" jsonschema:"title=Table,description=Name of the database table" jsonschema_extras:"x-collection-name=true"`
 	Delta bool   `json:"delta_updates,omitempty" jsonschema:"default=false,title=Delta Update,description=Should updates to this table be done via delta updates. Default is false."`
+
+	Indexes   []sql.TableIndex `json:"indexes,omitempty" jsonschema:"title=Indexes,description=Secondary indexes to create on the table. Text and binary columns are indexed by a prefix of their values."`
+	Retention *sql.Retention   `json:"retention,omitempty" jsonschema:"title=Retention,description=Remove rows from the table once they are older than a number of days."`
 }
 
 func newTableConfig(ep *sql.Endpoint) sql.Resource {
@@ -209,6 +219,11 @@ func (r tableConfig) Validate() error {
 	if r.Table == "" {
 		return fmt.Errorf("missing table")
 	}
+	if r.Retention != nil {
+		if err := r.Retention.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -220,6 +235,10 @@ func (c tableConfig) DeltaUpdates() bool {
 	return c.Delta
 }
 
+func (c tableConfig) TableOptions() sql.TableOptions {
+	return sql.TableOptions{Indexes: c.Indexes, Retention: c.Retention}
+}
+
 func newMysqlDriver() *sql.Driver {
 	return &sql.Driver{
 		DocumentationURL: "https://go.estuary.dev/materialize-mysql",
@@ -240,6 +259,12 @@ func newMysqlDriver() *sql.Driver {
 			var metaBase sql.TablePath
 			var metaSpecs, metaCheckpoints = sql.MetaTables(metaBase)
 
+			var metaSchemaChanges *sql.TableShape
+			if cfg.Advanced.AuditSchemaChanges {
+				var t = sql.FlowSchemaChangesTable(append(metaBase, sql.DefaultFlowSchemaChanges)...)
+				metaSchemaChanges = &t
+			}
+
 			// If SSH Endpoint is configured, then try to start a tunnel before establishing connections
 			if cfg.NetworkTunnel != nil && cfg.NetworkTunnel.SshForwarding != nil && cfg.NetworkTunnel.SshForwarding.SshEndpoint != "" {
 				host, port, err := net.SplitHostPort(cfg.Address)
@@ -315,17 +340,20 @@ func newMysqlDriver() *sql.Driver {
 			var templates = renderTemplates(dialect)
 
 			return &sql.Endpoint{
-				Config:               cfg,
-				Dialect:              dialect,
-				MetaSpecs:            &metaSpecs,
-				MetaCheckpoints:      &metaCheckpoints,
-				NewClient:            newClient,
-				CreateTableTemplate:  templates["createTargetTable"],
-				ReplaceTableTemplate: templates["replaceTargetTable"],
-				NewResource:          newTableConfig,
-				NewTransactor:        prepareNewTransactor(dialect, templates),
-				Tenant:               tenant,
-				ConcurrentApply:      false,
+				Config:                cfg,
+				Dialect:               dialect,
+				MetaSpecs:             &metaSpecs,
+				MetaCheckpoints:       &metaCheckpoints,
+				MetaSchemaChanges:     metaSchemaChanges,
+				NewClient:             newClient,
+				CreateTableTemplate:   templates["createTargetTable"],
+				ReplaceTableTemplate:  templates["replaceTargetTable"],
+				CreateIndexTemplate:   templates["createIndex"],
+				DeleteExpiredTemplate: templates["deleteExpired"],
+				NewResource:           newTableConfig,
+				NewTransactor:         prepareNewTransactor(dialect, templates),
+				Tenant:                tenant,
+				ConcurrentApply:       false,
 			}, nil
 		},
 	}
@@ -361,11 +389,29 @@ type transactor struct {
 		conn  *stdsql.Conn
 		fence sql.Fence
 	}
-	bindings []*binding
+	bindings  []*binding
+	retention *sql.RetentionEnforcer
+	cache     *sql.LoadCache
 }
 
-func (t *transactor) UnmarshalState(state json.RawMessage) error                  { return nil }
-func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) { return nil, nil }
+func (t *transactor) UnmarshalState(state json.RawMessage) error { return nil }
+
+// Acknowledge caches the documents of the committed transaction, and removes expired rows from
+// tables having a retention.
+func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) {
+	t.cache.Committed()
+
+	if err := t.retention.Enforce(ctx, func(ctx context.Context, statement string) (int64, error) {
+		res, err := t.store.conn.ExecContext(ctx, statement)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
 
 func prepareNewTransactor(
 	dialect sql.Dialect,
@@ -382,6 +428,8 @@ func prepareNewTransactor(
 		d.store.fence = fence
 
 		var cfg = ep.Config.(*config)
+		d.cache = sql.NewLoadCache(cfg.Advanced.LoadCacheSizeMB * 1024 * 1024)
+
 		// Establish connections.
 		if db, err := stdsql.Open("mysql", cfg.ToURI()); err != nil {
 			return nil, fmt.Errorf("load sql.Open: %w", err)
@@ -422,6 +470,10 @@ func prepareNewTransactor(
 		}
 		d.load.unionSQL = strings.Join(subqueries, "\nUNION ALL\n") + ";"
 
+		if d.retention, err = sql.NewRetentionEnforcer(bindings, templates["deleteExpired"], deleteExpiredBatchRows); err != nil {
+			return nil, err
+		}
+
 		return d, nil
 	}
 }
@@ -644,10 +696,23 @@ func (d *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage)
 	}
 	defer txn.Rollback()
 
+	type cachedDoc struct {
+		binding int
+		doc     json.RawMessage
+	}
+	var cached []cachedDoc
+	var misses int
+
 	var batches = make(map[int]batchMeta)
 	for it.Next() {
 		var b = d.bindings[it.Binding]
 
+		if doc, ok := d.cache.Get(it.Binding, it.PackedKey); ok {
+			cached = append(cached, cachedDoc{it.Binding, doc})
+			continue
+		}
+		misses++
+
 		if converted, err := b.target.ConvertKey(it.Key); err != nil {
 			return fmt.Errorf("converting Load key: %w", err)
 		} else {
@@ -705,6 +770,20 @@ func (d *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage)
 		return it.Err()
 	}
 
+	// Cached documents may only be sent once iteration has completed, and the prior transaction
+	// has been acknowledged.
+	for _, c := range cached {
+		if err := loaded(c.binding, c.doc); err != nil {
+			return err
+		}
+	}
+	if len(cached) > 0 {
+		log.WithFields(log.Fields{"hits": len(cached), "misses": misses}).Debug("loaded documents from cache")
+	}
+	if misses == 0 {
+		return nil
+	}
+
 	// Issue a union join of the target tables and their (now staged) load keys,
 	// and send results to the |loaded| callback.
 	rows, err := txn.QueryContext(ctx, d.load.unionSQL)
@@ -790,6 +869,10 @@ func (d *transactor) Store(it *m.StoreIterator) (_ m.StartCommitFunc, err error)
 
 		var b = d.bindings[it.Binding]
 
+		if !b.target.DeltaUpdates {
+			d.cache.Stored(it.Binding, it.PackedKey, it.RawJSON)
+		}
+
 		converted, err := b.target.ConvertAll(it.Key, it.Values, it.RawJSON)
 		if err != nil {
 			return nil, fmt.Errorf("converting store parameters: %w", err)
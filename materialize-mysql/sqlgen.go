@@ -119,6 +119,9 @@ func rfc3339TimeToTZ(loc *time.Location) sql.ElementConverter {
 	})
 }
 
+// deleteExpiredBatchRows is the LIMIT of rows deleted by a deleteExpired statement.
+const deleteExpiredBatchRows = 10000
+
 func renderTemplates(dialect sql.Dialect) map[string]*template.Template {
 	var tplAll = sql.MustParseTemplate(dialect, "root", `
 {{ define "temp_load_name" -}}
@@ -178,6 +181,35 @@ ALTER TABLE {{$.Identifier}}
 {{- end }};
 {{ end }}
 
+-- Templated creation of a secondary index of a materialized table. MySQL does
+-- not support IF NOT EXISTS for indexes, so this is only executed for indexes
+-- which are new to the resource, and the client ignores an index which already
+-- exists after a partial failure of a previous apply. TEXT and BLOB columns can
+-- only be indexed by a prefix of their values. Indexes added to an existing
+-- table are built in-place without locking the table.
+
+{{ define "createIndex" -}}
+CREATE {{ if $.Unique }}UNIQUE {{ end }}INDEX {{$.Identifier}} ON {{$.TableIdentifier}} (
+{{- range $ind, $col := $.Columns }}
+	{{- if $ind }}, {{ end -}}
+	{{$col.Identifier}}
+	{{- if or (Contains $col.DDL "TEXT") (Contains $col.DDL "BLOB") }}(255){{ end }}
+{{- end -}}
+)
+{{- if $.Concurrently }} ALGORITHM=INPLACE LOCK=NONE{{ end }};
+{{- end }}
+
+-- Templated deletion of a batch of rows which are older than the retention of
+-- a materialized table. Batches are bounded so that each deletion is brief.
+-- DATETIME values are materialized in the database's time zone, which is also
+-- the time zone of NOW().
+
+{{ define "deleteExpired" -}}
+DELETE FROM {{$.Identifier}}
+WHERE {{$.RetentionColumn.Identifier}} < NOW(6) - INTERVAL {{$.Options.Retention.Days}} DAY
+LIMIT {{$.BatchRows}};
+{{- end }}
+
 -- Templated creation of a temporary load table:
 
 {{ define "createLoadTable" }}
@@ -365,6 +397,8 @@ UPDATE {{ Identifier $.TablePath }}
 		"createTargetTable":  tplAll.Lookup("createTargetTable"),
 		"replaceTargetTable": tplAll.Lookup("replaceTargetTable"),
 		"alterTableColumns":  tplAll.Lookup("alterTableColumns"),
+		"createIndex":        tplAll.Lookup("createIndex"),
+		"deleteExpired":      tplAll.Lookup("deleteExpired"),
 		"updateLoad":         tplAll.Lookup("updateLoad"),
 		"updateReplace":      tplAll.Lookup("updateReplace"),
 		"updateTruncate":     tplAll.Lookup("truncateUpdateTable"),
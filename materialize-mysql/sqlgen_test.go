@@ -110,6 +110,39 @@ func TestSQLGeneration(t *testing.T) {
 		snap.WriteString("--- End " + testcase.name + " ---\n\n")
 	}
 
+	var shapeWithOptions = sqlDriver.BuildTableShape(spec, 0, tableConfig{
+		Table: "target_table",
+		Indexes: []sqlDriver.TableIndex{
+			{Fields: []string{"lower_case", "value"}},
+			{Fields: []string{"key1", "a Time"}, Unique: true},
+		},
+		Retention: &sqlDriver.Retention{Field: "a Time", Days: 30},
+	})
+	tableWithOptions, err := sqlDriver.ResolveTable(shapeWithOptions, testDialect)
+	require.NoError(t, err)
+
+	for _, concurrently := range []bool{false, true} {
+		for _, idx := range tableWithOptions.Indexes {
+			idx.Concurrently = concurrently
+			var testcase = idx.Name + " createIndex"
+			if concurrently {
+				testcase += " (concurrently)"
+			}
+
+			var rendered strings.Builder
+			require.NoError(t, templates["createIndex"].Execute(&rendered, &idx))
+			require.True(t, isCreateIndex(rendered.String()))
+
+			snap.WriteString("--- Begin " + testcase + " ---\n")
+			snap.WriteString(rendered.String())
+			snap.WriteString("\n--- End " + testcase + " ---\n\n")
+		}
+	}
+
+	snap.WriteString("--- Begin " + tableWithOptions.Identifier + " deleteExpired ---\n")
+	require.NoError(t, templates["deleteExpired"].Execute(&snap, &sqlDriver.DeleteExpiredParams{Table: tableWithOptions, BatchRows: deleteExpiredBatchRows}))
+	snap.WriteString("\n--- End " + tableWithOptions.Identifier + " deleteExpired ---\n\n")
+
 	var fence = sqlDriver.Fence{
 		TablePath:       sqlDriver.TablePath{"path", "To", "checkpoints"},
 		Checkpoint:      []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
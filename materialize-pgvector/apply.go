@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type pgvectorApplier struct {
+	pool *pgxpool.Pool
+	// Dimensions of the embeddings of the embedding provider.
+	dimensions int
+	// Schema of the table which materialization specs are persisted to.
+	schema string
+}
+
+var _ boilerplate.Applier = (*pgvectorApplier)(nil)
+
+// CreateMetaTables creates the pgvector extension and the table of materialization specs, if they
+// aren't already created.
+func (a *pgvectorApplier) CreateMetaTables(ctx context.Context, spec *pf.MaterializationSpec) (string, boilerplate.ActionApplyFn, error) {
+	var extensionExists, specsExist bool
+	if err := a.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'vector')").Scan(&extensionExists); err != nil {
+		return "", nil, fmt.Errorf("querying extensions: %w", err)
+	} else if err := a.pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", a.specsTable()).Scan(&specsExist); err != nil {
+		return "", nil, fmt.Errorf("querying table %s: %w", a.specsTable(), err)
+	}
+
+	var statements []string
+	if !extensionExists {
+		statements = append(statements, createExtensionSQL)
+	}
+	if !specsExist {
+		statements = append(statements, createSpecsTableSQL(a.specsTable()))
+	}
+	if len(statements) == 0 {
+		return "", nil, nil
+	}
+
+	return strings.Join(statements, "\n"), func(ctx context.Context) error {
+		for _, statement := range statements {
+			if _, err := a.pool.Exec(ctx, statement); err != nil {
+				return fmt.Errorf("executing %q: %w", statement, err)
+			}
+		}
+		return nil
+	}, nil
+}
+
+// LoadSpec loads the persisted spec of the materialization, which is nil if it hasn't been
+// persisted.
+func (a *pgvectorApplier) LoadSpec(ctx context.Context, materialization pf.Materialization) (*pf.MaterializationSpec, error) {
+	var exists bool
+	if err := a.pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", a.specsTable()).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("querying table %s: %w", a.specsTable(), err)
+	} else if !exists {
+		return nil, nil
+	}
+
+	var encoded string
+	err := a.pool.QueryRow(ctx,
+		fmt.Sprintf("SELECT spec FROM %s WHERE materialization = $1", a.specsTable()),
+		materialization.String(),
+	).Scan(&encoded)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("querying spec: %w", err)
+	}
+
+	specBytes, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding spec: %w", err)
+	}
+	spec := &pf.MaterializationSpec{}
+	if err := spec.Unmarshal(specBytes); err != nil {
+		return nil, fmt.Errorf("unmarshalling spec: %w", err)
+	}
+	return spec, nil
+}
+
+func (a *pgvectorApplier) PutSpec(ctx context.Context, spec *pf.MaterializationSpec, version string, exists bool) (string, boilerplate.ActionApplyFn, error) {
+	specBytes, err := spec.Marshal()
+	if err != nil {
+		panic(err) // Cannot fail to marshal.
+	}
+
+	putSpec := fmt.Sprintf(`INSERT INTO %s (materialization, version, spec) VALUES ($1, $2, $3)
+ON CONFLICT (materialization) DO UPDATE SET version = EXCLUDED.version, spec = EXCLUDED.spec;`, a.specsTable())
+
+	return fmt.Sprintf("update stored materialization spec and set version = %s", version), func(ctx context.Context) error {
+		if _, err := a.pool.Exec(ctx, putSpec, spec.Name.String(), version, base64.StdEncoding.EncodeToString(specBytes)); err != nil {
+			return fmt.Errorf("storing spec: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+func (a *pgvectorApplier) CreateResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int) (string, boilerplate.ActionApplyFn, error) {
+	createTable := createTableSQL(spec.Bindings[bindingIndex].ResourcePath, a.dimensions)
+
+	return createTable, func(ctx context.Context) error {
+		if _, err := a.pool.Exec(ctx, createTable); err != nil {
+			return fmt.Errorf("creating table: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// ReplaceResource replaces the table with an empty one, which is re-populated by the backfill of
+// its binding.
+func (a *pgvectorApplier) ReplaceResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int) (string, boilerplate.ActionApplyFn, error) {
+	path := spec.Bindings[bindingIndex].ResourcePath
+	dropTable := fmt.Sprintf("DROP TABLE IF EXISTS %s;", tableIdentifier(path))
+	createTable := createTableSQL(path, a.dimensions)
+
+	return dropTable + "\n" + createTable, func(ctx context.Context) error {
+		return a.pool.BeginFunc(ctx, func(txn pgx.Tx) error {
+			if _, err := txn.Exec(ctx, dropTable); err != nil {
+				return fmt.Errorf("dropping table: %w", err)
+			} else if _, err := txn.Exec(ctx, createTable); err != nil {
+				return fmt.Errorf("creating table: %w", err)
+			}
+			return nil
+		})
+	}, nil
+}
+
+// UpdateResource is a no-op, since the columns of tables don't depend on the field selection of
+// their binding.
+func (a *pgvectorApplier) UpdateResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int, bindingUpdate boilerplate.BindingUpdate) (string, boilerplate.ActionApplyFn, error) {
+	return "", nil, nil
+}
+
+const createExtensionSQL = "CREATE EXTENSION IF NOT EXISTS vector;"
+
+// specsTableName is the name of the table which materialization specs are persisted to, which is
+// the same as that of the SQL materializations.
+const specsTableName = "flow_materializations_v2"
+
+// specsTable returns the quoted identifier of the table of materialization specs.
+func (a *pgvectorApplier) specsTable() string {
+	return tableIdentifier([]string{a.schema, specsTableName})
+}
+
+// createSpecsTableSQL returns the statement which creates the table of materialization specs.
+func createSpecsTableSQL(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	materialization TEXT PRIMARY KEY NOT NULL,
+	version TEXT NOT NULL,
+	spec TEXT NOT NULL
+);`, table)
+}
+
+// tableIdentifier returns the quoted identifier of the table at the path.
+func tableIdentifier(path []string) string {
+	return pgx.Identifier(path).Sanitize()
+}
+
+// createTableSQL returns the statement which creates the table of vectors at the path, with
+// embeddings of the dimensions.
+func createTableSQL(path []string, dimensions int) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT PRIMARY KEY NOT NULL,
+	embedding vector(%d) NOT NULL,
+	metadata JSONB,
+	document JSONB
+);`, tableIdentifier(path), dimensions)
+}
+
+// tableDimensions returns the dimensions of the embedding column of the table at the path, or 0 if
+// the table does not exist.
+func tableDimensions(ctx context.Context, pool *pgxpool.Pool, path []string) (int, error) {
+	var typ string
+	err := pool.QueryRow(ctx, `
+		SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attname = 'embedding' AND NOT attisdropped`,
+		tableIdentifier(path),
+	).Scan(&typ)
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("querying embedding column of table %s: %w", tableIdentifier(path), err)
+	}
+
+	dimensions, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(typ, "vector("), ")"))
+	if err != nil {
+		return 0, fmt.Errorf("embedding column of table %s has type %q, which is not a vector", tableIdentifier(path), typ)
+	}
+	return dimensions, nil
+}
+
+// infoSchema reports the tables of the bindings which already exist, along with their columns.
+func infoSchema(ctx context.Context, pool *pgxpool.Pool, bindings []*pf.MaterializationSpec_Binding) (*boilerplate.InfoSchema, error) {
+	is := boilerplate.NewInfoSchema(
+		func(rp []string) []string { return rp },
+		func(f string) string { return f },
+	)
+
+	var schemas []string
+	for _, b := range bindings {
+		schemas = append(schemas, b.ResourcePath[0])
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT table_schema, table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema = ANY($1)`,
+		schemas,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying columns: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schema, table, column string
+		if err := rows.Scan(&schema, &table, &column); err != nil {
+			return nil, fmt.Errorf("scanning column: %w", err)
+		}
+		// The columns of tables are not materialized fields, so they are reported as nullable to
+		// avoid them being altered.
+		is.PushField(boilerplate.EndpointField{Name: column, Nullable: true}, schema, table)
+	}
+
+	return is, rows.Err()
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	m "github.com/estuary/connectors/go/protocols/materialize"
+	schemagen "github.com/estuary/connectors/go/schema-gen"
+	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
+	"github.com/estuary/connectors/materialize-postgres/connection"
+	vector "github.com/estuary/connectors/materialize-vector"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	pm "github.com/estuary/flow/go/protocols/materialize"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+)
+
+// TODO: These may need tuning based on real-world use.
+var (
+	upsertBatchSize   = 100
+	deleteBatchSize   = 1000
+	concurrentWorkers = 5
+)
+
+type config struct {
+	Address   string                 `json:"address" jsonschema:"title=Address,description=Host and port of the database (in the form of host[:port]). Port 5432 is used as the default if no specific port is provided." jsonschema_extras:"order=0"`
+	User      string                 `json:"user" jsonschema:"title=User,description=Database user to connect as." jsonschema_extras:"order=1"`
+	Password  string                 `json:"password" jsonschema:"title=Password,description=Password for the specified database user." jsonschema_extras:"secret=true,order=2"`
+	Database  string                 `json:"database,omitempty" jsonschema:"title=Database,description=Name of the logical database to materialize to." jsonschema_extras:"order=3"`
+	Schema    string                 `json:"schema,omitempty" jsonschema:"title=Database Schema,default=public,description=Database schema for bound collection tables (unless overridden within the binding resource configuration)." jsonschema_extras:"order=4"`
+	Embedding vector.EmbeddingConfig `json:"embedding" jsonschema:"title=Embeddings,description=Service used to generate the embeddings of documents." jsonschema_extras:"order=5"`
+	Advanced  advancedConfig         `json:"advanced,omitempty" jsonschema:"title=Advanced Options,description=Options for advanced users. You should not typically need to modify these." jsonschema_extras:"advanced=true"`
+
+	NetworkTunnel *connection.TunnelConfig `json:"networkTunnel,omitempty" jsonschema:"title=Network Tunnel,description=Connect to your system through an SSH server that acts as a bastion host for your network."`
+}
+
+type advancedConfig struct {
+	SSLMode   string `json:"sslmode,omitempty" jsonschema:"title=SSL Mode,enum=disable,enum=allow,enum=prefer,enum=require,enum=verify-ca,enum=verify-full"`
+	OpenAiOrg string `json:"openAiOrg,omitempty" jsonschema:"title=OpenAI Organization"`
+	vector.ChunkingConfig
+}
+
+func (advancedConfig) GetFieldDocString(fieldName string) string {
+	switch fieldName {
+	case "SSLMode":
+		return "Overrides SSL connection behavior by setting the 'sslmode' parameter."
+	case "OpenAiOrg":
+		return "Optional organization name for OpenAI requests. Use this if you belong to multiple organizations to specify which organization is used for API requests."
+	default:
+		return vector.ChunkingConfig{}.GetFieldDocString(fieldName)
+	}
+}
+
+func (c *config) Validate() error {
+	var requiredProperties = [][]string{
+		{"address", c.Address},
+		{"user", c.User},
+		{"password", c.Password},
+	}
+	for _, req := range requiredProperties {
+		if req[1] == "" {
+			return fmt.Errorf("missing '%s'", req[0])
+		}
+	}
+
+	if err := connection.ValidateSSLMode(c.Advanced.SSLMode); err != nil {
+		return err
+	} else if err := c.Embedding.Validate(); err != nil {
+		return err
+	}
+	return c.Advanced.ChunkingConfig.Validate()
+}
+
+func (c *config) connection() connection.Config {
+	return connection.Config{
+		Address:       c.Address,
+		User:          c.User,
+		Password:      c.Password,
+		Database:      c.Database,
+		SSLMode:       c.Advanced.SSLMode,
+		NetworkTunnel: c.NetworkTunnel,
+	}
+}
+
+func (c *config) schema() string {
+	if c.Schema == "" {
+		return "public"
+	}
+	return c.Schema
+}
+
+func (c *config) embedder() vector.Embedder {
+	return c.Embedding.Embedder(c.Advanced.OpenAiOrg)
+}
+
+// pool starts the network tunnel to the database, if one is configured, and connects to it.
+func (c *config) pool(ctx context.Context) (*pgxpool.Pool, error) {
+	if err := c.connection().StartTunnel(); err != nil {
+		return nil, err
+	}
+
+	pool, err := pgxpool.Connect(ctx, c.connection().URI())
+	if err != nil {
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	return pool, nil
+}
+
+type resource struct {
+	Table          string   `json:"table" jsonschema:"title=Table,description=Name of the database table." jsonschema_extras:"x-collection-name=true"`
+	Schema         string   `json:"schema,omitempty" jsonschema:"title=Alternative Schema,description=Alternative schema for this table (optional)."`
+	MetadataFields []string `json:"metadataFields,omitempty" jsonschema:"title=Metadata Fields,description=Fields which are included in the metadata column of the table."`
+	vector.InputConfig
+}
+
+func (r resource) Validate() error {
+	if r.Table == "" {
+		return fmt.Errorf("missing table")
+	}
+	return r.InputConfig.Validate()
+}
+
+// path is the schema and name of the table of the resource.
+func (r resource) path(cfg config) []string {
+	if r.Schema != "" {
+		return []string{r.Schema, r.Table}
+	}
+	return []string{cfg.schema(), r.Table}
+}
+
+type driver struct{}
+
+func (driver) Spec(ctx context.Context, req *pm.Request_Spec) (*pm.Response_Spec, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("validating request: %w", err)
+	}
+
+	endpointSchema, err := schemagen.GenerateSchema("Materialize pgvector Spec", &config{}).MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("generating endpoint schema: %w", err)
+	}
+
+	resourceSchema, err := schemagen.GenerateSchema("pgvector Table", &resource{}).MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("generating resource schema: %w", err)
+	}
+
+	return &pm.Response_Spec{
+		ConfigSchemaJson:         json.RawMessage(endpointSchema),
+		ResourceConfigSchemaJson: json.RawMessage(resourceSchema),
+		DocumentationUrl:         "https://go.estuary.dev/materialize-pgvector",
+	}, nil
+}
+
+func (driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Response_Validated, error) {
+	cfg, err := resolveEndpointConfig(req.ConfigJson)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := cfg.pool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Close()
+
+	var available bool
+	if err := pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'vector')").Scan(&available); err != nil {
+		return nil, fmt.Errorf("querying available extensions: %w", err)
+	} else if !available {
+		return nil, fmt.Errorf("the pgvector extension 'vector' is not available in database %q", cfg.Database)
+	}
+
+	dimensions, err := vector.Dimensions(ctx, cfg.embedder())
+	if err != nil {
+		return nil, fmt.Errorf("verifying embedding provider %s: %w", cfg.Embedding.Provider(), err)
+	}
+
+	var out []*pm.Response_Validated_Binding
+	for _, b := range req.Bindings {
+		res, err := resolveResourceConfig(b.ResourceConfigJson)
+		if err != nil {
+			return nil, err
+		}
+		path := res.path(cfg)
+
+		// Existing tables must have embeddings of the same dimensions as the embedding provider.
+		if existing, err := tableDimensions(ctx, pool, path); err != nil {
+			return nil, err
+		} else if existing != 0 && existing != dimensions {
+			return nil, fmt.Errorf(
+				"table %s has embeddings with dimensions of %d but embeddings of provider '%s' have dimensions of %d",
+				tableIdentifier(path),
+				existing,
+				cfg.Embedding.Provider(),
+				dimensions,
+			)
+		}
+
+		constraints, err := vector.Constraints(b.Collection, res.InputConfig, res.MetadataFields)
+		if err != nil {
+			return nil, fmt.Errorf("validating binding for table %s: %w", tableIdentifier(path), err)
+		}
+
+		out = append(out, &pm.Response_Validated_Binding{
+			Constraints:  constraints,
+			DeltaUpdates: true,
+			ResourcePath: path,
+		})
+	}
+
+	return &pm.Response_Validated{Bindings: out}, nil
+}
+
+func (driver) Apply(ctx context.Context, req *pm.Request_Apply) (*pm.Response_Applied, error) {
+	cfg, err := resolveEndpointConfig(req.Materialization.ConfigJson)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := cfg.pool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Close()
+
+	dimensions, err := vector.Dimensions(ctx, cfg.embedder())
+	if err != nil {
+		return nil, fmt.Errorf("verifying embedding provider %s: %w", cfg.Embedding.Provider(), err)
+	}
+
+	is, err := infoSchema(ctx, pool, req.Materialization.Bindings)
+	if err != nil {
+		return nil, err
+	}
+
+	return boilerplate.ApplyChanges(ctx, req, &pgvectorApplier{pool: pool, dimensions: dimensions, schema: cfg.schema()}, is, false)
+}
+
+func (driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Transactor, *pm.Response_Opened, error) {
+	cfg, err := resolveEndpointConfig(open.Materialization.ConfigJson)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var bindings []*vector.Binding
+	var tables []string
+	for _, b := range open.Materialization.Bindings {
+		res, err := resolveResourceConfig(b.ResourceConfigJson)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields := append(append([]string{}, b.FieldSelection.Keys...), b.FieldSelection.Values...)
+
+		input, err := vector.NewInputBuilder(res.InputConfig, fields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("building input for table %s: %w", tableIdentifier(b.ResourcePath), err)
+		}
+		metadata, err := vector.NewMetadata(fields, res.MetadataFields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("building metadata for table %s: %w", tableIdentifier(b.ResourcePath), err)
+		}
+
+		bindings = append(bindings, &vector.Binding{
+			Name:            tableIdentifier(b.ResourcePath),
+			Input:           input,
+			Metadata:        metadata,
+			IncludeDocument: true,
+		})
+		tables = append(tables, tableIdentifier(b.ResourcePath))
+	}
+
+	pool, err := cfg.pool(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"address":  cfg.Address,
+		"database": cfg.Database,
+	}).Info("starting materialize-pgvector")
+
+	return vector.NewTransactor(vector.TransactorConfig{
+		Store:           &store{pool: pool, tables: tables},
+		Embedder:        cfg.embedder(),
+		Bindings:        bindings,
+		Chunking:        cfg.Advanced.ChunkingConfig,
+		UpsertBatchSize: upsertBatchSize,
+		DeleteBatchSize: deleteBatchSize,
+		Concurrency:     concurrentWorkers,
+	}), &pm.Response_Opened{}, nil
+}
+
+func resolveEndpointConfig(specJson json.RawMessage) (config, error) {
+	var cfg = config{}
+	if err := pf.UnmarshalStrict(specJson, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing endpoint config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func resolveResourceConfig(specJson json.RawMessage) (resource, error) {
+	var res = resource{}
+	if err := pf.UnmarshalStrict(specJson, &res); err != nil {
+		return res, fmt.Errorf("parsing resource config: %w", err)
+	}
+
+	return res, nil
+}
+
+func main() {
+	boilerplate.RunMain(driver{})
+}
@@ -0,0 +1,117 @@
+//go:build !nodb
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	vector "github.com/estuary/connectors/materialize-vector"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() config {
+	return config{
+		Address:  "localhost:5432",
+		User:     "flow",
+		Password: "flow",
+		Database: "flow",
+	}
+}
+
+func TestApplyAndStore(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+
+	pool, err := cfg.pool(ctx)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	path := []string{"public", "test_pgvector"}
+	dropTable := "DROP TABLE IF EXISTS " + tableIdentifier(path)
+	_, err = pool.Exec(ctx, dropTable)
+	require.NoError(t, err)
+	t.Cleanup(func() { pool.Exec(ctx, dropTable) })
+
+	spec := &pf.MaterializationSpec{
+		Name:     "test/pgvector",
+		Bindings: []*pf.MaterializationSpec_Binding{{ResourcePath: path, Backfill: 1}},
+	}
+	applier := &pgvectorApplier{pool: pool, dimensions: 3, schema: "public"}
+	deleteSpec := "DELETE FROM " + applier.specsTable() + " WHERE materialization = 'test/pgvector'"
+	t.Cleanup(func() { pool.Exec(ctx, deleteSpec) })
+
+	_, action, err := applier.CreateMetaTables(ctx, spec)
+	require.NoError(t, err)
+	if action != nil {
+		require.NoError(t, action(ctx))
+	}
+	_, err = pool.Exec(ctx, deleteSpec)
+	require.NoError(t, err)
+
+	// The spec is persisted, so that the backfill counters of bindings are known.
+	stored, err := applier.LoadSpec(ctx, spec.Name)
+	require.NoError(t, err)
+	require.Nil(t, stored)
+
+	_, action, err = applier.PutSpec(ctx, spec, "v1", false)
+	require.NoError(t, err)
+	require.NoError(t, action(ctx))
+	_, action, err = applier.PutSpec(ctx, spec, "v2", true)
+	require.NoError(t, err)
+	require.NoError(t, action(ctx))
+
+	stored, err = applier.LoadSpec(ctx, spec.Name)
+	require.NoError(t, err)
+	require.Equal(t, spec, stored)
+
+	// The table is created with embeddings of the dimensions of the embedding provider.
+	dimensions, err := tableDimensions(ctx, pool, path)
+	require.NoError(t, err)
+	require.Equal(t, 0, dimensions)
+
+	_, action, err = applier.CreateResource(ctx, spec, 0)
+	require.NoError(t, err)
+	require.NoError(t, action(ctx))
+
+	dimensions, err = tableDimensions(ctx, pool, path)
+	require.NoError(t, err)
+	require.Equal(t, 3, dimensions)
+
+	is, err := infoSchema(ctx, pool, spec.Bindings)
+	require.NoError(t, err)
+	require.True(t, is.HasResource(path))
+
+	s := &store{pool: pool, tables: []string{tableIdentifier(path)}}
+	require.NoError(t, s.Upsert(ctx, 0, []vector.Vector{
+		{ID: "a#0", Values: []float64{1, 0, 0}, Metadata: map[string]any{"title": "A"}, Document: json.RawMessage(`{"id":"a"}`)},
+		{ID: "a#1", Values: []float64{0, 1, 0}, Metadata: map[string]any{"title": "A"}, Document: json.RawMessage(`{"id":"a"}`)},
+		{ID: "b#0", Values: []float64{0, 0, 1}},
+	}))
+	// Upserting an existing vector replaces it.
+	require.NoError(t, s.Upsert(ctx, 0, []vector.Vector{
+		{ID: "b#0", Values: []float64{0.5, 0.5, 0}, Metadata: map[string]any{"title": "B"}},
+	}))
+	require.NoError(t, s.Delete(ctx, 0, []string{"a#1", "a#2", "missing"}))
+
+	rows, err := pool.Query(ctx, "SELECT id, embedding::text, metadata::text, document::text FROM "+tableIdentifier(path)+" ORDER BY id")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var got [][]*string
+	for rows.Next() {
+		var id, embedding string
+		var metadata, document *string
+		require.NoError(t, rows.Scan(&id, &embedding, &metadata, &document))
+		got = append(got, []*string{&id, &embedding, metadata, document})
+	}
+	require.NoError(t, rows.Err())
+
+	str := func(s string) *string { return &s }
+	require.Equal(t, [][]*string{
+		{str("a#0"), str("[1,0,0]"), str(`{"title": "A"}`), str(`{"id": "a"}`)},
+		{str("b#0"), str("[0.5,0.5,0]"), str(`{"title": "B"}`), nil},
+	}, got)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	vector "github.com/estuary/connectors/materialize-vector"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// store is a vector.VectorStore of the tables of a database.
+type store struct {
+	pool *pgxpool.Pool
+	// Quoted identifiers of the tables of the bindings, by binding index.
+	tables []string
+}
+
+var _ vector.VectorStore = (*store)(nil)
+
+func upsertSQL(table string) string {
+	return fmt.Sprintf(`INSERT INTO %s (id, embedding, metadata, document)
+VALUES ($1, $2::vector, $3::jsonb, $4::jsonb)
+ON CONFLICT (id) DO UPDATE SET
+	embedding = excluded.embedding,
+	metadata = excluded.metadata,
+	document = excluded.document;`, table)
+}
+
+func deleteSQL(table string) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE id = ANY($1);", table)
+}
+
+func (s *store) Upsert(ctx context.Context, binding int, vectors []vector.Vector) error {
+	var batch = new(pgx.Batch)
+	var query = upsertSQL(s.tables[binding])
+
+	for _, v := range vectors {
+		var metadata, document *string
+		if len(v.Metadata) > 0 {
+			b, err := json.Marshal(v.Metadata)
+			if err != nil {
+				return fmt.Errorf("encoding metadata: %w", err)
+			}
+			metadata = new(string)
+			*metadata = string(b)
+		}
+		if v.Document != nil {
+			document = new(string)
+			*document = string(v.Document)
+		}
+
+		batch.Queue(query, v.ID, vectorLiteral(v.Values), metadata, document)
+	}
+
+	results := s.pool.SendBatch(ctx, batch)
+	for range vectors {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return fmt.Errorf("upserting vector: %w", err)
+		}
+	}
+	return results.Close()
+}
+
+func (s *store) Delete(ctx context.Context, binding int, ids []string) error {
+	if _, err := s.pool.Exec(ctx, deleteSQL(s.tables[binding]), ids); err != nil {
+		return fmt.Errorf("deleting vectors: %w", err)
+	}
+	return nil
+}
+
+func (s *store) Close() {
+	s.pool.Close()
+}
+
+// vectorLiteral returns the text representation of a pgvector vector, like '[1,2,3]'.
+func vectorLiteral(values []float64) string {
+	var out strings.Builder
+	out.WriteByte('[')
+	for idx, v := range values {
+		if idx > 0 {
+			out.WriteByte(',')
+		}
+		out.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	out.WriteByte(']')
+	return out.String()
+}
@@ -10,25 +10,23 @@ import (
 	schemagen "github.com/estuary/connectors/go/schema-gen"
 	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
 	"github.com/estuary/connectors/materialize-pinecone/client"
+	vector "github.com/estuary/connectors/materialize-vector"
 	pf "github.com/estuary/flow/go/protocols/flow"
 	pm "github.com/estuary/flow/go/protocols/materialize"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
-	textEmbeddingAda002                = "text-embedding-ada-002"
-	textEmbeddingAda002VectorLength    = 1536
 	starterPodType                     = "starter"
 	emptyNamespaceResourcePathSentinel = "FLOW_EMPTY_NAMESPACE"
 )
 
 type config struct {
-	Index          string         `json:"index" jsonschema:"title=Pinecone Index" jsonschema_extras:"order=0"`
-	Environment    string         `json:"environment" jsonschema:"title=Pinecone Environment" jsonschema_extras:"order=1"`
-	PineconeApiKey string         `json:"pineconeApiKey" jsonschema:"title=Pinecone API Key" jsonschema_extras:"secret=true,order=2"`
-	OpenAiApiKey   string         `json:"openAiApiKey" jsonschema:"title=OpenAI API Key" jsonschema_extras:"secret=true,order=3"`
-	EmbeddingModel string         `json:"embeddingModel,omitempty" jsonschema:"title=Embedding Model ID,default=text-embedding-ada-002" jsonschema_extras:"order=4"`
-	Advanced       advancedConfig `json:"advanced,omitempty" jsonschema_extras:"advanced=true"`
+	Index          string `json:"index" jsonschema:"title=Pinecone Index" jsonschema_extras:"order=0"`
+	Environment    string `json:"environment" jsonschema:"title=Pinecone Environment" jsonschema_extras:"order=1"`
+	PineconeApiKey string `json:"pineconeApiKey" jsonschema:"title=Pinecone API Key" jsonschema_extras:"secret=true,order=2"`
+	vector.EmbeddingConfig
+	Advanced advancedConfig `json:"advanced,omitempty" jsonschema_extras:"advanced=true"`
 }
 
 func (config) GetFieldDocString(fieldName string) string {
@@ -39,19 +37,16 @@ func (config) GetFieldDocString(fieldName string) string {
 		return "Cloud region for your Pinecone project. Example: us-central1-gcp"
 	case "PineconeApiKey":
 		return "Pinecone API key used for authentication."
-	case "OpenAiApiKey":
-		return "OpenAI API key used for authentication."
-	case "EmbeddingModel":
-		return "Embedding model ID for generating OpenAI bindings. The default text-embedding-ada-002 is recommended."
 	case "Advanced":
 		return "Options for advanced users. You should not typically need to modify these."
 	default:
-		return ""
+		return vector.EmbeddingConfig{}.GetFieldDocString(fieldName)
 	}
 }
 
 type advancedConfig struct {
 	OpenAiOrg string `json:"openAiOrg,omitempty" jsonschema:"title=OpenAI Organization"`
+	vector.ChunkingConfig
 }
 
 func (advancedConfig) GetFieldDocString(fieldName string) string {
@@ -59,7 +54,7 @@ func (advancedConfig) GetFieldDocString(fieldName string) string {
 	case "OpenAiOrg":
 		return "Optional organization name for OpenAI requests. Use this if you belong to multiple organizations to specify which organization is used for API requests."
 	default:
-		return ""
+		return vector.ChunkingConfig{}.GetFieldDocString(fieldName)
 	}
 }
 
@@ -68,7 +63,6 @@ func (c *config) Validate() error {
 		{"index", c.Index},
 		{"environment", c.Environment},
 		{"pineconeApiKey", c.PineconeApiKey},
-		{"openAiApiKey", c.OpenAiApiKey},
 	}
 	for _, req := range requiredProperties {
 		if req[1] == "" {
@@ -76,37 +70,38 @@ func (c *config) Validate() error {
 		}
 	}
 
-	return nil
+	if err := c.EmbeddingConfig.Validate(); err != nil {
+		return err
+	}
+	return c.Advanced.ChunkingConfig.Validate()
 }
 
 func (c *config) pineconeClient(ctx context.Context) (*client.PineconeClient, error) {
 	return client.NewPineconeClient(ctx, c.Index, c.Environment, c.PineconeApiKey)
 }
 
-func (c *config) openAiClient() *client.OpenAiClient {
-	selectedModel := textEmbeddingAda002
-	if c.EmbeddingModel != "" {
-		selectedModel = c.EmbeddingModel
-	}
-
-	return client.NewOpenAiClient(selectedModel, c.Advanced.OpenAiOrg, c.OpenAiApiKey)
-}
-
 type resource struct {
-	Namespace string `json:"namespace,omitempty" jsonschema:"title=Pinecone Namespace" jsonschema_extras:"x-collection-name=true"`
+	Namespace       string   `json:"namespace,omitempty" jsonschema:"title=Pinecone Namespace" jsonschema_extras:"x-collection-name=true"`
+	MetadataFields  []string `json:"metadataFields,omitempty" jsonschema:"title=Metadata Fields"`
+	ExcludeDocument bool     `json:"excludeDocument,omitempty" jsonschema:"title=Exclude Document"`
+	vector.InputConfig
 }
 
 func (resource) GetFieldDocString(fieldName string) string {
 	switch fieldName {
 	case "Namespace":
 		return "Name of the Pinecone namespace that this collection will materialize vectors into. For Pinecone starter plans, leave blank to use no namespace. Only a single binding can have a blank namespace, and Pinecone starter plans can only materialize a single binding."
+	case "MetadataFields":
+		return "Fields which are stored as metadata of vectors, so that query results can be filtered by them. Strings, numbers, booleans and arrays of strings are stored as their Pinecone metadata types, and other values are stored as JSON strings."
+	case "ExcludeDocument":
+		return "Don't store the whole document as the 'flow_document' metadata field of vectors. Pinecone limits the metadata of a vector to 40KB, which large documents may exceed."
 	default:
-		return ""
+		return vector.InputConfig{}.GetFieldDocString(fieldName)
 	}
 }
 
 func (r resource) Validate() error {
-	return nil
+	return r.InputConfig.Validate()
 }
 
 type driver struct{}
@@ -146,18 +141,23 @@ func (d driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Res
 	if err != nil {
 		return nil, err
 	}
-	if indexStats, err := pc.DescribeIndexStats(ctx); err != nil {
+	indexStats, err := pc.DescribeIndexStats(ctx)
+	if err != nil {
 		return nil, fmt.Errorf("connecting to Pinecone: %w", err)
-	} else if cfg.EmbeddingModel == textEmbeddingAda002 && indexStats.Dimension != textEmbeddingAda002VectorLength {
+	}
+
+	// Verify the embedding provider by embedding a sample input, and that its embeddings have the
+	// dimensions of the index.
+	if dimensions, err := vector.Dimensions(ctx, cfg.Embedder(cfg.Advanced.OpenAiOrg)); err != nil {
+		return nil, fmt.Errorf("verifying embedding provider %s: %w", cfg.Provider(), err)
+	} else if dimensions != indexStats.Dimension {
 		return nil, fmt.Errorf(
-			"index '%s' has dimensions of %d but must be %d for embedding model '%s'",
+			"index '%s' has dimensions of %d but embeddings of provider '%s' have dimensions of %d",
 			cfg.Index,
 			indexStats.Dimension,
-			textEmbeddingAda002VectorLength,
-			textEmbeddingAda002,
+			cfg.Provider(),
+			dimensions,
 		)
-	} else if err := cfg.openAiClient().VerifyModelExists(ctx); err != nil {
-		return nil, err
 	}
 
 	// Log a warning message if the 'flow_document' metadata field has not been excluded from
@@ -171,10 +171,28 @@ func (d driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Res
 		"index":       cfg.Index,
 		"environment": cfg.Environment,
 	})
-	if indexDescribe.Database.MetadataConfig.Indexed == nil {
+	indexed := indexDescribe.Database.MetadataConfig.Indexed
+	var includesDocument bool
+	for _, b := range req.Bindings {
+		res, err := resolveResourceConfig(b.ResourceConfigJson)
+		if err != nil {
+			return nil, err
+		}
+		includesDocument = includesDocument || !res.ExcludeDocument
+
+		// Metadata fields which aren't indexed can't be used to filter query results.
+		for _, f := range res.MetadataFields {
+			if indexed != nil && !slices.Contains(indexed, f) {
+				entry.WithField("field", f).Warn("Metadata field is not indexed by this index's selective metadata indexing configuration, and cannot be used to filter query results.")
+			}
+		}
+	}
+	if !includesDocument {
+		// No binding stores the 'flow_document' metadata field.
+	} else if indexed == nil {
 		// If no explicit metadata configuration for which fields are indexed has been provided all fields are indexed.
 		entry.Warn("Metadata field 'flow_document' will be indexed since this index is not configured with selective metadata indexing. Consider using selective metadata indexing to prevent this field from being indexed to optimize memory utilization.")
-	} else if slices.Contains(indexDescribe.Database.MetadataConfig.Indexed, "flow_document") {
+	} else if slices.Contains(indexed, "flow_document") {
 		entry.Warn("Metadata field 'flow_document' will be indexed. This may not result in optimal memory utilization for the index.")
 	}
 
@@ -212,29 +230,9 @@ func (d driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Res
 			return nil, err
 		}
 
-		constraints := make(map[string]*pm.Response_Validated_Constraint)
-		for _, projection := range b.Collection.Projections {
-
-			var constraint = new(pm.Response_Validated_Constraint)
-			switch {
-			// We require collection keys be materialized because it seems pretty reasonable to
-			// require they be included as metadata since the composite key is used as the basis for
-			// the vector ID, and also to avoid complications from
-			// https://github.com/estuary/flow/issues/1057.
-			case projection.IsPrimaryKey:
-				constraint.Type = pm.Response_Validated_Constraint_LOCATION_REQUIRED
-				constraint.Reason = "Components of the collection key must be materialized"
-			case projection.Inference.IsSingleScalarType():
-				constraint.Type = pm.Response_Validated_Constraint_LOCATION_RECOMMENDED
-				constraint.Reason = "The projection has a single scalar type"
-			case projection.IsRootDocumentProjection():
-				constraint.Type = pm.Response_Validated_Constraint_LOCATION_REQUIRED
-				constraint.Reason = "The root document must be materialized"
-			default:
-				constraint.Type = pm.Response_Validated_Constraint_FIELD_OPTIONAL
-				constraint.Reason = "This field can be materializaed"
-			}
-			constraints[projection.Field] = constraint
+		constraints, err := vector.Constraints(b.Collection, res.InputConfig, res.MetadataFields)
+		if err != nil {
+			return nil, fmt.Errorf("validating binding for namespace %q: %w", res.Namespace, err)
 		}
 
 		nsPath := res.Namespace
@@ -265,17 +263,31 @@ func (d driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Tran
 		return nil, nil, err
 	}
 
-	var bindings []binding
+	var bindings []*vector.Binding
+	var namespaces []string
 	for _, b := range open.Materialization.Bindings {
 		res, err := resolveResourceConfig(b.ResourceConfigJson)
 		if err != nil {
 			return nil, nil, err
 		}
 
-		bindings = append(bindings, binding{
-			namespace:   res.Namespace,
-			dataHeaders: b.FieldSelection.AllFields(),
+		fields := append(append([]string{}, b.FieldSelection.Keys...), b.FieldSelection.Values...)
+		input, err := vector.NewInputBuilder(res.InputConfig, fields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("building input for namespace %q: %w", res.Namespace, err)
+		}
+		metadata, err := vector.NewMetadata(fields, res.MetadataFields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("building metadata for namespace %q: %w", res.Namespace, err)
+		}
+
+		bindings = append(bindings, &vector.Binding{
+			Name:            res.Namespace,
+			Input:           input,
+			Metadata:        metadata,
+			IncludeDocument: !res.ExcludeDocument,
 		})
+		namespaces = append(namespaces, res.Namespace)
 	}
 
 	pc, err := cfg.pineconeClient(ctx)
@@ -288,11 +300,15 @@ func (d driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Tran
 		"environment": cfg.Environment,
 	}).Info("starting materialize-pinecone")
 
-	return &transactor{
-		pineconeClient: pc,
-		openAiClient:   cfg.openAiClient(),
-		bindings:       bindings,
-	}, &pm.Response_Opened{}, nil
+	return vector.NewTransactor(vector.TransactorConfig{
+		Store:           &store{client: pc, namespaces: namespaces},
+		Embedder:        cfg.Embedder(cfg.Advanced.OpenAiOrg),
+		Bindings:        bindings,
+		Chunking:        cfg.Advanced.ChunkingConfig,
+		UpsertBatchSize: batchSize,
+		DeleteBatchSize: deleteBatchSize,
+		Concurrency:     concurrentWorkers,
+	}), &pm.Response_Opened{}, nil
 }
 
 func resolveEndpointConfig(specJson json.RawMessage) (config, error) {
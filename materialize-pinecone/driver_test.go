@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/bradleyjkemp/cupaloy"
+	vector "github.com/estuary/connectors/materialize-vector"
 	pm "github.com/estuary/flow/go/protocols/materialize"
 	"github.com/stretchr/testify/require"
 )
@@ -19,3 +21,94 @@ func TestSpecification(t *testing.T) {
 
 	cupaloy.SnapshotT(t, formatted)
 }
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() config {
+		return config{
+			Index:           "index",
+			Environment:     "env",
+			PineconeApiKey:  "key",
+			EmbeddingConfig: vector.EmbeddingConfig{OpenAiApiKey: "openai"},
+		}
+	}
+	c := valid()
+	require.NoError(t, c.Validate())
+
+	for _, tt := range []struct {
+		name   string
+		modify func(*config)
+		want   string
+	}{
+		{"missing index", func(c *config) { c.Index = "" }, "missing required property 'index'"},
+		{"missing OpenAI key", func(c *config) { c.OpenAiApiKey = "" }, "missing required property 'openAiApiKey'"},
+		{"overlap too large", func(c *config) { c.Advanced.MaxInputLength = 100; c.Advanced.ChunkOverlap = 100 }, "chunkOverlap must be at least 0 and less than maxInputLength 100"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(&c)
+			require.ErrorContains(t, c.Validate(), tt.want)
+		})
+	}
+
+	require.ErrorContains(t, resource{InputConfig: vector.InputConfig{InputFields: []string{"a"}, InputTemplate: "{{ .a }}"}}.Validate(), "only one of inputFields and inputTemplate may be set")
+}
+
+func TestConfigEmbedded(t *testing.T) {
+	// Embedding and chunking options are properties of the endpoint and advanced configs.
+	cfg, err := resolveEndpointConfig(json.RawMessage(`{
+		"index": "index",
+		"environment": "env",
+		"pineconeApiKey": "key",
+		"embeddingProvider": "cohere",
+		"cohere": {"apiKey": "cohere"},
+		"advanced": {"openAiOrg": "org", "maxChunks": 4}
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, vector.ProviderCohere, cfg.Provider())
+	require.Equal(t, "cohere", cfg.Cohere.ApiKey)
+	require.Equal(t, 4, cfg.Advanced.MaxChunks)
+
+	res, err := resolveResourceConfig(json.RawMessage(`{"namespace": "ns", "inputFields": ["a", "b"], "metadataFields": ["c"], "excludeDocument": true}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, res.InputFields)
+	require.Equal(t, []string{"c"}, res.MetadataFields)
+	require.True(t, res.ExcludeDocument)
+}
+
+func TestPineconeMetadata(t *testing.T) {
+	got, err := pineconeMetadata(vector.Vector{
+		ID: "key#0",
+		Metadata: map[string]any{
+			"title":   "hello",
+			"count":   int64(3),
+			"score":   1.5,
+			"active":  true,
+			"tags":    []any{"a", "b"},
+			"mixed":   []any{"a", 1.0},
+			"details": map[string]any{"nested": true},
+		},
+		Document: json.RawMessage(`{"title":"hello"}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"title":         "hello",
+		"count":         int64(3),
+		"score":         1.5,
+		"active":        true,
+		"tags":          []string{"a", "b"},
+		"mixed":         `["a",1]`,
+		"details":       `{"nested":true}`,
+		"flow_document": `{"title":"hello"}`,
+	}, got)
+
+	// The document is only included if there is one.
+	got, err = pineconeMetadata(vector.Vector{ID: "key#0", Metadata: map[string]any{"title": "hello"}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"title": "hello"}, got)
+
+	_, err = pineconeMetadata(vector.Vector{
+		ID:       "key#0",
+		Document: json.RawMessage(`"` + strings.Repeat("a", maxMetadataBytes) + `"`),
+	})
+	require.ErrorContains(t, err, "exceeds Pinecone's limit of 40960 bytes")
+}
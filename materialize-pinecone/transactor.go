@@ -2,15 +2,11 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"strings"
 
-	m "github.com/estuary/connectors/go/protocols/materialize"
 	"github.com/estuary/connectors/materialize-pinecone/client"
-	pf "github.com/estuary/flow/go/protocols/flow"
-	"golang.org/x/sync/errgroup"
+	vector "github.com/estuary/connectors/materialize-vector"
 )
 
 // TODO(whb): These may need tuning based on real-world use.
@@ -19,159 +15,106 @@ var (
 	concurrentWorkers = 5
 )
 
-type transactor struct {
-	pineconeClient *client.PineconeClient
-	openAiClient   *client.OpenAiClient
-	bindings       []binding
+// deleteBatchSize is the maximum number of vector IDs which Pinecone allows to be deleted by a
+// single request.
+const deleteBatchSize = 1000
 
-	group    *errgroup.Group
-	groupCtx context.Context
-}
-
-type binding struct {
-	namespace   string
-	dataHeaders []string
-}
+// maxMetadataBytes is the maximum size of the metadata of a vector which Pinecone allows.
+const maxMetadataBytes = 40 * 1024
 
-type upsertDoc struct {
-	input    string
-	key      string
-	metadata map[string]interface{}
+// store is a vector.VectorStore of the namespaces of a Pinecone index.
+type store struct {
+	client *client.PineconeClient
+	// Namespaces of the bindings, by binding index.
+	namespaces []string
 }
 
-func (t *transactor) UnmarshalState(state json.RawMessage) error                  { return nil }
-func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) { return nil, nil }
+var _ vector.VectorStore = (*store)(nil)
 
-func (t *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage) error) error {
-	for it.Next() {
-		panic("driver only supports delta updates")
+func (s *store) Upsert(ctx context.Context, binding int, vectors []vector.Vector) error {
+	upsert := client.PineconeUpsertRequest{
+		Vectors:   []client.Vector{},
+		Namespace: s.namespaces[binding],
 	}
-	return nil
-}
-
-func (t *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
-	ctx := it.Context()
-
-	t.group, t.groupCtx = errgroup.WithContext(ctx)
-	t.group.SetLimit(concurrentWorkers)
-
-	batches := make(map[string][]upsertDoc)
-
-	for it.Next() {
-		b := t.bindings[it.Binding]
-
-		allFields := append(it.Key, it.Values...)
-
-		data := make(map[string]interface{})
-		for idx, val := range allFields {
-			if val != nil {
-				data[b.dataHeaders[idx]] = val
-			}
-		}
 
-		embeddingInput, err := makeInput(data)
+	for _, v := range vectors {
+		metadata, err := pineconeMetadata(v)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("vector %q: %w", v.ID, err)
 		}
 
-		namespace := t.bindings[it.Binding].namespace
-
-		batches[namespace] = append(batches[namespace], upsertDoc{
-			input: embeddingInput,
-			key:   base64.RawURLEncoding.EncodeToString(it.PackedKey),
-			// Only the document is included as metadata.
-			metadata: map[string]interface{}{
-				"flow_document": string(it.RawJSON),
-			},
+		upsert.Vectors = append(upsert.Vectors, client.Vector{
+			Id:       v.ID,
+			Values:   v.Values,
+			Metadata: metadata,
 		})
-
-		if len(batches[namespace]) >= batchSize {
-			if err := t.sendBatch(namespace, batches[namespace]); err != nil {
-				return nil, fmt.Errorf("sending batch of documents: %w", err)
-			}
-			batches[namespace] = nil
-		}
 	}
 
-	// Flush remaining partial batches.
-	for namespace, batch := range batches {
-		if len(batch) > 0 {
-			if err := t.sendBatch(namespace, batch); err != nil {
-				return nil, fmt.Errorf("flushing documents batch: %w", err)
-			}
-		}
-	}
+	return s.client.Upsert(ctx, upsert)
+}
 
-	return nil, t.group.Wait()
+func (s *store) Delete(ctx context.Context, binding int, ids []string) error {
+	return s.client.Delete(ctx, client.PineconeDeleteRequest{
+		Ids:       ids,
+		Namespace: s.namespaces[binding],
+	})
 }
 
-// The embedding input is an aggregate string of all included keys and values of the
-// materialization. It is arranged as "key: value" pairs on newlines with the value being the
-// stringified JSON-encoded of the field value.
-func makeInput(fields map[string]interface{}) (string, error) {
-	var out strings.Builder
-
-	count := 0
-	for k, v := range fields {
-		if c, ok := v.([]byte); ok {
-			// We get JSON arrays and objects as raw bytes of their encoded JSON. Rather than
-			// encoding that as a base64-string, we want to handle these bytes as precomputed JSON.
-			v = json.RawMessage(c)
+func (s *store) Close() {}
+
+// pineconeMetadata returns the metadata of a vector as Pinecone metadata, which may be strings,
+// numbers, booleans and arrays of strings. Other values are stored as their JSON encoding, and the
+// document is stored as the 'flow_document' field.
+func pineconeMetadata(v vector.Vector) (map[string]interface{}, error) {
+	metadata := make(map[string]interface{}, len(v.Metadata)+1)
+	for k, val := range v.Metadata {
+		if strs, ok := stringArray(val); ok {
+			metadata[k] = strs
+			continue
 		}
 
-		m, err := json.Marshal(v)
-		if err != nil {
-			return "", fmt.Errorf("serializing input field: %w", err)
+		switch vv := val.(type) {
+		case string, bool, int64, uint64, float64:
+			metadata[k] = vv
+		default:
+			b, err := json.Marshal(vv)
+			if err != nil {
+				return nil, fmt.Errorf("encoding metadata field %q: %w", k, err)
+			}
+			metadata[k] = string(b)
 		}
-		out.WriteString(k + ": " + string(m))
+	}
+	if v.Document != nil {
+		metadata["flow_document"] = string(v.Document)
+	}
 
-		count++
-		if count < len(fields) {
-			out.WriteString("\n")
-		}
+	if b, err := json.Marshal(metadata); err != nil {
+		return nil, fmt.Errorf("encoding metadata: %w", err)
+	} else if len(b) > maxMetadataBytes {
+		return nil, fmt.Errorf(
+			"metadata is %d bytes, which exceeds Pinecone's limit of %d bytes: consider setting 'excludeDocument' for the binding, or selecting fewer metadata fields",
+			len(b),
+			maxMetadataBytes,
+		)
 	}
 
-	return out.String(), nil
+	return metadata, nil
 }
 
-func (t *transactor) sendBatch(namespace string, batch []upsertDoc) error {
-	select {
-	case <-t.groupCtx.Done():
-		return t.group.Wait()
-	default:
-		t.group.Go(func() error {
-			input := make([]string, 0, len(batch))
-			for _, r := range batch {
-				input = append(input, r.input)
-			}
-
-			embeddings, err := t.openAiClient.CreateEmbeddings(t.groupCtx, input)
-			if err != nil {
-				return fmt.Errorf("openAI creating embeddings: %w", err)
-			}
-
-			upsert := client.PineconeUpsertRequest{
-				Vectors:   []client.Vector{},
-				Namespace: namespace,
-			}
-
-			for _, e := range embeddings {
-				thisMeta := batch[e.Index]
-				upsert.Vectors = append(upsert.Vectors, client.Vector{
-					Id:       thisMeta.key,
-					Values:   e.Embedding,
-					Metadata: thisMeta.metadata,
-				})
-			}
-
-			if err := t.pineconeClient.Upsert(t.groupCtx, upsert); err != nil {
-				return fmt.Errorf("pinecone upserting batch: %w", err)
-			}
-			return nil
-		})
+// stringArray returns the strings of a value which is an array of only strings.
+func stringArray(val any) ([]string, bool) {
+	arr, ok := val.([]any)
+	if !ok {
+		return nil, false
 	}
 
-	return nil
+	strs := make([]string, 0, len(arr))
+	for _, elem := range arr {
+		str, ok := elem.(string)
+		if !ok {
+			return nil, false
+		}
+		strs = append(strs, str)
+	}
+	return strs, true
 }
-
-func (t *transactor) Destroy() {}
@@ -0,0 +1,111 @@
+// Package connection holds the handling of connections to Postgres databases which is shared by
+// materializations into Postgres and its variants.
+package connection
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	networkTunnel "github.com/estuary/connectors/go/network-tunnel"
+)
+
+// SSLModes are the valid settings of the 'sslmode' connection parameter.
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+type SshForwarding struct {
+	SshEndpoint string `json:"sshEndpoint" jsonschema:"title=SSH Endpoint,description=Endpoint of the remote SSH server that supports tunneling (in the form of ssh://user@hostname[:port])" jsonschema_extras:"pattern=^ssh://.+@.+$"`
+	PrivateKey  string `json:"privateKey" jsonschema:"title=SSH Private Key,description=Private key to connect to the remote SSH server." jsonschema_extras:"secret=true,multiline=true"`
+}
+
+type TunnelConfig struct {
+	SshForwarding *SshForwarding `json:"sshForwarding,omitempty" jsonschema:"title=SSH Forwarding"`
+}
+
+// Config is the information needed to connect to a database.
+type Config struct {
+	Address       string
+	User          string
+	Password      string
+	Database      string
+	SSLMode       string
+	NetworkTunnel *TunnelConfig
+}
+
+// ValidateSSLMode returns an error if the mode is set and isn't a valid setting of 'sslmode'.
+func ValidateSSLMode(mode string) error {
+	if mode != "" && !slices.Contains(SSLModes, mode) {
+		return fmt.Errorf("invalid 'sslmode' configuration: unknown setting %q", mode)
+	}
+	return nil
+}
+
+func (c Config) tunneled() bool {
+	return c.NetworkTunnel != nil && c.NetworkTunnel.SshForwarding != nil && c.NetworkTunnel.SshForwarding.SshEndpoint != ""
+}
+
+// URI returns the DSN string of the connection.
+func (c Config) URI() string {
+	var address = c.Address
+	// If SSH Tunnel is configured, we are going to create a tunnel from localhost:5432
+	// to address through the bastion server, so we use the tunnel's address
+	if c.tunneled() {
+		address = "localhost:5432"
+	}
+
+	// If the user did not specify a port (or no network tunnel is being used), default to port
+	// 5432. pgx ends up doing this anyway, but we do it here to make it more explicit and stable in
+	// case that underlying behavior changes in the future.
+	if !strings.Contains(address, ":") {
+		address = address + ":5432"
+	}
+
+	var uri = url.URL{
+		Scheme: "postgres",
+		Host:   address,
+		User:   url.UserPassword(c.User, c.Password),
+	}
+	if c.Database != "" {
+		uri.Path = "/" + c.Database
+	}
+	var params = make(url.Values)
+	if c.SSLMode != "" {
+		params.Set("sslmode", c.SSLMode)
+	}
+	if len(params) > 0 {
+		uri.RawQuery = params.Encode()
+	}
+
+	return uri.String()
+}
+
+// StartTunnel starts the SSH tunnel to the database, if one is configured.
+func (c Config) StartTunnel() error {
+	if !c.tunneled() {
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(c.Address)
+	if err != nil {
+		return fmt.Errorf("splitting address to host and port: %w", err)
+	}
+
+	var sshConfig = &networkTunnel.SshConfig{
+		SshEndpoint: c.NetworkTunnel.SshForwarding.SshEndpoint,
+		PrivateKey:  []byte(c.NetworkTunnel.SshForwarding.PrivateKey),
+		ForwardHost: host,
+		ForwardPort: port,
+		LocalPort:   "5432",
+	}
+	var tunnel = sshConfig.CreateTunnel()
+
+	// FIXME/question: do we need to shut down the tunnel manually if it is a child process?
+	// at the moment tunnel.Stop is not being called anywhere, but if the connector shuts down, the child process also shuts down.
+	if err := tunnel.Start(); err != nil {
+		return fmt.Errorf("error starting network tunnel: %w", err)
+	}
+
+	return nil
+}
@@ -4,15 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net"
-	"net/url"
-	"slices"
 	"strings"
 	"text/template"
 
-	networkTunnel "github.com/estuary/connectors/go/network-tunnel"
 	m "github.com/estuary/connectors/go/protocols/materialize"
 	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
+	"github.com/estuary/connectors/materialize-postgres/connection"
 	sql "github.com/estuary/connectors/materialize-sql"
 	pf "github.com/estuary/flow/go/protocols/flow"
 	pm "github.com/estuary/flow/go/protocols/materialize"
@@ -32,15 +29,6 @@ const (
 	loadBatchSizeLimit  = storeBatchSizeLimit * 5 // Load records are keys-only so allow for more in a batch
 )
 
-type sshForwarding struct {
-	SshEndpoint string `json:"sshEndpoint" jsonschema:"title=SSH Endpoint,description=Endpoint of the remote SSH server that supports tunneling (in the form of ssh://user@hostname[:port])" jsonschema_extras:"pattern=^ssh://.+@.+$"`
-	PrivateKey  string `json:"privateKey" jsonschema:"title=SSH Private Key,description=Private key to connect to the remote SSH server." jsonschema_extras:"secret=true,multiline=true"`
-}
-
-type tunnelConfig struct {
-	SshForwarding *sshForwarding `json:"sshForwarding,omitempty" jsonschema:"title=SSH Forwarding"`
-}
-
 // config represents the endpoint configuration for postgres.
 type config struct {
 	Address  string         `json:"address" jsonschema:"title=Address,description=Host and port of the database (in the form of host[:port]). Port 5432 is used as the default if no specific port is provided." jsonschema_extras:"order=0"`
@@ -50,11 +38,14 @@ type config struct {
 	Schema   string         `json:"schema,omitempty" jsonschema:"title=Database Schema,default=public,description=Database schema for bound collection tables (unless overridden within the binding resource configuration) as well as associated materialization metadata tables" jsonschema_extras:"order=4"`
 	Advanced advancedConfig `json:"advanced,omitempty" jsonschema:"title=Advanced Options,description=Options for advanced users. You should not typically need to modify these." jsonschema_extras:"advanced=true"`
 
-	NetworkTunnel *tunnelConfig `json:"networkTunnel,omitempty" jsonschema:"title=Network Tunnel,description=Connect to your system through an SSH server that acts as a bastion host for your network."`
+	NetworkTunnel *connection.TunnelConfig `json:"networkTunnel,omitempty" jsonschema:"title=Network Tunnel,description=Connect to your system through an SSH server that acts as a bastion host for your network."`
 }
 
 type advancedConfig struct {
 	SSLMode string `json:"sslmode,omitempty" jsonschema:"title=SSL Mode,description=Overrides SSL connection behavior by setting the 'sslmode' parameter.,enum=disable,enum=allow,enum=prefer,enum=require,enum=verify-ca,enum=verify-full"`
+
+	AuditSchemaChanges bool `json:"audit_schema_changes,omitempty" jsonschema:"title=Audit Schema Changes,description=Record every table creation and alteration in a flow_schema_changes table alongside the materialization metadata tables."`
+	LoadCacheSizeMB    int  `json:"load_cache_size_mb,omitempty" jsonschema:"title=Load Cache Size (MB),description=Size of an in-memory cache of recently stored documents which is used to avoid querying the database for loads of frequently updated keys. Disabled if not set."`
 }
 
 // Validate the configuration.
@@ -70,10 +61,11 @@ func (c *config) Validate() error {
 		}
 	}
 
-	if c.Advanced.SSLMode != "" {
-		if !slices.Contains([]string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}, c.Advanced.SSLMode) {
-			return fmt.Errorf("invalid 'sslmode' configuration: unknown setting %q", c.Advanced.SSLMode)
-		}
+	if err := connection.ValidateSSLMode(c.Advanced.SSLMode); err != nil {
+		return err
+	}
+	if c.Advanced.LoadCacheSizeMB < 0 {
+		return fmt.Errorf("invalid 'load_cache_size_mb' configuration: must not be negative")
 	}
 
 	return nil
@@ -81,37 +73,18 @@ func (c *config) Validate() error {
 
 // ToURI converts the Config to a DSN string.
 func (c *config) ToURI() string {
-	var address = c.Address
-	// If SSH Tunnel is configured, we are going to create a tunnel from localhost:5432
-	// to address through the bastion server, so we use the tunnel's address
-	if c.NetworkTunnel != nil && c.NetworkTunnel.SshForwarding != nil && c.NetworkTunnel.SshForwarding.SshEndpoint != "" {
-		address = "localhost:5432"
-	}
-
-	// If the user did not specify a port (or no network tunnel is being used), default to port
-	// 5432. pgx ends up doing this anyway, but we do it here to make it more explicit and stable in
-	// case that underlying behavior changes in the future.
-	if !strings.Contains(address, ":") {
-		address = address + ":5432"
-	}
+	return c.connection().URI()
+}
 
-	var uri = url.URL{
-		Scheme: "postgres",
-		Host:   address,
-		User:   url.UserPassword(c.User, c.Password),
-	}
-	if c.Database != "" {
-		uri.Path = "/" + c.Database
+func (c *config) connection() connection.Config {
+	return connection.Config{
+		Address:       c.Address,
+		User:          c.User,
+		Password:      c.Password,
+		Database:      c.Database,
+		SSLMode:       c.Advanced.SSLMode,
+		NetworkTunnel: c.NetworkTunnel,
 	}
-	var params = make(url.Values)
-	if c.Advanced.SSLMode != "" {
-		params.Set("sslmode", c.Advanced.SSLMode)
-	}
-	if len(params) > 0 {
-		uri.RawQuery = params.Encode()
-	}
-
-	return uri.String()
 }
 
 func (c *config) metaSchema() string {
@@ -126,6 +99,9 @@ type tableConfig struct {
 	Schema        string `json:"schema,omitempty" jsonschema:"title=Alternative Schema,description=Alternative schema for this table (optional)"`
 	AdditionalSql string `json:"additional_table_create_sql,omitempty" jsonschema:"title=Additional Table Create SQL,description=Additional SQL statement(s) to be run in the same transaction that creates the table." jsonschema_extras:"multiline=true"`
 	Delta         bool   `json:"delta_updates,omitempty" jsonschema:"default=false,title=Delta Update,description=Should updates to this table be done via delta updates. Default is false."`
+
+	Indexes   []sql.TableIndex `json:"indexes,omitempty" jsonschema:"title=Indexes,description=Secondary indexes to create on the table. Indexes added to an existing table are built concurrently."`
+	Retention *sql.Retention   `json:"retention,omitempty" jsonschema:"title=Retention,description=Remove rows from the table once they are older than a number of days."`
 }
 
 func newTableConfig(ep *sql.Endpoint) sql.Resource {
@@ -141,6 +117,11 @@ func (r tableConfig) Validate() error {
 	if r.Table == "" {
 		return fmt.Errorf("missing table")
 	}
+	if r.Retention != nil {
+		if err := r.Retention.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -155,6 +136,10 @@ func (c tableConfig) DeltaUpdates() bool {
 	return c.Delta
 }
 
+func (c tableConfig) TableOptions() sql.TableOptions {
+	return sql.TableOptions{Indexes: c.Indexes, Retention: c.Retention}
+}
+
 func newPostgresDriver() *sql.Driver {
 	return &sql.Driver{
 		DocumentationURL: "https://go.estuary.dev/materialize-postgresql",
@@ -178,41 +163,32 @@ func newPostgresDriver() *sql.Driver {
 			}
 			var metaSpecs, metaCheckpoints = sql.MetaTables(metaBase)
 
-			// If SSH Endpoint is configured, then try to start a tunnel before establishing connections
-			if cfg.NetworkTunnel != nil && cfg.NetworkTunnel.SshForwarding != nil && cfg.NetworkTunnel.SshForwarding.SshEndpoint != "" {
-				host, port, err := net.SplitHostPort(cfg.Address)
-				if err != nil {
-					return nil, fmt.Errorf("splitting address to host and port: %w", err)
-				}
-
-				var sshConfig = &networkTunnel.SshConfig{
-					SshEndpoint: cfg.NetworkTunnel.SshForwarding.SshEndpoint,
-					PrivateKey:  []byte(cfg.NetworkTunnel.SshForwarding.PrivateKey),
-					ForwardHost: host,
-					ForwardPort: port,
-					LocalPort:   "5432",
-				}
-				var tunnel = sshConfig.CreateTunnel()
+			var metaSchemaChanges *sql.TableShape
+			if cfg.Advanced.AuditSchemaChanges {
+				var t = sql.FlowSchemaChangesTable(append(metaBase, sql.DefaultFlowSchemaChanges)...)
+				metaSchemaChanges = &t
+			}
 
-				// FIXME/question: do we need to shut down the tunnel manually if it is a child process?
-				// at the moment tunnel.Stop is not being called anywhere, but if the connector shuts down, the child process also shuts down.
-				if err := tunnel.Start(); err != nil {
-					return nil, fmt.Errorf("error starting network tunnel: %w", err)
-				}
+			// If SSH Endpoint is configured, then try to start a tunnel before establishing connections
+			if err := cfg.connection().StartTunnel(); err != nil {
+				return nil, err
 			}
 
 			return &sql.Endpoint{
-				Config:               cfg,
-				Dialect:              pgDialect,
-				MetaSpecs:            &metaSpecs,
-				MetaCheckpoints:      &metaCheckpoints,
-				NewClient:            newClient,
-				CreateTableTemplate:  tplCreateTargetTable,
-				ReplaceTableTemplate: tplReplaceTargetTable,
-				NewResource:          newTableConfig,
-				NewTransactor:        newTransactor,
-				Tenant:               tenant,
-				ConcurrentApply:      false,
+				Config:                cfg,
+				Dialect:               pgDialect,
+				MetaSpecs:             &metaSpecs,
+				MetaCheckpoints:       &metaCheckpoints,
+				MetaSchemaChanges:     metaSchemaChanges,
+				NewClient:             newClient,
+				CreateTableTemplate:   tplCreateTargetTable,
+				ReplaceTableTemplate:  tplReplaceTargetTable,
+				CreateIndexTemplate:   tplCreateIndex,
+				DeleteExpiredTemplate: tplDeleteExpired,
+				NewResource:           newTableConfig,
+				NewTransactor:         newTransactor,
+				Tenant:                tenant,
+				ConcurrentApply:       false,
 			}, nil
 		},
 	}
@@ -229,7 +205,9 @@ type transactor struct {
 		conn  *pgx.Conn
 		fence sql.Fence
 	}
-	bindings []*binding
+	bindings  []*binding
+	retention *sql.RetentionEnforcer
+	cache     *sql.LoadCache
 }
 
 func newTransactor(
@@ -243,6 +221,7 @@ func newTransactor(
 	d.store.fence = fence
 
 	var cfg = ep.Config.(*config)
+	d.cache = sql.NewLoadCache(cfg.Advanced.LoadCacheSizeMB * 1024 * 1024)
 	// Establish connections.
 	if d.load.conn, err = pgx.Connect(ctx, cfg.ToURI()); err != nil {
 		return nil, fmt.Errorf("load pgx.Connect: %w", err)
@@ -264,6 +243,10 @@ func newTransactor(
 	}
 	d.load.unionSQL = strings.Join(subqueries, "\nUNION ALL\n") + ";"
 
+	if d.retention, err = sql.NewRetentionEnforcer(bindings, tplDeleteExpired, deleteExpiredBatchRows); err != nil {
+		return nil, err
+	}
+
 	return d, nil
 }
 
@@ -305,8 +288,21 @@ func (t *transactor) addBinding(ctx context.Context, target sql.Table) error {
 	return nil
 }
 
-func (t *transactor) UnmarshalState(state json.RawMessage) error                  { return nil }
-func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) { return nil, nil }
+func (t *transactor) UnmarshalState(state json.RawMessage) error { return nil }
+
+// Acknowledge caches the documents of the committed transaction, and removes expired rows from
+// tables having a retention.
+func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) {
+	t.cache.Committed()
+
+	if err := t.retention.Enforce(ctx, func(ctx context.Context, statement string) (int64, error) {
+		tag, err := t.store.conn.Exec(ctx, statement)
+		return tag.RowsAffected(), err
+	}); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
 
 func (d *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage) error) error {
 	var ctx = it.Context()
@@ -319,10 +315,23 @@ func (d *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage)
 	}
 	defer txn.Rollback(ctx)
 
+	type cachedDoc struct {
+		binding int
+		doc     json.RawMessage
+	}
+	var cached []cachedDoc
+	var misses int
+
 	var batch pgx.Batch
 	for it.Next() {
 		var b = d.bindings[it.Binding]
 
+		if doc, ok := d.cache.Get(it.Binding, it.PackedKey); ok {
+			cached = append(cached, cachedDoc{it.Binding, doc})
+			continue
+		}
+		misses++
+
 		if converted, err := b.target.ConvertKey(it.Key); err != nil {
 			return fmt.Errorf("converting Load key: %w", err)
 		} else {
@@ -339,6 +348,20 @@ func (d *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage)
 		return it.Err()
 	}
 
+	// Cached documents may only be sent once iteration has completed, and the prior transaction
+	// has been acknowledged.
+	for _, c := range cached {
+		if err := loaded(c.binding, c.doc); err != nil {
+			return err
+		}
+	}
+	if len(cached) > 0 {
+		log.WithFields(log.Fields{"hits": len(cached), "misses": misses}).Debug("loaded documents from cache")
+	}
+	if misses == 0 {
+		return nil
+	}
+
 	// Send any remaining keys for this load.
 	if batch.Len() > 0 {
 		if err := sendBatch(ctx, txn, &batch); err != nil {
@@ -389,6 +412,10 @@ func (d *transactor) Store(it *m.StoreIterator) (_ m.StartCommitFunc, err error)
 	for it.Next() {
 		var b = d.bindings[it.Binding]
 
+		if !b.target.DeltaUpdates {
+			d.cache.Stored(it.Binding, it.PackedKey, it.RawJSON)
+		}
+
 		if converted, err := b.target.ConvertAll(it.Key, it.Values, it.RawJSON); err != nil {
 			return nil, fmt.Errorf("converting store parameters: %w", err)
 		} else if it.Exists {
@@ -152,6 +152,30 @@ ALTER TABLE {{$.Identifier}}
 {{- end }};
 {{ end }}
 
+-- Templated creation of a secondary index of a materialized table. Indexes
+-- added to an existing table are built concurrently, which cannot be done
+-- within a transaction.
+
+{{ define "createIndex" -}}
+CREATE {{ if $.Unique }}UNIQUE {{ end }}INDEX {{ if $.Concurrently }}CONCURRENTLY {{ end }}IF NOT EXISTS {{$.Identifier}} ON {{$.TableIdentifier}} (
+{{- range $ind, $col := $.Columns }}
+	{{- if $ind }}, {{ end -}}
+	{{$col.Identifier}}
+{{- end -}}
+);
+{{- end }}
+
+-- Templated deletion of a batch of rows which are older than the retention of
+-- a materialized table. Batches are bounded so that each deletion is brief.
+
+{{ define "deleteExpired" -}}
+DELETE FROM {{$.Identifier}} WHERE ctid = ANY(ARRAY(
+	SELECT ctid FROM {{$.Identifier}}
+	WHERE {{$.RetentionColumn.Identifier}} < NOW() - INTERVAL '{{$.Options.Retention.Days}} days'
+	LIMIT {{$.BatchRows}}
+));
+{{- end }}
+
 -- Templated creation of a temporary load table:
 
 {{ define "createLoadTable" }}
@@ -291,6 +315,8 @@ END $$;
 	tplCreateTargetTable  = tplAll.Lookup("createTargetTable")
 	tplReplaceTargetTable = tplAll.Lookup("replaceTargetTable")
 	tplAlterTableColumns  = tplAll.Lookup("alterTableColumns")
+	tplCreateIndex        = tplAll.Lookup("createIndex")
+	tplDeleteExpired      = tplAll.Lookup("deleteExpired")
 	tplLoadInsert         = tplAll.Lookup("loadInsert")
 	tplStoreInsert        = tplAll.Lookup("storeInsert")
 	tplStoreUpdate        = tplAll.Lookup("storeUpdate")
@@ -299,6 +325,9 @@ END $$;
 	tplUpdateFence        = tplAll.Lookup("updateFence")
 )
 
+// deleteExpiredBatchRows is the LIMIT of rows deleted by a deleteExpired statement.
+const deleteExpiredBatchRows = 10000
+
 // truncatedIdentifier produces a truncated form of an identifier, in accordance with Postgres'
 // automatic truncation of identifiers that are over 63 bytes in length. For example, if a Flow
 // collection or field name is over 63 bytes in length, Postgres will let a table/column be created
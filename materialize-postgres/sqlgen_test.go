@@ -104,6 +104,36 @@ func TestSQLGeneration(t *testing.T) {
 		snap.WriteString("--- End " + testcase.name + " ---\n\n")
 	}
 
+	var shapeWithOptions = sqlDriver.BuildTableShape(spec, 0, tableConfig{
+		Schema: "a-schema",
+		Table:  "target_table",
+		Indexes: []sqlDriver.TableIndex{
+			{Fields: []string{"lower_case"}},
+			{Fields: []string{"Camel_Case", "a Time"}, Unique: true},
+		},
+		Retention: &sqlDriver.Retention{Field: "a Time", Days: 30},
+	})
+	tableWithOptions, err := sqlDriver.ResolveTable(shapeWithOptions, pgDialect)
+	require.NoError(t, err)
+
+	for _, concurrently := range []bool{false, true} {
+		for _, idx := range tableWithOptions.Indexes {
+			idx.Concurrently = concurrently
+			var testcase = idx.Name + " createIndex"
+			if concurrently {
+				testcase += " (concurrently)"
+			}
+
+			snap.WriteString("--- Begin " + testcase + " ---\n")
+			require.NoError(t, tplCreateIndex.Execute(&snap, &idx))
+			snap.WriteString("\n--- End " + testcase + " ---\n\n")
+		}
+	}
+
+	snap.WriteString("--- Begin " + tableWithOptions.Identifier + " deleteExpired ---\n")
+	require.NoError(t, tplDeleteExpired.Execute(&snap, &sqlDriver.DeleteExpiredParams{Table: tableWithOptions, BatchRows: deleteExpiredBatchRows}))
+	snap.WriteString("\n--- End " + tableWithOptions.Identifier + " deleteExpired ---\n\n")
+
 	var shapeNoValues = sqlDriver.BuildTableShape(spec, 2, tableConfig{
 		Schema: "",
 		Table:  "target_table_no_values_materialized",
@@ -165,6 +165,11 @@ type tableConfig struct {
 	Table  string `json:"table" jsonschema:"title=Table,description=Name of the database table." jsonschema_extras:"x-collection-name=true"`
 	Schema string `json:"schema,omitempty" jsonschema:"title=Alternative Schema,description=Alternative schema for this table (optional)."`
 	Delta  bool   `json:"delta_updates,omitempty" jsonschema:"default=false,title=Delta Update,description=Should updates to this table be done via delta updates. Default is false."`
+
+	DistKeyField  string   `json:"distkey_field,omitempty" jsonschema:"title=Distribution Key Field,description=Field used as the distribution key of the table (optional)."`
+	SortKeyFields []string `json:"sortkey_fields,omitempty" jsonschema:"title=Sort Key Fields,description=Fields used as the compound sort key of the table (optional)."`
+
+	Retention *sql.Retention `json:"retention,omitempty" jsonschema:"title=Retention,description=Remove rows from the table once they are older than a number of days."`
 }
 
 func newTableConfig(ep *sql.Endpoint) sql.Resource {
@@ -180,6 +185,11 @@ func (r tableConfig) Validate() error {
 	if r.Table == "" {
 		return fmt.Errorf("missing table")
 	}
+	if r.Retention != nil {
+		if err := r.Retention.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -194,6 +204,14 @@ func (c tableConfig) DeltaUpdates() bool {
 	return c.Delta
 }
 
+func (c tableConfig) TableOptions() sql.TableOptions {
+	var opts = sql.TableOptions{ClusterBy: c.SortKeyFields, Retention: c.Retention}
+	if c.DistKeyField != "" {
+		opts.PartitionBy = []string{c.DistKeyField}
+	}
+	return opts
+}
+
 func newRedshiftDriver() *sql.Driver {
 	return &sql.Driver{
 		DocumentationURL: "https://go.estuary.dev/materialize-redshift",
@@ -239,17 +257,18 @@ func newRedshiftDriver() *sql.Driver {
 			}
 
 			return &sql.Endpoint{
-				Config:               cfg,
-				Dialect:              rsDialect,
-				MetaSpecs:            &metaSpecs,
-				MetaCheckpoints:      &metaCheckpoints,
-				NewClient:            newClient,
-				CreateTableTemplate:  tplCreateTargetTable,
-				ReplaceTableTemplate: tplReplaceTargetTable,
-				NewResource:          newTableConfig,
-				NewTransactor:        newTransactor,
-				Tenant:               tenant,
-				ConcurrentApply:      true,
+				Config:                cfg,
+				Dialect:               rsDialect,
+				MetaSpecs:             &metaSpecs,
+				MetaCheckpoints:       &metaCheckpoints,
+				NewClient:             newClient,
+				CreateTableTemplate:   tplCreateTargetTable,
+				ReplaceTableTemplate:  tplReplaceTargetTable,
+				DeleteExpiredTemplate: tplDeleteExpired,
+				NewResource:           newTableConfig,
+				NewTransactor:         newTransactor,
+				Tenant:                tenant,
+				ConcurrentApply:       true,
 			}, nil
 		},
 	}
@@ -262,6 +281,7 @@ type transactor struct {
 	bindings    []*binding
 	cfg         *config
 	updateDelay time.Duration
+	retention   *sql.RetentionEnforcer
 }
 
 func newTransactor(
@@ -329,6 +349,10 @@ func newTransactor(
 		}
 	}
 
+	if d.retention, err = sql.NewRetentionEnforcer(bindings, tplDeleteExpired, 0); err != nil {
+		return nil, err
+	}
+
 	return d, nil
 }
 
@@ -449,8 +473,32 @@ func (t *transactor) AckDelay() time.Duration {
 	return t.updateDelay
 }
 
-func (t *transactor) UnmarshalState(state json.RawMessage) error                  { return nil }
-func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) { return nil, nil }
+func (t *transactor) UnmarshalState(state json.RawMessage) error { return nil }
+
+// Acknowledge removes expired rows from tables having a retention, once their transactions have
+// committed. A connection is only established if a table is due for the removal of its rows.
+func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) {
+	var conn *pgx.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close(ctx)
+		}
+	}()
+
+	if err := t.retention.Enforce(ctx, func(ctx context.Context, statement string) (int64, error) {
+		if conn == nil {
+			var err error
+			if conn, err = pgx.Connect(ctx, t.cfg.toURI()); err != nil {
+				return 0, fmt.Errorf("retention pgx.Connect: %w", err)
+			}
+		}
+		tag, err := conn.Exec(ctx, statement)
+		return tag.RowsAffected(), err
+	}); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
 
 func (d *transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage) error) error {
 	var ctx = it.Context()
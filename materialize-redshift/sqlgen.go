@@ -154,7 +154,8 @@ flow_temp_table_{{ $.Binding }}
 {{- end }}
 
 -- Templated creation of a materialized table definition and comments.
--- DISTSTYLE and SORTKEY are omitted to allow for automatic optimization by Redshift.
+-- DISTKEY and SORTKEY are omitted unless partition or cluster columns are
+-- configured, to allow for automatic optimization by Redshift.
 -- Note that Redshift does not support primary keys or unique constraints.
 
 {{ define "createTargetTable" }}
@@ -163,7 +164,18 @@ CREATE TABLE IF NOT EXISTS {{$.Identifier}} (
 	{{- if $ind }},{{ end }}
 	{{$col.Identifier}} {{$col.DDL}}
 {{- end }}
-);
+)
+{{- with $.PartitionColumns }}
+DISTKEY({{ (index . 0).Identifier }})
+{{- end }}
+{{- if $.ClusterColumns }}
+SORTKEY(
+	{{- range $ind, $col := $.ClusterColumns }}
+	{{- if $ind }}, {{end -}}
+	{{$col.Identifier}}
+	{{- end -}}
+)
+{{- end }};
 
 COMMENT ON TABLE {{$.Identifier}} IS {{Literal $.Comment}};
 {{- range $col := .Columns }}
@@ -256,6 +268,15 @@ SELECT * FROM (SELECT -1, CAST(NULL AS SUPER) LIMIT 0) as nodoc
 {{- end }}
 {{ end }}
 
+-- Templated deletion of the rows which are older than the retention of a
+-- materialized table. Redshift has no DELETE ... LIMIT, so all expired rows are
+-- deleted by one statement.
+
+{{ define "deleteExpired" -}}
+DELETE FROM {{$.Identifier}}
+WHERE {{$.RetentionColumn.Identifier}} < DATEADD(day, -{{$.Options.Retention.Days}}, GETDATE());
+{{- end }}
+
 -- Templated update of a fence checkpoint.
 
 {{ define "updateFence" }}
@@ -293,6 +314,7 @@ TRUNCATECOLUMNS;
 	tplCreateStoreTable   = tplAll.Lookup("createStoreTable")
 	tplMergeInto          = tplAll.Lookup("mergeInto")
 	tplLoadQuery          = tplAll.Lookup("loadQuery")
+	tplDeleteExpired      = tplAll.Lookup("deleteExpired")
 	tplUpdateFence        = tplAll.Lookup("updateFence")
 	tplCopyFromS3         = tplAll.Lookup("copyFromS3")
 )
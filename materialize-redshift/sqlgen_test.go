@@ -28,6 +28,10 @@ func TestSQLGeneration(t *testing.T) {
 		Schema: "",
 		Table:  "Delta Updates",
 		Delta:  true,
+
+		DistKeyField:  "theKey",
+		SortKeyFields: []string{"aValue"},
+		Retention:     &sqlDriver.Retention{Field: "aValue", Days: 30},
 	})
 	shape2.Document = nil // TODO(johnny): this is a bit gross.
 
@@ -94,6 +98,10 @@ func TestSQLGeneration(t *testing.T) {
 	require.NoError(t, tplUpdateFence.Execute(&snap, fence))
 	snap.WriteString("--- End Fence Update ---\n\n")
 
+	snap.WriteString("--- Begin " + table2.Identifier + " deleteExpired ---\n")
+	require.NoError(t, tplDeleteExpired.Execute(&snap, &table2))
+	snap.WriteString("\n--- End " + table2.Identifier + " deleteExpired ---\n\n")
+
 	var copyParams = copyFromS3Params{
 		Target: "my_temp_table",
 		Columns: []*sqlDriver.Column{
@@ -12,8 +12,33 @@ type DriverCheckpoint struct {
 	// The sequence number used to name the next files to be uploaded to the cloud.
 	// To be specific, the next parquet file from the i-th binding is named using the deterministic pattern of
 	// "<KeyBegin>_<KeyEnd>_<NextSeqNumList[i]>.parquet".
-	// The NextSeqNumList[i] is increased by 1 after each successful upload from the i-th binding.
+	// The NextSeqNumList[i] is increased by 1 for each file uploaded from the i-th binding. A binding which rolls
+	// its files upon reaching a size or row limit may upload several files in a single commit, which are numbered
+	// consecutively. Upon recovery, files having sequence numbers beyond those re-produced by the recovered process
+	// were never committed, and are removed.
 	NextSeqNumList []int `json:"nextSeqNumList"`
+	// The sequence numbers used to name the next files to be uploaded to each partition of bindings having
+	// partitioned files. NextPartitionSeqNums[i] is keyed on the partition path of files from the i-th binding,
+	// like "dt=2024-05-01/region=us", and is nil if the i-th binding is not partitioned.
+	NextPartitionSeqNums []map[string]int `json:"nextPartitionSeqNums,omitempty"`
+	// The sequence numbers from which files are numbered in partitions which have no entry in NextPartitionSeqNums.
+	// Partitions which haven't been written recently are pruned from NextPartitionSeqNums, and
+	// PartitionSeqNumFloors[i] is greater than the sequence numbers of every partition pruned from the i-th binding.
+	PartitionSeqNumFloors []int `json:"partitionSeqNumFloors,omitempty"`
+	// The data files which have been uploaded from bindings materialized as Iceberg tables, but which may not yet
+	// have been appended to the table. PendingIcebergFiles[i] are the files of the i-th binding. They're appended
+	// once this checkpoint has committed, and again upon recovery, which skips files the table already has.
+	PendingIcebergFiles [][]IcebergDataFile `json:"pendingIcebergFiles,omitempty"`
+}
+
+// IcebergDataFile is a parquet file to be appended to an Iceberg table.
+type IcebergDataFile struct {
+	// The key of the file in the bucket.
+	Path string `json:"path"`
+	// The sequence number used to name the file.
+	SeqNum        int   `json:"seqNum"`
+	RecordCount   int64 `json:"recordCount"`
+	FileSizeBytes int64 `json:"fileSizeBytes"`
 }
 
 // Validate implements some interface that's required in order to use json.UnmarshalStrict
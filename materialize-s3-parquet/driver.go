@@ -88,10 +88,27 @@ func (c config) Validate() error {
 // resource specifies a materialization destinaion in S3, and the resulting parquet file configuration.
 type resource struct {
 	PathPrefix string `json:"pathPrefix" jsonschema_extras:"x-collection-name=true"`
-	// The method used for compressing data in parquet.
+	// The format of the files, which is parquet if unset.
+	Format string `json:"format,omitempty"`
+	// The method used for compressing data in files of the format.
 	CompressionType string `json:"compressionType,omitempty"`
+	// A file is rolled to a new file once it reaches either of these limits, if set, rather than
+	// growing until the next upload.
+	MaxFileSizeBytes int64 `json:"maxFileSizeBytes,omitempty"`
+	MaxRowsPerFile   int64 `json:"maxRowsPerFile,omitempty"`
+	// The size of row groups of parquet files, in bytes. Defaults to 128MiB if unset.
+	RowGroupSizeBytes int64 `json:"rowGroupSizeBytes,omitempty"`
+	// Fields which partition the parquet files into Hive-style `name=value` directories
+	// below the path prefix, in order.
+	PartitionBy []partitionField `json:"partitionBy,omitempty"`
+	// The format of a table located at the path prefix, which the parquet files are
+	// appended to. Files are not appended to a table if unset.
+	TableFormat string `json:"tableFormat,omitempty"`
 }
 
+// Parquet files are appended to an Apache Iceberg table.
+const tableFormatIceberg = "iceberg"
+
 var compressionTypeToCodec = map[string]parquet.CompressionCodec{
 	"none":   parquet.CompressionCodec_UNCOMPRESSED,
 	"snappy": parquet.CompressionCodec_SNAPPY,
@@ -105,15 +122,62 @@ func (r resource) Validate() error {
 		return fmt.Errorf("pathPrefix in a resource should not be None")
 	}
 
-	if r.CompressionType != "" {
+	if r.format() != formatParquet {
+		if err := validateFormat(r.format(), r.CompressionType); err != nil {
+			return err
+		}
+	} else if r.CompressionType != "" {
 		if _, ok := compressionTypeToCodec[r.CompressionType]; !ok {
 			return fmt.Errorf("invalid compressionType, expecting one of %v", reflect.ValueOf(compressionTypeToCodec).MapKeys())
 		}
 	}
 
+	if r.MaxFileSizeBytes < 0 {
+		return fmt.Errorf("maxFileSizeBytes should be non-negative")
+	} else if r.MaxRowsPerFile < 0 {
+		return fmt.Errorf("maxRowsPerFile should be non-negative")
+	} else if r.RowGroupSizeBytes < 0 {
+		return fmt.Errorf("rowGroupSizeBytes should be non-negative")
+	} else if r.RowGroupSizeBytes != 0 && r.format() != formatParquet {
+		return fmt.Errorf("rowGroupSizeBytes is only supported with format %q", formatParquet)
+	}
+
+	if r.TableFormat != "" && r.TableFormat != tableFormatIceberg {
+		return fmt.Errorf("invalid tableFormat %q, expecting %q", r.TableFormat, tableFormatIceberg)
+	} else if r.TableFormat == tableFormatIceberg && len(r.PartitionBy) != 0 {
+		return fmt.Errorf("partitionBy is not supported with tableFormat %q", r.TableFormat)
+	} else if r.TableFormat == tableFormatIceberg && r.format() != formatParquet {
+		return fmt.Errorf("format %q is not supported with tableFormat %q", r.Format, r.TableFormat)
+	}
+
+	var names = make(map[string]bool)
+	for _, p := range r.PartitionBy {
+		if err := p.Validate(); err != nil {
+			return err
+		} else if names[p.name()] {
+			return fmt.Errorf("duplicate partition name %q", p.name())
+		}
+		names[p.name()] = true
+	}
+
 	return nil
 }
 
+func (r resource) format() string {
+	if r.Format == "" {
+		return formatParquet
+	}
+	return r.Format
+}
+
+// compressionType returns the compression type of the resource, or the default of its format.
+func (r resource) compressionType() string {
+	if r.CompressionType == "" {
+		return formatCompressionTypes[r.format()][0]
+	}
+	return r.CompressionType
+}
+
 func (r resource) CompressionCodec() parquet.CompressionCodec {
 	if r.CompressionType == "" {
 		return parquet.CompressionCodec_SNAPPY
@@ -123,14 +187,17 @@ func (r resource) CompressionCodec() parquet.CompressionCodec {
 }
 
 // Creates a driver checkpoint, and encodes it into a json.RawMessage to populate the DriverCheckPointJson field in `prepared` response.
-func marshalDriverCheckpointJSON(flowCheckpoint []byte, nextSeqNumList []int) (json.RawMessage, error) {
+func marshalDriverCheckpointJSON(flowCheckpoint []byte, state FileState) (json.RawMessage, error) {
 	if len(flowCheckpoint) == 0 {
 		panic("empty checkpoint received")
 	}
 
 	dcp := &checkpoint.DriverCheckpoint{
 		B64EncodedFlowCheckpoint: base64.StdEncoding.EncodeToString(flowCheckpoint),
-		NextSeqNumList:           nextSeqNumList,
+		NextSeqNumList:           state.NextSeqNumList,
+		NextPartitionSeqNums:     state.NextPartitionSeqNums,
+		PartitionSeqNumFloors:    state.PartitionSeqNumFloors,
+		PendingIcebergFiles:      state.PendingIcebergFiles,
 	}
 
 	md, err := json.Marshal(dcp)
@@ -142,7 +209,7 @@ func marshalDriverCheckpointJSON(flowCheckpoint []byte, nextSeqNumList []int) (j
 }
 
 // Decodes a DriverCheckpointJson received from the Open txn request.
-func unmarshalDriverCheckpointJSON(raw json.RawMessage) (flowCheckpoint []byte, nextSeqNumList []int, err error) {
+func unmarshalDriverCheckpointJSON(raw json.RawMessage) (flowCheckpoint []byte, state FileState, err error) {
 	if len(raw) == 0 {
 		return
 	}
@@ -152,7 +219,12 @@ func unmarshalDriverCheckpointJSON(raw json.RawMessage) (flowCheckpoint []byte,
 		return
 	}
 
-	nextSeqNumList = parsed.NextSeqNumList
+	state = FileState{
+		NextSeqNumList:        parsed.NextSeqNumList,
+		NextPartitionSeqNums:  parsed.NextPartitionSeqNums,
+		PartitionSeqNumFloors: parsed.PartitionSeqNumFloors,
+		PendingIcebergFiles:   parsed.PendingIcebergFiles,
+	}
 	flowCheckpoint, err = base64.StdEncoding.DecodeString(parsed.B64EncodedFlowCheckpoint)
 	return
 }
@@ -223,6 +295,17 @@ func (driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Respo
 			}
 			constraints[projection.Field] = constraint
 		}
+
+		for _, p := range res.PartitionBy {
+			if err := validatePartitionField(p, binding.Collection.GetProjection(p.Field)); err != nil {
+				return nil, err
+			}
+			constraints[p.Field] = &pm.Response_Validated_Constraint{
+				Type:   pm.Response_Validated_Constraint_FIELD_REQUIRED,
+				Reason: "The field is used to partition files.",
+			}
+		}
+
 		out = append(out, &pm.Response_Validated_Binding{
 			Constraints: constraints,
 			// Only delta updates are supported by file materializations.
@@ -245,7 +328,7 @@ func (driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Transa
 		return nil, nil, fmt.Errorf("parsing endpoint config: %w", err)
 	}
 
-	runtimeCheckpoint, nextSeqNumList, err := unmarshalDriverCheckpointJSON(open.StateJson)
+	runtimeCheckpoint, fileState, err := unmarshalDriverCheckpointJSON(open.StateJson)
 	if err != nil {
 		// TODO(jixiang): How to resume the flow if there is a corrupt checkpoint? Always reset in code, or after a manual evaluation?
 		return nil, nil, fmt.Errorf("parsing CheckpointJson: %w", err)
@@ -257,7 +340,7 @@ func (driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Transa
 	}
 
 	var fileProcessor FileProcessor
-	fileProcessor, err = NewParquetFileProcessor(ctx, s3Uploader, nextSeqNumList, &open)
+	fileProcessor, err = NewParquetFileProcessor(ctx, s3Uploader, fileState, &open)
 	if err != nil {
 		return nil, nil, fmt.Errorf("creating parquet file processor: %w", err)
 	}
@@ -277,8 +360,10 @@ func (driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Transa
 		clock:             clock,
 		fileProcessor:     fileProcessorProxy,
 		runtimeCheckpoint: runtimeCheckpoint,
-		uploadInterval:    time.Duration(cfg.UploadIntervalInSeconds) * time.Second,
-		lastUploadTime:    clock.Now(),
+		// Files of the recovered checkpoint are appended to tables when it's acknowledged.
+		pendingIcebergFiles: fileState.PendingIcebergFiles,
+		uploadInterval:      time.Duration(cfg.UploadIntervalInSeconds) * time.Second,
+		lastUploadTime:      clock.Now(),
 	}
 
 	var cp *protocol.Checkpoint
@@ -298,12 +383,25 @@ type transactor struct {
 	fileProcessor        FileProcessor
 	driverCheckpointJSON json.RawMessage
 	runtimeCheckpoint    []byte
-	uploadInterval       time.Duration
-	lastUploadTime       time.Time
+	// Files of the most recent driverCheckpointJSON which are yet to be appended to Iceberg tables.
+	pendingIcebergFiles [][]checkpoint.IcebergDataFile
+	uploadInterval      time.Duration
+	lastUploadTime      time.Time
 }
 
-func (t *transactor) UnmarshalState(state json.RawMessage) error                  { return nil }
-func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) { return nil, nil }
+func (t *transactor) UnmarshalState(state json.RawMessage) error { return nil }
+func (t *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) {
+	// Files are appended to Iceberg tables only after the checkpoint which includes them has
+	// committed, since files of an uncommitted checkpoint are re-written upon recovery.
+	if len(t.pendingIcebergFiles) == 0 {
+		return nil, nil
+	} else if err := t.fileProcessor.AppendToTables(t.pendingIcebergFiles); err != nil {
+		return nil, err
+	}
+
+	t.pendingIcebergFiles = nil
+	return nil, nil
+}
 
 func (t *transactor) Load(it *m.LoadIterator, _ func(int, json.RawMessage) error) error {
 	for it.Next() {
@@ -348,12 +446,14 @@ func (t *transactor) maybeUpload() error {
 	var now = t.clock.Now()
 	if now.Sub(t.lastUploadTime) >= t.uploadInterval {
 		// Uploads the local file to cloud.
-		if nextSeqNumList, err := t.fileProcessor.Commit(); err != nil {
+		if state, err := t.fileProcessor.Commit(); err != nil {
 			return fmt.Errorf("uploading to cloud: %w,", err)
 		} else if t.driverCheckpointJSON, err = marshalDriverCheckpointJSON(
-			t.runtimeCheckpoint, nextSeqNumList,
+			t.runtimeCheckpoint, state,
 		); err != nil {
 			return fmt.Errorf("encoding driverCheckpointJson: %w", err)
+		} else {
+			t.pendingIcebergFiles = state.PendingIcebergFiles
 		}
 
 		t.lastUploadTime = now
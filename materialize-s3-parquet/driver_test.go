@@ -9,6 +9,7 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/bradleyjkemp/cupaloy"
+	"github.com/estuary/connectors/materialize-s3-parquet/checkpoint"
 	pm "github.com/estuary/flow/go/protocols/materialize"
 	"github.com/stretchr/testify/require"
 	"github.com/xitongsys/parquet-go/parquet"
@@ -61,35 +62,78 @@ func TestResource(t *testing.T) {
 	var invalidCompressionType = validResource
 	invalidCompressionType.CompressionType = "random"
 	require.Error(t, invalidCompressionType.Validate(), "expected validation error")
+
+	var csvResource = resource{PathPrefix: "test_path_prefix", Format: formatCSV}
+	require.NoError(t, csvResource.Validate())
+	require.Equal(t, "gzip", csvResource.compressionType())
+	csvResource.CompressionType = "snappy"
+	require.ErrorContains(t, csvResource.Validate(), `invalid compressionType "snappy" for format "csv"`)
+
+	var invalidFormat = validResource
+	invalidFormat.Format = "orc"
+	require.ErrorContains(t, invalidFormat.Validate(), `invalid format "orc"`)
+
+	var icebergAvro = resource{PathPrefix: "test_path_prefix", Format: formatAvro, TableFormat: tableFormatIceberg}
+	require.ErrorContains(t, icebergAvro.Validate(), `format "avro" is not supported with tableFormat "iceberg"`)
+
+	var partitioned = validResource
+	partitioned.PartitionBy = []partitionField{{Field: "ts", Name: "dt", Granularity: "day"}, {Field: "region"}}
+	require.NoError(t, partitioned.Validate())
+
+	var invalidGranularity = validResource
+	invalidGranularity.PartitionBy = []partitionField{{Field: "ts", Granularity: "week"}}
+	require.ErrorContains(t, invalidGranularity.Validate(), "invalid granularity")
+
+	var duplicatePartitionName = validResource
+	duplicatePartitionName.PartitionBy = []partitionField{{Field: "ts", Name: "region"}, {Field: "region"}}
+	require.ErrorContains(t, duplicatePartitionName.Validate(), "duplicate partition name")
 }
 
 func TestMarshalAndUnmarshalDriverCheckpointJson(t *testing.T) {
-	flowCheckpoint, nextSeqNumList, err := unmarshalDriverCheckpointJSON(nil)
+	flowCheckpoint, state, err := unmarshalDriverCheckpointJSON(nil)
 	require.Nil(t, flowCheckpoint)
-	require.Nil(t, nextSeqNumList)
+	require.Equal(t, FileState{}, state)
 	require.NoError(t, err)
 
-	require.Panics(t, func() { marshalDriverCheckpointJSON([]byte{}, []int{0}) }, "expected panics for empty checkpoint")
+	require.Panics(t, func() { marshalDriverCheckpointJSON([]byte{}, FileState{NextSeqNumList: []int{0}}) }, "expected panics for empty checkpoint")
 
 	testCases := []struct {
-		checkpoint     []byte
-		nextSeqNumList []int
+		checkpoint []byte
+		state      FileState
 	}{
-		{[]byte("checkpoint_a"), nil},
-		{[]byte("checkpoint_b"), []int{}},
-		{[]byte("checkpoint_c"), []int{1, 1, 2, 3, 4}},
+		{[]byte("checkpoint_a"), FileState{}},
+		{[]byte("checkpoint_b"), FileState{NextSeqNumList: []int{}}},
+		{[]byte("checkpoint_c"), FileState{NextSeqNumList: []int{1, 1, 2, 3, 4}}},
+		{[]byte("checkpoint_d"), FileState{
+			NextSeqNumList:       []int{0, 3},
+			NextPartitionSeqNums: []map[string]int{{"dt=2024-05-01": 2, "dt=2024-05-02": 1}, nil},
+		}},
+		{[]byte("checkpoint_f"), FileState{
+			NextSeqNumList:        []int{0},
+			NextPartitionSeqNums:  []map[string]int{{"dt=2024-05-03": 5}},
+			PartitionSeqNumFloors: []int{4},
+		}},
+		{[]byte("checkpoint_e"), FileState{
+			NextSeqNumList:      []int{2, 0},
+			PendingIcebergFiles: [][]checkpoint.IcebergDataFile{{{Path: "a/0000007b/000000001.parquet", SeqNum: 1, RecordCount: 10, FileSizeBytes: 100}}, nil},
+		}},
 	}
 
 	for _, test := range testCases {
-		marshaledDCJ, err := marshalDriverCheckpointJSON(test.checkpoint, test.nextSeqNumList)
+		marshaledDCJ, err := marshalDriverCheckpointJSON(test.checkpoint, test.state)
 		require.NoError(t, err)
 
-		checkpoint, nextSeqNumList, err := unmarshalDriverCheckpointJSON(marshaledDCJ)
+		checkpoint, state, err := unmarshalDriverCheckpointJSON(marshaledDCJ)
 		require.NoError(t, err)
 		require.Equal(t, test.checkpoint, checkpoint)
-		require.Equal(t, test.nextSeqNumList, nextSeqNumList)
+		require.Equal(t, test.state, state)
 	}
-	require.Panics(t, func() { marshalDriverCheckpointJSON(nil, []int{}) })
+	require.Panics(t, func() { marshalDriverCheckpointJSON(nil, FileState{NextSeqNumList: []int{}}) })
+
+	// Checkpoints of materializations which aren't partitioned are unchanged.
+	marshaledDCJ, err := marshalDriverCheckpointJSON([]byte("checkpoint"), FileState{NextSeqNumList: []int{1}})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"b64EncodedFlowCheckpoint":"Y2hlY2twb2ludA==","nextSeqNumList":[1]}`, string(marshaledDCJ))
 }
 
 func TestS3ParquetDriverSpec(t *testing.T) {
@@ -106,7 +150,7 @@ func TestTransactor(t *testing.T) {
 	var mockNextSeqNumList = []int{1, 2, 3}
 	var transactor = &transactor{
 		clock:                mockClock,
-		fileProcessor:        newMockFileProcessor(mockNextSeqNumList),
+		fileProcessor:        newMockFileProcessor(mockNextSeqNumList, nil),
 		driverCheckpointJSON: nil,
 		runtimeCheckpoint:    nil,
 		uploadInterval:       time.Second,
@@ -124,6 +168,38 @@ func TestTransactor(t *testing.T) {
 	// driverCheckpoint is set after an upload-to-cloud action.
 	mockClock.Add(time.Second * 2)
 	require.NoError(t, transactor.maybeUpload())
-	var expected, _ = marshalDriverCheckpointJSON(testFlowCheckpoint, mockNextSeqNumList)
+	var expected, _ = marshalDriverCheckpointJSON(testFlowCheckpoint, FileState{NextSeqNumList: mockNextSeqNumList})
 	require.Equal(t, 0, bytes.Compare(transactor.driverCheckpointJSON, expected))
 }
+
+func TestTransactorAcknowledge(t *testing.T) {
+	var mockClock = clock.NewMock()
+	var pending = [][]checkpoint.IcebergDataFile{{{Path: "a/0000007b/000000000.parquet", RecordCount: 1, FileSizeBytes: 10}}}
+	var mockFileProcessor = newMockFileProcessor([]int{1}, nil)
+	mockFileProcessor.MockPendingIcebergFiles = pending
+
+	var transactor = &transactor{
+		clock:             mockClock,
+		fileProcessor:     mockFileProcessor,
+		runtimeCheckpoint: []byte("test_checkPoint"),
+		uploadInterval:    time.Second,
+		lastUploadTime:    mockClock.Now(),
+	}
+
+	// Nothing is appended if there are no pending files.
+	_, err := transactor.Acknowledge(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, mockFileProcessor.AppendedIcebergFiles)
+
+	// Pending files of an uploaded checkpoint are appended when it's acknowledged.
+	mockClock.Add(time.Second * 2)
+	require.NoError(t, transactor.maybeUpload())
+	_, err = transactor.Acknowledge(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, [][][]checkpoint.IcebergDataFile{pending}, mockFileProcessor.AppendedIcebergFiles)
+
+	// And only once.
+	_, err = transactor.Acknowledge(context.Background())
+	require.NoError(t, err)
+	require.Len(t, mockFileProcessor.AppendedIcebergFiles, 1)
+}
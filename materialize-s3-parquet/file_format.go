@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+
+	// TODO revisit this after https://issues.apache.org/jira/browse/ARROW-13986 is completed.
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Formats of the files written for a binding.
+const (
+	formatParquet = "parquet"
+	formatAvro    = "avro"
+	formatCSV     = "csv"
+	formatJSONL   = "jsonl"
+)
+
+// formatCompressionTypes are the compression types supported by each format. The first is the
+// default compression type of the format.
+var formatCompressionTypes = map[string][]string{
+	formatParquet: {"snappy", "none", "gzip", "lz4", "zstd"},
+	formatAvro:    {"deflate", "none"},
+	formatCSV:     {"gzip", "none"},
+	formatJSONL:   {"gzip", "none"},
+}
+
+// A fileFormat creates encoders of local files in a format, which are uploaded with its
+// content type and extension.
+type fileFormat interface {
+	// Returns the extension of files, including the leading dot.
+	extension() string
+	// Returns the content type of uploaded files.
+	contentType() string
+	// Creates a local file, and returns an encoder of rows into it.
+	newEncoder(localFileName string) (fileEncoder, error)
+}
+
+// A fileEncoder encodes the rows of a binding into a local file.
+type fileEncoder interface {
+	// Encodes the key/values as a row of the file.
+	encode(key tuple.Tuple, values tuple.Tuple) error
+	// Returns the approximate size of the file in bytes, including encoded rows which are
+	// buffered and not yet written.
+	size() int64
+	// Flushes encoded rows and closes the local file.
+	close() error
+}
+
+// newFileFormat returns the fileFormat of the resource, which encodes rows using the converter.
+func newFileFormat(res resource, converter *ParquetDataConverter) (fileFormat, error) {
+	switch res.format() {
+	case formatParquet:
+		return &parquetFormat{converter: converter, codec: res.CompressionCodec(), rowGroupSize: res.RowGroupSizeBytes}, nil
+	case formatAvro:
+		var codec = ocf.Deflate
+		if res.compressionType() == "none" {
+			codec = ocf.Null
+		}
+		return newAvroFormat(converter, codec)
+	case formatCSV:
+		return &csvFormat{converter: converter, gzip: res.compressionType() == "gzip"}, nil
+	case formatJSONL:
+		return &jsonlFormat{converter: converter, gzip: res.compressionType() == "gzip"}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", res.Format)
+	}
+}
+
+// parquetFormat implements the fileFormat interface for parquet files.
+type parquetFormat struct {
+	converter *ParquetDataConverter
+	codec     parquet.CompressionCodec
+	// The size of row groups in bytes, or zero for the default of the parquet writer.
+	rowGroupSize int64
+}
+
+func (f *parquetFormat) extension() string   { return ".parquet" }
+func (f *parquetFormat) contentType() string { return pqContentType }
+
+func (f *parquetFormat) newEncoder(localFileName string) (fileEncoder, error) {
+	var e = &parquetEncoder{converter: f.converter}
+
+	var err error
+	if e.localFile, err = local.NewLocalFileWriter(localFileName); err != nil {
+		return nil, fmt.Errorf("creating local file: %w", err)
+	} else if e.pqWriter, err = writer.NewParquetWriter(
+		e.localFile,
+		f.converter.JSONFileSchema(),
+		parquetWriterParallelNumber); err != nil {
+		return nil, fmt.Errorf("creating parquet writer: %w", err)
+	}
+	e.pqWriter.CompressionType = f.codec
+	if f.rowGroupSize != 0 {
+		e.pqWriter.RowGroupSize = f.rowGroupSize
+	}
+
+	return e, nil
+}
+
+type parquetEncoder struct {
+	converter *ParquetDataConverter
+	pqWriter  *writer.ParquetWriter
+	localFile source.ParquetFile
+}
+
+func (e *parquetEncoder) encode(key tuple.Tuple, values tuple.Tuple) error {
+	pqData, err := e.converter.Convert(key, values)
+	if err != nil {
+		return fmt.Errorf("converting data: %w", err)
+	} else if err = e.pqWriter.Write(pqData); err != nil {
+		return fmt.Errorf("writing to parquet: %w", err)
+	}
+	return nil
+}
+
+func (e *parquetEncoder) size() int64 {
+	// The writer's offset is the size of row groups written to the file, followed by the encoded
+	// pages and the objects of the current row group.
+	return e.pqWriter.Offset + e.pqWriter.Size + e.pqWriter.ObjsSize
+}
+
+func (e *parquetEncoder) close() error {
+	if err := e.pqWriter.WriteStop(); err != nil {
+		return fmt.Errorf("stopping writer: %w", err)
+	} else if err := e.localFile.Close(); err != nil {
+		return fmt.Errorf("closing localFile: %w", err)
+	}
+	return nil
+}
+
+const avroContentType string = "application/avro"
+
+// The number of rows of each block of Avro files.
+const avroBlockLength = 100
+
+// avroFormat implements the fileFormat interface for Avro object container files.
+type avroFormat struct {
+	converter *ParquetDataConverter
+	schema    *avro.RecordSchema
+	codec     ocf.CodecName
+}
+
+func newAvroFormat(converter *ParquetDataConverter, codec ocf.CodecName) (*avroFormat, error) {
+	var fields []*avro.Field
+	var fieldNames = make(map[string]string)
+
+	for _, field := range converter.pqFields {
+		// Avro names are more restrictive than the names of fields.
+		var name = avroName(field.name)
+		if other, ok := fieldNames[name]; ok {
+			return nil, fmt.Errorf("fields %q and %q have the same avro name %q", other, field.name, name)
+		}
+		fieldNames[name] = field.name
+
+		var schema avro.Schema = avro.NewPrimitiveSchema(field.typeStrategy.avroType(), nil)
+		var opts []avro.SchemaOption
+		if field.optional {
+			var err error
+			if schema, err = avro.NewUnionSchema([]avro.Schema{avro.NewPrimitiveSchema(avro.Null, nil), schema}); err != nil {
+				return nil, err
+			}
+			opts = append(opts, avro.WithDefault(nil))
+		}
+
+		f, err := avro.NewField(name, schema, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating avro field %q: %w", name, err)
+		}
+		fields = append(fields, f)
+	}
+
+	schema, err := avro.NewRecordSchema("row", "", fields)
+	if err != nil {
+		return nil, fmt.Errorf("creating avro schema: %w", err)
+	}
+	return &avroFormat{converter: converter, schema: schema, codec: codec}, nil
+}
+
+// avroName replaces characters of the field name which are not permitted in Avro names, which
+// also may not begin with a digit.
+func avroName(field string) string {
+	var name = []byte(field)
+	for i, c := range name {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+		return "_" + string(name)
+	}
+	return string(name)
+}
+
+func (f *avroFormat) extension() string   { return ".avro" }
+func (f *avroFormat) contentType() string { return avroContentType }
+
+func (f *avroFormat) newEncoder(localFileName string) (fileEncoder, error) {
+	file, err := newLocalFile(localFileName, false)
+	if err != nil {
+		return nil, err
+	}
+	w, err := ocf.NewEncoder(f.schema.String(), file, ocf.WithCodec(f.codec), ocf.WithBlockLength(avroBlockLength))
+	if err != nil {
+		file.close()
+		return nil, fmt.Errorf("creating avro writer: %w", err)
+	}
+	return &avroEncoder{format: f, file: file, w: w}, nil
+}
+
+type avroEncoder struct {
+	format *avroFormat
+	file   *localFile
+	w      *ocf.Encoder
+	// The number of rows and bytes of the block which is being encoded.
+	rows     int
+	buffered int64
+}
+
+func (e *avroEncoder) encode(key tuple.Tuple, values tuple.Tuple) error {
+	row, err := e.format.converter.Values(key, values)
+	if err != nil {
+		return fmt.Errorf("converting data: %w", err)
+	}
+
+	var record = make(map[string]interface{}, len(row))
+	for i, v := range row {
+		record[e.format.schema.Fields()[i].Name()] = v
+	}
+	// Rows are encoded here rather than by the writer, so that the size of its current block
+	// is known.
+	raw, err := avro.Marshal(e.format.schema, record)
+	if err != nil {
+		return fmt.Errorf("encoding avro: %w", err)
+	} else if _, err := e.w.Write(raw); err != nil {
+		return fmt.Errorf("writing to avro: %w", err)
+	}
+
+	if e.rows++; e.rows == avroBlockLength {
+		e.rows, e.buffered = 0, 0
+	} else {
+		e.buffered += int64(len(raw))
+	}
+	return nil
+}
+
+func (e *avroEncoder) size() int64 { return e.file.size() + e.buffered }
+
+func (e *avroEncoder) close() error {
+	if err := e.w.Close(); err != nil {
+		e.file.close()
+		return fmt.Errorf("closing avro writer: %w", err)
+	}
+	return e.file.close()
+}
+
+const (
+	csvContentType   string = "text/csv"
+	jsonlContentType string = "application/x-ndjson"
+	gzipContentType  string = "application/gzip"
+)
+
+// csvFormat implements the fileFormat interface for CSV files having a header row of field names.
+type csvFormat struct {
+	converter *ParquetDataConverter
+	gzip      bool
+}
+
+func (f *csvFormat) extension() string {
+	if f.gzip {
+		return ".csv.gz"
+	}
+	return ".csv"
+}
+
+func (f *csvFormat) contentType() string {
+	if f.gzip {
+		return gzipContentType
+	}
+	return csvContentType
+}
+
+func (f *csvFormat) newEncoder(localFileName string) (fileEncoder, error) {
+	file, err := newLocalFile(localFileName, f.gzip)
+	if err != nil {
+		return nil, err
+	}
+	var e = &csvEncoder{converter: f.converter, file: file, w: csv.NewWriter(file)}
+
+	var header = make([]string, 0, len(f.converter.pqFields))
+	for _, field := range f.converter.pqFields {
+		header = append(header, field.name)
+	}
+	if err := e.w.Write(header); err != nil {
+		file.close()
+		return nil, fmt.Errorf("writing csv header: %w", err)
+	}
+	return e, nil
+}
+
+type csvEncoder struct {
+	converter *ParquetDataConverter
+	file      *localFile
+	w         *csv.Writer
+	record    []string
+}
+
+func (e *csvEncoder) encode(key tuple.Tuple, values tuple.Tuple) error {
+	row, err := e.converter.Values(key, values)
+	if err != nil {
+		return fmt.Errorf("converting data: %w", err)
+	}
+
+	e.record = e.record[:0]
+	for _, v := range row {
+		e.record = append(e.record, csvValue(v))
+	}
+	if err := e.w.Write(e.record); err != nil {
+		return fmt.Errorf("writing to csv: %w", err)
+	}
+	return nil
+}
+
+// csvValue formats a value of a field. Null values are empty.
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (e *csvEncoder) size() int64 {
+	// Rows are written through to the local file when they're flushed from the csv writer.
+	e.w.Flush()
+	return e.file.size()
+}
+
+func (e *csvEncoder) close() error {
+	if e.w.Flush(); e.w.Error() != nil {
+		return fmt.Errorf("flushing csv: %w", e.w.Error())
+	}
+	return e.file.close()
+}
+
+// jsonlFormat implements the fileFormat interface for JSON Lines files, having a JSON object of
+// the fields of each row.
+type jsonlFormat struct {
+	converter *ParquetDataConverter
+	gzip      bool
+}
+
+func (f *jsonlFormat) extension() string {
+	if f.gzip {
+		return ".jsonl.gz"
+	}
+	return ".jsonl"
+}
+
+func (f *jsonlFormat) contentType() string {
+	if f.gzip {
+		return gzipContentType
+	}
+	return jsonlContentType
+}
+
+func (f *jsonlFormat) newEncoder(localFileName string) (fileEncoder, error) {
+	file, err := newLocalFile(localFileName, f.gzip)
+	if err != nil {
+		return nil, err
+	}
+
+	// Field names are encoded once, and object and array fields are written as JSON rather than
+	// as the strings of other formats.
+	var e = &jsonlEncoder{converter: f.converter, file: file}
+	for _, field := range f.converter.pqFields {
+		name, err := json.Marshal(field.name)
+		if err != nil {
+			file.close()
+			return nil, fmt.Errorf("encoding field name: %w", err)
+		}
+		_, isJSON := field.typeStrategy.(*jsonStrategy)
+		e.names = append(e.names, name)
+		e.isJSON = append(e.isJSON, isJSON)
+	}
+	return e, nil
+}
+
+type jsonlEncoder struct {
+	converter *ParquetDataConverter
+	file      *localFile
+	names     [][]byte
+	isJSON    []bool
+	line      []byte
+}
+
+func (e *jsonlEncoder) encode(key tuple.Tuple, values tuple.Tuple) error {
+	row, err := e.converter.Values(key, values)
+	if err != nil {
+		return fmt.Errorf("converting data: %w", err)
+	}
+
+	e.line = append(e.line[:0], '{')
+	for i, v := range row {
+		if i != 0 {
+			e.line = append(e.line, ',')
+		}
+		e.line = append(append(e.line, e.names[i]...), ':')
+
+		if s, ok := v.(string); ok && e.isJSON[i] {
+			e.line = append(e.line, s...)
+		} else if encoded, err := json.Marshal(v); err != nil {
+			return fmt.Errorf("encoding field %s: %w", e.names[i], err)
+		} else {
+			e.line = append(e.line, encoded...)
+		}
+	}
+	e.line = append(e.line, '}', '\n')
+
+	if _, err := e.file.Write(e.line); err != nil {
+		return fmt.Errorf("writing to jsonl: %w", err)
+	}
+	return nil
+}
+
+func (e *jsonlEncoder) size() int64  { return e.file.size() }
+func (e *jsonlEncoder) close() error { return e.file.close() }
+
+// localFile is a buffered local file, which is optionally gzip-compressed.
+type localFile struct {
+	file *os.File
+	buf  *bufio.Writer
+	gz   *gzip.Writer
+	// The number of bytes written to the file.
+	written int64
+}
+
+func newLocalFile(name string, compress bool) (*localFile, error) {
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("creating local file: %w", err)
+	}
+	var f = &localFile{file: file}
+	f.buf = bufio.NewWriter(writerFunc(func(p []byte) (int, error) {
+		n, err := file.Write(p)
+		f.written += int64(n)
+		return n, err
+	}))
+	if compress {
+		f.gz = gzip.NewWriter(f.buf)
+	}
+	return f, nil
+}
+
+func (f *localFile) Write(p []byte) (int, error) {
+	if f.gz != nil {
+		return f.gz.Write(p)
+	}
+	return f.buf.Write(p)
+}
+
+// size returns the size of the file, including buffered bytes. Bytes which are buffered by the
+// gzip writer are not included.
+func (f *localFile) size() int64 { return f.written + int64(f.buf.Buffered()) }
+
+func (f *localFile) close() error {
+	if f.gz != nil {
+		if err := f.gz.Close(); err != nil {
+			f.file.Close()
+			return fmt.Errorf("closing gzip writer: %w", err)
+		}
+	}
+	if err := f.buf.Flush(); err != nil {
+		f.file.Close()
+		return fmt.Errorf("flushing local file: %w", err)
+	} else if err := f.file.Close(); err != nil {
+		return fmt.Errorf("closing localFile: %w", err)
+	}
+	return nil
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// validateFormat returns an error if the format or its compression type are not supported.
+func validateFormat(format, compressionType string) error {
+	var compressionTypes, ok = formatCompressionTypes[format]
+	if !ok {
+		return fmt.Errorf("invalid format %q, expecting one of %s", format,
+			strings.Join([]string{formatParquet, formatAvro, formatCSV, formatJSONL}, ", "))
+	} else if compressionType == "" {
+		return nil
+	}
+	for _, t := range compressionTypes {
+		if t == compressionType {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid compressionType %q for format %q, expecting one of %v", compressionType, format, compressionTypes)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/hamba/avro/v2/ocf"
+	"github.com/stretchr/testify/require"
+)
+
+func storeFormatTestRows(t *testing.T, res resource) (*MockS3Uploader, string) {
+	var mockS3Uploader = newMockS3Uploader(t)
+	var open = buildTestOpenRequest(1)
+	var binding = open.Materialization.Bindings[0]
+	binding.ResourceConfigJson, _ = json.Marshal(res)
+	binding.FieldSelection.Values = append(binding.FieldSelection.Values, "_meta/op", "doc")
+	// Projections are ordered on their field.
+	binding.Collection.Projections = append(binding.Collection.Projections,
+		pf.Projection{Field: "_meta/op", Inference: pf.Inference{Types: []string{"string", "null"}}},
+		pf.Projection{Field: "doc", Inference: pf.Inference{Types: []string{"object"}, Exists: pf.Inference_MUST}},
+	)
+
+	fileProcessor, err := NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{}, open)
+	require.NoError(t, err)
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{1}, tuple.Tuple{"a,\"b\"", "c", json.RawMessage(`{"x":1}`)}))
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{2}, tuple.Tuple{"d", nil, json.RawMessage(`[]`)}))
+
+	state, err := fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, state.NextSeqNumList)
+
+	return mockS3Uploader, "test_path_0/0000007b/000000000"
+}
+
+func gunzip(t *testing.T, data []byte) string {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestFileFormats(t *testing.T) {
+	t.Run("csv", func(t *testing.T) {
+		var u, path = storeFormatTestRows(t, resource{PathPrefix: "test_path_0", Format: formatCSV})
+		require.Equal(t, "Id,Message,_meta/op,doc\n1,\"a,\"\"b\"\"\",c,\"{\"\"x\"\":1}\"\n2,d,,[]\n",
+			gunzip(t, u.files[path+".csv.gz"]))
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		var u, path = storeFormatTestRows(t, resource{PathPrefix: "test_path_0", Format: formatJSONL, CompressionType: "none"})
+		require.Equal(t, `{"Id":1,"Message":"a,\"b\"","_meta/op":"c","doc":{"x":1}}`+"\n"+
+			`{"Id":2,"Message":"d","_meta/op":null,"doc":[]}`+"\n",
+			string(u.files[path+".jsonl"]))
+	})
+
+	t.Run("avro", func(t *testing.T) {
+		var u, path = storeFormatTestRows(t, resource{PathPrefix: "test_path_0", Format: formatAvro})
+		dec, err := ocf.NewDecoder(bytes.NewReader(u.files[path+".avro"]))
+		require.NoError(t, err)
+		require.Equal(t, []byte(ocf.Deflate), dec.Metadata()["avro.codec"])
+
+		var values []map[string]interface{}
+		for dec.HasNext() {
+			var v map[string]interface{}
+			require.NoError(t, dec.Decode(&v))
+			values = append(values, v)
+		}
+		require.NoError(t, dec.Error())
+		require.Equal(t, []map[string]interface{}{
+			{"Id": int64(1), "Message": "a,\"b\"", "_meta_op": "c", "doc": `{"x":1}`},
+			{"Id": int64(2), "Message": "d", "_meta_op": nil, "doc": `[]`},
+		}, values)
+	})
+}
+
+func TestAvroName(t *testing.T) {
+	require.Equal(t, "_meta_op", avroName("_meta/op"))
+	require.Equal(t, "_1st", avroName("1st"))
+	require.Equal(t, "a1_b", avroName("a1-b"))
+	require.Equal(t, "_", avroName(""))
+
+	var converter = &ParquetDataConverter{pqFields: []*pqField{newStringField("a-b", false), newStringField("a/b", true)}}
+	_, err := newAvroFormat(converter, ocf.Null)
+	require.ErrorContains(t, err, `fields "a-b" and "a/b" have the same avro name "a_b"`)
+}
@@ -0,0 +1,1122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/estuary/connectors/materialize-s3-parquet/checkpoint"
+	"github.com/google/uuid"
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+	log "github.com/sirupsen/logrus"
+)
+
+// The format version of Iceberg tables written by the connector.
+const icebergFormatVersion = 2
+
+const (
+	icebergMetadataContentType = "application/json"
+	icebergManifestContentType = "avro/binary"
+	icebergVersionContentType  = "text/plain"
+)
+
+// Prefix of the snapshot summary properties which record the next sequence number of the files
+// of each shard which writes to the table. It's suffixed with the `KeyBegin` of the shard.
+const icebergSeqNumPropertyPrefix = "flow.next-seq-num."
+
+// icebergMetadata is the metadata of an Iceberg table, as stored in its `vN.metadata.json` files.
+type icebergMetadata struct {
+	FormatVersion      int                       `json:"format-version"`
+	TableUUID          string                    `json:"table-uuid"`
+	Location           string                    `json:"location"`
+	LastSequenceNumber int64                     `json:"last-sequence-number"`
+	LastUpdatedMs      int64                     `json:"last-updated-ms"`
+	LastColumnID       int                       `json:"last-column-id"`
+	CurrentSchemaID    int                       `json:"current-schema-id"`
+	Schemas            []icebergSchema           `json:"schemas"`
+	DefaultSpecID      int                       `json:"default-spec-id"`
+	PartitionSpecs     []icebergPartitionSpec    `json:"partition-specs"`
+	LastPartitionID    int                       `json:"last-partition-id"`
+	DefaultSortOrderID int                       `json:"default-sort-order-id"`
+	SortOrders         []icebergSortOrder        `json:"sort-orders"`
+	Properties         map[string]string         `json:"properties,omitempty"`
+	CurrentSnapshotID  *int64                    `json:"current-snapshot-id,omitempty"`
+	Refs               map[string]icebergRef     `json:"refs,omitempty"`
+	Snapshots          []icebergSnapshot         `json:"snapshots"`
+	SnapshotLog        []icebergSnapshotLogEntry `json:"snapshot-log"`
+	MetadataLog        []icebergMetadataLogEntry `json:"metadata-log"`
+	Statistics         []map[string]interface{}  `json:"statistics,omitempty"`
+	PartitionStats     []map[string]interface{}  `json:"partition-statistics,omitempty"`
+}
+
+type icebergSchema struct {
+	Type     string         `json:"type"`
+	SchemaID int            `json:"schema-id"`
+	Fields   []icebergField `json:"fields"`
+}
+
+type icebergField struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	Type     string `json:"type"`
+}
+
+type icebergPartitionSpec struct {
+	SpecID int           `json:"spec-id"`
+	Fields []interface{} `json:"fields"`
+}
+
+type icebergSortOrder struct {
+	OrderID int           `json:"order-id"`
+	Fields  []interface{} `json:"fields"`
+}
+
+type icebergRef struct {
+	SnapshotID int64  `json:"snapshot-id"`
+	Type       string `json:"type"`
+}
+
+type icebergSnapshot struct {
+	SnapshotID       int64             `json:"snapshot-id"`
+	ParentSnapshotID *int64            `json:"parent-snapshot-id,omitempty"`
+	SequenceNumber   int64             `json:"sequence-number"`
+	TimestampMs      int64             `json:"timestamp-ms"`
+	ManifestList     string            `json:"manifest-list"`
+	Summary          map[string]string `json:"summary"`
+	SchemaID         int               `json:"schema-id"`
+}
+
+type icebergSnapshotLogEntry struct {
+	TimestampMs int64 `json:"timestamp-ms"`
+	SnapshotID  int64 `json:"snapshot-id"`
+}
+
+type icebergMetadataLogEntry struct {
+	TimestampMs  int64  `json:"timestamp-ms"`
+	MetadataFile string `json:"metadata-file"`
+}
+
+func (m *icebergMetadata) currentSchema() *icebergSchema {
+	for i := range m.Schemas {
+		if m.Schemas[i].SchemaID == m.CurrentSchemaID {
+			return &m.Schemas[i]
+		}
+	}
+	return nil
+}
+
+func (m *icebergMetadata) currentSnapshot() *icebergSnapshot {
+	if m.CurrentSnapshotID == nil {
+		return nil
+	}
+	for i := range m.Snapshots {
+		if m.Snapshots[i].SnapshotID == *m.CurrentSnapshotID {
+			return &m.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+// Limits of the history which is retained by the table. Snapshots beyond the most recent
+// icebergMaxSnapshots are expired, together with the manifests and manifest lists which only they
+// reference, and metadata files beyond the most recent icebergMaxMetadataLog are deleted. When a
+// snapshot would list more than icebergMaxManifests manifests, its manifests are merged.
+const (
+	icebergMaxSnapshots   = 100
+	icebergMaxMetadataLog = 100
+	icebergMaxManifests   = 100
+)
+
+// The maximum number of attempts to commit a snapshot, which are retried when another writer
+// concurrently commits a version of the table.
+const icebergMaxCommitAttempts = 10
+
+// Statuses of manifest entries.
+const (
+	icebergEntryExisting = 0
+	icebergEntryAdded    = 1
+	icebergEntryDeleted  = 2
+)
+
+// Avro schemas of Iceberg manifests and manifest lists, with the field IDs of the Iceberg spec.
+// Optional fields which the connector doesn't populate are omitted.
+var (
+	icebergManifestEntrySchema = avro.MustParse(`{
+		"type": "record",
+		"name": "manifest_entry",
+		"fields": [
+			{"name": "status", "type": "int", "field-id": 0},
+			{"name": "snapshot_id", "type": ["null", "long"], "default": null, "field-id": 1},
+			{"name": "sequence_number", "type": ["null", "long"], "default": null, "field-id": 3},
+			{"name": "file_sequence_number", "type": ["null", "long"], "default": null, "field-id": 4},
+			{"name": "data_file", "field-id": 2, "type": {
+				"type": "record",
+				"name": "r2",
+				"fields": [
+					{"name": "content", "type": "int", "field-id": 134},
+					{"name": "file_path", "type": "string", "field-id": 100},
+					{"name": "file_format", "type": "string", "field-id": 101},
+					{"name": "partition", "type": {"type": "record", "name": "r102", "fields": []}, "field-id": 102},
+					{"name": "record_count", "type": "long", "field-id": 103},
+					{"name": "file_size_in_bytes", "type": "long", "field-id": 104}
+				]
+			}}
+		]
+	}`)
+
+	icebergManifestFileSchema = avro.MustParse(`{
+		"type": "record",
+		"name": "manifest_file",
+		"fields": [
+			{"name": "manifest_path", "type": "string", "field-id": 500},
+			{"name": "manifest_length", "type": "long", "field-id": 501},
+			{"name": "partition_spec_id", "type": "int", "field-id": 502},
+			{"name": "content", "type": "int", "field-id": 517},
+			{"name": "sequence_number", "type": "long", "field-id": 515},
+			{"name": "min_sequence_number", "type": "long", "field-id": 516},
+			{"name": "added_snapshot_id", "type": "long", "field-id": 503},
+			{"name": "added_files_count", "type": "int", "field-id": 504},
+			{"name": "existing_files_count", "type": "int", "field-id": 505},
+			{"name": "deleted_files_count", "type": "int", "field-id": 506},
+			{"name": "added_rows_count", "type": "long", "field-id": 512},
+			{"name": "existing_rows_count", "type": "long", "field-id": 513},
+			{"name": "deleted_rows_count", "type": "long", "field-id": 514},
+			{"name": "partitions", "default": null, "field-id": 507, "type": ["null", {
+				"type": "array",
+				"element-id": 508,
+				"items": {
+					"type": "record",
+					"name": "r508",
+					"fields": [
+						{"name": "contains_null", "type": "boolean", "field-id": 509},
+						{"name": "contains_nan", "type": ["null", "boolean"], "default": null, "field-id": 518},
+						{"name": "lower_bound", "type": ["null", "bytes"], "default": null, "field-id": 510},
+						{"name": "upper_bound", "type": ["null", "bytes"], "default": null, "field-id": 511}
+					]
+				}
+			}]}
+		]
+	}`)
+)
+
+// icebergManifestEntry is a record of a manifest, which adds or tracks a data file.
+type icebergManifestEntry struct {
+	Status int32 `avro:"status"`
+	// The snapshot ID and sequence numbers are inherited from the manifest if they're nil.
+	SnapshotID         *int64          `avro:"snapshot_id"`
+	SequenceNumber     *int64          `avro:"sequence_number"`
+	FileSequenceNumber *int64          `avro:"file_sequence_number"`
+	DataFile           icebergDataFile `avro:"data_file"`
+}
+
+type icebergDataFile struct {
+	Content    int32  `avro:"content"`
+	FilePath   string `avro:"file_path"`
+	FileFormat string `avro:"file_format"`
+	// Tables are unpartitioned, and have an empty partition tuple.
+	Partition       struct{} `avro:"partition"`
+	RecordCount     int64    `avro:"record_count"`
+	FileSizeInBytes int64    `avro:"file_size_in_bytes"`
+}
+
+// icebergManifestFile is a record of a manifest list, which lists a manifest of the snapshot.
+type icebergManifestFile struct {
+	ManifestPath       string                 `avro:"manifest_path"`
+	ManifestLength     int64                  `avro:"manifest_length"`
+	PartitionSpecID    int32                  `avro:"partition_spec_id"`
+	Content            int32                  `avro:"content"`
+	SequenceNumber     int64                  `avro:"sequence_number"`
+	MinSequenceNumber  int64                  `avro:"min_sequence_number"`
+	AddedSnapshotID    int64                  `avro:"added_snapshot_id"`
+	AddedFilesCount    int32                  `avro:"added_files_count"`
+	ExistingFilesCount int32                  `avro:"existing_files_count"`
+	DeletedFilesCount  int32                  `avro:"deleted_files_count"`
+	AddedRowsCount     int64                  `avro:"added_rows_count"`
+	ExistingRowsCount  int64                  `avro:"existing_rows_count"`
+	DeletedRowsCount   int64                  `avro:"deleted_rows_count"`
+	Partitions         *[]icebergFieldSummary `avro:"partitions"`
+}
+
+type icebergFieldSummary struct {
+	ContainsNull bool    `avro:"contains_null"`
+	ContainsNaN  *bool   `avro:"contains_nan"`
+	LowerBound   *[]byte `avro:"lower_bound"`
+	UpperBound   *[]byte `avro:"upper_bound"`
+}
+
+// icebergTable appends the parquet files of a binding to an Apache Iceberg table, which is
+// cataloged in the manner of a Hadoop catalog: versions of the table metadata are written to
+// `metadata/vN.metadata.json` below the table location, and `metadata/version-hint.text` holds
+// the current version. The table is created by its first append.
+//
+// Shards, and zombie instances of a shard, may commit to the table concurrently. Each version of
+// the metadata is created only if it doesn't already exist, and a commit which loses the race
+// for a version is retried against the metadata of the version which won it.
+type icebergTable struct {
+	ctx      context.Context
+	uploader Uploader
+	// URI of the bucket, like `s3://bucket`.
+	bucketURI string
+	// Key of the table location within the bucket.
+	location string
+	tmpDir   string
+	// The `KeyBegin` of the shard, which identifies the sequence numbers of its files within the
+	// summaries of snapshots.
+	keyBegin uint32
+	// Fields of the parquet files written to the table, and the IDs of their columns in the
+	// schema of the table.
+	pqFields []*pqField
+	fieldIDs map[string]int
+	// Current metadata of the table and its version, which are nil and zero if the table does
+	// not exist yet.
+	metadata *icebergMetadata
+	version  int
+	// Limits of the history which is retained by the table.
+	maxSnapshots   int
+	maxMetadataLog int
+	maxManifests   int
+
+	now func() time.Time
+}
+
+func newIcebergTable(
+	ctx context.Context,
+	uploader Uploader,
+	bucket string,
+	location string,
+	tmpDir string,
+	keyBegin uint32,
+	converter *ParquetDataConverter,
+) (*icebergTable, error) {
+	var t = &icebergTable{
+		ctx:       ctx,
+		uploader:  uploader,
+		bucketURI: "s3://" + bucket,
+		location:  strings.TrimSuffix(location, "/"),
+		tmpDir:    tmpDir,
+		keyBegin:  keyBegin,
+		pqFields:  converter.pqFields,
+
+		maxSnapshots:   icebergMaxSnapshots,
+		maxMetadataLog: icebergMaxMetadataLog,
+		maxManifests:   icebergMaxManifests,
+		now:            time.Now,
+	}
+
+	if err := t.load(); err != nil {
+		return nil, fmt.Errorf("loading iceberg table metadata: %w", err)
+	}
+
+	fields, _, err := t.schemaFields()
+	if err != nil {
+		return nil, err
+	}
+
+	// Parquet files identify their columns by the IDs of the table schema.
+	t.fieldIDs = make(map[string]int, len(fields))
+	var fieldIDs = make(map[string]int32, len(fields))
+	for _, f := range fields {
+		t.fieldIDs[f.Name] = f.ID
+		fieldIDs[f.Name] = int32(f.ID)
+	}
+	converter.SetFieldIDs(fieldIDs)
+
+	return t, nil
+}
+
+// schemaFields returns the fields of the table schema with which the parquet files of the shard
+// are appended to the current metadata, and the last column ID of the table.
+func (t *icebergTable) schemaFields() ([]icebergField, int, error) {
+	var current []icebergField
+	var lastColumnID int
+	if t.metadata != nil {
+		lastColumnID = t.metadata.LastColumnID
+		if schema := t.metadata.currentSchema(); schema != nil {
+			current = schema.Fields
+		}
+	}
+
+	fields, lastColumnID, err := evolveIcebergSchema(current, lastColumnID, t.metadata == nil, t.pqFields)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Files which were written with the IDs of the columns when the table was loaded can only be
+	// appended if another writer hasn't since assigned different IDs to those columns.
+	for _, f := range fields {
+		if id, ok := t.fieldIDs[f.Name]; ok && id != f.ID {
+			return nil, 0, fmt.Errorf("column %q of the iceberg table was concurrently added with ID %d, which differs from ID %d of written files", f.Name, f.ID, id)
+		}
+	}
+
+	return fields, lastColumnID, nil
+}
+
+// evolveIcebergSchema returns the fields of a table schema which can be used to write parquet
+// files of the pqFields. Columns of the current schema are retained, and are made optional if
+// they're no longer written or are now optional. Newly written columns are added with new IDs,
+// and are optional unless the table is being created.
+func evolveIcebergSchema(current []icebergField, lastColumnID int, create bool, pqFields []*pqField) ([]icebergField, int, error) {
+	var written = make(map[string]*pqField, len(pqFields))
+	for _, f := range pqFields {
+		written[f.name] = f
+	}
+
+	var out = make([]icebergField, 0, len(current)+len(pqFields))
+	var existing = make(map[string]bool, len(current))
+
+	for _, f := range current {
+		existing[f.Name] = true
+
+		if pq, ok := written[f.Name]; !ok {
+			f.Required = false
+		} else if ty := pq.typeStrategy.icebergType(); ty != f.Type {
+			return nil, 0, fmt.Errorf("column %q of the iceberg table has type %s, which cannot be changed to %s", f.Name, f.Type, ty)
+		} else {
+			f.Required = f.Required && !pq.optional
+		}
+		out = append(out, f)
+	}
+
+	for _, pq := range pqFields {
+		if existing[pq.name] {
+			continue
+		}
+		lastColumnID++
+		out = append(out, icebergField{
+			ID:       lastColumnID,
+			Name:     pq.name,
+			Required: create && !pq.optional,
+			Type:     pq.typeStrategy.icebergType(),
+		})
+	}
+
+	return out, lastColumnID, nil
+}
+
+func (t *icebergTable) metadataKey(name string) string {
+	return fmt.Sprintf("%s/metadata/%s", t.location, name)
+}
+
+func (t *icebergTable) metadataVersionKey(version int) string {
+	return t.metadataKey(fmt.Sprintf("v%d.metadata.json", version))
+}
+
+func (t *icebergTable) uri(key string) string {
+	return t.bucketURI + "/" + key
+}
+
+// key returns the key of a URI within the bucket, which is empty if the URI is of another bucket.
+func (t *icebergTable) key(uri string) string {
+	if !strings.HasPrefix(uri, t.bucketURI+"/") {
+		return ""
+	}
+	return strings.TrimPrefix(uri, t.bucketURI+"/")
+}
+
+// load reads the current metadata of the table, if it exists. Like Hadoop catalogs, it looks
+// for versions beyond the version hint, which may not have been updated after the metadata of
+// a version was written, and beyond the version which was last loaded.
+func (t *icebergTable) load() error {
+	var version = t.version
+
+	hint, err := t.uploader.Download(t.metadataKey("version-hint.text"))
+	if err != nil {
+		return fmt.Errorf("reading version hint: %w", err)
+	} else if hint != nil {
+		n, err := strconv.Atoi(strings.TrimSpace(string(hint)))
+		if err != nil {
+			return fmt.Errorf("parsing version hint %q: %w", hint, err)
+		}
+		version = max(version, n)
+	}
+
+	var raw []byte
+	for {
+		next, err := t.uploader.Download(t.metadataVersionKey(version + 1))
+		if err != nil {
+			return fmt.Errorf("reading metadata: %w", err)
+		} else if next == nil {
+			break
+		}
+		version, raw = version+1, next
+	}
+
+	if raw == nil && version == 0 {
+		// The table does not exist yet.
+		return nil
+	} else if raw == nil && version == t.version && t.metadata != nil {
+		// The loaded metadata is current.
+		return nil
+	} else if raw == nil {
+		if raw, err = t.uploader.Download(t.metadataVersionKey(version)); err != nil {
+			return fmt.Errorf("reading metadata: %w", err)
+		} else if raw == nil {
+			return fmt.Errorf("metadata of version %d does not exist", version)
+		}
+	}
+
+	var metadata icebergMetadata
+	if err := json.Unmarshal(raw, &metadata); err != nil {
+		return fmt.Errorf("parsing metadata of version %d: %w", version, err)
+	} else if metadata.FormatVersion != icebergFormatVersion {
+		return fmt.Errorf("iceberg tables of format version %d are not supported", metadata.FormatVersion)
+	}
+	for _, spec := range metadata.PartitionSpecs {
+		if spec.SpecID == metadata.DefaultSpecID && len(spec.Fields) != 0 {
+			return fmt.Errorf("partitioned iceberg tables are not supported")
+		}
+	}
+
+	t.metadata, t.version = &metadata, version
+	return nil
+}
+
+// nextSeqNum returns the sequence number of the next file of the shard which is not yet part
+// of the table.
+func (t *icebergTable) nextSeqNum() int {
+	if t.metadata == nil {
+		return 0
+	} else if snapshot := t.metadata.currentSnapshot(); snapshot == nil {
+		return 0
+	} else if n, err := strconv.Atoi(snapshot.Summary[t.seqNumProperty()]); err == nil {
+		return n
+	}
+	return 0
+}
+
+func (t *icebergTable) seqNumProperty() string {
+	return fmt.Sprintf("%s%08x", icebergSeqNumPropertyPrefix, t.keyBegin)
+}
+
+// icebergCommit is a version of the table metadata which is being committed.
+type icebergCommit struct {
+	metadata *icebergMetadata
+	snapshot icebergSnapshot
+	// Keys of the manifest and manifest list which were written for the commit.
+	written []string
+	// Keys of files which are no longer referenced by the table once the commit succeeds.
+	expired []string
+}
+
+// appendFiles commits a snapshot of the table which appends the files. Files which are already
+// part of the table are skipped, so that appends are idempotent. The table is created if it
+// doesn't exist, and its schema is evolved if its columns have changed.
+//
+// The metadata of the table is reloaded before each attempt to commit, and files written for an
+// attempt which lost the race for its version are removed.
+func (t *icebergTable) appendFiles(files []checkpoint.IcebergDataFile) error {
+	var committed, lost *icebergCommit
+
+	for attempt := 1; committed == nil; attempt++ {
+		if err := t.load(); err != nil {
+			return fmt.Errorf("loading iceberg table metadata: %w", err)
+		}
+
+		if lost != nil {
+			// A conflicting write may have been a retry of the write of the lost commit, which
+			// actually succeeded.
+			if t.hasSnapshot(lost.snapshot.SnapshotID) {
+				committed = lost
+				break
+			}
+			t.deleteKeys(lost.written)
+			lost = nil
+		}
+		if attempt > icebergMaxCommitAttempts {
+			return fmt.Errorf("committing to iceberg table: version %d was concurrently written by another writer after %d attempts", t.version, icebergMaxCommitAttempts)
+		}
+
+		var c = new(icebergCommit)
+		if ok, err := t.prepareCommit(c, files); err != nil {
+			t.deleteKeys(c.written)
+			return err
+		} else if !ok {
+			return nil
+		}
+
+		if created, err := t.createMetadata(c.metadata, t.version+1); err != nil {
+			return err
+		} else if created {
+			t.metadata, t.version = c.metadata, t.version+1
+			committed = c
+		} else {
+			log.WithFields(log.Fields{
+				"table":   t.location,
+				"version": t.version + 1,
+				"attempt": attempt,
+			}).Info("iceberg table was concurrently updated, retrying commit")
+			lost = c
+		}
+	}
+
+	if err := t.writeFile("version-hint.text", []byte(strconv.Itoa(t.version)), icebergVersionContentType); err != nil {
+		return err
+	}
+	t.deleteKeys(committed.expired)
+
+	return nil
+}
+
+// prepareCommit writes the manifest and manifest list of a snapshot which appends the files to
+// the current metadata, and returns false if there are no files to append.
+func (t *icebergTable) prepareCommit(c *icebergCommit, files []checkpoint.IcebergDataFile) (bool, error) {
+	var nextSeqNum = t.nextSeqNum()
+	var added []checkpoint.IcebergDataFile
+	for _, f := range files {
+		if f.SeqNum >= nextSeqNum {
+			added = append(added, f)
+			nextSeqNum = f.SeqNum + 1
+		}
+	}
+	if len(added) == 0 {
+		return false, nil
+	}
+
+	fields, lastColumnID, err := t.schemaFields()
+	if err != nil {
+		return false, err
+	}
+
+	var now = t.now()
+	var metadata = t.nextMetadata(now, fields, lastColumnID)
+	var parent = metadata.currentSnapshot()
+
+	var snapshot = icebergSnapshot{
+		SnapshotID:     newIcebergSnapshotID(),
+		SequenceNumber: metadata.LastSequenceNumber + 1,
+		TimestampMs:    now.UnixMilli(),
+		SchemaID:       metadata.CurrentSchemaID,
+		Summary:        map[string]string{"operation": "append"},
+	}
+
+	var entries = make([]icebergManifestEntry, 0, len(added))
+	var addedRecords, addedSize int64
+	for _, f := range added {
+		addedRecords += f.RecordCount
+		addedSize += f.FileSizeBytes
+		entries = append(entries, icebergManifestEntry{
+			Status:     icebergEntryAdded,
+			SnapshotID: &snapshot.SnapshotID,
+			// Sequence numbers of added files are inherited from the manifest.
+			DataFile: icebergDataFile{
+				FilePath:        t.uri(f.Path),
+				FileFormat:      "PARQUET",
+				RecordCount:     f.RecordCount,
+				FileSizeInBytes: f.FileSizeBytes,
+			},
+		})
+	}
+	var totals = map[string]int64{
+		"total-data-files": int64(len(added)),
+		"total-records":    addedRecords,
+		"total-files-size": addedSize,
+	}
+
+	var manifests []icebergManifestFile
+	if parent != nil {
+		snapshot.ParentSnapshotID = &parent.SnapshotID
+
+		for k, v := range parent.Summary {
+			if strings.HasPrefix(k, icebergSeqNumPropertyPrefix) {
+				snapshot.Summary[k] = v
+			}
+		}
+		for k := range totals {
+			if n, err := strconv.ParseInt(parent.Summary[k], 10, 64); err == nil {
+				totals[k] += n
+			}
+		}
+
+		if manifests, err = t.readManifestList(parent.ManifestList); err != nil {
+			return false, fmt.Errorf("reading manifest list of snapshot %d: %w", parent.SnapshotID, err)
+		}
+	}
+
+	// The files of the parent's manifests are merged into the new manifest if the snapshot would
+	// otherwise list too many of them.
+	if len(manifests)+1 > t.maxManifests {
+		var retained []icebergManifestFile
+		for _, m := range manifests {
+			if m.Content != 0 {
+				// Manifests of delete files aren't merged.
+				retained = append(retained, m)
+				continue
+			}
+			existing, err := t.readManifest(m)
+			if err != nil {
+				return false, fmt.Errorf("reading manifest %q: %w", m.ManifestPath, err)
+			}
+			entries = append(entries, existing...)
+		}
+		manifests = retained
+	}
+
+	snapshot.Summary["added-data-files"] = strconv.Itoa(len(added))
+	snapshot.Summary["added-records"] = strconv.FormatInt(addedRecords, 10)
+	snapshot.Summary["added-files-size"] = strconv.FormatInt(addedSize, 10)
+	for k, v := range totals {
+		snapshot.Summary[k] = strconv.FormatInt(v, 10)
+	}
+	snapshot.Summary[t.seqNumProperty()] = strconv.Itoa(nextSeqNum)
+
+	manifest, err := t.writeManifest(c, metadata, snapshot, entries)
+	if err != nil {
+		return false, fmt.Errorf("writing manifest: %w", err)
+	}
+	// The new manifest is listed first, as it's the most recent.
+	manifests = append([]icebergManifestFile{manifest}, manifests...)
+
+	if snapshot.ManifestList, err = t.writeManifestList(c, snapshot, manifests); err != nil {
+		return false, fmt.Errorf("writing manifest list: %w", err)
+	}
+
+	metadata.LastSequenceNumber = snapshot.SequenceNumber
+	metadata.CurrentSnapshotID = &snapshot.SnapshotID
+	metadata.Refs["main"] = icebergRef{SnapshotID: snapshot.SnapshotID, Type: "branch"}
+	metadata.Snapshots = append(metadata.Snapshots, snapshot)
+	metadata.SnapshotLog = append(metadata.SnapshotLog, icebergSnapshotLogEntry{
+		TimestampMs: snapshot.TimestampMs,
+		SnapshotID:  snapshot.SnapshotID,
+	})
+
+	if c.expired, err = t.expire(metadata); err != nil {
+		return false, fmt.Errorf("expiring snapshots: %w", err)
+	}
+
+	c.metadata, c.snapshot = metadata, snapshot
+	return true, nil
+}
+
+// nextMetadata returns a copy of the current metadata updated for a new version, or the
+// metadata of a new table if it doesn't exist.
+func (t *icebergTable) nextMetadata(now time.Time, fields []icebergField, lastColumnID int) *icebergMetadata {
+	if t.metadata == nil {
+		return &icebergMetadata{
+			FormatVersion:   icebergFormatVersion,
+			TableUUID:       uuid.NewString(),
+			Location:        t.uri(t.location),
+			LastUpdatedMs:   now.UnixMilli(),
+			LastColumnID:    lastColumnID,
+			CurrentSchemaID: 0,
+			Schemas:         []icebergSchema{{Type: "struct", SchemaID: 0, Fields: fields}},
+			PartitionSpecs:  []icebergPartitionSpec{{SpecID: 0, Fields: []interface{}{}}},
+			// The ID of the first partition field, less one.
+			LastPartitionID: 999,
+			SortOrders:      []icebergSortOrder{{OrderID: 0, Fields: []interface{}{}}},
+			Refs:            map[string]icebergRef{},
+			Snapshots:       []icebergSnapshot{},
+			SnapshotLog:     []icebergSnapshotLogEntry{},
+			MetadataLog:     []icebergMetadataLogEntry{},
+		}
+	}
+
+	var next = *t.metadata
+	next.LastUpdatedMs = now.UnixMilli()
+	next.Schemas = append([]icebergSchema(nil), t.metadata.Schemas...)
+	next.Refs = make(map[string]icebergRef, len(t.metadata.Refs)+1)
+	for k, v := range t.metadata.Refs {
+		next.Refs[k] = v
+	}
+	next.Snapshots = append([]icebergSnapshot(nil), t.metadata.Snapshots...)
+	next.SnapshotLog = append([]icebergSnapshotLogEntry(nil), t.metadata.SnapshotLog...)
+	next.MetadataLog = append(append([]icebergMetadataLogEntry(nil), t.metadata.MetadataLog...), icebergMetadataLogEntry{
+		TimestampMs:  t.metadata.LastUpdatedMs,
+		MetadataFile: t.uri(t.metadataVersionKey(t.version)),
+	})
+
+	if current := next.currentSchema(); current == nil || !reflect.DeepEqual(current.Fields, fields) {
+		var schemaID = 0
+		for _, s := range next.Schemas {
+			if s.SchemaID >= schemaID {
+				schemaID = s.SchemaID + 1
+			}
+		}
+		next.Schemas = append(next.Schemas, icebergSchema{Type: "struct", SchemaID: schemaID, Fields: fields})
+		next.CurrentSchemaID = schemaID
+		next.LastColumnID = lastColumnID
+	}
+
+	return &next
+}
+
+// expire removes snapshots beyond the most recent maxSnapshots from the metadata, other
+// than those which are referenced by a branch or tag, and trims its metadata log. It returns the
+// keys of the metadata files, manifest lists, and manifests which are referenced only by what
+// was removed.
+func (t *icebergTable) expire(metadata *icebergMetadata) ([]string, error) {
+	var expiredKeys []string
+
+	if excess := len(metadata.MetadataLog) - t.maxMetadataLog; excess > 0 {
+		for _, entry := range metadata.MetadataLog[:excess] {
+			if key := t.key(entry.MetadataFile); key != "" {
+				expiredKeys = append(expiredKeys, key)
+			}
+		}
+		metadata.MetadataLog = metadata.MetadataLog[excess:]
+	}
+
+	var referenced = make(map[int64]bool, len(metadata.Refs))
+	for _, ref := range metadata.Refs {
+		referenced[ref.SnapshotID] = true
+	}
+
+	var retained, expired []icebergSnapshot
+	var excess = len(metadata.Snapshots) - t.maxSnapshots
+	for _, s := range metadata.Snapshots {
+		if excess > 0 && !referenced[s.SnapshotID] {
+			expired = append(expired, s)
+			excess--
+		} else {
+			retained = append(retained, s)
+		}
+	}
+	if len(expired) == 0 {
+		return expiredKeys, nil
+	}
+
+	var retainedIDs = make(map[int64]bool, len(retained))
+	for _, s := range retained {
+		retainedIDs[s.SnapshotID] = true
+	}
+	var snapshotLog []icebergSnapshotLogEntry
+	for _, entry := range metadata.SnapshotLog {
+		if retainedIDs[entry.SnapshotID] {
+			snapshotLog = append(snapshotLog, entry)
+		}
+	}
+	metadata.Snapshots, metadata.SnapshotLog = retained, snapshotLog
+
+	// Appends and merges never add a manifest which was dropped by a prior snapshot, and so the
+	// manifests of the oldest retained snapshot include every manifest of an expired snapshot
+	// which is still referenced by a later one. Snapshots referenced by a tag or branch are
+	// retained out of order, and their manifests are also live.
+	var live = make(map[string]bool)
+	for i, s := range retained {
+		if i != 0 && !referenced[s.SnapshotID] {
+			continue
+		}
+		manifests, err := t.readManifestList(s.ManifestList)
+		if err != nil {
+			return nil, fmt.Errorf("reading manifest list of snapshot %d: %w", s.SnapshotID, err)
+		}
+		for _, m := range manifests {
+			live[m.ManifestPath] = true
+		}
+	}
+
+	for _, s := range expired {
+		manifests, err := t.readManifestList(s.ManifestList)
+		if errors.Is(err, errIcebergFileMissing) {
+			// The files of the snapshot were already removed by a prior expiry.
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading manifest list of snapshot %d: %w", s.SnapshotID, err)
+		}
+		for _, m := range manifests {
+			if key := t.key(m.ManifestPath); key != "" && !live[m.ManifestPath] {
+				live[m.ManifestPath] = true // Expire each manifest only once.
+				expiredKeys = append(expiredKeys, key)
+			}
+		}
+		if key := t.key(s.ManifestList); key != "" {
+			expiredKeys = append(expiredKeys, key)
+		}
+	}
+
+	return expiredKeys, nil
+}
+
+// hasSnapshot returns whether the current metadata includes the snapshot.
+func (t *icebergTable) hasSnapshot(id int64) bool {
+	if t.metadata == nil {
+		return false
+	}
+	for _, s := range t.metadata.Snapshots {
+		if s.SnapshotID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteKeys removes files which are no longer part of the table. Failures are logged rather
+// than returned, as the files are not referenced by the table and only occupy storage.
+func (t *icebergTable) deleteKeys(keys []string) {
+	for _, key := range keys {
+		if err := t.uploader.Delete(key); err != nil {
+			log.WithFields(log.Fields{
+				"key": key,
+				"err": err,
+			}).Warn("failed to remove file which is no longer part of the iceberg table")
+		}
+	}
+}
+
+// newIcebergSnapshotID returns a random, positive snapshot ID.
+func newIcebergSnapshotID() int64 {
+	var id = uuid.New()
+	return int64((binary.BigEndian.Uint64(id[:8]) ^ binary.BigEndian.Uint64(id[8:])) >> 1)
+}
+
+// writeManifest writes a manifest of the entries, which are the files added by the snapshot
+// followed by any existing files which are merged into it.
+func (t *icebergTable) writeManifest(
+	c *icebergCommit,
+	metadata *icebergMetadata,
+	snapshot icebergSnapshot,
+	entries []icebergManifestEntry,
+) (icebergManifestFile, error) {
+	var manifest = icebergManifestFile{
+		SequenceNumber:    snapshot.SequenceNumber,
+		MinSequenceNumber: snapshot.SequenceNumber,
+		AddedSnapshotID:   snapshot.SnapshotID,
+	}
+	for _, e := range entries {
+		if e.Status == icebergEntryAdded {
+			manifest.AddedFilesCount++
+			manifest.AddedRowsCount += e.DataFile.RecordCount
+		} else {
+			manifest.ExistingFilesCount++
+			manifest.ExistingRowsCount += e.DataFile.RecordCount
+			manifest.MinSequenceNumber = min(manifest.MinSequenceNumber, *e.SequenceNumber)
+		}
+	}
+
+	schemaJSON, err := json.Marshal(metadata.currentSchema())
+	if err != nil {
+		return manifest, err
+	}
+
+	var key = t.metadataKey(fmt.Sprintf("%s-m0.avro", uuid.NewString()))
+	c.written = append(c.written, key)
+
+	if manifest.ManifestLength, err = t.writeAvro(key, icebergManifestEntrySchema, map[string][]byte{
+		"schema":            schemaJSON,
+		"schema-id":         []byte(strconv.Itoa(metadata.CurrentSchemaID)),
+		"partition-spec":    []byte("[]"),
+		"partition-spec-id": []byte("0"),
+		"format-version":    []byte(strconv.Itoa(icebergFormatVersion)),
+		"content":           []byte("data"),
+	}, func(w *ocf.Encoder) error {
+		for _, e := range entries {
+			if err := w.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return manifest, err
+	}
+
+	manifest.ManifestPath = t.uri(key)
+	return manifest, nil
+}
+
+// readManifest returns the live entries of a manifest as existing entries, having the snapshot
+// ID and sequence numbers which they inherit from the manifest.
+func (t *icebergTable) readManifest(m icebergManifestFile) ([]icebergManifestEntry, error) {
+	entries, err := readIcebergAvro[icebergManifestEntry](t, m.ManifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var out = make([]icebergManifestEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.Status == icebergEntryDeleted {
+			continue
+		}
+		if e.SnapshotID == nil {
+			e.SnapshotID = &m.AddedSnapshotID
+		}
+		if e.SequenceNumber == nil {
+			e.SequenceNumber = &m.SequenceNumber
+		}
+		if e.FileSequenceNumber == nil {
+			e.FileSequenceNumber = &m.SequenceNumber
+		}
+		e.Status = icebergEntryExisting
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func (t *icebergTable) writeManifestList(c *icebergCommit, snapshot icebergSnapshot, manifests []icebergManifestFile) (string, error) {
+	var parentID = "null"
+	if snapshot.ParentSnapshotID != nil {
+		parentID = strconv.FormatInt(*snapshot.ParentSnapshotID, 10)
+	}
+
+	var key = t.metadataKey(fmt.Sprintf("snap-%d-1-%s.avro", snapshot.SnapshotID, uuid.NewString()))
+	c.written = append(c.written, key)
+
+	if _, err := t.writeAvro(key, icebergManifestFileSchema, map[string][]byte{
+		"snapshot-id":        []byte(strconv.FormatInt(snapshot.SnapshotID, 10)),
+		"parent-snapshot-id": []byte(parentID),
+		"sequence-number":    []byte(strconv.FormatInt(snapshot.SequenceNumber, 10)),
+		"format-version":     []byte(strconv.Itoa(icebergFormatVersion)),
+	}, func(w *ocf.Encoder) error {
+		for _, m := range manifests {
+			if err := w.Encode(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	return t.uri(key), nil
+}
+
+func (t *icebergTable) readManifestList(uri string) ([]icebergManifestFile, error) {
+	return readIcebergAvro[icebergManifestFile](t, uri)
+}
+
+// errIcebergFileMissing is returned when reading a file of the table which does not exist.
+var errIcebergFileMissing = errors.New("file does not exist")
+
+// readIcebergAvro reads the records of an Avro file of the table.
+func readIcebergAvro[T any](t *icebergTable, uri string) ([]T, error) {
+	var key = t.key(uri)
+	if key == "" {
+		return nil, fmt.Errorf("%q is not within bucket %q", uri, t.bucketURI)
+	}
+	raw, err := t.uploader.Download(key)
+	if err != nil {
+		return nil, err
+	} else if raw == nil {
+		return nil, fmt.Errorf("reading %q: %w", uri, errIcebergFileMissing)
+	}
+
+	dec, err := ocf.NewDecoder(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	var out []T
+	for dec.HasNext() {
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, dec.Error()
+}
+
+// The magic bytes which begin an Avro object container file.
+var avroMagic = [4]byte{'O', 'b', 'j', 1}
+
+// writeAvro writes an Avro file of the schema to a local file using the write callback, uploads
+// it to the key, and returns its length.
+//
+// Iceberg identifies the fields of manifests by the field IDs of their schema, and so the header
+// of the file is written here: the encoder of the library writes the canonical form of the
+// schema, which drops them. The encoder then appends blocks to the file having the header.
+func (t *icebergTable) writeAvro(
+	key string,
+	schema avro.Schema,
+	metadata map[string][]byte,
+	write func(*ocf.Encoder) error,
+) (int64, error) {
+	rawSchema, err := json.Marshal(schema)
+	if err != nil {
+		return 0, fmt.Errorf("encoding avro schema: %w", err)
+	}
+
+	var header = ocf.Header{
+		Magic: avroMagic,
+		Meta: map[string][]byte{
+			"avro.schema": rawSchema,
+			"avro.codec":  []byte(ocf.Deflate),
+		},
+	}
+	for k, v := range metadata {
+		header.Meta[k] = v
+	}
+	if _, err := rand.Read(header.Sync[:]); err != nil {
+		return 0, err
+	}
+
+	var localFileName = filepath.Join(t.tmpDir, filepath.Base(key))
+	f, err := os.Create(localFileName)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(localFileName)
+	defer f.Close()
+
+	if err = avro.NewEncoderForSchema(ocf.HeaderSchema, f).Encode(header); err != nil {
+		return 0, fmt.Errorf("writing avro header: %w", err)
+	} else if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	w, err := ocf.NewEncoder(string(rawSchema), f)
+	if err != nil {
+		return 0, err
+	} else if err = write(w); err != nil {
+		return 0, err
+	} else if err = w.Close(); err != nil {
+		return 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	} else if err = f.Close(); err != nil {
+		return 0, err
+	} else if err = uploadWithRetries(t.ctx, t.uploader, key, localFileName, icebergManifestContentType); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// createMetadata writes the metadata as the version, and returns false if the version was
+// already written by another writer.
+func (t *icebergTable) createMetadata(metadata *icebergMetadata, version int) (bool, error) {
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return false, fmt.Errorf("encoding metadata: %w", err)
+	}
+
+	var name = fmt.Sprintf("v%d.metadata.json", version)
+	var localFileName = filepath.Join(t.tmpDir, name)
+	if err := os.WriteFile(localFileName, raw, 0644); err != nil {
+		return false, err
+	}
+	defer os.Remove(localFileName)
+
+	created, err := t.uploader.Create(t.metadataKey(name), localFileName, icebergMetadataContentType)
+	if err != nil {
+		return false, fmt.Errorf("writing %s: %w", name, err)
+	}
+	return created, nil
+}
+
+// writeFile writes the content to the named file of the table metadata.
+func (t *icebergTable) writeFile(name string, content []byte, contentType string) error {
+	var localFileName = filepath.Join(t.tmpDir, name)
+	if err := os.WriteFile(localFileName, content, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(localFileName)
+
+	if err := uploadWithRetries(t.ctx, t.uploader, t.metadataKey(name), localFileName, contentType); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
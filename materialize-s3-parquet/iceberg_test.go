@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/estuary/connectors/materialize-s3-parquet/checkpoint"
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/hamba/avro/v2/ocf"
+	"github.com/stretchr/testify/require"
+)
+
+func readIcebergMetadata(t *testing.T, u *MockS3Uploader, version string) icebergMetadata {
+	require.Equal(t, version, string(u.files["test_path_0/metadata/version-hint.text"]))
+
+	var metadata icebergMetadata
+	require.NoError(t, json.Unmarshal(u.files["test_path_0/metadata/v"+version+".metadata.json"], &metadata))
+	return metadata
+}
+
+func readManifestList(t *testing.T, table *icebergTable, uri string) []icebergManifestFile {
+	manifests, err := table.readManifestList(uri)
+	require.NoError(t, err)
+	return manifests
+}
+
+func TestIcebergTable(t *testing.T) {
+	var mockS3Uploader = newMockS3Uploader(t)
+	var open = buildTestOpenRequest(1)
+	open.Materialization.ConfigJson = json.RawMessage(`{"region":"us-east-1","bucket":"test_bucket","uploadIntervalInSeconds":60}`)
+	open.Materialization.Bindings[0].ResourceConfigJson, _ = json.Marshal(resource{
+		PathPrefix:  "test_path_0",
+		TableFormat: tableFormatIceberg,
+	})
+
+	fileProcessor, err := NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{}, open)
+	require.NoError(t, err)
+	var b = fileProcessor.pqBindings[0]
+	b.iceberg.now = func() time.Time { return time.UnixMilli(1000) }
+
+	// Parquet files identify their columns by the field IDs of the table.
+	require.Contains(t, b.pqDataConverter.JSONFileSchema(), "repetitiontype=REQUIRED, fieldid=1")
+	require.Contains(t, b.pqDataConverter.JSONFileSchema(), "repetitiontype=REQUIRED, fieldid=2")
+
+	// Uploaded files are pending until they're appended.
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{1}, tuple.Tuple{"msg #1"}))
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{2}, tuple.Tuple{"msg #2"}))
+	state, err := fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Len(t, state.PendingIcebergFiles, 1)
+	require.Len(t, state.PendingIcebergFiles[0], 1)
+
+	var first = state.PendingIcebergFiles[0][0]
+	require.Equal(t, "test_path_0/0000007b/000000000.parquet", first.Path)
+	require.Equal(t, int64(2), first.RecordCount)
+	require.Equal(t, int64(len(mockS3Uploader.files[first.Path])), first.FileSizeBytes)
+	require.Nil(t, mockS3Uploader.files["test_path_0/metadata/version-hint.text"])
+
+	// The first append creates the table.
+	require.NoError(t, fileProcessor.AppendToTables(state.PendingIcebergFiles))
+	require.Nil(t, fileProcessor.pendingIcebergFiles())
+
+	var metadata = readIcebergMetadata(t, mockS3Uploader, "1")
+	require.Equal(t, "s3://test_bucket/test_path_0", metadata.Location)
+	require.Equal(t, []icebergSchema{{Type: "struct", SchemaID: 0, Fields: []icebergField{
+		{ID: 1, Name: "Id", Required: true, Type: "long"},
+		{ID: 2, Name: "Message", Required: true, Type: "string"},
+	}}}, metadata.Schemas)
+	require.Len(t, metadata.Snapshots, 1)
+	require.Equal(t, int64(1), metadata.LastSequenceNumber)
+
+	var snapshot = metadata.Snapshots[0]
+	require.Equal(t, snapshot.SnapshotID, *metadata.CurrentSnapshotID)
+	require.Equal(t, "2", snapshot.Summary["added-records"])
+	require.Equal(t, "1", snapshot.Summary["flow.next-seq-num.0000007b"])
+
+	var manifests = readManifestList(t, b.iceberg, snapshot.ManifestList)
+	require.Len(t, manifests, 1)
+	require.Equal(t, int32(1), manifests[0].AddedFilesCount)
+	require.Equal(t, int64(1), manifests[0].SequenceNumber)
+
+	// Manifests retain the field IDs of their schema.
+	var manifestKey = strings.TrimPrefix(manifests[0].ManifestPath, "s3://test_bucket/")
+	dec, err := ocf.NewDecoder(bytes.NewReader(mockS3Uploader.files[manifestKey]))
+	require.NoError(t, err)
+	require.Contains(t, string(dec.Metadata()["avro.schema"]), `"name":"file_path","type":"string","field-id":100`)
+	require.Equal(t, "data", string(dec.Metadata()["content"]))
+
+	entries, err := readIcebergAvro[icebergManifestEntry](b.iceberg, manifests[0].ManifestPath)
+	require.NoError(t, err)
+	require.Equal(t, []icebergManifestEntry{{
+		Status:     icebergEntryAdded,
+		SnapshotID: &snapshot.SnapshotID,
+		DataFile: icebergDataFile{
+			FilePath:        "s3://test_bucket/test_path_0/0000007b/000000000.parquet",
+			FileFormat:      "PARQUET",
+			RecordCount:     2,
+			FileSizeInBytes: first.FileSizeBytes,
+		},
+	}}, entries)
+
+	// A second append adds a snapshot which lists the manifests of its parent.
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{3}, tuple.Tuple{"msg #3"}))
+	state, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.NoError(t, fileProcessor.AppendToTables(state.PendingIcebergFiles))
+
+	metadata = readIcebergMetadata(t, mockS3Uploader, "2")
+	require.Len(t, metadata.Snapshots, 2)
+	require.Equal(t, snapshot.SnapshotID, *metadata.Snapshots[1].ParentSnapshotID)
+	require.Equal(t, "3", metadata.Snapshots[1].Summary["total-records"])
+	require.Equal(t, "2", metadata.Snapshots[1].Summary["flow.next-seq-num.0000007b"])
+	require.Len(t, metadata.MetadataLog, 1)
+	require.Len(t, readManifestList(t, b.iceberg, metadata.Snapshots[1].ManifestList), 2)
+
+	// Appending files which are already part of the table is a no-op.
+	require.NoError(t, fileProcessor.AppendToTables(state.PendingIcebergFiles))
+	readIcebergMetadata(t, mockS3Uploader, "2")
+
+	// A recovered table finds metadata versions beyond its version hint.
+	mockS3Uploader.files["test_path_0/metadata/version-hint.text"] = []byte("1")
+	fileProcessor, err = NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{NextSeqNumList: []int{2}}, open)
+	require.NoError(t, err)
+	require.Equal(t, 2, fileProcessor.pqBindings[0].iceberg.version)
+	require.Equal(t, 2, fileProcessor.pqBindings[0].iceberg.nextSeqNum())
+
+	// Adding a projection evolves the schema of the table with an optional column.
+	var binding = open.Materialization.Bindings[0]
+	binding.FieldSelection.Values = append(binding.FieldSelection.Values, "Flag")
+	// Projections are ordered on their field.
+	binding.Collection.Projections = append([]pf.Projection{
+		{Field: "Flag", Inference: pf.Inference{Types: []string{"boolean"}, Exists: pf.Inference_MUST}},
+	}, binding.Collection.Projections...)
+
+	fileProcessor, err = NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{NextSeqNumList: []int{2}}, open)
+	require.NoError(t, err)
+	require.Contains(t, fileProcessor.pqBindings[0].pqDataConverter.JSONFileSchema(), "fieldid=3")
+	require.NoError(t, fileProcessor.AppendToTables([][]checkpoint.IcebergDataFile{{
+		{Path: "test_path_0/0000007b/000000002.parquet", SeqNum: 2, RecordCount: 1, FileSizeBytes: 10},
+	}}))
+
+	metadata = readIcebergMetadata(t, mockS3Uploader, "3")
+	require.Equal(t, 1, metadata.CurrentSchemaID)
+	require.Equal(t, 3, metadata.LastColumnID)
+	require.Equal(t, icebergField{ID: 3, Name: "Flag", Required: false, Type: "boolean"}, metadata.Schemas[1].Fields[2])
+	require.Equal(t, 1, metadata.Snapshots[2].SchemaID)
+}
+
+func newTestIcebergTable(t *testing.T, u *MockS3Uploader, keyBegin uint32) *icebergTable {
+	var converter = &ParquetDataConverter{pqFields: []*pqField{newIntField("id", false)}}
+	table, err := newIcebergTable(context.Background(), u, "test_bucket", "test_path_0", t.TempDir(), keyBegin, converter)
+	require.NoError(t, err)
+	return table
+}
+
+func testIcebergFiles(keyBegin uint32, seqNums ...int) []checkpoint.IcebergDataFile {
+	var out []checkpoint.IcebergDataFile
+	for _, n := range seqNums {
+		out = append(out, checkpoint.IcebergDataFile{
+			Path:          fmt.Sprintf("test_path_0/%08x/%09d.parquet", keyBegin, n),
+			SeqNum:        n,
+			RecordCount:   1,
+			FileSizeBytes: 10,
+		})
+	}
+	return out
+}
+
+func TestIcebergTableConcurrentCommits(t *testing.T) {
+	var u = newMockS3Uploader(t)
+	var a, b = newTestIcebergTable(t, u, 0), newTestIcebergTable(t, u, 0x80000000)
+
+	// Both shards create the table, and the first commit of the second shard loses the race.
+	var raced bool
+	u.beforeCreate = func(key string) {
+		if !raced {
+			raced = true
+			require.NoError(t, a.appendFiles(testIcebergFiles(0, 0)))
+		}
+	}
+	var before = len(u.files)
+	require.NoError(t, b.appendFiles(testIcebergFiles(0x80000000, 0, 1)))
+	require.Equal(t, 2, b.version)
+
+	var metadata = readIcebergMetadata(t, u, "2")
+	require.Len(t, metadata.Snapshots, 2)
+	var snapshot = metadata.Snapshots[1]
+	require.Equal(t, metadata.Snapshots[0].SnapshotID, *snapshot.ParentSnapshotID)
+	require.Equal(t, "1", snapshot.Summary["flow.next-seq-num.00000000"])
+	require.Equal(t, "2", snapshot.Summary["flow.next-seq-num.80000000"])
+	require.Equal(t, "3", snapshot.Summary["total-data-files"])
+	require.Len(t, readManifestList(t, b, snapshot.ManifestList), 2)
+
+	// Both commits wrote a manifest, a manifest list, and a metadata version, and the files of the
+	// lost commit were removed.
+	require.Equal(t, before+6, len(u.files)-1)
+	require.Equal(t, "2", string(u.files["test_path_0/metadata/version-hint.text"]))
+
+	// A shard which loaded a prior version commits the next one.
+	require.NoError(t, a.appendFiles(testIcebergFiles(0, 1)))
+	metadata = readIcebergMetadata(t, u, "3")
+	require.Equal(t, "2", metadata.Snapshots[2].Summary["flow.next-seq-num.00000000"])
+	require.Equal(t, "2", metadata.Snapshots[2].Summary["flow.next-seq-num.80000000"])
+
+	// A conflict which was reported for the commit's own write is recognized as its commit, and
+	// its files are retained.
+	b.uploader = conflictingUploader{u}
+	require.NoError(t, b.appendFiles(testIcebergFiles(0x80000000, 2)))
+	metadata = readIcebergMetadata(t, u, "4")
+	require.Equal(t, "3", metadata.Snapshots[3].Summary["flow.next-seq-num.80000000"])
+	require.Len(t, readManifestList(t, b, metadata.Snapshots[3].ManifestList), 4)
+	_, ok := u.files["test_path_0/metadata/v5.metadata.json"]
+	require.False(t, ok)
+}
+
+// conflictingUploader reports that each conditional upload conflicted, even when it succeeded.
+type conflictingUploader struct{ *MockS3Uploader }
+
+func (u conflictingUploader) Create(key, localFileName string, contentType string) (bool, error) {
+	_, err := u.MockS3Uploader.Create(key, localFileName, contentType)
+	return false, err
+}
+
+func TestIcebergTableExpiry(t *testing.T) {
+	var u = newMockS3Uploader(t)
+	var table = newTestIcebergTable(t, u, 0)
+	table.maxSnapshots, table.maxMetadataLog, table.maxManifests = 3, 2, 2
+
+	for n := 0; n != 6; n++ {
+		require.NoError(t, table.appendFiles(testIcebergFiles(0, n)))
+	}
+	var metadata = readIcebergMetadata(t, u, "6")
+
+	// Only the most recent snapshots and metadata versions are retained.
+	require.Len(t, metadata.Snapshots, 3)
+	require.Len(t, metadata.SnapshotLog, 3)
+	require.Equal(t, metadata.Snapshots[0].SnapshotID, metadata.SnapshotLog[0].SnapshotID)
+	require.Len(t, metadata.MetadataLog, 2)
+	require.Equal(t, "s3://test_bucket/test_path_0/metadata/v4.metadata.json", metadata.MetadataLog[0].MetadataFile)
+	for version := 1; version <= 6; version++ {
+		_, ok := u.files[table.metadataVersionKey(version)]
+		require.Equal(t, version >= 4, ok, "version %d", version)
+	}
+
+	// Manifests are merged, and files which are referenced only by expired snapshots are removed.
+	var live = make(map[string]bool)
+	for _, s := range metadata.Snapshots {
+		live[s.ManifestList] = true
+		var manifests = readManifestList(t, table, s.ManifestList)
+		require.LessOrEqual(t, len(manifests), 2)
+		for _, m := range manifests {
+			live[m.ManifestPath] = true
+		}
+	}
+	for key := range u.files {
+		if strings.HasSuffix(key, ".avro") {
+			require.True(t, live["s3://test_bucket/"+key], key)
+		}
+	}
+
+	// The current snapshot still includes every file, with the sequence numbers of their commits.
+	var entries []icebergManifestEntry
+	for _, m := range readManifestList(t, table, metadata.Snapshots[2].ManifestList) {
+		e, err := table.readManifest(m)
+		require.NoError(t, err)
+		entries = append(entries, e...)
+	}
+	require.Len(t, entries, 6)
+	var seqNums = make(map[string]int64)
+	for _, e := range entries {
+		seqNums[e.DataFile.FilePath] = *e.SequenceNumber
+	}
+	for n := 0; n != 6; n++ {
+		require.Equal(t, int64(n+1), seqNums[fmt.Sprintf("s3://test_bucket/test_path_0/00000000/%09d.parquet", n)])
+	}
+}
+
+func TestEvolveIcebergSchema(t *testing.T) {
+	var current = []icebergField{
+		{ID: 1, Name: "id", Required: true, Type: "long"},
+		{ID: 2, Name: "removed", Required: true, Type: "string"},
+		{ID: 4, Name: "relaxed", Required: true, Type: "double"},
+	}
+
+	fields, lastColumnID, err := evolveIcebergSchema(current, 4, false, []*pqField{
+		newIntField("id", false),
+		newFloatField("relaxed", true),
+		newJsonField("added", false),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 5, lastColumnID)
+	require.Equal(t, []icebergField{
+		{ID: 1, Name: "id", Required: true, Type: "long"},
+		{ID: 2, Name: "removed", Required: false, Type: "string"},
+		{ID: 4, Name: "relaxed", Required: false, Type: "double"},
+		{ID: 5, Name: "added", Required: false, Type: "string"},
+	}, fields)
+
+	// Columns of a new table may be required.
+	fields, lastColumnID, err = evolveIcebergSchema(nil, 0, true, []*pqField{newBoolField("flag", false)})
+	require.NoError(t, err)
+	require.Equal(t, 1, lastColumnID)
+	require.Equal(t, []icebergField{{ID: 1, Name: "flag", Required: true, Type: "boolean"}}, fields)
+
+	_, _, err = evolveIcebergSchema(current, 4, false, []*pqField{newStringField("id", false)})
+	require.ErrorContains(t, err, `column "id" of the iceberg table has type long, which cannot be changed to string`)
+}
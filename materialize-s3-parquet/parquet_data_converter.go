@@ -9,6 +9,7 @@ import (
 
 	"github.com/estuary/flow/go/protocols/fdb/tuple"
 	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/hamba/avro/v2"
 )
 
 // typeStrategy is an interface that provides functions with datatype-specific logic to process a column of a parquet file.
@@ -22,6 +23,12 @@ type typeStrategy interface {
 	// Returns the Go reflect type of this field.
 	reflectType() reflect.Type
 
+	// Returns the type of the field's column in an Iceberg table.
+	icebergType() string
+
+	// Returns the type of the field in an Avro record.
+	avroType() avro.Type
+
 	// Converts a tupleElement from Flow into the correct type in Go, and populates the corresponding field
 	// in a Go struct specified by `fldToSet`.
 	set(t tuple.TupleElement, fldToSet reflect.Value) error
@@ -31,6 +38,8 @@ type typeStrategy interface {
 type pqField struct {
 	name     string
 	optional bool
+	// The ID of the field within an Iceberg table schema, or zero if the field has no ID.
+	fieldID int32
 	// datatype-specific logics.
 	typeStrategy typeStrategy
 }
@@ -50,6 +59,10 @@ func (p *pqField) Tag() string {
 	if p.optional {
 		repetitionType = "OPTIONAL"
 	}
+	if p.fieldID != 0 {
+		// Field IDs follow the repetition type within the tag.
+		repetitionType += fmt.Sprintf(", fieldid=%d", p.fieldID)
+	}
 	return p.typeStrategy.tag(p.name, p.getInternalFieldName(), repetitionType)
 }
 
@@ -63,6 +76,22 @@ func (p *pqField) Set(t tuple.TupleElement, fldToSet reflect.Value) error {
 	return nil
 }
 
+// Value converts a tupleElement from Flow into the Go value of the field, which is nil if the
+// field is optional and the tupleElement is nil.
+func (p *pqField) Value(t tuple.TupleElement) (interface{}, error) {
+	if t == nil {
+		if !p.optional {
+			return nil, fmt.Errorf("unexpected nil value to a non-optional field")
+		}
+		return nil, nil
+	}
+	var v = reflect.New(p.typeStrategy.reflectType()).Elem()
+	if err := p.typeStrategy.set(t, v); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
 func (p *pqField) getInternalFieldName() string {
 	return "I" + strings.ReplaceAll(base32.StdEncoding.EncodeToString([]byte(p.name)), "=", "_")
 }
@@ -90,6 +119,8 @@ func (s *stringStrategy) tag(name, internalName, repetitionType string) string {
 	return fmt.Sprintf(`{"Tag": "name=%s, inname=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=%s"}`,
 		name, internalName, repetitionType)
 }
+func (s *stringStrategy) icebergType() string { return "string" }
+func (s *stringStrategy) avroType() avro.Type { return avro.String }
 func (s *stringStrategy) reflectType() reflect.Type {
 	return reflect.TypeOf("")
 }
@@ -117,6 +148,8 @@ func (i *intStrategy) tag(name, internalName, repetitionType string) string {
 	return fmt.Sprintf(`{"Tag": "name=%s, inname=%s, type=INT64, repetitiontype=%s"}`,
 		name, internalName, repetitionType)
 }
+func (i *intStrategy) icebergType() string { return "long" }
+func (i *intStrategy) avroType() avro.Type { return avro.Long }
 func (i *intStrategy) reflectType() reflect.Type {
 	return reflect.TypeOf(int64(0))
 }
@@ -146,6 +179,8 @@ func (f *floatStrategy) tag(name, internalName, repetitionType string) string {
 	return fmt.Sprintf(`{"Tag": "name=%s, inname=%s, type=DOUBLE, repetitiontype=%s"}`,
 		name, internalName, repetitionType)
 }
+func (f *floatStrategy) icebergType() string { return "double" }
+func (f *floatStrategy) avroType() avro.Type { return avro.Double }
 func (f *floatStrategy) reflectType() reflect.Type {
 	return reflect.TypeOf(float64(0))
 }
@@ -179,6 +214,8 @@ func (b *boolStrategy) tag(name, internalName, repetitionType string) string {
 	return fmt.Sprintf(`{"Tag": "name=%s, inname=%s, type=BOOLEAN, repetitiontype=%s"}`,
 		name, internalName, repetitionType)
 }
+func (b *boolStrategy) icebergType() string { return "boolean" }
+func (b *boolStrategy) avroType() avro.Type { return avro.Boolean }
 func (b *boolStrategy) reflectType() reflect.Type {
 	return reflect.TypeOf(false)
 }
@@ -206,6 +243,8 @@ func (b *jsonStrategy) tag(name, internalName, repetitionType string) string {
 	return fmt.Sprintf(`{"Tag": "name=%s, inname=%s, type=BYTE_ARRAY, logicaltype=STRING, repetitiontype=%s"}`,
 		name, internalName, repetitionType)
 }
+func (b *jsonStrategy) icebergType() string { return "string" }
+func (b *jsonStrategy) avroType() avro.Type { return avro.String }
 func (b *jsonStrategy) reflectType() reflect.Type {
 	return reflect.TypeOf("")
 }
@@ -297,6 +336,13 @@ func NewParquetDataConverter(binding *pf.MaterializationSpec_Binding) (*ParquetD
 	return &ParquetDataConverter{pqFields: pqFields, rowObjSchema: reflect.StructOf(structFields)}, nil
 }
 
+// SetFieldIDs sets the IDs of fields in the parquet file schema, keyed on their names.
+func (pd *ParquetDataConverter) SetFieldIDs(fieldIDs map[string]int32) {
+	for _, field := range pd.pqFields {
+		field.fieldID = fieldIDs[field.name]
+	}
+}
+
 // JSONFileSchema returns the parquet file schema represented in a JSON string.
 func (pd *ParquetDataConverter) JSONFileSchema() string {
 	var tags = make([]string, 0, len(pd.pqFields))
@@ -323,3 +369,16 @@ func (pd *ParquetDataConverter) Convert(key tuple.Tuple, values tuple.Tuple) (in
 	}
 	return row.Interface(), nil
 }
+
+// Values converts the key/values into the Go values of each field, in the order of the fields.
+func (pd *ParquetDataConverter) Values(key tuple.Tuple, values tuple.Tuple) ([]interface{}, error) {
+	var out = make([]interface{}, 0, len(pd.pqFields))
+	for i, t := range append(append(tuple.Tuple{}, key...), values...) {
+		v, err := pd.pqFields[i].Value(t)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", pd.pqFields[i].name, err)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,14 +15,9 @@ import (
 	"github.com/estuary/flow/go/protocols/fdb/tuple"
 	pf "github.com/estuary/flow/go/protocols/flow"
 	pm "github.com/estuary/flow/go/protocols/materialize"
-	"github.com/xitongsys/parquet-go-source/local"
 
 	"github.com/benbjohnson/clock"
-
-	// TODO revisit this after https://issues.apache.org/jira/browse/ARROW-13986 is completed.
-	"github.com/xitongsys/parquet-go/parquet"
-	"github.com/xitongsys/parquet-go/source"
-	"github.com/xitongsys/parquet-go/writer"
+	"github.com/estuary/connectors/materialize-s3-parquet/checkpoint"
 )
 
 // FileProcessor defines an interface to materialize data into files.
@@ -29,14 +25,25 @@ type FileProcessor interface {
 	// Stores data (key values) to a local file dedicated to the specified binding.
 	Store(binding int, key tuple.Tuple, values tuple.Tuple) error
 
-	// Uploads local files to the cloud storage for all bindings, and returns the updated nextSeqNumList upon success.
-	// Refer to `struct driverCheckpoint` definition in driver.go for details of nextSeqNumList.
-	Commit() (nextSeqNumList []int, e error)
+	// Uploads local files to the cloud storage for all bindings, and returns the updated FileState upon success.
+	Commit() (state FileState, e error)
+
+	// Appends the pending files of a committed FileState to the Iceberg tables of bindings.
+	AppendToTables(pendingIcebergFiles [][]checkpoint.IcebergDataFile) error
 
 	// Releases resources occupied by the processor.
 	Destroy() error
 }
 
+// FileState is the state of the files of all bindings, which is checkpointed after files are uploaded.
+// Refer to the `checkpoint.DriverCheckpoint` definition for details of its fields.
+type FileState struct {
+	NextSeqNumList        []int
+	NextPartitionSeqNums  []map[string]int
+	PartitionSeqNumFloors []int
+	PendingIcebergFiles   [][]checkpoint.IcebergDataFile
+}
+
 // FileProcessorProxy implements FileProcessor interface.
 // It proxies a file processor with the following additional logic:
 // a) It maintains a ticker, which triggers the Commit() command on the proxied file processor
@@ -110,7 +117,7 @@ func (fp *FileProcessorProxy) Store(binding int, key tuple.Tuple, values tuple.T
 }
 
 // Commit implements the FileProcessor interface.
-func (fp *FileProcessorProxy) Commit() (nextSeqNumList []int, e error) {
+func (fp *FileProcessorProxy) Commit() (state FileState, e error) {
 	fp.mu.Lock()
 	defer func() {
 		fp.mostRecentCommitTime = fp.clock.Now()
@@ -121,14 +128,25 @@ func (fp *FileProcessorProxy) Commit() (nextSeqNumList []int, e error) {
 		return
 	}
 
-	if nextSeqNumList, err := fp.fileProcessor.Commit(); err != nil {
-		return nil, fmt.Errorf("committing data: %w", err)
+	if state, err := fp.fileProcessor.Commit(); err != nil {
+		return FileState{}, fmt.Errorf("committing data: %w", err)
 	} else {
 		fp.hasLocalStagingData = false
-		return nextSeqNumList, nil
+		return state, nil
 	}
 }
 
+// AppendToTables implements the FileProcessor interface.
+func (fp *FileProcessorProxy) AppendToTables(pendingIcebergFiles [][]checkpoint.IcebergDataFile) error {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	if err := fp.fileProcessor.AppendToTables(pendingIcebergFiles); err != nil {
+		return fmt.Errorf("appending to tables: %w", err)
+	}
+	return nil
+}
+
 // Destroy implements the FileProcessor interface.
 func (fp *FileProcessorProxy) Destroy() error {
 	close(fp.stopServingCh)
@@ -178,7 +196,7 @@ type ParquetFileProcessor struct {
 func NewParquetFileProcessor(
 	ctx context.Context,
 	S3Uploader Uploader,
-	nextSeqNumList []int,
+	state FileState,
 	open *pm.Request_Open) (*ParquetFileProcessor, error) {
 
 	tmpDir, err := ioutil.TempDir("", strings.Replace(string(open.Materialization.Name), "/", "_", -1))
@@ -186,39 +204,91 @@ func NewParquetFileProcessor(
 		return nil, fmt.Errorf("creating temp dir: %w", err)
 	}
 
+	// The endpoint config is parsed only if a binding needs it.
+	var cfg *config
+
 	var pqBindings = make([]*pqBinding, 0, len(open.Materialization.Bindings))
 	for i, binding := range open.Materialization.Bindings {
 		var res resource
 		if err := pf.UnmarshalStrict(binding.ResourceConfigJson, &res); err != nil {
 			return nil, fmt.Errorf("parsing resource config: %w", err)
 		}
-		// Ensure exactly one / between the given prefix and the shard range, and in between the
-		// shard range and the filename. The prefixe only encodes the `KeyBegin`, so that the
-		// prefix will not change after a shard is split.
-		var s3PathPrefix = fmt.Sprintf("%s/%08x/", strings.TrimSuffix(res.PathPrefix, "/"), open.Range.KeyBegin)
 		var localPathPrefix = fmt.Sprintf("%s/%d_%08x_", tmpDir, i, open.Range.KeyBegin)
 
 		pqDataConverter, err := NewParquetDataConverter(binding)
 		if err != nil {
 			return nil, fmt.Errorf("creating parquet data converter: %w", err)
 		}
+		format, err := newFileFormat(res, pqDataConverter)
+		if err != nil {
+			return nil, fmt.Errorf("creating file format: %w", err)
+		}
+		partitioner, err := newPartitioner(res.PartitionBy, binding.FieldSelection)
+		if err != nil {
+			return nil, fmt.Errorf("creating partitioner: %w", err)
+		}
+
+		var iceberg *icebergTable
+		if res.TableFormat == tableFormatIceberg {
+			if cfg == nil {
+				cfg = new(config)
+				if err := pf.UnmarshalStrict(open.Materialization.ConfigJson, cfg); err != nil {
+					return nil, fmt.Errorf("parsing endpoint config: %w", err)
+				}
+			}
+			if iceberg, err = newIcebergTable(ctx, S3Uploader, cfg.Bucket, res.PathPrefix, tmpDir, open.Range.KeyBegin, pqDataConverter); err != nil {
+				return nil, fmt.Errorf("opening iceberg table of %q: %w", res.PathPrefix, err)
+			}
+		}
 
 		var nextSeqNum = 0
-		if nextSeqNumList != nil && len(nextSeqNumList) > i {
-			nextSeqNum = nextSeqNumList[i]
+		if state.NextSeqNumList != nil && len(state.NextSeqNumList) > i {
+			nextSeqNum = state.NextSeqNumList[i]
+		}
+		var partitionSeqNums = make(map[string]int)
+		if len(state.NextPartitionSeqNums) > i {
+			for partition, seqNum := range state.NextPartitionSeqNums[i] {
+				partitionSeqNums[partition] = seqNum
+			}
+		}
+		var partitionSeqNumFloor = 0
+		if len(state.PartitionSeqNumFloors) > i {
+			partitionSeqNumFloor = state.PartitionSeqNumFloors[i]
+		}
+		var pendingIcebergFiles []checkpoint.IcebergDataFile
+		if iceberg != nil && len(state.PendingIcebergFiles) > i {
+			pendingIcebergFiles = append(pendingIcebergFiles, state.PendingIcebergFiles[i]...)
+		}
+
+		var b = &pqBinding{
+			ctx:                  ctx,
+			S3Uploader:           S3Uploader,
+			pathPrefix:           strings.TrimSuffix(res.PathPrefix, "/"),
+			keyBegin:             open.Range.KeyBegin,
+			pqDataConverter:      pqDataConverter,
+			format:               format,
+			partitioner:          partitioner,
+			maxFileSizeBytes:     res.MaxFileSizeBytes,
+			maxRowsPerFile:       res.MaxRowsPerFile,
+			files:                make(map[string]*pqFile),
+			localPathPrefix:      localPathPrefix,
+			nextSeqNum:           nextSeqNum,
+			nextPartitionSeqNums: partitionSeqNums,
+			partitionSeqNumFloor: partitionSeqNumFloor,
+			maxPartitionSeqNums:  maxPartitionSeqNums,
+			orphansRemoved:       make(map[string]bool),
+			iceberg:              iceberg,
+			pendingIcebergFiles:  pendingIcebergFiles,
 		}
 
-		pqBindings = append(pqBindings, &pqBinding{
-			ctx:              ctx,
-			S3Uploader:       S3Uploader,
-			s3PathPrefix:     s3PathPrefix,
-			pqDataConverter:  pqDataConverter,
-			pqWriter:         nil,
-			compressionCodec: res.CompressionCodec(),
-			localFile:        nil,
-			localPathPrefix:  localPathPrefix,
-			nextSeqNum:       nextSeqNum,
-		})
+		// Files of a transaction which was uploaded but never checkpointed are removed from each
+		// partition recorded by the checkpoint.
+		if len(state.NextSeqNumList) > i || len(state.NextPartitionSeqNums) > i {
+			if err := b.removeRecordedOrphans(); err != nil {
+				return nil, err
+			}
+		}
+		pqBindings = append(pqBindings, b)
 	}
 
 	return &ParquetFileProcessor{
@@ -237,14 +307,46 @@ func (pfp *ParquetFileProcessor) Store(binding int, key tuple.Tuple, values tupl
 }
 
 // Commit implements the FileProcessor interface.
-func (pfp *ParquetFileProcessor) Commit() (nextSeqNumList []int, err error) {
+func (pfp *ParquetFileProcessor) Commit() (FileState, error) {
 	for _, binding := range pfp.pqBindings {
 		if err := binding.Commit(); err != nil {
-			return nil, err
+			return FileState{}, err
+		}
+	}
+
+	return FileState{
+		NextSeqNumList:        pfp.nextSeqNumList(),
+		NextPartitionSeqNums:  pfp.nextPartitionSeqNums(),
+		PartitionSeqNumFloors: pfp.partitionSeqNumFloors(),
+		PendingIcebergFiles:   pfp.pendingIcebergFiles(),
+	}, nil
+}
+
+// AppendToTables implements the FileProcessor interface.
+func (pfp *ParquetFileProcessor) AppendToTables(pendingIcebergFiles [][]checkpoint.IcebergDataFile) error {
+	for i, files := range pendingIcebergFiles {
+		if i >= len(pfp.pqBindings) || pfp.pqBindings[i].iceberg == nil {
+			// The binding was removed, or is no longer materialized as an Iceberg table.
+			continue
+		}
+		var b = pfp.pqBindings[i]
+
+		if err := b.iceberg.appendFiles(files); err != nil {
+			return fmt.Errorf("appending files to iceberg table %q: %w", b.iceberg.location, err)
 		}
+
+		// Files which are now part of the table are no longer pending.
+		var nextSeqNum = b.iceberg.nextSeqNum()
+		var pending = b.pendingIcebergFiles[:0]
+		for _, f := range b.pendingIcebergFiles {
+			if f.SeqNum >= nextSeqNum {
+				pending = append(pending, f)
+			}
+		}
+		b.pendingIcebergFiles = pending
 	}
 
-	return pfp.nextSeqNumList(), nil
+	return nil
 }
 
 // Destroy implements the FileProcessor interface.
@@ -260,129 +362,392 @@ func (pfp *ParquetFileProcessor) nextSeqNumList() []int {
 	return nextSeqNumList
 }
 
+// nextPartitionSeqNums returns nil if no bindings are partitioned, so that checkpoints of
+// materializations which don't use partitioning are unchanged.
+func (pfp *ParquetFileProcessor) nextPartitionSeqNums() []map[string]int {
+	var partitioned = false
+	var nextPartitionSeqNums = make([]map[string]int, 0, len(pfp.pqBindings))
+
+	for _, b := range pfp.pqBindings {
+		if b.partitioner == nil {
+			nextPartitionSeqNums = append(nextPartitionSeqNums, nil)
+			continue
+		}
+		partitioned = true
+
+		var seqNums = make(map[string]int, len(b.nextPartitionSeqNums))
+		for partition, seqNum := range b.nextPartitionSeqNums {
+			seqNums[partition] = seqNum
+		}
+		nextPartitionSeqNums = append(nextPartitionSeqNums, seqNums)
+	}
+
+	if !partitioned {
+		return nil
+	}
+	return nextPartitionSeqNums
+}
+
+// partitionSeqNumFloors returns nil if no partitions have been pruned, so that checkpoints of
+// materializations which don't use partitioning are unchanged.
+func (pfp *ParquetFileProcessor) partitionSeqNumFloors() []int {
+	var pruned = false
+	var floors = make([]int, 0, len(pfp.pqBindings))
+
+	for _, b := range pfp.pqBindings {
+		pruned = pruned || b.partitionSeqNumFloor != 0
+		floors = append(floors, b.partitionSeqNumFloor)
+	}
+
+	if !pruned {
+		return nil
+	}
+	return floors
+}
+
+// pendingIcebergFiles returns nil if no files are pending, so that checkpoints of materializations
+// which don't use Iceberg tables are unchanged.
+func (pfp *ParquetFileProcessor) pendingIcebergFiles() [][]checkpoint.IcebergDataFile {
+	var pending = false
+	var pendingIcebergFiles = make([][]checkpoint.IcebergDataFile, 0, len(pfp.pqBindings))
+
+	for _, b := range pfp.pqBindings {
+		pending = pending || len(b.pendingIcebergFiles) != 0
+		pendingIcebergFiles = append(pendingIcebergFiles, append([]checkpoint.IcebergDataFile(nil), b.pendingIcebergFiles...))
+	}
+
+	if !pending {
+		return nil
+	}
+	return pendingIcebergFiles
+}
+
 // TODO(jixiang) better understand the impact of ParallelNumber to the performance of the connector.
 const parquetWriterParallelNumber int64 = 4
 
 const pqContentType string = "application/octet-stream"
 
+// maxPartitionSeqNums is the number of partitions of a binding whose sequence numbers are tracked
+// before those which aren't being written are pruned.
+const maxPartitionSeqNums = 1000
+
 // A pqBinding is responsible for converting, storing, and uploading the materialization results from
 // a single binding of flow txns into the cloud.
 type pqBinding struct {
 	ctx context.Context
 	// For uploading files to S3.
-	S3Uploader   Uploader
-	s3PathPrefix string
+	S3Uploader Uploader
+	pathPrefix string
+	// Files are named using only the `KeyBegin` of the shard, so that their paths will not change
+	// after a shard is split.
+	keyBegin uint32
 	// For converting flow data of key/values into formats acceptable by the parquet file writer.
 	pqDataConverter *ParquetDataConverter
-	// For generating local parquet files.
-	pqWriter         *writer.ParquetWriter
-	compressionCodec parquet.CompressionCodec
-	localFile        source.ParquetFile
-	localPathPrefix  string
-	nextSeqNum       int
+	// For encoding rows into local files of the format of the binding.
+	format fileFormat
+	// For routing rows into partitions. Nil if the binding is not partitioned.
+	partitioner *partitioner
+	// A file is rolled to a new file once it reaches either limit, if set.
+	maxFileSizeBytes int64
+	maxRowsPerFile   int64
+	// Local files being written, keyed on their partition path. The partition path is
+	// empty if the binding is not partitioned.
+	files map[string]*pqFile
+	// Local files which were rolled, in order, and are yet to be uploaded.
+	rolledFiles     []*pqFile
+	localPathPrefix string
+	// The number of local files which have been created, for naming them uniquely.
+	localFileCount int
+	// The sequence number of the next file, if the binding is not partitioned.
+	nextSeqNum int
+	// The sequence number of the next file of each partition, if the binding is partitioned.
+	nextPartitionSeqNums map[string]int
+	// The sequence number of the first file of a partition which isn't in nextPartitionSeqNums.
+	partitionSeqNumFloor int
+	// The number of partitions in nextPartitionSeqNums beyond which it's pruned.
+	maxPartitionSeqNums int
+	// Partitions of which files left behind by a previous process have been removed. The partition
+	// is empty if the binding is not partitioned.
+	orphansRemoved map[string]bool
+	// For appending uploaded files to an Iceberg table. Nil if the binding is not materialized as
+	// an Iceberg table.
+	iceberg *icebergTable
+	// Uploaded files which have not yet been appended to the Iceberg table.
+	pendingIcebergFiles []checkpoint.IcebergDataFile
+}
+
+// A pqFile is a local file being written, which will be uploaded to a path in the cloud.
+type pqFile struct {
+	encoder       fileEncoder
+	localFileName string
+	partition     string
+	seqNum        int
+	s3Path        string
+	rows          int64
 }
 
 // Stores the input data into local files.
 func (b *pqBinding) Store(key tuple.Tuple, values tuple.Tuple) error {
-	pqData, err := b.pqDataConverter.Convert(key, values)
+	var partition string
+	var err error
+	if b.partitioner != nil {
+		if partition, err = b.partitioner.path(key, values); err != nil {
+			return err
+		}
+	}
 
-	if err != nil {
-		return fmt.Errorf("converting data: %w", err)
-	}
-
-	if b.localFile == nil {
-		if b.pqWriter != nil {
-			return fmt.Errorf("pqWriting is expected not to be nil if localFile is nil")
-		} else if b.localFile, err = local.NewLocalFileWriter(b.localFileName()); err != nil {
-			return fmt.Errorf("creating local file: %w", err)
-		} else if b.pqWriter, err = writer.NewParquetWriter(
-			b.localFile,
-			b.pqDataConverter.JSONFileSchema(),
-			parquetWriterParallelNumber); err != nil {
-			return fmt.Errorf("creating parquet writer: %w", err)
-		} else {
-			b.pqWriter.CompressionType = b.compressionCodec
+	var file, ok = b.files[partition]
+	if !ok {
+		if file, err = b.newFile(partition); err != nil {
+			return err
 		}
+		b.files[partition] = file
 	}
 
-	if err = b.pqWriter.Write(pqData); err != nil {
-		return fmt.Errorf("writing to parquet: %w", err)
+	if err = file.encoder.encode(key, values); err != nil {
+		return err
+	}
+	file.rows++
+
+	// Rolling depends only on the stored rows, so that a recovered process which stores the same
+	// rows will re-produce the same files.
+	if (b.maxRowsPerFile != 0 && file.rows >= b.maxRowsPerFile) ||
+		(b.maxFileSizeBytes != 0 && file.encoder.size() >= b.maxFileSizeBytes) {
+		if err := file.encoder.close(); err != nil {
+			return err
+		}
+		delete(b.files, partition)
+		b.rolledFiles = append(b.rolledFiles, file)
 	}
 
 	return nil
 }
 
+// newFile creates a local file of the partition, which is assigned the next sequence number.
+func (b *pqBinding) newFile(partition string) (*pqFile, error) {
+	var file = &pqFile{partition: partition}
+	if b.partitioner == nil {
+		file.seqNum = b.nextSeqNum
+	} else {
+		file.seqNum = b.partitionSeqNum(partition)
+	}
+	file.s3Path = b.s3Path(partition, file.seqNum)
+	// Each local file of the binding is numbered, since partition paths are not suitable for
+	// local file names.
+	file.localFileName = fmt.Sprintf("%s%d_%s", b.localPathPrefix, b.localFileCount, b.filename(file.seqNum))
+
+	var err error
+	if file.encoder, err = b.format.newEncoder(file.localFileName); err != nil {
+		return nil, err
+	}
+
+	b.localFileCount++
+	if b.partitioner == nil {
+		b.nextSeqNum++
+	} else {
+		b.nextPartitionSeqNums[partition] = file.seqNum + 1
+	}
+	return file, nil
+}
+
 // Uploads local files to the cloud.
 func (b *pqBinding) Commit() error {
-	if b.pqWriter != nil {
-		defer func() { b.pqWriter = nil }()
-		if err := b.pqWriter.WriteStop(); err != nil {
-			return fmt.Errorf("stopping writer: %w", err)
-		}
-	}
-
-	if b.localFile != nil {
-		defer func() { b.localFile = nil }()
-
-		if err := b.localFile.Close(); err != nil {
-			return fmt.Errorf("closing localFile: %w", err)
-		}
-
-		// TODO(whb): The Go AWS SDK version 2 handles retryable errors out of the box. At some point it
-		// might make sense to update this connector to use the version 2 SDK and get rid of this retry
-		// loop. For now we will use a reasonable maximum limit on the number of attempts to upload
-		// before failing without trying to distinguish between retry-able errors and terminal errors.
-		var err error
-		maxRetryAttempts := 10
-		for attempt, backoffInSec := 0, 1; attempt < maxRetryAttempts; attempt++ {
-			// If upload failed, keep retrying until succeed or canceled.
-			if err = b.upload(); err == nil {
-				break
-			} else {
-				log.WithFields(log.Fields{
-					"attempt": attempt,
-					"err":     err,
-				}).Warn(fmt.Sprintf("uploading file failed, Retrying (attempt %d of %d)...", attempt, maxRetryAttempts))
-			}
-
-			select {
-			case <-b.ctx.Done():
-				return b.ctx.Err()
-			case <-time.After(time.Duration(backoffInSec) * time.Second):
-				// Fallthrough intended.
-			}
+	// Upload rolled files in the order they were rolled, followed by partitions in a deterministic
+	// order.
+	var partitions = make([]string, 0, len(b.files))
+	for partition := range b.files {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
 
-			if backoffInSec < 16 {
-				backoffInSec *= 2
-			}
+	var files = b.rolledFiles
+	for _, partition := range partitions {
+		var file = b.files[partition]
+		if err := file.encoder.close(); err != nil {
+			return err
 		}
+		files = append(files, file)
+		delete(b.files, partition)
+	}
+	b.rolledFiles = nil
+
+	var uploaded = make(map[string]bool)
+	for _, file := range files {
+		info, err := os.Stat(file.localFileName)
 		if err != nil {
-			return fmt.Errorf("retry attempts exhausted: %w", err)
+			return fmt.Errorf("reading local file size: %w", err)
+		} else if err := uploadWithRetries(b.ctx, b.S3Uploader, file.s3Path, file.localFileName, b.format.contentType()); err != nil {
+			return err
+		} else if err := os.Remove(file.localFileName); err != nil {
+			return fmt.Errorf("removing local file: %w", err)
+		}
+		uploaded[file.partition] = true
+
+		if b.iceberg != nil {
+			b.pendingIcebergFiles = append(b.pendingIcebergFiles, checkpoint.IcebergDataFile{
+				Path:          file.s3Path,
+				SeqNum:        file.seqNum,
+				RecordCount:   file.rows,
+				FileSizeBytes: info.Size(),
+			})
 		}
+	}
 
-		if err := os.Remove(b.localFileName()); err != nil {
-			return fmt.Errorf("removing local file: %w", err)
+	// Partitions which were first written by an uncommitted transaction aren't recorded by the
+	// checkpoint, and their orphans are removed the first time this process writes the partition,
+	// whichever transaction that is.
+	for partition := range uploaded {
+		if b.orphansRemoved[partition] {
+			continue
+		} else if err := b.removeOrphans(partition); err != nil {
+			return err
+		}
+		b.orphansRemoved[partition] = true
+	}
+
+	if b.partitioner != nil {
+		b.prunePartitionSeqNums(uploaded)
+	}
+	return nil
+}
 
+// partitionSeqNum returns the sequence number of the next file of the partition.
+func (b *pqBinding) partitionSeqNum(partition string) int {
+	if seqNum, ok := b.nextPartitionSeqNums[partition]; ok {
+		return seqNum
+	}
+	return b.partitionSeqNumFloor
+}
+
+// prunePartitionSeqNums bounds the partitions tracked by nextPartitionSeqNums, which would
+// otherwise grow with every partition ever written, like each day of a date partition. Once there
+// are more than maxPartitionSeqNums, partitions which weren't written by this commit are pruned.
+// The floor is raised to their sequence numbers, so that a pruned partition which is written
+// again never re-uses the name of one of its files.
+func (b *pqBinding) prunePartitionSeqNums(written map[string]bool) {
+	if len(b.nextPartitionSeqNums) <= b.maxPartitionSeqNums {
+		return
+	}
+
+	var pruned int
+	for partition, seqNum := range b.nextPartitionSeqNums {
+		if written[partition] {
+			continue
 		}
-		b.nextSeqNum++
+		b.partitionSeqNumFloor = max(b.partitionSeqNumFloor, seqNum)
+		delete(b.nextPartitionSeqNums, partition)
+		delete(b.orphansRemoved, partition)
+		pruned++
+	}
+
+	log.WithFields(log.Fields{
+		"path":    b.pathPrefix,
+		"pruned":  pruned,
+		"floor":   b.partitionSeqNumFloor,
+		"tracked": len(b.nextPartitionSeqNums),
+	}).Info("pruned sequence numbers of partitions which weren't recently written")
+}
+
+// removeRecordedOrphans removes the orphans of each partition which is recorded by the
+// checkpoint of a recovered process.
+func (b *pqBinding) removeRecordedOrphans() error {
+	if b.partitioner == nil {
+		if err := b.removeOrphans(""); err != nil {
+			return err
+		}
+		b.orphansRemoved[""] = true
+		return nil
+	}
+
+	var partitions = make([]string, 0, len(b.nextPartitionSeqNums))
+	for partition := range b.nextPartitionSeqNums {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
+
+	for _, partition := range partitions {
+		if err := b.removeOrphans(partition); err != nil {
+			return err
+		}
+		b.orphansRemoved[partition] = true
 	}
 	return nil
 }
 
-func (b *pqBinding) filename() string {
+// removeOrphans removes files of the partition which were uploaded by a previous process, but
+// were never included in a committed checkpoint. A recovered process re-produces the files of
+// its uncommitted transactions from their first sequence number, but may produce fewer files if
+// its transactions or file limits differ, and the remaining files of the previous process would
+// otherwise duplicate its rows. These files always have contiguous sequence numbers, starting
+// from the partition's next sequence number.
+func (b *pqBinding) removeOrphans(partition string) error {
+	var seqNum = b.nextSeqNum
+	if b.partitioner != nil {
+		seqNum = b.partitionSeqNum(partition)
+	}
+
+	for ; ; seqNum++ {
+		var key = b.s3Path(partition, seqNum)
+		if exists, err := b.S3Uploader.Exists(key); err != nil {
+			return fmt.Errorf("checking for orphaned file %q: %w", key, err)
+		} else if !exists {
+			return nil
+		} else if err := b.S3Uploader.Delete(key); err != nil {
+			return fmt.Errorf("removing orphaned file %q: %w", key, err)
+		}
+		log.WithField("key", key).Info("removed orphaned file of an uncommitted transaction")
+	}
+}
+
+func (b *pqBinding) filename(seqNum int) string {
 	// Pad the sequence number with 0's so that the lexicographical ordering of files will match the
 	// sequence number ordering.
-	return fmt.Sprintf("%09d.parquet", b.nextSeqNum)
+	if b.partitioner == nil {
+		return fmt.Sprintf("%09d%s", seqNum, b.format.extension())
+	}
+	// Partitioned files are in Hive-style directories of the partition, which must not be nested
+	// within a directory of the shard, so the `KeyBegin` is instead a part of their name.
+	return fmt.Sprintf("%08x_%09d%s", b.keyBegin, seqNum, b.format.extension())
 }
 
-func (b *pqBinding) localFileName() string {
-	return b.localPathPrefix + b.filename()
+func (b *pqBinding) s3Path(partition string, seqNum int) string {
+	// Ensure exactly one / between the given prefix and the shard range or partition path, and in
+	// between those and the filename.
+	if b.partitioner == nil {
+		return fmt.Sprintf("%s/%08x/%s", b.pathPrefix, b.keyBegin, b.filename(seqNum))
+	}
+	return fmt.Sprintf("%s/%s/%s", b.pathPrefix, partition, b.filename(seqNum))
 }
 
-func (b *pqBinding) s3Path() string {
-	return b.s3PathPrefix + b.filename()
-}
+// uploadWithRetries uploads the local file to the key, retrying failed attempts.
+func uploadWithRetries(ctx context.Context, uploader Uploader, key, localFileName, contentType string) error {
+	// TODO(whb): The Go AWS SDK version 2 handles retryable errors out of the box. At some point it
+	// might make sense to update this connector to use the version 2 SDK and get rid of this retry
+	// loop. For now we will use a reasonable maximum limit on the number of attempts to upload
+	// before failing without trying to distinguish between retry-able errors and terminal errors.
+	var err error
+	maxRetryAttempts := 10
+	for attempt, backoffInSec := 0, 1; attempt < maxRetryAttempts; attempt++ {
+		// If upload failed, keep retrying until succeed or canceled.
+		if err = uploader.Upload(key, localFileName, contentType); err == nil {
+			return nil
+		} else {
+			log.WithFields(log.Fields{
+				"attempt": attempt,
+				"err":     err,
+			}).Warn(fmt.Sprintf("uploading file failed, Retrying (attempt %d of %d)...", attempt, maxRetryAttempts))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(backoffInSec) * time.Second):
+			// Fallthrough intended.
+		}
 
-func (b *pqBinding) upload() error {
-	return b.S3Uploader.Upload(b.s3Path(), b.localFileName(), pqContentType)
+		if backoffInSec < 16 {
+			backoffInSec *= 2
+		}
+	}
+	return fmt.Errorf("retry attempts exhausted: %w", err)
 }
@@ -4,14 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/estuary/connectors/materialize-s3-parquet/checkpoint"
 	"github.com/estuary/flow/go/protocols/fdb/tuple"
 	pf "github.com/estuary/flow/go/protocols/flow"
 	pm "github.com/estuary/flow/go/protocols/materialize"
 	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/buffer"
 	"github.com/xitongsys/parquet-go-source/local"
 	"github.com/xitongsys/parquet-go/reader"
 )
@@ -22,25 +25,40 @@ type MockFileProcessor struct {
 	CommitTimes        int
 	DestroyTimes       int
 	MockNextSeqNumList []int
-	DestroyError       error
+	// Partition sequence numbers returned by Commit.
+	MockNextPartitionSeqNums []map[string]int
+	// Pending Iceberg files returned by Commit.
+	MockPendingIcebergFiles [][]checkpoint.IcebergDataFile
+	// Arguments of each call to AppendToTables.
+	AppendedIcebergFiles [][][]checkpoint.IcebergDataFile
+	DestroyError         error
 }
 
-func newMockFileProcessor(mockNextSeqNumList []int) *MockFileProcessor {
+func newMockFileProcessor(mockNextSeqNumList []int, mockNextPartitionSeqNums []map[string]int) *MockFileProcessor {
 	return &MockFileProcessor{
-		StoreTimes:         0,
-		CommitTimes:        0,
-		DestroyTimes:       0,
-		MockNextSeqNumList: mockNextSeqNumList,
-		DestroyError:       nil,
+		StoreTimes:               0,
+		CommitTimes:              0,
+		DestroyTimes:             0,
+		MockNextSeqNumList:       mockNextSeqNumList,
+		MockNextPartitionSeqNums: mockNextPartitionSeqNums,
+		DestroyError:             nil,
 	}
 }
 func (mfp *MockFileProcessor) Store(binding int, key tuple.Tuple, values tuple.Tuple) error {
 	mfp.StoreTimes++
 	return nil
 }
-func (mfp *MockFileProcessor) Commit() ([]int, error) {
+func (mfp *MockFileProcessor) Commit() (FileState, error) {
 	mfp.CommitTimes++
-	return mfp.MockNextSeqNumList, nil
+	return FileState{
+		NextSeqNumList:       mfp.MockNextSeqNumList,
+		NextPartitionSeqNums: mfp.MockNextPartitionSeqNums,
+		PendingIcebergFiles:  mfp.MockPendingIcebergFiles,
+	}, nil
+}
+func (mfp *MockFileProcessor) AppendToTables(pendingIcebergFiles [][]checkpoint.IcebergDataFile) error {
+	mfp.AppendedIcebergFiles = append(mfp.AppendedIcebergFiles, pendingIcebergFiles)
+	return nil
 }
 func (mfp *MockFileProcessor) Destroy() error {
 	mfp.DestroyTimes++
@@ -57,9 +75,21 @@ type TestData struct {
 type MockS3Uploader struct {
 	t        *testing.T
 	contents map[string][]TestData
+	// Raw content of each uploaded key.
+	files map[string][]byte
+	// Called before each conditional upload, if set.
+	beforeCreate func(key string)
 }
 
 func (u *MockS3Uploader) Upload(key, localFileName string, contentType string) error {
+	raw, err := os.ReadFile(localFileName)
+	require.NoError(u.t, err)
+	u.files[key] = raw
+
+	if contentType != pqContentType {
+		return nil
+	}
+
 	fr, err := local.NewLocalFileReader(localFileName)
 	require.NoError(u.t, err)
 	defer fr.Close()
@@ -78,21 +108,45 @@ func (u *MockS3Uploader) Upload(key, localFileName string, contentType string) e
 
 	return nil
 }
+func (u *MockS3Uploader) Create(key, localFileName string, contentType string) (bool, error) {
+	if u.beforeCreate != nil {
+		u.beforeCreate(key)
+	}
+	if _, ok := u.files[key]; ok {
+		return false, nil
+	}
+	return true, u.Upload(key, localFileName, contentType)
+}
+func (u *MockS3Uploader) Download(key string) ([]byte, error) {
+	return u.files[key], nil
+}
+func (u *MockS3Uploader) Exists(key string) (bool, error) {
+	_, ok := u.files[key]
+	return ok, nil
+}
+func (u *MockS3Uploader) Delete(key string) error {
+	delete(u.files, key)
+	delete(u.contents, fmt.Sprintf("s3://test_bucket/%s", key))
+	return nil
+}
 func newMockS3Uploader(t *testing.T) *MockS3Uploader {
-	return &MockS3Uploader{t: t, contents: make(map[string][]TestData)}
+	return &MockS3Uploader{t: t, contents: make(map[string][]TestData), files: make(map[string][]byte)}
 }
 
 func TestFileProcessorProxy_APIs(t *testing.T) {
 	var mockClock = clock.NewMock()
 	var ctx = context.Background()
 
-	var mockFileProcessor = newMockFileProcessor([]int{1, 2, 3})
+	var mockFileProcessor = newMockFileProcessor([]int{1, 2, 3}, []map[string]int{nil, {"dt=2024": 1}, nil})
 	var proxy = NewFileProcessorProxy(ctx, mockFileProcessor, time.Second*2, mockClock)
 	mockClock.Add(time.Second)
 
 	proxy.Store(0, tuple.Tuple{}, tuple.Tuple{})
-	var nextSeqNumList, _ = proxy.Commit()
-	require.Equal(t, []int{1, 2, 3}, nextSeqNumList)
+	var state, _ = proxy.Commit()
+	require.Equal(t, []int{1, 2, 3}, state.NextSeqNumList)
+	require.Equal(t, []map[string]int{nil, {"dt=2024": 1}, nil}, state.NextPartitionSeqNums)
+	require.NoError(t, proxy.AppendToTables([][]checkpoint.IcebergDataFile{{{Path: "a"}}}))
+	require.Equal(t, [][][]checkpoint.IcebergDataFile{{{{Path: "a"}}}}, mockFileProcessor.AppendedIcebergFiles)
 	require.NoError(t, proxy.Destroy())
 
 	require.Equal(t, 1, mockFileProcessor.StoreTimes)
@@ -103,7 +157,7 @@ func TestFileProcessorProxy_APIs(t *testing.T) {
 func TestFileProcessorProxy_cancelByCtx(t *testing.T) {
 	var mockClock = clock.NewMock()
 	var ctx, cancel = context.WithCancel(context.Background())
-	var mockFileProcessor = newMockFileProcessor(nil)
+	var mockFileProcessor = newMockFileProcessor(nil, nil)
 	// acquireFileProcessor succeeds before cancel.
 	var proxy = NewFileProcessorProxy(ctx, mockFileProcessor, 2*time.Second, mockClock)
 
@@ -118,7 +172,7 @@ func TestFileProcessorProxy_cancelByCtx(t *testing.T) {
 
 func TestFileProcessorProxy_DestroyNoError(t *testing.T) {
 	var mockClock = clock.NewMock()
-	var mockFileProcessor = newMockFileProcessor(nil)
+	var mockFileProcessor = newMockFileProcessor(nil, nil)
 
 	// acquireFileProcessor succeeds before destroy.
 	var proxy = NewFileProcessorProxy(context.Background(), mockFileProcessor, 2*time.Second, mockClock)
@@ -132,7 +186,7 @@ func TestFileProcessorProxy_DestroyNoError(t *testing.T) {
 
 func TestFileProcessorProxy_DestroyWithError(t *testing.T) {
 	var mockClock = clock.NewMock()
-	var mockFileProcessor = newMockFileProcessor(nil)
+	var mockFileProcessor = newMockFileProcessor(nil, nil)
 	mockFileProcessor.DestroyError = fmt.Errorf("testing error")
 
 	// acquireFileProcessor succeeds before destroy.
@@ -145,7 +199,7 @@ func TestFileProcessorProxy_DestroyWithError(t *testing.T) {
 func TestFileProcessorProxy_TimerTriggered(t *testing.T) {
 	var mockClock = clock.NewMock()
 	var ctx = context.Background()
-	var mockFileProcessor = newMockFileProcessor(nil)
+	var mockFileProcessor = newMockFileProcessor(nil, nil)
 	var proxy = NewFileProcessorProxy(ctx, mockFileProcessor, 5*time.Second, mockClock)
 	mockClock.Add(time.Second)
 	defer func() { require.NoError(t, proxy.Destroy()) }()
@@ -197,10 +251,12 @@ func TestParquetFileProcessor_NoBinding(t *testing.T) {
 		Name: pf.Materialization("test_materialization"),
 	}}
 
-	fileProcessor, _ := NewParquetFileProcessor(context.Background(), mockS3Uploader, nil, open)
-	nextSeqNumList, err := fileProcessor.Commit()
+	fileProcessor, _ := NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{}, open)
+	state, err := fileProcessor.Commit()
 	require.NoError(t, err)
-	require.Equal(t, []int{}, nextSeqNumList)
+	require.Equal(t, []int{}, state.NextSeqNumList)
+	require.Nil(t, state.NextPartitionSeqNums)
+	require.Nil(t, state.PendingIcebergFiles)
 }
 
 func buildTestOpenRequest(numOfBindings int) *pm.Request_Open {
@@ -238,7 +294,7 @@ func TestParquetFileProcessor_SingleBinding(t *testing.T) {
 	var mockS3Uploader = newMockS3Uploader(t)
 	var open = buildTestOpenRequest(1)
 
-	fileProcessor, _ := NewParquetFileProcessor(context.Background(), mockS3Uploader, nil, open)
+	fileProcessor, _ := NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{}, open)
 
 	require.Equal(t, []int{0}, fileProcessor.nextSeqNumList())
 
@@ -246,46 +302,46 @@ func TestParquetFileProcessor_SingleBinding(t *testing.T) {
 	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{1}, tuple.Tuple{"msg #1"}))
 	require.Equal(t, 0, len(mockS3Uploader.contents))
 
-	nextSeqNumList, err := fileProcessor.Commit()
+	state, err := fileProcessor.Commit()
 	require.NoError(t, err)
 	var expectedA = []TestData{{Id: 1, Message: "msg #1"}, {Id: 1, Message: "msg #1"}}
 	require.Equal(t, expectedA, mockS3Uploader.contents["s3://test_bucket/test_path_0/0000007b/000000000.parquet"])
-	require.Equal(t, []int{1}, nextSeqNumList)
+	require.Equal(t, []int{1}, state.NextSeqNumList)
 
 	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{2}, tuple.Tuple{"msg #2"}))
-	nextSeqNumList, err = fileProcessor.Commit()
+	state, err = fileProcessor.Commit()
 	require.NoError(t, err)
 	var expectedB = []TestData{{Id: 2, Message: "msg #2"}}
 	require.Equal(t, expectedA, mockS3Uploader.contents["s3://test_bucket/test_path_0/0000007b/000000000.parquet"])
 	require.Equal(t, expectedB, mockS3Uploader.contents["s3://test_bucket/test_path_0/0000007b/000000001.parquet"])
-	require.Equal(t, []int{2}, nextSeqNumList)
+	require.Equal(t, []int{2}, state.NextSeqNumList)
 
 	// The process resumed with nextSeqNum being 1 to override the previous file.
-	fileProcessor, _ = NewParquetFileProcessor(context.Background(), mockS3Uploader, []int{1}, open)
+	fileProcessor, _ = NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{NextSeqNumList: []int{1}}, open)
 	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{2}, tuple.Tuple{"msg #2"}))
 	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{3}, tuple.Tuple{"msg #3"}))
-	nextSeqNumList, err = fileProcessor.Commit()
+	state, err = fileProcessor.Commit()
 	require.NoError(t, err)
 	var expectedC = []TestData{{Id: 2, Message: "msg #2"}, {Id: 3, Message: "msg #3"}}
 	require.Equal(t, expectedA, mockS3Uploader.contents["s3://test_bucket/test_path_0/0000007b/000000000.parquet"])
 	require.Equal(t, expectedC, mockS3Uploader.contents["s3://test_bucket/test_path_0/0000007b/000000001.parquet"])
-	require.Equal(t, []int{2}, nextSeqNumList)
+	require.Equal(t, []int{2}, state.NextSeqNumList)
 }
 
 func TestParquetFileProcessor_MultipleBindings(t *testing.T) {
 	var mockS3Uploader = newMockS3Uploader(t)
 	var open = buildTestOpenRequest(4)
 
-	fileProcessor, _ := NewParquetFileProcessor(context.Background(), mockS3Uploader, []int{1, 2, 3, 4}, open)
+	fileProcessor, _ := NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{NextSeqNumList: []int{1, 2, 3, 4}}, open)
 	require.Equal(t, []int{1, 2, 3, 4}, fileProcessor.nextSeqNumList())
 
 	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{0}, tuple.Tuple{"msg #0"}))
 	require.NoError(t, fileProcessor.Store(1, tuple.Tuple{1}, tuple.Tuple{"msg #1"}))
 	require.Equal(t, 0, len(mockS3Uploader.contents))
 
-	nextSeqNumList, err := fileProcessor.Commit()
+	state, err := fileProcessor.Commit()
 	require.NoError(t, err)
-	require.Equal(t, []int{2, 3, 3, 4}, nextSeqNumList)
+	require.Equal(t, []int{2, 3, 3, 4}, state.NextSeqNumList)
 	require.Equal(t, []TestData{{Id: 0, Message: "msg #0"}}, mockS3Uploader.contents["s3://test_bucket/test_path_0/0000007b/000000001.parquet"])
 	require.Equal(t, []TestData{{Id: 1, Message: "msg #1"}}, mockS3Uploader.contents["s3://test_bucket/test_path_1/0000007b/000000002.parquet"])
 
@@ -294,9 +350,9 @@ func TestParquetFileProcessor_MultipleBindings(t *testing.T) {
 	require.NoError(t, fileProcessor.Store(2, tuple.Tuple{4}, tuple.Tuple{"msg #4"}))
 	require.NoError(t, fileProcessor.Store(3, tuple.Tuple{5}, tuple.Tuple{"msg #5"}))
 
-	nextSeqNumList, err = fileProcessor.Commit()
+	state, err = fileProcessor.Commit()
 	require.NoError(t, err)
-	require.Equal(t, []int{3, 4, 4, 5}, nextSeqNumList)
+	require.Equal(t, []int{3, 4, 4, 5}, state.NextSeqNumList)
 	require.Equal(t, []TestData{{Id: 0, Message: "msg #0"}}, mockS3Uploader.contents["s3://test_bucket/test_path_0/0000007b/000000001.parquet"])
 	require.Equal(t, []TestData{{Id: 2, Message: "msg #2"}}, mockS3Uploader.contents["s3://test_bucket/test_path_0/0000007b/000000002.parquet"])
 	require.Equal(t, []TestData{{Id: 1, Message: "msg #1"}}, mockS3Uploader.contents["s3://test_bucket/test_path_1/0000007b/000000002.parquet"])
@@ -304,3 +360,229 @@ func TestParquetFileProcessor_MultipleBindings(t *testing.T) {
 	require.Equal(t, []TestData{{Id: 4, Message: "msg #4"}}, mockS3Uploader.contents["s3://test_bucket/test_path_2/0000007b/000000003.parquet"])
 	require.Equal(t, []TestData{{Id: 5, Message: "msg #5"}}, mockS3Uploader.contents["s3://test_bucket/test_path_3/0000007b/000000004.parquet"])
 }
+
+func TestParquetFileProcessor_Partitioned(t *testing.T) {
+	var mockS3Uploader = newMockS3Uploader(t)
+	var open = buildTestOpenRequest(2)
+	open.Materialization.Bindings[1].ResourceConfigJson, _ = json.Marshal(resource{
+		PathPrefix:  "test_path_1/",
+		PartitionBy: []partitionField{{Field: "Message", Name: "msg"}},
+	})
+
+	fileProcessor, err := NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{}, open)
+	require.NoError(t, err)
+	require.Equal(t, []map[string]int{nil, {}}, fileProcessor.nextPartitionSeqNums())
+
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{0}, tuple.Tuple{"a"}))
+	require.NoError(t, fileProcessor.Store(1, tuple.Tuple{1}, tuple.Tuple{"a"}))
+	require.NoError(t, fileProcessor.Store(1, tuple.Tuple{2}, tuple.Tuple{"b/c"}))
+	require.NoError(t, fileProcessor.Store(1, tuple.Tuple{3}, tuple.Tuple{"a"}))
+
+	state, err := fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 0}, state.NextSeqNumList)
+	require.Equal(t, []map[string]int{nil, {"msg=a": 1, "msg=b%2Fc": 1}}, state.NextPartitionSeqNums)
+	require.Equal(t, []TestData{{Id: 0, Message: "a"}}, mockS3Uploader.contents["s3://test_bucket/test_path_0/0000007b/000000000.parquet"])
+	require.Equal(t, []TestData{{Id: 1, Message: "a"}, {Id: 3, Message: "a"}}, mockS3Uploader.contents["s3://test_bucket/test_path_1/msg=a/0000007b_000000000.parquet"])
+	require.Equal(t, []TestData{{Id: 2, Message: "b/c"}}, mockS3Uploader.contents["s3://test_bucket/test_path_1/msg=b%2Fc/0000007b_000000000.parquet"])
+
+	// Sequence numbers are tracked independently for each partition.
+	require.NoError(t, fileProcessor.Store(1, tuple.Tuple{4}, tuple.Tuple{"a"}))
+	require.NoError(t, fileProcessor.Store(1, tuple.Tuple{5}, tuple.Tuple{"d"}))
+	state, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Equal(t, []map[string]int{nil, {"msg=a": 2, "msg=b%2Fc": 1, "msg=d": 1}}, state.NextPartitionSeqNums)
+	require.Equal(t, []TestData{{Id: 4, Message: "a"}}, mockS3Uploader.contents["s3://test_bucket/test_path_1/msg=a/0000007b_000000001.parquet"])
+	require.Equal(t, []TestData{{Id: 5, Message: "d"}}, mockS3Uploader.contents["s3://test_bucket/test_path_1/msg=d/0000007b_000000000.parquet"])
+
+	// A process resumed from the partition sequence numbers of the first commit removes the
+	// uncommitted files of each partition recorded by the checkpoint, whether or not it writes to
+	// them. Partitions which aren't recorded are cleaned up once the process writes to them.
+	var recovered = FileState{
+		NextSeqNumList:       []int{1, 0},
+		NextPartitionSeqNums: []map[string]int{nil, {"msg=a": 1, "msg=b%2Fc": 1}},
+	}
+	_, err = NewParquetFileProcessor(context.Background(), mockS3Uploader, recovered, open)
+	require.NoError(t, err)
+	require.NotContains(t, mockS3Uploader.contents, "s3://test_bucket/test_path_1/msg=a/0000007b_000000001.parquet")
+	require.Contains(t, mockS3Uploader.contents, "s3://test_bucket/test_path_1/msg=a/0000007b_000000000.parquet")
+	require.Contains(t, mockS3Uploader.contents, "s3://test_bucket/test_path_1/msg=d/0000007b_000000000.parquet")
+
+	// The resumed process overwrites the files of the second commit.
+	fileProcessor, err = NewParquetFileProcessor(context.Background(), mockS3Uploader, recovered, open)
+	require.NoError(t, err)
+	require.NoError(t, fileProcessor.Store(1, tuple.Tuple{6}, tuple.Tuple{"a"}))
+	require.NoError(t, fileProcessor.Store(1, tuple.Tuple{7}, tuple.Tuple{"d"}))
+	state, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Equal(t, []map[string]int{nil, {"msg=a": 2, "msg=b%2Fc": 1, "msg=d": 1}}, state.NextPartitionSeqNums)
+	require.Equal(t, []TestData{{Id: 6, Message: "a"}}, mockS3Uploader.contents["s3://test_bucket/test_path_1/msg=a/0000007b_000000001.parquet"])
+	require.Equal(t, []TestData{{Id: 7, Message: "d"}}, mockS3Uploader.contents["s3://test_bucket/test_path_1/msg=d/0000007b_000000000.parquet"])
+
+	// Once too many partitions are tracked, those which weren't written are pruned. A pruned
+	// partition which is written again is numbered from the floor.
+	fileProcessor.pqBindings[1].maxPartitionSeqNums = 2
+	require.NoError(t, fileProcessor.Store(1, tuple.Tuple{8}, tuple.Tuple{"d"}))
+	state, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Equal(t, []map[string]int{nil, {"msg=d": 2}}, state.NextPartitionSeqNums)
+	require.Equal(t, []int{0, 2}, state.PartitionSeqNumFloors)
+
+	require.NoError(t, fileProcessor.Store(1, tuple.Tuple{9}, tuple.Tuple{"b/c"}))
+	state, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Equal(t, []map[string]int{nil, {"msg=b%2Fc": 3, "msg=d": 2}}, state.NextPartitionSeqNums)
+	require.Equal(t, []TestData{{Id: 2, Message: "b/c"}}, mockS3Uploader.contents["s3://test_bucket/test_path_1/msg=b%2Fc/0000007b_000000000.parquet"])
+	require.Equal(t, []TestData{{Id: 9, Message: "b/c"}}, mockS3Uploader.contents["s3://test_bucket/test_path_1/msg=b%2Fc/0000007b_000000002.parquet"])
+
+	// Partition fields must be selected.
+	open.Materialization.Bindings[1].ResourceConfigJson, _ = json.Marshal(resource{
+		PathPrefix:  "test_path_1",
+		PartitionBy: []partitionField{{Field: "Region"}},
+	})
+	_, err = NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{}, open)
+	require.ErrorContains(t, err, `partition field "Region" is not included in the field selection`)
+}
+
+func TestParquetFileProcessor_PartitionOrphans(t *testing.T) {
+	var mockS3Uploader = newMockS3Uploader(t)
+	var open = buildTestOpenRequest(1)
+	open.Materialization.Bindings[0].ResourceConfigJson, _ = json.Marshal(resource{
+		PathPrefix:  "test_path_0",
+		PartitionBy: []partitionField{{Field: "Message", Name: "msg"}},
+	})
+	var path = func(partition string, seqNum int) string {
+		return fmt.Sprintf("s3://test_bucket/test_path_0/msg=%s/0000007b_%09d.parquet", partition, seqNum)
+	}
+
+	fileProcessor, err := NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{}, open)
+	require.NoError(t, err)
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{1}, tuple.Tuple{"a"}))
+	state, err := fileProcessor.Commit()
+	require.NoError(t, err)
+
+	// A transaction which is uploaded but never checkpointed writes two files of a partition which
+	// isn't recorded by the checkpoint.
+	fileProcessor, err = NewParquetFileProcessor(context.Background(), mockS3Uploader, state, open)
+	require.NoError(t, err)
+	fileProcessor.pqBindings[0].maxRowsPerFile = 1
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{2}, tuple.Tuple{"d"}))
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{3}, tuple.Tuple{"d"}))
+	_, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Contains(t, mockS3Uploader.contents, path("d", 1))
+
+	// The recovered process doesn't write the partition until its second transaction, which
+	// still removes the orphaned file.
+	fileProcessor, err = NewParquetFileProcessor(context.Background(), mockS3Uploader, state, open)
+	require.NoError(t, err)
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{4}, tuple.Tuple{"a"}))
+	_, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Contains(t, mockS3Uploader.contents, path("d", 1))
+
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{2}, tuple.Tuple{"d"}))
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{3}, tuple.Tuple{"d"}))
+	state, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Equal(t, []map[string]int{{"msg=a": 2, "msg=d": 1}}, state.NextPartitionSeqNums)
+	require.Equal(t, []TestData{{Id: 2, Message: "d"}, {Id: 3, Message: "d"}}, mockS3Uploader.contents[path("d", 0)])
+	require.NotContains(t, mockS3Uploader.contents, path("d", 1))
+
+	// Files written by this process are never orphans.
+	require.NoError(t, fileProcessor.Store(0, tuple.Tuple{5}, tuple.Tuple{"d"}))
+	_, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Equal(t, []TestData{{Id: 2, Message: "d"}, {Id: 3, Message: "d"}}, mockS3Uploader.contents[path("d", 0)])
+	require.Equal(t, []TestData{{Id: 5, Message: "d"}}, mockS3Uploader.contents[path("d", 1)])
+}
+
+func TestParquetFileProcessor_RollingFiles(t *testing.T) {
+	var mockS3Uploader = newMockS3Uploader(t)
+	var open = buildTestOpenRequest(1)
+	var setResource = func(res resource) {
+		open.Materialization.Bindings[0].ResourceConfigJson, _ = json.Marshal(res)
+	}
+	var storeRows = func(fileProcessor *ParquetFileProcessor, ids ...int) {
+		for _, id := range ids {
+			require.NoError(t, fileProcessor.Store(0, tuple.Tuple{id}, tuple.Tuple{fmt.Sprintf("msg #%d", id)}))
+		}
+	}
+	var path = func(seqNum int) string {
+		return fmt.Sprintf("s3://test_bucket/test_path_0/0000007b/%09d.parquet", seqNum)
+	}
+
+	// Files are rolled mid-transaction, and each is uploaded with its own sequence number.
+	setResource(resource{PathPrefix: "test_path_0", MaxRowsPerFile: 2})
+	fileProcessor, err := NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{}, open)
+	require.NoError(t, err)
+	storeRows(fileProcessor, 1, 2, 3, 4, 5)
+	state, err := fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Equal(t, []int{3}, state.NextSeqNumList)
+	require.Equal(t, []TestData{{1, "msg #1"}, {2, "msg #2"}}, mockS3Uploader.contents[path(0)])
+	require.Equal(t, []TestData{{3, "msg #3"}, {4, "msg #4"}}, mockS3Uploader.contents[path(1)])
+	require.Equal(t, []TestData{{5, "msg #5"}}, mockS3Uploader.contents[path(2)])
+
+	// A transaction which is uploaded but never checkpointed.
+	fileProcessor, err = NewParquetFileProcessor(context.Background(), mockS3Uploader, state, open)
+	require.NoError(t, err)
+	storeRows(fileProcessor, 6, 7, 8, 9, 10)
+	_, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Len(t, mockS3Uploader.contents, 6)
+
+	// The recovered process produces fewer files for the same rows, and the remaining files of
+	// the uncommitted transaction are removed.
+	setResource(resource{PathPrefix: "test_path_0", MaxRowsPerFile: 4})
+	fileProcessor, err = NewParquetFileProcessor(context.Background(), mockS3Uploader, state, open)
+	require.NoError(t, err)
+	storeRows(fileProcessor, 6, 7, 8, 9, 10)
+	state, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Equal(t, []int{5}, state.NextSeqNumList)
+	require.Equal(t, []TestData{{6, "msg #6"}, {7, "msg #7"}, {8, "msg #8"}, {9, "msg #9"}}, mockS3Uploader.contents[path(3)])
+	require.Equal(t, []TestData{{10, "msg #10"}}, mockS3Uploader.contents[path(4)])
+	require.NotContains(t, mockS3Uploader.contents, path(5))
+	require.Len(t, mockS3Uploader.contents, 5)
+
+	// Files are also rolled on their size.
+	setResource(resource{PathPrefix: "test_path_0", Format: formatJSONL, CompressionType: "none", MaxFileSizeBytes: 20})
+	fileProcessor, err = NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{}, open)
+	require.NoError(t, err)
+	storeRows(fileProcessor, 1, 2, 3)
+	_, err = fileProcessor.Commit()
+	require.NoError(t, err)
+	require.Equal(t, `{"Id":1,"Message":"msg #1"}`+"\n", string(mockS3Uploader.files["test_path_0/0000007b/000000000.jsonl"]))
+	require.Equal(t, `{"Id":3,"Message":"msg #3"}`+"\n", string(mockS3Uploader.files["test_path_0/0000007b/000000002.jsonl"]))
+	require.NotContains(t, mockS3Uploader.files, "test_path_0/0000007b/000000003.jsonl")
+}
+
+func TestParquetFileProcessor_RowGroupSize(t *testing.T) {
+	var rowGroups = func(rowGroupSizeBytes int64) int {
+		var mockS3Uploader = newMockS3Uploader(t)
+		var open = buildTestOpenRequest(1)
+		open.Materialization.Bindings[0].ResourceConfigJson, _ = json.Marshal(resource{
+			PathPrefix:        "test_path_0",
+			RowGroupSizeBytes: rowGroupSizeBytes,
+		})
+
+		fileProcessor, err := NewParquetFileProcessor(context.Background(), mockS3Uploader, FileState{}, open)
+		require.NoError(t, err)
+		// The parquet writer checks the size of its row group only after buffering enough rows.
+		for id := 0; id != 10000; id++ {
+			require.NoError(t, fileProcessor.Store(0, tuple.Tuple{id}, tuple.Tuple{fmt.Sprintf("msg #%d", id)}))
+		}
+		_, err = fileProcessor.Commit()
+		require.NoError(t, err)
+
+		var fr = buffer.NewBufferFileFromBytes(mockS3Uploader.files["test_path_0/0000007b/000000000.parquet"])
+		pr, err := reader.NewParquetReader(fr, nil, 1)
+		require.NoError(t, err)
+		defer pr.ReadStop()
+		return len(pr.Footer.RowGroups)
+	}
+
+	require.Equal(t, 1, rowGroups(0))
+	require.Greater(t, rowGroups(1024), 1)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+)
+
+// partitionField specifies a field whose values partition the files of a binding into Hive-style
+// `name=value` directories.
+type partitionField struct {
+	// The selected field which provides the partition value.
+	Field string `json:"field"`
+	// Name of the partition in file paths. Defaults to the name of the field.
+	Name string `json:"name,omitempty"`
+	// Truncates date or date-time values of the field to the year, month, day, or hour. The raw
+	// value of the field is used if unset.
+	Granularity string `json:"granularity,omitempty"`
+}
+
+// Layouts of truncated date-time partition values, keyed on their granularity.
+var partitionGranularityLayouts = map[string]string{
+	"year":  "2006",
+	"month": "2006-01",
+	"day":   "2006-01-02",
+	"hour":  "2006-01-02T15",
+}
+
+// hiveDefaultPartition is the partition value used by Hive for null values.
+const hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+func (p partitionField) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Field
+}
+
+func (p partitionField) Validate() error {
+	if p.Field == "" {
+		return fmt.Errorf("missing partition field")
+	} else if strings.ContainsAny(p.name(), "/=") {
+		return fmt.Errorf("partition name %q must not contain '/' or '='", p.name())
+	} else if _, ok := partitionGranularityLayouts[p.Granularity]; p.Granularity != "" && !ok {
+		return fmt.Errorf("invalid granularity %q of partition field %q, expecting one of year, month, day, or hour", p.Granularity, p.Field)
+	}
+	return nil
+}
+
+// validatePartitionField returns an error if the projection cannot partition the files of a binding.
+func validatePartitionField(p partitionField, projection *pf.Projection) error {
+	if projection == nil {
+		return fmt.Errorf("partition field %q is not a projection of the collection", p.Field)
+	} else if !projection.Inference.IsSingleScalarType() {
+		return fmt.Errorf("partition field %q must have a single scalar type, not %v", p.Field, projection.Inference.Types)
+	}
+
+	if p.Granularity != "" {
+		var format string
+		if projection.Inference.String_ != nil {
+			format = projection.Inference.String_.Format
+		}
+		if format != "date" && format != "date-time" {
+			return fmt.Errorf("partition field %q must be a date or date-time string to be truncated to a %s", p.Field, p.Granularity)
+		}
+	}
+
+	return nil
+}
+
+// partitioner builds the partition paths of rows stored to a binding.
+type partitioner struct {
+	fields []partitionField
+	// Indices of the fields within the concatenated key and values of a row.
+	indices []int
+}
+
+func newPartitioner(fields []partitionField, fieldSelection pf.FieldSelection) (*partitioner, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	var allFields = append(append([]string{}, fieldSelection.Keys...), fieldSelection.Values...)
+	var p = &partitioner{fields: fields}
+
+	for _, field := range fields {
+		var index = -1
+		for i, f := range allFields {
+			if f == field.Field {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			return nil, fmt.Errorf("partition field %q is not included in the field selection", field.Field)
+		}
+		p.indices = append(p.indices, index)
+	}
+
+	return p, nil
+}
+
+// path returns the partition path of the row, like `dt=2024-05-01/region=us`.
+func (p *partitioner) path(key tuple.Tuple, values tuple.Tuple) (string, error) {
+	var segments = make([]string, 0, len(p.fields))
+
+	for i, field := range p.fields {
+		var elem tuple.TupleElement
+		if idx := p.indices[i]; idx < len(key) {
+			elem = key[idx]
+		} else {
+			elem = values[idx-len(key)]
+		}
+
+		value, err := partitionValue(field, elem)
+		if err != nil {
+			return "", fmt.Errorf("partition field %q: %w", field.Field, err)
+		}
+		segments = append(segments, escapePartitionPath(field.name())+"="+value)
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+func partitionValue(field partitionField, elem tuple.TupleElement) (string, error) {
+	if elem == nil {
+		return hiveDefaultPartition, nil
+	} else if field.Granularity == "" {
+		return escapePartitionPath(fmt.Sprint(elem)), nil
+	}
+
+	str, ok := elem.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a date or date-time string, got %T", elem)
+	}
+	ts, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		if ts, err = time.Parse("2006-01-02", str); err != nil {
+			return "", fmt.Errorf("parsing %q as a date or date-time: %w", str, err)
+		}
+	}
+
+	return ts.UTC().Format(partitionGranularityLayouts[field.Granularity]), nil
+}
+
+// escapePartitionPath escapes characters of a partition name or value in the manner of Hive, so
+// that it forms a single, unambiguous path segment.
+func escapePartitionPath(s string) string {
+	if s == "" {
+		return hiveDefaultPartition
+	}
+
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if c < 0x20 || c == 0x7f || strings.IndexByte("\"#%'*/:=?\\{[]^", c) != -1 {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionerPath(t *testing.T) {
+	var fieldSelection = pf.FieldSelection{Keys: []string{"id"}, Values: []string{"ts", "region", "count"}}
+
+	p, err := newPartitioner(nil, fieldSelection)
+	require.NoError(t, err)
+	require.Nil(t, p)
+
+	p, err = newPartitioner([]partitionField{
+		{Field: "ts", Name: "dt", Granularity: "day"},
+		{Field: "region"},
+		{Field: "id"},
+	}, fieldSelection)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		key    tuple.Tuple
+		values tuple.Tuple
+		want   string
+	}{
+		{tuple.Tuple{int64(1)}, tuple.Tuple{"2024-05-01T23:59:59Z", "us", int64(2)}, "dt=2024-05-01/region=us/id=1"},
+		{tuple.Tuple{int64(1)}, tuple.Tuple{"2024-05-01T20:00:00-05:00", "us", int64(2)}, "dt=2024-05-02/region=us/id=1"},
+		{tuple.Tuple{true}, tuple.Tuple{"2024-05-01", nil, nil}, "dt=2024-05-01/region=__HIVE_DEFAULT_PARTITION__/id=true"},
+		{tuple.Tuple{"a/b"}, tuple.Tuple{nil, "", nil}, "dt=__HIVE_DEFAULT_PARTITION__/region=__HIVE_DEFAULT_PARTITION__/id=a%2Fb"},
+		{tuple.Tuple{"x"}, tuple.Tuple{"2024-05-01T00:00:00Z", "k=v:1", nil}, "dt=2024-05-01/region=k%3Dv%3A1/id=x"},
+	} {
+		got, err := p.path(tc.key, tc.values)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, got)
+	}
+
+	_, err = p.path(tuple.Tuple{int64(1)}, tuple.Tuple{"yesterday", "us", nil})
+	require.ErrorContains(t, err, `partition field "ts": parsing "yesterday"`)
+	_, err = p.path(tuple.Tuple{int64(1)}, tuple.Tuple{int64(20240501), "us", nil})
+	require.ErrorContains(t, err, "expected a date or date-time string, got int64")
+
+	_, err = newPartitioner([]partitionField{{Field: "missing"}}, fieldSelection)
+	require.ErrorContains(t, err, `partition field "missing" is not included in the field selection`)
+}
+
+func TestPartitionGranularity(t *testing.T) {
+	for granularity, want := range map[string]string{
+		"year":  "2024",
+		"month": "2024-05",
+		"day":   "2024-05-01",
+		"hour":  "2024-05-01T13",
+	} {
+		got, err := partitionValue(partitionField{Field: "ts", Granularity: granularity}, "2024-05-01T13:14:15.123456Z")
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+}
+
+func TestValidatePartitionField(t *testing.T) {
+	var dateTime = &pf.Projection{Field: "ts", Inference: pf.Inference{
+		Types:   []string{"string"},
+		String_: &pf.Inference_String{Format: "date-time"},
+	}}
+	var str = &pf.Projection{Field: "region", Inference: pf.Inference{Types: []string{"string", "null"}}}
+	var obj = &pf.Projection{Field: "obj", Inference: pf.Inference{Types: []string{"object"}}}
+	var multi = &pf.Projection{Field: "multi", Inference: pf.Inference{Types: []string{"string", "integer"}}}
+
+	require.NoError(t, validatePartitionField(partitionField{Field: "ts", Granularity: "hour"}, dateTime))
+	require.NoError(t, validatePartitionField(partitionField{Field: "ts"}, dateTime))
+	require.NoError(t, validatePartitionField(partitionField{Field: "region"}, str))
+	require.ErrorContains(t, validatePartitionField(partitionField{Field: "region", Granularity: "day"}, str), "must be a date or date-time string")
+	require.ErrorContains(t, validatePartitionField(partitionField{Field: "obj"}, obj), "must have a single scalar type")
+	require.ErrorContains(t, validatePartitionField(partitionField{Field: "multi"}, multi), "must have a single scalar type")
+	require.ErrorContains(t, validatePartitionField(partitionField{Field: "missing"}, nil), "is not a projection of the collection")
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -14,6 +18,14 @@ import (
 // Uploader is the interface of the object responsible for uploading local files to the cloud.
 type Uploader interface {
 	Upload(key, localFileName string, contextType string) error
+	// Uploads the local file only if the key does not exist, and returns whether it was uploaded.
+	Create(key, localFileName string, contentType string) (bool, error)
+	// Downloads the content of the key, which is nil if the key does not exist.
+	Download(key string) ([]byte, error)
+	// Returns whether the key exists.
+	Exists(key string) (bool, error)
+	// Deletes the key.
+	Delete(key string) error
 }
 
 // S3Uploader implements the Uploader interface for uploading files to S3.
@@ -42,6 +54,69 @@ func (u *S3Uploader) Upload(key, localFileName string, contentType string) error
 	return err
 }
 
+// Create implements the S3Uploader interface.
+func (u *S3Uploader) Create(key, localFileName string, contentType string) (bool, error) {
+	f, err := os.Open(localFileName)
+	if err != nil {
+		return false, fmt.Errorf("opening local file: %w", err)
+	}
+	defer f.Close()
+
+	_, err = u.uploaderImp.S3.PutObjectWithContext(context.Background(), &s3.PutObjectInput{
+		Bucket:      &u.bucket,
+		ContentType: &contentType,
+		Key:         &key,
+		Body:        f,
+	}, request.WithSetRequestHeaders(map[string]string{"If-None-Match": "*"}))
+	// The key exists if the precondition fails, or if it's concurrently being created.
+	if rerr, ok := err.(awserr.RequestFailure); ok && (rerr.StatusCode() == 412 || rerr.StatusCode() == 409) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Download implements the S3Uploader interface.
+func (u *S3Uploader) Download(key string) ([]byte, error) {
+	out, err := u.uploaderImp.S3.GetObject(&s3.GetObjectInput{
+		Bucket: &u.bucket,
+		Key:    &key,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	return io.ReadAll(out.Body)
+}
+
+// Exists implements the S3Uploader interface.
+func (u *S3Uploader) Exists(key string) (bool, error) {
+	_, err := u.uploaderImp.S3.HeadObject(&s3.HeadObjectInput{
+		Bucket: &u.bucket,
+		Key:    &key,
+	})
+	// HEAD responses have no body, and so a missing key is reported by its status alone.
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NotFound" {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete implements the S3Uploader interface.
+func (u *S3Uploader) Delete(key string) error {
+	_, err := u.uploaderImp.S3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: &u.bucket,
+		Key:    &key,
+	})
+	return err
+}
+
 // NewS3Uploader creates an uploader for s3 given input configs.
 func NewS3Uploader(cfg config) (*S3Uploader, error) {
 	var c = aws.NewConfig()
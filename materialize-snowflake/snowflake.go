@@ -130,6 +130,10 @@ type tableConfig struct {
 	Schema string `json:"schema,omitempty" jsonschema:"title=Alternative Schema,description=Alternative schema for this table (optional)"`
 	Delta  bool   `json:"delta_updates,omitempty"`
 
+	ClusterFields []string `json:"cluster_fields,omitempty" jsonschema:"title=Cluster Fields,description=Fields used as the clustering key of the table (optional)."`
+
+	Retention *sql.Retention `json:"retention,omitempty" jsonschema:"title=Retention,description=Remove rows from the table once they are older than a number of days."`
+
 	// If the endpoint schema is the same as the resource schema, the resource path will be only the
 	// table name. This is to provide compatibility for materializations that were created prior to
 	// the resource-level schema setting existing, which always had a resource path of only the
@@ -150,6 +154,11 @@ func (c tableConfig) Validate() error {
 	if c.Table == "" {
 		return fmt.Errorf("expected table")
 	}
+	if c.Retention != nil {
+		if err := c.Retention.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -175,6 +184,10 @@ func (c tableConfig) DeltaUpdates() bool {
 	return c.Delta
 }
 
+func (c tableConfig) TableOptions() sql.TableOptions {
+	return sql.TableOptions{ClusterBy: c.ClusterFields, Retention: c.Retention}
+}
+
 // The Snowflake driver Params map uses string pointers as values, which is what this is used for.
 var trueString = "true"
 
@@ -210,14 +223,15 @@ func newSnowflakeDriver() *sql.Driver {
 				MetaSpecs: &metaSpecs,
 				// Snowflake does not use the checkpoint table, instead we use the recovery log
 				// as the authoritative checkpoint and idempotent apply pattern
-				MetaCheckpoints:      nil,
-				NewClient:            newClient,
-				CreateTableTemplate:  templates["createTargetTable"],
-				ReplaceTableTemplate: templates["replaceTargetTable"],
-				NewResource:          newTableConfig,
-				NewTransactor:        newTransactor,
-				Tenant:               tenant,
-				ConcurrentApply:      true,
+				MetaCheckpoints:       nil,
+				NewClient:             newClient,
+				CreateTableTemplate:   templates["createTargetTable"],
+				ReplaceTableTemplate:  templates["replaceTargetTable"],
+				DeleteExpiredTemplate: templates["deleteExpired"],
+				NewResource:           newTableConfig,
+				NewTransactor:         newTransactor,
+				Tenant:                tenant,
+				ConcurrentApply:       true,
 			}, nil
 		},
 	}
@@ -241,6 +255,7 @@ type transactor struct {
 	bindings    []*binding
 	updateDelay time.Duration
 	cp          checkpoint
+	retention   *sql.RetentionEnforcer
 }
 
 func (t *transactor) AckDelay() time.Duration {
@@ -314,6 +329,10 @@ func newTransactor(
 		}
 	}
 
+	if d.retention, err = sql.NewRetentionEnforcer(bindings, d.templates["deleteExpired"], 0); err != nil {
+		return nil, err
+	}
+
 	return d, nil
 }
 
@@ -527,6 +546,18 @@ func renderWithDir(tpl string, dir string) string {
 
 // Acknowledge merges data from temporary table to main table
 func (d *transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) {
+	// Remove expired rows from tables having a retention. This happens before the staged files of
+	// the transaction are merged and removed, so that a failure leaves them to be merged again.
+	if err := d.retention.Enforce(ctx, func(ctx context.Context, statement string) (int64, error) {
+		result, err := d.store.conn.ExecContext(ctx, statement)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}); err != nil {
+		return nil, err
+	}
+
 	var asyncCtx = sf.WithAsyncMode(ctx)
 	log.Info("store: starting committing changes")
 
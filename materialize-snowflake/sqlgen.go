@@ -129,6 +129,20 @@ func renderTemplates(dialect sql.Dialect) map[string]*template.Template {
 flow_temp_table_{{ $.Binding }}
 {{- end }}
 
+-- Templated clustering clause of a table definition, if cluster columns are
+-- configured.
+
+{{ define "clusterBy" -}}
+{{- if $.ClusterColumns }}
+CLUSTER BY (
+	{{- range $ind, $col := $.ClusterColumns }}
+	{{- if $ind }}, {{end -}}
+	{{$col.Identifier}}
+	{{- end -}}
+)
+{{- end -}}
+{{ end }}
+
 -- Templated creation of a materialized table definition and comments:
 
 {{ define "createTargetTable" }}
@@ -146,7 +160,7 @@ CREATE TABLE IF NOT EXISTS {{$.Identifier}} (
 	{{- end -}}
 )
 {{- end }}
-);
+){{ template "clusterBy" $ }};
 
 COMMENT ON TABLE {{$.Identifier}} IS {{Literal $.Comment}};
 {{- range $col := .Columns }}
@@ -172,7 +186,7 @@ CREATE OR REPLACE TABLE {{$.Identifier}} (
 	{{- end -}}
 )
 {{- end }}
-);
+){{ template "clusterBy" $ }};
 
 COMMENT ON TABLE {{$.Identifier}} IS {{Literal $.Comment}};
 {{- range $col := .Columns }}
@@ -244,6 +258,14 @@ COPY INTO {{ $.Identifier }} (
 );
 {{ end }}
 
+-- Templated deletion of the rows which are older than the retention of a
+-- materialized table. All expired rows are deleted by one statement.
+
+{{ define "deleteExpired" -}}
+DELETE FROM {{$.Identifier}}
+WHERE {{$.RetentionColumn.Identifier}} < DATEADD(day, -{{$.Options.Retention.Days}}, CURRENT_TIMESTAMP());
+{{- end }}
+
 
 {{ define "mergeInto" }}
 MERGE INTO {{ $.Identifier }} AS l
@@ -293,6 +315,7 @@ WHEN NOT MATCHED THEN
 		"loadQuery":          tplAll.Lookup("loadQuery"),
 		"copyInto":           tplAll.Lookup("copyInto"),
 		"mergeInto":          tplAll.Lookup("mergeInto"),
+		"deleteExpired":      tplAll.Lookup("deleteExpired"),
 	}
 }
 
@@ -31,6 +31,8 @@ func TestSQLGeneration(t *testing.T) {
 		Schema:         "default",
 		Table:          "Delta Updates",
 		Delta:          true,
+		ClusterFields:  []string{"aValue"},
+		Retention:      &sqlDriver.Retention{Field: "aValue", Days: 30},
 		endpointSchema: "default",
 	})
 	shape2.Document = nil // TODO(johnny): this is a bit gross.
@@ -116,6 +118,10 @@ func TestSQLGeneration(t *testing.T) {
 		snap.WriteString("--- End " + testcase.name + " ---\n\n")
 	}
 
+	snap.WriteString("--- Begin " + table2.Identifier + " deleteExpired ---\n")
+	require.NoError(t, templates["deleteExpired"].Execute(&snap, &table2))
+	snap.WriteString("\n--- End " + table2.Identifier + " deleteExpired ---\n\n")
+
 	var shapeNoValues = sqlDriver.BuildTableShape(spec, 2, tableConfig{
 		Table: "target_table_no_values_materialized",
 		Delta: false,
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
 	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
@@ -51,7 +52,7 @@ type TableAlter struct {
 }
 
 // MetaSpecsUpdate is an endpoint-specific parameterized query and parameters needed to persist a
-// new or updated materialization spec.
+// new or updated materialization spec, or a record of an applied schema change.
 type MetaSpecsUpdate struct {
 	ParameterizedQuery string
 	Parameters         []interface{}
@@ -64,29 +65,37 @@ type sqlApplier struct {
 	client   Client
 	is       *boilerplate.InfoSchema
 	endpoint *Endpoint
+
+	// storedSpec is the previously applied materialization spec, if any, as returned by LoadSpec,
+	// and storedVersion is its version.
+	storedSpec    *pf.MaterializationSpec
+	storedVersion string
+	// version is the version of the materialization spec being applied.
+	version string
+
+	// metaTablesCreated is closed once the meta tables exist, which applied schema changes are
+	// recorded in.
+	metaTablesCreated chan struct{}
 }
 
-func newSqlApplier(client Client, is *boilerplate.InfoSchema, endpoint *Endpoint) *sqlApplier {
+func newSqlApplier(client Client, is *boilerplate.InfoSchema, endpoint *Endpoint, version string) *sqlApplier {
 	return &sqlApplier{
-		client:   client,
-		is:       is,
-		endpoint: endpoint,
+		client:            client,
+		is:                is,
+		endpoint:          endpoint,
+		version:           version,
+		metaTablesCreated: make(chan struct{}),
 	}
 }
 
 func (a *sqlApplier) CreateMetaTables(ctx context.Context, spec *pf.MaterializationSpec) (string, boilerplate.ActionApplyFn, error) {
-	if (a.endpoint.MetaCheckpoints == nil || a.is.HasResource(a.endpoint.MetaCheckpoints.Path)) &&
-		(a.endpoint.MetaSpecs == nil || a.is.HasResource(a.endpoint.MetaSpecs.Path)) {
-		// If this materialization does not use the checkpoints or specs table OR it does and
-		// they already exist, there is nothing more to do here.
-		return "", nil, nil
-	}
-
 	var creates []TableCreate
 	var actionDesc []string
 
-	for _, meta := range []*TableShape{a.endpoint.MetaSpecs, a.endpoint.MetaCheckpoints} {
-		if meta == nil {
+	for _, meta := range []*TableShape{a.endpoint.MetaSpecs, a.endpoint.MetaCheckpoints, a.endpoint.MetaSchemaChanges} {
+		if meta == nil || a.is.HasResource(meta.Path) {
+			// If this materialization does not use the meta table OR it does and it already
+			// exists, there is nothing more to do for it.
 			continue
 		}
 		resolved, err := ResolveTable(*meta, a.endpoint.Dialect)
@@ -106,6 +115,7 @@ func (a *sqlApplier) CreateMetaTables(ctx context.Context, spec *pf.Materializat
 	}
 
 	if len(creates) == 0 {
+		close(a.metaTablesCreated)
 		return "", nil, nil
 	}
 
@@ -115,11 +125,17 @@ func (a *sqlApplier) CreateMetaTables(ctx context.Context, spec *pf.Materializat
 				return err
 			}
 		}
+		close(a.metaTablesCreated)
 		return nil
 	}, nil
 }
 
 func (a *sqlApplier) CreateResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int) (string, boilerplate.ActionApplyFn, error) {
+	desc, action, err := a.createResource(ctx, spec, bindingIndex)
+	return desc, a.recordSchemaChange(spec, bindingIndex, desc, action), err
+}
+
+func (a *sqlApplier) createResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int) (string, boilerplate.ActionApplyFn, error) {
 	table, err := getTable(a.endpoint, spec, bindingIndex)
 	if err != nil {
 		return "", nil, err
@@ -130,20 +146,30 @@ func (a *sqlApplier) CreateResource(ctx context.Context, spec *pf.Materializatio
 		return "", nil, err
 	}
 
-	return createStatement, func(ctx context.Context) error {
-		return a.client.CreateTable(ctx, TableCreate{
+	indexStatements, err := a.renderIndexes(table.Indexes, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return strings.Join(append([]string{createStatement}, indexStatements...), "\n"), func(ctx context.Context) error {
+		if err := a.client.CreateTable(ctx, TableCreate{
 			Table:              table,
 			TableCreateSql:     createStatement,
 			ResourceConfigJson: spec.Bindings[bindingIndex].ResourceConfigJson,
-		})
+		}); err != nil {
+			return err
+		}
+		return a.createIndexes(ctx, indexStatements)
 	}, nil
 }
 
 func (a *sqlApplier) LoadSpec(ctx context.Context, materialization pf.Materialization) (*pf.MaterializationSpec, error) {
-	spec, _, err := loadSpec(ctx, a.client, a.endpoint, materialization)
+	spec, version, err := loadSpec(ctx, a.client, a.endpoint, materialization)
 	if err != nil {
 		return nil, err
 	}
+	a.storedSpec = spec
+	a.storedVersion = version
 
 	return spec, nil
 }
@@ -195,6 +221,11 @@ func (a *sqlApplier) PutSpec(ctx context.Context, spec *pf.MaterializationSpec,
 }
 
 func (a *sqlApplier) ReplaceResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int) (string, boilerplate.ActionApplyFn, error) {
+	desc, action, err := a.replaceResource(ctx, spec, bindingIndex)
+	return desc, a.recordSchemaChange(spec, bindingIndex, desc, action), err
+}
+
+func (a *sqlApplier) replaceResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int) (string, boilerplate.ActionApplyFn, error) {
 	table, err := getTable(a.endpoint, spec, bindingIndex)
 	if err != nil {
 		return "", nil, err
@@ -205,14 +236,35 @@ func (a *sqlApplier) ReplaceResource(ctx context.Context, spec *pf.Materializati
 		return "", nil, err
 	}
 
-	return a.client.ReplaceTable(ctx, TableReplace{
+	desc, action, err := a.client.ReplaceTable(ctx, TableReplace{
 		Table:              table,
 		TableReplaceSql:    replaceStatement,
 		ResourceConfigJson: spec.Bindings[bindingIndex].ResourceConfigJson,
 	})
+	if err != nil || len(table.Indexes) == 0 {
+		return desc, action, err
+	}
+
+	// Indexes of the replaced table are dropped along with it and must be created again.
+	indexStatements, err := a.renderIndexes(table.Indexes, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return strings.Join(append([]string{desc}, indexStatements...), "\n"), func(ctx context.Context) error {
+		if err := action(ctx); err != nil {
+			return err
+		}
+		return a.createIndexes(ctx, indexStatements)
+	}, nil
 }
 
 func (a *sqlApplier) UpdateResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int, bindingUpdate boilerplate.BindingUpdate) (string, boilerplate.ActionApplyFn, error) {
+	desc, action, err := a.updateResource(ctx, spec, bindingIndex, bindingUpdate)
+	return desc, a.recordSchemaChange(spec, bindingIndex, desc, action), err
+}
+
+func (a *sqlApplier) updateResource(ctx context.Context, spec *pf.MaterializationSpec, bindingIndex int, bindingUpdate boilerplate.BindingUpdate) (string, boilerplate.ActionApplyFn, error) {
 	table, err := getTable(a.endpoint, spec, bindingIndex)
 	if err != nil {
 		return "", nil, err
@@ -240,13 +292,94 @@ func (a *sqlApplier) UpdateResource(ctx context.Context, spec *pf.Materializatio
 		alter.AddColumns = append(alter.AddColumns, col)
 	}
 
-	// We only currently handle adding columns or dropping nullability constraints for SQL
-	// materializations.
+	newIndexes, err := a.newIndexes(spec.Bindings[bindingIndex].ResourcePath, table.Indexes)
+	if err != nil {
+		return "", nil, err
+	}
+	// Indexes added to an existing table are built concurrently where supported, to avoid locking
+	// a table that may be in active use.
+	indexStatements, err := a.renderIndexes(newIndexes, true)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// We only currently handle adding columns, dropping nullability constraints, and adding
+	// indexes for SQL materializations.
 	if len(alter.AddColumns) == 0 && len(alter.DropNotNulls) == 0 {
-		return "", nil, nil
+		if len(indexStatements) == 0 {
+			return "", nil, nil
+		}
+		return strings.Join(indexStatements, "\n"), func(ctx context.Context) error {
+			return a.createIndexes(ctx, indexStatements)
+		}, nil
+	}
+
+	desc, action, err := a.client.AlterTable(ctx, alter)
+	if err != nil || len(indexStatements) == 0 {
+		return desc, action, err
+	}
+
+	return strings.Join(append([]string{desc}, indexStatements...), "\n"), func(ctx context.Context) error {
+		if err := action(ctx); err != nil {
+			return err
+		}
+		return a.createIndexes(ctx, indexStatements)
+	}, nil
+}
+
+// newIndexes returns the indexes which were not part of the resource configuration of the binding
+// with the same resource path in the previously applied spec. All indexes are considered new if
+// there is no such binding.
+func (a *sqlApplier) newIndexes(resourcePath []string, indexes []Index) ([]Index, error) {
+	if len(indexes) == 0 || a.storedSpec == nil {
+		return indexes, nil
+	}
+
+	var existing = make(map[string]bool)
+	for _, b := range a.storedSpec.Bindings {
+		if !slices.Equal(b.ResourcePath, resourcePath) {
+			continue
+		}
+
+		resource := a.endpoint.NewResource(a.endpoint)
+		if err := pf.UnmarshalStrict(b.ResourceConfigJson, resource); err != nil {
+			return nil, fmt.Errorf("unmarshalling stored resource binding for collection %q: %w", b.Collection.Name.String(), err)
+		}
+		for _, ti := range tableOptionsFor(resource).Indexes {
+			existing[indexName(resource.Path(), ti)] = true
+		}
 	}
 
-	return a.client.AlterTable(ctx, alter)
+	var out []Index
+	for _, idx := range indexes {
+		if !existing[idx.Name] {
+			out = append(out, idx)
+		}
+	}
+	return out, nil
+}
+
+// renderIndexes renders the statements which create the indexes.
+func (a *sqlApplier) renderIndexes(indexes []Index, concurrently bool) ([]string, error) {
+	var out []string
+	for _, idx := range indexes {
+		idx.Concurrently = concurrently
+		statement, err := RenderIndexTemplate(idx, a.endpoint.CreateIndexTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("rendering index %s: %w", idx.Name, err)
+		}
+		out = append(out, statement)
+	}
+	return out, nil
+}
+
+// createIndexes executes index creation statements. Each statement is executed individually, since
+// some endpoints cannot build indexes concurrently within a transaction.
+func (a *sqlApplier) createIndexes(ctx context.Context, statements []string) error {
+	if len(statements) == 0 {
+		return nil
+	}
+	return a.client.ExecStatements(ctx, statements)
 }
 
 func getTable(endpoint *Endpoint, spec *pf.MaterializationSpec, bindingIndex int) (Table, error) {
@@ -122,6 +122,8 @@ func (d *Driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Re
 		)
 		if err != nil {
 			return nil, err
+		} else if err := ValidateTableOptions(endpoint, res, bindingSpec.Collection, bindingSpec.FieldConfigJsonMap, constraints); err != nil {
+			return nil, err
 		}
 
 		resp.Bindings = append(resp.Bindings,
@@ -161,7 +163,7 @@ func (d *Driver) Apply(ctx context.Context, req *pm.Request_Apply) (*pm.Response
 		return nil, err
 	}
 
-	return boilerplate.ApplyChanges(ctx, req, newSqlApplier(client, is, endpoint), is, endpoint.ConcurrentApply)
+	return boilerplate.ApplyChanges(ctx, req, newSqlApplier(client, is, endpoint, req.Version), is, endpoint.ConcurrentApply)
 }
 
 func (d *Driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Transactor, *pm.Response_Opened, error) {
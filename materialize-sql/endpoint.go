@@ -31,7 +31,8 @@ type Client interface {
 	// the relevant schemas.
 	InfoSchema(ctx context.Context, resourcePaths [][]string) (*boilerplate.InfoSchema, error)
 
-	// PutSpec executes the MetaSpecsUpdate to upsert the spec into the metadata table.
+	// PutSpec executes the MetaSpecsUpdate to upsert the spec into the metadata table, or to
+	// insert a record into the schema changes metadata table.
 	PutSpec(ctx context.Context, spec MetaSpecsUpdate) error
 
 	// CreateTable creates a table in the destination system.
@@ -95,6 +96,9 @@ type Endpoint struct {
 	// MetaCheckpoints is the checkpoints meta-table of the Endpoint.
 	// It's optional, and won't be created or used if it's nil.
 	MetaCheckpoints *TableShape
+	// MetaSchemaChanges is the schema change audit log meta-table of the Endpoint.
+	// It's optional, and schema changes won't be recorded if it's nil.
+	MetaSchemaChanges *TableShape
 	// NewClient creates a client, which provides Endpoint-specific methods for performing
 	// operations with the Endpoint store.
 	NewClient func(context.Context, *Endpoint) (Client, error)
@@ -102,6 +106,13 @@ type Endpoint struct {
 	CreateTableTemplate *template.Template
 	// ReplaceTableTemplate evaluates a Table into an endpoint statement which creates or replaces it.
 	ReplaceTableTemplate *template.Template
+	// CreateIndexTemplate evaluates an Index into an endpoint statement which creates it. It's
+	// optional, and resources may not declare indexes if it's nil.
+	CreateIndexTemplate *template.Template
+	// DeleteExpiredTemplate evaluates DeleteExpiredParams into an endpoint statement which deletes
+	// rows, or a batch of rows, that are older than the Retention of its Table. It's optional, and resources may not declare a
+	// Retention if it's nil.
+	DeleteExpiredTemplate *template.Template
 	// NewResource returns an uninitialized or partially-initialized Resource
 	// which will be parsed into and validated from a resource configuration.
 	NewResource func(*Endpoint) Resource
@@ -0,0 +1,125 @@
+package sql
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+)
+
+// loadCacheEntryOverhead is an approximation of the memory used by each entry of a LoadCache, in
+// addition to its key and document.
+const loadCacheEntryOverhead = 128
+
+// LoadCache is a bounded, least-recently-used cache of the documents most recently committed for
+// keys of standard (non-delta) bindings. A Transactor may answer Loads of cached keys from memory
+// and query its endpoint only for misses.
+//
+// Documents are added to the cache only once the transaction that stored them has committed:
+// keys stored by a transaction are removed from the cache as they are stored, and their documents
+// are added by Committed. This preserves read-committed semantics for Loads of the next
+// transaction, which may be pipelined with the commit of the prior one. A LoadCache is scoped to
+// the Fence of a single Transactor, and a Transactor which is fenced off will fail to commit, so
+// nothing it stores after another instance has taken over is ever added to its cache.
+//
+// All methods may be called on a nil *LoadCache, which caches nothing.
+type LoadCache struct {
+	maxBytes int
+
+	mu      sync.Mutex
+	size    int
+	entries map[loadCacheKey]*list.Element
+	lru     *list.List // Of *loadCacheEntry, most recently used at the front.
+	pending []loadCacheEntry
+}
+
+type loadCacheKey struct {
+	binding   int
+	packedKey string
+}
+
+type loadCacheEntry struct {
+	key loadCacheKey
+	doc json.RawMessage
+}
+
+func (e *loadCacheEntry) size() int {
+	return len(e.key.packedKey) + len(e.doc) + loadCacheEntryOverhead
+}
+
+// NewLoadCache returns a LoadCache which uses approximately maxBytes of memory. It returns nil if
+// maxBytes is not positive.
+func NewLoadCache(maxBytes int) *LoadCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &LoadCache{
+		maxBytes: maxBytes,
+		entries:  make(map[loadCacheKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Get returns the committed document of the binding's packed key, if it's cached.
+func (c *LoadCache) Get(binding int, packedKey []byte) (json.RawMessage, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[loadCacheKey{binding, string(packedKey)}]; ok {
+		c.lru.MoveToFront(el)
+		return el.Value.(*loadCacheEntry).doc, true
+	}
+	return nil, false
+}
+
+// Stored records that the document of the binding's packed key has been stored by the current
+// transaction. Any cached document of the key is removed, and the stored document will be cached
+// when the transaction is Committed.
+func (c *LoadCache) Stored(binding int, packedKey []byte, doc json.RawMessage) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var key = loadCacheKey{binding, string(packedKey)}
+	c.remove(key)
+	// The document may reference buffers that are re-used by the StoreIterator, so it must be
+	// copied.
+	c.pending = append(c.pending, loadCacheEntry{key: key, doc: append(json.RawMessage(nil), doc...)})
+}
+
+// Committed adds the documents stored by the transaction to the cache, once it has committed.
+func (c *LoadCache) Committed() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, pending := range c.pending {
+		var entry = &loadCacheEntry{key: pending.key, doc: pending.doc}
+		if entry.size() > c.maxBytes {
+			continue
+		}
+		// A key may be stored more than once in a transaction, and the last document wins.
+		c.remove(entry.key)
+		c.entries[entry.key] = c.lru.PushFront(entry)
+		c.size += entry.size()
+	}
+	c.pending = nil
+
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back().Value.(*loadCacheEntry).key)
+	}
+}
+
+func (c *LoadCache) remove(key loadCacheKey) {
+	if el, ok := c.entries[key]; ok {
+		c.size -= el.Value.(*loadCacheEntry).size()
+		c.lru.Remove(el)
+		delete(c.entries, key)
+	}
+}
@@ -0,0 +1,62 @@
+package sql
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCache(t *testing.T) {
+	var nilCache *LoadCache = NewLoadCache(0)
+	require.Nil(t, nilCache)
+	nilCache.Stored(0, []byte("key"), json.RawMessage(`{}`))
+	nilCache.Committed()
+	_, ok := nilCache.Get(0, []byte("key"))
+	require.False(t, ok)
+
+	// Room for two entries having 3 byte keys and 10 byte documents.
+	var c = NewLoadCache(2 * (3 + 10 + loadCacheEntryOverhead))
+	var doc = func(s string) json.RawMessage { return json.RawMessage(`"` + s + `____"`) }
+
+	// Stored documents are not available until committed.
+	c.Stored(0, []byte("one"), doc("aaaa"))
+	c.Stored(1, []byte("one"), doc("bbbb"))
+	_, ok = c.Get(0, []byte("one"))
+	require.False(t, ok)
+
+	c.Committed()
+	got, ok := c.Get(0, []byte("one"))
+	require.True(t, ok)
+	require.Equal(t, doc("aaaa"), got)
+	got, ok = c.Get(1, []byte("one"))
+	require.True(t, ok)
+	require.Equal(t, doc("bbbb"), got)
+
+	// Storing a key removes its cached document until the transaction commits.
+	c.Stored(0, []byte("one"), doc("cccc"))
+	_, ok = c.Get(0, []byte("one"))
+	require.False(t, ok)
+	c.Committed()
+	got, ok = c.Get(0, []byte("one"))
+	require.True(t, ok)
+	require.Equal(t, doc("cccc"), got)
+
+	// The least recently used entry is evicted when the cache is full.
+	c.Stored(2, []byte("two"), doc("dddd"))
+	c.Committed()
+	_, ok = c.Get(1, []byte("one"))
+	require.False(t, ok)
+	_, ok = c.Get(0, []byte("one"))
+	require.True(t, ok)
+	_, ok = c.Get(2, []byte("two"))
+	require.True(t, ok)
+
+	// Documents larger than the cache are never cached.
+	c.Stored(3, []byte("big"), json.RawMessage(make([]byte, 1000)))
+	c.Committed()
+	_, ok = c.Get(3, []byte("big"))
+	require.False(t, ok)
+	_, ok = c.Get(2, []byte("two"))
+	require.True(t, ok)
+}
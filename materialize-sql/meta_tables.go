@@ -9,6 +9,8 @@ const (
 	DefaultFlowCheckpoints = "flow_checkpoints_v1"
 	// DefaultFlowMaterializations is the default table for materialization specs.
 	DefaultFlowMaterializations = "flow_materializations_v2"
+	// DefaultFlowSchemaChanges is the default table for the schema change audit log.
+	DefaultFlowSchemaChanges = "flow_schema_changes"
 )
 
 // MetaTables returns *Table configurations for Flow materialization metadata tables,
@@ -148,3 +150,106 @@ func FlowMaterializationsTable(path ...string) TableShape {
 		Document: nil,
 	}
 }
+
+// FlowSchemaChangesTable returns the TableShape that (optionally) holds an audit log of the schema
+// changes applied to the endpoint by materializations. Rows are only ever appended to it, so it has
+// no primary key.
+func FlowSchemaChangesTable(path ...string) TableShape {
+	return TableShape{
+		Path:         path,
+		Binding:      -1,
+		Source:       "",
+		Comment:      "This table holds an audit log of schema changes applied by Flow materializations",
+		DeltaUpdates: true,
+		Keys: []Projection{
+			{
+				Projection: flow.Projection{
+					Field: "materialization",
+					Inference: flow.Inference{
+						Types:   []string{"string"},
+						Exists:  flow.Inference_MUST,
+						String_: &flow.Inference_String{},
+					},
+				},
+				RawFieldConfig: nil,
+				Comment:        "The name of the materialization.",
+			},
+			{
+				Projection: flow.Projection{
+					Field: "applied_at",
+					Inference: flow.Inference{
+						Types:  []string{"string"},
+						Exists: flow.Inference_MUST,
+						String_: &flow.Inference_String{
+							Format: "date-time",
+						},
+					},
+				},
+				RawFieldConfig: nil,
+				Comment:        "The time at which the schema change was applied.",
+			},
+		},
+		Values: []Projection{
+			{
+				Projection: flow.Projection{
+					Field: "binding",
+					Inference: flow.Inference{
+						Types:  []string{"integer"},
+						Exists: flow.Inference_MUST,
+					},
+				},
+				RawFieldConfig: nil,
+				Comment:        "The index of the binding within the materialization.",
+			},
+			{
+				Projection: flow.Projection{
+					Field: "resource_path",
+					Inference: flow.Inference{
+						Types:   []string{"string"},
+						Exists:  flow.Inference_MUST,
+						String_: &flow.Inference_String{},
+					},
+				},
+				RawFieldConfig: nil,
+				Comment:        "The resource path of the binding, encoded as a JSON array.",
+			},
+			{
+				Projection: flow.Projection{
+					Field: "statement",
+					Inference: flow.Inference{
+						Types:   []string{"string"},
+						Exists:  flow.Inference_MUST,
+						String_: &flow.Inference_String{},
+					},
+				},
+				RawFieldConfig: nil,
+				Comment:        "The statements which were executed to apply the schema change.",
+			},
+			{
+				Projection: flow.Projection{
+					Field: "prior_version",
+					Inference: flow.Inference{
+						Types:   []string{"string"},
+						Exists:  flow.Inference_MUST,
+						String_: &flow.Inference_String{},
+					},
+				},
+				RawFieldConfig: nil,
+				Comment:        "Version of the previously applied materialization, or empty if there was none.",
+			},
+			{
+				Projection: flow.Projection{
+					Field: "new_version",
+					Inference: flow.Inference{
+						Types:   []string{"string"},
+						Exists:  flow.Inference_MUST,
+						String_: &flow.Inference_String{},
+					},
+				},
+				RawFieldConfig: nil,
+				Comment:        "Version of the materialization which applied the schema change.",
+			},
+		},
+		Document: nil,
+	}
+}
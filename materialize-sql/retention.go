@@ -0,0 +1,126 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxRetentionStatements bounds the number of statements which delete expired rows from a table in
+// one pass of a RetentionEnforcer, so that a large backlog of expired rows doesn't hold up the
+// acknowledgement of a transaction. Rows which remain are deleted by following passes.
+const maxRetentionStatements = 10
+
+// RetentionEnforcer removes expired rows from the tables of bindings having a Retention. It is
+// intended to be periodically invoked by a Transactor after its transactions commit, and removes
+// rows from each table at most once per the interval of its Retention.
+type RetentionEnforcer struct {
+	bindings []*retentionBinding
+	// batchRows is the maximum number of rows deleted by one statement, or zero if a statement
+	// deletes all expired rows of a table.
+	batchRows int64
+	// now is stubbed out by tests.
+	now func() time.Time
+}
+
+type retentionBinding struct {
+	table      Table
+	deleteSQL  string
+	interval   time.Duration
+	lastRun    time.Time
+	rowsPurged int64
+}
+
+// DeleteExpiredParams are evaluated by a template into a statement which deletes the expired rows
+// of the Table.
+type DeleteExpiredParams struct {
+	Table
+	// BatchRows is the LIMIT of rows deleted by the statement, or zero if it deletes all expired
+	// rows of the Table.
+	BatchRows int64
+}
+
+// NewRetentionEnforcer returns a RetentionEnforcer for the tables having a RetentionColumn, using
+// the template to render the statements which delete expired rows from DeleteExpiredParams. The
+// batchRows is rendered as the LIMIT of rows deleted by a statement, or is zero if the endpoint
+// deletes all expired rows with a single statement. The returned RetentionEnforcer is nil if no
+// tables have a retention.
+func NewRetentionEnforcer(tables []Table, tpl *template.Template, batchRows int64) (*RetentionEnforcer, error) {
+	var e = &RetentionEnforcer{batchRows: batchRows, now: time.Now}
+
+	for _, table := range tables {
+		if table.RetentionColumn == nil {
+			continue
+		} else if tpl == nil {
+			return nil, fmt.Errorf("table %s has a retention, but the endpoint does not support it", table.Identifier)
+		}
+
+		interval, err := table.Options.Retention.interval()
+		if err != nil {
+			return nil, err
+		}
+		var w strings.Builder
+		if err := tpl.Execute(&w, &DeleteExpiredParams{Table: table, BatchRows: batchRows}); err != nil {
+			return nil, fmt.Errorf("rendering delete expired statement for %s: %w", table.Identifier, err)
+		}
+
+		e.bindings = append(e.bindings, &retentionBinding{
+			table:     table,
+			deleteSQL: w.String(),
+			interval:  interval,
+		})
+	}
+
+	if len(e.bindings) == 0 {
+		return nil, nil
+	}
+	return e, nil
+}
+
+// Enforce removes expired rows from each table which is due for it. The exec function executes a
+// statement which deletes expired rows, and returns the number of rows deleted. Statements are
+// executed until one deletes less than a full batch of rows, or up to maxRetentionStatements times.
+// A table which may still have expired rows remains due, and its removal continues with the next
+// call. Enforce may be called on a nil RetentionEnforcer, in which case it does nothing.
+func (e *RetentionEnforcer) Enforce(ctx context.Context, exec func(ctx context.Context, statement string) (int64, error)) error {
+	if e == nil {
+		return nil
+	}
+
+	for _, b := range e.bindings {
+		var started = e.now()
+		if !b.lastRun.IsZero() && started.Sub(b.lastRun) < b.interval {
+			continue
+		}
+
+		var purged int64
+		var done bool
+		for i := 0; i != maxRetentionStatements && !done; i++ {
+			rows, err := exec(ctx, b.deleteSQL)
+			if err != nil {
+				return fmt.Errorf("deleting expired rows from %s: %w", b.table.Identifier, err)
+			}
+			purged += rows
+			done = e.batchRows == 0 || rows < e.batchRows
+		}
+
+		if done {
+			b.lastRun = started
+		}
+		b.rowsPurged += purged
+
+		log.WithFields(log.Fields{
+			"table":           b.table.Identifier,
+			"rowsPurged":      purged,
+			"totalRowsPurged": b.rowsPurged,
+			"complete":        done,
+			"elapsed":         e.now().Sub(started).String(),
+		}).Info("deleted expired rows")
+	}
+
+	return nil
+}
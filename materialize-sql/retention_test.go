@@ -0,0 +1,95 @@
+package sql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionEnforcer(t *testing.T) {
+	var dialect = newTestDialect()
+	var tpl = MustParseTemplate(dialect, "deleteExpired",
+		`DELETE FROM {{$.Identifier}} WHERE {{$.RetentionColumn.Identifier}} < NOW() - {{$.Options.Retention.Days}} LIMIT {{$.BatchRows}};`)
+
+	var shape = FlowSchemaChangesTable("one", "two", "changes")
+	withoutRetention, err := ResolveTable(shape, dialect)
+	require.NoError(t, err)
+
+	shape.Options.Retention = &Retention{Field: "applied_at", Days: 30, Interval: "10m"}
+	withRetention, err := ResolveTable(shape, dialect)
+	require.NoError(t, err)
+	require.Equal(t, "applied_at", withRetention.RetentionColumn.Identifier)
+
+	e, err := NewRetentionEnforcer([]Table{withoutRetention}, tpl, 100)
+	require.NoError(t, err)
+	require.Nil(t, e)
+	require.NoError(t, e.Enforce(context.Background(), nil))
+
+	_, err = NewRetentionEnforcer([]Table{withRetention}, nil, 100)
+	require.ErrorContains(t, err, "endpoint does not support it")
+
+	e, err = NewRetentionEnforcer([]Table{withoutRetention, withRetention}, tpl, 100)
+	require.NoError(t, err)
+
+	var now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+
+	// Batches are deleted until one is less than a full batch.
+	var statements []string
+	var batches = []int64{100, 20}
+	var exec = func(_ context.Context, statement string) (int64, error) {
+		statements = append(statements, statement)
+		var rows = batches[0]
+		batches = batches[1:]
+		return rows, nil
+	}
+
+	require.NoError(t, e.Enforce(context.Background(), exec))
+	require.Equal(t, []string{
+		"DELETE FROM one.two.changes WHERE applied_at < NOW() - 30 LIMIT 100;",
+		"DELETE FROM one.two.changes WHERE applied_at < NOW() - 30 LIMIT 100;",
+	}, statements)
+	require.Equal(t, int64(120), e.bindings[0].rowsPurged)
+	require.Equal(t, now, e.bindings[0].lastRun)
+
+	// Not yet due.
+	statements = nil
+	now = now.Add(5 * time.Minute)
+	require.NoError(t, e.Enforce(context.Background(), exec))
+	require.Empty(t, statements)
+
+	// Due again, with more expired rows than one pass removes.
+	for i := 0; i != maxRetentionStatements; i++ {
+		batches = append(batches, 100)
+	}
+	now = now.Add(5 * time.Minute)
+	require.NoError(t, e.Enforce(context.Background(), exec))
+	require.Len(t, statements, maxRetentionStatements)
+	require.Equal(t, int64(120+100*maxRetentionStatements), e.bindings[0].rowsPurged)
+	require.Equal(t, now.Add(-10*time.Minute), e.bindings[0].lastRun)
+
+	// Still due, and the removal continues.
+	statements = nil
+	batches = []int64{0}
+	now = now.Add(time.Minute)
+	require.NoError(t, e.Enforce(context.Background(), exec))
+	require.Len(t, statements, 1)
+	require.Equal(t, now, e.bindings[0].lastRun)
+
+	// Endpoints without batches execute a single statement.
+	e, err = NewRetentionEnforcer([]Table{withRetention}, tpl, 0)
+	require.NoError(t, err)
+	statements, batches = nil, []int64{500}
+	require.NoError(t, e.Enforce(context.Background(), exec))
+	require.Len(t, statements, 1)
+}
+
+func TestRetentionValidate(t *testing.T) {
+	require.NoError(t, Retention{Field: "ts", Days: 1}.Validate())
+	require.NoError(t, Retention{Field: "ts", Days: 1, Interval: "30m"}.Validate())
+	require.ErrorContains(t, Retention{Days: 1}.Validate(), "missing retention field")
+	require.ErrorContains(t, Retention{Field: "ts"}.Validate(), "days must be positive")
+	require.ErrorContains(t, Retention{Field: "ts", Days: 1, Interval: "soon"}.Validate(), "parsing retention interval")
+}
@@ -0,0 +1,103 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
+	pf "github.com/estuary/flow/go/protocols/flow"
+)
+
+// schemaChange is a schema change which has been applied to a binding's resource.
+type schemaChange struct {
+	binding      int
+	resourcePath []string
+	statement    string
+	appliedAt    time.Time
+}
+
+// recordSchemaChange wraps the action so that a schemaChange is recorded for it as soon as it has
+// been successfully applied, so that it's recorded even if a later action fails. The action is
+// returned as-is if the endpoint doesn't record schema changes.
+func (a *sqlApplier) recordSchemaChange(spec *pf.MaterializationSpec, bindingIndex int, desc string, action boilerplate.ActionApplyFn) boilerplate.ActionApplyFn {
+	if action == nil || a.endpoint.MetaSchemaChanges == nil {
+		return action
+	}
+
+	return func(ctx context.Context) error {
+		if err := action(ctx); err != nil {
+			return err
+		}
+
+		update, err := a.schemaChangeUpdate(spec.Name, schemaChange{
+			binding:      bindingIndex,
+			resourcePath: spec.Bindings[bindingIndex].ResourcePath,
+			statement:    desc,
+			appliedAt:    time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+
+		// Actions may be applied concurrently with the creation of the meta tables.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.metaTablesCreated:
+		}
+
+		if err := a.client.PutSpec(ctx, update); err != nil {
+			return fmt.Errorf("recording schema change: %w", err)
+		}
+		return nil
+	}
+}
+
+// schemaChangeUpdate builds the query which inserts the schema change into the MetaSchemaChanges
+// table, attributing it to the version of the materialization which is being applied.
+func (a *sqlApplier) schemaChangeUpdate(materialization pf.Materialization, c schemaChange) (MetaSpecsUpdate, error) {
+	var columns []string
+	for _, f := range []string{"materialization", "applied_at", "binding", "resource_path", "statement", "prior_version", "new_version"} {
+		columns = append(columns, a.endpoint.Identifier(f))
+	}
+	var q = "INSERT INTO %[1]s (" + strings.Join(columns, ", ") + ") VALUES (%[2]s, %[3]s, %[4]s, %[5]s, %[6]s, %[7]s, %[8]s);"
+	var table = a.endpoint.Identifier(a.endpoint.MetaSchemaChanges.Path...)
+
+	path, err := json.Marshal(c.resourcePath)
+	if err != nil {
+		return MetaSpecsUpdate{}, fmt.Errorf("encoding resource path: %w", err)
+	}
+
+	var params = []interface{}{
+		materialization.String(),
+		c.appliedAt,
+		c.binding,
+		string(path),
+		c.statement,
+		a.storedVersion,
+		a.version,
+	}
+	var paramArgs = []interface{}{table}
+	var queryStringArgs = []interface{}{
+		table,
+		a.endpoint.Literal(materialization.String()),
+		a.endpoint.Literal(c.appliedAt.Format("2006-01-02 15:04:05.000000")),
+		fmt.Sprintf("%d", c.binding),
+		a.endpoint.Literal(string(path)),
+		a.endpoint.Literal(c.statement),
+		a.endpoint.Literal(a.storedVersion),
+		a.endpoint.Literal(a.version),
+	}
+	for idx := range params {
+		paramArgs = append(paramArgs, a.endpoint.Placeholder(idx))
+	}
+
+	return MetaSpecsUpdate{
+		ParameterizedQuery: fmt.Sprintf(q, paramArgs...),
+		Parameters:         params,
+		QueryString:        fmt.Sprintf(q, queryStringArgs...),
+	}, nil
+}
@@ -0,0 +1,81 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/stretchr/testify/require"
+)
+
+// putSpecClient is a Client which records the updates of PutSpec.
+type putSpecClient struct {
+	Client
+	mu      sync.Mutex
+	updates []MetaSpecsUpdate
+}
+
+func (c *putSpecClient) PutSpec(ctx context.Context, update MetaSpecsUpdate) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updates = append(c.updates, update)
+	return nil
+}
+
+func TestRecordSchemaChange(t *testing.T) {
+	var schemaChanges = FlowSchemaChangesTable("one", "two", DefaultFlowSchemaChanges)
+	var spec = &pf.MaterializationSpec{
+		Name: "some/materialization",
+		Bindings: []*pf.MaterializationSpec_Binding{
+			{ResourcePath: []string{"schema", "first"}},
+			{ResourcePath: []string{"schema", "second"}},
+		},
+	}
+
+	var client = &putSpecClient{}
+	var applier = newSqlApplier(client, nil, &Endpoint{Dialect: newTestDialect(), MetaSchemaChanges: &schemaChanges}, "v2")
+	applier.storedVersion = "v1"
+
+	var ok = func(context.Context) error { return nil }
+	var failed = func(context.Context) error { return errors.New("failed") }
+
+	require.Nil(t, applier.recordSchemaChange(spec, 0, "", nil))
+
+	// Changes aren't recorded until the meta tables exist.
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, applier.recordSchemaChange(spec, 0, "CREATE TABLE first;", ok)(ctx), context.Canceled)
+	require.Empty(t, client.updates)
+	close(applier.metaTablesCreated)
+
+	// Each change is recorded as soon as it's applied, even if a later action fails.
+	require.NoError(t, applier.recordSchemaChange(spec, 0, "CREATE TABLE first;", ok)(context.Background()))
+	require.Len(t, client.updates, 1)
+	require.Error(t, applier.recordSchemaChange(spec, 1, "ALTER TABLE second;", failed)(context.Background()))
+	require.Len(t, client.updates, 1)
+	require.NoError(t, applier.recordSchemaChange(spec, 1, "ALTER TABLE 'second';", ok)(context.Background()))
+	require.Len(t, client.updates, 2)
+
+	require.Equal(t,
+		"INSERT INTO one.two.flow_schema_changes (materialization, applied_at, binding, resource_path, statement, prior_version, new_version) VALUES ($1, $2, $3, $4, $5, $6, $7);",
+		client.updates[0].ParameterizedQuery,
+	)
+	require.Equal(t, []interface{}{
+		"some/materialization",
+		client.updates[1].Parameters[1],
+		1,
+		`["schema","second"]`,
+		"ALTER TABLE 'second';",
+		"v1",
+		"v2",
+	}, client.updates[1].Parameters)
+	require.Contains(t, client.updates[1].QueryString, `1, '["schema","second"]', 'ALTER TABLE ''second'';', 'v1', 'v2');`)
+
+	// Nothing is recorded for endpoints without a schema changes table.
+	client = &putSpecClient{}
+	applier = newSqlApplier(client, nil, &Endpoint{Dialect: newTestDialect()}, "v2")
+	require.NoError(t, applier.recordSchemaChange(spec, 0, "CREATE TABLE first;", ok)(context.Background()))
+	require.Empty(t, client.updates)
+}
@@ -23,6 +23,8 @@ type TableShape struct {
 	Comment string
 	// The table is operating in delta-updates mode (instead of a standard materialization).
 	DeltaUpdates bool
+	// Partitioning and clustering options of the table.
+	Options TableOptions
 
 	Keys, Values []Projection
 	Document     *Projection
@@ -42,6 +44,14 @@ type Table struct {
 	Keys, Values []Column
 	Document     *Column
 
+	// Columns which partition and cluster the table, as resolved from its Options.
+	PartitionColumns, ClusterColumns []*Column
+	// Secondary indexes of the table, as resolved from its Options.
+	Indexes []Index
+	// Column whose age determines when rows are removed from the table, as resolved from the
+	// Retention of its Options.
+	RetentionColumn *Column
+
 	// The stateKey associated with this table's binding
 	StateKey string
 }
@@ -169,6 +179,10 @@ func ResolveTable(shape TableShape, dialect Dialect) (Table, error) {
 		*col = resolved
 	}
 
+	if err := resolveTableOptions(&table, dialect); err != nil {
+		return Table{}, err
+	}
+
 	return table, nil
 }
 
@@ -204,6 +218,7 @@ func BuildTableShape(spec *pf.MaterializationSpec, index int, resource Resource)
 		Source:       binding.Collection.Name,
 		Comment:      comment,
 		DeltaUpdates: resource.DeltaUpdates(),
+		Options:      tableOptionsFor(resource),
 		Keys:         keys,
 		Values:       values,
 		Document:     document,
@@ -0,0 +1,293 @@
+package sql
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	pf "github.com/estuary/flow/go/protocols/flow"
+	pm "github.com/estuary/flow/go/protocols/materialize"
+)
+
+// TableOptioner is an optional interface which may be implemented by a Resource that allows for
+// partitioning, clustering, or secondary indexes of its table. Each dialect renders the resulting
+// TableOptions in its own templates, using whatever constructs are native to the endpoint.
+type TableOptioner interface {
+	TableOptions() TableOptions
+}
+
+// PartitionValidator is an optional interface which may be implemented by a Resource that has
+// partitioning options. It verifies that the options are valid for the mapped type of a partition
+// column, since the partition expressions of some endpoints depend on the type of the column.
+type PartitionValidator interface {
+	ValidatePartitionColumn(field string, mapped MappedType) error
+}
+
+// TableOptions declares how the table for a resource is partitioned, clustered, and indexed. The
+// fields referenced must be selected fields of the binding.
+type TableOptions struct {
+	// PartitionBy is the list of fields used to partition the table, for example as a BigQuery
+	// PARTITION BY, a Databricks PARTITIONED BY, or a Redshift DISTKEY.
+	PartitionBy []string
+	// PartitionGranularity is an optional time unit, such as "DAY" or "MONTH", to which date and
+	// timestamp partition columns are truncated by dialects that support partition expressions.
+	PartitionGranularity string
+	// ClusterBy is the list of fields used to cluster or sort the table, for example as a
+	// BigQuery or Snowflake CLUSTER BY, a Databricks ZORDER, or a Redshift SORTKEY.
+	ClusterBy []string
+	// Indexes are secondary indexes of the table. They are created along with the table, and
+	// indexes added to the resource later are created on the existing table during Apply.
+	Indexes []TableIndex
+	// Retention optionally removes rows from the table once they are older than a number of days.
+	Retention *Retention
+}
+
+// TableIndex is a secondary index of a table, as it appears in a resource configuration.
+type TableIndex struct {
+	Fields []string `json:"fields" jsonschema:"title=Fields,description=Fields of the index in order."`
+	Unique bool     `json:"unique,omitempty" jsonschema:"title=Unique,description=Whether the index enforces uniqueness of its fields."`
+}
+
+// Retention is a policy for removing rows from a table, as it appears in a resource configuration.
+type Retention struct {
+	Field    string `json:"field" jsonschema:"title=Field,description=Date or timestamp field which determines the age of a row."`
+	Days     int    `json:"days" jsonschema:"title=Days,description=Rows older than this number of days are removed from the table."`
+	Interval string `json:"interval,omitempty" jsonschema:"title=Interval,description=How often expired rows are removed. Defaults to 1h."`
+}
+
+// Validate returns an error if the Retention is malformed.
+func (r Retention) Validate() error {
+	if r.Field == "" {
+		return fmt.Errorf("missing retention field")
+	} else if r.Days <= 0 {
+		return fmt.Errorf("retention days must be positive")
+	} else if _, err := r.interval(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// defaultRetentionInterval is how often expired rows are removed if the Retention does not specify
+// an interval.
+const defaultRetentionInterval = time.Hour
+
+func (r Retention) interval() (time.Duration, error) {
+	if r.Interval == "" {
+		return defaultRetentionInterval, nil
+	}
+	d, err := time.ParseDuration(r.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("parsing retention interval: %w", err)
+	} else if d <= 0 {
+		return 0, fmt.Errorf("retention interval must be positive")
+	}
+	return d, nil
+}
+
+// Index is a secondary index of a Table which is fully resolved using the database Dialect.
+type Index struct {
+	TableIndex
+
+	// Name of the index, which is derived from the name of its table and its fields.
+	Name string
+	// Quoted identifier of the index, suited for direct inclusion in SQL.
+	Identifier string
+	// Quoted identifier of the indexed table.
+	TableIdentifier string
+	// Columns of the index, in order.
+	Columns []*Column
+	// Concurrently is set if the index is being added to an existing table, and should be built
+	// without blocking writes to the table if the endpoint supports it.
+	Concurrently bool
+}
+
+// maxIndexNameLength is the maximum length of a generated index name. This is the limit imposed by
+// Postgres, and MySQL's limit of 64 is slightly larger.
+const maxIndexNameLength = 63
+
+var indexNameSanitizer = regexp.MustCompile(`[^a-z0-9_]`)
+
+// indexName generates a deterministic name for an index of the table at the given path. The name is
+// used to determine if an index has already been created, so it must not change for a given index.
+func indexName(path TablePath, idx TableIndex) string {
+	var suffix = "_idx"
+	if idx.Unique {
+		suffix = "_key"
+	}
+
+	var name = strings.Join(append([]string{path[len(path)-1]}, idx.Fields...), "_")
+	name = indexNameSanitizer.ReplaceAllString(strings.ToLower(name), "_") + suffix
+
+	if len(name) > maxIndexNameLength {
+		var h = fnv.New32a()
+		h.Write([]byte(name))
+		name = fmt.Sprintf("%s_%08x", name[:maxIndexNameLength-9], h.Sum32())
+	}
+
+	return name
+}
+
+// RenderIndexTemplate renders a template with an Index as its context.
+func RenderIndexTemplate(index Index, tpl *template.Template) (string, error) {
+	var w strings.Builder
+	if err := tpl.Execute(&w, &index); err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// tableOptionsFor returns the TableOptions of the resource, which will be empty if the resource
+// does not implement TableOptioner.
+func tableOptionsFor(resource Resource) TableOptions {
+	if o, ok := resource.(TableOptioner); ok {
+		return o.TableOptions()
+	}
+	return TableOptions{}
+}
+
+// resolveTableOptions populates the partition and cluster columns and the indexes of the table from
+// the options of its shape. It is an error for the options to reference a field that is not a
+// column of the table.
+func resolveTableOptions(table *Table, dialect Dialect) error {
+	var cols = table.Columns()
+
+	var lookup = func(field string) (*Column, error) {
+		for _, c := range cols {
+			if c.Field == field {
+				return c, nil
+			}
+		}
+		return nil, fmt.Errorf("field %q is not a selected field of table %s", field, table.Identifier)
+	}
+
+	for _, f := range table.Options.PartitionBy {
+		c, err := lookup(f)
+		if err != nil {
+			return fmt.Errorf("resolving partition columns: %w", err)
+		}
+		table.PartitionColumns = append(table.PartitionColumns, c)
+	}
+	for _, f := range table.Options.ClusterBy {
+		c, err := lookup(f)
+		if err != nil {
+			return fmt.Errorf("resolving cluster columns: %w", err)
+		}
+		table.ClusterColumns = append(table.ClusterColumns, c)
+	}
+	for _, ti := range table.Options.Indexes {
+		var idx = Index{
+			TableIndex:      ti,
+			Name:            indexName(table.Path, ti),
+			TableIdentifier: table.Identifier,
+		}
+		idx.Identifier = dialect.Identifier(idx.Name)
+
+		for _, f := range ti.Fields {
+			c, err := lookup(f)
+			if err != nil {
+				return fmt.Errorf("resolving index %s: %w", idx.Name, err)
+			}
+			idx.Columns = append(idx.Columns, c)
+		}
+		table.Indexes = append(table.Indexes, idx)
+	}
+	if r := table.Options.Retention; r != nil {
+		c, err := lookup(r.Field)
+		if err != nil {
+			return fmt.Errorf("resolving retention column: %w", err)
+		}
+		table.RetentionColumn = c
+	}
+
+	return nil
+}
+
+// ValidateTableOptions verifies that the fields referenced by the resource's TableOptions are
+// projections of the bound collection, and that they are not forbidden from being materialized.
+// Referenced fields are marked as required in the constraints, so that the table will always have
+// columns for them.
+func ValidateTableOptions(
+	endpoint *Endpoint,
+	resource Resource,
+	collection pf.CollectionSpec,
+	fieldConfigJsonMap map[string]json.RawMessage,
+	constraints map[string]*pm.Response_Validated_Constraint,
+) error {
+	var opts = tableOptionsFor(resource)
+
+	type referencedFields struct {
+		kind   string
+		fields []string
+	}
+	var referenced = []referencedFields{
+		{"partition", opts.PartitionBy},
+		{"cluster", opts.ClusterBy},
+	}
+
+	if len(opts.Indexes) > 0 && endpoint.CreateIndexTemplate == nil {
+		return fmt.Errorf("indexes are not supported by this endpoint")
+	}
+	for _, idx := range opts.Indexes {
+		if len(idx.Fields) == 0 {
+			return fmt.Errorf("index must have at least one field")
+		}
+		referenced = append(referenced, referencedFields{"index", idx.Fields})
+	}
+
+	if r := opts.Retention; r != nil {
+		if endpoint.DeleteExpiredTemplate == nil {
+			return fmt.Errorf("retention is not supported by this endpoint")
+		} else if err := r.Validate(); err != nil {
+			return err
+		}
+		if p := collection.GetProjection(r.Field); p != nil {
+			if s := p.Inference.String_; s == nil || (s.Format != "date-time" && s.Format != "date") {
+				return fmt.Errorf("retention field %q must be a date or date-time formatted string", r.Field)
+			}
+		}
+		referenced = append(referenced, referencedFields{"retention", []string{r.Field}})
+	}
+
+	for _, fields := range referenced {
+		for _, f := range fields.fields {
+			if collection.GetProjection(f) == nil {
+				return fmt.Errorf("%s field %q does not exist in collection %q", fields.kind, f, collection.Name.String())
+			}
+
+			c, ok := constraints[f]
+			if !ok {
+				continue
+			}
+
+			switch c.Type {
+			case pm.Response_Validated_Constraint_FIELD_FORBIDDEN, pm.Response_Validated_Constraint_UNSATISFIABLE:
+				return fmt.Errorf("%s field %q cannot be materialized: %s", fields.kind, f, c.Reason)
+			case pm.Response_Validated_Constraint_FIELD_REQUIRED, pm.Response_Validated_Constraint_LOCATION_REQUIRED:
+				// Already required.
+			default:
+				constraints[f] = &pm.Response_Validated_Constraint{
+					Type:   pm.Response_Validated_Constraint_FIELD_REQUIRED,
+					Reason: fmt.Sprintf("This field is a %s field of the table", fields.kind),
+				}
+			}
+		}
+	}
+
+	if v, ok := resource.(PartitionValidator); ok {
+		for _, f := range opts.PartitionBy {
+			var p = Projection{Projection: *collection.GetProjection(f), RawFieldConfig: fieldConfigJsonMap[f]}
+
+			if mapped, err := endpoint.Dialect.MapType(&p); err != nil {
+				return fmt.Errorf("mapping partition field %q: %w", f, err)
+			} else if err := v.ValidatePartitionColumn(f, mapped); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package sql
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	pf "github.com/estuary/flow/go/protocols/flow"
+	pm "github.com/estuary/flow/go/protocols/materialize"
+	"github.com/stretchr/testify/require"
+)
+
+type testResource struct {
+	opts TableOptions
+}
+
+func (r testResource) Validate() error            { return nil }
+func (r testResource) Path() TablePath            { return TablePath{"one", "two", "three"} }
+func (r testResource) DeltaUpdates() bool         { return false }
+func (r testResource) TableOptions() TableOptions { return r.opts }
+
+// partitionedResource only allows partitioning by TIMESTAMPTZ columns.
+type partitionedResource struct {
+	testResource
+}
+
+func (r partitionedResource) ValidatePartitionColumn(field string, mapped MappedType) error {
+	if mapped.NullableDDL != "TIMESTAMPTZ" {
+		return fmt.Errorf("partition field %q is %s", field, mapped.NullableDDL)
+	}
+	return nil
+}
+
+func TestResolveTableOptions(t *testing.T) {
+	var dialect = newTestDialect()
+
+	var shape = FlowCheckpointsTable("one", "two", "checkpoints")
+	shape.Options = TableOptions{
+		PartitionBy: []string{"materialization"},
+		ClusterBy:   []string{"key_begin", "fence"},
+	}
+
+	table, err := ResolveTable(shape, dialect)
+	require.NoError(t, err)
+	require.Len(t, table.PartitionColumns, 1)
+	require.Equal(t, "materialization", table.PartitionColumns[0].Identifier)
+	require.Len(t, table.ClusterColumns, 2)
+	require.Equal(t, "key_begin", table.ClusterColumns[0].Identifier)
+	require.Equal(t, "fence", table.ClusterColumns[1].Identifier)
+
+	shape.Options.ClusterBy = []string{"missing"}
+	_, err = ResolveTable(shape, dialect)
+	require.ErrorContains(t, err, `field "missing" is not a selected field`)
+
+	shape.Options = TableOptions{
+		Indexes: []TableIndex{{Fields: []string{"materialization", "key_begin"}, Unique: true}},
+	}
+	table, err = ResolveTable(shape, dialect)
+	require.NoError(t, err)
+	require.Len(t, table.Indexes, 1)
+	require.Equal(t, "checkpoints_materialization_key_begin_key", table.Indexes[0].Name)
+	require.Equal(t, table.Identifier, table.Indexes[0].TableIdentifier)
+	require.Len(t, table.Indexes[0].Columns, 2)
+}
+
+func TestIndexName(t *testing.T) {
+	require.Equal(t, "table_a_b_idx", indexName(TablePath{"schema", "Table"}, TableIndex{Fields: []string{"a", "b"}}))
+	require.Equal(t, "table_some_field_key", indexName(TablePath{"Table"}, TableIndex{Fields: []string{"Some-Field"}, Unique: true}))
+
+	var long = indexName(TablePath{"a_table_with_a_rather_long_name"}, TableIndex{
+		Fields: []string{"first_long_field_name", "second_long_field_name"},
+	})
+	require.Len(t, long, maxIndexNameLength)
+	require.Equal(t, long, indexName(TablePath{"a_table_with_a_rather_long_name"}, TableIndex{
+		Fields: []string{"first_long_field_name", "second_long_field_name"},
+	}))
+}
+
+func TestValidateTableOptions(t *testing.T) {
+	var collection = pf.CollectionSpec{
+		Name: "a/collection",
+		Projections: []pf.Projection{
+			{Field: "forbidden", Ptr: "/forbidden"},
+			{Field: "key", Ptr: "/key", IsPrimaryKey: true},
+			{Field: "optional", Ptr: "/optional"},
+			{Field: "ts", Ptr: "/ts", Inference: pf.Inference{
+				Types:   []string{"string"},
+				String_: &pf.Inference_String{Format: "date-time"},
+			}},
+		},
+	}
+
+	var newConstraints = func() map[string]*pm.Response_Validated_Constraint {
+		return map[string]*pm.Response_Validated_Constraint{
+			"key":       {Type: pm.Response_Validated_Constraint_LOCATION_REQUIRED},
+			"ts":        {Type: pm.Response_Validated_Constraint_LOCATION_RECOMMENDED},
+			"forbidden": {Type: pm.Response_Validated_Constraint_FIELD_FORBIDDEN, Reason: "not allowed"},
+			"optional":  {Type: pm.Response_Validated_Constraint_FIELD_OPTIONAL},
+		}
+	}
+
+	t.Run("fields become required", func(t *testing.T) {
+		var constraints = newConstraints()
+		require.NoError(t, ValidateTableOptions(&Endpoint{}, testResource{opts: TableOptions{
+			PartitionBy: []string{"ts"},
+			ClusterBy:   []string{"key", "optional"},
+		}}, collection, nil, constraints))
+
+		require.Equal(t, pm.Response_Validated_Constraint_FIELD_REQUIRED, constraints["ts"].Type)
+		require.Equal(t, pm.Response_Validated_Constraint_FIELD_REQUIRED, constraints["optional"].Type)
+		require.Equal(t, pm.Response_Validated_Constraint_LOCATION_REQUIRED, constraints["key"].Type)
+	})
+
+	t.Run("missing field", func(t *testing.T) {
+		require.ErrorContains(t, ValidateTableOptions(&Endpoint{}, testResource{opts: TableOptions{
+			ClusterBy: []string{"nope"},
+		}}, collection, nil, newConstraints()), `cluster field "nope" does not exist`)
+	})
+
+	t.Run("index fields", func(t *testing.T) {
+		var constraints = newConstraints()
+		var res = testResource{opts: TableOptions{
+			Indexes: []TableIndex{{Fields: []string{"optional"}}},
+		}}
+		require.ErrorContains(t, ValidateTableOptions(&Endpoint{}, res, collection, nil, constraints), "indexes are not supported")
+
+		var endpoint = &Endpoint{CreateIndexTemplate: MustParseTemplate(newTestDialect(), "idx", "")}
+		require.NoError(t, ValidateTableOptions(endpoint, res, collection, nil, constraints))
+		require.Equal(t, pm.Response_Validated_Constraint_FIELD_REQUIRED, constraints["optional"].Type)
+	})
+
+	t.Run("retention field", func(t *testing.T) {
+		var constraints = newConstraints()
+		var res = testResource{opts: TableOptions{
+			Retention: &Retention{Field: "ts", Days: 30},
+		}}
+		require.ErrorContains(t, ValidateTableOptions(&Endpoint{}, res, collection, nil, constraints), "retention is not supported")
+
+		var endpoint = &Endpoint{DeleteExpiredTemplate: MustParseTemplate(newTestDialect(), "deleteExpired", "")}
+		require.NoError(t, ValidateTableOptions(endpoint, res, collection, nil, constraints))
+		require.Equal(t, pm.Response_Validated_Constraint_FIELD_REQUIRED, constraints["ts"].Type)
+
+		res.opts.Retention.Field = "optional"
+		require.ErrorContains(t, ValidateTableOptions(endpoint, res, collection, nil, newConstraints()), `retention field "optional" must be a date`)
+	})
+
+	t.Run("partition column type", func(t *testing.T) {
+		var endpoint = &Endpoint{Dialect: newTestDialect()}
+		require.NoError(t, ValidateTableOptions(endpoint, partitionedResource{testResource{opts: TableOptions{
+			PartitionBy: []string{"ts"},
+		}}}, collection, nil, newConstraints()))
+
+		// Field configuration is applied to the mapped type of the column.
+		require.ErrorContains(t, ValidateTableOptions(endpoint, partitionedResource{testResource{opts: TableOptions{
+			PartitionBy: []string{"ts"},
+		}}}, collection, map[string]json.RawMessage{"ts": json.RawMessage(`{"ignoreStringFormat":true}`)}, newConstraints()), `partition field "ts" is`)
+	})
+
+	t.Run("forbidden field", func(t *testing.T) {
+		require.ErrorContains(t, ValidateTableOptions(&Endpoint{}, testResource{opts: TableOptions{
+			PartitionBy: []string{"forbidden"},
+		}}, collection, nil, newConstraints()), `partition field "forbidden" cannot be materialized: not allowed`)
+	})
+}
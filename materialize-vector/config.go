@@ -0,0 +1,262 @@
+package vector
+
+import (
+	"fmt"
+)
+
+const (
+	textEmbeddingAda002          = "text-embedding-ada-002"
+	cohereEmbedEnglishV3         = "embed-english-v3.0"
+	azureOpenAiDefaultApiVersion = "2024-02-01"
+
+	ProviderOpenAi      = "openai"
+	ProviderAzureOpenAi = "azure_openai"
+	ProviderCohere      = "cohere"
+	ProviderLocal       = "local"
+
+	defaultMaxInputLength = 8000
+	defaultMaxChunks      = 16
+)
+
+// EmbeddingConfig configures the service which generates embeddings.
+type EmbeddingConfig struct {
+	EmbeddingProvider string               `json:"embeddingProvider,omitempty" jsonschema:"title=Embedding Provider,default=openai,enum=openai,enum=azure_openai,enum=cohere,enum=local" jsonschema_extras:"order=3"`
+	OpenAiApiKey      string               `json:"openAiApiKey,omitempty" jsonschema:"title=OpenAI API Key" jsonschema_extras:"secret=true,order=4"`
+	EmbeddingModel    string               `json:"embeddingModel,omitempty" jsonschema:"title=Embedding Model ID" jsonschema_extras:"order=5"`
+	AzureOpenAi       *AzureOpenAiConfig   `json:"azureOpenAi,omitempty" jsonschema:"title=Azure OpenAI" jsonschema_extras:"order=6"`
+	Cohere            *CohereConfig        `json:"cohere,omitempty" jsonschema:"title=Cohere" jsonschema_extras:"order=7"`
+	Local             *LocalEmbedderConfig `json:"local,omitempty" jsonschema:"title=Local Embedding Server" jsonschema_extras:"order=8"`
+}
+
+func (EmbeddingConfig) GetFieldDocString(fieldName string) string {
+	switch fieldName {
+	case "EmbeddingProvider":
+		return "Service used to generate embeddings. Defaults to OpenAI."
+	case "OpenAiApiKey":
+		return "OpenAI API key used for authentication. Required if the embedding provider is OpenAI."
+	case "EmbeddingModel":
+		return "Embedding model ID for generating embeddings. Defaults to text-embedding-ada-002 for OpenAI and embed-english-v3.0 for Cohere. Azure OpenAI uses the model of its deployment instead."
+	case "AzureOpenAi":
+		return "Azure OpenAI deployment used to generate embeddings. Required if the embedding provider is Azure OpenAI."
+	case "Cohere":
+		return "Cohere account used to generate embeddings. Required if the embedding provider is Cohere."
+	case "Local":
+		return "Self-hosted embedding server used to generate embeddings. Required if the embedding provider is local."
+	default:
+		return ""
+	}
+}
+
+type AzureOpenAiConfig struct {
+	Endpoint   string `json:"endpoint" jsonschema:"title=Endpoint" jsonschema_extras:"order=0"`
+	Deployment string `json:"deployment" jsonschema:"title=Deployment" jsonschema_extras:"order=1"`
+	ApiKey     string `json:"apiKey" jsonschema:"title=API Key" jsonschema_extras:"secret=true,order=2"`
+	ApiVersion string `json:"apiVersion,omitempty" jsonschema:"title=API Version,default=2024-02-01" jsonschema_extras:"order=3"`
+}
+
+func (AzureOpenAiConfig) GetFieldDocString(fieldName string) string {
+	switch fieldName {
+	case "Endpoint":
+		return "Endpoint of the Azure OpenAI resource. Example: https://my-resource.openai.azure.com"
+	case "Deployment":
+		return "Name of the deployment of an embedding model."
+	case "ApiKey":
+		return "API key of the Azure OpenAI resource."
+	case "ApiVersion":
+		return "Version of the Azure OpenAI API to use."
+	default:
+		return ""
+	}
+}
+
+type CohereConfig struct {
+	ApiKey string `json:"apiKey" jsonschema:"title=API Key" jsonschema_extras:"secret=true"`
+}
+
+func (CohereConfig) GetFieldDocString(fieldName string) string {
+	switch fieldName {
+	case "ApiKey":
+		return "Cohere API key used for authentication."
+	default:
+		return ""
+	}
+}
+
+type LocalEmbedderConfig struct {
+	Url    string `json:"url" jsonschema:"title=URL" jsonschema_extras:"order=0"`
+	ApiKey string `json:"apiKey,omitempty" jsonschema:"title=API Key" jsonschema_extras:"secret=true,order=1"`
+}
+
+func (LocalEmbedderConfig) GetFieldDocString(fieldName string) string {
+	switch fieldName {
+	case "Url":
+		return "URL of an embedding server which implements the OpenAI embeddings API. Example: http://localhost:8080/v1/embeddings"
+	case "ApiKey":
+		return "Optional API key which is sent as a bearer token."
+	default:
+		return ""
+	}
+}
+
+// Validate returns an error if properties required by the embedding provider are missing.
+func (c *EmbeddingConfig) Validate() error {
+	var requiredProperties [][]string
+
+	switch c.Provider() {
+	case ProviderOpenAi:
+		requiredProperties = append(requiredProperties, []string{"openAiApiKey", c.OpenAiApiKey})
+	case ProviderAzureOpenAi:
+		if c.AzureOpenAi == nil {
+			return fmt.Errorf("endpoint config missing required property 'azureOpenAi'")
+		}
+		requiredProperties = append(requiredProperties,
+			[]string{"azureOpenAi.endpoint", c.AzureOpenAi.Endpoint},
+			[]string{"azureOpenAi.deployment", c.AzureOpenAi.Deployment},
+			[]string{"azureOpenAi.apiKey", c.AzureOpenAi.ApiKey},
+		)
+	case ProviderCohere:
+		if c.Cohere == nil {
+			return fmt.Errorf("endpoint config missing required property 'cohere'")
+		}
+		requiredProperties = append(requiredProperties, []string{"cohere.apiKey", c.Cohere.ApiKey})
+	case ProviderLocal:
+		if c.Local == nil {
+			return fmt.Errorf("endpoint config missing required property 'local'")
+		}
+		requiredProperties = append(requiredProperties, []string{"local.url", c.Local.Url})
+	default:
+		return fmt.Errorf("invalid embedding provider %q", c.EmbeddingProvider)
+	}
+
+	for _, req := range requiredProperties {
+		if req[1] == "" {
+			return fmt.Errorf("endpoint config missing required property '%s'", req[0])
+		}
+	}
+
+	return nil
+}
+
+// Provider returns the configured embedding provider, which defaults to OpenAI.
+func (c *EmbeddingConfig) Provider() string {
+	if c.EmbeddingProvider == "" {
+		return ProviderOpenAi
+	}
+	return c.EmbeddingProvider
+}
+
+// Embedder returns the client of the configured embedding provider. The organization is used for
+// requests to OpenAI, if it's set.
+func (c *EmbeddingConfig) Embedder(openAiOrg string) Embedder {
+	switch c.Provider() {
+	case ProviderAzureOpenAi:
+		apiVersion := c.AzureOpenAi.ApiVersion
+		if apiVersion == "" {
+			apiVersion = azureOpenAiDefaultApiVersion
+		}
+		return NewAzureOpenAiClient(c.AzureOpenAi.Endpoint, c.AzureOpenAi.Deployment, apiVersion, c.AzureOpenAi.ApiKey)
+	case ProviderCohere:
+		model := cohereEmbedEnglishV3
+		if c.EmbeddingModel != "" {
+			model = c.EmbeddingModel
+		}
+		return NewCohereClient(model, c.Cohere.ApiKey)
+	case ProviderLocal:
+		return NewLocalEmbeddingClient(c.Local.Url, c.EmbeddingModel, c.Local.ApiKey)
+	default:
+		model := textEmbeddingAda002
+		if c.EmbeddingModel != "" {
+			model = c.EmbeddingModel
+		}
+		return NewOpenAiClient(model, openAiOrg, c.OpenAiApiKey)
+	}
+}
+
+// ChunkingConfig configures how long inputs are split into chunks.
+type ChunkingConfig struct {
+	MaxInputLength int `json:"maxInputLength,omitempty" jsonschema:"title=Maximum Input Length,default=8000"`
+	ChunkOverlap   int `json:"chunkOverlap,omitempty" jsonschema:"title=Chunk Overlap"`
+	MaxChunks      int `json:"maxChunks,omitempty" jsonschema:"title=Maximum Chunks,default=16"`
+}
+
+func (ChunkingConfig) GetFieldDocString(fieldName string) string {
+	switch fieldName {
+	case "MaxInputLength":
+		return "Maximum number of characters of the input of a single embedding. Longer inputs are split into chunks which are each embedded as a separate vector."
+	case "ChunkOverlap":
+		return "Number of characters at the end of a chunk which are repeated at the start of the next chunk."
+	case "MaxChunks":
+		return "Maximum number of chunks of a single document. Text beyond the last chunk is not embedded. Vectors of chunks beyond this limit are not deleted when a document shrinks, so avoid decreasing it."
+	default:
+		return ""
+	}
+}
+
+func (c ChunkingConfig) Validate() error {
+	if c.MaxInputLength < 0 {
+		return fmt.Errorf("maxInputLength must not be negative")
+	} else if c.MaxChunks < 0 {
+		return fmt.Errorf("maxChunks must not be negative")
+	} else if c.ChunkOverlap < 0 || c.ChunkOverlap >= c.maxInputLength() {
+		return fmt.Errorf("chunkOverlap must be at least 0 and less than maxInputLength %d", c.maxInputLength())
+	}
+
+	return nil
+}
+
+func (c ChunkingConfig) maxInputLength() int {
+	if c.MaxInputLength == 0 {
+		return defaultMaxInputLength
+	}
+	return c.MaxInputLength
+}
+
+func (c ChunkingConfig) maxChunks() int {
+	if c.MaxChunks == 0 {
+		return defaultMaxChunks
+	}
+	return c.MaxChunks
+}
+
+// InputConfig configures the input which is embedded for the documents of a binding.
+type InputConfig struct {
+	InputFields   []string `json:"inputFields,omitempty" jsonschema:"title=Input Fields"`
+	InputTemplate string   `json:"inputTemplate,omitempty" jsonschema:"title=Input Template"`
+}
+
+func (InputConfig) GetFieldDocString(fieldName string) string {
+	switch fieldName {
+	case "InputFields":
+		return "Fields which are embedded, in order. Each field is included as a 'field: value' line. Defaults to all selected fields other than the root document."
+	case "InputTemplate":
+		return "Go template which renders the embedded text instead of input fields. Fields are referenced by name, like '{{ .title }}' or '{{ index . \"my-field\" }}'."
+	default:
+		return ""
+	}
+}
+
+func (c InputConfig) Validate() error {
+	if len(c.InputFields) > 0 && c.InputTemplate != "" {
+		return fmt.Errorf("only one of inputFields and inputTemplate may be set")
+	}
+	if c.InputTemplate != "" {
+		if _, err := ParseInputTemplate(c.InputTemplate); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fields returns the fields which are included in the input. All selected fields are included if
+// no fields are configured, and nil is returned.
+func (c InputConfig) Fields() ([]string, error) {
+	if c.InputTemplate != "" {
+		tmpl, err := ParseInputTemplate(c.InputTemplate)
+		if err != nil {
+			return nil, err
+		}
+		return TemplateFields(tmpl), nil
+	}
+	return c.InputFields, nil
+}
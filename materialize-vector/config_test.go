@@ -0,0 +1,59 @@
+package vector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddingConfigValidate(t *testing.T) {
+	require.NoError(t, (&EmbeddingConfig{OpenAiApiKey: "openai"}).Validate())
+	require.NoError(t, (&EmbeddingConfig{EmbeddingProvider: ProviderLocal, Local: &LocalEmbedderConfig{Url: "http://localhost"}}).Validate())
+
+	for _, tt := range []struct {
+		name string
+		cfg  EmbeddingConfig
+		want string
+	}{
+		{"missing OpenAI key", EmbeddingConfig{}, "missing required property 'openAiApiKey'"},
+		{"missing Azure config", EmbeddingConfig{EmbeddingProvider: ProviderAzureOpenAi}, "missing required property 'azureOpenAi'"},
+		{"missing Azure deployment", EmbeddingConfig{
+			EmbeddingProvider: ProviderAzureOpenAi,
+			AzureOpenAi:       &AzureOpenAiConfig{Endpoint: "https://example.com", ApiKey: "key"},
+		}, "missing required property 'azureOpenAi.deployment'"},
+		{"missing Cohere key", EmbeddingConfig{EmbeddingProvider: ProviderCohere, Cohere: &CohereConfig{}}, "missing required property 'cohere.apiKey'"},
+		{"missing local URL", EmbeddingConfig{EmbeddingProvider: ProviderLocal, Local: &LocalEmbedderConfig{}}, "missing required property 'local.url'"},
+		{"invalid provider", EmbeddingConfig{EmbeddingProvider: "other"}, `invalid embedding provider "other"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.cfg.Validate(), tt.want)
+		})
+	}
+}
+
+func TestChunkingConfigValidate(t *testing.T) {
+	require.NoError(t, ChunkingConfig{}.Validate())
+	require.NoError(t, ChunkingConfig{MaxInputLength: 100, ChunkOverlap: 99}.Validate())
+
+	require.ErrorContains(t, ChunkingConfig{MaxInputLength: -1}.Validate(), "maxInputLength must not be negative")
+	require.ErrorContains(t, ChunkingConfig{MaxChunks: -1}.Validate(), "maxChunks must not be negative")
+	require.ErrorContains(t, ChunkingConfig{MaxInputLength: 100, ChunkOverlap: 100}.Validate(), "chunkOverlap must be at least 0 and less than maxInputLength 100")
+	require.ErrorContains(t, ChunkingConfig{ChunkOverlap: 8000}.Validate(), "less than maxInputLength 8000")
+}
+
+func TestInputConfig(t *testing.T) {
+	require.ErrorContains(t, InputConfig{InputFields: []string{"a"}, InputTemplate: "{{ .a }}"}.Validate(), "only one of inputFields and inputTemplate may be set")
+	require.ErrorContains(t, InputConfig{InputTemplate: "{{ .a "}.Validate(), "parsing input template")
+
+	fields, err := InputConfig{InputFields: []string{"b", "a"}}.Fields()
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "a"}, fields)
+
+	fields, err = InputConfig{InputTemplate: "{{ .b }} {{ .a }}"}.Fields()
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, fields)
+
+	fields, err = InputConfig{}.Fields()
+	require.NoError(t, err)
+	require.Nil(t, fields)
+}
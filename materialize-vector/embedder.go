@@ -0,0 +1,268 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Embedder creates vector embeddings of text.
+type Embedder interface {
+	// CreateEmbeddings returns an embedding for each input, in the same order as the inputs.
+	CreateEmbeddings(ctx context.Context, input []string) ([][]float64, error)
+}
+
+type OpenAIEmbeddingsRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type OpenAIEmbeddingsResponse struct {
+	Object string      `json:"object"`
+	Data   []Embedding `json:"data"`
+	Model  string      `json:"model"`
+	Usage  usage       `json:"usage"`
+}
+
+type Embedding struct {
+	Object    string    `json:"object"`
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+type usage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// OpenAiClient creates embeddings with the OpenAI embeddings API, or with any other service which
+// implements it such as Azure OpenAI and many locally hosted embedding servers.
+type OpenAiClient struct {
+	http           *http.Client
+	url            string
+	embeddingModel string
+	setAuth        func(*http.Request)
+}
+
+var _ Embedder = (*OpenAiClient)(nil)
+
+func NewOpenAiClient(embeddingModel string, org string, apiKey string) *OpenAiClient {
+	return &OpenAiClient{
+		http:           http.DefaultClient,
+		url:            "https://api.openai.com/v1/embeddings",
+		embeddingModel: embeddingModel,
+		setAuth: func(req *http.Request) {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+			if org != "" {
+				req.Header.Set("OpenAI-Organization", org)
+			}
+		},
+	}
+}
+
+// NewAzureOpenAiClient creates embeddings with the model of an Azure OpenAI deployment. The
+// endpoint is that of the Azure OpenAI resource, like https://my-resource.openai.azure.com.
+func NewAzureOpenAiClient(endpoint string, deployment string, apiVersion string, apiKey string) *OpenAiClient {
+	return &OpenAiClient{
+		http: http.DefaultClient,
+		url: fmt.Sprintf(
+			"%s/openai/deployments/%s/embeddings?api-version=%s",
+			strings.TrimSuffix(endpoint, "/"),
+			url.PathEscape(deployment),
+			url.QueryEscape(apiVersion),
+		),
+		setAuth: func(req *http.Request) {
+			req.Header.Set("api-key", apiKey)
+		},
+	}
+}
+
+// NewLocalEmbeddingClient creates embeddings with an embedding server which implements the OpenAI
+// embeddings API at the endpoint. The API key is optional.
+func NewLocalEmbeddingClient(endpoint string, embeddingModel string, apiKey string) *OpenAiClient {
+	return &OpenAiClient{
+		http:           http.DefaultClient,
+		url:            endpoint,
+		embeddingModel: embeddingModel,
+		setAuth: func(req *http.Request) {
+			if apiKey != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+			}
+		},
+	}
+}
+
+type openAiEmbeddingsError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+func (c *OpenAiClient) CreateEmbeddings(ctx context.Context, input []string) ([][]float64, error) {
+	res, err := WithRetry(ctx, func() (*http.Response, error) {
+		body := new(bytes.Buffer)
+		if err := json.NewEncoder(body).Encode(&OpenAIEmbeddingsRequest{
+			Model: c.embeddingModel,
+			Input: input,
+		}); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", c.url, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("accept", "application/json")
+		req.Header.Set("Content-Type", "application/json")
+		c.setAuth(req)
+
+		return c.http.Do(req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var errorBody openAiEmbeddingsError
+		if err := json.NewDecoder(res.Body).Decode(&errorBody); err != nil {
+			log.WithField("error", err).Warn("could not decode error response body")
+		} else if errorBody.Error.Message != "" {
+			return nil, fmt.Errorf("creating embeddings failed (%s): %s", res.Status, errorBody.Error.Message)
+		} else {
+			log.WithField("errorBody", errorBody).Warn("errorBody error message was empty")
+		}
+
+		return nil, fmt.Errorf("OpenAiClient CreateEmbeddings unexpected status: %s", res.Status)
+	}
+
+	embeddings := OpenAIEmbeddingsResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&embeddings); err != nil {
+		return nil, err
+	}
+
+	// Embeddings are returned with the index of their input, which is not necessarily in order.
+	out := make([][]float64, len(input))
+	for _, e := range embeddings.Data {
+		if e.Index < 0 || e.Index >= len(input) {
+			return nil, fmt.Errorf("embedding has index %d but there were %d inputs", e.Index, len(input))
+		}
+		out[e.Index] = e.Embedding
+	}
+	for idx := range out {
+		if out[idx] == nil {
+			return nil, fmt.Errorf("no embedding was returned for input %d", idx)
+		}
+	}
+
+	return out, nil
+}
+
+// cohereMaxTexts is the maximum number of texts which can be embedded by a single request to the
+// Cohere embed API.
+const cohereMaxTexts = 96
+
+type cohereEmbedRequest struct {
+	Model     string   `json:"model"`
+	Texts     []string `json:"texts"`
+	InputType string   `json:"input_type"`
+}
+
+type cohereEmbedResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+type cohereError struct {
+	Message string `json:"message"`
+}
+
+// CohereClient creates embeddings with the Cohere embed API.
+type CohereClient struct {
+	http           *http.Client
+	url            string
+	embeddingModel string
+	apiKey         string
+}
+
+var _ Embedder = (*CohereClient)(nil)
+
+func NewCohereClient(embeddingModel string, apiKey string) *CohereClient {
+	return &CohereClient{
+		http:           http.DefaultClient,
+		url:            "https://api.cohere.com/v1/embed",
+		embeddingModel: embeddingModel,
+		apiKey:         apiKey,
+	}
+}
+
+func (c *CohereClient) CreateEmbeddings(ctx context.Context, input []string) ([][]float64, error) {
+	out := make([][]float64, 0, len(input))
+
+	for len(input) > 0 {
+		texts := input[:min(len(input), cohereMaxTexts)]
+		input = input[len(texts):]
+
+		embeddings, err := c.embed(ctx, texts)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, embeddings...)
+	}
+
+	return out, nil
+}
+
+func (c *CohereClient) embed(ctx context.Context, texts []string) ([][]float64, error) {
+	res, err := WithRetry(ctx, func() (*http.Response, error) {
+		body := new(bytes.Buffer)
+		if err := json.NewEncoder(body).Encode(&cohereEmbedRequest{
+			Model: c.embeddingModel,
+			Texts: texts,
+			// Documents are embedded for retrieval by search queries.
+			InputType: "search_document",
+		}); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", c.url, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("accept", "application/json")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+
+		return c.http.Do(req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var errorBody cohereError
+		if err := json.NewDecoder(res.Body).Decode(&errorBody); err != nil {
+			log.WithField("error", err).Warn("could not decode error response body")
+		} else if errorBody.Message != "" {
+			return nil, fmt.Errorf("creating embeddings failed (%s): %s", res.Status, errorBody.Message)
+		}
+
+		return nil, fmt.Errorf("CohereClient CreateEmbeddings unexpected status: %s", res.Status)
+	}
+
+	embeddings := cohereEmbedResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&embeddings); err != nil {
+		return nil, err
+	} else if len(embeddings.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d inputs", len(embeddings.Embeddings), len(texts))
+	}
+
+	return embeddings.Embeddings, nil
+}
@@ -0,0 +1,86 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAiClientCreateEmbeddings(t *testing.T) {
+	var gotPath, gotQuery, gotApiKey string
+	var gotReq OpenAIEmbeddingsRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery, gotApiKey = r.URL.Path, r.URL.RawQuery, r.Header.Get("api-key")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&gotReq))
+
+		// Embeddings are returned out of order.
+		var res OpenAIEmbeddingsResponse
+		for idx := len(gotReq.Input) - 1; idx >= 0; idx-- {
+			res.Data = append(res.Data, Embedding{Index: idx, Embedding: []float64{float64(idx)}})
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(res))
+	}))
+	defer srv.Close()
+
+	c := NewAzureOpenAiClient(srv.URL+"/", "my-deployment", "2024-02-01", "secret")
+	got, err := c.CreateEmbeddings(context.Background(), []string{"a", "b", "c"})
+	require.NoError(t, err)
+	require.Equal(t, [][]float64{{0}, {1}, {2}}, got)
+
+	require.Equal(t, "/openai/deployments/my-deployment/embeddings", gotPath)
+	require.Equal(t, "api-version=2024-02-01", gotQuery)
+	require.Equal(t, "secret", gotApiKey)
+	require.Equal(t, OpenAIEmbeddingsRequest{Input: []string{"a", "b", "c"}}, gotReq)
+
+	c = NewLocalEmbeddingClient(srv.URL+"/v1/embeddings", "my-model", "")
+	_, err = c.CreateEmbeddings(context.Background(), []string{"a"})
+	require.NoError(t, err)
+	require.Equal(t, "/v1/embeddings", gotPath)
+	require.Equal(t, OpenAIEmbeddingsRequest{Model: "my-model", Input: []string{"a"}}, gotReq)
+}
+
+func TestCohereClientCreateEmbeddings(t *testing.T) {
+	var requests []cohereEmbedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var req cohereEmbedRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+
+		var res cohereEmbedResponse
+		for _, text := range req.Texts {
+			var n float64
+			fmt.Sscanf(text, "%g", &n)
+			res.Embeddings = append(res.Embeddings, []float64{n})
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(res))
+	}))
+	defer srv.Close()
+
+	c := NewCohereClient("embed-english-v3.0", "secret")
+	c.url = srv.URL
+
+	var input []string
+	var want [][]float64
+	for idx := 0; idx < 100; idx++ {
+		input = append(input, fmt.Sprint(idx))
+		want = append(want, []float64{float64(idx)})
+	}
+
+	got, err := c.CreateEmbeddings(context.Background(), input)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// Inputs are embedded in batches of at most 96 texts.
+	require.Len(t, requests, 2)
+	require.Len(t, requests[0].Texts, 96)
+	require.Len(t, requests[1].Texts, 4)
+	require.Equal(t, "embed-english-v3.0", requests[0].Model)
+	require.Equal(t, "search_document", requests[0].InputType)
+}
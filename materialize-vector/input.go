@@ -0,0 +1,207 @@
+package vector
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+)
+
+// InputBuilder renders the text which is embedded for a document from its selected fields.
+type InputBuilder struct {
+	// Names of the key and value fields, in the order of their values.
+	fields []string
+	// Indices of the fields which are included as "field: value" lines, in order. Unused if there
+	// is a template.
+	include []int
+	tmpl    *template.Template
+}
+
+// NewInputBuilder creates an InputBuilder for a binding with the selected key and value fields,
+// in the order of their values.
+func NewInputBuilder(cfg InputConfig, fields []string) (*InputBuilder, error) {
+	var b = &InputBuilder{fields: fields}
+
+	if cfg.InputTemplate != "" {
+		tmpl, err := ParseInputTemplate(cfg.InputTemplate)
+		if err != nil {
+			return nil, err
+		}
+		b.tmpl = tmpl
+	} else if len(cfg.InputFields) > 0 {
+		for _, f := range cfg.InputFields {
+			idx := slices.Index(fields, f)
+			if idx == -1 {
+				return nil, fmt.Errorf("input field %q is not a selected field", f)
+			}
+			b.include = append(b.include, idx)
+		}
+	} else {
+		for idx := range fields {
+			b.include = append(b.include, idx)
+		}
+	}
+
+	return b, nil
+}
+
+// Build renders the input of a document from the values of its key and value fields.
+func (b *InputBuilder) Build(key, values tuple.Tuple) (string, error) {
+	var all = make([]tuple.TupleElement, 0, len(key)+len(values))
+	all = append(append(all, key...), values...)
+
+	if b.tmpl != nil {
+		var data = make(map[string]any, len(b.fields))
+		for idx, f := range b.fields {
+			switch v := all[idx].(type) {
+			case nil:
+				data[f] = ""
+			case []byte:
+				// JSON arrays and objects are rendered as their encoded JSON.
+				data[f] = string(v)
+			case json.RawMessage:
+				data[f] = string(v)
+			default:
+				data[f] = v
+			}
+		}
+
+		var out strings.Builder
+		if err := b.tmpl.Execute(&out, data); err != nil {
+			return "", fmt.Errorf("rendering input template: %w", err)
+		}
+		return out.String(), nil
+	}
+
+	// The input is arranged as "field: value" lines with the value being the JSON encoding of the
+	// field value. Fields with null values are omitted.
+	var lines []string
+	for _, idx := range b.include {
+		v := all[idx]
+		if v == nil {
+			continue
+		} else if c, ok := v.([]byte); ok {
+			// We get JSON arrays and objects as raw bytes of their encoded JSON. Rather than
+			// encoding that as a base64-string, we want to handle these bytes as precomputed JSON.
+			v = json.RawMessage(c)
+		}
+
+		m, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("serializing input field: %w", err)
+		}
+		lines = append(lines, b.fields[idx]+": "+string(m))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// ParseInputTemplate parses the template of an input. Templates fail to render if they reference
+// a field which is not selected.
+func ParseInputTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("input").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing input template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// TemplateFields returns the fields which are referenced by the template, either as '.field' or
+// as 'index . "field"'. References within the body of range and with actions are relative to a
+// different value, and are not included.
+func TemplateFields(tmpl *template.Template) []string {
+	var out []string
+
+	var walk func(parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			if len(n.Args) >= 3 {
+				ident, isIdent := n.Args[0].(*parse.IdentifierNode)
+				_, isDot := n.Args[1].(*parse.DotNode)
+				str, isString := n.Args[2].(*parse.StringNode)
+				if isIdent && ident.Ident == "index" && isDot && isString {
+					out = append(out, str.Text)
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			out = append(out, n.Ident[0])
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		}
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// ChunkInput splits the input into chunks of at most maxLength characters, with each chunk
+// repeating the last overlap characters of the previous one. Chunks are split after whitespace
+// where possible. At most maxChunks chunks are returned, and truncated is true if there was more
+// input than fits in them.
+func ChunkInput(input string, maxLength, overlap, maxChunks int) (chunks []string, truncated bool) {
+	runes := []rune(input)
+
+	start := 0
+	for len(chunks) < maxChunks {
+		end := start + maxLength
+		if end >= len(runes) {
+			return append(chunks, string(runes[start:])), false
+		}
+
+		// Prefer to split after whitespace, as long as at least half of the chunk is used.
+		for i := end; i > start+maxLength/2; i-- {
+			if unicode.IsSpace(runes[i-1]) {
+				end = i
+				break
+			}
+		}
+		chunks = append(chunks, string(runes[start:end]))
+
+		if next := end - overlap; next > start {
+			start = next
+		} else {
+			start = end
+		}
+	}
+
+	return chunks, true
+}
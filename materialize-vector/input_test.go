@@ -0,0 +1,74 @@
+package vector
+
+import (
+	"testing"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputBuilder(t *testing.T) {
+	fields := []string{"id", "title", "body", "tags", "missing"}
+	key := tuple.Tuple{int64(1)}
+	values := tuple.Tuple{"A title", "Some text", []byte(`["a","b"]`), nil}
+
+	for _, tt := range []struct {
+		name string
+		cfg  InputConfig
+		want string
+	}{
+		{"all fields in order", InputConfig{}, "id: 1\ntitle: \"A title\"\nbody: \"Some text\"\ntags: [\"a\",\"b\"]"},
+		{"selected fields in order", InputConfig{InputFields: []string{"body", "title"}}, "body: \"Some text\"\ntitle: \"A title\""},
+		{"template", InputConfig{InputTemplate: `{{ .title }} ({{ .id }}){{ index . "missing" }}: {{ .body }} {{ .tags }}`}, `A title (1): Some text ["a","b"]`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewInputBuilder(tt.cfg, fields)
+			require.NoError(t, err)
+
+			got, err := b.Build(key, values)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := NewInputBuilder(InputConfig{InputFields: []string{"other"}}, fields)
+	require.ErrorContains(t, err, `input field "other" is not a selected field`)
+
+	b, err := NewInputBuilder(InputConfig{InputTemplate: "{{ .other }}"}, fields)
+	require.NoError(t, err)
+	_, err = b.Build(key, values)
+	require.ErrorContains(t, err, `map has no entry for key "other"`)
+}
+
+func TestTemplateFields(t *testing.T) {
+	tmpl, err := ParseInputTemplate(`{{ .title }} {{ index . "my-field" }} {{ if .flag }}{{ .a | printf "%s" }}{{ else }}{{ .b }}{{ end }}` +
+		`{{ range .items }}{{ .nested }}{{ end }}{{ with .obj }}{{ .inner }}{{ else }}{{ .c }}{{ end }}{{ .title }}`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c", "flag", "items", "my-field", "obj", "title"}, TemplateFields(tmpl))
+}
+
+func TestChunkInput(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		input         string
+		maxLength     int
+		overlap       int
+		maxChunks     int
+		want          []string
+		wantTruncated bool
+	}{
+		{"short input", "hello world", 20, 0, 4, []string{"hello world"}, false},
+		{"empty input", "", 20, 0, 4, []string{""}, false},
+		{"split at whitespace", "the quick brown fox jumps", 12, 0, 4, []string{"the quick ", "brown fox ", "jumps"}, false},
+		{"split without whitespace", "abcdefghij", 4, 0, 4, []string{"abcd", "efgh", "ij"}, false},
+		{"overlap", "abcdefghij", 4, 2, 8, []string{"abcd", "cdef", "efgh", "ghij"}, false},
+		{"multi-byte characters", "ééééé", 2, 0, 4, []string{"éé", "éé", "é"}, false},
+		{"truncated", "abcdefghij", 4, 0, 2, []string{"abcd", "efgh"}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := ChunkInput(tt.input, tt.maxLength, tt.overlap, tt.maxChunks)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.wantTruncated, truncated)
+		})
+	}
+}
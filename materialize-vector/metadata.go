@@ -0,0 +1,69 @@
+package vector
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+)
+
+// Metadata selects the fields of documents which are included as metadata of their vectors.
+type Metadata struct {
+	// Names of the key and value fields, in the order of their values.
+	fields []string
+	// Indices of the fields which are included.
+	include []int
+}
+
+// NewMetadata creates a Metadata which includes the named fields of a binding with the selected
+// key and value fields, in the order of their values.
+func NewMetadata(fields []string, include []string) (*Metadata, error) {
+	var m = &Metadata{fields: fields}
+
+	for _, f := range include {
+		idx := slices.Index(fields, f)
+		if idx == -1 {
+			return nil, fmt.Errorf("metadata field %q is not a selected field", f)
+		}
+		m.include = append(m.include, idx)
+	}
+
+	return m, nil
+}
+
+// Build returns the metadata of a document from the values of its key and value fields. Arrays
+// and objects are decoded from their JSON encoding, and fields with null values are omitted.
+func (m *Metadata) Build(key, values tuple.Tuple) (map[string]any, error) {
+	var out = make(map[string]any, len(m.include))
+
+	for _, idx := range m.include {
+		var v tuple.TupleElement
+		if idx < len(key) {
+			v = key[idx]
+		} else {
+			v = values[idx-len(key)]
+		}
+
+		var raw []byte
+		switch vv := v.(type) {
+		case nil:
+			continue
+		case []byte:
+			raw = vv
+		case json.RawMessage:
+			raw = vv
+		default:
+			out[m.fields[idx]] = v
+			continue
+		}
+
+		var decoded any
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			return nil, fmt.Errorf("decoding metadata field %q: %w", m.fields[idx], err)
+		}
+		out[m.fields[idx]] = decoded
+	}
+
+	return out, nil
+}
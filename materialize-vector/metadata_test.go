@@ -0,0 +1,27 @@
+package vector
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata(t *testing.T) {
+	fields := []string{"id", "title", "score", "tags", "missing"}
+
+	m, err := NewMetadata(fields, []string{"id", "score", "tags", "missing"})
+	require.NoError(t, err)
+
+	got, err := m.Build(tuple.Tuple{"key"}, tuple.Tuple{"A title", 1.5, json.RawMessage(`["a","b"]`), nil})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"id":    "key",
+		"score": 1.5,
+		"tags":  []any{"a", "b"},
+	}, got)
+
+	_, err = NewMetadata(fields, []string{"other"})
+	require.ErrorContains(t, err, `metadata field "other" is not a selected field`)
+}
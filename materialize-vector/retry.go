@@ -0,0 +1,83 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	maxRetries             = 10
+	initialBackoff float64 = 200 // Milliseconds
+	maxBackoff             = time.Duration(60 * time.Second)
+	retryableCodes         = []int{http.StatusTooManyRequests, http.StatusInternalServerError}
+)
+
+// WithRetry sends a request with fn, retrying it with an exponential backoff for as long as its
+// response has a status code which indicates that the request should be retried.
+func WithRetry(ctx context.Context, fn func() (*http.Response, error)) (*http.Response, error) {
+	n := 0
+	backoff := initialBackoff
+
+	for {
+		n++
+
+		res, err := fn()
+		if err != nil {
+			return nil, fmt.Errorf("withRetry: %w", err)
+		}
+
+		if containsCode(retryableCodes, res.StatusCode) {
+			if n > maxRetries {
+				log.WithFields(log.Fields{
+					"host":       res.Request.URL.Host,
+					"path":       res.Request.URL.Path,
+					"attempts":   n,
+					"lastCode":   res.StatusCode,
+					"lastStatus": res.Status,
+				}).Warn("exceeded retry limit")
+				return res, nil
+			}
+
+			res.Body.Close()
+			backoff *= math.Pow(2, 1+rand.Float64())
+			delay := time.Duration(backoff * float64(time.Millisecond))
+			if delay > maxBackoff {
+				delay = maxBackoff
+			}
+
+			log.WithFields(log.Fields{
+				"host":       res.Request.URL.Host,
+				"path":       res.Request.URL.Path,
+				"attempts":   n,
+				"lastCode":   res.StatusCode,
+				"lastStatus": res.Status,
+				"delay":      delay.String(),
+			}).Info("waiting to retry request on retryable error")
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+				continue
+			}
+		}
+
+		return res, nil
+	}
+}
+
+func containsCode(src []int, in int) bool {
+	for _, c := range src {
+		if c == in {
+			return true
+		}
+	}
+
+	return false
+}
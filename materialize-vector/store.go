@@ -0,0 +1,28 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Vector is the embedding of a chunk of the input of a document.
+type Vector struct {
+	ID     string
+	Values []float64
+	// Metadata holds the values of the metadata fields of the document, by field name.
+	Metadata map[string]any
+	// Document is the root document, if the binding includes it.
+	Document json.RawMessage
+}
+
+// VectorStore is a database of vectors which the vectors of bindings are materialized to. Its
+// methods may be called concurrently.
+type VectorStore interface {
+	// Upsert creates or replaces vectors of the binding.
+	Upsert(ctx context.Context, binding int, vectors []Vector) error
+	// Delete deletes vectors of the binding by their IDs. It is not an error if some of the vectors
+	// do not exist.
+	Delete(ctx context.Context, binding int, ids []string) error
+	// Close releases the resources of the store.
+	Close()
+}
@@ -0,0 +1,341 @@
+package vector
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	m "github.com/estuary/connectors/go/protocols/materialize"
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	pc "go.gazette.dev/core/consumer/protocol"
+	"golang.org/x/sync/errgroup"
+)
+
+// Binding is a binding which materializes the documents of a collection as vectors.
+type Binding struct {
+	// Name identifies the binding in logs and the state of the Transactor, such as by the namespace
+	// or table it is materialized to.
+	Name string
+	// Input builds the input which is embedded for documents.
+	Input *InputBuilder
+	// Metadata selects the fields which are included as metadata of vectors. No fields are included
+	// if it is nil.
+	Metadata *Metadata
+	// IncludeDocument includes the root document with vectors.
+	IncludeDocument bool
+
+	// Whether a warning has been logged about the input of a document being truncated.
+	warnedTruncation bool
+}
+
+// TransactorConfig configures a Transactor.
+type TransactorConfig struct {
+	Store    VectorStore
+	Embedder Embedder
+	Bindings []*Binding
+	Chunking ChunkingConfig
+
+	// UpsertBatchSize is the number of vectors which are embedded and upserted together.
+	UpsertBatchSize int
+	// DeleteBatchSize is the maximum number of vectors which are deleted together.
+	DeleteBatchSize int
+	// Concurrency is the number of batches which may be embedded, upserted or deleted at once.
+	Concurrency int
+}
+
+// Transactor is a delta updates transactor which embeds the inputs of stored documents and
+// upserts their vectors to a VectorStore. Inputs which are too long are split into chunks, which
+// each have a vector with an ID of the base64-encoded packed key of their document and the index
+// of the chunk, like "<key>#0". Vectors of chunks which a previous version of a document may have
+// had are deleted, as are all of the vectors of documents which are deletion tombstones.
+//
+// The most chunks that any document of a binding has had is tracked in the connector state, so
+// that only the chunks up to this number are deleted rather than up to the maximum number of chunks.
+type Transactor struct {
+	cfg TransactorConfig
+
+	// Most chunks that any document of each binding has had, by binding index.
+	maxChunks []int
+
+	// State of the transaction being stored.
+	ctx      context.Context
+	group    *errgroup.Group
+	groupCtx context.Context
+	// Partial batches of upserts, and pending deletes, by binding index.
+	batches map[int][]upsertDoc
+	deletes map[int][]string
+	// Keys of each binding which have pending upserts or deletes. A key which is stored again
+	// within the transaction first waits for them, so that its operations are applied in order.
+	pendingKeys map[int]map[string]bool
+	// Bindings which had documents with more chunks than any before them.
+	updated map[string]int
+}
+
+var _ m.Transactor = (*Transactor)(nil)
+
+// transactorState is the connector state of a Transactor.
+type transactorState struct {
+	// MaxChunks is the most chunks that any document of each binding has had, by binding name.
+	MaxChunks map[string]int `json:"maxChunks,omitempty"`
+}
+
+func NewTransactor(cfg TransactorConfig) *Transactor {
+	return &Transactor{cfg: cfg, maxChunks: make([]int, len(cfg.Bindings))}
+}
+
+type upsertDoc struct {
+	input  string
+	vector Vector
+}
+
+func (t *Transactor) UnmarshalState(state json.RawMessage) error {
+	var s transactorState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return fmt.Errorf("decoding state: %w", err)
+	}
+	for idx, b := range t.cfg.Bindings {
+		t.maxChunks[idx] = s.MaxChunks[b.Name]
+	}
+	return nil
+}
+
+func (t *Transactor) Acknowledge(ctx context.Context) (*pf.ConnectorState, error) { return nil, nil }
+
+func (t *Transactor) Load(it *m.LoadIterator, loaded func(int, json.RawMessage) error) error {
+	for it.Next() {
+		panic("driver only supports delta updates")
+	}
+	return nil
+}
+
+func (t *Transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
+	t.beginStore(it.Context())
+
+	for it.Next() {
+		if err := t.storeDocument(it.Binding, it.Key, it.PackedKey, it.Values, it.RawJSON); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := t.flush(); err != nil {
+		return nil, err
+	} else if len(t.updated) == 0 {
+		return nil, nil
+	}
+
+	// The vectors of the documents are stored before the state which accounts for their chunks is
+	// committed, and they are stored again if the transaction is retried.
+	state, err := json.Marshal(transactorState{MaxChunks: t.updated})
+	if err != nil {
+		return nil, fmt.Errorf("encoding state: %w", err)
+	}
+	return func(context.Context, *pc.Checkpoint) (*pf.ConnectorState, m.OpFuture) {
+		return &pf.ConnectorState{UpdatedJson: state, MergePatch: true}, nil
+	}, nil
+}
+
+// beginStore resets the state of the transaction being stored.
+func (t *Transactor) beginStore(ctx context.Context) {
+	t.ctx = ctx
+	t.newGroup()
+	t.batches = make(map[int][]upsertDoc)
+	t.deletes = make(map[int][]string)
+	t.pendingKeys = make(map[int]map[string]bool)
+	t.updated = make(map[string]int)
+}
+
+func (t *Transactor) newGroup() {
+	t.group, t.groupCtx = errgroup.WithContext(t.ctx)
+	t.group.SetLimit(t.cfg.Concurrency)
+}
+
+// storeDocument embeds and upserts the chunks of a stored document, and adds the vectors which may
+// remain from its previous versions to the pending deletes.
+func (t *Transactor) storeDocument(binding int, key tuple.Tuple, packedKey []byte, values tuple.Tuple, doc json.RawMessage) error {
+	b := t.cfg.Bindings[binding]
+	id := base64.RawURLEncoding.EncodeToString(packedKey)
+
+	if t.pendingKeys[binding][id] {
+		if err := t.flush(); err != nil {
+			return err
+		}
+	}
+	if t.pendingKeys[binding] == nil {
+		t.pendingKeys[binding] = make(map[string]bool)
+	}
+	t.pendingKeys[binding][id] = true
+
+	// All of the vectors of deleted documents are deleted.
+	if isDeletion(doc) {
+		t.deletes[binding] = append(t.deletes[binding], staleVectorIDs(id, 0, t.maxChunks[binding])...)
+		return nil
+	}
+
+	embeddingInput, err := b.Input.Build(key, values)
+	if err != nil {
+		return err
+	}
+
+	maxChunks := t.cfg.Chunking.maxChunks()
+	chunks, truncated := ChunkInput(embeddingInput, t.cfg.Chunking.maxInputLength(), t.cfg.Chunking.ChunkOverlap, maxChunks)
+	if truncated && !b.warnedTruncation {
+		log.WithFields(log.Fields{
+			"binding":   b.Name,
+			"maxChunks": maxChunks,
+		}).Warn("input of a document exceeds the maximum number of chunks and will be truncated")
+		b.warnedTruncation = true
+	}
+
+	var metadata map[string]any
+	if b.Metadata != nil {
+		if metadata, err = b.Metadata.Build(key, values); err != nil {
+			return err
+		}
+	}
+	if !b.IncludeDocument {
+		doc = nil
+	}
+
+	for idx, chunk := range chunks {
+		t.batches[binding] = append(t.batches[binding], upsertDoc{
+			input: chunk,
+			vector: Vector{
+				ID:       chunkVectorID(id, idx),
+				Metadata: metadata,
+				Document: doc,
+			},
+		})
+
+		if len(t.batches[binding]) >= t.cfg.UpsertBatchSize {
+			if err := t.sendBatch(binding, t.batches[binding]); err != nil {
+				return fmt.Errorf("sending batch of documents: %w", err)
+			}
+			t.batches[binding] = nil
+		}
+	}
+
+	t.deletes[binding] = append(t.deletes[binding], staleVectorIDs(id, len(chunks), t.maxChunks[binding])...)
+	if len(chunks) > t.maxChunks[binding] {
+		t.maxChunks[binding] = len(chunks)
+		t.updated[b.Name] = len(chunks)
+	}
+	return nil
+}
+
+// flush completes the pending upserts, and then the pending deletes. Deletes are only sent once
+// upserts have completed, since they may be of chunks which previous versions of a document
+// upserted within the transaction. Upserts and deletes which are pending together are of distinct
+// vectors, as each key has at most one pending version.
+func (t *Transactor) flush() error {
+	for binding, batch := range t.batches {
+		if len(batch) > 0 {
+			if err := t.sendBatch(binding, batch); err != nil {
+				return fmt.Errorf("flushing documents batch: %w", err)
+			}
+		}
+	}
+	if err := t.group.Wait(); err != nil {
+		return err
+	}
+	t.batches = make(map[int][]upsertDoc)
+
+	t.newGroup()
+	for binding, ids := range t.deletes {
+		for len(ids) > 0 {
+			n := min(len(ids), t.cfg.DeleteBatchSize)
+			if err := t.sendDeletes(binding, ids[:n]); err != nil {
+				return fmt.Errorf("sending batch of deletes: %w", err)
+			}
+			ids = ids[n:]
+		}
+	}
+	if err := t.group.Wait(); err != nil {
+		return err
+	}
+	t.deletes = make(map[int][]string)
+	t.pendingKeys = make(map[int]map[string]bool)
+
+	t.newGroup()
+	return nil
+}
+
+// isDeletion returns whether the document is a deletion tombstone, which has a /_meta/op of "d".
+// Only as much of the document as is needed to find the op is parsed.
+func isDeletion(doc json.RawMessage) bool {
+	return gjson.GetBytes(doc, "_meta.op").String() == "d"
+}
+
+// chunkVectorID is the ID of the vector of a chunk of the input of a document.
+func chunkVectorID(key string, chunk int) string {
+	return key + "#" + strconv.Itoa(chunk)
+}
+
+// staleVectorIDs returns the IDs of vectors which may remain from previous versions of a document
+// that had more chunks than its current version, given that no document had more than maxChunks.
+// This includes the ID of the single vector of a document which was materialized before inputs
+// were chunked.
+func staleVectorIDs(key string, chunks int, maxChunks int) []string {
+	var out = []string{key}
+	for idx := chunks; idx < maxChunks; idx++ {
+		out = append(out, chunkVectorID(key, idx))
+	}
+	return out
+}
+
+func (t *Transactor) sendBatch(binding int, batch []upsertDoc) error {
+	select {
+	case <-t.groupCtx.Done():
+		return t.group.Wait()
+	default:
+		t.group.Go(func() error {
+			input := make([]string, 0, len(batch))
+			for _, r := range batch {
+				input = append(input, r.input)
+			}
+
+			embeddings, err := t.cfg.Embedder.CreateEmbeddings(t.groupCtx, input)
+			if err != nil {
+				return fmt.Errorf("creating embeddings: %w", err)
+			}
+
+			vectors := make([]Vector, 0, len(batch))
+			for idx, e := range embeddings {
+				v := batch[idx].vector
+				v.Values = e
+				vectors = append(vectors, v)
+			}
+
+			if err := t.cfg.Store.Upsert(t.groupCtx, binding, vectors); err != nil {
+				return fmt.Errorf("upserting batch of binding %q: %w", t.cfg.Bindings[binding].Name, err)
+			}
+			return nil
+		})
+	}
+
+	return nil
+}
+
+func (t *Transactor) sendDeletes(binding int, ids []string) error {
+	select {
+	case <-t.groupCtx.Done():
+		return t.group.Wait()
+	default:
+		t.group.Go(func() error {
+			if err := t.cfg.Store.Delete(t.groupCtx, binding, ids); err != nil {
+				return fmt.Errorf("deleting batch of binding %q: %w", t.cfg.Bindings[binding].Name, err)
+			}
+			return nil
+		})
+	}
+
+	return nil
+}
+
+func (t *Transactor) Destroy() {
+	t.cfg.Store.Close()
+}
@@ -0,0 +1,154 @@
+package vector
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/estuary/flow/go/protocols/fdb/tuple"
+	"github.com/stretchr/testify/require"
+)
+
+// testEmbedder embeds inputs as their length, after a delay for inputs having a slow prefix.
+type testEmbedder struct {
+	slow string
+}
+
+func (e *testEmbedder) CreateEmbeddings(ctx context.Context, input []string) ([][]float64, error) {
+	var out [][]float64
+	for _, in := range input {
+		if e.slow != "" && strings.HasPrefix(in, e.slow) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		out = append(out, []float64{float64(len(in))})
+	}
+	return out, nil
+}
+
+// testStore holds the vectors of a single binding by their ID.
+type testStore struct {
+	mu      sync.Mutex
+	vectors map[string][]float64
+}
+
+func (s *testStore) Upsert(ctx context.Context, binding int, vectors []Vector) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range vectors {
+		s.vectors[v.ID] = v.Values
+	}
+	return nil
+}
+
+func (s *testStore) Delete(ctx context.Context, binding int, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.vectors, id)
+	}
+	return nil
+}
+
+func (s *testStore) Close() {}
+
+func newTestTransactor(t *testing.T, embedder Embedder, store VectorStore) *Transactor {
+	input, err := NewInputBuilder(InputConfig{InputTemplate: "{{ .body }}"}, []string{"id", "body"})
+	require.NoError(t, err)
+
+	tr := NewTransactor(TransactorConfig{
+		Store:           store,
+		Embedder:        embedder,
+		Bindings:        []*Binding{{Name: "docs", Input: input}},
+		Chunking:        ChunkingConfig{MaxInputLength: 10},
+		UpsertBatchSize: 3,
+		DeleteBatchSize: 1,
+		Concurrency:     4,
+	})
+	tr.beginStore(context.Background())
+	return tr
+}
+
+// storeTestDocument stores a document of the key having the body, or a deletion tombstone if the
+// body is empty.
+func storeTestDocument(t *testing.T, tr *Transactor, key string, body string) {
+	doc := json.RawMessage(`{"id":"` + key + `","body":"` + body + `"}`)
+	if body == "" {
+		doc = json.RawMessage(`{"id":"` + key + `","_meta":{"op":"d"}}`)
+	}
+	require.NoError(t, tr.storeDocument(0, tuple.Tuple{key}, tuple.Tuple{key}.Pack(), tuple.Tuple{body}, doc))
+}
+
+func testVectorID(key string, chunk int) string {
+	return chunkVectorID(base64.RawURLEncoding.EncodeToString(tuple.Tuple{key}.Pack()), chunk)
+}
+
+func TestStaleVectorIDs(t *testing.T) {
+	require.Equal(t, []string{"key", "key#2", "key#3"}, staleVectorIDs("key", 2, 4))
+	require.Equal(t, []string{"key"}, staleVectorIDs("key", 4, 4))
+	require.Equal(t, []string{"key"}, staleVectorIDs("key", 0, 0))
+}
+
+func TestUnmarshalState(t *testing.T) {
+	tr := NewTransactor(TransactorConfig{Bindings: []*Binding{{Name: "first"}, {Name: "second"}}})
+	require.NoError(t, tr.UnmarshalState(json.RawMessage(`{"maxChunks":{"second":3,"removed":5}}`)))
+	require.Equal(t, []int{0, 3}, tr.maxChunks)
+
+	require.Error(t, tr.UnmarshalState(json.RawMessage(`{"maxChunks":[]}`)))
+}
+
+func TestIsDeletion(t *testing.T) {
+	for _, tt := range []struct {
+		doc  string
+		want bool
+	}{
+		{`{"id":1,"_meta":{"op":"d","uuid":"abc"}}`, true},
+		{`{"id":1,"_meta":{"op":"u"}}`, false},
+		{`{"id":1,"_meta":{"uuid":"abc"}}`, false},
+		{`{"id":1}`, false},
+		{`{"_meta":{"op":"d"},"id":1}`, true},
+		{`{"id":{"_meta":{"op":"d"}}}`, false},
+	} {
+		require.Equal(t, tt.want, isDeletion(json.RawMessage(tt.doc)), tt.doc)
+	}
+}
+
+func TestStoreRechunkedDocument(t *testing.T) {
+	store := &testStore{vectors: make(map[string][]float64)}
+	tr := newTestTransactor(t, &testEmbedder{slow: "aaaa"}, store)
+
+	// The first version of the document has three chunks which are slow to embed, and the stale
+	// chunks of the second version are deleted only once they are upserted.
+	storeTestDocument(t, tr, "k1", "aaaaaaaaaabbbbbbbbbbcccccccccc")
+	storeTestDocument(t, tr, "k2", "x")
+	storeTestDocument(t, tr, "k1", "y")
+	require.NoError(t, tr.flush())
+
+	require.NotContains(t, store.vectors, testVectorID("k1", 1))
+	require.NotContains(t, store.vectors, testVectorID("k1", 2))
+	require.Equal(t, []float64{1}, store.vectors[testVectorID("k1", 0)])
+	require.Equal(t, []int{3}, tr.maxChunks)
+	require.Equal(t, map[string]int{"docs": 3}, tr.updated)
+}
+
+func TestStoreDeletedDocument(t *testing.T) {
+	store := &testStore{vectors: make(map[string][]float64)}
+	tr := newTestTransactor(t, &testEmbedder{slow: "a"}, store)
+
+	// A document which is upserted and then deleted within a transaction isn't brought back by
+	// its upsert, which is slow to embed.
+	storeTestDocument(t, tr, "k1", "aaaaaaaaaabbbb")
+	storeTestDocument(t, tr, "k2", "x")
+	storeTestDocument(t, tr, "k1", "")
+	require.NoError(t, tr.flush())
+	require.Equal(t, map[string][]float64{testVectorID("k2", 0): {1}}, store.vectors)
+
+	// A document which is deleted and then stored again has the vectors of its last version.
+	storeTestDocument(t, tr, "k2", "")
+	storeTestDocument(t, tr, "k2", "abc")
+	require.NoError(t, tr.flush())
+	require.Equal(t, map[string][]float64{testVectorID("k2", 0): {3}}, store.vectors)
+}
@@ -0,0 +1,78 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	pf "github.com/estuary/flow/go/protocols/flow"
+	pm "github.com/estuary/flow/go/protocols/materialize"
+)
+
+// Constraints returns the constraints of the projections of a collection which is materialized
+// as vectors of the configured input, with the metadata fields.
+func Constraints(collection pf.CollectionSpec, input InputConfig, metadataFields []string) (map[string]*pm.Response_Validated_Constraint, error) {
+	// Fields which are included in the embedding input must be materialized.
+	inputFields, err := input.Fields()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range inputFields {
+		if p := collection.GetProjection(f); p == nil {
+			return nil, fmt.Errorf("input field %q is not a field of collection %q", f, collection.Name)
+		} else if p.IsRootDocumentProjection() {
+			return nil, fmt.Errorf("input field %q is the root document, which can't be included in the input", f)
+		}
+	}
+
+	for _, f := range metadataFields {
+		if p := collection.GetProjection(f); p == nil {
+			return nil, fmt.Errorf("metadata field %q is not a field of collection %q", f, collection.Name)
+		} else if p.IsRootDocumentProjection() {
+			return nil, fmt.Errorf("metadata field %q is the root document, which can't be included as a metadata field", f)
+		}
+	}
+
+	constraints := make(map[string]*pm.Response_Validated_Constraint)
+	for _, projection := range collection.Projections {
+
+		var constraint = new(pm.Response_Validated_Constraint)
+		switch {
+		case slices.Contains(inputFields, projection.Field):
+			constraint.Type = pm.Response_Validated_Constraint_FIELD_REQUIRED
+			constraint.Reason = "This field is included in the embedding input"
+		case slices.Contains(metadataFields, projection.Field):
+			constraint.Type = pm.Response_Validated_Constraint_FIELD_REQUIRED
+			constraint.Reason = "This field is included as metadata"
+		// We require collection keys be materialized because it seems pretty reasonable to
+		// require they be included as metadata since the composite key is used as the basis for
+		// the vector ID, and also to avoid complications from
+		// https://github.com/estuary/flow/issues/1057.
+		case projection.IsPrimaryKey:
+			constraint.Type = pm.Response_Validated_Constraint_LOCATION_REQUIRED
+			constraint.Reason = "Components of the collection key must be materialized"
+		case projection.Inference.IsSingleScalarType():
+			constraint.Type = pm.Response_Validated_Constraint_LOCATION_RECOMMENDED
+			constraint.Reason = "The projection has a single scalar type"
+		case projection.IsRootDocumentProjection():
+			constraint.Type = pm.Response_Validated_Constraint_LOCATION_REQUIRED
+			constraint.Reason = "The root document must be materialized"
+		default:
+			constraint.Type = pm.Response_Validated_Constraint_FIELD_OPTIONAL
+			constraint.Reason = "This field can be materializaed"
+		}
+		constraints[projection.Field] = constraint
+	}
+
+	return constraints, nil
+}
+
+// Dimensions returns the dimensions of the embeddings of the embedder, which is verified by
+// embedding a sample input.
+func Dimensions(ctx context.Context, embedder Embedder) (int, error) {
+	embeddings, err := embedder.CreateEmbeddings(ctx, []string{"Estuary Flow"})
+	if err != nil {
+		return 0, err
+	}
+	return len(embeddings[0]), nil
+}
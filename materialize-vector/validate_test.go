@@ -0,0 +1,61 @@
+package vector
+
+import (
+	"testing"
+
+	pf "github.com/estuary/flow/go/protocols/flow"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraints(t *testing.T) {
+	collection := pf.CollectionSpec{
+		Name: "acmeCo/docs",
+		Projections: []pf.Projection{
+			{Field: "flow_document", Ptr: "", Inference: pf.Inference{Types: []string{pf.JsonTypeObject}, Exists: pf.Inference_MUST}},
+			{Field: "id", Ptr: "/id", IsPrimaryKey: true, Inference: pf.Inference{Types: []string{pf.JsonTypeString}, Exists: pf.Inference_MUST}},
+			{Field: "nested", Ptr: "/nested", Inference: pf.Inference{Types: []string{pf.JsonTypeObject}, Exists: pf.Inference_MAY}},
+			{Field: "title", Ptr: "/title", Inference: pf.Inference{Types: []string{pf.JsonTypeString}, Exists: pf.Inference_MUST}},
+		},
+	}
+
+	types := func(input InputConfig, metadataFields []string) map[string]string {
+		constraints, err := Constraints(collection, input, metadataFields)
+		require.NoError(t, err)
+
+		out := make(map[string]string)
+		for f, c := range constraints {
+			out[f] = c.Type.String()
+		}
+		return out
+	}
+
+	require.Equal(t, map[string]string{
+		"flow_document": "LOCATION_REQUIRED",
+		"id":            "LOCATION_REQUIRED",
+		"nested":        "FIELD_OPTIONAL",
+		"title":         "LOCATION_RECOMMENDED",
+	}, types(InputConfig{}, nil))
+
+	require.Equal(t, map[string]string{
+		"flow_document": "LOCATION_REQUIRED",
+		"id":            "FIELD_REQUIRED",
+		"nested":        "FIELD_REQUIRED",
+		"title":         "LOCATION_RECOMMENDED",
+	}, types(InputConfig{InputTemplate: `{{ .id }}: {{ index . "nested" }}`}, nil))
+
+	require.Equal(t, map[string]string{
+		"flow_document": "LOCATION_REQUIRED",
+		"id":            "LOCATION_REQUIRED",
+		"nested":        "FIELD_OPTIONAL",
+		"title":         "FIELD_REQUIRED",
+	}, types(InputConfig{}, []string{"title"}))
+
+	_, err := Constraints(collection, InputConfig{InputFields: []string{"other"}}, nil)
+	require.ErrorContains(t, err, `input field "other" is not a field of collection "acmeCo/docs"`)
+
+	_, err = Constraints(collection, InputConfig{InputFields: []string{"flow_document"}}, nil)
+	require.ErrorContains(t, err, `input field "flow_document" is the root document`)
+
+	_, err = Constraints(collection, InputConfig{}, []string{"other"})
+	require.ErrorContains(t, err, `metadata field "other" is not a field of collection "acmeCo/docs"`)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/iancoleman/orderedmap"
+	"github.com/invopop/jsonschema"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	authTypeNone   = "none"
+	authTypeBearer = "bearer"
+	authTypeBasic  = "basic"
+	authTypeOAuth2 = "oauth2"
+
+	defaultSignatureHeader = "X-Signature-256"
+	defaultMaxRetries      = 10
+)
+
+type authConfig struct {
+	AuthType string `json:"authType,omitempty"`
+
+	// Bearer token authentication.
+	Token string `json:"token,omitempty"`
+
+	// Basic authentication.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// OAuth2 client credentials authentication.
+	TokenURL     string   `json:"tokenUrl,omitempty"`
+	ClientID     string   `json:"clientId,omitempty"`
+	ClientSecret string   `json:"clientSecret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+}
+
+func (c authConfig) Validate() error {
+	switch c.AuthType {
+	case "", authTypeNone:
+		return nil
+	case authTypeBearer:
+		if c.Token == "" {
+			return fmt.Errorf("missing token")
+		}
+	case authTypeBasic:
+		if c.Username == "" {
+			return fmt.Errorf("missing username")
+		}
+	case authTypeOAuth2:
+		if c.TokenURL == "" {
+			return fmt.Errorf("missing tokenUrl")
+		} else if c.ClientID == "" {
+			return fmt.Errorf("missing clientId")
+		} else if c.ClientSecret == "" {
+			return fmt.Errorf("missing clientSecret")
+		}
+	default:
+		return fmt.Errorf("invalid auth type %q", c.AuthType)
+	}
+	return nil
+}
+
+// apply sets the Authorization header of a request for bearer and basic authentication. OAuth2
+// tokens are instead set by the transport of the client.
+func (c authConfig) apply(req *http.Request) {
+	switch c.AuthType {
+	case authTypeBearer:
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case authTypeBasic:
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+}
+
+// JSONSchema allows for the schema to be (semi-)manually specified when used with the
+// github.com/invopop/jsonschema package in go-schema-gen, to fulfill the required schema shape for
+// a discriminated union of authentication types.
+func (authConfig) JSONSchema() *jsonschema.Schema {
+	authType := func(t string) *jsonschema.Schema {
+		return &jsonschema.Schema{Type: "string", Default: t, Const: t}
+	}
+	str := func(title, description string, secret bool) *jsonschema.Schema {
+		s := &jsonschema.Schema{Title: title, Description: description, Type: "string"}
+		if secret {
+			s.Extras = map[string]interface{}{"secret": true}
+		}
+		return s
+	}
+
+	noneProps := orderedmap.New()
+	noneProps.Set("authType", authType(authTypeNone))
+
+	bearerProps := orderedmap.New()
+	bearerProps.Set("authType", authType(authTypeBearer))
+	bearerProps.Set("token", str("Token", "Token sent in the Authorization header as 'Bearer <token>'.", true))
+
+	basicProps := orderedmap.New()
+	basicProps.Set("authType", authType(authTypeBasic))
+	basicProps.Set("username", str("Username", "Username for HTTP basic authentication.", false))
+	basicProps.Set("password", str("Password", "Password for HTTP basic authentication.", true))
+
+	oauth2Props := orderedmap.New()
+	oauth2Props.Set("authType", authType(authTypeOAuth2))
+	oauth2Props.Set("tokenUrl", str("Token URL", "URL of the token endpoint of the authorization server.", false))
+	oauth2Props.Set("clientId", str("Client ID", "Client ID of the OAuth2 client credentials.", false))
+	oauth2Props.Set("clientSecret", str("Client Secret", "Client secret of the OAuth2 client credentials.", true))
+	oauth2Props.Set("scopes", &jsonschema.Schema{
+		Title:       "Scopes",
+		Description: "Scopes to request for the access token.",
+		Type:        "array",
+		Items:       &jsonschema.Schema{Type: "string"},
+	})
+
+	return &jsonschema.Schema{
+		Title:       "Authentication",
+		Description: "Authentication of requests to the webhook.",
+		Default:     map[string]string{"authType": authTypeNone},
+		OneOf: []*jsonschema.Schema{
+			{
+				Title:      "None",
+				Required:   []string{"authType"},
+				Properties: noneProps,
+			},
+			{
+				Title:      "Bearer Token",
+				Required:   []string{"authType", "token"},
+				Properties: bearerProps,
+			},
+			{
+				Title:      "Basic Authentication",
+				Required:   []string{"authType", "username", "password"},
+				Properties: basicProps,
+			},
+			{
+				Title:      "OAuth2 Client Credentials",
+				Required:   []string{"authType", "tokenUrl", "clientId", "clientSecret"},
+				Properties: oauth2Props,
+			},
+		},
+		Extras: map[string]interface{}{
+			"discriminator": map[string]string{"propertyName": "authType"},
+		},
+		Type: "object",
+	}
+}
+
+type signingConfig struct {
+	Secret string `json:"secret,omitempty" jsonschema:"title=Signing Secret,description=Secret key with which the body of each request is signed using HMAC-SHA256. Requests are not signed if this is empty." jsonschema_extras:"secret=true"`
+	Header string `json:"header,omitempty" jsonschema:"title=Signature Header,description=Header in which the signature is sent as the hex-encoded HMAC-SHA256 digest with a 'sha256' prefix. Defaults to X-Signature-256."`
+}
+
+// signature is the value of the signature header for a request body.
+func (c signingConfig) signature(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(c.Secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c signingConfig) header() string {
+	if c.Header == "" {
+		return defaultSignatureHeader
+	}
+	return c.Header
+}
+
+// webhookClient sends requests to webhooks, retrying them per the retry policy of the endpoint.
+type webhookClient struct {
+	http             *http.Client
+	headers          map[string]string
+	auth             authConfig
+	signing          signingConfig
+	maxRetries       int
+	retryStatusCodes []int
+}
+
+func newWebhookClient(ctx context.Context, cfg config) *webhookClient {
+	var httpClient = &http.Client{}
+	if cfg.Auth.AuthType == authTypeOAuth2 {
+		var cc = clientcredentials.Config{
+			ClientID:     cfg.Auth.ClientID,
+			ClientSecret: cfg.Auth.ClientSecret,
+			TokenURL:     cfg.Auth.TokenURL,
+			Scopes:       cfg.Auth.Scopes,
+		}
+		httpClient.Transport = &oauth2.Transport{
+			Source: cc.TokenSource(ctx),
+			Base:   http.DefaultTransport,
+		}
+	}
+
+	var maxRetries = defaultMaxRetries
+	if cfg.Advanced.MaxRetries != nil {
+		maxRetries = *cfg.Advanced.MaxRetries
+	}
+
+	return &webhookClient{
+		http:             httpClient,
+		headers:          cfg.Headers,
+		auth:             cfg.Auth,
+		signing:          cfg.Signing,
+		maxRetries:       maxRetries,
+		retryStatusCodes: cfg.Advanced.RetryStatusCodes,
+	}
+}
+
+// retryable returns whether a request which failed with a response status code should be retried.
+// Every unsuccessful status code is retried if no specific status codes are configured.
+func (c *webhookClient) retryable(statusCode int) bool {
+	return len(c.retryStatusCodes) == 0 || slices.Contains(c.retryStatusCodes, statusCode)
+}
+
+// rejected returns whether a request which failed with a response status code was rejected because
+// of its documents, rather than for a transient reason. This is the case if the status code is not
+// retried, or if it's a client error other than a timeout or rate limiting, which retrying isn't
+// expected to resolve.
+func (c *webhookClient) rejected(statusCode int) bool {
+	if !c.retryable(statusCode) {
+		return true
+	}
+	return statusCode >= 400 && statusCode < 500 &&
+		statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests
+}
+
+// responseError is the error of a request which failed with an unsuccessful response status code.
+type responseError struct {
+	statusCode int
+	address    string
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("unexpected webhook response code %d from %s", e.statusCode, e.address)
+}
+
+// send invokes the webhook at address with the body, retrying failed requests.
+func (c *webhookClient) send(ctx context.Context, method, address, contentType string, body []byte) error {
+	var delay time.Duration
+
+	for attempt := 0; true; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+			// Fallthrough.
+		}
+
+		request, err := http.NewRequestWithContext(ctx, method, address, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("http.NewRequest(%s): %w", address, err)
+		}
+		request.Header.Set("Content-Type", contentType)
+		for k, v := range c.headers {
+			request.Header.Set(k, v)
+		}
+		c.auth.apply(request)
+		if c.signing.Secret != "" {
+			request.Header.Set(c.signing.header(), c.signing.signature(body))
+		}
+
+		var retry = true
+		delay = backoff(attempt + 1)
+
+		response, err := c.http.Do(request)
+		if err == nil {
+			// Drain the body so that the connection may be reused.
+			_, _ = io.Copy(io.Discard, response.Body)
+			err = response.Body.Close()
+		}
+		if err == nil && (response.StatusCode < 200 || response.StatusCode >= 300) {
+			err = &responseError{statusCode: response.StatusCode, address: address}
+			retry = c.retryable(response.StatusCode)
+
+			if after, parseErr := strconv.Atoi(response.Header.Get("Retry-After")); parseErr == nil && after > 0 {
+				delay = time.Duration(after) * time.Second
+			}
+		}
+
+		if err == nil {
+			return nil
+		} else if !retry {
+			return err
+		} else if attempt >= c.maxRetries {
+			return fmt.Errorf("webhook failed after many attempts: %w", err)
+		}
+
+		log.WithFields(log.Fields{
+			"err":     err,
+			"attempt": attempt,
+			"address": address,
+		}).Error("failed to invoke Webhook (will retry)")
+	}
+
+	panic("not reached")
+}
+
+func backoff(attempt int) time.Duration {
+	switch attempt {
+	case 0:
+		return 0
+	case 1:
+		return time.Millisecond * 100
+	case 2, 3, 4, 5, 6, 7, 8, 9, 10:
+		return time.Second * time.Duration(attempt-1)
+	default:
+		return 10 * time.Second
+	}
+}
@@ -4,11 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	m "github.com/estuary/connectors/go/protocols/materialize"
 	schemagen "github.com/estuary/connectors/go/schema-gen"
@@ -22,7 +22,34 @@ import (
 type driver struct{}
 
 type config struct {
-	Address pf.Endpoint `json:"address" jsonschema:"title=Address,description=Base address URL. Must end in a trailing '/'."`
+	Address    pf.Endpoint       `json:"address" jsonschema:"title=Address,description=Base address URL. Must end in a trailing '/'."`
+	Headers    map[string]string `json:"headers,omitempty" jsonschema:"title=Headers,description=Additional HTTP headers which are sent with every request."`
+	Auth       authConfig        `json:"auth,omitempty"`
+	Signing    signingConfig     `json:"signing,omitempty" jsonschema:"title=Request Signing"`
+	DeadLetter *deadLetterConfig `json:"dead_letter,omitempty" jsonschema:"title=Dead Letter Webhook,description=Send documents of requests which the webhook rejects to a separate webhook instead of failing the materialization. Requests are rejected by a client error response status other than 408 and 429 or by a status code that is not retried."`
+	Advanced   advancedConfig    `json:"advanced,omitempty" jsonschema:"title=Advanced Options,description=Options for advanced users. You should not typically need to modify these." jsonschema_extras:"advanced=true"`
+}
+
+type advancedConfig struct {
+	MaxBatchDocuments int   `json:"maxBatchDocuments,omitempty" jsonschema:"title=Max Batch Documents,description=Maximum number of documents sent in a single request. Documents of a transaction are sent in as few requests as possible if unset."`
+	MaxBatchBytes     int   `json:"maxBatchBytes,omitempty" jsonschema:"title=Max Batch Bytes,description=Maximum size in bytes of the body of a single request. A single document larger than this is still sent in its own request."`
+	MaxRetries        *int  `json:"maxRetries,omitempty" jsonschema:"title=Max Retries,description=Maximum number of times a failed request is retried. Defaults to 10."`
+	RetryStatusCodes  []int `json:"retryStatusCodes,omitempty" jsonschema:"title=Retry Status Codes,description=Response status codes of failed requests which are retried. All failed requests are retried if unset."`
+}
+
+// deadLetterConfig configures the webhook which dead-lettered documents are sent to.
+type deadLetterConfig struct {
+	RelativePath               string `json:"relative_path" jsonschema:"title=Relative Path,description=Path which is joined with the base Address to build the URL that dead letters are sent to."`
+	MaxDocumentsPerTransaction int    `json:"max_documents_per_transaction,omitempty" jsonschema:"title=Max Documents Per Transaction,description=Fail the materialization if more than this many documents are rejected in a single transaction. Defaults to 100."`
+}
+
+// address is the URL which dead letters are sent to.
+func (c deadLetterConfig) address(base pf.Endpoint) (*url.URL, error) {
+	rel, err := url.Parse(c.RelativePath)
+	if err != nil {
+		return nil, err
+	}
+	return base.URL().ResolveReference(rel), nil
 }
 
 // Validate returns an error if the config is not well-formed.
@@ -31,18 +58,71 @@ func (c config) Validate() error {
 		return fmt.Errorf("address: %w", err)
 	} else if !strings.HasSuffix(string(c.Address), "/") {
 		return fmt.Errorf("address must end in a trailing '/'")
+	} else if err := c.Auth.Validate(); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	} else if c.Advanced.MaxBatchDocuments < 0 {
+		return fmt.Errorf("maxBatchDocuments cannot be negative")
+	} else if c.Advanced.MaxBatchBytes < 0 {
+		return fmt.Errorf("maxBatchBytes cannot be negative")
+	} else if c.Advanced.MaxRetries != nil && *c.Advanced.MaxRetries < 0 {
+		return fmt.Errorf("maxRetries cannot be negative")
+	}
+
+	if c.DeadLetter != nil {
+		if c.DeadLetter.RelativePath == "" {
+			return fmt.Errorf("missing dead letter relative_path")
+		} else if _, err := c.DeadLetter.address(c.Address); err != nil {
+			return fmt.Errorf("dead letter relative_path: %w", err)
+		} else if c.DeadLetter.MaxDocumentsPerTransaction < 0 {
+			return fmt.Errorf("dead letter max_documents_per_transaction cannot be negative")
+		}
 	}
+
+	for _, code := range c.Advanced.RetryStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid retry status code %d", code)
+		}
+	}
+	for name := range c.Headers {
+		if name == "" {
+			return fmt.Errorf("header name cannot be empty")
+		} else if http.CanonicalHeaderKey(name) == "Authorization" && c.Auth.AuthType != "" && c.Auth.AuthType != authTypeNone {
+			return fmt.Errorf("cannot set the Authorization header when auth is configured")
+		}
+	}
+
 	return nil
 }
 
+const (
+	bodyFormatArray  = "array"
+	bodyFormatObject = "object"
+	bodyFormatNDJSON = "ndjson"
+)
+
 type resource struct {
 	RelativePath string `json:"relativePath,omitempty" jsonschema:"title=Relative Path,description=Path which is joined with the base Address to build a complete URL" jsonschema_extras:"x-collection-name=true"`
+	Method       string `json:"method,omitempty" jsonschema:"title=HTTP Method,description=HTTP method of requests. Defaults to POST.,enum=POST,enum=PUT,enum=PATCH"`
+	BodyFormat   string `json:"bodyFormat,omitempty" jsonschema:"title=Body Format,description=Shape of request bodies. An array of documents (the default) or a single document per request or newline-delimited JSON documents.,enum=array,enum=object,enum=ndjson"`
 }
 
 func (r resource) Validate() error {
 	if _, err := url.Parse(r.RelativePath); err != nil {
 		return fmt.Errorf("relativePath: %w", err)
 	}
+
+	switch r.Method {
+	case "", http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("invalid method %q: must be one of POST, PUT, or PATCH", r.Method)
+	}
+
+	switch r.BodyFormat {
+	case "", bodyFormatArray, bodyFormatObject, bodyFormatNDJSON:
+	default:
+		return fmt.Errorf("invalid bodyFormat %q: must be one of array, object, or ndjson", r.BodyFormat)
+	}
+
 	return nil
 }
 
@@ -54,6 +134,20 @@ func (r resource) URL() *url.URL {
 	return u
 }
 
+func (r resource) method() string {
+	if r.Method == "" {
+		return http.MethodPost
+	}
+	return r.Method
+}
+
+func (r resource) bodyFormat() string {
+	if r.BodyFormat == "" {
+		return bodyFormatArray
+	}
+	return r.BodyFormat
+}
+
 func (driver) Spec(ctx context.Context, req *pm.Request_Spec) (*pm.Response_Spec, error) {
 	endpointSchema, err := schemagen.GenerateSchema("Webhook", &config{}).MarshalJSON()
 	if err != nil {
@@ -80,6 +174,14 @@ func (driver) Validate(ctx context.Context, req *pm.Request_Validate) (*pm.Respo
 		return nil, fmt.Errorf("parsing endpoint config: %w", err)
 	}
 
+	if cfg.DeadLetter != nil {
+		if resolved, err := cfg.DeadLetter.address(cfg.Address); err != nil {
+			return nil, fmt.Errorf("resolving dead letter address: %w", err)
+		} else if !resolved.IsAbs() {
+			return nil, fmt.Errorf("resolved dead letter address %s is not absolute", resolved)
+		}
+	}
+
 	var out []*pm.Response_Validated_Binding
 	for _, binding := range req.Bindings {
 
@@ -129,25 +231,130 @@ func (driver) NewTransactor(ctx context.Context, open pm.Request_Open) (m.Transa
 		return nil, nil, fmt.Errorf("parsing endpoint config: %w", err)
 	}
 
-	var addresses []*url.URL
+	var bindings []*binding
 
-	for _, binding := range open.Materialization.Bindings {
+	for _, b := range open.Materialization.Bindings {
 		// Join paths of each binding with the base URL.
 		var res resource
-		if err := pf.UnmarshalStrict(binding.ResourceConfigJson, &res); err != nil {
+		if err := pf.UnmarshalStrict(b.ResourceConfigJson, &res); err != nil {
 			return nil, nil, fmt.Errorf("parsing resource config: %w", err)
 		}
-		addresses = append(addresses, cfg.Address.URL().ResolveReference(res.URL()))
+		bindings = append(bindings, &binding{
+			address: cfg.Address.URL().ResolveReference(res.URL()).String(),
+			method:  res.method(),
+			format:  res.bodyFormat(),
+		})
+	}
+
+	var client = newWebhookClient(ctx, cfg)
+
+	var deadLetters *boilerplate.DeadLetterQueue
+	if cfg.DeadLetter != nil {
+		address, err := cfg.DeadLetter.address(cfg.Address)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resolving dead letter address: %w", err)
+		}
+		deadLetters = boilerplate.NewDeadLetterQueue(
+			&deadLetterWebhook{client: client, address: address.String()},
+			cfg.DeadLetter.MaxDocumentsPerTransaction,
+			open.Materialization.Bindings,
+		)
 	}
 
 	var transactor = &transactor{
-		addresses: addresses,
+		client:       client,
+		bindings:     bindings,
+		maxDocuments: cfg.Advanced.MaxBatchDocuments,
+		maxBytes:     cfg.Advanced.MaxBatchBytes,
+		deadLetters:  deadLetters,
 	}
 	return transactor, &pm.Response_Opened{}, nil
 }
 
+// binding accumulates a batch of documents which are sent to its webhook in a single request.
+type binding struct {
+	address string
+	method  string
+	format  string
+
+	batch   bytes.Buffer
+	docs    int
+	rawDocs []json.RawMessage
+}
+
+// add appends a document to the batch.
+func (b *binding) add(doc json.RawMessage) {
+	switch b.format {
+	case bodyFormatArray:
+		if b.docs == 0 {
+			b.batch.WriteString("[\n")
+		} else {
+			b.batch.WriteString(",\n")
+		}
+		b.batch.Write(doc)
+	case bodyFormatNDJSON:
+		b.batch.Write(doc)
+		b.batch.WriteString("\n")
+	default:
+		b.batch.Write(doc)
+	}
+	b.docs++
+	b.rawDocs = append(b.rawDocs, append(json.RawMessage(nil), doc...))
+}
+
+// full returns whether the batch must be sent before a document of size n is added to it.
+func (b *binding) full(n, maxDocuments, maxBytes int) bool {
+	if b.docs == 0 {
+		return false
+	} else if b.format == bodyFormatObject {
+		return true
+	}
+	return (maxDocuments > 0 && b.docs >= maxDocuments) ||
+		(maxBytes > 0 && b.batch.Len()+n > maxBytes)
+}
+
+// body returns the request body of the batch.
+func (b *binding) body() []byte {
+	if b.format == bodyFormatArray {
+		b.batch.WriteString("\n]")
+	}
+	return b.batch.Bytes()
+}
+
+func (b *binding) contentType() string {
+	if b.format == bodyFormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// flush sends the batch of the binding, if there is one.
+func (b *binding) flush(ctx context.Context, client *webhookClient) error {
+	if b.docs == 0 {
+		return nil
+	} else if err := client.send(ctx, b.method, b.address, b.contentType(), b.body()); err != nil {
+		return err
+	}
+
+	b.reset()
+	return nil
+}
+
+// reset clears the batch for its next use.
+func (b *binding) reset() {
+	b.batch.Reset()
+	b.docs = 0
+	b.rawDocs = b.rawDocs[:0]
+}
+
 type transactor struct {
-	addresses []*url.URL
+	client       *webhookClient
+	bindings     []*binding
+	maxDocuments int
+	maxBytes     int
+
+	// Documents of requests which permanently fail are dead-lettered if this is configured.
+	deadLetters *boilerplate.DeadLetterQueue
 }
 
 func (t *transactor) UnmarshalState(state json.RawMessage) error                  { return nil }
@@ -161,87 +368,71 @@ func (d *transactor) Load(it *m.LoadIterator, _ func(int, json.RawMessage) error
 	return nil
 }
 
-// Store invokes the Webhook URL, with a body containing StoreIterator documents.
+// Store invokes the Webhook URL of each binding with batches of StoreIterator documents, which are
+// sent as they fill up.
 func (d *transactor) Store(it *m.StoreIterator) (m.StartCommitFunc, error) {
-	var bodies = make([]bytes.Buffer, len(d.addresses))
 	var ctx = it.Context()
 
-	// TODO(johnny): perform incremental POSTs rather than queuing a single call.
 	for it.Next() {
-		var b = &bodies[it.Binding]
+		var b = d.bindings[it.Binding]
 
-		if b.Len() != 0 {
-			b.WriteString(",\n")
-		} else {
-			b.WriteString("[\n")
-		}
-		if _, err := b.Write(it.RawJSON); err != nil {
-			return nil, err
+		if b.full(len(it.RawJSON), d.maxDocuments, d.maxBytes) {
+			if err := d.flush(ctx, it.Binding); err != nil {
+				return nil, err
+			}
 		}
+		b.add(it.RawJSON)
 	}
 
-	for i := range bodies {
-		bodies[i].WriteString("\n]")
+	for idx := range d.bindings {
+		if err := d.flush(ctx, idx); err != nil {
+			return nil, err
+		}
 	}
+	return nil, d.deadLetters.Flush(ctx)
+}
 
-	for i, address := range d.addresses {
-		var address = address.String()
-		var body = &bodies[i]
-
-		for attempt := 0; true; attempt++ {
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(backoff(attempt)):
-				// Fallthrough.
-			}
-
-			request, err := http.NewRequest("POST", address, bytes.NewReader(body.Bytes()))
-			if err != nil {
-				return nil, fmt.Errorf("http.NewRequest(%s): %w", address, err)
-			}
-			request.Header.Add("Content-Type", "application/json")
-
-			response, err := http.DefaultClient.Do(request)
-			if err == nil {
-				err = response.Body.Close()
-			}
-			if err == nil && (response.StatusCode < 200 || response.StatusCode >= 300) {
-				err = fmt.Errorf("unexpected webhook response code %d from %s",
-					response.StatusCode, address)
-			}
+// flush sends the batch of a binding. If the webhook rejects the request, the documents of the
+// batch are dead-lettered if possible.
+func (d *transactor) flush(ctx context.Context, binding int) error {
+	var b = d.bindings[binding]
 
-			if err == nil {
-				body.Reset() // Reset for next use.
-				break
-			} else if attempt == 10 {
-				return nil, fmt.Errorf("webhook failed after many attempts: %w", err)
-			}
+	var err = b.flush(ctx, d.client)
+	var respErr *responseError
+	if err == nil || !errors.As(err, &respErr) || !d.client.rejected(respErr.statusCode) {
+		return err
+	}
 
-			log.WithFields(log.Fields{
-				"err":     err,
-				"attempt": attempt,
-				"address": address,
-			}).Error("failed to invoke Webhook (will retry)")
+	for _, doc := range b.rawDocs {
+		if err := d.deadLetters.Reject(binding, doc, err); err != nil {
+			return err
 		}
 	}
-	return nil, nil
+	b.reset()
+	return nil
 }
 
-// Destroy is a no-op.
-func (d *transactor) Destroy() {}
+func (d *transactor) Destroy() {
+	if err := d.deadLetters.Close(context.Background()); err != nil {
+		log.WithField("error", err).Warn("closing dead letter webhook")
+	}
+}
 
-func backoff(attempt int) time.Duration {
-	switch attempt {
-	case 0:
-		return 0
-	case 1:
-		return time.Millisecond * 100
-	case 2, 3, 4, 5, 6, 7, 8, 9, 10:
-		return time.Second * time.Duration(attempt-1)
-	default:
-		return 10 * time.Second
+// deadLetterWebhook is a boilerplate.DeadLetterSink which sends dead letters to a webhook as a JSON
+// array.
+type deadLetterWebhook struct {
+	client  *webhookClient
+	address string
+}
+
+func (d *deadLetterWebhook) WriteDeadLetters(ctx context.Context, letters []boilerplate.DeadLetter) error {
+	body, err := json.Marshal(letters)
+	if err != nil {
+		return fmt.Errorf("marshalling dead letters: %w", err)
 	}
+	return d.client.send(ctx, http.MethodPost, d.address, "application/json", body)
 }
 
+func (d *deadLetterWebhook) Close(context.Context) error { return nil }
+
 func main() { boilerplate.RunMain(new(driver)) }
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/bradleyjkemp/cupaloy"
+	boilerplate "github.com/estuary/connectors/materialize-boilerplate"
+	pf "github.com/estuary/flow/go/protocols/flow"
+	pm "github.com/estuary/flow/go/protocols/materialize"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriverSpec(t *testing.T) {
+	response, err := driver{}.Spec(context.Background(), &pm.Request_Spec{})
+	require.NoError(t, err)
+
+	formatted, err := json.MarshalIndent(response, "", "  ")
+	require.NoError(t, err)
+	cupaloy.SnapshotT(t, string(formatted))
+}
+
+type receivedRequest struct {
+	method string
+	header http.Header
+	body   string
+}
+
+// testServer records requests, responding to each with the next of statuses and then with 200.
+// Errors of the handler are recorded and asserted on the test goroutine when requests are read
+// and when the test completes, as the handler runs on a goroutine of the server.
+func testServer(t *testing.T, statuses ...int) (*httptest.Server, func() []receivedRequest) {
+	var mu sync.Mutex
+	var received []receivedRequest
+	var handlerErr error
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			handlerErr = errors.Join(handlerErr, fmt.Errorf("reading request body: %w", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		received = append(received, receivedRequest{method: r.Method, header: r.Header, body: string(body)})
+
+		if len(statuses) > 0 {
+			w.WriteHeader(statuses[0])
+			statuses = statuses[1:]
+		}
+	}))
+	t.Cleanup(func() {
+		server.Close()
+		require.NoError(t, handlerErr)
+	})
+
+	return server, func() []receivedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		require.NoError(t, handlerErr)
+		return received
+	}
+}
+
+func TestBatching(t *testing.T) {
+	docs := []json.RawMessage{json.RawMessage(`{"a":1}`), json.RawMessage(`{"a":2}`), json.RawMessage(`{"a":3}`)}
+
+	for _, tt := range []struct {
+		name         string
+		format       string
+		maxDocuments int
+		maxBytes     int
+		want         []string
+	}{
+		{name: "unlimited array", format: bodyFormatArray, want: []string{"[\n{\"a\":1},\n{\"a\":2},\n{\"a\":3}\n]"}},
+		{name: "array by documents", format: bodyFormatArray, maxDocuments: 2, want: []string{"[\n{\"a\":1},\n{\"a\":2}\n]", "[\n{\"a\":3}\n]"}},
+		{name: "ndjson by bytes", format: bodyFormatNDJSON, maxBytes: 20, want: []string{"{\"a\":1}\n{\"a\":2}\n", "{\"a\":3}\n"}},
+		{name: "object", format: bodyFormatObject, want: []string{`{"a":1}`, `{"a":2}`, `{"a":3}`}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server, received := testServer(t)
+			client := newWebhookClient(context.Background(), config{})
+			b := &binding{address: server.URL, method: http.MethodPut, format: tt.format}
+
+			for _, doc := range docs {
+				if b.full(len(doc), tt.maxDocuments, tt.maxBytes) {
+					require.NoError(t, b.flush(context.Background(), client))
+				}
+				b.add(doc)
+			}
+			require.NoError(t, b.flush(context.Background(), client))
+
+			var bodies []string
+			for _, r := range received() {
+				require.Equal(t, http.MethodPut, r.method)
+				require.Equal(t, b.contentType(), r.header.Get("Content-Type"))
+				bodies = append(bodies, r.body)
+			}
+			require.Equal(t, tt.want, bodies)
+		})
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	server, received := testServer(t)
+
+	client := newWebhookClient(context.Background(), config{
+		Headers: map[string]string{"X-Custom": "value"},
+		Auth:    authConfig{AuthType: authTypeBasic, Username: "user", Password: "pass"},
+		Signing: signingConfig{Secret: "secret"},
+	})
+	require.NoError(t, client.send(context.Background(), http.MethodPost, server.URL, "application/json", []byte(`{"a":1}`)))
+
+	header := received()[0].header
+	require.Equal(t, "value", header.Get("X-Custom"))
+	require.Equal(t, "Basic dXNlcjpwYXNz", header.Get("Authorization"))
+	// echo -n '{"a":1}' | openssl dgst -sha256 -hmac secret
+	require.Equal(t, "sha256=aa9e2e3575f5d7098b6caccd790888c36d5fdb63342a73bada2d6a51747a8494", header.Get(defaultSignatureHeader))
+
+	client = newWebhookClient(context.Background(), config{
+		Auth:    authConfig{AuthType: authTypeBearer, Token: "token"},
+		Signing: signingConfig{Secret: "secret", Header: "X-Hub-Signature"},
+	})
+	require.NoError(t, client.send(context.Background(), http.MethodPost, server.URL, "application/json", []byte(`{"a":1}`)))
+
+	header = received()[1].header
+	require.Equal(t, "Bearer token", header.Get("Authorization"))
+	require.Equal(t, client.signing.signature([]byte(`{"a":1}`)), header.Get("X-Hub-Signature"))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	maxRetries := 1
+	cfg := config{Advanced: advancedConfig{MaxRetries: &maxRetries, RetryStatusCodes: []int{429}}}
+
+	// Retried status codes are retried up to the maximum number of retries.
+	server, received := testServer(t, 429, 200)
+	require.NoError(t, newWebhookClient(context.Background(), cfg).send(context.Background(), http.MethodPost, server.URL, "application/json", nil))
+	require.Len(t, received(), 2)
+
+	server, received = testServer(t, 429, 429)
+	require.ErrorContains(t, newWebhookClient(context.Background(), cfg).send(context.Background(), http.MethodPost, server.URL, "application/json", nil), "webhook failed after many attempts")
+	require.Len(t, received(), 2)
+
+	// Other status codes fail immediately.
+	server, received = testServer(t, 400)
+	require.ErrorContains(t, newWebhookClient(context.Background(), cfg).send(context.Background(), http.MethodPost, server.URL, "application/json", nil), "unexpected webhook response code 400")
+	require.Len(t, received(), 1)
+}
+
+func TestDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	cfg := config{Advanced: advancedConfig{RetryStatusCodes: []int{429}}}
+
+	// The first request of the binding is rejected, and the dead letter and the next request succeed.
+	server, received := testServer(t, 400)
+	client := newWebhookClient(ctx, cfg)
+	d := &transactor{
+		client:   client,
+		bindings: []*binding{{address: server.URL + "/docs", method: http.MethodPost, format: bodyFormatArray}},
+		deadLetters: boilerplate.NewDeadLetterQueue(
+			&deadLetterWebhook{client: client, address: server.URL + "/dead"},
+			0,
+			[]*pf.MaterializationSpec_Binding{{ResourcePath: []string{server.URL + "/docs"}}},
+		),
+	}
+
+	d.bindings[0].add(json.RawMessage(`{"a":1}`))
+	d.bindings[0].add(json.RawMessage(`{"a":2}`))
+	require.NoError(t, d.flush(ctx, 0))
+	require.Equal(t, 0, d.bindings[0].docs)
+
+	d.bindings[0].add(json.RawMessage(`{"a":3}`))
+	require.NoError(t, d.flush(ctx, 0))
+	require.NoError(t, d.deadLetters.Flush(ctx))
+	require.Len(t, received(), 3)
+
+	var letters []boilerplate.DeadLetter
+	require.NoError(t, json.Unmarshal([]byte(received()[2].body), &letters))
+	require.Len(t, letters, 2)
+	require.Equal(t, json.RawMessage(`{"a":2}`), letters[1].Document)
+	require.Contains(t, letters[1].Reason, "unexpected webhook response code 400")
+
+	// If every status code is retried, client errors are dead-lettered once the retries are exhausted.
+	server, received = testServer(t, 400, 500)
+	var maxRetries = 0
+	client = newWebhookClient(ctx, config{Advanced: advancedConfig{MaxRetries: &maxRetries}})
+	d = &transactor{
+		client:   client,
+		bindings: []*binding{{address: server.URL + "/docs", method: http.MethodPost, format: bodyFormatArray}},
+		deadLetters: boilerplate.NewDeadLetterQueue(
+			&deadLetterWebhook{client: client, address: server.URL + "/dead"},
+			0,
+			[]*pf.MaterializationSpec_Binding{{ResourcePath: []string{server.URL + "/docs"}}},
+		),
+	}
+	d.bindings[0].add(json.RawMessage(`{"a":1}`))
+	require.NoError(t, d.flush(ctx, 0))
+
+	// Server errors aren't dead-lettered.
+	d.bindings[0].add(json.RawMessage(`{"a":2}`))
+	require.ErrorContains(t, d.flush(ctx, 0), "unexpected webhook response code 500")
+	require.Len(t, received(), 2)
+
+	// Without dead-lettering, the failed request fails the transaction.
+	server, _ = testServer(t, 400)
+	d = &transactor{
+		client:   client,
+		bindings: []*binding{{address: server.URL, method: http.MethodPost, format: bodyFormatArray}},
+	}
+	d.bindings[0].add(json.RawMessage(`{"a":1}`))
+	require.ErrorContains(t, d.flush(ctx, 0), "unexpected webhook response code 400")
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := config{Address: "http://example.com/"}
+	require.NoError(t, valid.Validate())
+
+	for _, tt := range []struct {
+		cfg  config
+		want string
+	}{
+		{cfg: config{Address: "http://example.com/", Auth: authConfig{AuthType: authTypeBearer}}, want: "auth: missing token"},
+		{cfg: config{Address: "http://example.com/", Auth: authConfig{AuthType: authTypeOAuth2, TokenURL: "http://example.com/token", ClientID: "id"}}, want: "auth: missing clientSecret"},
+		{cfg: config{Address: "http://example.com/", Auth: authConfig{AuthType: "digest"}}, want: `auth: invalid auth type "digest"`},
+		{cfg: config{Address: "http://example.com/", Auth: authConfig{AuthType: authTypeBearer, Token: "t"}, Headers: map[string]string{"authorization": "x"}}, want: "cannot set the Authorization header"},
+		{cfg: config{Address: "http://example.com/", Advanced: advancedConfig{RetryStatusCodes: []int{42}}}, want: "invalid retry status code 42"},
+		{cfg: config{Address: "http://example.com/", DeadLetter: &deadLetterConfig{}}, want: "missing dead letter relative_path"},
+	} {
+		require.ErrorContains(t, tt.cfg.Validate(), tt.want)
+	}
+
+	require.ErrorContains(t, resource{Method: "GET"}.Validate(), `invalid method "GET"`)
+	require.ErrorContains(t, resource{BodyFormat: "xml"}.Validate(), `invalid bodyFormat "xml"`)
+}